@@ -6,6 +6,8 @@
 // PayPal Worker Integration
 const PayPalWorker = {
     workerUrl: 'https://pay.magicspore.com',
+    statusPollInterval: 5000,
+    statusPollLimit: 60,
     
     // Redirect to PayPal via worker
     redirectToPayPal: function(orderId) {
@@ -91,20 +93,90 @@ const PayPalWorker = {
         const urlParams = new URLSearchParams(window.location.search);
         const payment = urlParams.get('payment');
         const orderId = urlParams.get('order');
+        const statusToken = urlParams.get('status_token');
         
         if (payment === 'success' || payment === 'confirmed') {
             // Show success message
             this.showSuccessMessage(orderId);
             
-            // Redirect to thank you page after delay
+            // Redirect to thank you page after delay, keeping the status token
             setTimeout(() => {
-                window.location.href = '/dziekujemy?order=' + orderId;
+                let thankYouUrl = '/dziekujemy?order=' + encodeURIComponent(orderId);
+                if (statusToken) {
+                    thankYouUrl += '&status_token=' + encodeURIComponent(statusToken);
+                }
+                window.location.href = thankYouUrl;
             }, 3000);
+        } else if (payment === 'pending' && statusToken) {
+            // PayPal has not confirmed the payment yet: follow its progress
+            this.showPendingMessage();
+            this.pollPaymentStatus(statusToken, (status) => {
+                this.removeMessage('.paypal-pending-message');
+                if (status === 'processing' || status === 'completed') {
+                    this.showSuccessMessage(orderId);
+                }
+            });
         } else if (payment === 'cancelled') {
             this.showCancelMessage();
         }
     },
     
+    // Poll the worker's status endpoint with the signed status token until the
+    // order leaves pending. The token only reveals the order's status.
+    pollPaymentStatus: function(statusToken, onSettled, attempt = 0) {
+        if (attempt >= this.statusPollLimit) {
+            return;
+        }
+        
+        fetch(`${this.workerUrl}/api/v1/status?token=${encodeURIComponent(statusToken)}`, {
+            headers: { 'Accept': 'application/json' },
+            cache: 'no-store'
+        })
+            .then((response) => {
+                // 404 and 410 mean the token is invalid or expired: stop polling
+                if (response.status === 404 || response.status === 410) {
+                    return null;
+                }
+                return response.ok ? response.json() : { status: 'pending' };
+            })
+            .then((orderStatus) => {
+                if (!orderStatus) {
+                    return;
+                }
+                if (orderStatus.status === 'pending' || orderStatus.status === 'on-hold') {
+                    setTimeout(() => this.pollPaymentStatus(statusToken, onSettled, attempt + 1), this.statusPollInterval);
+                    return;
+                }
+                onSettled(orderStatus.status);
+            })
+            .catch(() => {
+                setTimeout(() => this.pollPaymentStatus(statusToken, onSettled, attempt + 1), this.statusPollInterval);
+            });
+    },
+    
+    // Show pending message while the payment is being confirmed
+    showPendingMessage: function() {
+        const message = document.createElement('div');
+        message.className = 'paypal-pending-message';
+        message.innerHTML = `
+            <div style="background: #fff3cd; color: #856404; padding: 1rem; border-radius: 8px; margin: 1rem 0;">
+                <h3>⏳ Confirming Payment</h3>
+                <p>PayPal is confirming your payment.</p>
+                <p>This page updates automatically.</p>
+            </div>
+        `;
+        
+        document.body.insertBefore(message, document.body.firstChild);
+    },
+    
+    // Remove a message shown earlier
+    removeMessage: function(selector) {
+        const message = document.querySelector(selector);
+        if (message) {
+            message.remove();
+        }
+    },
+    
     // Show success message
     showSuccessMessage: function(orderId) {
         const message = document.createElement('div');
@@ -199,6 +271,7 @@ const paypalStyles = `
 }
 
 .paypal-success-message,
+.paypal-pending-message,
 .paypal-cancel-message {
     position: fixed;
     top: 20px;
@@ -8,13 +8,15 @@ type PaymentRedirectRequest struct {
 	Domain  string `json:"domain"`
 }
 
-// PaymentRedirectResponse represents the response with redirect URL
+// PaymentRedirectResponse represents the response with redirect URL.
+// StatusToken lets the storefront poll the order's payment status.
 type PaymentRedirectResponse struct {
 	RedirectURL  string `json:"redirect_url"`
 	OrderID      string `json:"order_id"`
 	ProxyOrderID string `json:"proxy_order_id"`
 	Status       string `json:"status"`
 	Message      string `json:"message,omitempty"`
+	StatusToken  string `json:"status_token,omitempty"`
 }
 
 // PaymentReturnRequest represents the request from PayPal return
@@ -47,6 +49,32 @@ type PaymentCancelResponse struct {
 	Message     string `json:"message,omitempty"`
 }
 
+// PaymentRefundRequest represents a request to refund an order's payment.
+// Amount is a decimal in the order currency; leaving it empty refunds
+// everything still refundable.
+type PaymentRefundRequest struct {
+	OrderID        string `json:"-"`
+	IdempotencyKey string `json:"-"` // Idempotency-Key header; retries with the same key refund once
+	Amount         string `json:"amount,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// PaymentRefundResponse represents the outcome of a refund
+type PaymentRefundResponse struct {
+	OrderID            string   `json:"order_id"`
+	OITAMOrderID       string   `json:"oitam_order_id,omitempty"`
+	PaymentID          string   `json:"payment_id"`
+	RefundID           string   `json:"refund_id,omitempty"`
+	Status             string   `json:"status"`
+	Amount             string   `json:"amount"`
+	Currency           string   `json:"currency"`
+	SettlementAmount   string   `json:"settlement_amount"`
+	SettlementCurrency string   `json:"settlement_currency"`
+	RefundedTotal      string   `json:"refunded_total"`
+	RefundableAmount   string   `json:"refundable_amount"`
+	Warnings           []string `json:"warnings,omitempty"`
+}
+
 // WebhookRequest represents a webhook request
 type WebhookRequest struct {
 	EventType string                 `json:"event_type"`
@@ -61,6 +89,141 @@ type WebhookResponse struct {
 	Message string `json:"message"`
 }
 
+// WebhookDelivery represents a webhook as received, with its raw body and
+// signature verdict (verified, skipped or invalid)
+type WebhookDelivery struct {
+	Request   *WebhookRequest
+	Body      []byte
+	Signature string
+}
+
+// WebhookReplayResult represents the outcome of replaying one stored event
+type WebhookReplayResult struct {
+	EventID   string `json:"event_id"`
+	EventType string `json:"event_type"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+}
+
+// WebhookReplayResponse represents the outcome of a webhook replay
+type WebhookReplayResponse struct {
+	Replayed int                   `json:"replayed"`
+	Failed   int                   `json:"failed"`
+	Skipped  int                   `json:"skipped"`
+	Events   []WebhookReplayResult `json:"events"`
+}
+
+// WebhookEventResponse represents a stored webhook event without its body
+type WebhookEventResponse struct {
+	ID            string     `json:"id"`
+	EventType     string     `json:"event_type"`
+	Status        string     `json:"status"`
+	Signature     string     `json:"signature"`
+	Result        string     `json:"result,omitempty"`
+	Attempts      int        `json:"attempts"`
+	ReceivedAt    time.Time  `json:"received_at"`
+	ProcessedAt   *time.Time `json:"processed_at,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+}
+
+// DisputeResponse represents a PayPal dispute raised against a MagicSpore order.
+// Deadline is the next response due on the dispute.
+type DisputeResponse struct {
+	ID                  string     `json:"id"`
+	OrderID             string     `json:"order_id"`
+	OITAMOrderID        string     `json:"oitam_order_id,omitempty"`
+	TransactionID       string     `json:"transaction_id,omitempty"`
+	Reason              string     `json:"reason,omitempty"`
+	Status              string     `json:"status"`
+	Stage               string     `json:"stage,omitempty"`
+	Amount              string     `json:"amount"`
+	Currency            string     `json:"currency"`
+	Deadline            *time.Time `json:"deadline,omitempty"`
+	SellerResponseDueAt *time.Time `json:"seller_response_due_at,omitempty"`
+	BuyerResponseDueAt  *time.Time `json:"buyer_response_due_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// ReconciliationReport summarises one reconciliation run over active proxy orders
+type ReconciliationReport struct {
+	StartedAt     time.Time                   `json:"started_at"`
+	FinishedAt    time.Time                   `json:"finished_at"`
+	Scanned       int                         `json:"scanned"`
+	Repaired      int                         `json:"repaired"`
+	Unresolved    int                         `json:"unresolved"`
+	Discrepancies []ReconciliationDiscrepancy `json:"discrepancies"`
+}
+
+// ReconciliationDiscrepancy describes a proxy order whose stores disagree.
+// Repaired is set when the reconciler applied the fix itself; otherwise the
+// order needs a manual check.
+type ReconciliationDiscrepancy struct {
+	OrderID       string `json:"order_id"`
+	OITAMOrderID  string `json:"oitam_order_id"`
+	Kind          string `json:"kind"`
+	Detail        string `json:"detail"`
+	MagicStatus   string `json:"magic_status,omitempty"`
+	OITAMStatus   string `json:"oitam_status,omitempty"`
+	PayPalStatus  string `json:"paypal_status,omitempty"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Repaired      bool   `json:"repaired"`
+	Error         string `json:"error,omitempty"`
+}
+
+// SweepReport summarises one sweep over abandoned proxy orders
+type SweepReport struct {
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Scanned    int          `json:"scanned"`
+	Expired    int          `json:"expired"`
+	Skipped    int          `json:"skipped"`
+	Failed     int          `json:"failed"`
+	Orders     []SweptOrder `json:"orders"`
+}
+
+// SweptOrder describes what a sweep did with one abandoned proxy order
+type SweptOrder struct {
+	OrderID       string `json:"order_id"`
+	OITAMOrderID  string `json:"oitam_order_id"`
+	Outcome       string `json:"outcome"`
+	Detail        string `json:"detail"`
+	OITAMStatus   string `json:"oitam_status,omitempty"`
+	MagicRestored bool   `json:"magic_restored"`
+	Error         string `json:"error,omitempty"`
+}
+
+// OutboxEntryResponse represents a MagicSpore order update waiting in the outbox
+type OutboxEntryResponse struct {
+	ID            string     `json:"id"`
+	Sequence      int64      `json:"sequence"`
+	OrderID       string     `json:"order_id"`
+	Operation     string     `json:"operation"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+// OrderTimelineResponse represents the audit timeline of a MagicSpore order
+type OrderTimelineResponse struct {
+	OrderID string               `json:"order_id"`
+	Events  []OrderTimelineEvent `json:"events"`
+	Count   int                  `json:"count"`
+}
+
+// OrderTimelineEvent represents one recorded step of an order's payment flow
+type OrderTimelineEvent struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Message    string            `json:"message"`
+	Details    map[string]string `json:"details,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	OccurredAt time.Time         `json:"occurred_at"`
+}
+
 // OrderStatusRequest represents a request to get order status
 type OrderStatusRequest struct {
 	OrderID string `json:"order_id" validate:"required"`
@@ -78,6 +241,14 @@ type OrderStatusResponse struct {
 	TransactionID string    `json:"transaction_id,omitempty"`
 }
 
+// PublicOrderStatusResponse is the subset of OrderStatusResponse shown to a
+// customer holding a status token
+type PublicOrderStatusResponse struct {
+	OrderID  string     `json:"order_id"`
+	Status   string     `json:"status"`
+	DatePaid *time.Time `json:"date_paid"`
+}
+
 // ErrorResponse represents an error response with enhanced security
 type ErrorResponse struct {
 	Error     string `json:"error"`
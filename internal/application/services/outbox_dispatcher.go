@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/interfaces"
+	"sync"
+	"time"
+)
+
+// OutboxDispatcher delivers MagicSpore order updates waiting in the outbox in the background
+type OutboxDispatcher struct {
+	outboxUseCase *usecases.OutboxUseCase
+	config        interfaces.ConfigService
+	logger        interfaces.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewOutboxDispatcher creates a new outbox dispatcher
+func NewOutboxDispatcher(outboxUseCase *usecases.OutboxUseCase, config interfaces.ConfigService, logger interfaces.Logger) *OutboxDispatcher {
+	return &OutboxDispatcher{
+		outboxUseCase: outboxUseCase,
+		config:        config,
+		logger:        logger,
+	}
+}
+
+// Start launches the dispatch loop. It runs until Stop is called or ctx is
+// cancelled.
+func (d *OutboxDispatcher) Start(ctx context.Context) {
+	ctx, d.cancel = context.WithCancel(ctx)
+	settings := d.config.GetOutboxConfig()
+
+	d.logger.Info("Starting outbox dispatcher", map[string]interface{}{
+		"poll_interval": settings.PollInterval.String(),
+		"max_attempts":  settings.MaxAttempts,
+	})
+
+	d.wg.Add(1)
+	go d.run(ctx)
+}
+
+// Stop signals the dispatcher to finish its current entry and waits for it
+func (d *OutboxDispatcher) Stop() {
+	if d.cancel == nil {
+		return
+	}
+	d.cancel()
+	d.wg.Wait()
+}
+
+// run claims and delivers due entries, sleeping for the poll interval whenever
+// nothing is due
+func (d *OutboxDispatcher) run(ctx context.Context) {
+	defer d.wg.Done()
+
+	settings := d.config.GetOutboxConfig()
+	timeout := d.config.GetServerConfig().GetTimeout()
+
+	for ctx.Err() == nil {
+		// The lease outlives the delivery timeout, so an entry is only
+		// handed to another dispatcher once its holder has given up on it
+		entry, err := d.outboxUseCase.ClaimNext(ctx, 2*timeout)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Error("Failed to claim outbox entry", err, map[string]interface{}{})
+		}
+		if entry == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(settings.PollInterval):
+			}
+			continue
+		}
+
+		// In-flight entries are finished even when the dispatcher is stopping
+		deliverCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := d.outboxUseCase.Deliver(deliverCtx, entry); err != nil {
+			d.logger.Error("Failed to deliver outbox entry", err, map[string]interface{}{
+				"entry_id": entry.ID,
+				"order_id": entry.OrderID,
+			})
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+)
+
+// OutboxWooCommerceRepository sends MagicSpore order updates through the
+// durable outbox. Reads, refunds and OITAM calls go to the wrapped repository.
+type OutboxWooCommerceRepository struct {
+	interfaces.WooCommerceRepository
+	outbox *usecases.OutboxUseCase
+}
+
+// NewOutboxWooCommerceRepository wraps a WooCommerce repository so MagicSpore
+// order updates survive a MagicSpore outage
+func NewOutboxWooCommerceRepository(inner interfaces.WooCommerceRepository, outbox *usecases.OutboxUseCase) interfaces.WooCommerceRepository {
+	return &OutboxWooCommerceRepository{
+		WooCommerceRepository: inner,
+		outbox:                outbox,
+	}
+}
+
+// UpdateMagicOrderStatus records a status change for delivery
+func (r *OutboxWooCommerceRepository) UpdateMagicOrderStatus(ctx context.Context, orderID string, status entities.OrderStatus) error {
+	return r.outbox.Enqueue(ctx, orderID, entities.OutboxUpdateStatus, entities.OutboxPayload{Status: status})
+}
+
+// UpdateMagicOrderPayment records a payment for delivery
+func (r *OutboxWooCommerceRepository) UpdateMagicOrderPayment(ctx context.Context, orderID string, payment *entities.Payment) error {
+	return r.outbox.Enqueue(ctx, orderID, entities.OutboxUpdatePayment, entities.OutboxPayload{Payment: payment})
+}
+
+// UpdateMagicOrderTransactionID records a transaction ID for delivery
+func (r *OutboxWooCommerceRepository) UpdateMagicOrderTransactionID(ctx context.Context, orderID string, transactionID string) error {
+	return r.outbox.Enqueue(ctx, orderID, entities.OutboxUpdateTransactionID, entities.OutboxPayload{TransactionID: transactionID})
+}
+
+// AddMagicOrderNote records an order note for delivery
+func (r *OutboxWooCommerceRepository) AddMagicOrderNote(ctx context.Context, orderID string, note string) error {
+	return r.outbox.Enqueue(ctx, orderID, entities.OutboxAddNote, entities.OutboxPayload{Note: note})
+}
+
+// UpdateMagicOrderMetaData records meta data for delivery
+func (r *OutboxWooCommerceRepository) UpdateMagicOrderMetaData(ctx context.Context, orderID string, metaData []entities.MetaData) error {
+	return r.outbox.Enqueue(ctx, orderID, entities.OutboxUpdateMetaData, entities.OutboxPayload{MetaData: metaData})
+}
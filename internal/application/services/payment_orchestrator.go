@@ -5,15 +5,20 @@ import (
 	"paypal-proxy/internal/application/dto"
 	"paypal-proxy/internal/application/usecases"
 	"paypal-proxy/internal/domain/interfaces"
+	"time"
 )
 
 // PaymentOrchestrator orchestrates all payment-related use cases
 type PaymentOrchestrator struct {
-	redirectUseCase *usecases.PaymentRedirectUseCase
-	returnUseCase   *usecases.PaymentReturnUseCase
-	cancelUseCase   *usecases.PaymentCancelUseCase
-	webhookUseCase  *usecases.WebhookUseCase
-	logger          interfaces.Logger
+	redirectUseCase  *usecases.PaymentRedirectUseCase
+	returnUseCase    *usecases.PaymentReturnUseCase
+	cancelUseCase    *usecases.PaymentCancelUseCase
+	webhookUseCase   *usecases.WebhookUseCase
+	refundUseCase    *usecases.PaymentRefundUseCase
+	reconcileUseCase *usecases.ReconciliationUseCase
+	sweepUseCase     *usecases.ProxyOrderSweepUseCase
+	outboxUseCase    *usecases.OutboxUseCase
+	logger           interfaces.Logger
 }
 
 // NewPaymentOrchestrator creates a new payment orchestrator
@@ -22,14 +27,22 @@ func NewPaymentOrchestrator(
 	returnUseCase *usecases.PaymentReturnUseCase,
 	cancelUseCase *usecases.PaymentCancelUseCase,
 	webhookUseCase *usecases.WebhookUseCase,
+	refundUseCase *usecases.PaymentRefundUseCase,
+	reconcileUseCase *usecases.ReconciliationUseCase,
+	sweepUseCase *usecases.ProxyOrderSweepUseCase,
+	outboxUseCase *usecases.OutboxUseCase,
 	logger interfaces.Logger,
 ) *PaymentOrchestrator {
 	return &PaymentOrchestrator{
-		redirectUseCase: redirectUseCase,
-		returnUseCase:   returnUseCase,
-		cancelUseCase:   cancelUseCase,
-		webhookUseCase:  webhookUseCase,
-		logger:          logger,
+		redirectUseCase:  redirectUseCase,
+		returnUseCase:    returnUseCase,
+		cancelUseCase:    cancelUseCase,
+		webhookUseCase:   webhookUseCase,
+		refundUseCase:    refundUseCase,
+		reconcileUseCase: reconcileUseCase,
+		sweepUseCase:     sweepUseCase,
+		outboxUseCase:    outboxUseCase,
+		logger:           logger,
 	}
 }
 
@@ -64,14 +77,115 @@ func (po *PaymentOrchestrator) HandlePaymentCancel(ctx context.Context, request
 	return po.cancelUseCase.Execute(ctx, request)
 }
 
-// HandleWebhook handles webhook requests
-func (po *PaymentOrchestrator) HandleWebhook(ctx context.Context, request *dto.WebhookRequest) (*dto.WebhookResponse, error) {
+// HandlePaymentRefund handles refund requests
+func (po *PaymentOrchestrator) HandlePaymentRefund(ctx context.Context, request *dto.PaymentRefundRequest) (*dto.PaymentRefundResponse, error) {
+	po.logger.Info("Orchestrating payment refund", map[string]interface{}{
+		"order_id": request.OrderID,
+		"amount":   request.Amount,
+	})
+
+	return po.refundUseCase.Execute(ctx, request)
+}
+
+// HandleWebhook handles webhook deliveries
+func (po *PaymentOrchestrator) HandleWebhook(ctx context.Context, delivery *dto.WebhookDelivery) (*dto.WebhookResponse, error) {
 	po.logger.Info("Orchestrating webhook processing", map[string]interface{}{
-		"event_type": request.EventType,
-		"webhook_id": request.ID,
+		"event_type": delivery.Request.EventType,
+		"webhook_id": delivery.Request.ID,
+		"signature":  delivery.Signature,
 	})
 	
-	return po.webhookUseCase.Execute(ctx, request)
+	return po.webhookUseCase.Receive(ctx, delivery)
+}
+
+// RecordRejectedWebhook stores a webhook delivery that failed signature verification
+func (po *PaymentOrchestrator) RecordRejectedWebhook(ctx context.Context, delivery *dto.WebhookDelivery) error {
+	return po.webhookUseCase.RecordRejected(ctx, delivery)
+}
+
+// ReplayWebhook processes a stored webhook event again
+func (po *PaymentOrchestrator) ReplayWebhook(ctx context.Context, eventID string) (*dto.WebhookReplayResult, error) {
+	po.logger.Info("Orchestrating webhook replay", map[string]interface{}{
+		"webhook_id": eventID,
+	})
+
+	return po.webhookUseCase.Replay(ctx, eventID)
+}
+
+// ReplayWebhooks processes again the webhook events received in [from, to)
+func (po *PaymentOrchestrator) ReplayWebhooks(ctx context.Context, from, to time.Time) (*dto.WebhookReplayResponse, error) {
+	po.logger.Info("Orchestrating webhook range replay", map[string]interface{}{
+		"from": from,
+		"to":   to,
+	})
+
+	return po.webhookUseCase.ReplayRange(ctx, from, to)
+}
+
+// RequeueWebhook puts a stored webhook event back on the processing queue
+func (po *PaymentOrchestrator) RequeueWebhook(ctx context.Context, eventID string) (*dto.WebhookResponse, error) {
+	po.logger.Info("Orchestrating webhook requeue", map[string]interface{}{
+		"webhook_id": eventID,
+	})
+
+	return po.webhookUseCase.Requeue(ctx, eventID)
+}
+
+// DeadLetterWebhooks lists webhook events that exhausted their processing attempts
+func (po *PaymentOrchestrator) DeadLetterWebhooks(ctx context.Context, limit int) ([]dto.WebhookEventResponse, error) {
+	return po.webhookUseCase.DeadLetters(ctx, limit)
+}
+
+// OpenDisputes lists the PayPal disputes that are not resolved yet
+func (po *PaymentOrchestrator) OpenDisputes(ctx context.Context) ([]dto.DisputeResponse, error) {
+	return po.webhookUseCase.OpenDisputes(ctx)
+}
+
+// Reconcile runs a reconciliation of active proxy orders now
+func (po *PaymentOrchestrator) Reconcile(ctx context.Context) (*dto.ReconciliationReport, error) {
+	po.logger.Info("Orchestrating reconciliation", map[string]interface{}{})
+
+	return po.reconcileUseCase.Execute(ctx)
+}
+
+// LastReconciliation returns the report of the most recent reconciliation run
+func (po *PaymentOrchestrator) LastReconciliation() *dto.ReconciliationReport {
+	return po.reconcileUseCase.LastReport()
+}
+
+// SweepProxyOrders closes abandoned proxy orders past the sweep TTL now
+func (po *PaymentOrchestrator) SweepProxyOrders(ctx context.Context) (*dto.SweepReport, error) {
+	po.logger.Info("Orchestrating proxy order sweep", map[string]interface{}{})
+
+	return po.sweepUseCase.Execute(ctx)
+}
+
+// LastProxyOrderSweep returns the report of the most recent proxy order sweep
+func (po *PaymentOrchestrator) LastProxyOrderSweep() *dto.SweepReport {
+	return po.sweepUseCase.LastReport()
+}
+
+// StuckOutboxEntries lists MagicSpore order updates still undelivered past the stuck threshold
+func (po *PaymentOrchestrator) StuckOutboxEntries(ctx context.Context, limit int) ([]dto.OutboxEntryResponse, error) {
+	return po.outboxUseCase.Stuck(ctx, limit)
+}
+
+// RetryOutboxEntry gives an undelivered outbox entry a fresh set of attempts
+func (po *PaymentOrchestrator) RetryOutboxEntry(ctx context.Context, entryID string) (*dto.OutboxEntryResponse, error) {
+	po.logger.Info("Orchestrating outbox entry retry", map[string]interface{}{
+		"entry_id": entryID,
+	})
+
+	return po.outboxUseCase.Retry(ctx, entryID)
+}
+
+// DiscardOutboxEntry gives up on an undelivered outbox entry
+func (po *PaymentOrchestrator) DiscardOutboxEntry(ctx context.Context, entryID string) (*dto.OutboxEntryResponse, error) {
+	po.logger.Info("Orchestrating outbox entry discard", map[string]interface{}{
+		"entry_id": entryID,
+	})
+
+	return po.outboxUseCase.Discard(ctx, entryID)
 }
 
 // ValidateRequest validates common request parameters
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/interfaces"
+	"sync"
+	"time"
+)
+
+// ProxyOrderSweeper periodically closes abandoned proxy orders in the background
+type ProxyOrderSweeper struct {
+	sweepUseCase *usecases.ProxyOrderSweepUseCase
+	config       interfaces.ConfigService
+	logger       interfaces.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewProxyOrderSweeper creates a new background proxy order sweeper
+func NewProxyOrderSweeper(sweepUseCase *usecases.ProxyOrderSweepUseCase, config interfaces.ConfigService, logger interfaces.Logger) *ProxyOrderSweeper {
+	return &ProxyOrderSweeper{
+		sweepUseCase: sweepUseCase,
+		config:       config,
+		logger:       logger,
+	}
+}
+
+// Start launches the sweep loop. It runs until Stop is called or ctx is
+// cancelled.
+func (s *ProxyOrderSweeper) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	settings := s.config.GetSweepConfig()
+
+	s.logger.Info("Starting proxy order sweeper", map[string]interface{}{
+		"interval":   settings.Interval.String(),
+		"ttl":        settings.TTL.String(),
+		"trash":      settings.Trash,
+		"restore":    settings.RestoreMagicOrder,
+		"batch_size": settings.BatchSize,
+	})
+
+	s.wg.Add(1)
+	go s.run(ctx, settings.Interval)
+}
+
+// Stop cancels the current sweep and waits for the loop to exit
+func (s *ProxyOrderSweeper) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	s.wg.Wait()
+}
+
+// run sweeps once per interval, the first sweep one interval after start
+func (s *ProxyOrderSweeper) run(ctx context.Context, interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := s.sweepUseCase.Execute(ctx); err != nil && ctx.Err() == nil {
+			s.logger.Error("Proxy order sweep failed", err, map[string]interface{}{})
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/interfaces"
+	"sync"
+	"time"
+)
+
+// Reconciler periodically reconciles active proxy orders in the background
+type Reconciler struct {
+	reconcileUseCase *usecases.ReconciliationUseCase
+	config           interfaces.ConfigService
+	logger           interfaces.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewReconciler creates a new background reconciler
+func NewReconciler(reconcileUseCase *usecases.ReconciliationUseCase, config interfaces.ConfigService, logger interfaces.Logger) *Reconciler {
+	return &Reconciler{
+		reconcileUseCase: reconcileUseCase,
+		config:           config,
+		logger:           logger,
+	}
+}
+
+// Start launches the reconciliation loop. It runs until Stop is called or ctx
+// is cancelled.
+func (r *Reconciler) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	settings := r.config.GetReconciliationConfig()
+
+	r.logger.Info("Starting reconciler", map[string]interface{}{
+		"interval":   settings.Interval.String(),
+		"min_age":    settings.MinAge.String(),
+		"batch_size": settings.BatchSize,
+	})
+
+	r.wg.Add(1)
+	go r.run(ctx, settings.Interval)
+}
+
+// Stop cancels the current run and waits for the loop to exit
+func (r *Reconciler) Stop() {
+	if r.cancel == nil {
+		return
+	}
+	r.cancel()
+	r.wg.Wait()
+}
+
+// run reconciles once per interval, the first run one interval after start
+func (r *Reconciler) run(ctx context.Context, interval time.Duration) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := r.reconcileUseCase.Execute(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Error("Reconciliation run failed", err, map[string]interface{}{})
+		}
+	}
+}
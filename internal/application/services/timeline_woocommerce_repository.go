@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+)
+
+// TimelineWooCommerceRepository adds every status and payment write that
+// reaches MagicSpore to the order's timeline, including failed ones. Other
+// calls go to the wrapped repository unchanged.
+type TimelineWooCommerceRepository struct {
+	interfaces.WooCommerceRepository
+	timeline *usecases.OrderTimelineUseCase
+}
+
+// NewTimelineWooCommerceRepository wraps a WooCommerce repository so writes to
+// MagicSpore orders are recorded on their timelines
+func NewTimelineWooCommerceRepository(inner interfaces.WooCommerceRepository, timeline *usecases.OrderTimelineUseCase) interfaces.WooCommerceRepository {
+	return &TimelineWooCommerceRepository{
+		WooCommerceRepository: inner,
+		timeline:              timeline,
+	}
+}
+
+// UpdateMagicOrderStatus writes a status change and records it
+func (r *TimelineWooCommerceRepository) UpdateMagicOrderStatus(ctx context.Context, orderID string, status entities.OrderStatus) error {
+	err := r.WooCommerceRepository.UpdateMagicOrderStatus(ctx, orderID, status)
+	r.record(ctx, orderID, "Order status written to MagicSpore", map[string]string{
+		"status": string(status),
+	}, err)
+	return err
+}
+
+// UpdateMagicOrderPayment writes a payment and records it
+func (r *TimelineWooCommerceRepository) UpdateMagicOrderPayment(ctx context.Context, orderID string, payment *entities.Payment) error {
+	err := r.WooCommerceRepository.UpdateMagicOrderPayment(ctx, orderID, payment)
+	details := map[string]string{}
+	if payment != nil {
+		details["payment_status"] = string(payment.Status)
+		details["transaction_id"] = payment.TransactionID
+		details["amount"] = payment.Amount.Decimal()
+		details["currency"] = payment.Currency
+	}
+	r.record(ctx, orderID, "Payment written to MagicSpore", details, err)
+	return err
+}
+
+// UpdateMagicOrderTransactionID writes a transaction ID and records it
+func (r *TimelineWooCommerceRepository) UpdateMagicOrderTransactionID(ctx context.Context, orderID string, transactionID string) error {
+	err := r.WooCommerceRepository.UpdateMagicOrderTransactionID(ctx, orderID, transactionID)
+	r.record(ctx, orderID, "Transaction ID written to MagicSpore", map[string]string{
+		"transaction_id": transactionID,
+	}, err)
+	return err
+}
+
+// record adds the outcome of a MagicSpore write to the order's timeline
+func (r *TimelineWooCommerceRepository) record(ctx context.Context, orderID, message string, details map[string]string, err error) {
+	if err != nil {
+		r.timeline.RecordError(ctx, orderID, message+" failed", err)
+		return
+	}
+	r.timeline.Record(ctx, orderID, entities.OrderEventMagicOrderUpdated, message, details)
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/interfaces"
+	"sync"
+	"time"
+)
+
+// WebhookWorker runs the pool that processes queued webhook events in the background
+type WebhookWorker struct {
+	webhookUseCase *usecases.WebhookUseCase
+	config         interfaces.ConfigService
+	logger         interfaces.Logger
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWebhookWorker creates a new webhook worker pool
+func NewWebhookWorker(webhookUseCase *usecases.WebhookUseCase, config interfaces.ConfigService, logger interfaces.Logger) *WebhookWorker {
+	return &WebhookWorker{
+		webhookUseCase: webhookUseCase,
+		config:         config,
+		logger:         logger,
+	}
+}
+
+// Start launches the configured number of workers. They run until Stop is
+// called or ctx is cancelled.
+func (w *WebhookWorker) Start(ctx context.Context) {
+	ctx, w.cancel = context.WithCancel(ctx)
+	settings := w.config.GetWebhookQueueConfig()
+
+	w.logger.Info("Starting webhook workers", map[string]interface{}{
+		"workers":      settings.Workers,
+		"max_attempts": settings.MaxAttempts,
+	})
+
+	for i := 0; i < settings.Workers; i++ {
+		w.wg.Add(1)
+		go w.run(ctx, i)
+	}
+}
+
+// Stop signals the workers to finish their current event and waits for them
+func (w *WebhookWorker) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	w.wg.Wait()
+}
+
+// run claims and processes due events, sleeping for the poll interval whenever
+// the queue is empty
+func (w *WebhookWorker) run(ctx context.Context, worker int) {
+	defer w.wg.Done()
+
+	settings := w.config.GetWebhookQueueConfig()
+	timeout := w.config.GetServerConfig().GetTimeout()
+
+	for ctx.Err() == nil {
+		// The lease outlives the processing timeout, so an event is only
+		// handed to another worker once its holder has given up on it
+		event, err := w.webhookUseCase.ClaimNext(ctx, 2*timeout)
+		if err != nil && ctx.Err() == nil {
+			w.logger.Error("Failed to claim webhook event", err, map[string]interface{}{
+				"worker": worker,
+			})
+		}
+		if event == nil {
+			select {
+			case <-ctx.Done():
+			case <-time.After(settings.PollInterval):
+			}
+			continue
+		}
+
+		// In-flight events are finished even when the pool is stopping
+		processCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err := w.webhookUseCase.ProcessQueued(processCtx, event); err != nil {
+			w.logger.Error("Failed to process queued webhook event", err, map[string]interface{}{
+				"worker":     worker,
+				"webhook_id": event.ID,
+			})
+		}
+		cancel()
+	}
+}
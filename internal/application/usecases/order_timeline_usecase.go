@@ -0,0 +1,96 @@
+package usecases
+
+import (
+	"context"
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+)
+
+// OrderTimelineUseCase keeps the append-only audit timeline of each MagicSpore
+// order's payment flow. Recording is best effort: a failure to store an event
+// is logged and never fails the step being recorded. A nil use case records
+// nothing.
+type OrderTimelineUseCase struct {
+	eventRepo interfaces.OrderEventRepository
+	logger    interfaces.Logger
+}
+
+// NewOrderTimelineUseCase creates a new order timeline use case
+func NewOrderTimelineUseCase(eventRepo interfaces.OrderEventRepository, logger interfaces.Logger) *OrderTimelineUseCase {
+	return &OrderTimelineUseCase{
+		eventRepo: eventRepo,
+		logger:    logger,
+	}
+}
+
+// Record appends a step to an order's timeline
+func (uc *OrderTimelineUseCase) Record(ctx context.Context, orderID string, eventType entities.OrderEventType, message string, details map[string]string) {
+	if uc == nil || orderID == "" {
+		return
+	}
+	uc.append(ctx, entities.NewOrderEvent(orderID, eventType, message, details))
+}
+
+// RecordError appends a failed step to an order's timeline
+func (uc *OrderTimelineUseCase) RecordError(ctx context.Context, orderID string, message string, err error) {
+	if uc == nil || orderID == "" || err == nil {
+		return
+	}
+	event := entities.NewOrderEvent(orderID, entities.OrderEventError, message, nil)
+	event.Error = err.Error()
+	uc.append(ctx, event)
+}
+
+// Timeline returns an order's events in the order they were recorded
+func (uc *OrderTimelineUseCase) Timeline(ctx context.Context, orderID string) (*dto.OrderTimelineResponse, error) {
+	events, err := uc.eventRepo.ListByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.OrderTimelineResponse{
+		OrderID: orderID,
+		Events:  make([]dto.OrderTimelineEvent, 0, len(events)),
+		Count:   len(events),
+	}
+	for _, event := range events {
+		response.Events = append(response.Events, dto.OrderTimelineEvent{
+			ID:         event.ID,
+			Type:       string(event.Type),
+			Message:    event.Message,
+			Details:    event.Details,
+			Error:      event.Error,
+			OccurredAt: event.OccurredAt,
+		})
+	}
+	return response, nil
+}
+
+// append stores an event, logging rather than returning a failure
+func (uc *OrderTimelineUseCase) append(ctx context.Context, event *entities.OrderEvent) {
+	// The event is still worth keeping when the request that caused it was cancelled
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+	if err := uc.eventRepo.Append(ctx, event); err != nil {
+		uc.logger.Error("Failed to record order event", err, map[string]interface{}{
+			"order_id": event.OrderID,
+			"type":     event.Type,
+		})
+	}
+}
+
+// recordStatusHistory stores the order's status history on the MagicSpore
+// order after a transition was persisted. Like the timeline it is best effort.
+func recordStatusHistory(ctx context.Context, wooCommerceRepo interfaces.WooCommerceRepository, logger interfaces.Logger, orderID string, order *entities.Order) {
+	if order == nil {
+		return
+	}
+	if err := wooCommerceRepo.UpdateMagicOrderMetaData(ctx, orderID, []entities.MetaData{order.StatusHistoryMetaData()}); err != nil {
+		logger.Error("Failed to record order status history", err, map[string]interface{}{
+			"order_id": orderID,
+			"status":   order.Status,
+		})
+	}
+}
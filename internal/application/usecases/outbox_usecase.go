@@ -0,0 +1,329 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"time"
+)
+
+// ErrOutboxEntryNotFound is returned for an outbox entry ID that does not exist
+var ErrOutboxEntryNotFound = errors.New("outbox entry not found")
+
+// ErrOutboxEntryFinished is returned when retrying or discarding an entry that
+// was already delivered or discarded
+var ErrOutboxEntryFinished = errors.New("outbox entry is no longer undelivered")
+
+// OutboxUseCase records MagicSpore order updates in a durable outbox before
+// sending them, and delivers them in order per MagicSpore order until
+// MagicSpore accepts them. Delivery is at least once; every operation is safe
+// to apply twice except notes, which may then appear twice.
+type OutboxUseCase struct {
+	wooCommerceRepo interfaces.WooCommerceRepository
+	outboxRepo      interfaces.OutboxRepository
+	logger          interfaces.Logger
+	config          interfaces.ConfigService
+	orderLocks      *keyedMutex
+}
+
+// NewOutboxUseCase creates a new outbox use case. wooCommerceRepo must send
+// updates to MagicSpore directly rather than through the outbox.
+func NewOutboxUseCase(
+	wooCommerceRepo interfaces.WooCommerceRepository,
+	outboxRepo interfaces.OutboxRepository,
+	logger interfaces.Logger,
+	config interfaces.ConfigService,
+) *OutboxUseCase {
+	return &OutboxUseCase{
+		wooCommerceRepo: wooCommerceRepo,
+		outboxRepo:      outboxRepo,
+		logger:          logger,
+		config:          config,
+		orderLocks:      newKeyedMutex(),
+	}
+}
+
+// Enqueue records an update of a MagicSpore order and tries to deliver it
+// right away. Once the update is recorded it is only reported as failed to
+// the dispatcher, so nil means MagicSpore will receive it eventually. When
+// the outbox cannot be written the update is sent directly instead.
+func (uc *OutboxUseCase) Enqueue(ctx context.Context, orderID string, operation entities.OutboxOperation, payload entities.OutboxPayload) error {
+	entry := entities.NewOutboxEntry(orderID, operation, payload)
+	if err := uc.outboxRepo.Create(ctx, entry); err != nil {
+		uc.logger.Error("Failed to record order update in outbox - sending it directly", err, map[string]interface{}{
+			"order_id":  orderID,
+			"operation": operation,
+		})
+		return uc.apply(ctx, entry)
+	}
+
+	unlock := uc.orderLocks.Lock(orderID)
+	defer unlock()
+
+	if err := uc.deliverOrder(ctx, orderID); err != nil {
+		uc.logger.Error("Failed to deliver order update - left to the dispatcher", err, map[string]interface{}{
+			"order_id": orderID,
+			"entry_id": entry.ID,
+		})
+	}
+	return nil
+}
+
+// ClaimNext leases the oldest due entry whose order has nothing undelivered
+// before it, or returns nil when there is none
+func (uc *OutboxUseCase) ClaimNext(ctx context.Context, lease time.Duration) (*entities.OutboxEntry, error) {
+	now := time.Now()
+	due, err := uc.outboxRepo.ListDue(ctx, now, claimBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Microsecond precision survives every store, so the lease can be compared later
+	leaseUntil := now.Add(lease).Round(0).Truncate(time.Microsecond)
+	for _, entry := range due {
+		claimed, err := uc.outboxRepo.Claim(ctx, entry, leaseUntil)
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			return entry, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Deliver sends a claimed entry to MagicSpore. Failures are retried with
+// exponential backoff until the configured attempts are used up, after which
+// the entry and the rest of its order wait for an operator.
+func (uc *OutboxUseCase) Deliver(ctx context.Context, claimed *entities.OutboxEntry) error {
+	unlock := uc.orderLocks.Lock(claimed.OrderID)
+	defer unlock()
+
+	_, err := uc.deliver(ctx, claimed)
+	return err
+}
+
+// Stuck returns up to limit entries still undelivered after the configured
+// stuck threshold, oldest first
+func (uc *OutboxUseCase) Stuck(ctx context.Context, limit int) ([]dto.OutboxEntryResponse, error) {
+	createdBefore := time.Now().Add(-uc.config.GetOutboxConfig().StuckAfter)
+	entries, err := uc.outboxRepo.ListUndelivered(ctx, createdBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.OutboxEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		responses = append(responses, outboxEntryResponse(entry))
+	}
+	return responses, nil
+}
+
+// Retry gives an undelivered entry a fresh set of attempts and tries to
+// deliver its order's updates right away
+func (uc *OutboxUseCase) Retry(ctx context.Context, id string) (*dto.OutboxEntryResponse, error) {
+	entry, err := uc.getEntry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := uc.orderLocks.Lock(entry.OrderID)
+	defer unlock()
+
+	// Re-read under the lock in case a delivery finished meanwhile
+	if entry, err = uc.getEntry(ctx, id); err != nil {
+		return nil, err
+	}
+	if !entry.IsUndelivered() {
+		return nil, fmt.Errorf("outbox entry %s is already %s: %w", id, entry.Status, ErrOutboxEntryFinished)
+	}
+
+	entry.Requeue(time.Now().Round(0).Truncate(time.Microsecond))
+	if err := uc.outboxRepo.Update(ctx, entry); err != nil {
+		return nil, fmt.Errorf("failed to requeue outbox entry: %w", err)
+	}
+
+	uc.logger.Info("Outbox entry requeued", map[string]interface{}{
+		"entry_id": entry.ID,
+		"order_id": entry.OrderID,
+	})
+
+	if err := uc.deliverOrder(ctx, entry.OrderID); err != nil {
+		return nil, err
+	}
+
+	if entry, err = uc.getEntry(ctx, id); err != nil {
+		return nil, err
+	}
+	response := outboxEntryResponse(entry)
+	return &response, nil
+}
+
+// Discard gives up on an undelivered entry, releasing the later updates of its order
+func (uc *OutboxUseCase) Discard(ctx context.Context, id string) (*dto.OutboxEntryResponse, error) {
+	entry, err := uc.getEntry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := uc.orderLocks.Lock(entry.OrderID)
+	defer unlock()
+
+	if entry, err = uc.getEntry(ctx, id); err != nil {
+		return nil, err
+	}
+	if !entry.IsUndelivered() {
+		return nil, fmt.Errorf("outbox entry %s is already %s: %w", id, entry.Status, ErrOutboxEntryFinished)
+	}
+
+	entry.Discard()
+	if err := uc.outboxRepo.Update(ctx, entry); err != nil {
+		return nil, fmt.Errorf("failed to discard outbox entry: %w", err)
+	}
+
+	uc.logger.Warn("Outbox entry discarded - the order update will not be delivered", map[string]interface{}{
+		"entry_id":   entry.ID,
+		"order_id":   entry.OrderID,
+		"operation":  entry.Operation,
+		"attempts":   entry.Attempts,
+		"last_error": entry.LastError,
+	})
+
+	response := outboxEntryResponse(entry)
+	return &response, nil
+}
+
+// getEntry reads an entry by ID, reporting an unknown ID as ErrOutboxEntryNotFound
+func (uc *OutboxUseCase) getEntry(ctx context.Context, id string) (*entities.OutboxEntry, error) {
+	entry, err := uc.outboxRepo.GetByID(ctx, id)
+	if errors.Is(err, interfaces.ErrNotFound) {
+		return nil, fmt.Errorf("outbox entry %s: %w", id, ErrOutboxEntryNotFound)
+	}
+	return entry, err
+}
+
+// deliverOrder delivers an order's due entries in sequence order, stopping at
+// the first one that is not due or fails. The caller holds the order's lock.
+func (uc *OutboxUseCase) deliverOrder(ctx context.Context, orderID string) error {
+	entries, err := uc.outboxRepo.ListByOrderID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	leaseUntil := now.Add(2 * uc.config.GetServerConfig().GetTimeout()).Round(0).Truncate(time.Microsecond)
+	for _, entry := range entries {
+		if !entry.IsUndelivered() {
+			continue
+		}
+		// Failed, retrying later or held by the dispatcher
+		if !entry.IsPending() || entry.NextAttemptAt.After(now) {
+			return nil
+		}
+
+		claimed, err := uc.outboxRepo.Claim(ctx, entry, leaseUntil)
+		if err != nil || !claimed {
+			return err
+		}
+		delivered, err := uc.deliver(ctx, entry)
+		if err != nil || !delivered {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deliver sends a claimed entry and records the outcome. The caller holds the
+// order's lock.
+func (uc *OutboxUseCase) deliver(ctx context.Context, claimed *entities.OutboxEntry) (bool, error) {
+	// The entry may have been discarded or re-claimed after a lost lease
+	entry, err := uc.outboxRepo.GetByID(ctx, claimed.ID)
+	if err != nil {
+		return false, err
+	}
+	if !entry.IsPending() || !entry.NextAttemptAt.Equal(*claimed.NextAttemptAt) {
+		uc.logger.Debug("Skipping outbox entry no longer held by this dispatcher", map[string]interface{}{
+			"entry_id": entry.ID,
+			"status":   entry.Status,
+		})
+		return false, nil
+	}
+
+	err = uc.apply(ctx, entry)
+
+	settings := uc.config.GetOutboxConfig()
+	switch {
+	case err == nil:
+		entry.RecordDelivery()
+		uc.logger.Debug("Order update delivered", map[string]interface{}{
+			"entry_id":  entry.ID,
+			"order_id":  entry.OrderID,
+			"operation": entry.Operation,
+			"attempts":  entry.Attempts,
+		})
+	case entry.Attempts+1 >= settings.MaxAttempts:
+		entry.RecordFailure(err.Error())
+		uc.logger.Error("Order update failed - waiting for an operator", err, map[string]interface{}{
+			"entry_id":  entry.ID,
+			"order_id":  entry.OrderID,
+			"operation": entry.Operation,
+			"attempts":  entry.Attempts,
+		})
+	default:
+		retryAt := time.Now().Add(backoffDelay(settings.RetryBaseDelay, settings.RetryMaxDelay, entry.Attempts+1))
+		entry.ScheduleRetry(err.Error(), retryAt)
+		uc.logger.Warn("Order update failed - retry scheduled", map[string]interface{}{
+			"entry_id":  entry.ID,
+			"order_id":  entry.OrderID,
+			"operation": entry.Operation,
+			"attempts":  entry.Attempts,
+			"retry_at":  retryAt,
+			"error":     err.Error(),
+		})
+	}
+
+	if updateErr := uc.outboxRepo.Update(ctx, entry); updateErr != nil {
+		return false, fmt.Errorf("failed to record outbox delivery: %w", updateErr)
+	}
+	return entry.Status == entities.OutboxDelivered, nil
+}
+
+// apply sends an entry's update to MagicSpore
+func (uc *OutboxUseCase) apply(ctx context.Context, entry *entities.OutboxEntry) error {
+	payload := entry.Payload
+	switch entry.Operation {
+	case entities.OutboxUpdatePayment:
+		return uc.wooCommerceRepo.UpdateMagicOrderPayment(ctx, entry.OrderID, payload.Payment)
+	case entities.OutboxUpdateStatus:
+		return uc.wooCommerceRepo.UpdateMagicOrderStatus(ctx, entry.OrderID, payload.Status)
+	case entities.OutboxUpdateTransactionID:
+		return uc.wooCommerceRepo.UpdateMagicOrderTransactionID(ctx, entry.OrderID, payload.TransactionID)
+	case entities.OutboxAddNote:
+		return uc.wooCommerceRepo.AddMagicOrderNote(ctx, entry.OrderID, payload.Note)
+	case entities.OutboxUpdateMetaData:
+		return uc.wooCommerceRepo.UpdateMagicOrderMetaData(ctx, entry.OrderID, payload.MetaData)
+	default:
+		return fmt.Errorf("unknown outbox operation %q", entry.Operation)
+	}
+}
+
+// outboxEntryResponse maps an entry to its admin representation
+func outboxEntryResponse(entry *entities.OutboxEntry) dto.OutboxEntryResponse {
+	return dto.OutboxEntryResponse{
+		ID:            entry.ID,
+		Sequence:      entry.Sequence,
+		OrderID:       entry.OrderID,
+		Operation:     string(entry.Operation),
+		Status:        string(entry.Status),
+		Attempts:      entry.Attempts,
+		LastError:     entry.LastError,
+		CreatedAt:     entry.CreatedAt,
+		NextAttemptAt: entry.NextAttemptAt,
+		DeliveredAt:   entry.DeliveredAt,
+	}
+}
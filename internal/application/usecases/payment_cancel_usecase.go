@@ -12,6 +12,8 @@ import (
 // PaymentCancelUseCase handles the payment cancel use case
 type PaymentCancelUseCase struct {
 	wooCommerceRepo interfaces.WooCommerceRepository
+	paymentRepo     interfaces.PaymentRepository
+	mappingRepo     interfaces.OrderMappingRepository
 	paymentService  *services.PaymentDomainService
 	orderService    *services.OrderDomainService
 	logger          interfaces.Logger
@@ -21,6 +23,8 @@ type PaymentCancelUseCase struct {
 // NewPaymentCancelUseCase creates a new payment cancel use case
 func NewPaymentCancelUseCase(
 	wooCommerceRepo interfaces.WooCommerceRepository,
+	paymentRepo interfaces.PaymentRepository,
+	mappingRepo interfaces.OrderMappingRepository,
 	paymentService *services.PaymentDomainService,
 	orderService *services.OrderDomainService,
 	logger interfaces.Logger,
@@ -28,6 +32,8 @@ func NewPaymentCancelUseCase(
 ) *PaymentCancelUseCase {
 	return &PaymentCancelUseCase{
 		wooCommerceRepo: wooCommerceRepo,
+		paymentRepo:     paymentRepo,
+		mappingRepo:     mappingRepo,
 		paymentService:  paymentService,
 		orderService:    orderService,
 		logger:          logger,
@@ -44,46 +50,76 @@ func (uc *PaymentCancelUseCase) Execute(ctx context.Context, request *dto.Paymen
 
 	returnURLs := uc.config.GetReturnURLs()
 
-	// Update order status if order ID is provided
-	if request.OrderID != "" {
+	// Resolve the order pair server-side; the URL may carry only one side
+	mapping := uc.resolveOrderMapping(ctx, request)
+	orderID := request.OrderID
+	if orderID == "" && mapping != nil {
+		orderID = mapping.MagicOrderID
+	}
+
+	// A late cancel redirect must never cancel an order that has been paid
+	if mapping != nil && mapping.State == entities.MappingStatePaid {
+		uc.logger.Warn("Ignoring cancellation of paid order", map[string]interface{}{
+			"order_id":       orderID,
+			"oitam_order_id": mapping.OITAMOrderID,
+		})
+
+		return &dto.PaymentCancelResponse{
+			RedirectURL: fmt.Sprintf("%s?order=%s&already_paid=1", returnURLs.Success, orderID),
+			Status:      "already_paid",
+			Message:     "Order payment already completed",
+		}, nil
+	}
+
+	// Update order status if order ID is provided and its state machine allows it
+	if order := uc.cancellableMagicOrder(ctx, orderID); order != nil {
 		// Create a cancelled payment record
 		cancelledPayment := uc.paymentService.CreatePaymentRecord(
 			ctx,
-			request.OrderID,
+			orderID,
 			"", // No payment ID for cancelled payments
 			"", // No payer ID for cancelled payments
 			entities.Money{}, // No amount for cancelled payments
 			entities.PaymentStatusCancelled,
 		)
 
-		// Store the cancelled payment record (commented out as we don't have a payment repository yet)
-		// TODO: Implement payment repository for storing payment records
-		// if err := uc.paymentRepo.Create(ctx, cancelledPayment); err != nil {
-		//     uc.logger.Error("Failed to store cancelled payment record", err, map[string]interface{}{
-		//         "order_id": request.OrderID,
-		//     })
-		//     // Continue even if storage fails
-		// }
+		// Store the cancelled payment record
+		if err := uc.paymentRepo.Create(ctx, cancelledPayment); err != nil {
+			uc.logger.Error("Failed to store cancelled payment record", err, map[string]interface{}{
+				"order_id": orderID,
+			})
+			// Continue even if storage fails
+		}
 
 		// Update original order status to cancelled
-		err := uc.wooCommerceRepo.UpdateMagicOrderStatus(ctx, request.OrderID, entities.StatusCancelled)
+		err := uc.wooCommerceRepo.UpdateMagicOrderStatus(ctx, orderID, entities.StatusCancelled)
 		if err != nil {
 			uc.logger.Error("Failed to update order status to cancelled", err, map[string]interface{}{
-				"order_id": request.OrderID,
+				"order_id": orderID,
 			})
 			// Don't fail the entire operation if order update fails
 		} else {
 			uc.logger.Info("Order status updated to cancelled", map[string]interface{}{
-				"order_id": request.OrderID,
+				"order_id": orderID,
+			})
+			recordStatusHistory(ctx, uc.wooCommerceRepo, uc.logger, orderID, order)
+		}
+	}
+
+	if mapping != nil && !mapping.IsFinal() {
+		if err := uc.mappingRepo.UpdateState(ctx, mapping.OITAMOrderID, entities.MappingStateCancelled); err != nil {
+			uc.logger.Error("Failed to mark order mapping as cancelled", err, map[string]interface{}{
+				"order_id":       orderID,
+				"oitam_order_id": mapping.OITAMOrderID,
 			})
 		}
 	}
 
 	// Build cancel redirect URL
-	cancelURL := fmt.Sprintf("%s?order=%s&payment=cancelled", returnURLs.Cancel, request.OrderID)
+	cancelURL := fmt.Sprintf("%s?order=%s&payment=cancelled", returnURLs.Cancel, orderID)
 
 	uc.logger.Info("Payment cancellation processed", map[string]interface{}{
-		"order_id":     request.OrderID,
+		"order_id":     orderID,
 		"redirect_url": cancelURL,
 	})
 
@@ -92,4 +128,61 @@ func (uc *PaymentCancelUseCase) Execute(ctx context.Context, request *dto.Paymen
 		Status:      "cancelled",
 		Message:     "Payment cancelled by customer",
 	}, nil
-}
\ No newline at end of file
+}
+
+// cancellableMagicOrder moves the original order to cancelled when its state
+// machine allows it, so a late cancel redirect never cancels an order that was
+// paid or refunded. It returns nil when the order cannot be cancelled.
+func (uc *PaymentCancelUseCase) cancellableMagicOrder(ctx context.Context, orderID string) *entities.Order {
+	if orderID == "" {
+		return nil
+	}
+
+	order, err := uc.wooCommerceRepo.GetMagicOrder(ctx, orderID)
+	if err != nil {
+		uc.logger.Error("Failed to fetch order for cancellation", err, map[string]interface{}{
+			"order_id": orderID,
+		})
+		return nil
+	}
+
+	if err := uc.orderService.TransitionOrder(ctx, order, entities.StatusCancelled, "customer cancelled payment"); err != nil {
+		return nil
+	}
+	return order
+}
+
+// resolveOrderMapping looks up the stored proxy order for the cancelled order,
+// returning nil when no mapping exists
+func (uc *PaymentCancelUseCase) resolveOrderMapping(ctx context.Context, request *dto.PaymentCancelRequest) *entities.OrderMapping {
+	var mapping *entities.OrderMapping
+	var err error
+
+	switch {
+	case request.OrderID != "":
+		mapping, err = uc.mappingRepo.GetByMagicOrderID(ctx, request.OrderID)
+	case request.OITAMOrderID != "":
+		mapping, err = uc.mappingRepo.GetByOITAMOrderID(ctx, request.OITAMOrderID)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		uc.logger.Warn("No order mapping found for payment cancellation", map[string]interface{}{
+			"order_id":       request.OrderID,
+			"oitam_order_id": request.OITAMOrderID,
+			"error":          err.Error(),
+		})
+		return nil
+	}
+
+	if request.OITAMOrderID != "" && request.OITAMOrderID != mapping.OITAMOrderID {
+		uc.logger.Warn("OITAM order ID in cancel URL does not match stored mapping", map[string]interface{}{
+			"order_id":              mapping.MagicOrderID,
+			"oitam_order_id":        mapping.OITAMOrderID,
+			"requested_oitam_order": request.OITAMOrderID,
+		})
+	}
+
+	return mapping
+}
@@ -3,43 +3,75 @@ package usecases
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"paypal-proxy/internal/application/dto"
 	"paypal-proxy/internal/domain/entities"
 	"paypal-proxy/internal/domain/interfaces"
 	"paypal-proxy/internal/domain/services"
+	"strings"
+	"sync"
+	"time"
 )
 
 // PaymentRedirectUseCase handles the payment redirect use case
 type PaymentRedirectUseCase struct {
 	wooCommerceRepo interfaces.WooCommerceRepository
+	mappingRepo     interfaces.OrderMappingRepository
 	urlBuilder      interfaces.URLBuilder
 	orderService    *services.OrderDomainService
 	paymentService  *services.PaymentDomainService
+	currencyService *services.CurrencyConversionService
+	statusTokens    *services.StatusTokenService
+	timeline        *OrderTimelineUseCase
 	logger          interfaces.Logger
 	config          interfaces.ConfigService
+	orderLocks      *keyedMutex
 }
 
-// NewPaymentRedirectUseCase creates a new payment redirect use case
+// NewPaymentRedirectUseCase creates a new payment redirect use case.
+// Without a currency service, proxy orders keep the MagicSpore order currency;
+// without a timeline, no audit events are recorded.
 func NewPaymentRedirectUseCase(
 	wooCommerceRepo interfaces.WooCommerceRepository,
+	mappingRepo interfaces.OrderMappingRepository,
 	urlBuilder interfaces.URLBuilder,
 	orderService *services.OrderDomainService,
 	paymentService *services.PaymentDomainService,
+	currencyService *services.CurrencyConversionService,
+	timeline *OrderTimelineUseCase,
 	logger interfaces.Logger,
 	config interfaces.ConfigService,
 ) *PaymentRedirectUseCase {
 	return &PaymentRedirectUseCase{
 		wooCommerceRepo: wooCommerceRepo,
+		mappingRepo:     mappingRepo,
 		urlBuilder:      urlBuilder,
 		orderService:    orderService,
 		paymentService:  paymentService,
+		currencyService: currencyService,
+		statusTokens:    services.NewStatusTokenService(config.GetStatusTokenConfig()),
+		timeline:        timeline,
 		logger:          logger,
 		config:          config,
+		orderLocks:      newKeyedMutex(),
 	}
 }
 
 // Execute executes the payment redirect use case
 func (uc *PaymentRedirectUseCase) Execute(ctx context.Context, request *dto.PaymentRedirectRequest) (*dto.PaymentRedirectResponse, error) {
+	uc.timeline.Record(ctx, request.OrderID, entities.OrderEventRedirectRequested, "Payment redirect requested", map[string]string{
+		"domain": request.Domain,
+	})
+
+	response, err := uc.execute(ctx, request)
+	if err != nil {
+		uc.timeline.RecordError(ctx, request.OrderID, "Payment redirect failed", err)
+	}
+	return response, err
+}
+
+// execute runs the redirect steps for Execute
+func (uc *PaymentRedirectUseCase) execute(ctx context.Context, request *dto.PaymentRedirectRequest) (*dto.PaymentRedirectResponse, error) {
 	uc.logger.Info("Starting payment redirect", map[string]interface{}{
 		"order_id": request.OrderID,
 		"domain":   request.Domain,
@@ -64,13 +96,15 @@ func (uc *PaymentRedirectUseCase) Execute(ctx context.Context, request *dto.Paym
 			})
 			
 			returnURLs := uc.config.GetReturnURLs()
-			successURL := fmt.Sprintf("%s?order=%s&already_paid=1", returnURLs.Success, request.OrderID)
+			statusToken := uc.statusTokens.Issue(request.OrderID, magicOrder.OrderKey)
+			successURL := withStatusToken(fmt.Sprintf("%s?order=%s&already_paid=1", returnURLs.Success, request.OrderID), statusToken)
 			
 			return &dto.PaymentRedirectResponse{
 				RedirectURL:  successURL,
 				OrderID:      request.OrderID,
 				Status:       "already_paid",
 				Message:      "Order payment already completed",
+				StatusToken:  statusToken,
 			}, nil
 		}
 		
@@ -81,8 +115,27 @@ func (uc *PaymentRedirectUseCase) Execute(ctx context.Context, request *dto.Paym
 		return nil, fmt.Errorf("order validation failed: %w", err)
 	}
 
-	// 3. Create anonymous proxy order
-	anonymousOrder, err := uc.orderService.CreateAnonymousOrder(ctx, magicOrder)
+	// Serialize redirects per order so a double-click cannot create two proxy orders
+	unlock := uc.orderLocks.Lock(request.OrderID)
+	defer unlock()
+
+	// 3. Reuse an open proxy order for the same order and amount
+	if existing := uc.findReusableProxyOrder(ctx, request.OrderID, magicOrder); existing != nil {
+		response := uc.buildRedirectResponse(request, magicOrder, existing)
+		response.Status = "redirect_reused"
+		response.Message = "Redirect to existing PayPal checkout"
+
+		uc.logger.Info("Reusing existing proxy order", map[string]interface{}{
+			"order_id":       request.OrderID,
+			"oitam_order_id": existing.ID,
+		})
+		uc.recordCheckoutURL(ctx, response, true)
+
+		return response, nil
+	}
+
+	// 4. Create anonymous proxy order
+	anonymousOrder, err := uc.orderService.CreateAnonymousOrder(ctx, magicOrder, uc.config.GetAnonymizationPolicy())
 	if err != nil {
 		uc.logger.Error("Failed to create anonymous order", err, map[string]interface{}{
 			"order_id": request.OrderID,
@@ -90,7 +143,17 @@ func (uc *PaymentRedirectUseCase) Execute(ctx context.Context, request *dto.Paym
 		return nil, fmt.Errorf("failed to create anonymous order: %w", err)
 	}
 
-	// 4. Create proxy order on OITAM
+	// 5. Convert into the PayPal settlement currency when it differs
+	conversion, err := uc.convertToSettlementCurrency(ctx, anonymousOrder)
+	if err != nil {
+		uc.logger.Error("Failed to convert order to settlement currency", err, map[string]interface{}{
+			"order_id": request.OrderID,
+			"currency": anonymousOrder.Currency,
+		})
+		return nil, fmt.Errorf("failed to convert order currency: %w", err)
+	}
+
+	// 6. Create proxy order on OITAM
 	oitamOrder, err := uc.wooCommerceRepo.CreateOITAMOrder(ctx, anonymousOrder)
 	if err != nil {
 		uc.logger.Error("Failed to create OITAM order", err, map[string]interface{}{
@@ -100,33 +163,245 @@ func (uc *PaymentRedirectUseCase) Execute(ctx context.Context, request *dto.Paym
 		return nil, fmt.Errorf("failed to create payment order: %w", err)
 	}
 
-	// 5. Build return and cancel URLs
+	// 7. Record the link between both orders so later flows resolve it server-side
+	mapping := entities.NewOrderMapping(request.OrderID, oitamOrder, uc.config.GetProxyOrderTTL())
+	if err := uc.mappingRepo.Create(ctx, mapping); err != nil {
+		uc.logger.Error("Failed to store order mapping", err, map[string]interface{}{
+			"order_id":       request.OrderID,
+			"oitam_order_id": oitamOrder.ID,
+		})
+		return nil, fmt.Errorf("failed to record proxy order: %w", err)
+	}
+
+	uc.timeline.Record(ctx, request.OrderID, entities.OrderEventProxyOrderCreated, "OITAM proxy order created", map[string]string{
+		"oitam_order_id": fmt.Sprintf("%d", oitamOrder.ID),
+		"total":          oitamOrder.Total.Decimal(),
+		"currency":       oitamOrder.Currency,
+	})
+
+	if conversion != nil {
+		uc.recordConversion(ctx, request.OrderID, conversion)
+	}
+
+	response := uc.buildRedirectResponse(request, magicOrder, oitamOrder)
+
+	uc.logger.Info("Payment redirect created successfully", map[string]interface{}{
+		"order_id":       request.OrderID,
+		"oitam_order_id": oitamOrder.ID,
+		"checkout_url":   response.RedirectURL,
+	})
+	uc.recordCheckoutURL(ctx, response, false)
+
+	return response, nil
+}
+
+// recordCheckoutURL adds the checkout URL handed to the customer to the order's timeline
+func (uc *PaymentRedirectUseCase) recordCheckoutURL(ctx context.Context, response *dto.PaymentRedirectResponse, reused bool) {
+	uc.timeline.Record(ctx, response.OrderID, entities.OrderEventCheckoutURLIssued, "PayPal checkout URL issued", map[string]string{
+		"checkout_url":   response.RedirectURL,
+		"proxy_order_id": response.ProxyOrderID,
+		"reused":         fmt.Sprintf("%t", reused),
+	})
+}
+
+// findReusableProxyOrder returns the open OITAM order from the latest mapping
+// when it is still payable for the current order total, or nil when a new
+// proxy order is needed.
+func (uc *PaymentRedirectUseCase) findReusableProxyOrder(ctx context.Context, orderID string, magicOrder *entities.Order) *entities.Order {
+	mapping, err := uc.mappingRepo.GetByMagicOrderID(ctx, orderID)
+	if err != nil || mapping.State != entities.MappingStateActive {
+		return nil
+	}
+
+	if mapping.IsExpired(time.Now()) {
+		uc.supersedeMapping(ctx, mapping, nil, entities.MappingStateExpired, "expired")
+		return nil
+	}
+
+	oitamOrder, err := uc.wooCommerceRepo.GetOITAMOrder(ctx, mapping.OITAMOrderID)
+	if err != nil {
+		uc.logger.Warn("Failed to fetch existing OITAM order, creating a new one", map[string]interface{}{
+			"order_id":       orderID,
+			"oitam_order_id": mapping.OITAMOrderID,
+			"error":          err.Error(),
+		})
+		return nil
+	}
+
+	if oitamOrder.Status != entities.StatusPending {
+		uc.supersedeMapping(ctx, mapping, oitamOrder, entities.MappingStateCancelled, "proxy order no longer pending")
+		return nil
+	}
+
+	if !strings.EqualFold(oitamOrder.Currency, uc.settlementCurrency(magicOrder)) {
+		uc.supersedeMapping(ctx, mapping, oitamOrder, entities.MappingStateCancelled, "settlement currency changed")
+		return nil
+	}
+
+	if _, err := uc.paymentService.ExpectedCapture(ctx, oitamOrder, magicOrder); err != nil {
+		uc.supersedeMapping(ctx, mapping, oitamOrder, entities.MappingStateCancelled, "order total changed")
+		return nil
+	}
+
+	return oitamOrder
+}
+
+// settlementCurrency returns the currency the proxy order for an order is charged in
+func (uc *PaymentRedirectUseCase) settlementCurrency(order *entities.Order) string {
+	if currency := uc.config.GetSettlementCurrency(); currency != "" {
+		return currency
+	}
+	return order.Currency
+}
+
+// convertToSettlementCurrency converts the proxy order into the configured
+// settlement currency. It returns nil when no conversion is needed.
+func (uc *PaymentRedirectUseCase) convertToSettlementCurrency(ctx context.Context, order *entities.Order) (*entities.CurrencyConversion, error) {
+	currency := uc.settlementCurrency(order)
+	if strings.EqualFold(currency, order.Currency) {
+		return nil, nil
+	}
+
+	if uc.currencyService == nil {
+		return nil, fmt.Errorf("no exchange rate provider configured for %s/%s", order.Currency, currency)
+	}
+
+	return uc.currencyService.ConvertOrder(ctx, order, currency)
+}
+
+// recordConversion stores the rate and both amounts on the MagicSpore order;
+// the proxy order receives them when it is created
+func (uc *PaymentRedirectUseCase) recordConversion(ctx context.Context, orderID string, conversion *entities.CurrencyConversion) {
+	if err := uc.wooCommerceRepo.UpdateMagicOrderMetaData(ctx, orderID, conversion.MetaData()); err != nil {
+		uc.logger.Error("Failed to record currency conversion on order", err, map[string]interface{}{
+			"order_id":         orderID,
+			"original_amount":  conversion.Original.String(),
+			"converted_amount": conversion.Converted.String(),
+		})
+	}
+}
+
+// supersedeMapping closes a mapping that can no longer be reused and cancels
+// its proxy order, so the stale checkout cannot be paid. oitamOrder is the
+// proxy order when it was already fetched, or nil.
+func (uc *PaymentRedirectUseCase) supersedeMapping(ctx context.Context, mapping *entities.OrderMapping, oitamOrder *entities.Order, state entities.OrderMappingState, reason string) {
+	uc.logger.Info("Existing proxy order cannot be reused", map[string]interface{}{
+		"order_id":       mapping.MagicOrderID,
+		"oitam_order_id": mapping.OITAMOrderID,
+		"reason":         reason,
+	})
+
+	if err := uc.mappingRepo.UpdateState(ctx, mapping.OITAMOrderID, state); err != nil {
+		uc.logger.Error("Failed to update order mapping state", err, map[string]interface{}{
+			"order_id":       mapping.MagicOrderID,
+			"oitam_order_id": mapping.OITAMOrderID,
+			"state":          state,
+		})
+	}
+
+	uc.cancelProxyOrder(ctx, mapping, oitamOrder)
+}
+
+// cancelProxyOrder cancels a superseded proxy order while it is still unpaid.
+// Orders that were paid or closed in the meantime are left to reconciliation.
+func (uc *PaymentRedirectUseCase) cancelProxyOrder(ctx context.Context, mapping *entities.OrderMapping, oitamOrder *entities.Order) {
+	fields := map[string]interface{}{
+		"order_id":       mapping.MagicOrderID,
+		"oitam_order_id": mapping.OITAMOrderID,
+	}
+
+	if oitamOrder == nil {
+		var err error
+		oitamOrder, err = uc.wooCommerceRepo.GetOITAMOrder(ctx, mapping.OITAMOrderID)
+		if err != nil {
+			uc.logger.Error("Failed to fetch superseded proxy order", err, fields)
+			return
+		}
+	}
+
+	if oitamOrder.Status != entities.StatusPending || oitamOrder.TransactionID != "" {
+		return
+	}
+
+	if err := uc.wooCommerceRepo.UpdateOITAMOrderStatus(ctx, mapping.OITAMOrderID, entities.StatusCancelled); err != nil {
+		uc.logger.Error("Failed to cancel superseded proxy order", err, fields)
+	}
+}
+
+// buildRedirectResponse builds the checkout redirect for a proxy order, with a
+// status token for the MagicSpore order
+func (uc *PaymentRedirectUseCase) buildRedirectResponse(request *dto.PaymentRedirectRequest, magicOrder *entities.Order, oitamOrder *entities.Order) *dto.PaymentRedirectResponse {
+	// Build return and cancel URLs
 	returnURL := uc.urlBuilder.BuildReturnURL(
 		fmt.Sprintf("https://%s", request.Domain),
 		request.OrderID,
 		fmt.Sprintf("%d", oitamOrder.ID),
 		"success",
 	)
-	
+
 	cancelURL := uc.urlBuilder.BuildCancelURL(
 		fmt.Sprintf("https://%s", request.Domain),
 		request.OrderID,
 	)
 
-	// 6. Build checkout URL
+	// Build checkout URL
 	checkoutURL := uc.urlBuilder.BuildCheckoutURL(oitamOrder, returnURL, cancelURL)
 
-	uc.logger.Info("Payment redirect created successfully", map[string]interface{}{
-		"order_id":       request.OrderID,
-		"oitam_order_id": oitamOrder.ID,
-		"checkout_url":   checkoutURL,
-	})
-
 	return &dto.PaymentRedirectResponse{
 		RedirectURL:  checkoutURL,
 		OrderID:      request.OrderID,
 		ProxyOrderID: fmt.Sprintf("%d", oitamOrder.ID),
 		Status:       "redirect_created",
 		Message:      "Redirect to PayPal checkout created",
-	}, nil
-}
\ No newline at end of file
+		StatusToken:  uc.statusTokens.Issue(request.OrderID, magicOrder.OrderKey),
+	}
+}
+
+// withStatusToken adds a status token to a storefront URL; an empty token
+// leaves the URL unchanged
+func withStatusToken(redirectURL, statusToken string) string {
+	if statusToken == "" {
+		return redirectURL
+	}
+	return redirectURL + "&status_token=" + url.QueryEscape(statusToken)
+}
+
+// keyedMutex provides one mutex per key, released once no caller holds it
+type keyedMutex struct {
+	mutex sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	waiters int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// Lock acquires the mutex for key and returns its unlock function
+func (k *keyedMutex) Lock(key string) func() {
+	k.mutex.Lock()
+	lock, exists := k.locks[key]
+	if !exists {
+		lock = &keyedLock{}
+		k.locks[key] = lock
+	}
+	lock.waiters++
+	k.mutex.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		k.mutex.Lock()
+		lock.waiters--
+		if lock.waiters == 0 {
+			delete(k.locks, key)
+		}
+		k.mutex.Unlock()
+	}
+}
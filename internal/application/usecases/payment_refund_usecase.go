@@ -0,0 +1,298 @@
+package usecases
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+)
+
+// ErrRefundsUnavailable is returned when no payment gateway is configured to refund through
+var ErrRefundsUnavailable = errors.New("refunds need PayPal credentials")
+
+// ErrNoRefundablePayment is returned when an order has no completed payment to refund
+var ErrNoRefundablePayment = errors.New("no completed payment to refund")
+
+// proxyRefundReason is the only reason recorded on OITAM refunds, so the
+// proxy store never sees the reason given for the original order
+const proxyRefundReason = "Refund"
+
+// PaymentRefundUseCase refunds a captured payment through PayPal and records
+// the refund on both the MagicSpore order and its OITAM proxy order
+type PaymentRefundUseCase struct {
+	wooCommerceRepo interfaces.WooCommerceRepository
+	paymentRepo     interfaces.PaymentRepository
+	mappingRepo     interfaces.OrderMappingRepository
+	paymentGateway  interfaces.PaymentGateway
+	paymentService  *services.PaymentDomainService
+	orderService    *services.OrderDomainService
+	logger          interfaces.Logger
+	orderLocks      *keyedMutex // serializes refunds of the same order
+}
+
+// NewPaymentRefundUseCase creates a new payment refund use case
+func NewPaymentRefundUseCase(
+	wooCommerceRepo interfaces.WooCommerceRepository,
+	paymentRepo interfaces.PaymentRepository,
+	mappingRepo interfaces.OrderMappingRepository,
+	paymentGateway interfaces.PaymentGateway,
+	paymentService *services.PaymentDomainService,
+	orderService *services.OrderDomainService,
+	logger interfaces.Logger,
+) *PaymentRefundUseCase {
+	return &PaymentRefundUseCase{
+		wooCommerceRepo: wooCommerceRepo,
+		paymentRepo:     paymentRepo,
+		mappingRepo:     mappingRepo,
+		paymentGateway:  paymentGateway,
+		paymentService:  paymentService,
+		orderService:    orderService,
+		logger:          logger,
+		orderLocks:      newKeyedMutex(),
+	}
+}
+
+// Execute refunds all or part of an order's completed payment. Once PayPal has
+// returned the money the refund is not undone; failures to record it on the
+// stores are logged and reported as warnings instead. Refunds of the same
+// order never run concurrently.
+func (uc *PaymentRefundUseCase) Execute(ctx context.Context, request *dto.PaymentRefundRequest) (*dto.PaymentRefundResponse, error) {
+	if request.OrderID == "" {
+		return nil, entities.NewValidationError("order_id", "Order ID is required")
+	}
+	if uc.paymentGateway == nil {
+		return nil, ErrRefundsUnavailable
+	}
+
+	unlock := uc.orderLocks.Lock(request.OrderID)
+	defer unlock()
+
+	uc.logger.Info("Processing payment refund", map[string]interface{}{
+		"order_id": request.OrderID,
+		"amount":   request.Amount,
+	})
+
+	order, err := uc.wooCommerceRepo.GetMagicOrder(ctx, request.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	payment, err := uc.findRefundablePayment(ctx, request.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var requested *entities.Money
+	if request.Amount != "" {
+		amount, err := entities.ParseMoney(request.Amount, order.Total.Currency)
+		if err != nil {
+			return nil, entities.NewValidationError("amount", fmt.Sprintf("Invalid refund amount: %v", err))
+		}
+		requested = &amount
+	}
+
+	orderAmount, captureAmount, err := uc.paymentService.RefundAmounts(ctx, payment, order, requested)
+	if err != nil {
+		return nil, err
+	}
+
+	refundID, err := uc.paymentGateway.RefundPayment(ctx, payment.PaymentID, captureAmount, refundRequestID(request, payment, captureAmount))
+	if err != nil {
+		return nil, fmt.Errorf("failed to refund payment: %w", err)
+	}
+
+	// PayPal answered a retried request with the refund it already made,
+	// which the original request recorded on the stores
+	if recordedByAPI(payment, refundID) {
+		uc.logger.Info("Refund request already processed", map[string]interface{}{
+			"order_id":   request.OrderID,
+			"payment_id": payment.PaymentID,
+			"refund_id":  refundID,
+		})
+		response := refundResponse(request.OrderID, payment, refundID, orderAmount, captureAmount, nil)
+		if mapping, err := uc.mappingRepo.GetByMagicOrderID(ctx, request.OrderID); err == nil {
+			response.OITAMOrderID = mapping.OITAMOrderID
+		}
+		return response, nil
+	}
+
+	var warnings []string
+	warn := func(message string, err error, fields map[string]interface{}) {
+		fields["order_id"] = request.OrderID
+		uc.logger.Error(message, err, fields)
+		warnings = append(warnings, fmt.Sprintf("%s: %v", message, err))
+	}
+
+	entry := entities.PaymentRefund{ID: refundID, Amount: captureAmount, Source: entities.RefundSourceAPI}
+	refunded, err := uc.paymentRepo.RecordRefund(ctx, payment.PaymentID, entry)
+	// The refund webhook can beat the PayPal response, in which case it has
+	// already recorded the refund on the MagicSpore order
+	recordedByWebhook := errors.Is(err, entities.ErrDuplicateRefund)
+	if recordedByWebhook {
+		refunded, err = uc.paymentRepo.GetByPaymentID(ctx, payment.PaymentID)
+	}
+	if err != nil {
+		warn("Failed to record payment refund", err, map[string]interface{}{
+			"payment_id": payment.PaymentID,
+			"refund_id":  refundID,
+		})
+		// Report the refund PayPal has made even though it was not stored
+		refunded = payment
+		refunded.ApplyRefund(entry, "refunded")
+	}
+	fullyRefunded := refunded.IsFullyRefunded()
+
+	if !recordedByWebhook {
+		if _, err := uc.wooCommerceRepo.CreateMagicOrderRefund(ctx, request.OrderID, &entities.OrderRefund{
+			Amount: orderAmount,
+			Reason: request.Reason,
+		}); err != nil {
+			warn("Failed to create MagicSpore order refund", err, map[string]interface{}{
+				"amount": orderAmount.Decimal(),
+			})
+		}
+	}
+
+	mapping, err := uc.mappingRepo.GetByMagicOrderID(ctx, request.OrderID)
+	if err != nil {
+		warn("Failed to find OITAM proxy order", err, map[string]interface{}{})
+		mapping = nil
+	}
+	if mapping != nil {
+		if _, err := uc.wooCommerceRepo.CreateOITAMOrderRefund(ctx, mapping.OITAMOrderID, &entities.OrderRefund{
+			Amount: captureAmount,
+			Reason: proxyRefundReason,
+		}); err != nil {
+			warn("Failed to create OITAM order refund", err, map[string]interface{}{
+				"oitam_order_id": mapping.OITAMOrderID,
+				"amount":         captureAmount.Decimal(),
+			})
+		}
+	}
+
+	if fullyRefunded && !recordedByWebhook {
+		uc.markRefunded(ctx, request.OrderID, order, mapping, warn)
+	}
+
+	if err := uc.wooCommerceRepo.AddMagicOrderNote(ctx, request.OrderID, refundNote(orderAmount, captureAmount, refunded, request.Reason)); err != nil {
+		warn("Failed to add refund order note", err, map[string]interface{}{})
+	}
+
+	response := refundResponse(request.OrderID, refunded, refundID, orderAmount, captureAmount, warnings)
+	if mapping != nil {
+		response.OITAMOrderID = mapping.OITAMOrderID
+	}
+
+	uc.logger.Info("Payment refund processed", map[string]interface{}{
+		"order_id":   request.OrderID,
+		"payment_id": payment.PaymentID,
+		"refund_id":  refundID,
+		"amount":     orderAmount.Decimal(),
+		"status":     response.Status,
+		"warnings":   len(warnings),
+	})
+
+	return response, nil
+}
+
+// recordedByAPI checks if a refund API request already recorded refundID on payment
+func recordedByAPI(payment *entities.Payment, refundID string) bool {
+	for _, refund := range payment.Refunds {
+		if refund.ID == refundID && refund.Source == entities.RefundSourceAPI {
+			return true
+		}
+	}
+	return false
+}
+
+// refundResponse describes a refund of payment, which already includes it
+func refundResponse(orderID string, payment *entities.Payment, refundID string, orderAmount, captureAmount entities.Money, warnings []string) *dto.PaymentRefundResponse {
+	status := "partially_refunded"
+	if payment.IsFullyRefunded() {
+		status = "refunded"
+	}
+
+	return &dto.PaymentRefundResponse{
+		OrderID:            orderID,
+		PaymentID:          payment.PaymentID,
+		RefundID:           refundID,
+		Status:             status,
+		Amount:             orderAmount.Decimal(),
+		Currency:           orderAmount.Currency,
+		SettlementAmount:   captureAmount.Decimal(),
+		SettlementCurrency: captureAmount.Currency,
+		RefundedTotal:      payment.RefundedAmount().Decimal(),
+		RefundableAmount:   payment.RefundableAmount().Decimal(),
+		Warnings:           warnings,
+	}
+}
+
+// findRefundablePayment returns the order's most recent completed payment
+func (uc *PaymentRefundUseCase) findRefundablePayment(ctx context.Context, orderID string) (*entities.Payment, error) {
+	payments, err := uc.paymentRepo.GetByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+
+	for i := len(payments) - 1; i >= 0; i-- {
+		if payments[i].IsCompleted() && payments[i].PaymentID != "" {
+			return payments[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("order %s: %w", orderID, ErrNoRefundablePayment)
+}
+
+// refundRequestID derives the PayPal-Request-Id of a refund. With an
+// idempotency key it identifies the client's request; without one it
+// identifies the refund of this amount on top of what is already refunded, so
+// a retry after a lost response is not refunded twice.
+func refundRequestID(request *dto.PaymentRefundRequest, payment *entities.Payment, amount entities.Money) string {
+	source := fmt.Sprintf("%s|%s|%s", payment.PaymentID, amount.String(), payment.RefundedAmount().String())
+	if request.IdempotencyKey != "" {
+		source = fmt.Sprintf("%s|key|%s", request.OrderID, request.IdempotencyKey)
+	}
+	sum := sha256.Sum256([]byte(source))
+	return "refund-" + hex.EncodeToString(sum[:16])
+}
+
+// markRefunded moves a fully refunded order and its proxy mapping to refunded
+func (uc *PaymentRefundUseCase) markRefunded(ctx context.Context, orderID string, order *entities.Order, mapping *entities.OrderMapping, warn func(string, error, map[string]interface{})) {
+	if err := uc.orderService.TransitionOrder(ctx, order, entities.StatusRefunded, "payment refunded"); err != nil {
+		warn("Order cannot move to refunded", err, map[string]interface{}{
+			"status": order.Status,
+		})
+	} else if err := uc.wooCommerceRepo.UpdateMagicOrderStatus(ctx, orderID, entities.StatusRefunded); err != nil {
+		warn("Failed to update order status to refunded", err, map[string]interface{}{})
+	} else {
+		recordStatusHistory(ctx, uc.wooCommerceRepo, uc.logger, orderID, order)
+	}
+
+	if mapping != nil {
+		if err := uc.mappingRepo.UpdateState(ctx, mapping.OITAMOrderID, entities.MappingStateRefunded); err != nil {
+			warn("Failed to mark order mapping as refunded", err, map[string]interface{}{
+				"oitam_order_id": mapping.OITAMOrderID,
+			})
+		}
+	}
+}
+
+// refundNote describes a refund for the MagicSpore order notes
+func refundNote(orderAmount, captureAmount entities.Money, payment *entities.Payment, reason string) string {
+	note := fmt.Sprintf("Refunded %s through PayPal (capture %s).", orderAmount.String(), payment.PaymentID)
+	if !captureAmount.SameCurrency(orderAmount) {
+		note = fmt.Sprintf("Refunded %s (%s) through PayPal (capture %s).", orderAmount.String(), captureAmount.String(), payment.PaymentID)
+	}
+	if remaining := payment.RefundableAmount(); remaining.IsPositive() {
+		note += fmt.Sprintf(" %s of the capture remains refundable.", remaining.String())
+	}
+	if reason != "" {
+		note += " Reason: " + reason
+	}
+	return note
+}
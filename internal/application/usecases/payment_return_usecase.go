@@ -2,39 +2,80 @@ package usecases
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"paypal-proxy/internal/application/dto"
 	"paypal-proxy/internal/domain/entities"
 	"paypal-proxy/internal/domain/interfaces"
 	"paypal-proxy/internal/domain/services"
+	"sync"
+	"time"
 )
 
 // PaymentReturnUseCase handles the payment return use case
 type PaymentReturnUseCase struct {
 	wooCommerceRepo interfaces.WooCommerceRepository
+	paymentRepo     interfaces.PaymentRepository
+	mappingRepo     interfaces.OrderMappingRepository
+	paymentGateway  interfaces.PaymentGateway
 	paymentService  *services.PaymentDomainService
 	orderService    *services.OrderDomainService
+	statusTokens    *services.StatusTokenService
+	timeline        *OrderTimelineUseCase
 	logger          interfaces.Logger
 	config          interfaces.ConfigService
+	polling         sync.Map // order IDs with a re-verification in progress
 }
 
-// NewPaymentReturnUseCase creates a new payment return use case
+// NewPaymentReturnUseCase creates a new payment return use case.
+// Without a payment gateway, captures are verified from the OITAM order alone.
 func NewPaymentReturnUseCase(
 	wooCommerceRepo interfaces.WooCommerceRepository,
+	paymentRepo interfaces.PaymentRepository,
+	mappingRepo interfaces.OrderMappingRepository,
+	paymentGateway interfaces.PaymentGateway,
 	paymentService *services.PaymentDomainService,
 	orderService *services.OrderDomainService,
+	timeline *OrderTimelineUseCase,
 	logger interfaces.Logger,
 	config interfaces.ConfigService,
 ) *PaymentReturnUseCase {
 	return &PaymentReturnUseCase{
 		wooCommerceRepo: wooCommerceRepo,
+		paymentRepo:     paymentRepo,
+		mappingRepo:     mappingRepo,
+		paymentGateway:  paymentGateway,
 		paymentService:  paymentService,
 		orderService:    orderService,
+		statusTokens:    services.NewStatusTokenService(config.GetStatusTokenConfig()),
+		timeline:        timeline,
 		logger:          logger,
 		config:          config,
 	}
 }
 
+// verificationOutcome classifies the result of a server-side payment check
+type verificationOutcome string
+
+const (
+	verificationAlreadyPaid verificationOutcome = "already_paid"
+	verificationConfirmed   verificationOutcome = "confirmed"
+	verificationPending     verificationOutcome = "pending"
+	verificationRejected    verificationOutcome = "rejected"
+)
+
+// paymentVerification is the outcome of checking a return against OITAM and PayPal
+type paymentVerification struct {
+	outcome      verificationOutcome
+	reason       string
+	order        *entities.Order
+	mapping      *entities.OrderMapping
+	oitamOrderID string
+	captureID    string
+	captureState entities.PaymentStatus // PayPal capture status, when looked up
+	captured     entities.Money
+}
+
 // Execute executes the payment return use case
 func (uc *PaymentReturnUseCase) Execute(ctx context.Context, request *dto.PaymentReturnRequest) (*dto.PaymentReturnResponse, error) {
 	uc.logger.Info("Processing payment return", map[string]interface{}{
@@ -59,96 +100,385 @@ func (uc *PaymentReturnUseCase) Execute(ctx context.Context, request *dto.Paymen
 		}, nil
 	}
 
-	// 1. Verify payment status from OITAM order if available
-	if request.OITAMOrderID != "" {
-		oitamOrder, err := uc.wooCommerceRepo.GetOITAMOrder(ctx, request.OITAMOrderID)
-		if err == nil && oitamOrder.IsPaymentCompleted() {
-			// Payment confirmed, update original order
-			err = uc.updateOriginalOrderWithPayment(ctx, request, oitamOrder.TransactionID)
-			if err != nil {
-				uc.logger.Error("Failed to update original order", err, map[string]interface{}{
-					"order_id": request.OrderID,
-				})
-			}
-
-			uc.logger.Info("Payment confirmed via OITAM order", map[string]interface{}{
-				"order_id":       request.OrderID,
-				"oitam_order_id": request.OITAMOrderID,
-				"transaction_id": oitamOrder.TransactionID,
-			})
+	uc.timeline.Record(ctx, request.OrderID, entities.OrderEventReturnReceived, "Customer returned from PayPal", returnParams(request))
 
-			return &dto.PaymentReturnResponse{
-				RedirectURL: fmt.Sprintf("%s?order=%s&payment=confirmed", returnURLs.Success, request.OrderID),
-				Status:      "success",
-				Message:     "Payment confirmed",
-			}, nil
-		}
+	// 1. Verify the payment server-side; query parameters only identify the order
+	verification, err := uc.verifyPayment(ctx, request.OrderID, request.OITAMOrderID)
+	if err != nil {
+		uc.logger.Error("Payment verification failed", err, map[string]interface{}{
+			"order_id":       request.OrderID,
+			"oitam_order_id": request.OITAMOrderID,
+		})
+		uc.timeline.RecordError(ctx, request.OrderID, "Payment verification failed on return", err)
+		return &dto.PaymentReturnResponse{
+			RedirectURL: fmt.Sprintf("%s?order=%s&error=payment_verification_failed", returnURLs.Error, request.OrderID),
+			Status:      "error",
+			Message:     "Payment verification failed",
+		}, nil
 	}
 
-	// 2. Fallback: Update order status based on URL parameters
-	if request.PaymentID != "" || request.PayerID != "" {
-		err := uc.updateOriginalOrderWithPayment(ctx, request, request.TransactionID)
-		if err != nil {
-			uc.logger.Error("Failed to update original order (fallback)", err, map[string]interface{}{
-				"order_id":   request.OrderID,
-				"payment_id": request.PaymentID,
-			})
-		} else {
-			uc.logger.Info("Payment processed via fallback method", map[string]interface{}{
-				"order_id":   request.OrderID,
-				"payment_id": request.PaymentID,
-			})
+	switch verification.outcome {
+	case verificationAlreadyPaid:
+		return &dto.PaymentReturnResponse{
+			RedirectURL: uc.successURL(request.OrderID, "confirmed", verification),
+			Status:      "success",
+			Message:     "Payment already confirmed",
+		}, nil
 
-			return &dto.PaymentReturnResponse{
-				RedirectURL: fmt.Sprintf("%s?order=%s&payment=success", returnURLs.Success, request.OrderID),
-				Status:      "success",
-				Message:     "Payment processed",
-			}, nil
+	case verificationConfirmed:
+		// 2. Payment confirmed, update original order
+		if err := uc.recordVerifiedPayment(ctx, request.OrderID, request.PayerID, verification, "payment verified on return"); err != nil {
+			uc.logger.Error("Failed to update original order", err, map[string]interface{}{
+				"order_id": request.OrderID,
+			})
+			uc.timeline.RecordError(ctx, request.OrderID, "Failed to record verified payment on return", err)
+			uc.schedulePoll(request.OrderID, request.OITAMOrderID, request.PayerID)
 		}
+
+		uc.logger.Info("Payment confirmed via OITAM order", map[string]interface{}{
+			"order_id":       request.OrderID,
+			"oitam_order_id": verification.oitamOrderID,
+			"transaction_id": verification.captureID,
+			"amount":         verification.captured.Decimal(),
+		})
+
+		return &dto.PaymentReturnResponse{
+			RedirectURL: uc.successURL(request.OrderID, "confirmed", verification),
+			Status:      "success",
+			Message:     "Payment confirmed",
+		}, nil
+
+	case verificationRejected:
+		uc.logger.Error("Payment rejected during verification", nil, map[string]interface{}{
+			"order_id":       request.OrderID,
+			"oitam_order_id": verification.oitamOrderID,
+			"reason":         verification.reason,
+		})
+		uc.timeline.RecordError(ctx, request.OrderID, "Payment rejected on return", errors.New(verification.reason))
+
+		return &dto.PaymentReturnResponse{
+			RedirectURL: fmt.Sprintf("%s?order=%s&error=payment_verification_failed", returnURLs.Error, request.OrderID),
+			Status:      "error",
+			Message:     "Payment verification failed",
+		}, nil
 	}
 
-	// 3. If we can't confirm payment, redirect to error page
-	uc.logger.Warn("Could not verify payment", map[string]interface{}{
+	// 3. Payment not confirmed yet: leave the order pending and re-check later
+	uc.logger.Warn("Could not verify payment yet", map[string]interface{}{
 		"order_id":       request.OrderID,
-		"oitam_order_id": request.OITAMOrderID,
-		"payment_id":     request.PaymentID,
+		"oitam_order_id": verification.oitamOrderID,
+		"reason":         verification.reason,
 	})
+	uc.schedulePoll(request.OrderID, request.OITAMOrderID, request.PayerID)
 
 	return &dto.PaymentReturnResponse{
-		RedirectURL: fmt.Sprintf("%s?order=%s&error=payment_verification_failed", returnURLs.Error, request.OrderID),
-		Status:      "error",
-		Message:     "Payment verification failed",
+		RedirectURL: uc.successURL(request.OrderID, "pending", verification),
+		Status:      "pending",
+		Message:     "Payment is being confirmed",
 	}, nil
 }
 
-// updateOriginalOrderWithPayment updates the original order with payment information
-func (uc *PaymentReturnUseCase) updateOriginalOrderWithPayment(ctx context.Context, request *dto.PaymentReturnRequest, transactionID string) error {
+// successURL builds the storefront URL for a confirmed or pending payment,
+// with a status token so the page can follow the payment's progress
+func (uc *PaymentReturnUseCase) successURL(orderID string, payment string, verification *paymentVerification) string {
+	successURL := fmt.Sprintf("%s?order=%s&payment=%s", uc.config.GetReturnURLs().Success, orderID, payment)
+	if verification.order == nil {
+		return successURL
+	}
+	return withStatusToken(successURL, uc.statusTokens.Issue(orderID, verification.order.OrderKey))
+}
+
+// returnParams lists the query parameters PayPal sent the customer back with
+func returnParams(request *dto.PaymentReturnRequest) map[string]string {
+	params := make(map[string]string)
+	for name, value := range map[string]string{
+		"oitam_order_id": request.OITAMOrderID,
+		"status":         request.Status,
+		"payment_id":     request.PaymentID,
+		"payer_id":       request.PayerID,
+		"transaction_id": request.TransactionID,
+	} {
+		if value != "" {
+			params[name] = value
+		}
+	}
+	return params
+}
+
+// verifyPayment checks the payment for an order against its OITAM proxy order
+func (uc *PaymentReturnUseCase) verifyPayment(ctx context.Context, orderID, requestedOITAMOrderID string) (*paymentVerification, error) {
+	magicOrder, err := uc.wooCommerceRepo.GetMagicOrder(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch original order: %w", err)
+	}
+
+	if magicOrder.IsPaymentCompleted() {
+		return &paymentVerification{outcome: verificationAlreadyPaid, order: magicOrder}, nil
+	}
+
+	// Refunded orders are final and cannot be paid again
+	if !magicOrder.Status.CanTransitionTo(entities.StatusProcessing) {
+		return &paymentVerification{
+			outcome: verificationRejected,
+			reason:  fmt.Sprintf("order is %s", magicOrder.Status),
+		}, nil
+	}
+
+	mapping, err := uc.resolveOrderMapping(ctx, orderID, requestedOITAMOrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a mapping the proxy order named in the return URL cannot be trusted
+	if mapping == nil {
+		return &paymentVerification{
+			outcome:      verificationRejected,
+			order:        magicOrder,
+			oitamOrderID: requestedOITAMOrderID,
+			reason:       "no proxy order mapping for this order",
+		}, nil
+	}
+
+	verification := &paymentVerification{
+		outcome:      verificationPending,
+		order:        magicOrder,
+		mapping:      mapping,
+		oitamOrderID: mapping.OITAMOrderID,
+	}
+
+	oitamOrder, err := uc.wooCommerceRepo.GetOITAMOrder(ctx, verification.oitamOrderID)
+	if err != nil {
+		verification.reason = fmt.Sprintf("failed to fetch proxy order: %v", err)
+		return verification, nil
+	}
+
+	uc.verifyProxyOrder(ctx, orderID, verification, oitamOrder)
+	return verification, nil
+}
+
+// verifyProxyOrder completes a verification from the fetched OITAM proxy order
+// and, when a gateway is configured, the PayPal capture itself
+func (uc *PaymentReturnUseCase) verifyProxyOrder(ctx context.Context, orderID string, verification *paymentVerification, oitamOrder *entities.Order) {
+	magicOrder := verification.order
+
+	// The proxy order must have been created for this order
+	if number, ok := oitamOrder.MetaValue(entities.MetaOriginalOrderNumber); !ok || number != magicOrder.Number {
+		verification.outcome = verificationRejected
+		verification.reason = fmt.Sprintf("proxy order was not created for order %s", magicOrder.Number)
+		return
+	}
+
+	// On-hold means PayPal has not released the funds yet
+	if oitamOrder.Status != entities.StatusProcessing && oitamOrder.Status != entities.StatusCompleted {
+		verification.reason = fmt.Sprintf("proxy order is %s", oitamOrder.Status)
+		return
+	}
+
+	expected, err := uc.paymentService.ExpectedCapture(ctx, oitamOrder, magicOrder)
+	if err != nil {
+		verification.outcome = verificationRejected
+		verification.reason = fmt.Sprintf("proxy order total mismatch: %v", err)
+		return
+	}
+
+	verification.captureID = oitamOrder.TransactionID
+	verification.captured = oitamOrder.Total
+
+	if uc.paymentGateway != nil {
+		if verification.captureID == "" {
+			verification.reason = "proxy order has no capture ID yet"
+			return
+		}
+
+		capture, err := uc.paymentGateway.GetPaymentStatus(ctx, verification.captureID)
+		if err != nil {
+			verification.reason = fmt.Sprintf("failed to look up capture: %v", err)
+			return
+		}
+		verification.captureState = capture.Status
+
+		switch {
+		case capture.IsCompleted():
+		case capture.IsFinal():
+			verification.outcome = verificationRejected
+			verification.reason = fmt.Sprintf("capture is %s", capture.Status)
+			return
+		default:
+			verification.reason = fmt.Sprintf("capture is %s", capture.Status)
+			return
+		}
+
+		if err := uc.paymentService.VerifyCapturedAmount(ctx, capture.Amount, expected); err != nil {
+			verification.outcome = verificationRejected
+			verification.reason = fmt.Sprintf("capture amount mismatch: %v", err)
+			return
+		}
+		verification.captured = capture.Amount
+	}
+
+	// A capture can only ever pay for one order
+	if verification.captureID != "" {
+		existing, err := uc.paymentRepo.GetByPaymentID(ctx, verification.captureID)
+		switch {
+		case err == nil && existing.OrderID != orderID:
+			verification.outcome = verificationRejected
+			verification.reason = fmt.Sprintf("capture already recorded for order %s", existing.OrderID)
+			return
+		case err != nil && !errors.Is(err, interfaces.ErrNotFound):
+			verification.reason = fmt.Sprintf("failed to look up recorded captures: %v", err)
+			return
+		}
+	}
+
+	verification.outcome = verificationConfirmed
+}
+
+// recordVerifiedPayment stores the verified payment and updates the original order
+func (uc *PaymentReturnUseCase) recordVerifiedPayment(ctx context.Context, orderID, payerID string, verification *paymentVerification, reason string) error {
+	if verification.order != nil {
+		if err := uc.orderService.TransitionOrder(ctx, verification.order, entities.StatusProcessing, reason); err != nil {
+			return err
+		}
+	}
+
 	// Create payment record
 	payment := uc.paymentService.CreatePaymentRecord(
 		ctx,
-		request.OrderID,
-		request.PaymentID,
-		request.PayerID,
-		entities.Money{}, // Amount will be filled from order
+		orderID,
+		verification.captureID,
+		payerID,
+		verification.captured,
 		entities.PaymentStatusCompleted,
 	)
-
-	// Set transaction ID
-	if transactionID != "" {
-		payment.TransactionID = transactionID
-	} else if request.PaymentID != "" {
-		payment.TransactionID = request.PaymentID
-	}
+	payment.TransactionID = verification.captureID
 
 	// Store payment record
 	if err := uc.paymentRepo.Create(ctx, payment); err != nil {
 		uc.logger.Error("Failed to store payment record", err, map[string]interface{}{
 			"payment_id": payment.ID,
-			"order_id":   request.OrderID,
+			"order_id":   orderID,
 		})
 		// Continue even if payment record storage fails
 	}
 
 	// Update original order
-	return uc.wooCommerceRepo.UpdateMagicOrderPayment(ctx, request.OrderID, payment)
-}
\ No newline at end of file
+	if err := uc.wooCommerceRepo.UpdateMagicOrderPayment(ctx, orderID, payment); err != nil {
+		return err
+	}
+	recordStatusHistory(ctx, uc.wooCommerceRepo, uc.logger, orderID, verification.order)
+
+	if verification.mapping != nil {
+		if err := uc.mappingRepo.UpdateState(ctx, verification.mapping.OITAMOrderID, entities.MappingStatePaid); err != nil {
+			uc.logger.Error("Failed to mark order mapping as paid", err, map[string]interface{}{
+				"order_id":       orderID,
+				"oitam_order_id": verification.mapping.OITAMOrderID,
+			})
+		}
+	}
+
+	return nil
+}
+
+// schedulePoll re-verifies an unconfirmed return in the background until the
+// payment is settled or the configured attempts are used up
+func (uc *PaymentReturnUseCase) schedulePoll(orderID, oitamOrderID, payerID string) {
+	settings := uc.config.GetPaymentVerificationConfig()
+	if settings.PollAttempts <= 0 {
+		return
+	}
+
+	if _, running := uc.polling.LoadOrStore(orderID, struct{}{}); running {
+		return
+	}
+
+	go func() {
+		defer uc.polling.Delete(orderID)
+
+		for attempt := 1; attempt <= settings.PollAttempts; attempt++ {
+			time.Sleep(settings.PollInterval)
+
+			ctx, cancel := context.WithTimeout(context.Background(), uc.config.GetServerConfig().GetTimeout())
+			settled := uc.pollOnce(ctx, orderID, oitamOrderID, payerID, attempt)
+			cancel()
+
+			if settled {
+				return
+			}
+		}
+
+		uc.logger.Warn("Payment still unconfirmed after polling", map[string]interface{}{
+			"order_id": orderID,
+			"attempts": settings.PollAttempts,
+		})
+	}()
+}
+
+// pollOnce runs a single re-verification and reports whether polling can stop
+func (uc *PaymentReturnUseCase) pollOnce(ctx context.Context, orderID, oitamOrderID, payerID string, attempt int) bool {
+	verification, err := uc.verifyPayment(ctx, orderID, oitamOrderID)
+	if err != nil {
+		uc.logger.Warn("Payment re-verification failed", map[string]interface{}{
+			"order_id": orderID,
+			"attempt":  attempt,
+			"error":    err.Error(),
+		})
+		return false
+	}
+
+	switch verification.outcome {
+	case verificationConfirmed:
+		if err := uc.recordVerifiedPayment(ctx, orderID, payerID, verification, "payment verified on return"); err != nil {
+			uc.logger.Error("Failed to update original order after re-verification", err, map[string]interface{}{
+				"order_id": orderID,
+				"attempt":  attempt,
+			})
+			return false
+		}
+		uc.logger.Info("Payment confirmed by re-verification", map[string]interface{}{
+			"order_id":       orderID,
+			"transaction_id": verification.captureID,
+			"attempt":        attempt,
+		})
+		return true
+
+	case verificationAlreadyPaid:
+		return true
+
+	case verificationRejected:
+		uc.logger.Error("Payment rejected during re-verification", nil, map[string]interface{}{
+			"order_id": orderID,
+			"reason":   verification.reason,
+		})
+		return true
+
+	default:
+		return false
+	}
+}
+
+// resolveOrderMapping looks up the stored proxy order for the returned order.
+// A nil mapping means the order has no proxy order; the OITAM order ID in the
+// return URL is only compared against the mapping, never used in its place.
+func (uc *PaymentReturnUseCase) resolveOrderMapping(ctx context.Context, orderID, requestedOITAMOrderID string) (*entities.OrderMapping, error) {
+	mapping, err := uc.mappingRepo.GetByMagicOrderID(ctx, orderID)
+	if errors.Is(err, interfaces.ErrNotFound) {
+		uc.logger.Warn("No order mapping found for payment return", map[string]interface{}{
+			"order_id":       orderID,
+			"oitam_order_id": requestedOITAMOrderID,
+		})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order mapping: %w", err)
+	}
+
+	if requestedOITAMOrderID != "" && requestedOITAMOrderID != mapping.OITAMOrderID {
+		uc.logger.Warn("OITAM order ID in return URL does not match stored mapping", map[string]interface{}{
+			"order_id":              orderID,
+			"oitam_order_id":        mapping.OITAMOrderID,
+			"requested_oitam_order": requestedOITAMOrderID,
+		})
+	}
+
+	return mapping, nil
+}
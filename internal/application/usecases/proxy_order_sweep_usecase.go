@@ -0,0 +1,342 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// proxyCreatedAtMetaKey holds the Unix time CreateOITAMOrder created a proxy order at
+const proxyCreatedAtMetaKey = "_proxy_created_at"
+
+// Outcomes of sweeping one abandoned proxy order
+const (
+	SweepOutcomeCancelled     = "cancelled"
+	SweepOutcomeTrashed       = "trashed"
+	SweepOutcomeAlreadyClosed = "already_closed"
+	SweepOutcomeSkipped       = "skipped"
+	SweepOutcomeFailed        = "failed"
+)
+
+// sweptMappingStates are the mapping states whose proxy orders are swept.
+// A checkout the customer cancelled, or one superseded by a newer checkout,
+// closes its mapping but can leave a pending proxy order that is still payable.
+var sweptMappingStates = []entities.OrderMappingState{
+	entities.MappingStateActive,
+	entities.MappingStateExpired,
+	entities.MappingStateCancelled,
+}
+
+// ProxyOrderSweepUseCase closes OITAM proxy orders left behind by abandoned
+// checkouts once they are older than the sweep TTL and were never paid
+type ProxyOrderSweepUseCase struct {
+	wooCommerceRepo interfaces.WooCommerceRepository
+	mappingRepo     interfaces.OrderMappingRepository
+	orderService    *services.OrderDomainService
+	logger          interfaces.Logger
+	config          interfaces.ConfigService
+
+	running sync.Mutex // held for the length of a sweep
+	// cursors holds the last mapping checked per state; guarded by running
+	cursors map[entities.OrderMappingState]*entities.OrderMapping
+
+	mutex      sync.RWMutex
+	lastReport *dto.SweepReport
+}
+
+// NewProxyOrderSweepUseCase creates a new abandoned proxy order sweep use case
+func NewProxyOrderSweepUseCase(
+	wooCommerceRepo interfaces.WooCommerceRepository,
+	mappingRepo interfaces.OrderMappingRepository,
+	orderService *services.OrderDomainService,
+	logger interfaces.Logger,
+	config interfaces.ConfigService,
+) *ProxyOrderSweepUseCase {
+	return &ProxyOrderSweepUseCase{
+		wooCommerceRepo: wooCommerceRepo,
+		mappingRepo:     mappingRepo,
+		orderService:    orderService,
+		logger:          logger,
+		config:          config,
+		cursors:         make(map[entities.OrderMappingState]*entities.OrderMapping),
+	}
+}
+
+// Execute sweeps the proxy orders past the TTL: active ones, and those of
+// expired and cancelled mappings closed within the last TTL and interval, so
+// each closed mapping is checked by at least one sweep. Each sweep continues
+// after the last mapping the previous one checked. Sweeps never overlap; a
+// second caller waits for the first to finish.
+func (uc *ProxyOrderSweepUseCase) Execute(ctx context.Context) (*dto.SweepReport, error) {
+	uc.running.Lock()
+	defer uc.running.Unlock()
+
+	settings := uc.config.GetSweepConfig()
+	report := &dto.SweepReport{
+		StartedAt: time.Now(),
+		Orders:    []dto.SweptOrder{},
+	}
+	cutoff := report.StartedAt.Add(-settings.TTL)
+
+	// List every state before sweeping, so a mapping expired by this sweep is
+	// not listed again as expired
+	var mappings []*entities.OrderMapping
+	for _, state := range sweptMappingStates {
+		batch, err := uc.nextBatch(ctx, state, cutoff, settings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s order mappings: %w", state, err)
+		}
+		mappings = append(mappings, batch...)
+	}
+
+	for _, mapping := range mappings {
+		if ctx.Err() != nil {
+			break
+		}
+		report.Scanned++
+
+		swept := uc.sweep(ctx, mapping, cutoff, settings)
+		if swept == nil {
+			continue
+		}
+		switch swept.Outcome {
+		case SweepOutcomeSkipped:
+			report.Skipped++
+		case SweepOutcomeFailed:
+			report.Failed++
+		default:
+			report.Expired++
+		}
+		report.Orders = append(report.Orders, *swept)
+	}
+	report.FinishedAt = time.Now()
+
+	uc.logger.Info("Proxy order sweep finished", map[string]interface{}{
+		"scanned":  report.Scanned,
+		"expired":  report.Expired,
+		"skipped":  report.Skipped,
+		"failed":   report.Failed,
+		"duration": report.FinishedAt.Sub(report.StartedAt).String(),
+	})
+
+	uc.mutex.Lock()
+	uc.lastReport = report
+	uc.mutex.Unlock()
+
+	return report, ctx.Err()
+}
+
+// LastReport returns the report of the most recent sweep, or nil before the first
+func (uc *ProxyOrderSweepUseCase) LastReport() *dto.SweepReport {
+	uc.mutex.RLock()
+	defer uc.mutex.RUnlock()
+	return uc.lastReport
+}
+
+// nextBatch lists the mappings in state created before cutoff that follow the
+// last one checked, starting over with the oldest once the newest was
+// checked. Closed mappings are only listed while they are recent enough to
+// still be swept. The caller holds the running lock.
+func (uc *ProxyOrderSweepUseCase) nextBatch(ctx context.Context, state entities.OrderMappingState, cutoff time.Time, settings interfaces.SweepConfig) ([]*entities.OrderMapping, error) {
+	var start *entities.OrderMapping
+	if state != entities.MappingStateActive {
+		start = &entities.OrderMapping{CreatedAt: cutoff.Add(-settings.TTL - settings.Interval)}
+	}
+
+	after := uc.cursors[state]
+	if after == nil || (start != nil && after.CreatedAt.Before(start.CreatedAt)) {
+		after = start
+	}
+
+	mappings, err := uc.mappingRepo.ListByState(ctx, state, cutoff, after, settings.BatchSize)
+	if err == nil && len(mappings) == 0 && after != start {
+		mappings, err = uc.mappingRepo.ListByState(ctx, state, cutoff, start, settings.BatchSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	uc.cursors[state] = nil
+	if len(mappings) == settings.BatchSize {
+		uc.cursors[state] = mappings[len(mappings)-1]
+	}
+	return mappings, nil
+}
+
+// sweep closes one proxy order created before cutoff. It returns nil when the
+// proxy order turns out to be younger than the mapping suggested, or when a
+// closed mapping's proxy order needs nothing done.
+func (uc *ProxyOrderSweepUseCase) sweep(ctx context.Context, mapping *entities.OrderMapping, cutoff time.Time, settings interfaces.SweepConfig) *dto.SweptOrder {
+	swept := &dto.SweptOrder{
+		OrderID:      mapping.MagicOrderID,
+		OITAMOrderID: mapping.OITAMOrderID,
+	}
+
+	oitamOrder, err := uc.wooCommerceRepo.GetOITAMOrder(ctx, mapping.OITAMOrderID)
+	if err != nil {
+		return uc.failed(swept, "failed to fetch OITAM proxy order", err)
+	}
+	swept.OITAMStatus = string(oitamOrder.Status)
+
+	createdAt := proxyCreatedAt(oitamOrder, mapping)
+	if createdAt.After(cutoff) {
+		return nil
+	}
+
+	active := mapping.State == entities.MappingStateActive
+	switch {
+	case oitamOrder.TransactionID != "",
+		oitamOrder.Status == entities.StatusProcessing,
+		oitamOrder.Status == entities.StatusCompleted,
+		oitamOrder.Status == entities.StatusOnHold,
+		oitamOrder.Status == entities.StatusRefunded:
+		if !active {
+			// Reconciliation only checks active mappings
+			swept.Outcome = SweepOutcomeSkipped
+			swept.Detail = fmt.Sprintf("proxy order is %s although its mapping is %s; needs review", oitamOrder.Status, mapping.State)
+			return swept
+		}
+		// Paid, or possibly paid; reconciliation applies it to the MagicSpore order
+		swept.Outcome = SweepOutcomeSkipped
+		swept.Detail = fmt.Sprintf("proxy order is %s and may have been paid; left to reconciliation", oitamOrder.Status)
+		return swept
+
+	case oitamOrder.Status == entities.StatusPending, oitamOrder.Status == entities.StatusFailed:
+		if settings.Trash {
+			swept.Outcome = SweepOutcomeTrashed
+			err = uc.wooCommerceRepo.TrashOITAMOrder(ctx, mapping.OITAMOrderID)
+		} else {
+			swept.Outcome = SweepOutcomeCancelled
+			err = uc.wooCommerceRepo.UpdateOITAMOrderStatus(ctx, mapping.OITAMOrderID, entities.StatusCancelled)
+		}
+		if err != nil {
+			return uc.failed(swept, "failed to close OITAM proxy order", err)
+		}
+		swept.Detail = fmt.Sprintf("unpaid proxy order created %s %s", createdAt.UTC().Format(time.RFC3339), swept.Outcome)
+
+	case !active:
+		// Closed on both sides already
+		return nil
+
+	default:
+		swept.Outcome = SweepOutcomeAlreadyClosed
+		swept.Detail = fmt.Sprintf("proxy order is already %s", oitamOrder.Status)
+	}
+
+	// A closed mapping keeps its state, and its MagicSpore order was already
+	// cancelled or moved on to a newer checkout
+	if active {
+		if err := uc.mappingRepo.UpdateState(ctx, mapping.OITAMOrderID, entities.MappingStateExpired); err != nil {
+			return uc.failed(swept, "failed to mark order mapping expired", err)
+		}
+
+		if settings.RestoreMagicOrder {
+			swept.MagicRestored = uc.restoreMagicOrder(ctx, mapping, settings.TTL)
+		}
+	}
+
+	uc.logger.Info("Abandoned proxy order swept", map[string]interface{}{
+		"order_id":       mapping.MagicOrderID,
+		"oitam_order_id": mapping.OITAMOrderID,
+		"outcome":        swept.Outcome,
+		"magic_restored": swept.MagicRestored,
+	})
+
+	return swept
+}
+
+// restoreMagicOrder moves the MagicSpore order of an abandoned checkout back to
+// pending so the customer can pay again, and notes why. Orders that were paid,
+// cancelled, or have a newer checkout of their own are left alone.
+func (uc *ProxyOrderSweepUseCase) restoreMagicOrder(ctx context.Context, mapping *entities.OrderMapping, ttl time.Duration) bool {
+	fields := map[string]interface{}{
+		"order_id":       mapping.MagicOrderID,
+		"oitam_order_id": mapping.OITAMOrderID,
+	}
+
+	if latest, err := uc.mappingRepo.GetByMagicOrderID(ctx, mapping.MagicOrderID); err == nil && latest.OITAMOrderID != mapping.OITAMOrderID {
+		return false
+	}
+
+	order, err := uc.wooCommerceRepo.GetMagicOrder(ctx, mapping.MagicOrderID)
+	if err != nil {
+		uc.logger.Error("Failed to fetch order for restoring", err, fields)
+		return false
+	}
+	if order.IsPaymentCompleted() {
+		return false
+	}
+
+	from := order.Status
+	if err := uc.orderService.TransitionOrder(ctx, order, entities.StatusPending, "proxy order abandoned"); err != nil {
+		return false
+	}
+	if from != entities.StatusPending {
+		if err := uc.wooCommerceRepo.UpdateMagicOrderStatus(ctx, mapping.MagicOrderID, entities.StatusPending); err != nil {
+			uc.logger.Error("Failed to restore order to pending", err, fields)
+			return false
+		}
+		recordStatusHistory(ctx, uc.wooCommerceRepo, uc.logger, mapping.MagicOrderID, order)
+	}
+
+	note := fmt.Sprintf("PayPal checkout abandoned: proxy order %s was not paid within %s and has been closed. The order is awaiting payment again.",
+		mapping.OITAMOrderID, ttl)
+	if err := uc.wooCommerceRepo.AddMagicOrderNote(ctx, mapping.MagicOrderID, note); err != nil {
+		uc.logger.Error("Failed to add sweep order note", err, fields)
+	}
+
+	return true
+}
+
+// failed records a proxy order the sweeper could not close; the next sweep retries it
+func (uc *ProxyOrderSweepUseCase) failed(swept *dto.SweptOrder, detail string, err error) *dto.SweptOrder {
+	swept.Outcome = SweepOutcomeFailed
+	swept.Detail = detail
+	swept.Error = err.Error()
+
+	uc.logger.Error("Failed to sweep abandoned proxy order", err, map[string]interface{}{
+		"order_id":       swept.OrderID,
+		"oitam_order_id": swept.OITAMOrderID,
+		"detail":         detail,
+	})
+	return swept
+}
+
+// proxyCreatedAt returns when a proxy order was created, from the meta data
+// CreateOITAMOrder stamps on it, falling back to the mapping's creation time
+func proxyCreatedAt(oitamOrder *entities.Order, mapping *entities.OrderMapping) time.Time {
+	for _, meta := range oitamOrder.MetaData {
+		if meta.Key != proxyCreatedAtMetaKey {
+			continue
+		}
+
+		var seconds int64
+		var err error
+		switch value := meta.Value.(type) {
+		case string:
+			seconds, err = strconv.ParseInt(value, 10, 64)
+		case json.Number:
+			seconds, err = value.Int64()
+		case float64:
+			seconds = int64(value)
+		case int64:
+			seconds = value
+		case int:
+			seconds = int64(value)
+		default:
+			err = fmt.Errorf("unexpected type %T", value)
+		}
+		if err == nil && seconds > 0 {
+			return time.Unix(seconds, 0)
+		}
+	}
+
+	return mapping.CreatedAt
+}
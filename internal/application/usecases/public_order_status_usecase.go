@@ -0,0 +1,55 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+)
+
+// PublicOrderStatusUseCase serves an order's payment status to the customer
+// holding its status token, without exposing the rest of the order
+type PublicOrderStatusUseCase struct {
+	wooCommerceRepo interfaces.WooCommerceRepository
+	tokens          *services.StatusTokenService
+	logger          interfaces.Logger
+}
+
+// NewPublicOrderStatusUseCase creates a new public order status use case.
+// Without a status token secret every token is rejected.
+func NewPublicOrderStatusUseCase(wooCommerceRepo interfaces.WooCommerceRepository, config interfaces.ConfigService, logger interfaces.Logger) *PublicOrderStatusUseCase {
+	return &PublicOrderStatusUseCase{
+		wooCommerceRepo: wooCommerceRepo,
+		tokens:          services.NewStatusTokenService(config.GetStatusTokenConfig()),
+		logger:          logger,
+	}
+}
+
+// Execute returns the status of the order a token was issued for
+func (uc *PublicOrderStatusUseCase) Execute(ctx context.Context, token string) (*dto.PublicOrderStatusResponse, error) {
+	// Check the signature and expiry first, so forged tokens never reach WooCommerce
+	orderID, err := uc.tokens.OrderID(token)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := uc.wooCommerceRepo.GetMagicOrder(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch order: %w", err)
+	}
+
+	if err := uc.tokens.Verify(token, orderID, order.OrderKey); err != nil {
+		uc.logger.Warn("Rejected order status token", map[string]interface{}{
+			"order_id": orderID,
+			"error":    err.Error(),
+		})
+		return nil, err
+	}
+
+	return &dto.PublicOrderStatusResponse{
+		OrderID:  orderID,
+		Status:   string(order.Status),
+		DatePaid: order.DatePaid,
+	}, nil
+}
@@ -0,0 +1,309 @@
+package usecases
+
+import (
+	"context"
+	"fmt"
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"sync"
+	"time"
+)
+
+// Discrepancy kinds reported by the reconciler
+const (
+	DiscrepancyFetchFailed        = "fetch_failed"
+	DiscrepancyMissedPayment      = "missed_payment"
+	DiscrepancyPaymentUnconfirmed = "payment_unconfirmed"
+	DiscrepancyPaymentRejected    = "payment_rejected"
+	DiscrepancyPaidAfterClose     = "paid_after_close"
+	DiscrepancyTransactionID      = "transaction_id_drift"
+	DiscrepancyDuplicatePayment   = "duplicate_payment"
+	DiscrepancyCaptureNotSettled  = "capture_not_settled"
+	DiscrepancyMappingState       = "mapping_state_drift"
+	DiscrepancyProxyNotPaid       = "proxy_order_not_paid"
+	DiscrepancyProxyOrderClosed   = "proxy_order_closed"
+)
+
+// ReconciliationUseCase compares active proxy orders against both WooCommerce
+// stores and PayPal, repairing the drift left behind by lost return redirects
+// and webhooks
+type ReconciliationUseCase struct {
+	returnUseCase   *PaymentReturnUseCase
+	wooCommerceRepo interfaces.WooCommerceRepository
+	paymentRepo     interfaces.PaymentRepository
+	mappingRepo     interfaces.OrderMappingRepository
+	paymentGateway  interfaces.PaymentGateway
+	logger          interfaces.Logger
+	config          interfaces.ConfigService
+
+	running    sync.Mutex             // held for the length of a run
+	cursor     *entities.OrderMapping // last mapping checked; guarded by running
+	mutex      sync.RWMutex
+	lastReport *dto.ReconciliationReport
+}
+
+// NewReconciliationUseCase creates a new reconciliation use case. Payments are
+// verified the same way as on return; without a payment gateway, from the
+// OITAM proxy order alone.
+func NewReconciliationUseCase(
+	returnUseCase *PaymentReturnUseCase,
+	wooCommerceRepo interfaces.WooCommerceRepository,
+	paymentRepo interfaces.PaymentRepository,
+	mappingRepo interfaces.OrderMappingRepository,
+	paymentGateway interfaces.PaymentGateway,
+	logger interfaces.Logger,
+	config interfaces.ConfigService,
+) *ReconciliationUseCase {
+	return &ReconciliationUseCase{
+		returnUseCase:   returnUseCase,
+		wooCommerceRepo: wooCommerceRepo,
+		paymentRepo:     paymentRepo,
+		mappingRepo:     mappingRepo,
+		paymentGateway:  paymentGateway,
+		logger:          logger,
+		config:          config,
+	}
+}
+
+// Execute reconciles the next batch of active proxy orders and returns the
+// discrepancies found. Each run continues after the last proxy order the
+// previous run checked and starts over with the oldest once every one was
+// checked, so a backlog of old proxy orders cannot starve newer ones. Runs
+// never overlap; a second caller waits for the first to finish.
+func (uc *ReconciliationUseCase) Execute(ctx context.Context) (*dto.ReconciliationReport, error) {
+	uc.running.Lock()
+	defer uc.running.Unlock()
+
+	settings := uc.config.GetReconciliationConfig()
+	report := &dto.ReconciliationReport{
+		StartedAt:     time.Now(),
+		Discrepancies: []dto.ReconciliationDiscrepancy{},
+	}
+
+	createdBefore := report.StartedAt.Add(-settings.MinAge)
+	mappings, err := uc.mappingRepo.ListByState(ctx, entities.MappingStateActive, createdBefore, uc.cursor, settings.BatchSize)
+	if err == nil && len(mappings) == 0 && uc.cursor != nil {
+		uc.cursor = nil
+		mappings, err = uc.mappingRepo.ListByState(ctx, entities.MappingStateActive, createdBefore, nil, settings.BatchSize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active order mappings: %w", err)
+	}
+
+	for i, mapping := range mappings {
+		if ctx.Err() != nil {
+			break
+		}
+		report.Scanned++
+
+		// The next run starts over once the last active proxy order was checked
+		uc.cursor = mapping
+		if i == len(mappings)-1 && len(mappings) < settings.BatchSize {
+			uc.cursor = nil
+		}
+
+		discrepancy := uc.reconcile(ctx, mapping)
+		if discrepancy == nil {
+			continue
+		}
+		if discrepancy.Repaired {
+			report.Repaired++
+		} else {
+			report.Unresolved++
+		}
+		report.Discrepancies = append(report.Discrepancies, *discrepancy)
+	}
+	report.FinishedAt = time.Now()
+
+	uc.logger.Info("Reconciliation finished", map[string]interface{}{
+		"scanned":    report.Scanned,
+		"repaired":   report.Repaired,
+		"unresolved": report.Unresolved,
+		"duration":   report.FinishedAt.Sub(report.StartedAt).String(),
+	})
+
+	uc.mutex.Lock()
+	uc.lastReport = report
+	uc.mutex.Unlock()
+
+	return report, ctx.Err()
+}
+
+// LastReport returns the report of the most recent run, or nil before the first
+func (uc *ReconciliationUseCase) LastReport() *dto.ReconciliationReport {
+	uc.mutex.RLock()
+	defer uc.mutex.RUnlock()
+	return uc.lastReport
+}
+
+// reconcile checks one proxy order against its MagicSpore order. It returns
+// nil when both stores agree or the payment is simply still outstanding.
+func (uc *ReconciliationUseCase) reconcile(ctx context.Context, mapping *entities.OrderMapping) *dto.ReconciliationDiscrepancy {
+	discrepancy := &dto.ReconciliationDiscrepancy{
+		OrderID:      mapping.MagicOrderID,
+		OITAMOrderID: mapping.OITAMOrderID,
+	}
+
+	magicOrder, err := uc.wooCommerceRepo.GetMagicOrder(ctx, mapping.MagicOrderID)
+	if err != nil {
+		return uc.unresolved(discrepancy, DiscrepancyFetchFailed, "failed to fetch MagicSpore order", err)
+	}
+	discrepancy.MagicStatus = string(magicOrder.Status)
+
+	oitamOrder, err := uc.wooCommerceRepo.GetOITAMOrder(ctx, mapping.OITAMOrderID)
+	if err != nil {
+		return uc.unresolved(discrepancy, DiscrepancyFetchFailed, "failed to fetch OITAM proxy order", err)
+	}
+	discrepancy.OITAMStatus = string(oitamOrder.Status)
+	discrepancy.TransactionID = oitamOrder.TransactionID
+
+	proxyPaid := oitamOrder.Status == entities.StatusProcessing || oitamOrder.Status == entities.StatusCompleted
+
+	switch {
+	case proxyPaid && !magicOrder.IsPaymentCompleted():
+		return uc.recoverPayment(ctx, mapping, magicOrder, oitamOrder, discrepancy)
+
+	case proxyPaid:
+		return uc.reconcilePaidOrder(ctx, mapping, magicOrder, oitamOrder, discrepancy)
+
+	case magicOrder.IsPaymentCompleted():
+		// A newer proxy order may have paid for it; the old one just expires
+		if latest, err := uc.mappingRepo.GetByMagicOrderID(ctx, mapping.MagicOrderID); err == nil && latest.OITAMOrderID != mapping.OITAMOrderID {
+			return nil
+		}
+		return uc.unresolved(discrepancy, DiscrepancyProxyNotPaid,
+			fmt.Sprintf("MagicSpore order is %s but its proxy order is %s", magicOrder.Status, oitamOrder.Status), nil)
+
+	case oitamOrder.Status == entities.StatusCancelled || oitamOrder.Status == entities.StatusFailed:
+		state := entities.MappingStateCancelled
+		if oitamOrder.Status == entities.StatusFailed {
+			state = entities.MappingStateFailed
+		}
+		discrepancy.Kind = DiscrepancyProxyOrderClosed
+		discrepancy.Detail = fmt.Sprintf("proxy order is %s; mapping marked %s", oitamOrder.Status, state)
+		return uc.repaired(discrepancy, uc.mappingRepo.UpdateState(ctx, mapping.OITAMOrderID, state))
+	}
+
+	return nil
+}
+
+// recoverPayment records a proxy order payment the MagicSpore order never
+// heard about, after verifying it like a return would
+func (uc *ReconciliationUseCase) recoverPayment(ctx context.Context, mapping *entities.OrderMapping, magicOrder, oitamOrder *entities.Order, discrepancy *dto.ReconciliationDiscrepancy) *dto.ReconciliationDiscrepancy {
+	if !magicOrder.Status.CanTransitionTo(entities.StatusProcessing) {
+		return uc.unresolved(discrepancy, DiscrepancyPaidAfterClose,
+			fmt.Sprintf("proxy order was paid but the MagicSpore order is %s", magicOrder.Status), nil)
+	}
+
+	verification := &paymentVerification{
+		outcome:      verificationPending,
+		order:        magicOrder,
+		mapping:      mapping,
+		oitamOrderID: mapping.OITAMOrderID,
+	}
+	uc.returnUseCase.verifyProxyOrder(ctx, mapping.MagicOrderID, verification, oitamOrder)
+	discrepancy.PayPalStatus = string(verification.captureState)
+
+	switch verification.outcome {
+	case verificationRejected:
+		return uc.unresolved(discrepancy, DiscrepancyPaymentRejected, verification.reason, nil)
+	case verificationPending:
+		return uc.unresolved(discrepancy, DiscrepancyPaymentUnconfirmed, verification.reason, nil)
+	}
+
+	discrepancy.Kind = DiscrepancyMissedPayment
+	discrepancy.Detail = fmt.Sprintf("proxy order paid with capture %s; MagicSpore order moved to processing", verification.captureID)
+	discrepancy.TransactionID = verification.captureID
+	if err := uc.returnUseCase.recordVerifiedPayment(ctx, mapping.MagicOrderID, "", verification, "payment recovered by reconciliation"); err != nil {
+		return uc.repaired(discrepancy, err)
+	}
+
+	uc.addNote(ctx, mapping.MagicOrderID, fmt.Sprintf("Payment recovered by reconciliation: proxy order %s was paid with PayPal capture %s (%s).",
+		mapping.OITAMOrderID, verification.captureID, verification.captured.String()))
+	return uc.repaired(discrepancy, nil)
+}
+
+// reconcilePaidOrder checks an order both stores consider paid for a drifted
+// transaction ID, an unsettled capture or a mapping left active
+func (uc *ReconciliationUseCase) reconcilePaidOrder(ctx context.Context, mapping *entities.OrderMapping, magicOrder, oitamOrder *entities.Order, discrepancy *dto.ReconciliationDiscrepancy) *dto.ReconciliationDiscrepancy {
+	captureID := oitamOrder.TransactionID
+
+	if uc.paymentGateway != nil && captureID != "" {
+		capture, err := uc.paymentGateway.GetPaymentStatus(ctx, captureID)
+		if err != nil {
+			return uc.unresolved(discrepancy, DiscrepancyFetchFailed, "failed to look up PayPal capture", err)
+		}
+		discrepancy.PayPalStatus = string(capture.Status)
+		if !capture.IsCompleted() {
+			return uc.unresolved(discrepancy, DiscrepancyCaptureNotSettled,
+				fmt.Sprintf("both orders are paid but PayPal capture %s is %s", captureID, capture.Status), nil)
+		}
+	}
+
+	if captureID != "" && magicOrder.TransactionID != captureID {
+		// The order recording a capture of its own was paid twice
+		if magicOrder.TransactionID != "" {
+			if existing, err := uc.paymentRepo.GetByPaymentID(ctx, magicOrder.TransactionID); err == nil && existing.OrderID == mapping.MagicOrderID && existing.IsCompleted() {
+				return uc.unresolved(discrepancy, DiscrepancyDuplicatePayment,
+					fmt.Sprintf("MagicSpore order was paid with capture %s and proxy order with capture %s", magicOrder.TransactionID, captureID), nil)
+			}
+		}
+
+		discrepancy.Kind = DiscrepancyTransactionID
+		discrepancy.Detail = fmt.Sprintf("MagicSpore transaction ID %q corrected to %s", magicOrder.TransactionID, captureID)
+		if err := uc.wooCommerceRepo.UpdateMagicOrderTransactionID(ctx, mapping.MagicOrderID, captureID); err != nil {
+			return uc.repaired(discrepancy, err)
+		}
+		uc.addNote(ctx, mapping.MagicOrderID, fmt.Sprintf("Transaction ID corrected to PayPal capture %s by reconciliation.", captureID))
+	} else {
+		discrepancy.Kind = DiscrepancyMappingState
+		discrepancy.Detail = "both orders are paid; mapping marked paid"
+	}
+
+	return uc.repaired(discrepancy, uc.mappingRepo.UpdateState(ctx, mapping.OITAMOrderID, entities.MappingStatePaid))
+}
+
+// repaired finishes a discrepancy the reconciler fixed, unless err says the fix failed
+func (uc *ReconciliationUseCase) repaired(discrepancy *dto.ReconciliationDiscrepancy, err error) *dto.ReconciliationDiscrepancy {
+	fields := map[string]interface{}{
+		"order_id":       discrepancy.OrderID,
+		"oitam_order_id": discrepancy.OITAMOrderID,
+		"kind":           discrepancy.Kind,
+	}
+	if err != nil {
+		discrepancy.Error = err.Error()
+		uc.logger.Error("Failed to repair order drift", err, fields)
+		return discrepancy
+	}
+
+	discrepancy.Repaired = true
+	uc.logger.Info("Order drift repaired", fields)
+	return discrepancy
+}
+
+// unresolved records a discrepancy that needs a manual check
+func (uc *ReconciliationUseCase) unresolved(discrepancy *dto.ReconciliationDiscrepancy, kind, detail string, err error) *dto.ReconciliationDiscrepancy {
+	discrepancy.Kind = kind
+	discrepancy.Detail = detail
+	if err != nil {
+		discrepancy.Error = err.Error()
+	}
+
+	uc.logger.Warn("Order drift needs a manual check", map[string]interface{}{
+		"order_id":       discrepancy.OrderID,
+		"oitam_order_id": discrepancy.OITAMOrderID,
+		"kind":           kind,
+		"detail":         detail,
+	})
+	return discrepancy
+}
+
+// addNote adds a MagicSpore order note, logging failures
+func (uc *ReconciliationUseCase) addNote(ctx context.Context, orderID, note string) {
+	if err := uc.wooCommerceRepo.AddMagicOrderNote(ctx, orderID, note); err != nil {
+		uc.logger.Error("Failed to add reconciliation order note", err, map[string]interface{}{
+			"order_id": orderID,
+		})
+	}
+}
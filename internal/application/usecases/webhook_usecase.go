@@ -2,37 +2,467 @@ package usecases
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"paypal-proxy/internal/application/dto"
 	"paypal-proxy/internal/domain/entities"
 	"paypal-proxy/internal/domain/interfaces"
 	"paypal-proxy/internal/domain/services"
+	"sort"
+	"strings"
+	"time"
 )
 
+// staleWebhookAfter is how long an event may stay in the received state before
+// a redelivery treats the original attempt as lost and processes it again
+const staleWebhookAfter = 5 * time.Minute
+
+// claimBatchSize is how many due events a worker considers per claim, so
+// workers racing for the oldest event can fall back to the next ones
+const claimBatchSize = 10
+
 // WebhookUseCase handles webhook processing use case
 type WebhookUseCase struct {
 	wooCommerceRepo interfaces.WooCommerceRepository
+	paymentRepo     interfaces.PaymentRepository
+	mappingRepo     interfaces.OrderMappingRepository
+	eventRepo       interfaces.WebhookEventRepository
+	disputeRepo     interfaces.DisputeRepository
 	paymentService  *services.PaymentDomainService
 	orderService    *services.OrderDomainService
+	timeline        *OrderTimelineUseCase
 	logger          interfaces.Logger
 	config          interfaces.ConfigService
+	eventLocks      *keyedMutex
 }
 
 // NewWebhookUseCase creates a new webhook use case
 func NewWebhookUseCase(
 	wooCommerceRepo interfaces.WooCommerceRepository,
+	paymentRepo interfaces.PaymentRepository,
+	mappingRepo interfaces.OrderMappingRepository,
+	eventRepo interfaces.WebhookEventRepository,
+	disputeRepo interfaces.DisputeRepository,
 	paymentService *services.PaymentDomainService,
 	orderService *services.OrderDomainService,
+	timeline *OrderTimelineUseCase,
 	logger interfaces.Logger,
 	config interfaces.ConfigService,
 ) *WebhookUseCase {
 	return &WebhookUseCase{
 		wooCommerceRepo: wooCommerceRepo,
+		paymentRepo:     paymentRepo,
+		mappingRepo:     mappingRepo,
+		eventRepo:       eventRepo,
+		disputeRepo:     disputeRepo,
 		paymentService:  paymentService,
 		orderService:    orderService,
+		timeline:        timeline,
 		logger:          logger,
 		config:          config,
+		eventLocks:      newKeyedMutex(),
+	}
+}
+
+// Receive records a verified delivery and processes it, or queues it for the
+// background workers when webhook retries are enabled. Redeliveries of an
+// event that was already handled are acknowledged without processing again.
+func (uc *WebhookUseCase) Receive(ctx context.Context, delivery *dto.WebhookDelivery) (*dto.WebhookResponse, error) {
+	request := delivery.Request
+	if request.ID == "" {
+		return nil, errors.New("webhook event ID is required")
+	}
+
+	unlock := uc.eventLocks.Lock(request.ID)
+	defer unlock()
+
+	queued := uc.config.GetWebhookQueueConfig().Enabled
+
+	event := entities.NewWebhookEvent(request.ID, request.EventType, delivery.Body, entities.WebhookSignatureVerdict(delivery.Signature))
+	if queued {
+		event.Enqueue(event.ReceivedAt)
+	}
+	err := uc.eventRepo.Create(ctx, event)
+	if errors.Is(err, entities.ErrDuplicateWebhookEvent) {
+		existing, err := uc.eventRepo.GetByID(ctx, request.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load webhook event: %w", err)
+		}
+
+		stale := existing.Status == entities.WebhookEventReceived && time.Since(existing.ReceivedAt) > staleWebhookAfter
+		if !existing.NeedsProcessing() && !stale {
+			uc.logger.Info("Duplicate webhook acknowledged", map[string]interface{}{
+				"event_type": request.EventType,
+				"webhook_id": request.ID,
+				"status":     existing.Status,
+			})
+			return &dto.WebhookResponse{
+				Status:  "duplicate",
+				Message: fmt.Sprintf("Event %s already %s", request.ID, existing.Status),
+			}, nil
+		}
+
+		// Retry of a failed or lost attempt, or a signed redelivery of an event
+		// first seen with a bad signature. The stored body may be forged, so
+		// only this delivery's payload is processed.
+		existing.AcceptRedelivery(event)
+		if queued {
+			return uc.requeue(ctx, existing)
+		}
+		event = existing
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to record webhook event: %w", err)
+	} else if queued {
+		uc.logger.Info("Webhook queued", map[string]interface{}{
+			"event_type": request.EventType,
+			"webhook_id": request.ID,
+		})
+		return queuedResponse(event), nil
+	}
+
+	return uc.process(ctx, event, request)
+}
+
+// RecordRejected stores a delivery that failed signature verification so it
+// shows up in the event history. It is never processed or replayed.
+func (uc *WebhookUseCase) RecordRejected(ctx context.Context, delivery *dto.WebhookDelivery) error {
+	request := delivery.Request
+	if request.ID == "" {
+		return nil
+	}
+
+	event := entities.NewWebhookEvent(request.ID, request.EventType, delivery.Body, entities.SignatureInvalid)
+	err := uc.eventRepo.Create(ctx, event)
+	if err != nil && !errors.Is(err, entities.ErrDuplicateWebhookEvent) {
+		return fmt.Errorf("failed to record rejected webhook event: %w", err)
+	}
+	return nil
+}
+
+// Replay processes a stored event again, regardless of its previous outcome
+func (uc *WebhookUseCase) Replay(ctx context.Context, eventID string) (*dto.WebhookReplayResult, error) {
+	unlock := uc.eventLocks.Lock(eventID)
+	defer unlock()
+
+	event, err := uc.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.replay(ctx, event)
+}
+
+// ReplayRange processes again every trusted event received in [from, to), oldest first
+func (uc *WebhookUseCase) ReplayRange(ctx context.Context, from, to time.Time) (*dto.WebhookReplayResponse, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("invalid replay range: %s is not before %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	events, err := uc.eventRepo.ListByReceivedAt(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.logger.Info("Replaying webhook events", map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"events": len(events),
+	})
+
+	response := &dto.WebhookReplayResponse{Events: []dto.WebhookReplayResult{}}
+	for _, event := range events {
+		if !event.IsTrusted() {
+			response.Skipped++
+			response.Events = append(response.Events, dto.WebhookReplayResult{
+				EventID:   event.ID,
+				EventType: event.EventType,
+				Status:    string(entities.WebhookEventRejected),
+				Message:   "Event failed signature verification",
+			})
+			continue
+		}
+
+		result, err := uc.Replay(ctx, event.ID)
+		if err != nil {
+			response.Failed++
+			response.Events = append(response.Events, dto.WebhookReplayResult{
+				EventID:   event.ID,
+				EventType: event.EventType,
+				Status:    string(entities.WebhookEventFailed),
+				Message:   err.Error(),
+			})
+			continue
+		}
+
+		response.Replayed++
+		response.Events = append(response.Events, *result)
+	}
+
+	return response, nil
+}
+
+// Requeue puts a stored event back on the queue with a fresh set of attempts,
+// typically to retry a dead-lettered event once its cause is fixed
+func (uc *WebhookUseCase) Requeue(ctx context.Context, eventID string) (*dto.WebhookResponse, error) {
+	if !uc.config.GetWebhookQueueConfig().Enabled {
+		return nil, errors.New("webhook queue is not enabled")
+	}
+
+	unlock := uc.eventLocks.Lock(eventID)
+	defer unlock()
+
+	event, err := uc.eventRepo.GetByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if !event.IsTrusted() {
+		return nil, fmt.Errorf("webhook event %s failed signature verification and cannot be requeued", event.ID)
+	}
+
+	return uc.requeue(ctx, event)
+}
+
+// DeadLetters returns up to limit events that exhausted their attempts, oldest first
+func (uc *WebhookUseCase) DeadLetters(ctx context.Context, limit int) ([]dto.WebhookEventResponse, error) {
+	events, err := uc.eventRepo.ListByStatus(ctx, entities.WebhookEventDeadLetter, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.WebhookEventResponse, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, dto.WebhookEventResponse{
+			ID:            event.ID,
+			EventType:     event.EventType,
+			Status:        string(event.Status),
+			Signature:     string(event.Signature),
+			Result:        event.Result,
+			Attempts:      event.Attempts,
+			ReceivedAt:    event.ReceivedAt,
+			ProcessedAt:   event.ProcessedAt,
+			NextAttemptAt: event.NextAttemptAt,
+		})
+	}
+	return responses, nil
+}
+
+// OpenDisputes returns the disputes not resolved yet, the most urgent deadline
+// first and disputes without a deadline last
+func (uc *WebhookUseCase) OpenDisputes(ctx context.Context) ([]dto.DisputeResponse, error) {
+	disputes, err := uc.disputeRepo.ListOpen(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(disputes, func(i, j int) bool {
+		first, second := disputes[i].Deadline(), disputes[j].Deadline()
+		return first != nil && (second == nil || first.Before(*second))
+	})
+
+	responses := make([]dto.DisputeResponse, 0, len(disputes))
+	for _, dispute := range disputes {
+		responses = append(responses, dto.DisputeResponse{
+			ID:                  dispute.ID,
+			OrderID:             dispute.OrderID,
+			OITAMOrderID:        dispute.OITAMOrderID,
+			TransactionID:       dispute.TransactionID,
+			Reason:              dispute.Reason,
+			Status:              string(dispute.Status),
+			Stage:               dispute.Stage,
+			Amount:              dispute.Amount.Decimal(),
+			Currency:            dispute.Amount.Currency,
+			Deadline:            dispute.Deadline(),
+			SellerResponseDueAt: dispute.SellerResponseDueAt,
+			BuyerResponseDueAt:  dispute.BuyerResponseDueAt,
+			CreatedAt:           dispute.CreatedAt,
+			UpdatedAt:           dispute.UpdatedAt,
+		})
+	}
+	return responses, nil
+}
+
+// ClaimNext leases the oldest due event for processing by this worker. It
+// returns nil when nothing is due. Until the lease runs out no other worker,
+// in this process or another, picks the event up.
+func (uc *WebhookUseCase) ClaimNext(ctx context.Context, lease time.Duration) (*entities.WebhookEvent, error) {
+	now := time.Now()
+	due, err := uc.eventRepo.ListDue(ctx, now, claimBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// Microsecond precision survives every store, so the lease can be compared later
+	leaseUntil := now.Add(lease).Round(0).Truncate(time.Microsecond)
+	for _, event := range due {
+		claimed, err := uc.eventRepo.Claim(ctx, event, leaseUntil)
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			return event, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// ProcessQueued processes a claimed event. Failures are retried with
+// exponential backoff until the configured attempts are used up, after which
+// the event is dead-lettered.
+func (uc *WebhookUseCase) ProcessQueued(ctx context.Context, claimed *entities.WebhookEvent) error {
+	unlock := uc.eventLocks.Lock(claimed.ID)
+	defer unlock()
+
+	// The event may have been replayed or re-claimed after a lost lease
+	event, err := uc.eventRepo.GetByID(ctx, claimed.ID)
+	if err != nil {
+		return err
+	}
+	if !event.IsQueued() || !event.NextAttemptAt.Equal(*claimed.NextAttemptAt) {
+		uc.logger.Debug("Skipping webhook event no longer held by this worker", map[string]interface{}{
+			"webhook_id": event.ID,
+			"status":     event.Status,
+		})
+		return nil
+	}
+
+	var response *dto.WebhookResponse
+	request, err := decodeWebhookEvent(event)
+	if err == nil {
+		response, err = uc.Execute(ctx, request)
+	}
+
+	settings := uc.config.GetWebhookQueueConfig()
+	switch {
+	case err == nil && response.Status == "ignored":
+		event.RecordOutcome(entities.WebhookEventIgnored, response.Message)
+	case err == nil:
+		event.RecordOutcome(entities.WebhookEventProcessed, response.Message)
+	case event.Attempts+1 >= settings.MaxAttempts:
+		event.RecordOutcome(entities.WebhookEventDeadLetter, err.Error())
+		uc.logger.Error("Webhook event dead-lettered", err, map[string]interface{}{
+			"event_type": event.EventType,
+			"webhook_id": event.ID,
+			"attempts":   event.Attempts,
+		})
+	default:
+		retryAt := time.Now().Add(backoffDelay(settings.RetryBaseDelay, settings.RetryMaxDelay, event.Attempts+1))
+		event.ScheduleRetry(err.Error(), retryAt)
+		uc.logger.Warn("Webhook processing failed - retry scheduled", map[string]interface{}{
+			"event_type": event.EventType,
+			"webhook_id": event.ID,
+			"attempts":   event.Attempts,
+			"retry_at":   retryAt,
+			"error":      err.Error(),
+		})
+	}
+
+	if updateErr := uc.eventRepo.Update(ctx, event); updateErr != nil {
+		return fmt.Errorf("failed to record webhook outcome: %w", updateErr)
+	}
+	return nil
+}
+
+// requeue schedules an existing event for immediate background processing
+func (uc *WebhookUseCase) requeue(ctx context.Context, event *entities.WebhookEvent) (*dto.WebhookResponse, error) {
+	if event.Status == entities.WebhookEventDeadLetter || event.Status == entities.WebhookEventRejected {
+		event.Attempts = 0
+	}
+	event.Enqueue(time.Now())
+
+	if err := uc.eventRepo.Update(ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to queue webhook event: %w", err)
+	}
+
+	uc.logger.Info("Webhook requeued", map[string]interface{}{
+		"event_type": event.EventType,
+		"webhook_id": event.ID,
+	})
+	return queuedResponse(event), nil
+}
+
+// replay decodes a stored event and runs it through Execute
+func (uc *WebhookUseCase) replay(ctx context.Context, event *entities.WebhookEvent) (*dto.WebhookReplayResult, error) {
+	if !event.IsTrusted() {
+		return nil, fmt.Errorf("webhook event %s failed signature verification and cannot be replayed", event.ID)
+	}
+
+	request, err := decodeWebhookEvent(event)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.logger.Info("Replaying webhook event", map[string]interface{}{
+		"event_type": event.EventType,
+		"webhook_id": event.ID,
+		"attempts":   event.Attempts,
+	})
+
+	response, err := uc.process(ctx, event, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.WebhookReplayResult{
+		EventID:   event.ID,
+		EventType: event.EventType,
+		Status:    response.Status,
+		Message:   response.Message,
+	}, nil
+}
+
+// queuedResponse acknowledges an event handed to the background workers
+func queuedResponse(event *entities.WebhookEvent) *dto.WebhookResponse {
+	return &dto.WebhookResponse{
+		Status:  string(entities.WebhookEventQueued),
+		Message: fmt.Sprintf("Event %s queued for processing", event.ID),
+	}
+}
+
+// decodeWebhookEvent restores the request a stored event was received with
+func decodeWebhookEvent(event *entities.WebhookEvent) (*dto.WebhookRequest, error) {
+	var request dto.WebhookRequest
+	if err := json.Unmarshal([]byte(event.Body), &request); err != nil {
+		return nil, fmt.Errorf("failed to decode stored webhook event %s: %w", event.ID, err)
 	}
+	return &request, nil
+}
+
+// backoffDelay returns the backoff after a number of failed attempts: the base
+// delay doubled for every failure after the first, capped at the maximum delay
+func backoffDelay(base, max time.Duration, failures int) time.Duration {
+	delay := base
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// process runs a webhook through Execute and records the outcome on its event
+func (uc *WebhookUseCase) process(ctx context.Context, event *entities.WebhookEvent, request *dto.WebhookRequest) (*dto.WebhookResponse, error) {
+	response, err := uc.Execute(ctx, request)
+
+	switch {
+	case err != nil:
+		event.RecordOutcome(entities.WebhookEventFailed, err.Error())
+	case response.Status == "ignored":
+		event.RecordOutcome(entities.WebhookEventIgnored, response.Message)
+	default:
+		event.RecordOutcome(entities.WebhookEventProcessed, response.Message)
+	}
+
+	if updateErr := uc.eventRepo.Update(ctx, event); updateErr != nil {
+		uc.logger.Error("Failed to record webhook outcome", updateErr, map[string]interface{}{
+			"webhook_id": event.ID,
+			"status":     event.Status,
+		})
+	}
+
+	return response, err
 }
 
 // Execute executes the webhook processing use case
@@ -42,14 +472,7 @@ func (uc *WebhookUseCase) Execute(ctx context.Context, request *dto.WebhookReque
 		"webhook_id": request.ID,
 	})
 
-	switch request.EventType {
-	case "PAYMENT.CAPTURE.COMPLETED":
-		return uc.handlePaymentCaptureCompleted(ctx, request)
-	case "PAYMENT.CAPTURE.DENIED":
-		return uc.handlePaymentCaptureDenied(ctx, request)
-	case "PAYMENT.CAPTURE.REFUNDED":
-		return uc.handlePaymentCaptureRefunded(ctx, request)
-	default:
+	if !entities.IsSupportedWebhookEventType(request.EventType) {
 		uc.logger.Info("Unhandled webhook event type", map[string]interface{}{
 			"event_type": request.EventType,
 			"webhook_id": request.ID,
@@ -59,6 +482,26 @@ func (uc *WebhookUseCase) Execute(ctx context.Context, request *dto.WebhookReque
 			Message: fmt.Sprintf("Event type %s not handled", request.EventType),
 		}, nil
 	}
+
+	response, err := uc.handle(ctx, request)
+	if err != nil {
+		orderID, _, _ := uc.resolveOrderID(ctx, uc.extractOrderIDFromResource(request.Resource))
+		uc.timeline.RecordError(ctx, orderID, fmt.Sprintf("Webhook %s failed", request.EventType), err)
+	}
+	return response, err
+}
+
+// handle dispatches a supported webhook to its handler
+func (uc *WebhookUseCase) handle(ctx context.Context, request *dto.WebhookRequest) (*dto.WebhookResponse, error) {
+	switch request.EventType {
+	case entities.EventPaymentCaptureCompleted:
+		return uc.handlePaymentCaptureCompleted(ctx, request)
+	case entities.EventPaymentCaptureRefunded:
+		return uc.handlePaymentCaptureRefunded(ctx, request)
+	case entities.EventCustomerDisputeCreated, entities.EventCustomerDisputeUpdated, entities.EventCustomerDisputeResolved:
+		return uc.handleDispute(ctx, request)
+	}
+	return uc.handleOrderTransition(ctx, request)
 }
 
 // handlePaymentCaptureCompleted handles payment capture completed webhook
@@ -69,16 +512,13 @@ func (uc *WebhookUseCase) handlePaymentCaptureCompleted(ctx context.Context, req
 		return nil, fmt.Errorf("payment ID not found in webhook")
 	}
 
-	// Try to get order ID from custom_id or invoice_id
-	var orderID string
-	if customID, exists := request.Resource["custom_id"].(string); exists {
-		orderID = customID
-	} else if invoiceID, exists := request.Resource["invoice_id"].(string); exists {
-		orderID = invoiceID
+	// Resolve the MagicSpore order from custom_id or invoice_id
+	orderID, mapping, err := uc.resolveWebhookOrder(ctx, request)
+	if err != nil {
+		return nil, err
 	}
-
 	if orderID == "" {
-		return nil, fmt.Errorf("order ID not found in webhook")
+		return uc.ignoreUnmappedOrder(request), nil
 	}
 
 	uc.logger.Info("Processing payment capture completed", map[string]interface{}{
@@ -86,20 +526,44 @@ func (uc *WebhookUseCase) handlePaymentCaptureCompleted(ctx context.Context, req
 		"order_id":   orderID,
 	})
 
-	// Extract amount information
-	amount := entities.Money{}
-	if amountData, exists := request.Resource["amount"].(map[string]interface{}); exists {
-		if value, ok := amountData["value"].(string); ok {
-			// Convert string to float64
-			if parsedValue, err := parseFloat(value); err == nil {
-				amount.Amount = parsedValue
-			}
+	order, err := uc.wooCommerceRepo.GetMagicOrder(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	note := uc.orderNote(request)
+	transition, _ := entities.WebhookTransition(request.EventType)
+	amount, _ := extractAmount(request.Resource)
+
+	// A capture that does not match what the proxy order charged never pays the order
+	if transition.Allows(order.Status) {
+		mismatch, err := uc.verifyCaptureAmount(ctx, order, mapping, amount)
+		if err != nil {
+			return nil, err
 		}
-		if currency, ok := amountData["currency_code"].(string); ok {
-			amount.Currency = currency
+		if mismatch != "" {
+			return uc.holdForReview(ctx, request, orderID, order, paymentID, mismatch)
 		}
 	}
 
+	// A completed or refunded order is past payment; the capture is only noted
+	allowed := order.Status == transition.To
+	if transition.Allows(order.Status) {
+		allowed = uc.orderService.TransitionOrder(ctx, order, transition.To, request.EventType) == nil
+	}
+	if !allowed {
+		uc.logger.Warn("Payment capture completed for order past payment", map[string]interface{}{
+			"payment_id": paymentID,
+			"order_id":   orderID,
+			"status":     order.Status,
+		})
+		uc.addOrderNote(ctx, orderID, note+fmt.Sprintf(" Order status left at %s.", order.Status))
+		return &dto.WebhookResponse{
+			Status:  "processed",
+			Message: "Payment capture completed noted on order",
+		}, nil
+	}
+
 	// Create payment record
 	payment := uc.paymentService.CreatePaymentRecord(
 		ctx,
@@ -110,17 +574,16 @@ func (uc *WebhookUseCase) handlePaymentCaptureCompleted(ctx context.Context, req
 		entities.PaymentStatusCompleted,
 	)
 
-	// Store payment record (commented out as we don't have a payment repository yet)
-	// TODO: Implement payment repository for storing payment records
-	// if err := uc.paymentRepo.Create(ctx, payment); err != nil {
-	//     uc.logger.Error("Failed to store webhook payment record", err, map[string]interface{}{
-	//         "payment_id": payment.ID,
-	//         "order_id":   orderID,
-	//     })
-	//     // Continue even if storage fails
-	// }
-
-	// Update original order status to completed
+	// Store payment record
+	if err := uc.paymentRepo.Create(ctx, payment); err != nil {
+		uc.logger.Error("Failed to store webhook payment record", err, map[string]interface{}{
+			"payment_id": payment.ID,
+			"order_id":   orderID,
+		})
+		// Continue even if storage fails
+	}
+
+	// Update original order status to processing
 	if err := uc.wooCommerceRepo.UpdateMagicOrderPayment(ctx, orderID, payment); err != nil {
 		uc.logger.Error("Failed to update order from webhook", err, map[string]interface{}{
 			"order_id":   orderID,
@@ -128,11 +591,15 @@ func (uc *WebhookUseCase) handlePaymentCaptureCompleted(ctx context.Context, req
 		})
 		return nil, fmt.Errorf("failed to update order: %w", err)
 	}
+	recordStatusHistory(ctx, uc.wooCommerceRepo, uc.logger, orderID, order)
+
+	uc.updateMappingState(ctx, mapping, transition.Mapping)
+	uc.addOrderNote(ctx, orderID, note)
 
 	uc.logger.Info("Payment capture completed processed successfully", map[string]interface{}{
 		"payment_id": paymentID,
 		"order_id":   orderID,
-		"amount":     amount.Amount,
+		"amount":     amount.Decimal(),
 	})
 
 	return &dto.WebhookResponse{
@@ -141,77 +608,668 @@ func (uc *WebhookUseCase) handlePaymentCaptureCompleted(ctx context.Context, req
 	}, nil
 }
 
-// handlePaymentCaptureDenied handles payment capture denied webhook
-func (uc *WebhookUseCase) handlePaymentCaptureDenied(ctx context.Context, request *dto.WebhookRequest) (*dto.WebhookResponse, error) {
-	// Extract payment information
-	paymentID, _ := request.Resource["id"].(string)
-	orderID := uc.extractOrderIDFromResource(request.Resource)
+// verifyCaptureAmount checks a captured amount against what the order's proxy
+// order charged, the same way the return flow does. It returns why the capture
+// does not match, or an empty string when it does.
+func (uc *WebhookUseCase) verifyCaptureAmount(ctx context.Context, order *entities.Order, mapping *entities.OrderMapping, captured entities.Money) (string, error) {
+	expected := order.Total
+	if mapping != nil {
+		oitamOrder, err := uc.wooCommerceRepo.GetOITAMOrder(ctx, mapping.OITAMOrderID)
+		if err != nil {
+			return "", fmt.Errorf("failed to get proxy order: %w", err)
+		}
+		expected, err = uc.paymentService.ExpectedCapture(ctx, oitamOrder, order)
+		if err != nil {
+			return fmt.Sprintf("proxy order total mismatch: %v", err), nil
+		}
+	}
+
+	if err := uc.paymentService.VerifyCapturedAmount(ctx, captured, expected); err != nil {
+		return fmt.Sprintf("capture amount mismatch: %v", err), nil
+	}
+	return "", nil
+}
 
-	uc.logger.Info("Processing payment capture denied", map[string]interface{}{
+// holdForReview puts an order with a mismatched capture on hold and notes why,
+// leaving it unpaid until someone has reviewed the capture
+func (uc *WebhookUseCase) holdForReview(ctx context.Context, request *dto.WebhookRequest, orderID string, order *entities.Order, paymentID, reason string) (*dto.WebhookResponse, error) {
+	uc.logger.Error("Payment capture flagged for review", nil, map[string]interface{}{
 		"payment_id": paymentID,
 		"order_id":   orderID,
+		"reason":     reason,
 	})
+	uc.timeline.RecordError(ctx, orderID, "Payment capture flagged for review", errors.New(reason))
 
-	if orderID != "" {
-		// Update order status to failed
-		if err := uc.wooCommerceRepo.UpdateMagicOrderStatus(ctx, orderID, entities.StatusFailed); err != nil {
-			uc.logger.Error("Failed to update order status to failed", err, map[string]interface{}{
-				"order_id": orderID,
+	note := uc.orderNote(request) + fmt.Sprintf(" Capture %s needs review: %s. The order was not marked paid.", paymentID, reason)
+	return uc.applyOrderTransition(ctx, request, entities.CaptureReviewTransition, orderID, order, nil, note)
+}
+
+// handlePaymentCaptureRefunded adds a refund to the ledger of the payment it
+// was made on. The order only moves to refunded once the ledger adds up to the
+// captured amount; a partial refund is recorded as a refund line and note on
+// the MagicSpore order instead.
+func (uc *WebhookUseCase) handlePaymentCaptureRefunded(ctx context.Context, request *dto.WebhookRequest) (*dto.WebhookResponse, error) {
+	refundID, _ := request.Resource["id"].(string)
+	amount, ok := extractAmount(request.Resource)
+	if refundID == "" || !ok || !amount.IsPositive() {
+		return nil, fmt.Errorf("refund ID and amount not found in webhook")
+	}
+
+	orderID, mapping, err := uc.resolveWebhookOrder(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if orderID == "" {
+		return uc.ignoreUnmappedOrder(request), nil
+	}
+
+	order, err := uc.wooCommerceRepo.GetMagicOrder(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	note := uc.orderNote(request)
+	processed := &dto.WebhookResponse{
+		Status:  "processed",
+		Message: fmt.Sprintf("Event type %s processed", request.EventType),
+	}
+
+	payment, err := uc.findRefundedPayment(ctx, orderID, request.Resource)
+	if err != nil {
+		// Without a payment the refund cannot be totalled, so the order is left as it is
+		uc.logger.Warn("No payment found for refund webhook", map[string]interface{}{
+			"order_id":  orderID,
+			"refund_id": refundID,
+			"error":     err.Error(),
+		})
+		uc.addOrderNote(ctx, orderID, note+fmt.Sprintf(" No captured payment found to refund. Order status left at %s.", order.Status))
+		return processed, nil
+	}
+
+	captureID := payment.PaymentID
+	recorded := true
+	payment, err = uc.paymentRepo.RecordRefund(ctx, captureID, entities.PaymentRefund{
+		ID:     refundID,
+		Amount: amount,
+		Source: entities.RefundSourceWebhook,
+	})
+	var validationErr *entities.ValidationError
+	switch {
+	case errors.Is(err, entities.ErrDuplicateRefund):
+		// Issued through the refund API or delivered before, so the refund is
+		// already on the MagicSpore order
+		recorded = false
+		payment, err = uc.paymentRepo.GetByPaymentID(ctx, captureID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get refunded payment: %w", err)
+		}
+	case errors.As(err, &validationErr) || errors.Is(err, entities.ErrInvalidStatusTransition):
+		uc.logger.Warn("Refund webhook does not fit the payment", map[string]interface{}{
+			"order_id":  orderID,
+			"refund_id": refundID,
+			"error":     err.Error(),
+		})
+		uc.addOrderNote(ctx, orderID, note+fmt.Sprintf(" Refund not recorded: %v. Order status left at %s.", err, order.Status))
+		return processed, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to record refund: %w", err)
+	}
+
+	uc.logger.Info("Refund recorded from webhook", map[string]interface{}{
+		"order_id":   orderID,
+		"payment_id": payment.PaymentID,
+		"refund_id":  refundID,
+		"amount":     amount.Decimal(),
+		"refunded":   payment.RefundedAmount().Decimal(),
+		"recorded":   recorded,
+	})
+
+	if payment.IsFullyRefunded() {
+		transition, _ := entities.WebhookTransition(request.EventType)
+		return uc.applyOrderTransition(ctx, request, transition, orderID, order, mapping, note)
+	}
+
+	if recorded {
+		if err := uc.createPartialRefund(ctx, orderID, order, payment, amount); err != nil {
+			uc.logger.Error("Failed to create MagicSpore order refund from webhook", err, map[string]interface{}{
+				"order_id":  orderID,
+				"refund_id": refundID,
 			})
+			note += " The refund line could not be added to the order."
 		}
 	}
 
-	return &dto.WebhookResponse{
-		Status:  "processed",
-		Message: "Payment capture denied processed",
-	}, nil
+	note += fmt.Sprintf(" Partially refunded: %s of %s captured.", payment.RefundedAmount().String(), payment.Amount.String())
+	uc.addOrderNote(ctx, orderID, note)
+
+	return processed, nil
 }
 
-// handlePaymentCaptureRefunded handles payment capture refunded webhook
-func (uc *WebhookUseCase) handlePaymentCaptureRefunded(ctx context.Context, request *dto.WebhookRequest) (*dto.WebhookResponse, error) {
-	// Extract payment information
-	paymentID, _ := request.Resource["id"].(string)
-	orderID := uc.extractOrderIDFromResource(request.Resource)
+// createPartialRefund records a refund line on the MagicSpore order for a
+// partial refund given in the capture currency
+func (uc *WebhookUseCase) createPartialRefund(ctx context.Context, orderID string, order *entities.Order, payment *entities.Payment, captureAmount entities.Money) error {
+	orderAmount, err := uc.paymentService.OrderRefundAmount(ctx, payment, order, captureAmount)
+	if err != nil {
+		return err
+	}
 
-	uc.logger.Info("Processing payment capture refunded", map[string]interface{}{
-		"payment_id": paymentID,
-		"order_id":   orderID,
+	_, err = uc.wooCommerceRepo.CreateMagicOrderRefund(ctx, orderID, &entities.OrderRefund{Amount: orderAmount})
+	return err
+}
+
+// findRefundedPayment returns the payment a refund was made on: the capture
+// its up link points to, or else the order's most recent captured payment
+func (uc *WebhookUseCase) findRefundedPayment(ctx context.Context, orderID string, resource map[string]interface{}) (*entities.Payment, error) {
+	if captureID := upLinkID(resource); captureID != "" {
+		if payment, err := uc.paymentRepo.GetByPaymentID(ctx, captureID); err == nil {
+			return payment, nil
+		}
+	}
+
+	payments, err := uc.paymentRepo.GetByOrderID(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+
+	for i := len(payments) - 1; i >= 0; i-- {
+		captured := payments[i].IsCompleted() || payments[i].Status == entities.PaymentStatusRefunded
+		if captured && payments[i].PaymentID != "" {
+			return payments[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("captured payment for order %s not found", orderID)
+}
+
+// handleDispute records a dispute as last reported by PayPal and applies the
+// event to the MagicSpore order. The order is held while any of its disputes
+// is open and only released once the last one is resolved.
+func (uc *WebhookUseCase) handleDispute(ctx context.Context, request *dto.WebhookRequest) (*dto.WebhookResponse, error) {
+	orderID, mapping, err := uc.resolveWebhookOrder(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if orderID == "" {
+		return uc.ignoreUnmappedOrder(request), nil
+	}
+
+	report, err := parseDispute(request.Resource, orderID, mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := uc.wooCommerceRepo.GetMagicOrder(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	transition := uc.resolveTransition(request)
+	note := uc.orderNote(request)
+
+	dispute, current, err := uc.recordDispute(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+	if !current {
+		// An older report delivered late; it must not undo a later one
+		uc.logger.Info("Stale dispute webhook noted", map[string]interface{}{
+			"dispute_id": dispute.ID,
+			"order_id":   orderID,
+			"status":     dispute.Status,
+		})
+		transition = entities.NoteOnlyTransition
+	}
+
+	if deadline := dispute.Deadline(); current && dispute.IsOpen() && deadline != nil {
+		note += fmt.Sprintf(" Response due by %s.", deadline.UTC().Format("2006-01-02 15:04 MST"))
+	}
+
+	// Releasing the order waits for every other dispute on it to be resolved
+	if !dispute.IsOpen() && transition.To != "" && transition.To != entities.StatusRefunded {
+		open, err := uc.otherOpenDisputes(ctx, dispute)
+		if err != nil {
+			return nil, err
+		}
+		if open > 0 {
+			transition = entities.NoteOnlyTransition
+			note += fmt.Sprintf(" Order stays on hold while %d other dispute(s) are open.", open)
+		}
+	}
+
+	return uc.applyOrderTransition(ctx, request, transition, orderID, order, mapping, note)
+}
+
+// recordDispute merges a reported dispute into the stored one. It returns the
+// dispute as stored and whether the report was current.
+func (uc *WebhookUseCase) recordDispute(ctx context.Context, report *entities.Dispute) (*entities.Dispute, bool, error) {
+	dispute, err := uc.disputeRepo.GetByID(ctx, report.ID)
+	switch {
+	case errors.Is(err, interfaces.ErrNotFound):
+		dispute = report
+	case err != nil:
+		return nil, false, fmt.Errorf("failed to get dispute: %w", err)
+	case !dispute.Merge(report):
+		return dispute, false, nil
+	}
+
+	if err := uc.disputeRepo.Save(ctx, dispute); err != nil {
+		return nil, false, fmt.Errorf("failed to record dispute: %w", err)
+	}
+
+	uc.logger.Info("Dispute recorded", map[string]interface{}{
+		"dispute_id": dispute.ID,
+		"order_id":   dispute.OrderID,
+		"status":     dispute.Status,
+		"amount":     dispute.Amount.Decimal(),
 	})
 
-	if orderID != "" {
-		// Update order status to refunded
-		if err := uc.wooCommerceRepo.UpdateMagicOrderStatus(ctx, orderID, entities.StatusRefunded); err != nil {
-			uc.logger.Error("Failed to update order status to refunded", err, map[string]interface{}{
+	return dispute, true, nil
+}
+
+// otherOpenDisputes counts the open disputes on the order besides dispute
+func (uc *WebhookUseCase) otherOpenDisputes(ctx context.Context, dispute *entities.Dispute) (int, error) {
+	disputes, err := uc.disputeRepo.ListByOrderID(ctx, dispute.OrderID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list order disputes: %w", err)
+	}
+
+	open := 0
+	for _, other := range disputes {
+		if other.ID != dispute.ID && other.IsOpen() {
+			open++
+		}
+	}
+	return open, nil
+}
+
+// handleOrderTransition moves the MagicSpore order along the event's
+// transition and records the event as an order note. Orders in a status the
+// transition does not start from keep their status and only get the note.
+func (uc *WebhookUseCase) handleOrderTransition(ctx context.Context, request *dto.WebhookRequest) (*dto.WebhookResponse, error) {
+	transition := uc.resolveTransition(request)
+	orderID, mapping, err := uc.resolveWebhookOrder(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if orderID == "" {
+		return uc.ignoreUnmappedOrder(request), nil
+	}
+
+	order, err := uc.wooCommerceRepo.GetMagicOrder(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	return uc.applyOrderTransition(ctx, request, transition, orderID, order, mapping, uc.orderNote(request))
+}
+
+// applyOrderTransition moves the order along transition when both the
+// catalogue and the order state machine allow it, then records note on it
+func (uc *WebhookUseCase) applyOrderTransition(ctx context.Context, request *dto.WebhookRequest, transition entities.OrderTransition, orderID string, order *entities.Order, mapping *entities.OrderMapping, note string) (*dto.WebhookResponse, error) {
+	from := order.Status
+
+	switch {
+	case transition.Allows(order.Status) && uc.orderService.TransitionOrder(ctx, order, transition.To, request.EventType) == nil:
+		uc.logger.Info("Applying webhook order transition", map[string]interface{}{
+			"event_type": request.EventType,
+			"order_id":   orderID,
+			"from":       from,
+			"to":         transition.To,
+		})
+		if err := uc.wooCommerceRepo.UpdateMagicOrderStatus(ctx, orderID, transition.To); err != nil {
+			uc.logger.Error("Failed to update order status from webhook", err, map[string]interface{}{
 				"order_id": orderID,
+				"status":   transition.To,
 			})
+			return nil, fmt.Errorf("failed to update order status: %w", err)
 		}
+		recordStatusHistory(ctx, uc.wooCommerceRepo, uc.logger, orderID, order)
+		uc.updateMappingState(ctx, mapping, transition.Mapping)
+	case transition.To != "" && order.Status != transition.To:
+		// Either the catalogue or the order state machine rejected the move
+		uc.logger.Info("Webhook order transition not applicable", map[string]interface{}{
+			"event_type": request.EventType,
+			"order_id":   orderID,
+			"status":     order.Status,
+			"to":         transition.To,
+		})
+		note += fmt.Sprintf(" Order status left at %s.", order.Status)
 	}
 
+	uc.addOrderNote(ctx, orderID, note)
+
 	return &dto.WebhookResponse{
 		Status:  "processed",
-		Message: "Payment capture refunded processed",
+		Message: fmt.Sprintf("Event type %s processed", request.EventType),
 	}, nil
 }
 
+// resolveTransition refines the catalogue transition with the event payload
+func (uc *WebhookUseCase) resolveTransition(request *dto.WebhookRequest) entities.OrderTransition {
+	transition, _ := entities.WebhookTransition(request.EventType)
+
+	switch request.EventType {
+	case entities.EventCheckoutOrderCompleted:
+		// PayPal completes an order once it is captured, even when the
+		// capture is still pending or was declined
+		if !capturesCompleted(request.Resource) {
+			return entities.NoteOnlyTransition
+		}
+	case entities.EventCustomerDisputeUpdated:
+		// Resolutions are applied by the resolved event
+		if status, _ := request.Resource["status"].(string); entities.DisputeStatus(status) == entities.DisputeStatusResolved {
+			return entities.NoteOnlyTransition
+		}
+	case entities.EventCustomerDisputeResolved:
+		outcome, _ := nestedString(request.Resource, "dispute_outcome", "outcome_code")
+		return entities.DisputeResolvedTransition(outcome)
+	}
+
+	return transition
+}
+
+// orderNote describes a webhook event for the MagicSpore order history
+func (uc *WebhookUseCase) orderNote(request *dto.WebhookRequest) string {
+	resource := request.Resource
+	id, _ := resource["id"].(string)
+	amount := ""
+	if money, ok := extractAmount(resource); ok {
+		amount = " for " + money.String()
+	}
+
+	switch request.EventType {
+	case entities.EventCheckoutOrderApproved:
+		return fmt.Sprintf("PayPal order %s approved by the buyer.", id)
+	case entities.EventCheckoutOrderCompleted:
+		return fmt.Sprintf("PayPal order %s completed.", id)
+	case entities.EventPaymentAuthorizationCreated:
+		return fmt.Sprintf("PayPal authorization %s created%s.", id, amount)
+	case entities.EventPaymentAuthorizationVoided:
+		return fmt.Sprintf("PayPal authorization %s voided.", id)
+	case entities.EventPaymentCaptureCompleted:
+		return fmt.Sprintf("PayPal capture %s completed%s.", id, amount)
+	case entities.EventPaymentCapturePending:
+		reason, _ := nestedString(resource, "status_details", "reason")
+		return fmt.Sprintf("PayPal capture %s pending%s (reason: %s).", id, amount, valueOr(reason, "unknown"))
+	case entities.EventPaymentCaptureDenied:
+		return fmt.Sprintf("PayPal capture %s denied.", id)
+	case entities.EventPaymentCaptureRefunded:
+		return fmt.Sprintf("PayPal refund %s issued%s.", id, amount)
+	case entities.EventPaymentCaptureReversed:
+		return fmt.Sprintf("PayPal payment reversed%s (%s).", amount, id)
+	case entities.EventCustomerDisputeCreated:
+		disputeID, _ := resource["dispute_id"].(string)
+		reason, _ := resource["reason"].(string)
+		return fmt.Sprintf("PayPal dispute %s opened%s (reason: %s).", disputeID, amount, valueOr(reason, "unknown"))
+	case entities.EventCustomerDisputeUpdated:
+		disputeID, _ := resource["dispute_id"].(string)
+		status, _ := resource["status"].(string)
+		return fmt.Sprintf("PayPal dispute %s updated (status: %s).", disputeID, valueOr(status, "unknown"))
+	case entities.EventCustomerDisputeResolved:
+		disputeID, _ := resource["dispute_id"].(string)
+		outcome, _ := nestedString(resource, "dispute_outcome", "outcome_code")
+		return fmt.Sprintf("PayPal dispute %s resolved (outcome: %s).", disputeID, valueOr(outcome, "unknown"))
+	default:
+		return fmt.Sprintf("PayPal event %s received.", request.EventType)
+	}
+}
+
+// addOrderNote records a note on the MagicSpore order. Notes are informational,
+// so a failure is logged rather than failing the webhook.
+func (uc *WebhookUseCase) addOrderNote(ctx context.Context, orderID, note string) {
+	if err := uc.wooCommerceRepo.AddMagicOrderNote(ctx, orderID, note); err != nil {
+		uc.logger.Error("Failed to add order note from webhook", err, map[string]interface{}{
+			"order_id": orderID,
+		})
+	}
+}
+
+// resolveOrderID maps the order reference PayPal echoes back (the OITAM proxy
+// order) to the MagicSpore order. A reference without a stored mapping resolves
+// to no order: it is never taken for a MagicSpore order ID, which would update
+// whichever unrelated order shares the number.
+func (uc *WebhookUseCase) resolveOrderID(ctx context.Context, reference string) (string, *entities.OrderMapping, error) {
+	if reference == "" {
+		return "", nil, nil
+	}
+
+	mapping, err := uc.mappingRepo.GetByOITAMOrderID(ctx, reference)
+	if errors.Is(err, interfaces.ErrNotFound) {
+		uc.logger.Warn("No order mapping found for webhook reference", map[string]interface{}{
+			"reference": reference,
+		})
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to resolve order reference %s: %w", reference, err)
+	}
+
+	return mapping.MagicOrderID, mapping, nil
+}
+
+// ignoreUnmappedOrder ignores a webhook that references no known proxy order
+func (uc *WebhookUseCase) ignoreUnmappedOrder(request *dto.WebhookRequest) *dto.WebhookResponse {
+	uc.logger.Warn("Webhook does not reference a known proxy order", map[string]interface{}{
+		"event_type": request.EventType,
+		"webhook_id": request.ID,
+	})
+	return &dto.WebhookResponse{
+		Status:  "ignored",
+		Message: fmt.Sprintf("Event %s does not reference a known proxy order", request.ID),
+	}
+}
+
+// resolveWebhookOrder resolves the MagicSpore order a webhook refers to and
+// adds the webhook to that order's timeline
+func (uc *WebhookUseCase) resolveWebhookOrder(ctx context.Context, request *dto.WebhookRequest) (string, *entities.OrderMapping, error) {
+	orderID, mapping, err := uc.resolveOrderID(ctx, uc.extractOrderIDFromResource(request.Resource))
+	if err != nil {
+		return "", nil, err
+	}
+
+	details := map[string]string{
+		"event_type": request.EventType,
+		"webhook_id": request.ID,
+	}
+	if resourceID, ok := request.Resource["id"].(string); ok && resourceID != "" {
+		details["resource_id"] = resourceID
+	}
+	if status, ok := request.Resource["status"].(string); ok && status != "" {
+		details["resource_status"] = status
+	}
+	uc.timeline.Record(ctx, orderID, entities.OrderEventWebhookReceived, "PayPal webhook received", details)
+
+	return orderID, mapping, nil
+}
+
+// updateMappingState records the outcome of a webhook on the order mapping
+func (uc *WebhookUseCase) updateMappingState(ctx context.Context, mapping *entities.OrderMapping, state entities.OrderMappingState) {
+	if mapping == nil || state == "" {
+		return
+	}
+
+	if err := uc.mappingRepo.UpdateState(ctx, mapping.OITAMOrderID, state); err != nil {
+		uc.logger.Error("Failed to update order mapping state", err, map[string]interface{}{
+			"order_id":       mapping.MagicOrderID,
+			"oitam_order_id": mapping.OITAMOrderID,
+			"state":          state,
+		})
+	}
+}
+
 // extractOrderIDFromResource extracts order ID from webhook resource
 func (uc *WebhookUseCase) extractOrderIDFromResource(resource map[string]interface{}) string {
-	// Try custom_id first
-	if customID, exists := resource["custom_id"].(string); exists {
-		return customID
+	// Captures, refunds and authorizations carry custom_id or invoice_id
+	for _, key := range []string{"custom_id", "invoice_id"} {
+		if value, ok := resource[key].(string); ok && value != "" {
+			return value
+		}
 	}
-	
-	// Try invoice_id
-	if invoiceID, exists := resource["invoice_id"].(string); exists {
-		return invoiceID
+
+	// Checkout orders carry them on their purchase unit
+	if unit := firstElement(resource, "purchase_units"); unit != nil {
+		return uc.extractOrderIDFromResource(unit)
 	}
-	
+
+	// Disputes carry them on the disputed transaction
+	if transaction := firstElement(resource, "disputed_transactions"); transaction != nil {
+		for _, key := range []string{"custom", "invoice_number"} {
+			if value, ok := transaction[key].(string); ok && value != "" {
+				return value
+			}
+		}
+	}
+
 	return ""
 }
 
-// parseFloat safely parses a string to float64
-func parseFloat(s string) (float64, error) {
-	// Simple implementation - in production use strconv.ParseFloat
-	var f float64
-	_, err := fmt.Sscanf(s, "%f", &f)
-	return f, err
-}
\ No newline at end of file
+// parseDispute reads a dispute resource for the given MagicSpore order
+func parseDispute(resource map[string]interface{}, orderID string, mapping *entities.OrderMapping) (*entities.Dispute, error) {
+	id, _ := resource["dispute_id"].(string)
+	if id == "" {
+		return nil, fmt.Errorf("dispute ID not found in webhook")
+	}
+
+	now := time.Now()
+	dispute := &entities.Dispute{
+		ID:        id,
+		OrderID:   orderID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if mapping != nil {
+		dispute.OITAMOrderID = mapping.OITAMOrderID
+	}
+	dispute.Reason, _ = resource["reason"].(string)
+	dispute.Stage, _ = resource["dispute_life_cycle_stage"].(string)
+	dispute.Outcome, _ = nestedString(resource, "dispute_outcome", "outcome_code")
+	if status, _ := resource["status"].(string); status != "" {
+		dispute.Status = entities.DisputeStatus(status)
+	} else {
+		dispute.Status = entities.DisputeStatusOpen
+	}
+	if amount, ok := extractAmount(resource); ok {
+		dispute.Amount = amount
+	}
+	if transaction := firstElement(resource, "disputed_transactions"); transaction != nil {
+		dispute.TransactionID, _ = transaction["seller_transaction_id"].(string)
+	}
+
+	if createdAt := parseTime(resource["create_time"]); createdAt != nil {
+		dispute.CreatedAt = *createdAt
+	}
+	if updatedAt := parseTime(resource["update_time"]); updatedAt != nil {
+		dispute.UpdatedAt = *updatedAt
+	}
+	dispute.SellerResponseDueAt = parseTime(resource["seller_response_due_date"])
+	dispute.BuyerResponseDueAt = parseTime(resource["buyer_response_due_date"])
+	if !dispute.IsOpen() {
+		resolvedAt := dispute.UpdatedAt
+		dispute.ResolvedAt = &resolvedAt
+	}
+
+	return dispute, nil
+}
+
+// parseTime reads an RFC 3339 timestamp from a resource field
+func parseTime(value interface{}) *time.Time {
+	text, _ := value.(string)
+	parsed, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
+
+// extractAmount reads the amount of a capture, refund, authorization, checkout
+// order or dispute resource
+func extractAmount(resource map[string]interface{}) (entities.Money, bool) {
+	amountData, ok := resource["amount"].(map[string]interface{})
+	if !ok {
+		amountData, ok = resource["dispute_amount"].(map[string]interface{})
+	}
+	if !ok {
+		if unit := firstElement(resource, "purchase_units"); unit != nil {
+			amountData, ok = unit["amount"].(map[string]interface{})
+		}
+	}
+	if !ok {
+		return entities.Money{}, false
+	}
+
+	currency, _ := amountData["currency_code"].(string)
+	amount := entities.NewMoneyFromMinorUnits(0, currency)
+	if value, ok := amountData["value"].(string); ok {
+		if parsed, err := entities.ParseMoney(value, currency); err == nil {
+			amount = parsed
+		}
+	}
+
+	return amount, true
+}
+
+// capturesCompleted checks if a checkout order's captures all completed
+func capturesCompleted(resource map[string]interface{}) bool {
+	unit := firstElement(resource, "purchase_units")
+	if unit == nil {
+		return false
+	}
+	payments, _ := unit["payments"].(map[string]interface{})
+	captures, _ := payments["captures"].([]interface{})
+	if len(captures) == 0 {
+		return false
+	}
+
+	for _, item := range captures {
+		capture, _ := item.(map[string]interface{})
+		if status, _ := capture["status"].(string); status != "COMPLETED" {
+			return false
+		}
+	}
+	return true
+}
+
+// firstElement returns the first object of an array field
+func firstElement(resource map[string]interface{}, key string) map[string]interface{} {
+	items, ok := resource[key].([]interface{})
+	if !ok || len(items) == 0 {
+		return nil
+	}
+	element, _ := items[0].(map[string]interface{})
+	return element
+}
+
+// upLinkID returns the ID at the end of a resource's "up" link, which for a
+// refund is the capture it was made on
+func upLinkID(resource map[string]interface{}) string {
+	links, _ := resource["links"].([]interface{})
+	for _, item := range links {
+		link, _ := item.(map[string]interface{})
+		if rel, _ := link["rel"].(string); rel != "up" {
+			continue
+		}
+		href, _ := link["href"].(string)
+		if index := strings.LastIndex(href, "/"); index >= 0 && index < len(href)-1 {
+			return href[index+1:]
+		}
+	}
+	return ""
+}
+
+// nestedString reads a string field of a nested object
+func nestedString(resource map[string]interface{}, object, key string) (string, bool) {
+	nested, ok := resource[object].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	value, ok := nested[key].(string)
+	return value, ok
+}
+
+// valueOr returns value, or fallback when value is empty
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,350 @@
+package entities
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Meta data keys every proxy order carries
+const (
+	MetaOriginalOrderNumber = "_original_order_number"
+	MetaProxyOrder          = "_proxy_order"
+)
+
+// AnonymizationAction is what happens to a field of a proxy order
+type AnonymizationAction string
+
+const (
+	AnonymizeKeep    AnonymizationAction = "keep"    // copy the original value
+	AnonymizeDrop    AnonymizationAction = "drop"    // leave the field empty
+	AnonymizeHash    AnonymizationAction = "hash"    // replace with a keyed hash of the original value
+	AnonymizeReplace AnonymizationAction = "replace" // replace with the rule's template
+)
+
+// Anonymized fields. Address fields exist for both billing and shipping.
+const (
+	FieldCustomerNote       = "customer_note"
+	FieldMetaData           = "meta_data"
+	FieldLineItemName       = "line_item.name"
+	FieldLineItemSKU        = "line_item.sku"
+	FieldLineItemMetaData   = "line_item.meta_data"
+	FieldShippingLineMethod = "shipping_line.method_title"
+	FieldFeeLineName        = "fee_line.name"
+)
+
+// addressFields are the anonymized fields of an address, prefixed with
+// "billing." or "shipping."
+var addressFields = []string{
+	"first_name", "last_name", "company", "address_1", "address_2",
+	"city", "state", "postcode", "country", "email", "phone",
+}
+
+// keepOrDropFields hold structured values that cannot be hashed or replaced
+var keepOrDropFields = map[string]bool{
+	FieldMetaData:         true,
+	FieldLineItemMetaData: true,
+}
+
+// Template placeholders for replace rules
+const (
+	placeholderIndex      = "{n}"          // 1-based position of the line
+	placeholderDescriptor = "{descriptor}" // the line item's category descriptor
+	placeholderHash       = "{hash}"       // keyed hash of the original value
+)
+
+// hashLength is the number of hex characters kept from a field hash
+const hashLength = 16
+
+// AnonymizationRule says how one field is anonymized
+type AnonymizationRule struct {
+	Action AnonymizationAction `json:"action"`
+	Value  string              `json:"value,omitempty"` // template for replace
+}
+
+// ItemDescriptor names line items generically by category, e.g. "Supplement"
+// for every product in a category
+type ItemDescriptor struct {
+	Descriptor  string   `json:"descriptor"`
+	ProductIDs  []int    `json:"product_ids,omitempty"`
+	SKUPrefixes []string `json:"sku_prefixes,omitempty"`
+}
+
+// AnonymizationPolicy decides what a proxy order on OITAM reveals about the
+// original order. Fields without a rule are dropped.
+type AnonymizationPolicy struct {
+	Fields            map[string]AnonymizationRule `json:"fields"`
+	DefaultDescriptor string                       `json:"default_descriptor"`
+	Descriptors       []ItemDescriptor             `json:"descriptors"`
+	HashSalt          string                       `json:"-"`
+}
+
+// DefaultAnonymizationPolicy returns the built-in policy: placeholder
+// contact details, the billing country PayPal requires and generic
+// "Item N" line names without SKUs or product meta data
+func DefaultAnonymizationPolicy() *AnonymizationPolicy {
+	fields := map[string]AnonymizationRule{
+		FieldCustomerNote:       {Action: AnonymizeDrop},
+		FieldMetaData:           {Action: AnonymizeDrop},
+		FieldLineItemName:       {Action: AnonymizeReplace, Value: placeholderDescriptor + " " + placeholderIndex},
+		FieldLineItemSKU:        {Action: AnonymizeDrop},
+		FieldLineItemMetaData:   {Action: AnonymizeDrop},
+		FieldShippingLineMethod: {Action: AnonymizeKeep},
+		FieldFeeLineName:        {Action: AnonymizeKeep},
+	}
+	for _, prefix := range []string{"billing.", "shipping."} {
+		fields[prefix+"first_name"] = AnonymizationRule{Action: AnonymizeReplace, Value: "Customer"}
+		fields[prefix+"last_name"] = AnonymizationRule{Action: AnonymizeReplace, Value: "Order"}
+		fields[prefix+"address_1"] = AnonymizationRule{Action: AnonymizeReplace, Value: "Private"}
+		fields[prefix+"city"] = AnonymizationRule{Action: AnonymizeReplace, Value: "Private"}
+		fields[prefix+"postcode"] = AnonymizationRule{Action: AnonymizeReplace, Value: "00000"}
+		fields[prefix+"country"] = AnonymizationRule{Action: AnonymizeKeep} // PayPal requires the country
+	}
+	fields["billing.email"] = AnonymizationRule{Action: AnonymizeReplace, Value: "noreply@oitam.com"}
+
+	return &AnonymizationPolicy{
+		Fields:            fields,
+		DefaultDescriptor: "Item",
+	}
+}
+
+// ParseAnonymizationPolicy reads a JSON policy. Its rules and descriptors
+// override the default policy; unlisted fields keep their default rule.
+func ParseAnonymizationPolicy(data []byte) (*AnonymizationPolicy, error) {
+	var overrides AnonymizationPolicy
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("invalid anonymization policy: %w", err)
+	}
+
+	policy := DefaultAnonymizationPolicy()
+	for field, rule := range overrides.Fields {
+		policy.Fields[field] = rule
+	}
+	if overrides.DefaultDescriptor != "" {
+		policy.DefaultDescriptor = overrides.DefaultDescriptor
+	}
+	policy.Descriptors = overrides.Descriptors
+
+	return policy, nil
+}
+
+// Validate checks the policy's fields, actions and templates
+func (p *AnonymizationPolicy) Validate() error {
+	known := make(map[string]bool)
+	for _, field := range anonymizableFields() {
+		known[field] = true
+	}
+
+	fields := make([]string, 0, len(p.Fields))
+	for field := range p.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	usesHash := false
+	for _, field := range fields {
+		rule := p.Fields[field]
+		if !known[field] {
+			return fmt.Errorf("unknown anonymization field %q", field)
+		}
+
+		switch rule.Action {
+		case AnonymizeKeep, AnonymizeDrop:
+		case AnonymizeHash:
+			usesHash = true
+		case AnonymizeReplace:
+			if rule.Value == "" {
+				return fmt.Errorf("anonymization field %q needs a replacement value", field)
+			}
+			usesHash = usesHash || strings.Contains(rule.Value, placeholderHash)
+		default:
+			return fmt.Errorf("anonymization field %q has unknown action %q", field, rule.Action)
+		}
+
+		if keepOrDropFields[field] && rule.Action != AnonymizeKeep && rule.Action != AnonymizeDrop {
+			return fmt.Errorf("anonymization field %q can only be kept or dropped", field)
+		}
+	}
+
+	if usesHash && p.HashSalt == "" {
+		return fmt.Errorf("hashed anonymization fields need a hash salt")
+	}
+
+	for _, descriptor := range p.Descriptors {
+		if descriptor.Descriptor == "" {
+			return fmt.Errorf("item descriptors need a name")
+		}
+	}
+
+	return nil
+}
+
+// Anonymize creates the proxy order for an order. Amounts, quantities and
+// currency are copied; everything else follows the policy's rules.
+func (p *AnonymizationPolicy) Anonymize(o *Order) *Order {
+	anonymousOrder := &Order{
+		Number:             o.Number, // Keep same order number
+		Status:             StatusPending,
+		Currency:           o.Currency,
+		Total:              o.Total,
+		PaymentMethod:      "paypal",
+		PaymentMethodTitle: "PayPal",
+		DateCreated:        time.Now(),
+		CustomerNote:       p.apply(FieldCustomerNote, o.CustomerNote, 0, ""),
+		Billing:            p.anonymizeAddress("billing.", o.Billing),
+		Shipping:           p.anonymizeAddress("shipping.", o.Shipping),
+		LineItems:          make([]LineItem, 0, len(o.LineItems)),
+		ShippingLines:      make([]ShippingLine, 0, len(o.ShippingLines)),
+		FeeLines:           make([]FeeLine, 0, len(o.FeeLines)),
+		TaxLines:           append([]TaxLine{}, o.TaxLines...),
+		CouponLines:        []CouponLine{}, // Remove coupon info
+		MetaData: []MetaData{
+			{Key: MetaOriginalOrderNumber, Value: o.Number},
+			{Key: MetaProxyOrder, Value: "true"},
+		},
+	}
+
+	for i, item := range o.LineItems {
+		anonymousOrder.LineItems = append(anonymousOrder.LineItems, LineItem{
+			Name:     p.apply(FieldLineItemName, item.Name, i+1, p.descriptorFor(item)),
+			Quantity: item.Quantity,
+			SKU:      p.apply(FieldLineItemSKU, item.SKU, i+1, ""),
+			Price:    item.Price,
+			Subtotal: item.Subtotal,
+			Total:    item.Total,
+			MetaData: p.applyMetaData(FieldLineItemMetaData, item.MetaData),
+		})
+	}
+
+	for i, line := range o.ShippingLines {
+		anonymousOrder.ShippingLines = append(anonymousOrder.ShippingLines, ShippingLine{
+			MethodID:    line.MethodID,
+			MethodTitle: p.apply(FieldShippingLineMethod, line.MethodTitle, i+1, ""),
+			Total:       line.Total,
+		})
+	}
+
+	for i, line := range o.FeeLines {
+		anonymousOrder.FeeLines = append(anonymousOrder.FeeLines, FeeLine{
+			Name:  p.apply(FieldFeeLineName, line.Name, i+1, ""),
+			Total: line.Total,
+		})
+	}
+
+	anonymousOrder.MetaData = append(anonymousOrder.MetaData, p.applyMetaData(FieldMetaData, o.MetaData)...)
+
+	return anonymousOrder
+}
+
+// anonymizeAddress applies the address rules under a field prefix
+func (p *AnonymizationPolicy) anonymizeAddress(prefix string, address Address) Address {
+	return Address{
+		FirstName: p.apply(prefix+"first_name", address.FirstName, 0, ""),
+		LastName:  p.apply(prefix+"last_name", address.LastName, 0, ""),
+		Company:   p.apply(prefix+"company", address.Company, 0, ""),
+		Address1:  p.apply(prefix+"address_1", address.Address1, 0, ""),
+		Address2:  p.apply(prefix+"address_2", address.Address2, 0, ""),
+		City:      p.apply(prefix+"city", address.City, 0, ""),
+		State:     p.apply(prefix+"state", address.State, 0, ""),
+		Postcode:  p.apply(prefix+"postcode", address.Postcode, 0, ""),
+		Country:   p.apply(prefix+"country", address.Country, 0, ""),
+		Email:     p.apply(prefix+"email", address.Email, 0, ""),
+		Phone:     p.apply(prefix+"phone", address.Phone, 0, ""),
+	}
+}
+
+// apply anonymizes one text field. index is the 1-based line position for
+// line fields.
+func (p *AnonymizationPolicy) apply(field, value string, index int, descriptor string) string {
+	rule, exists := p.Fields[field]
+	if !exists {
+		return ""
+	}
+
+	switch rule.Action {
+	case AnonymizeKeep:
+		return value
+	case AnonymizeHash:
+		if value == "" {
+			return ""
+		}
+		return p.hash(value)
+	case AnonymizeReplace:
+		if descriptor == "" {
+			descriptor = p.DefaultDescriptor
+		}
+		replacer := strings.NewReplacer(
+			placeholderIndex, strconv.Itoa(index),
+			placeholderDescriptor, descriptor,
+			placeholderHash, p.hash(value),
+		)
+		return replacer.Replace(rule.Value)
+	default:
+		return ""
+	}
+}
+
+// applyMetaData keeps or drops a meta data list
+func (p *AnonymizationPolicy) applyMetaData(field string, metaData []MetaData) []MetaData {
+	if rule, exists := p.Fields[field]; exists && rule.Action == AnonymizeKeep {
+		kept := make([]MetaData, 0, len(metaData))
+		for _, meta := range metaData {
+			// The status history describes the original order, not the proxy
+			if meta.Key == MetaStatusHistory {
+				continue
+			}
+			kept = append(kept, MetaData{Key: meta.Key, Value: meta.Value})
+		}
+		return kept
+	}
+	return []MetaData{}
+}
+
+// descriptorFor returns the category descriptor of a line item
+func (p *AnonymizationPolicy) descriptorFor(item LineItem) string {
+	for _, descriptor := range p.Descriptors {
+		for _, productID := range descriptor.ProductIDs {
+			if productID != 0 && (productID == item.ProductID || productID == item.VariationID) {
+				return descriptor.Descriptor
+			}
+		}
+		for _, prefix := range descriptor.SKUPrefixes {
+			if prefix != "" && strings.HasPrefix(item.SKU, prefix) {
+				return descriptor.Descriptor
+			}
+		}
+	}
+	return p.DefaultDescriptor
+}
+
+// hash returns a keyed hash of a value, stable for the same salt
+func (p *AnonymizationPolicy) hash(value string) string {
+	mac := hmac.New(sha256.New, []byte(p.HashSalt))
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// anonymizableFields lists every field a policy can have a rule for
+func anonymizableFields() []string {
+	fields := []string{
+		FieldCustomerNote,
+		FieldMetaData,
+		FieldLineItemName,
+		FieldLineItemSKU,
+		FieldLineItemMetaData,
+		FieldShippingLineMethod,
+		FieldFeeLineName,
+	}
+	for _, prefix := range []string{"billing.", "shipping."} {
+		for _, field := range addressFields {
+			fields = append(fields, prefix+field)
+		}
+	}
+	return fields
+}
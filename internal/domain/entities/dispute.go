@@ -0,0 +1,67 @@
+package entities
+
+import "time"
+
+// DisputeStatus is the status PayPal reports for a dispute
+type DisputeStatus string
+
+const (
+	DisputeStatusOpen                     DisputeStatus = "OPEN"
+	DisputeStatusWaitingForBuyerResponse  DisputeStatus = "WAITING_FOR_BUYER_RESPONSE"
+	DisputeStatusWaitingForSellerResponse DisputeStatus = "WAITING_FOR_SELLER_RESPONSE"
+	DisputeStatusUnderReview              DisputeStatus = "UNDER_REVIEW"
+	DisputeStatusResolved                 DisputeStatus = "RESOLVED"
+	DisputeStatusOther                    DisputeStatus = "OTHER"
+)
+
+// Dispute is a PayPal dispute or chargeback raised against a MagicSpore
+// order's payment, as last reported by the dispute webhooks
+type Dispute struct {
+	ID                  string        `json:"id"` // PayPal dispute ID
+	OrderID             string        `json:"order_id"`
+	OITAMOrderID        string        `json:"oitam_order_id,omitempty"`
+	TransactionID       string        `json:"transaction_id,omitempty"` // disputed PayPal capture
+	Reason              string        `json:"reason,omitempty"`
+	Status              DisputeStatus `json:"status"`
+	Stage               string        `json:"stage,omitempty"` // INQUIRY, CHARGEBACK, PRE_ARBITRATION or ARBITRATION
+	Outcome             string        `json:"outcome,omitempty"`
+	Amount              Money         `json:"amount"`
+	SellerResponseDueAt *time.Time    `json:"seller_response_due_at,omitempty"`
+	BuyerResponseDueAt  *time.Time    `json:"buyer_response_due_at,omitempty"`
+	CreatedAt           time.Time     `json:"created_at"`
+	UpdatedAt           time.Time     `json:"updated_at"`
+	ResolvedAt          *time.Time    `json:"resolved_at,omitempty"`
+}
+
+// IsOpen checks if the dispute is still awaiting a resolution
+func (d *Dispute) IsOpen() bool {
+	return d.Status != DisputeStatusResolved
+}
+
+// Deadline returns the next response due on the dispute, the seller's taking
+// precedence as it is the one the merchant has to meet
+func (d *Dispute) Deadline() *time.Time {
+	if d.SellerResponseDueAt != nil {
+		return d.SellerResponseDueAt
+	}
+	return d.BuyerResponseDueAt
+}
+
+// Merge applies a newer report of the same dispute. Reports are applied in the
+// order PayPal updated the dispute, so a late delivery never reopens a
+// resolved dispute; it returns false when the report is stale.
+func (d *Dispute) Merge(report *Dispute) bool {
+	if !d.IsOpen() && report.IsOpen() {
+		return false
+	}
+	if report.UpdatedAt.Before(d.UpdatedAt) {
+		return false
+	}
+
+	createdAt := d.CreatedAt
+	*d = *report
+	if !createdAt.IsZero() {
+		d.CreatedAt = createdAt
+	}
+	return true
+}
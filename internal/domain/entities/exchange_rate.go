@@ -0,0 +1,124 @@
+package entities
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Meta data keys recording a currency conversion on both orders
+const (
+	MetaConversionRate              = "_currency_conversion_rate"
+	MetaConversionRateSource        = "_currency_conversion_rate_source"
+	MetaConversionRateDate          = "_currency_conversion_rate_date"
+	MetaConversionOriginalAmount    = "_currency_conversion_original_amount"
+	MetaConversionOriginalCurrency  = "_currency_conversion_original_currency"
+	MetaConversionConvertedAmount   = "_currency_conversion_converted_amount"
+	MetaConversionConvertedCurrency = "_currency_conversion_converted_currency"
+)
+
+// ExchangeRate converts amounts in one currency into another
+type ExchangeRate struct {
+	From   string
+	To     string
+	Rate   string // units of To per unit of From, as quoted by the source
+	Source string
+	AsOf   time.Time
+}
+
+// NewExchangeRate creates an exchange rate from a decimal quote
+func NewExchangeRate(from, to, rate, source string, asOf time.Time) (*ExchangeRate, error) {
+	value, err := parseDecimal(rate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exchange rate %s/%s: %w", from, to, err)
+	}
+	if value.Sign() <= 0 {
+		return nil, fmt.Errorf("exchange rate %s/%s must be positive", from, to)
+	}
+
+	return &ExchangeRate{
+		From:   strings.ToUpper(from),
+		To:     strings.ToUpper(to),
+		Rate:   strings.TrimSpace(rate),
+		Source: source,
+		AsOf:   asOf,
+	}, nil
+}
+
+// Convert converts an amount in the rate's From currency into its To
+// currency, rounding to the To currency's minor unit
+func (r *ExchangeRate) Convert(amount Money, mode RoundingMode) (Money, error) {
+	if !strings.EqualFold(amount.Currency, r.From) {
+		return Money{}, fmt.Errorf("cannot convert %s with a %s/%s rate", amount.Currency, r.From, r.To)
+	}
+
+	rate, err := parseDecimal(r.Rate)
+	if err != nil {
+		return Money{}, err
+	}
+
+	// Rescale between the minor units of both currencies
+	factor := new(big.Rat).Mul(rate, new(big.Rat).SetFrac(exponentScale(r.To), exponentScale(r.From)))
+	converted := NewMoneyFromMinorUnits(amount.MinorUnits, r.To).MultiplyRat(factor, mode)
+	return converted, nil
+}
+
+// CurrencyConversion records how an order total was converted into the
+// settlement currency
+type CurrencyConversion struct {
+	Original  Money
+	Converted Money
+	Rate      ExchangeRate
+}
+
+// MetaData returns the conversion as order meta data
+func (c *CurrencyConversion) MetaData() []MetaData {
+	return []MetaData{
+		{Key: MetaConversionRate, Value: c.Rate.Rate},
+		{Key: MetaConversionRateSource, Value: c.Rate.Source},
+		{Key: MetaConversionRateDate, Value: c.Rate.AsOf.UTC().Format(time.RFC3339)},
+		{Key: MetaConversionOriginalAmount, Value: c.Original.Decimal()},
+		{Key: MetaConversionOriginalCurrency, Value: c.Original.Currency},
+		{Key: MetaConversionConvertedAmount, Value: c.Converted.Decimal()},
+		{Key: MetaConversionConvertedCurrency, Value: c.Converted.Currency},
+	}
+}
+
+// IsCurrencyConversionMetaKey checks if a meta data key records a conversion
+func IsCurrencyConversionMetaKey(key string) bool {
+	return strings.HasPrefix(key, "_currency_conversion_")
+}
+
+// CurrencyConversionFromMetaData reads a conversion recorded on an order. It
+// returns false when the order was not converted.
+func CurrencyConversionFromMetaData(metaData []MetaData) (*CurrencyConversion, bool) {
+	values := make(map[string]string)
+	for _, meta := range metaData {
+		if IsCurrencyConversionMetaKey(meta.Key) {
+			values[meta.Key] = fmt.Sprint(meta.Value)
+		}
+	}
+
+	original, err := ParseMoney(values[MetaConversionOriginalAmount], values[MetaConversionOriginalCurrency])
+	if err != nil {
+		return nil, false
+	}
+	converted, err := ParseMoney(values[MetaConversionConvertedAmount], values[MetaConversionConvertedCurrency])
+	if err != nil {
+		return nil, false
+	}
+	asOf, _ := time.Parse(time.RFC3339, values[MetaConversionRateDate])
+
+	return &CurrencyConversion{
+		Original:  original,
+		Converted: converted,
+		Rate: ExchangeRate{
+			From:   original.Currency,
+			To:     converted.Currency,
+			Rate:   values[MetaConversionRate],
+			Source: values[MetaConversionRateSource],
+			AsOf:   asOf,
+		},
+	}, true
+}
@@ -1,69 +1,356 @@
 package entities
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 )
 
-// Money represents a monetary value with currency
+// Money represents a monetary value with currency. The amount is held in the
+// currency's minor unit (cents for EUR, yen for JPY, fils for KWD) so that
+// arithmetic and comparisons are exact.
 type Money struct {
-	Amount   float64 `json:"amount"`
-	Currency string  `json:"currency"`
+	MinorUnits int64
+	Currency   string
 }
 
-// NewMoney creates a new Money instance
+// RoundingMode selects how amounts that fall between two minor units are rounded
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds halves away from zero
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds halves to the nearest even minor unit
+	RoundHalfEven
+	// RoundDown rounds toward zero
+	RoundDown
+	// RoundUp rounds away from zero
+	RoundUp
+)
+
+// NewMoney creates a Money instance from a major-unit amount, rounding half up
+// to the currency's minor unit. Use ParseMoney for amounts received as text.
 func NewMoney(amount float64, currency string) Money {
+	money, err := ParseMoneyRounded(strconv.FormatFloat(amount, 'f', -1, 64), currency, RoundHalfUp)
+	if err != nil {
+		return Money{Currency: strings.ToUpper(currency)}
+	}
+	return money
+}
+
+// NewMoneyFromMinorUnits creates a Money instance from an amount in minor units
+func NewMoneyFromMinorUnits(minorUnits int64, currency string) Money {
 	return Money{
-		Amount:   amount,
-		Currency: strings.ToUpper(currency),
+		MinorUnits: minorUnits,
+		Currency:   strings.ToUpper(currency),
+	}
+}
+
+// ParseMoney parses a decimal amount such as "49.99" without loss. Amounts with
+// more significant decimal places than the currency's minor unit are rejected.
+func ParseMoney(amount, currency string) (Money, error) {
+	value, err := parseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+
+	scaled := value.Mul(value, new(big.Rat).SetInt(exponentScale(currency)))
+	if !scaled.IsInt() {
+		return Money{}, fmt.Errorf("amount %s has more than %d decimal places for %s", amount, CurrencyExponent(currency), strings.ToUpper(currency))
 	}
+	if !scaled.Num().IsInt64() {
+		return Money{}, fmt.Errorf("amount %s is out of range", amount)
+	}
+
+	return NewMoneyFromMinorUnits(scaled.Num().Int64(), currency), nil
+}
+
+// ParseMoneyRounded parses a decimal amount, rounding any extra decimal places
+// to the currency's minor unit
+func ParseMoneyRounded(amount, currency string, mode RoundingMode) (Money, error) {
+	value, err := parseDecimal(amount)
+	if err != nil {
+		return Money{}, err
+	}
+
+	minorUnits, err := roundRat(value.Mul(value, new(big.Rat).SetInt(exponentScale(currency))), mode)
+	if err != nil {
+		return Money{}, fmt.Errorf("amount %s is out of range", amount)
+	}
+
+	return NewMoneyFromMinorUnits(minorUnits, currency), nil
+}
+
+// Exponent returns the number of minor unit digits of the money's currency
+func (m Money) Exponent() int {
+	return CurrencyExponent(m.Currency)
+}
+
+// Decimal formats the amount in major units with the currency's minor unit
+// digits, e.g. "49.99" for PLN, "5000" for JPY and "1.250" for KWD
+func (m Money) Decimal() string {
+	exponent := m.Exponent()
+	digits := strconv.FormatInt(m.MinorUnits, 10)
+
+	sign := ""
+	if m.MinorUnits < 0 {
+		sign = "-"
+		digits = digits[1:]
+	}
+	if exponent == 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+// Float64 returns the amount in major units as a float, for display and for
+// APIs that expect a JSON number. Do not use the result for arithmetic.
+func (m Money) Float64() float64 {
+	value, _ := strconv.ParseFloat(m.Decimal(), 64)
+	return value
 }
 
 // String returns string representation of money
 func (m Money) String() string {
-	return fmt.Sprintf("%.2f %s", m.Amount, m.Currency)
+	return fmt.Sprintf("%s %s", m.Decimal(), m.Currency)
 }
 
 // IsZero checks if the money amount is zero
 func (m Money) IsZero() bool {
-	return m.Amount == 0
+	return m.MinorUnits == 0
 }
 
 // IsPositive checks if the money amount is positive
 func (m Money) IsPositive() bool {
-	return m.Amount > 0
+	return m.MinorUnits > 0
+}
+
+// IsNegative checks if the money amount is negative
+func (m Money) IsNegative() bool {
+	return m.MinorUnits < 0
+}
+
+// SameCurrency checks if two Money values are in the same currency
+func (m Money) SameCurrency(other Money) bool {
+	return strings.EqualFold(m.Currency, other.Currency)
+}
+
+// Equals checks if two Money values have the same currency and amount
+func (m Money) Equals(other Money) bool {
+	return m.SameCurrency(other) && m.MinorUnits == other.MinorUnits
+}
+
+// Compare returns -1, 0 or 1 as m is less than, equal to or greater than
+// other. Values in different currencies cannot be compared.
+func (m Money) Compare(other Money) (int, error) {
+	if !m.SameCurrency(other) {
+		return 0, fmt.Errorf("cannot compare different currencies: %s and %s", m.Currency, other.Currency)
+	}
+	switch {
+	case m.MinorUnits < other.MinorUnits:
+		return -1, nil
+	case m.MinorUnits > other.MinorUnits:
+		return 1, nil
+	default:
+		return 0, nil
+	}
 }
 
 // Add adds two Money values (must have same currency)
 func (m Money) Add(other Money) (Money, error) {
-	if m.Currency != other.Currency {
+	if !m.SameCurrency(other) {
 		return Money{}, fmt.Errorf("cannot add different currencies: %s and %s", m.Currency, other.Currency)
 	}
-	return Money{
-		Amount:   m.Amount + other.Amount,
-		Currency: m.Currency,
-	}, nil
+	return NewMoneyFromMinorUnits(m.MinorUnits+other.MinorUnits, m.Currency), nil
 }
 
-// Multiply multiplies money by a factor
-func (m Money) Multiply(factor float64) Money {
-	return Money{
-		Amount:   m.Amount * factor,
-		Currency: m.Currency,
+// Sub subtracts other from m (must have same currency)
+func (m Money) Sub(other Money) (Money, error) {
+	if !m.SameCurrency(other) {
+		return Money{}, fmt.Errorf("cannot subtract different currencies: %s and %s", m.Currency, other.Currency)
 	}
+	return NewMoneyFromMinorUnits(m.MinorUnits-other.MinorUnits, m.Currency), nil
+}
+
+// Multiply multiplies money by a factor, rounding the result to the minor unit.
+// The factor is taken at its shortest decimal representation, so 1.15 is
+// exactly 1.15 rather than the nearest binary float.
+func (m Money) Multiply(factor float64, mode RoundingMode) Money {
+	rat, ok := new(big.Rat).SetString(strconv.FormatFloat(factor, 'f', -1, 64))
+	if !ok {
+		return NewMoneyFromMinorUnits(0, m.Currency)
+	}
+	return m.MultiplyRat(rat, mode)
+}
+
+// MultiplyRat multiplies money by an exact rational factor, rounding the
+// result to the minor unit
+func (m Money) MultiplyRat(factor *big.Rat, mode RoundingMode) Money {
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(m.MinorUnits), factor)
+	minorUnits, _ := roundRat(product, mode)
+	return NewMoneyFromMinorUnits(minorUnits, m.Currency)
+}
+
+// Allocate splits money into parts proportional to the given ratios without
+// losing a minor unit. Remainders go one minor unit at a time to the first
+// parts with a non-zero ratio.
+func (m Money) Allocate(ratios ...int) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, errors.New("at least one ratio is required")
+	}
+
+	var total int64
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, fmt.Errorf("invalid negative ratio %d", ratio)
+		}
+		total += int64(ratio)
+	}
+	if total == 0 {
+		return nil, errors.New("ratios must not all be zero")
+	}
+
+	amount := big.NewInt(m.MinorUnits)
+	parts := make([]Money, len(ratios))
+	remainder := m.MinorUnits
+	for i, ratio := range ratios {
+		share := new(big.Int).Mul(amount, big.NewInt(int64(ratio)))
+		share.Quo(share, big.NewInt(total))
+		parts[i] = NewMoneyFromMinorUnits(share.Int64(), m.Currency)
+		remainder -= share.Int64()
+	}
+
+	step := int64(1)
+	if remainder < 0 {
+		step = -1
+	}
+	for i := 0; remainder != 0; i = (i + 1) % len(parts) {
+		if ratios[i] == 0 {
+			continue
+		}
+		parts[i].MinorUnits += step
+		remainder -= step
+	}
+
+	return parts, nil
+}
+
+// Split divides money into n parts that differ by at most one minor unit
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("cannot split into %d parts", n)
+	}
+	ratios := make([]int, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
 }
 
 // ToWooCommerceFormat converts to WooCommerce API format
 func (m Money) ToWooCommerceFormat() string {
-	return fmt.Sprintf("%.2f", m.Amount)
+	return m.Decimal()
 }
 
 // FromWooCommerceFormat parses WooCommerce API format
 func FromWooCommerceFormat(amount, currency string) (Money, error) {
-	amountFloat, err := strconv.ParseFloat(amount, 64)
+	money, err := ParseMoney(amount, currency)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount format: %w", err)
+	}
+	return money, nil
+}
+
+// moneyJSON is the wire format of Money: the amount as a decimal JSON number
+// in major units, so stored records and API consumers see e.g. 49.99
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as an exact decimal number in major units
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{
+		Amount:   json.RawMessage(m.Decimal()),
+		Currency: m.Currency,
+	})
+}
+
+// UnmarshalJSON decodes an amount given as a JSON number or decimal string
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var wire moneyJSON
+	if err := json.Unmarshal(data, &wire); err != nil {
+		return err
+	}
+
+	amount := string(bytes.Trim(wire.Amount, `"`))
+	if amount == "" || amount == "null" {
+		*m = NewMoneyFromMinorUnits(0, wire.Currency)
+		return nil
+	}
+
+	parsed, err := ParseMoney(amount, wire.Currency)
 	if err != nil {
-		return Money{}, fmt.Errorf("invalid amount format: %s", amount)
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// parseDecimal parses a plain decimal number with an optional sign
+func parseDecimal(amount string) (*big.Rat, error) {
+	trimmed := strings.TrimSpace(amount)
+	digits := strings.TrimLeft(trimmed, "+-")
+	if digits == "" || len(trimmed)-len(digits) > 1 || strings.Count(digits, ".") > 1 || strings.Trim(digits, "0123456789.") != "" || digits == "." {
+		return nil, fmt.Errorf("invalid amount format: %s", amount)
 	}
-	return NewMoney(amountFloat, currency), nil
-}
\ No newline at end of file
+
+	value, ok := new(big.Rat).SetString(trimmed)
+	if !ok {
+		return nil, fmt.Errorf("invalid amount format: %s", amount)
+	}
+	return value, nil
+}
+
+// exponentScale returns 10 raised to the currency's exponent
+func exponentScale(currency string) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(CurrencyExponent(currency))), nil)
+}
+
+// roundRat rounds a rational number to an integer with the given mode
+func roundRat(value *big.Rat, mode RoundingMode) (int64, error) {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+
+	if remainder.Sign() != 0 {
+		step := big.NewInt(int64(value.Sign()))
+		half := new(big.Int).Abs(remainder)
+		half.Mul(half, big.NewInt(2))
+		cmp := half.Cmp(value.Denom())
+
+		switch mode {
+		case RoundUp:
+			quotient.Add(quotient, step)
+		case RoundHalfUp:
+			if cmp >= 0 {
+				quotient.Add(quotient, step)
+			}
+		case RoundHalfEven:
+			if cmp > 0 || (cmp == 0 && quotient.Bit(0) == 1) {
+				quotient.Add(quotient, step)
+			}
+		}
+	}
+
+	if !quotient.IsInt64() {
+		return 0, errors.New("amount out of range")
+	}
+	return quotient.Int64(), nil
+}
@@ -27,6 +27,7 @@ type Order struct {
 	TaxLines          []TaxLine
 	CouponLines       []CouponLine
 	MetaData          []MetaData
+	StatusHistory     []StatusChange `json:"status_history,omitempty"`
 }
 
 // OrderStatus represents the status of an order
@@ -135,74 +136,25 @@ func (o *Order) IsPaymentCompleted() bool {
 
 // CanBeProcessed checks if the order can be processed for payment
 func (o *Order) CanBeProcessed() bool {
-	return o.Status == StatusPending && o.Total.Amount > 0
+	return o.Status == StatusPending && o.Total.IsPositive()
 }
 
-// ToAnonymousOrder creates an anonymized version of the order for proxy processing
-func (o *Order) ToAnonymousOrder() *Order {
-	anonymousOrder := &Order{
-		Number:            o.Number, // Keep same order number
-		Status:            StatusPending,
-		Currency:          o.Currency,
-		Total:             o.Total,
-		PaymentMethod:     "paypal",
-		PaymentMethodTitle: "PayPal",
-		DateCreated:       time.Now(),
-		CustomerNote:      "",
-		Billing: Address{
-			FirstName: "Customer",
-			LastName:  "Order",
-			Address1:  "Private",
-			City:      "Private",
-			Postcode:  "00000",
-			Country:   o.Billing.Country, // Keep for PayPal requirements
-			Email:     "noreply@oitam.com",
-		},
-		Shipping: Address{
-			FirstName: "Customer",
-			LastName:  "Order",
-			Address1:  "Private",
-			City:      "Private",
-			Postcode:  "00000",
-			Country:   o.Shipping.Country,
-		},
-		LineItems:     o.anonymizeLineItems(),
-		ShippingLines: o.ShippingLines,
-		FeeLines:      o.FeeLines,
-		TaxLines:      o.TaxLines,
-		CouponLines:   []CouponLine{}, // Remove coupon info
-		MetaData: []MetaData{
-			{Key: "_original_order_id", Value: o.ID},
-			{Key: "_proxy_order", Value: "true"},
-		},
-	}
-	
-	return anonymousOrder
-}
-
-// anonymizeLineItems creates anonymous line items
-func (o *Order) anonymizeLineItems() []LineItem {
-	var anonymousItems []LineItem
-	
-	for i, item := range o.LineItems {
-		anonymousItem := LineItem{
-			Name:        formatGenericItemName(i + 1),
-			ProductID:   0, // No product reference
-			VariationID: 0,
-			Quantity:    item.Quantity,
-			SKU:         item.SKU, // Keep original SKU for inventory
-			Price:       item.Price,
-			Subtotal:    item.Subtotal,
-			Total:       item.Total,
-			MetaData:    []MetaData{}, // No product metadata
+// MetaValue returns the value of a meta data key as a string and whether the
+// order carries the key
+func (o *Order) MetaValue(key string) (string, bool) {
+	for _, meta := range o.MetaData {
+		if meta.Key == key {
+			return fmt.Sprint(meta.Value), true
 		}
-		anonymousItems = append(anonymousItems, anonymousItem)
 	}
-	
-	return anonymousItems
+	return "", false
 }
 
-// formatGenericItemName creates generic item names
-func formatGenericItemName(index int) string {
-	return fmt.Sprintf("Item %d", index)
+// ToAnonymousOrder creates an anonymized version of the order for proxy
+// processing. A nil policy applies the default policy.
+func (o *Order) ToAnonymousOrder(policy *AnonymizationPolicy) *Order {
+	if policy == nil {
+		policy = DefaultAnonymizationPolicy()
+	}
+	return policy.Anonymize(o)
 }
\ No newline at end of file
@@ -0,0 +1,53 @@
+package entities
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// OrderEventType names a step of an order's payment flow
+type OrderEventType string
+
+const (
+	OrderEventRedirectRequested OrderEventType = "redirect_requested"
+	OrderEventProxyOrderCreated OrderEventType = "proxy_order_created"
+	OrderEventCheckoutURLIssued OrderEventType = "checkout_url_issued"
+	OrderEventReturnReceived    OrderEventType = "return_received"
+	OrderEventWebhookReceived   OrderEventType = "webhook_received"
+	OrderEventMagicOrderUpdated OrderEventType = "magic_order_updated"
+	OrderEventError             OrderEventType = "error"
+)
+
+// OrderEvent is one entry of a MagicSpore order's audit timeline. Events are
+// only ever appended, never changed.
+type OrderEvent struct {
+	ID         string            `json:"id"`
+	OrderID    string            `json:"order_id"`
+	Type       OrderEventType    `json:"type"`
+	Message    string            `json:"message"`
+	Details    map[string]string `json:"details,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	OccurredAt time.Time         `json:"occurred_at"`
+}
+
+// NewOrderEvent creates an event that occurred now
+func NewOrderEvent(orderID string, eventType OrderEventType, message string, details map[string]string) *OrderEvent {
+	return &OrderEvent{
+		ID:         generateOrderEventID(),
+		OrderID:    orderID,
+		Type:       eventType,
+		Message:    message,
+		Details:    details,
+		OccurredAt: time.Now().Round(0).Truncate(time.Microsecond),
+	}
+}
+
+// generateOrderEventID returns a random event ID
+func generateOrderEventID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "oev_" + time.Now().Format("20060102150405.000000000")
+	}
+	return "oev_" + hex.EncodeToString(buf)
+}
@@ -0,0 +1,61 @@
+package entities
+
+import (
+	"fmt"
+	"time"
+)
+
+// OrderMappingState represents the lifecycle state of a proxy order mapping
+type OrderMappingState string
+
+const (
+	MappingStateActive    OrderMappingState = "active"
+	MappingStatePaid      OrderMappingState = "paid"
+	MappingStateCancelled OrderMappingState = "cancelled"
+	MappingStateFailed    OrderMappingState = "failed"
+	MappingStateRefunded  OrderMappingState = "refunded"
+	MappingStateExpired   OrderMappingState = "expired"
+)
+
+// OrderMapping links a MagicSpore order to the anonymous OITAM order that
+// collects its payment. It is the server-side source of truth for resolving
+// one side of the proxy from the other.
+type OrderMapping struct {
+	MagicOrderID string            `json:"magic_order_id"`
+	OITAMOrderID string            `json:"oitam_order_id"`
+	OrderKey     string            `json:"order_key"` // OITAM order key used for checkout
+	State        OrderMappingState `json:"state"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+	ExpiresAt    time.Time         `json:"expires_at"`
+}
+
+// NewOrderMapping creates an active mapping for a freshly created proxy order
+func NewOrderMapping(magicOrderID string, proxyOrder *Order, ttl time.Duration) *OrderMapping {
+	now := time.Now()
+	return &OrderMapping{
+		MagicOrderID: magicOrderID,
+		OITAMOrderID: fmt.Sprintf("%d", proxyOrder.ID),
+		OrderKey:     proxyOrder.OrderKey,
+		State:        MappingStateActive,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+	}
+}
+
+// IsExpired checks if an active mapping has passed its expiry time
+func (m *OrderMapping) IsExpired(now time.Time) bool {
+	return m.State == MappingStateExpired ||
+		(m.State == MappingStateActive && !m.ExpiresAt.IsZero() && now.After(m.ExpiresAt))
+}
+
+// IsFinal checks if the mapping has reached a terminal state
+func (m *OrderMapping) IsFinal() bool {
+	switch m.State {
+	case MappingStatePaid, MappingStateCancelled, MappingStateFailed, MappingStateRefunded, MappingStateExpired:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,13 @@
+package entities
+
+import "time"
+
+// OrderRefund is a refund recorded against a WooCommerce order. It only
+// records the money returned; the payment itself is refunded through the
+// payment gateway.
+type OrderRefund struct {
+	ID          int       `json:"id"`
+	Amount      Money     `json:"amount"`
+	Reason      string    `json:"reason,omitempty"`
+	DateCreated time.Time `json:"date_created"`
+}
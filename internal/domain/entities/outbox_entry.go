@@ -0,0 +1,128 @@
+package entities
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// OutboxOperation names the MagicSpore order mutation an outbox entry carries
+type OutboxOperation string
+
+const (
+	OutboxUpdatePayment       OutboxOperation = "update_payment"
+	OutboxUpdateStatus        OutboxOperation = "update_status"
+	OutboxUpdateTransactionID OutboxOperation = "update_transaction_id"
+	OutboxAddNote             OutboxOperation = "add_note"
+	OutboxUpdateMetaData      OutboxOperation = "update_meta_data"
+)
+
+// OutboxEntryStatus represents the delivery state of an outbox entry
+type OutboxEntryStatus string
+
+const (
+	OutboxPending   OutboxEntryStatus = "pending"
+	OutboxDelivered OutboxEntryStatus = "delivered"
+	OutboxFailed    OutboxEntryStatus = "failed"    // attempts used up; holds back later entries of its order
+	OutboxDiscarded OutboxEntryStatus = "discarded" // given up on by an operator
+)
+
+// OutboxPayload holds the arguments of an outbox operation; only the fields
+// of the entry's operation are set
+type OutboxPayload struct {
+	Status        OrderStatus `json:"status,omitempty"`
+	Payment       *Payment    `json:"payment,omitempty"`
+	TransactionID string      `json:"transaction_id,omitempty"`
+	Note          string      `json:"note,omitempty"`
+	MetaData      []MetaData  `json:"meta_data,omitempty"`
+}
+
+// OutboxEntry is a MagicSpore order mutation recorded before it is sent, so
+// it is retried until delivered even when MagicSpore is down. Entries of one
+// order are delivered in Sequence order.
+type OutboxEntry struct {
+	ID            string            `json:"id"`
+	Sequence      int64             `json:"sequence"` // assigned by the repository on create
+	OrderID       string            `json:"order_id"`
+	Operation     OutboxOperation   `json:"operation"`
+	Payload       OutboxPayload     `json:"payload"`
+	Status        OutboxEntryStatus `json:"status"`
+	Attempts      int               `json:"attempts"`
+	LastError     string            `json:"last_error,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at,omitempty"` // set while the entry is pending
+	DeliveredAt   *time.Time        `json:"delivered_at,omitempty"`
+}
+
+// NewOutboxEntry creates a pending entry due immediately
+func NewOutboxEntry(orderID string, operation OutboxOperation, payload OutboxPayload) *OutboxEntry {
+	// Microsecond precision survives every store, so leases can be compared later
+	now := time.Now().Round(0).Truncate(time.Microsecond)
+	return &OutboxEntry{
+		ID:            generateOutboxEntryID(),
+		OrderID:       orderID,
+		Operation:     operation,
+		Payload:       payload,
+		Status:        OutboxPending,
+		CreatedAt:     now,
+		NextAttemptAt: &now,
+	}
+}
+
+// RecordDelivery marks the entry delivered
+func (e *OutboxEntry) RecordDelivery() {
+	now := time.Now()
+	e.Status = OutboxDelivered
+	e.Attempts++
+	e.LastError = ""
+	e.NextAttemptAt = nil
+	e.DeliveredAt = &now
+}
+
+// ScheduleRetry records a failed attempt and schedules the next one
+func (e *OutboxEntry) ScheduleRetry(lastError string, at time.Time) {
+	e.Status = OutboxPending
+	e.Attempts++
+	e.LastError = lastError
+	e.NextAttemptAt = &at
+}
+
+// RecordFailure records a failed last attempt; the entry waits for an operator
+func (e *OutboxEntry) RecordFailure(lastError string) {
+	e.Status = OutboxFailed
+	e.Attempts++
+	e.LastError = lastError
+	e.NextAttemptAt = nil
+}
+
+// Requeue makes an undelivered entry due at the given time with fresh attempts
+func (e *OutboxEntry) Requeue(at time.Time) {
+	e.Status = OutboxPending
+	e.Attempts = 0
+	e.NextAttemptAt = &at
+}
+
+// Discard gives up on an undelivered entry, releasing the entries after it
+func (e *OutboxEntry) Discard() {
+	e.Status = OutboxDiscarded
+	e.NextAttemptAt = nil
+}
+
+// IsPending checks if the entry waits for delivery
+func (e *OutboxEntry) IsPending() bool {
+	return e.Status == OutboxPending && e.NextAttemptAt != nil
+}
+
+// IsUndelivered checks if the entry still holds back later entries of its order
+func (e *OutboxEntry) IsUndelivered() bool {
+	return e.Status == OutboxPending || e.Status == OutboxFailed
+}
+
+// generateOutboxEntryID returns a random entry ID
+func generateOutboxEntryID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "obx_" + time.Now().Format("20060102150405.000000000")
+	}
+	return "obx_" + hex.EncodeToString(buf)
+}
@@ -41,6 +41,7 @@ type Payment struct {
 	CreatedAt       time.Time     `json:"created_at"`
 	UpdatedAt       time.Time     `json:"updated_at"`
 	CompletedAt     *time.Time    `json:"completed_at"`
+	ProcessedAt     time.Time     `json:"processed_at"`
 	
 	// PayPal specific fields
 	PayPalDetails   *PayPalDetails `json:"paypal_details,omitempty"`
@@ -54,8 +55,14 @@ type Payment struct {
 	FailureReason   string `json:"failure_reason,omitempty"`
 	RefundAmount    *Money `json:"refund_amount,omitempty"`
 	
+	// Refund ledger, oldest first; RefundAmount is the sum of its entries
+	Refunds         []PaymentRefund `json:"refunds,omitempty"`
+	
 	// Metadata
 	MetaData        []MetaData `json:"meta_data,omitempty"`
+	
+	// Status transitions, oldest first
+	StatusHistory   []StatusChange `json:"status_history,omitempty"`
 }
 
 // PayPalDetails contains PayPal-specific payment details
@@ -115,6 +122,7 @@ type PaymentRequest struct {
 	ReturnURL   string    `json:"return_url"`
 	CancelURL   string    `json:"cancel_url"`
 	Method      PaymentMethod `json:"method"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
 }
 
 // PaymentResponse represents a payment creation response
@@ -145,25 +153,125 @@ func (p *Payment) IsFailed() bool {
 	return p.Status == PaymentStatusFailed || p.Status == PaymentStatusCancelled
 }
 
+// IsFinal checks if the payment has reached a terminal status
+func (p *Payment) IsFinal() bool {
+	switch p.Status {
+	case PaymentStatusCompleted, PaymentStatusFailed, PaymentStatusCancelled, PaymentStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// RefundedAmount returns the total refunded on the payment so far
+func (p *Payment) RefundedAmount() Money {
+	if p.RefundAmount == nil {
+		return NewMoneyFromMinorUnits(0, p.Amount.Currency)
+	}
+	return *p.RefundAmount
+}
+
+// RefundableAmount returns the part of the payment not refunded yet
+func (p *Payment) RefundableAmount() Money {
+	remaining, err := p.Amount.Sub(p.RefundedAmount())
+	if err != nil || remaining.IsNegative() {
+		return NewMoneyFromMinorUnits(0, p.Amount.Currency)
+	}
+	return remaining
+}
+
+// CanRefund checks that amount can be refunded on the payment
+func (p *Payment) CanRefund(amount Money) error {
+	if p.Status != PaymentStatusCompleted {
+		return &StatusTransitionError{Entity: "payment", From: string(p.Status), To: string(PaymentStatusRefunded)}
+	}
+
+	if !amount.IsPositive() {
+		return NewValidationError("refund_amount", "Refund amount must be positive")
+	}
+
+	remaining := p.RefundableAmount()
+	comparison, err := amount.Compare(remaining)
+	if err != nil {
+		return NewValidationError("refund_amount", err.Error())
+	}
+	if comparison > 0 {
+		return NewValidationError("refund_amount", "Refund amount "+amount.String()+" exceeds refundable "+remaining.String())
+	}
+
+	return nil
+}
+
+// HasRefund checks if a refund with the given PayPal refund ID is in the ledger
+func (p *Payment) HasRefund(refundID string) bool {
+	if refundID == "" {
+		return false
+	}
+	for _, refund := range p.Refunds {
+		if refund.ID == refundID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFullyRefunded checks if the refunded total has reached the captured amount
+func (p *Payment) IsFullyRefunded() bool {
+	return p.RefundAmount != nil && p.RefundAmount.Equals(p.Amount)
+}
+
+// ApplyRefund adds a refund to the ledger and the refunded total. The payment
+// moves to refunded once nothing is left to refund. A refund whose ID is
+// already in the ledger returns ErrDuplicateRefund.
+func (p *Payment) ApplyRefund(refund PaymentRefund, reason string) error {
+	if p.HasRefund(refund.ID) {
+		return ErrDuplicateRefund
+	}
+	if err := p.CanRefund(refund.Amount); err != nil {
+		return err
+	}
+
+	refunded, err := p.RefundedAmount().Add(refund.Amount)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if refund.RecordedAt.IsZero() {
+		refund.RecordedAt = now
+	}
+	p.Refunds = append(p.Refunds, refund)
+	p.RefundAmount = &refunded
+	p.UpdatedAt = now
+
+	if p.IsFullyRefunded() {
+		return p.TransitionTo(PaymentStatusRefunded, reason)
+	}
+	return nil
+}
+
 // CanBeProcessed checks if the payment can be processed
 func (p *Payment) CanBeProcessed() bool {
 	return p.Status == PaymentStatusApproved && p.PayerID != ""
 }
 
 // MarkAsCompleted marks the payment as completed
-func (p *Payment) MarkAsCompleted(transactionID string) {
-	p.Status = PaymentStatusCompleted
+func (p *Payment) MarkAsCompleted(transactionID string) error {
+	if err := p.TransitionTo(PaymentStatusCompleted, "captured"); err != nil {
+		return err
+	}
 	p.TransactionID = transactionID
 	now := time.Now()
 	p.CompletedAt = &now
 	p.UpdatedAt = now
+	return nil
 }
 
 // MarkAsFailed marks the payment as failed
-func (p *Payment) MarkAsFailed(reason string) {
-	p.Status = PaymentStatusFailed
+func (p *Payment) MarkAsFailed(reason string) error {
+	if err := p.TransitionTo(PaymentStatusFailed, reason); err != nil {
+		return err
+	}
 	p.FailureReason = reason
-	p.UpdatedAt = time.Now()
+	return nil
 }
 
 // GetApprovalURL returns the PayPal approval URL
@@ -190,7 +298,7 @@ func (p *Payment) Validate() error {
 		return NewValidationError("order_id", "Order ID is required")
 	}
 	
-	if p.Amount.Amount <= 0 {
+	if !p.Amount.IsPositive() {
 		return NewValidationError("amount", "Payment amount must be positive")
 	}
 	
@@ -0,0 +1,30 @@
+package entities
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrDuplicateRefund is returned when a refund is already in a payment's refund ledger
+var ErrDuplicateRefund = errors.New("refund already recorded")
+
+// RefundSource tells which path first recorded a refund
+type RefundSource string
+
+const (
+	// RefundSourceAPI marks refunds issued through the refund endpoint
+	RefundSourceAPI RefundSource = "api"
+	// RefundSourceWebhook marks refunds first seen in a PAYMENT.CAPTURE.REFUNDED webhook,
+	// such as refunds issued from the PayPal dashboard
+	RefundSourceWebhook RefundSource = "webhook"
+)
+
+// PaymentRefund is an entry in a payment's refund ledger. Entries are keyed by
+// the PayPal refund ID, so a refund issued through the API and then reported
+// by webhook is only counted once.
+type PaymentRefund struct {
+	ID         string       `json:"id"`
+	Amount     Money        `json:"amount"`
+	Source     RefundSource `json:"source"`
+	RecordedAt time.Time    `json:"recorded_at"`
+}
@@ -0,0 +1,55 @@
+package entities
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AuthScope names a permission an API caller can hold
+type AuthScope string
+
+const (
+	ScopeReadOrders   AuthScope = "read:orders"
+	ScopeWriteRefunds AuthScope = "write:refunds"
+	ScopeAdmin        AuthScope = "admin" // grants every other scope
+)
+
+// ParseAuthScopes parses scopes separated by spaces or pipes, rejecting
+// unknown ones
+func ParseAuthScopes(value string) ([]AuthScope, error) {
+	fields := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == '|'
+	})
+
+	scopes := make([]AuthScope, 0, len(fields))
+	for _, field := range fields {
+		scope := AuthScope(field)
+		switch scope {
+		case ScopeReadOrders, ScopeWriteRefunds, ScopeAdmin:
+			scopes = append(scopes, scope)
+		default:
+			return nil, fmt.Errorf("unknown scope %q", field)
+		}
+	}
+	return scopes, nil
+}
+
+// Principal is the authenticated caller of an API request
+type Principal struct {
+	Subject string // key fingerprint or token subject, safe to log
+	Method  string // api_key or jwt
+	Scopes  []AuthScope
+}
+
+// HasScope reports whether the principal holds scope, directly or through admin
+func (p *Principal) HasScope(scope AuthScope) bool {
+	if p == nil {
+		return false
+	}
+	for _, held := range p.Scopes {
+		if held == scope || held == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
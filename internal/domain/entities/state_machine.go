@@ -0,0 +1,163 @@
+package entities
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidStatusTransition is wrapped by every StatusTransitionError
+var ErrInvalidStatusTransition = errors.New("invalid status transition")
+
+// StatusTransitionError is returned when a state machine rejects a status change
+type StatusTransitionError struct {
+	Entity string // "order" or "payment"
+	From   string
+	To     string
+}
+
+func (e *StatusTransitionError) Error() string {
+	return fmt.Sprintf("%s cannot move from %s to %s", e.Entity, e.From, e.To)
+}
+
+// Unwrap lets callers match guard errors with errors.Is
+func (e *StatusTransitionError) Unwrap() error {
+	return ErrInvalidStatusTransition
+}
+
+// StatusChange records one transition in an order's or payment's status history
+type StatusChange struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// orderStatusTransitions lists the statuses each order status may move to.
+// Paid orders leave only through a refund, refunded orders are final, and
+// cancelled orders only reopen when a late payment is captured for them.
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	StatusPending:    {StatusProcessing, StatusOnHold, StatusCancelled, StatusFailed},
+	StatusOnHold:     {StatusPending, StatusProcessing, StatusCancelled, StatusFailed, StatusRefunded},
+	StatusProcessing: {StatusOnHold, StatusCompleted, StatusRefunded},
+	StatusCompleted:  {StatusOnHold, StatusRefunded},
+	StatusFailed:     {StatusPending, StatusProcessing, StatusOnHold, StatusCancelled},
+	StatusCancelled:  {StatusProcessing},
+	StatusRefunded:   {},
+}
+
+// paymentStatusTransitions lists the statuses each payment status may move to
+var paymentStatusTransitions = map[PaymentStatus][]PaymentStatus{
+	PaymentStatusPending:   {PaymentStatusCreated, PaymentStatusApproved, PaymentStatusCompleted, PaymentStatusFailed, PaymentStatusCancelled},
+	PaymentStatusCreated:   {PaymentStatusPending, PaymentStatusApproved, PaymentStatusCompleted, PaymentStatusFailed, PaymentStatusCancelled},
+	PaymentStatusApproved:  {PaymentStatusPending, PaymentStatusCompleted, PaymentStatusFailed, PaymentStatusCancelled},
+	PaymentStatusCompleted: {PaymentStatusRefunded},
+	PaymentStatusFailed:    {},
+	PaymentStatusCancelled: {},
+	PaymentStatusRefunded:  {},
+}
+
+// CanTransitionTo checks if an order may move from this status to next.
+// Staying in the same status is always allowed.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	if s == next {
+		return true
+	}
+	for _, allowed := range orderStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransitionTo checks if a payment may move from this status to next.
+// Staying in the same status is always allowed.
+func (s PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	if s == next {
+		return true
+	}
+	for _, allowed := range paymentStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionTo moves the order to next, recording the change in its history.
+// Moving to the current status is a no-op.
+func (o *Order) TransitionTo(next OrderStatus, reason string) error {
+	if o.Status == next {
+		return nil
+	}
+	if !o.Status.CanTransitionTo(next) {
+		return &StatusTransitionError{Entity: "order", From: string(o.Status), To: string(next)}
+	}
+
+	o.StatusHistory = append(o.StatusHistory, StatusChange{
+		From:   string(o.Status),
+		To:     string(next),
+		Reason: reason,
+		At:     time.Now(),
+	})
+	o.Status = next
+	return nil
+}
+
+// MetaStatusHistory is the meta data key an order's status history is stored
+// under on its WooCommerce order, as a JSON array
+const MetaStatusHistory = "_status_history"
+
+// StatusHistoryMetaData returns the order's status history as a meta data entry
+func (o *Order) StatusHistoryMetaData() MetaData {
+	history := o.StatusHistory
+	if history == nil {
+		history = []StatusChange{}
+	}
+	data, _ := json.Marshal(history)
+	return MetaData{Key: MetaStatusHistory, Value: string(data)}
+}
+
+// StatusHistoryFromMetaData reads a status history stored by
+// StatusHistoryMetaData. Missing or unreadable entries yield no history.
+func StatusHistoryFromMetaData(metaData []MetaData) []StatusChange {
+	for _, meta := range metaData {
+		if meta.Key != MetaStatusHistory {
+			continue
+		}
+		value, ok := meta.Value.(string)
+		if !ok {
+			return nil
+		}
+		var history []StatusChange
+		if err := json.Unmarshal([]byte(value), &history); err != nil {
+			return nil
+		}
+		return history
+	}
+	return nil
+}
+
+// TransitionTo moves the payment to next, recording the change in its history.
+// Moving to the current status is a no-op.
+func (p *Payment) TransitionTo(next PaymentStatus, reason string) error {
+	if p.Status == next {
+		return nil
+	}
+	if !p.Status.CanTransitionTo(next) {
+		return &StatusTransitionError{Entity: "payment", From: string(p.Status), To: string(next)}
+	}
+
+	now := time.Now()
+	p.StatusHistory = append(p.StatusHistory, StatusChange{
+		From:   string(p.Status),
+		To:     string(next),
+		Reason: reason,
+		At:     now,
+	})
+	p.Status = next
+	p.UpdatedAt = now
+	return nil
+}
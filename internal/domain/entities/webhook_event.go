@@ -0,0 +1,109 @@
+package entities
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrDuplicateWebhookEvent is returned when an event ID has already been recorded
+var ErrDuplicateWebhookEvent = errors.New("webhook event already recorded")
+
+// WebhookSignatureVerdict records how a webhook delivery was authenticated
+type WebhookSignatureVerdict string
+
+const (
+	SignatureVerified WebhookSignatureVerdict = "verified"
+	SignatureSkipped  WebhookSignatureVerdict = "skipped" // no verifier configured
+	SignatureInvalid  WebhookSignatureVerdict = "invalid"
+)
+
+// WebhookEventStatus represents the processing outcome of a webhook event
+type WebhookEventStatus string
+
+const (
+	WebhookEventReceived   WebhookEventStatus = "received"
+	WebhookEventQueued     WebhookEventStatus = "queued"
+	WebhookEventProcessed  WebhookEventStatus = "processed"
+	WebhookEventIgnored    WebhookEventStatus = "ignored"
+	WebhookEventFailed     WebhookEventStatus = "failed"
+	WebhookEventDeadLetter WebhookEventStatus = "dead_letter"
+	WebhookEventRejected   WebhookEventStatus = "rejected"
+)
+
+// WebhookEvent is a webhook delivery as received, kept so retried deliveries
+// are recognised and processed events can be replayed
+type WebhookEvent struct {
+	ID            string                  `json:"id"` // PayPal event ID
+	EventType     string                  `json:"event_type"`
+	Body          string                  `json:"body"` // raw request body
+	Signature     WebhookSignatureVerdict `json:"signature"`
+	Status        WebhookEventStatus      `json:"status"`
+	Result        string                  `json:"result,omitempty"` // outcome message or error
+	Attempts      int                     `json:"attempts"`
+	ReceivedAt    time.Time               `json:"received_at"`
+	ProcessedAt   *time.Time              `json:"processed_at,omitempty"`
+	NextAttemptAt *time.Time              `json:"next_attempt_at,omitempty"` // set while the event waits in the queue
+}
+
+// NewWebhookEvent creates a record for a freshly received delivery
+func NewWebhookEvent(id, eventType string, body []byte, signature WebhookSignatureVerdict) *WebhookEvent {
+	status := WebhookEventReceived
+	if signature == SignatureInvalid {
+		status = WebhookEventRejected
+	}
+
+	return &WebhookEvent{
+		ID:         id,
+		EventType:  eventType,
+		Body:       string(body),
+		Signature:  signature,
+		Status:     status,
+		ReceivedAt: time.Now(),
+	}
+}
+
+// RecordOutcome stores the final result of a processing attempt
+func (e *WebhookEvent) RecordOutcome(status WebhookEventStatus, result string) {
+	now := time.Now()
+	e.Status = status
+	e.Result = result
+	e.Attempts++
+	e.ProcessedAt = &now
+	e.NextAttemptAt = nil
+}
+
+// Enqueue schedules the event for background processing at the given time
+func (e *WebhookEvent) Enqueue(at time.Time) {
+	e.Status = WebhookEventQueued
+	e.NextAttemptAt = &at
+}
+
+// ScheduleRetry records a failed attempt and queues the next one
+func (e *WebhookEvent) ScheduleRetry(result string, at time.Time) {
+	e.RecordOutcome(WebhookEventFailed, result)
+	e.NextAttemptAt = &at
+}
+
+// AcceptRedelivery takes over the payload and signature verdict of a
+// redelivery that is about to be processed, so a body first recorded under a
+// bad signature is never processed as trusted
+func (e *WebhookEvent) AcceptRedelivery(redelivery *WebhookEvent) {
+	e.EventType = redelivery.EventType
+	e.Body = redelivery.Body
+	e.Signature = redelivery.Signature
+}
+
+// IsQueued checks if the event is waiting for background processing
+func (e *WebhookEvent) IsQueued() bool {
+	return e.NextAttemptAt != nil
+}
+
+// IsTrusted checks if the event passed signature verification or none was configured
+func (e *WebhookEvent) IsTrusted() bool {
+	return e.Signature != SignatureInvalid
+}
+
+// NeedsProcessing checks if a redelivery of this event should be processed again
+func (e *WebhookEvent) NeedsProcessing() bool {
+	return e.Status == WebhookEventFailed || e.Status == WebhookEventRejected || e.Status == WebhookEventDeadLetter
+}
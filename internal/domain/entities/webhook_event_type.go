@@ -0,0 +1,140 @@
+package entities
+
+// PayPal webhook event types handled by the proxy
+const (
+	EventCheckoutOrderApproved       = "CHECKOUT.ORDER.APPROVED"
+	EventCheckoutOrderCompleted      = "CHECKOUT.ORDER.COMPLETED"
+	EventPaymentAuthorizationCreated = "PAYMENT.AUTHORIZATION.CREATED"
+	EventPaymentAuthorizationVoided  = "PAYMENT.AUTHORIZATION.VOIDED"
+	EventPaymentCaptureCompleted     = "PAYMENT.CAPTURE.COMPLETED"
+	EventPaymentCapturePending       = "PAYMENT.CAPTURE.PENDING"
+	EventPaymentCaptureDenied        = "PAYMENT.CAPTURE.DENIED"
+	EventPaymentCaptureRefunded      = "PAYMENT.CAPTURE.REFUNDED"
+	EventPaymentCaptureReversed      = "PAYMENT.CAPTURE.REVERSED"
+	EventCustomerDisputeCreated      = "CUSTOMER.DISPUTE.CREATED"
+	EventCustomerDisputeUpdated      = "CUSTOMER.DISPUTE.UPDATED"
+	EventCustomerDisputeResolved     = "CUSTOMER.DISPUTE.RESOLVED"
+)
+
+// OrderTransition describes how a webhook event moves the MagicSpore order
+type OrderTransition struct {
+	From    []OrderStatus     // statuses the order may leave; in any other status only the note is added
+	To      OrderStatus       // target status; empty adds the note without a status change
+	Mapping OrderMappingState // proxy mapping state after the move; empty leaves the mapping unchanged
+}
+
+// Allows checks if an order in the given status takes the transition
+func (t OrderTransition) Allows(status OrderStatus) bool {
+	if t.To == "" || status == t.To {
+		return false
+	}
+	for _, from := range t.From {
+		if status == from {
+			return true
+		}
+	}
+	return false
+}
+
+// NoteOnlyTransition records the event on the order without changing it
+var NoteOnlyTransition = OrderTransition{}
+
+var (
+	// disputeOpenTransition holds a paid order while a dispute is open
+	disputeOpenTransition = OrderTransition{
+		From: []OrderStatus{StatusProcessing, StatusCompleted},
+		To:   StatusOnHold,
+	}
+
+	// disputeLostTransition applies a dispute resolved with the money returned to the buyer
+	disputeLostTransition = OrderTransition{
+		From:    []OrderStatus{StatusOnHold, StatusProcessing, StatusCompleted},
+		To:      StatusRefunded,
+		Mapping: MappingStateRefunded,
+	}
+
+	// disputeWonTransition applies a dispute resolved with the merchant keeping the money
+	disputeWonTransition = OrderTransition{
+		From: []OrderStatus{StatusOnHold},
+		To:   StatusProcessing,
+	}
+)
+
+// CaptureReviewTransition holds an unpaid order whose capture does not match
+// the amount its proxy order charged until someone reviews it
+var CaptureReviewTransition = OrderTransition{
+	From: []OrderStatus{StatusPending, StatusFailed},
+	To:   StatusOnHold,
+}
+
+// webhookTransitions is the catalogue of supported event types. Events whose
+// effect depends on the payload (completed orders, updated and resolved
+// disputes) list their default here and are refined by the webhook use case.
+var webhookTransitions = map[string]OrderTransition{
+	EventCheckoutOrderApproved: NoteOnlyTransition,
+	EventCheckoutOrderCompleted: {
+		From:    []OrderStatus{StatusPending, StatusOnHold},
+		To:      StatusProcessing,
+		Mapping: MappingStatePaid,
+	},
+	EventPaymentAuthorizationCreated: {
+		From: []OrderStatus{StatusPending},
+		To:   StatusOnHold,
+	},
+	EventPaymentAuthorizationVoided: {
+		From:    []OrderStatus{StatusPending, StatusOnHold},
+		To:      StatusCancelled,
+		Mapping: MappingStateCancelled,
+	},
+	EventPaymentCaptureCompleted: {
+		From:    []OrderStatus{StatusPending, StatusOnHold, StatusFailed, StatusCancelled},
+		To:      StatusProcessing,
+		Mapping: MappingStatePaid,
+	},
+	EventPaymentCapturePending: {
+		From: []OrderStatus{StatusPending},
+		To:   StatusOnHold,
+	},
+	EventPaymentCaptureDenied: {
+		From:    []OrderStatus{StatusPending, StatusOnHold},
+		To:      StatusFailed,
+		Mapping: MappingStateFailed,
+	},
+	EventPaymentCaptureRefunded: {
+		From:    []OrderStatus{StatusProcessing, StatusCompleted, StatusOnHold},
+		To:      StatusRefunded,
+		Mapping: MappingStateRefunded,
+	},
+	EventPaymentCaptureReversed: {
+		From:    []OrderStatus{StatusProcessing, StatusCompleted, StatusOnHold},
+		To:      StatusRefunded,
+		Mapping: MappingStateRefunded,
+	},
+	EventCustomerDisputeCreated:  disputeOpenTransition,
+	EventCustomerDisputeUpdated:  disputeOpenTransition,
+	EventCustomerDisputeResolved: NoteOnlyTransition,
+}
+
+// WebhookTransition returns the order transition for a webhook event type
+func WebhookTransition(eventType string) (OrderTransition, bool) {
+	transition, exists := webhookTransitions[eventType]
+	return transition, exists
+}
+
+// IsSupportedWebhookEventType checks if the proxy handles a webhook event type
+func IsSupportedWebhookEventType(eventType string) bool {
+	_, exists := webhookTransitions[eventType]
+	return exists
+}
+
+// DisputeResolvedTransition returns the order transition for a dispute outcome code
+func DisputeResolvedTransition(outcome string) OrderTransition {
+	switch outcome {
+	case "RESOLVED_BUYER_FAVOUR", "ACCEPTED":
+		return disputeLostTransition
+	case "RESOLVED_SELLER_FAVOUR", "RESOLVED_WITH_PAYOUT", "CANCELED_BY_BUYER", "DENIED":
+		return disputeWonTransition
+	default:
+		return NoteOnlyTransition
+	}
+}
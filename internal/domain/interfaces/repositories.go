@@ -2,9 +2,14 @@ package interfaces
 
 import (
 	"context"
+	"errors"
 	"paypal-proxy/internal/domain/entities"
+	"time"
 )
 
+// ErrNotFound is wrapped by repositories when the requested record does not exist
+var ErrNotFound = errors.New("not found")
+
 // OrderRepository defines the interface for order data access
 type OrderRepository interface {
 	// GetByID retrieves an order by its ID
@@ -31,11 +36,122 @@ type PaymentRepository interface {
 	// GetByOrderID retrieves payments for a specific order
 	GetByOrderID(ctx context.Context, orderID string) ([]*entities.Payment, error)
 	
-	// GetByPaymentID retrieves a payment by its payment provider ID
+	// GetByPaymentID retrieves a payment by its payment provider ID. Lookups
+	// without a payment return an error wrapping ErrNotFound.
 	GetByPaymentID(ctx context.Context, paymentID string) (*entities.Payment, error)
 	
 	// UpdateStatus updates the payment status
 	UpdateStatus(ctx context.Context, paymentID string, status entities.PaymentStatus) error
+	
+	// RecordRefund adds a refund to the ledger of the most recent payment with
+	// the given payment provider ID and returns the updated payment. A refund
+	// already in the ledger returns entities.ErrDuplicateRefund.
+	RecordRefund(ctx context.Context, paymentID string, refund entities.PaymentRefund) (*entities.Payment, error)
+}
+
+// OrderMappingRepository defines the interface for proxy order mapping data access
+type OrderMappingRepository interface {
+	// Create stores a new mapping
+	Create(ctx context.Context, mapping *entities.OrderMapping) error
+	
+	// GetByMagicOrderID retrieves the most recent mapping for a MagicSpore order.
+	// Lookups without a mapping return an error wrapping ErrNotFound.
+	GetByMagicOrderID(ctx context.Context, magicOrderID string) (*entities.OrderMapping, error)
+	
+	// GetByOITAMOrderID retrieves the mapping for an OITAM proxy order. Lookups
+	// without a mapping return an error wrapping ErrNotFound.
+	GetByOITAMOrderID(ctx context.Context, oitamOrderID string) (*entities.OrderMapping, error)
+	
+	// UpdateState updates the state of the mapping for an OITAM proxy order
+	UpdateState(ctx context.Context, oitamOrderID string, state entities.OrderMappingState) error
+	
+	// ListByState retrieves up to limit mappings in a state created before
+	// createdBefore, oldest first. Mappings are ordered by creation time, then
+	// OITAM order ID; a non-nil after starts the page past that mapping.
+	ListByState(ctx context.Context, state entities.OrderMappingState, createdBefore time.Time, after *entities.OrderMapping, limit int) ([]*entities.OrderMapping, error)
+}
+
+// DisputeRepository defines the interface for PayPal dispute data access
+type DisputeRepository interface {
+	// Save stores a dispute, replacing any earlier record with its ID
+	Save(ctx context.Context, dispute *entities.Dispute) error
+	
+	// GetByID retrieves a dispute by its PayPal dispute ID. Unknown disputes
+	// return an error wrapping ErrNotFound.
+	GetByID(ctx context.Context, id string) (*entities.Dispute, error)
+	
+	// ListByOrderID retrieves the disputes of a MagicSpore order, oldest first
+	ListByOrderID(ctx context.Context, orderID string) ([]*entities.Dispute, error)
+	
+	// ListOpen retrieves every dispute that is not resolved yet, oldest first
+	ListOpen(ctx context.Context) ([]*entities.Dispute, error)
+}
+
+// OutboxRepository defines the interface for MagicSpore order mutations awaiting delivery
+type OutboxRepository interface {
+	// Create stores a new entry at the end of its order's queue, assigning its sequence
+	Create(ctx context.Context, entry *entities.OutboxEntry) error
+	
+	// GetByID retrieves an entry by its ID. Lookups without an entry return
+	// an error wrapping ErrNotFound.
+	GetByID(ctx context.Context, id string) (*entities.OutboxEntry, error)
+	
+	// Update replaces a stored entry, returning an error wrapping ErrNotFound
+	// if it does not exist
+	Update(ctx context.Context, entry *entities.OutboxEntry) error
+	
+	// ListByOrderID retrieves the entries of a MagicSpore order in sequence order
+	ListByOrderID(ctx context.Context, orderID string) ([]*entities.OutboxEntry, error)
+	
+	// ListDue retrieves up to limit pending entries whose next attempt is at or
+	// before now and which no undelivered entry of the same order precedes, in
+	// sequence order
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.OutboxEntry, error)
+	
+	// ListUndelivered retrieves up to limit pending or failed entries created
+	// before createdBefore, oldest first
+	ListUndelivered(ctx context.Context, createdBefore time.Time, limit int) ([]*entities.OutboxEntry, error)
+	
+	// Claim moves a pending entry's next attempt to leaseUntil, provided it has
+	// not changed since it was read. It reports whether the claim succeeded.
+	Claim(ctx context.Context, entry *entities.OutboxEntry, leaseUntil time.Time) (bool, error)
+}
+
+// OrderEventRepository defines the interface for the append-only order audit timeline
+type OrderEventRepository interface {
+	// Append stores a new event at the end of its order's timeline
+	Append(ctx context.Context, event *entities.OrderEvent) error
+	
+	// ListByOrderID retrieves the timeline of a MagicSpore order in the order
+	// its events were appended
+	ListByOrderID(ctx context.Context, orderID string) ([]*entities.OrderEvent, error)
+}
+
+// WebhookEventRepository defines the interface for received webhook event data access
+type WebhookEventRepository interface {
+	// Create stores a new event, returning entities.ErrDuplicateWebhookEvent if its ID exists
+	Create(ctx context.Context, event *entities.WebhookEvent) error
+	
+	// GetByID retrieves an event by its PayPal event ID. Lookups without an
+	// event return an error wrapping ErrNotFound.
+	GetByID(ctx context.Context, id string) (*entities.WebhookEvent, error)
+	
+	// Update replaces a stored event, returning an error wrapping ErrNotFound
+	// if it does not exist
+	Update(ctx context.Context, event *entities.WebhookEvent) error
+	
+	// ListByReceivedAt retrieves events received in [from, to), oldest first
+	ListByReceivedAt(ctx context.Context, from, to time.Time) ([]*entities.WebhookEvent, error)
+	
+	// ListByStatus retrieves up to limit events in a status, oldest first
+	ListByStatus(ctx context.Context, status entities.WebhookEventStatus, limit int) ([]*entities.WebhookEvent, error)
+	
+	// ListDue retrieves up to limit queued events whose next attempt is at or before now
+	ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.WebhookEvent, error)
+	
+	// Claim moves a due event's next attempt to leaseUntil, provided it has not
+	// changed since it was read. It reports whether the claim succeeded.
+	Claim(ctx context.Context, event *entities.WebhookEvent, leaseUntil time.Time) (bool, error)
 }
 
 // WooCommerceRepository defines the interface for WooCommerce API operations
@@ -45,9 +161,16 @@ type WooCommerceRepository interface {
 	UpdateMagicOrder(ctx context.Context, orderID string, order *entities.Order) error
 	UpdateMagicOrderStatus(ctx context.Context, orderID string, status entities.OrderStatus) error
 	UpdateMagicOrderPayment(ctx context.Context, orderID string, payment *entities.Payment) error
+	UpdateMagicOrderTransactionID(ctx context.Context, orderID string, transactionID string) error
+	AddMagicOrderNote(ctx context.Context, orderID string, note string) error
+	UpdateMagicOrderMetaData(ctx context.Context, orderID string, metaData []entities.MetaData) error
+	CreateMagicOrderRefund(ctx context.Context, orderID string, refund *entities.OrderRefund) (*entities.OrderRefund, error)
 	
 	// OITAM operations (payment processor store)
 	CreateOITAMOrder(ctx context.Context, order *entities.Order) (*entities.Order, error)
 	GetOITAMOrder(ctx context.Context, orderID string) (*entities.Order, error)
 	UpdateOITAMOrder(ctx context.Context, orderID string, order *entities.Order) error
+	UpdateOITAMOrderStatus(ctx context.Context, orderID string, status entities.OrderStatus) error
+	TrashOITAMOrder(ctx context.Context, orderID string) error
+	CreateOITAMOrderRefund(ctx context.Context, orderID string, refund *entities.OrderRefund) (*entities.OrderRefund, error)
 }
\ No newline at end of file
@@ -3,6 +3,7 @@ package interfaces
 import (
 	"context"
 	"paypal-proxy/internal/domain/entities"
+	"time"
 )
 
 // PaymentGateway defines the interface for payment processing
@@ -19,8 +20,43 @@ type PaymentGateway interface {
 	// CancelPayment cancels a payment
 	CancelPayment(ctx context.Context, paymentID string) error
 	
-	// RefundPayment refunds a payment
-	RefundPayment(ctx context.Context, paymentID string, amount entities.Money) error
+	// RefundPayment refunds a payment and returns the provider's refund ID.
+	// Calls with the same requestID refund once and return the same refund.
+	RefundPayment(ctx context.Context, paymentID string, amount entities.Money, requestID string) (string, error)
+}
+
+// WebhookVerifier defines the interface for authenticating payment provider webhooks
+type WebhookVerifier interface {
+	// VerifyWebhook checks a delivery's transmission headers against its raw body
+	VerifyWebhook(ctx context.Context, transmission WebhookTransmission, body []byte) error
+}
+
+// WebhookTransmission holds the PAYPAL-* headers that sign a webhook delivery
+type WebhookTransmission struct {
+	ID        string // PAYPAL-TRANSMISSION-ID
+	Time      string // PAYPAL-TRANSMISSION-TIME
+	Signature string // PAYPAL-TRANSMISSION-SIG, base64 encoded
+	CertURL   string // PAYPAL-CERT-URL
+	AuthAlgo  string // PAYPAL-AUTH-ALGO
+}
+
+// Authenticator defines the interface for identifying API callers
+type Authenticator interface {
+	// Authenticate returns the caller behind the credentials, nil when they
+	// hold none this authenticator reads, or an error when they are invalid
+	Authenticate(ctx context.Context, credentials APICredentials) (*entities.Principal, error)
+}
+
+// APICredentials holds the credentials presented with an API request
+type APICredentials struct {
+	APIKey      string // X-API-Key header
+	BearerToken string // Authorization: Bearer header
+}
+
+// ExchangeRateProvider defines the interface for currency exchange rate sources
+type ExchangeRateProvider interface {
+	// GetRate returns the rate converting one unit of from into to
+	GetRate(ctx context.Context, from, to string) (*entities.ExchangeRate, error)
 }
 
 // URLBuilder defines the interface for building URLs
@@ -75,6 +111,37 @@ type ConfigService interface {
 	
 	// GetServerConfig returns server configuration
 	GetServerConfig() ServerConfig
+	
+	// GetWebhookSecret returns the webhook secret for signature validation
+	GetWebhookSecret() string
+	
+	// GetProxyOrderTTL returns how long a proxy order accepts payment
+	GetProxyOrderTTL() time.Duration
+	
+	// GetPaymentVerificationConfig returns polling settings for unconfirmed returns
+	GetPaymentVerificationConfig() PaymentVerificationConfig
+	
+	// GetWebhookQueueConfig returns asynchronous webhook processing settings
+	GetWebhookQueueConfig() WebhookQueueConfig
+	
+	// GetReconciliationConfig returns background reconciliation settings
+	GetReconciliationConfig() ReconciliationConfig
+	
+	// GetSweepConfig returns abandoned proxy order sweeping settings
+	GetSweepConfig() SweepConfig
+	
+	// GetOutboxConfig returns durable MagicSpore order update settings
+	GetOutboxConfig() OutboxConfig
+	
+	// GetSettlementCurrency returns the currency of OITAM proxy orders; empty
+	// keeps the MagicSpore order currency
+	GetSettlementCurrency() string
+	
+	// GetAnonymizationPolicy returns the policy applied to proxy orders
+	GetAnonymizationPolicy() *entities.AnonymizationPolicy
+	
+	// GetStatusTokenConfig returns the signing of storefront status tokens
+	GetStatusTokenConfig() StatusTokenConfig
 }
 
 // Configuration types
@@ -97,10 +164,63 @@ type ReturnURLsConfig struct {
 	Error   string
 }
 
-type ServerConfig struct {
-	Environment string
-	Port        string
-	LogLevel    string
-	APITimeout  int
-	RateLimit   int
+// PaymentVerificationConfig controls re-verification of returns whose payment
+// could not be confirmed immediately
+type PaymentVerificationConfig struct {
+	PollInterval time.Duration
+	PollAttempts int
+}
+
+// WebhookQueueConfig controls background processing of queued webhooks
+type WebhookQueueConfig struct {
+	Enabled        bool
+	Workers        int
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	PollInterval   time.Duration
+}
+
+// ReconciliationConfig controls the background reconciliation of proxy orders
+type ReconciliationConfig struct {
+	Enabled   bool
+	Interval  time.Duration
+	MinAge    time.Duration
+	BatchSize int
+}
+
+// SweepConfig controls the closing of proxy orders that were never paid
+type SweepConfig struct {
+	Enabled           bool
+	Interval          time.Duration
+	TTL               time.Duration
+	Trash             bool // trash the OITAM order instead of cancelling it
+	RestoreMagicOrder bool
+	BatchSize         int
+}
+
+// OutboxConfig controls the durable delivery of MagicSpore order updates
+type OutboxConfig struct {
+	Enabled        bool
+	PollInterval   time.Duration
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	StuckAfter     time.Duration
+}
+
+// StatusTokenConfig controls the signed tokens that let a customer poll
+// their order's payment status
+type StatusTokenConfig struct {
+	Secret string // empty disables status tokens
+	TTL    time.Duration
+}
+
+// ServerConfig exposes server settings to the inner layers
+type ServerConfig interface {
+	GetPort() string
+	GetEnvironment() string
+	GetLogLevel() string
+	GetBaseURL() string
+	GetTimeout() time.Duration
 }
\ No newline at end of file
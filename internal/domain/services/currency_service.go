@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"strings"
+	"time"
+)
+
+// conversionRounding rounds converted amounts to the target minor unit
+const conversionRounding = entities.RoundHalfUp
+
+// CurrencyConversionService converts orders into the PayPal settlement currency
+type CurrencyConversionService struct {
+	provider interfaces.ExchangeRateProvider
+	logger   interfaces.Logger
+}
+
+// NewCurrencyConversionService creates a new currency conversion service
+func NewCurrencyConversionService(provider interfaces.ExchangeRateProvider, logger interfaces.Logger) *CurrencyConversionService {
+	return &CurrencyConversionService{
+		provider: provider,
+		logger:   logger,
+	}
+}
+
+// Convert converts an amount into the given currency at the provider's rate
+func (s *CurrencyConversionService) Convert(ctx context.Context, amount entities.Money, currency string) (*entities.CurrencyConversion, error) {
+	rate, err := s.rate(ctx, amount.Currency, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := rate.Convert(amount, conversionRounding)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.CurrencyConversion{
+		Original:  amount,
+		Converted: converted,
+		Rate:      *rate,
+	}, nil
+}
+
+// ConvertOrder converts every amount of an order into the given currency with
+// a single rate and records the conversion in the order's meta data. The
+// order's line slices are replaced, never modified in place.
+func (s *CurrencyConversionService) ConvertOrder(ctx context.Context, order *entities.Order, currency string) (*entities.CurrencyConversion, error) {
+	if order == nil {
+		return nil, errors.New("order cannot be nil")
+	}
+
+	conversion, err := s.Convert(ctx, order.Total, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var convertErr error
+	convert := func(amount entities.Money) entities.Money {
+		// Lines without an amount have no currency set
+		if amount.Currency == "" && amount.IsZero() {
+			return entities.NewMoneyFromMinorUnits(0, conversion.Rate.To)
+		}
+		converted, err := conversion.Rate.Convert(amount, conversionRounding)
+		if err != nil && convertErr == nil {
+			convertErr = err
+		}
+		return converted
+	}
+
+	lineItems := make([]entities.LineItem, len(order.LineItems))
+	for i, item := range order.LineItems {
+		item.Price = convert(item.Price)
+		item.Subtotal = convert(item.Subtotal)
+		item.Total = convert(item.Total)
+		lineItems[i] = item
+	}
+
+	shippingLines := make([]entities.ShippingLine, len(order.ShippingLines))
+	for i, line := range order.ShippingLines {
+		line.Total = convert(line.Total)
+		shippingLines[i] = line
+	}
+
+	feeLines := make([]entities.FeeLine, len(order.FeeLines))
+	for i, line := range order.FeeLines {
+		line.Total = convert(line.Total)
+		feeLines[i] = line
+	}
+
+	taxLines := make([]entities.TaxLine, len(order.TaxLines))
+	for i, line := range order.TaxLines {
+		line.TaxTotal = convert(line.TaxTotal)
+		line.ShippingTaxTotal = convert(line.ShippingTaxTotal)
+		taxLines[i] = line
+	}
+
+	couponLines := make([]entities.CouponLine, len(order.CouponLines))
+	for i, line := range order.CouponLines {
+		line.Discount = convert(line.Discount)
+		line.DiscountTax = convert(line.DiscountTax)
+		couponLines[i] = line
+	}
+
+	if convertErr != nil {
+		return nil, fmt.Errorf("failed to convert order lines: %w", convertErr)
+	}
+
+	order.Currency = conversion.Converted.Currency
+	order.Total = conversion.Converted
+	order.LineItems = lineItems
+	order.ShippingLines = shippingLines
+	order.FeeLines = feeLines
+	order.TaxLines = taxLines
+	order.CouponLines = couponLines
+	order.MetaData = append(append([]entities.MetaData{}, order.MetaData...), conversion.MetaData()...)
+
+	s.logger.Info("Order converted to settlement currency", map[string]interface{}{
+		"order_number":     order.Number,
+		"original_amount":  conversion.Original.String(),
+		"converted_amount": conversion.Converted.String(),
+		"rate":             conversion.Rate.Rate,
+		"rate_source":      conversion.Rate.Source,
+	})
+
+	return conversion, nil
+}
+
+// rate looks up the exchange rate between two currencies. Converting into
+// the same currency uses a rate of one.
+func (s *CurrencyConversionService) rate(ctx context.Context, from, to string) (*entities.ExchangeRate, error) {
+	if from == "" || to == "" {
+		return nil, errors.New("both currencies are required for conversion")
+	}
+
+	if strings.EqualFold(from, to) {
+		return entities.NewExchangeRate(from, to, "1", "identity", time.Now())
+	}
+
+	rate, err := s.provider.GetRate(ctx, strings.ToUpper(from), strings.ToUpper(to))
+	if err != nil {
+		s.logger.Error("Failed to get exchange rate", err, map[string]interface{}{
+			"from": from,
+			"to":   to,
+		})
+		return nil, fmt.Errorf("failed to get exchange rate %s/%s: %w", from, to, err)
+	}
+
+	return rate, nil
+}
@@ -29,7 +29,7 @@ func (s *OrderDomainService) ValidateOrderForPayment(ctx context.Context, order
 		return errors.New("order ID is required")
 	}
 	
-	if order.Total.Amount <= 0 {
+	if !order.Total.IsPositive() {
 		return errors.New("order total must be greater than zero")
 	}
 	
@@ -51,7 +51,7 @@ func (s *OrderDomainService) ValidateOrderForPayment(ctx context.Context, order
 	
 	s.logger.Info("Order validation successful", map[string]interface{}{
 		"order_id":  order.ID,
-		"order_total": order.Total.Amount,
+		"order_total": order.Total.Decimal(),
 		"currency":  order.Currency,
 		"status":    order.Status,
 	})
@@ -59,8 +59,9 @@ func (s *OrderDomainService) ValidateOrderForPayment(ctx context.Context, order
 	return nil
 }
 
-// CreateAnonymousOrder creates an anonymized version of an order for proxy processing
-func (s *OrderDomainService) CreateAnonymousOrder(ctx context.Context, originalOrder *entities.Order) (*entities.Order, error) {
+// CreateAnonymousOrder creates an anonymized version of an order for proxy
+// processing. A nil policy applies the default anonymization policy.
+func (s *OrderDomainService) CreateAnonymousOrder(ctx context.Context, originalOrder *entities.Order, policy *entities.AnonymizationPolicy) (*entities.Order, error) {
 	if originalOrder == nil {
 		return nil, errors.New("original order cannot be nil")
 	}
@@ -70,7 +71,7 @@ func (s *OrderDomainService) CreateAnonymousOrder(ctx context.Context, originalO
 		return nil, err
 	}
 	
-	anonymousOrder := originalOrder.ToAnonymousOrder()
+	anonymousOrder := originalOrder.ToAnonymousOrder(policy)
 	
 	s.logger.Info("Anonymous order created", map[string]interface{}{
 		"original_order_id": originalOrder.ID,
@@ -87,71 +88,94 @@ func (s *OrderDomainService) CalculateOrderTotals(ctx context.Context, order *en
 		return errors.New("order cannot be nil")
 	}
 	
-	var itemsTotal float64
+	// Sum in minor units so the check is exact
+	var calculatedTotal int64
 	for _, item := range order.LineItems {
-		itemsTotal += item.Total.Amount
+		calculatedTotal += item.Total.MinorUnits
 	}
 	
-	var shippingTotal float64
 	for _, shipping := range order.ShippingLines {
-		shippingTotal += shipping.Total.Amount
+		calculatedTotal += shipping.Total.MinorUnits
 	}
 	
-	var feesTotal float64
 	for _, fee := range order.FeeLines {
-		feesTotal += fee.Total.Amount
+		calculatedTotal += fee.Total.MinorUnits
 	}
 	
-	var taxTotal float64
 	for _, tax := range order.TaxLines {
-		taxTotal += tax.TaxTotal.Amount
+		calculatedTotal += tax.TaxTotal.MinorUnits
 	}
 	
-	var discountTotal float64
 	for _, coupon := range order.CouponLines {
-		discountTotal += coupon.Discount.Amount
+		calculatedTotal -= coupon.Discount.MinorUnits
 	}
 	
-	calculatedTotal := itemsTotal + shippingTotal + feesTotal + taxTotal - discountTotal
-	
-	// Allow small floating point differences
-	tolerance := 0.01
-	if abs(calculatedTotal-order.Total.Amount) > tolerance {
+	calculated := entities.NewMoneyFromMinorUnits(calculatedTotal, order.Total.Currency)
+	if !calculated.Equals(order.Total) {
+		difference, _ := order.Total.Sub(calculated)
 		s.logger.Warn("Order total mismatch", map[string]interface{}{
 			"order_id": order.ID,
-			"calculated_total": calculatedTotal,
-			"order_total": order.Total.Amount,
-			"difference": abs(calculatedTotal - order.Total.Amount),
+			"calculated_total": calculated.Decimal(),
+			"order_total": order.Total.Decimal(),
+			"difference": difference.Decimal(),
 		})
 	}
 	
 	return nil
 }
 
-// DetermineOrderStatus determines the appropriate order status based on payment
+// DetermineOrderStatus determines the appropriate order status based on payment.
+// A status the order's state machine does not allow from its current status is
+// never returned; the order keeps its current status instead.
 func (s *OrderDomainService) DetermineOrderStatus(ctx context.Context, order *entities.Order, payment *entities.Payment) entities.OrderStatus {
-	if payment == nil {
-		return entities.StatusPending
-	}
-	
-	switch payment.Status {
-	case entities.PaymentStatusCompleted:
-		return entities.StatusProcessing
-	case entities.PaymentStatusFailed:
-		return entities.StatusFailed
-	case entities.PaymentStatusCancelled:
-		return entities.StatusCancelled
-	case entities.PaymentStatusRefunded:
-		return entities.StatusRefunded
-	default:
-		return entities.StatusPending
+	status := entities.StatusPending
+	if payment != nil {
+		switch payment.Status {
+		case entities.PaymentStatusCompleted:
+			status = entities.StatusProcessing
+		case entities.PaymentStatusFailed:
+			status = entities.StatusFailed
+		case entities.PaymentStatusCancelled:
+			status = entities.StatusCancelled
+		case entities.PaymentStatusRefunded:
+			status = entities.StatusRefunded
+		}
+	}
+
+	if order != nil && order.Status != "" && !order.Status.CanTransitionTo(status) {
+		s.logger.Warn("Payment status does not allow an order transition", map[string]interface{}{
+			"order_id": order.ID,
+			"status":   order.Status,
+			"target":   status,
+		})
+		return order.Status
 	}
+
+	return status
 }
 
-// abs returns the absolute value of a float64
-func abs(x float64) float64 {
-	if x < 0 {
-		return -x
+// TransitionOrder moves an order to a new status through its state machine,
+// recording the change in the order's status history
+func (s *OrderDomainService) TransitionOrder(ctx context.Context, order *entities.Order, next entities.OrderStatus, reason string) error {
+	from := order.Status
+	if err := order.TransitionTo(next, reason); err != nil {
+		s.logger.Warn("Order status transition rejected", map[string]interface{}{
+			"order_id": order.ID,
+			"from":     from,
+			"to":       next,
+			"reason":   reason,
+		})
+		return err
 	}
-	return x
-}
\ No newline at end of file
+
+	if from != next {
+		s.logger.Info("Order status transition", map[string]interface{}{
+			"order_id": order.ID,
+			"from":     from,
+			"to":       next,
+			"reason":   reason,
+		})
+	}
+
+	return nil
+}
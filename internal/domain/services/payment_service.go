@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/big"
 	"paypal-proxy/internal/domain/entities"
 	"paypal-proxy/internal/domain/interfaces"
+	"strconv"
 	"time"
 )
 
@@ -36,7 +38,7 @@ func (s *PaymentDomainService) CreatePaymentRequest(ctx context.Context, order *
 	}
 	
 	request := &entities.PaymentRequest{
-		OrderID:     string(rune(order.ID)),
+		OrderID:     strconv.Itoa(order.ID),
 		Amount:      order.Total,
 		Method:      entities.PaymentMethodPayPal,
 		ReturnURL:   returnURL,
@@ -52,7 +54,7 @@ func (s *PaymentDomainService) CreatePaymentRequest(ctx context.Context, order *
 	
 	s.logger.Info("Payment request created", map[string]interface{}{
 		"order_id":    order.ID,
-		"amount":      request.Amount.Amount,
+		"amount":      request.Amount.Decimal(),
 		"currency":    request.Amount.Currency,
 		"method":      request.Method,
 	})
@@ -71,16 +73,16 @@ func (s *PaymentDomainService) ProcessPaymentResult(ctx context.Context, payment
 	}
 	
 	// Validate payment matches order
-	if payment.OrderID != string(rune(order.ID)) {
+	if payment.OrderID != strconv.Itoa(order.ID) {
 		return errors.New("payment order ID does not match order")
 	}
 	
 	// Validate payment amount matches order total
-	if payment.Amount.Amount != order.Total.Amount {
+	if payment.Amount.MinorUnits != order.Total.MinorUnits {
 		s.logger.Warn("Payment amount mismatch", map[string]interface{}{
 			"order_id":        order.ID,
-			"payment_amount":  payment.Amount.Amount,
-			"order_amount":    order.Total.Amount,
+			"payment_amount":  payment.Amount.Decimal(),
+			"order_amount":    order.Total.Decimal(),
 		})
 	}
 	
@@ -93,12 +95,118 @@ func (s *PaymentDomainService) ProcessPaymentResult(ctx context.Context, payment
 		"payment_id":   payment.ID,
 		"order_id":     order.ID,
 		"status":       payment.Status,
-		"amount":       payment.Amount.Amount,
+		"amount":       payment.Amount.Decimal(),
 	})
 	
 	return nil
 }
 
+// VerifyCapturedAmount checks that a captured amount matches the expected
+// amount exactly, currency included
+func (s *PaymentDomainService) VerifyCapturedAmount(ctx context.Context, captured entities.Money, expected entities.Money) error {
+	if !captured.Equals(expected) {
+		s.logger.Warn("Captured amount does not match expected amount", map[string]interface{}{
+			"captured_amount":   captured.Decimal(),
+			"captured_currency": captured.Currency,
+			"expected_amount":   expected.Decimal(),
+			"expected_currency": expected.Currency,
+		})
+		return fmt.Errorf("captured %s does not match expected %s", captured.String(), expected.String())
+	}
+	
+	return nil
+}
+
+// ExpectedCapture returns the amount PayPal must capture for a proxy order
+// after checking that the proxy order charges the original order's total,
+// either directly or through the currency conversion recorded on it
+func (s *PaymentDomainService) ExpectedCapture(ctx context.Context, proxyOrder *entities.Order, order *entities.Order) (entities.Money, error) {
+	if proxyOrder == nil || order == nil {
+		return entities.Money{}, errors.New("order cannot be nil")
+	}
+	
+	conversion, converted := entities.CurrencyConversionFromMetaData(proxyOrder.MetaData)
+	if !converted {
+		if err := s.VerifyCapturedAmount(ctx, proxyOrder.Total, order.Total); err != nil {
+			return entities.Money{}, err
+		}
+		return order.Total, nil
+	}
+	
+	if !conversion.Original.Equals(order.Total) {
+		return entities.Money{}, fmt.Errorf("proxy order was converted from %s, order total is %s", conversion.Original.String(), order.Total.String())
+	}
+	
+	if !conversion.Converted.Equals(proxyOrder.Total) {
+		return entities.Money{}, fmt.Errorf("proxy order total %s does not match converted %s", proxyOrder.Total.String(), conversion.Converted.String())
+	}
+	
+	return proxyOrder.Total, nil
+}
+
+// RefundAmounts returns how much a refund returns on the original order and
+// how much it refunds on the capture. A nil requested amount, given in the
+// order currency, refunds everything still refundable. When the capture was
+// taken in another currency, the capture amount is scaled by the ratio of the
+// captured amount to the order total.
+func (s *PaymentDomainService) RefundAmounts(ctx context.Context, payment *entities.Payment, order *entities.Order, requested *entities.Money) (entities.Money, entities.Money, error) {
+	if payment == nil || order == nil {
+		return entities.Money{}, entities.Money{}, errors.New("payment and order are required")
+	}
+	
+	remaining := payment.RefundableAmount()
+	if !remaining.IsPositive() {
+		return entities.Money{}, entities.Money{}, fmt.Errorf("payment %s has nothing left to refund", payment.PaymentID)
+	}
+	
+	converted := !payment.Amount.SameCurrency(order.Total)
+	if converted && (!order.Total.IsPositive() || !payment.Amount.IsPositive()) {
+		return entities.Money{}, entities.Money{}, fmt.Errorf("cannot scale refund between %s and %s", order.Total.String(), payment.Amount.String())
+	}
+	
+	if requested == nil {
+		switch {
+		case !converted:
+			return remaining, remaining, nil
+		case payment.RefundedAmount().IsZero():
+			return order.Total, remaining, nil
+		default:
+			return scaleMoney(remaining, order.Total, payment.Amount), remaining, nil
+		}
+	}
+	
+	if !requested.SameCurrency(order.Total) {
+		return entities.Money{}, entities.Money{}, entities.NewValidationError("refund_amount", fmt.Sprintf("Refund currency %s does not match order currency %s", requested.Currency, order.Total.Currency))
+	}
+	
+	captureAmount := *requested
+	if converted {
+		captureAmount = scaleMoney(*requested, payment.Amount, order.Total)
+	}
+	if err := payment.CanRefund(captureAmount); err != nil {
+		return entities.Money{}, entities.Money{}, err
+	}
+	
+	return *requested, captureAmount, nil
+}
+
+// OrderRefundAmount returns how much a refund of captureAmount returns on the
+// original order, scaling it back when the capture was taken in another currency
+func (s *PaymentDomainService) OrderRefundAmount(ctx context.Context, payment *entities.Payment, order *entities.Order, captureAmount entities.Money) (entities.Money, error) {
+	if payment == nil || order == nil {
+		return entities.Money{}, errors.New("payment and order are required")
+	}
+	
+	if payment.Amount.SameCurrency(order.Total) {
+		return captureAmount, nil
+	}
+	if !order.Total.IsPositive() || !payment.Amount.IsPositive() {
+		return entities.Money{}, fmt.Errorf("cannot scale refund between %s and %s", order.Total.String(), payment.Amount.String())
+	}
+	
+	return scaleMoney(captureAmount, order.Total, payment.Amount), nil
+}
+
 // ValidateWebhookPayment validates a payment from webhook data
 func (s *PaymentDomainService) ValidateWebhookPayment(ctx context.Context, payment *entities.Payment, expectedOrderID string) error {
 	if payment == nil {
@@ -113,7 +221,7 @@ func (s *PaymentDomainService) ValidateWebhookPayment(ctx context.Context, payme
 		return errors.New("payment order ID does not match expected order ID")
 	}
 	
-	if payment.Amount.Amount <= 0 {
+	if !payment.Amount.IsPositive() {
 		return errors.New("payment amount must be greater than zero")
 	}
 	
@@ -129,7 +237,7 @@ func (s *PaymentDomainService) ValidateWebhookPayment(ctx context.Context, payme
 		"payment_id": payment.PaymentID,
 		"order_id":   payment.OrderID,
 		"status":     payment.Status,
-		"amount":     payment.Amount.Amount,
+		"amount":     payment.Amount.Decimal(),
 	})
 	
 	return nil
@@ -146,15 +254,17 @@ func (s *PaymentDomainService) CreatePaymentRecord(ctx context.Context, orderID,
 		Status:        status,
 		Method:        entities.PaymentMethodPayPal,
 		TransactionID: paymentID, // Use PayPal payment ID as transaction ID
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
 		ProcessedAt:   time.Now(),
-		Metadata: map[string]interface{}{
-			"payment_provider": "paypal",
-			"processed_at":     time.Now().Format(time.RFC3339),
+		MetaData: []entities.MetaData{
+			{Key: "payment_provider", Value: "paypal"},
+			{Key: "processed_at", Value: time.Now().Format(time.RFC3339)},
 		},
 	}
 	
 	if payerID != "" {
-		payment.Metadata["payer_id"] = payerID
+		payment.MetaData = append(payment.MetaData, entities.MetaData{Key: "payer_id", Value: payerID})
 	}
 	
 	s.logger.Info("Payment record created", map[string]interface{}{
@@ -180,4 +290,10 @@ func (s *PaymentDomainService) generatePaymentDescription(order *entities.Order)
 func generatePaymentID() string {
 	// In a real implementation, you might use UUID or another unique ID generator
 	return fmt.Sprintf("pay_%d", time.Now().UnixNano())
-}
\ No newline at end of file
+}
+
+// scaleMoney converts amount into to's currency by the ratio of to over from
+func scaleMoney(amount, to, from entities.Money) entities.Money {
+	scaled := amount.MultiplyRat(big.NewRat(to.MinorUnits, from.MinorUnits), entities.RoundHalfUp)
+	return entities.NewMoneyFromMinorUnits(scaled.MinorUnits, to.Currency)
+}
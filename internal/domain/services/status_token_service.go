@@ -0,0 +1,127 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/interfaces"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status token errors
+var (
+	ErrInvalidStatusToken = errors.New("invalid status token")
+	ErrStatusTokenExpired = errors.New("status token expired")
+)
+
+// statusTokenContext separates status token signatures from other uses of the secret
+const statusTokenContext = "order-status"
+
+// keyTagSize is the number of MAC bytes a token keeps to bind it to the order key
+const keyTagSize = 16
+
+// StatusTokenService signs and checks the tokens that let a customer poll
+// their order's payment status. A token reads orderID.expiry.keyTag.signature:
+// the key tag is a MAC of the MagicSpore order key, so a token cannot be
+// derived from the order ID alone and stops verifying once the key changes,
+// and the signature covers the other fields, so forged or expired tokens are
+// rejected before the order is looked up. A nil service issues no tokens.
+type StatusTokenService struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewStatusTokenService creates a status token service. It returns nil when
+// no secret is configured.
+func NewStatusTokenService(config interfaces.StatusTokenConfig) *StatusTokenService {
+	if config.Secret == "" {
+		return nil
+	}
+	return &StatusTokenService{
+		secret: []byte(config.Secret),
+		ttl:    config.TTL,
+		now:    time.Now,
+	}
+}
+
+// Issue returns a token for an order's status, or an empty string from a nil service
+func (s *StatusTokenService) Issue(orderID, orderKey string) string {
+	if s == nil || orderID == "" {
+		return ""
+	}
+	expiresAt := s.now().Add(s.ttl).Unix()
+	keyTag := s.keyTag(orderID, orderKey)
+	return fmt.Sprintf("%s.%d.%s.%s", orderID, expiresAt, keyTag, s.sign(orderID, keyTag, expiresAt))
+}
+
+// OrderID returns the order a validly signed, unexpired token was issued for.
+// The order key is not checked until Verify, which needs the order.
+func (s *StatusTokenService) OrderID(token string) (string, error) {
+	orderID, _, err := s.check(token)
+	return orderID, err
+}
+
+// Verify checks that token was issued by this service for the order and its key
+func (s *StatusTokenService) Verify(token, orderID, orderKey string) error {
+	tokenOrderID, keyTag, err := s.check(token)
+	if err != nil {
+		return err
+	}
+	if tokenOrderID != orderID {
+		return ErrInvalidStatusToken
+	}
+	if !hmac.Equal([]byte(keyTag), []byte(s.keyTag(orderID, orderKey))) {
+		return ErrInvalidStatusToken
+	}
+	return nil
+}
+
+// check verifies a token's signature and expiry and returns its order ID and key tag
+func (s *StatusTokenService) check(token string) (string, string, error) {
+	if s == nil {
+		return "", "", ErrInvalidStatusToken
+	}
+	orderID, expiresAt, keyTag, signature, err := parseStatusToken(token)
+	if err != nil {
+		return "", "", err
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(orderID, keyTag, expiresAt))) {
+		return "", "", ErrInvalidStatusToken
+	}
+	if s.now().Unix() > expiresAt {
+		return "", "", ErrStatusTokenExpired
+	}
+	return orderID, keyTag, nil
+}
+
+// keyTag returns the base64url HMAC binding a token to the order key
+func (s *StatusTokenService) keyTag(orderID, orderKey string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s-key\n%s\n%s", statusTokenContext, orderID, orderKey)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:keyTagSize])
+}
+
+// sign returns the base64url HMAC of a token's fields
+func (s *StatusTokenService) sign(orderID, keyTag string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", statusTokenContext, orderID, keyTag, expiresAt)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseStatusToken splits a token into its order ID, expiry, key tag and signature
+func parseStatusToken(token string) (string, int64, string, string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 4 || parts[0] == "" || parts[2] == "" || parts[3] == "" {
+		return "", 0, "", "", ErrInvalidStatusToken
+	}
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, "", "", ErrInvalidStatusToken
+	}
+	return parts[0], expiresAt, parts[2], parts[3], nil
+}
@@ -6,17 +6,28 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"paypal-proxy/internal/domain/entities"
 	"paypal-proxy/internal/domain/interfaces"
 )
 
 // Config represents the application configuration
 type Config struct {
-	Server ServerConfig
-	Magic  WooCommerceConfig
-	OITAM  WooCommerceConfig
-	PayPal PayPalConfig
-	Cache  CacheConfig
-	DB     DatabaseConfig
+	Server  ServerConfig
+	Magic   WooCommerceConfig
+	OITAM   WooCommerceConfig
+	PayPal  PayPalConfig
+	Cache   CacheConfig
+	DB      DatabaseConfig
+	Proxy   ProxyConfig
+	Webhook  WebhookConfig
+	Reconcile ReconcileConfig
+	Sweep    SweepConfig
+	Outbox   OutboxConfig
+	Currency CurrencyConfig
+	Anonymization AnonymizationConfig
+	APIAuth  APIAuthConfig
+	StatusToken StatusTokenConfig
+	Mock     MockConfig
 }
 
 // ServerConfig represents server configuration
@@ -39,11 +50,15 @@ type WooCommerceConfig struct {
 
 // PayPalConfig represents PayPal configuration
 type PayPalConfig struct {
-	ClientID     string
-	ClientSecret string
-	Environment  string // sandbox or live
-	WebhookID    string
-	Timeout      time.Duration
+	ClientID            string
+	ClientSecret        string
+	Environment         string // sandbox or live
+	WebhookID           string
+	WebhookVerification string // certificate, api or disabled
+	APIBase             string // REST API base URL, derived from Environment when unset
+	Timeout             time.Duration
+	RetryAttempts       int
+	RetryDelay          time.Duration
 }
 
 // CacheConfig represents cache configuration
@@ -55,12 +70,126 @@ type CacheConfig struct {
 
 // DatabaseConfig represents database configuration
 type DatabaseConfig struct {
+	Driver           string // memory, sqlite or postgres
 	ConnectionString string
+	SQLitePath       string
 	MaxConnections   int
 	Timeout         time.Duration
 	Enabled         bool
+	AutoMigrate     bool
 }
 
+// ProxyConfig represents proxy order configuration
+type ProxyConfig struct {
+	OrderTTL           time.Duration // how long a proxy order accepts payment
+	VerifyPollInterval time.Duration // delay between payment re-verifications
+	VerifyPollAttempts int           // re-verifications after an unconfirmed return
+}
+
+// WebhookConfig represents asynchronous webhook processing
+type WebhookConfig struct {
+	RetryEnabled   bool          // queue webhooks and process them in the background with retries
+	Workers        int           // concurrent queue workers
+	MaxAttempts    int           // attempts before an event is dead-lettered
+	RetryBaseDelay time.Duration // delay before the first retry, doubled per attempt
+	RetryMaxDelay  time.Duration // upper bound for the retry delay
+	PollInterval   time.Duration // how often workers look for due events
+}
+
+// ReconcileConfig represents the background reconciliation of proxy orders
+type ReconcileConfig struct {
+	Enabled   bool          // periodically reconcile active proxy orders
+	Interval  time.Duration // delay between reconciliation runs
+	MinAge    time.Duration // mappings younger than this are left to the return and webhook flows
+	BatchSize int           // mappings checked per run
+}
+
+// SweepConfig represents the sweeping of abandoned proxy orders
+type SweepConfig struct {
+	Enabled           bool          // periodically close proxy orders that were never paid
+	Interval          time.Duration // delay between sweeps
+	TTL               time.Duration // age after which an unpaid proxy order is abandoned
+	Action            string        // cancel or trash the abandoned OITAM order
+	RestoreMagicOrder bool          // move the MagicSpore order back to pending with a note
+	BatchSize         int           // mappings checked per sweep
+}
+
+// OutboxConfig represents the durable delivery of MagicSpore order updates
+type OutboxConfig struct {
+	Enabled        bool          // record MagicSpore order updates before sending them
+	PollInterval   time.Duration // delay between dispatcher polls when nothing is due
+	MaxAttempts    int           // deliveries tried before an entry waits for an operator
+	RetryBaseDelay time.Duration // delay before the first redelivery
+	RetryMaxDelay  time.Duration // upper bound on the delay between redeliveries
+	StuckAfter     time.Duration // age after which an undelivered entry is reported as stuck
+}
+
+// CurrencyConfig represents conversion of proxy orders into the PayPal settlement currency
+type CurrencyConfig struct {
+	SettlementCurrency string        // currency of OITAM proxy orders; empty keeps the store currency
+	RateProvider       string        // static or remote
+	StaticRates        string        // comma separated FROM/TO=rate pairs for the static provider
+	RatesFile          string        // JSON file of FROM/TO rates for the static provider
+	RemoteURL          string        // rate API base URL for the remote provider
+	CacheTTL           time.Duration // how long remote rates are reused
+}
+
+// AnonymizationConfig represents the anonymization policy for proxy orders
+type AnonymizationConfig struct {
+	PolicyFile string // JSON policy overriding the default rules
+	HashSalt   string // secret key for hashed fields
+	policy     *entities.AnonymizationPolicy
+}
+
+// APIAuthConfig represents authentication of the /api/v1 routes
+type APIAuthConfig struct {
+	Enabled     bool   // require credentials holding each route's scope
+	APIKeys     string // comma separated key=scope|scope entries
+	JWTSecret   string // HMAC key of bearer tokens; empty disables them
+	JWTIssuer   string // required iss claim of bearer tokens, if set
+	JWTAudience string // required aud claim of bearer tokens, if set
+}
+
+// StatusTokenConfig represents the signed tokens the storefront uses to poll payment status
+type StatusTokenConfig struct {
+	Secret string        // HMAC key of status tokens; empty disables them
+	TTL    time.Duration // how long an issued token stays valid
+}
+
+// MockConfig represents local simulators for offline testing
+type MockConfig struct {
+	PayPal             bool   // serve a PayPal simulator and point the gateway at it
+	PayPalAddress      string // listen address of the PayPal simulator
+	WooCommerce        bool   // serve MagicSpore and OITAM store simulators and point the repository at them
+	WooCommerceAddress string // listen address of the WooCommerce simulators
+}
+
+// Supported webhook verification modes
+const (
+	WebhookVerificationCertificate = "certificate"
+	WebhookVerificationAPI         = "api"
+	WebhookVerificationDisabled    = "disabled"
+)
+
+// Supported exchange rate providers
+const (
+	ExchangeRateProviderStatic = "static"
+	ExchangeRateProviderRemote = "remote"
+)
+
+// Supported abandoned proxy order sweep actions
+const (
+	SweepActionCancel = "cancel"
+	SweepActionTrash  = "trash"
+)
+
+// Supported storage drivers
+const (
+	DatabaseDriverMemory   = "memory"
+	DatabaseDriverSQLite   = "sqlite"
+	DatabaseDriverPostgres = "postgres"
+)
+
 // NewConfig creates a new configuration from environment variables
 func NewConfig() *Config {
 	return &Config{
@@ -86,11 +215,15 @@ func NewConfig() *Config {
 			RetryAttempts:  getIntEnv("OITAM_RETRY_ATTEMPTS", 3),
 		},
 		PayPal: PayPalConfig{
-			ClientID:     getEnv("PAYPAL_CLIENT_ID", ""),
-			ClientSecret: getEnv("PAYPAL_CLIENT_SECRET", ""),
-			Environment:  getEnv("PAYPAL_ENVIRONMENT", "sandbox"),
-			WebhookID:    getEnv("PAYPAL_WEBHOOK_ID", ""),
-			Timeout:      getDurationEnv("PAYPAL_TIMEOUT", 30*time.Second),
+			ClientID:            getEnv("PAYPAL_CLIENT_ID", ""),
+			ClientSecret:        getEnv("PAYPAL_CLIENT_SECRET", ""),
+			Environment:         getEnv("PAYPAL_ENVIRONMENT", "sandbox"),
+			WebhookID:           getEnv("PAYPAL_WEBHOOK_ID", ""),
+			WebhookVerification: getEnv("PAYPAL_WEBHOOK_VERIFICATION", WebhookVerificationCertificate),
+			APIBase:             getEnv("PAYPAL_API_BASE", ""),
+			Timeout:             getDurationEnv("PAYPAL_TIMEOUT", 30*time.Second),
+			RetryAttempts:       getIntEnv("PAYPAL_RETRY_ATTEMPTS", 3),
+			RetryDelay:          getDurationEnv("PAYPAL_RETRY_DELAY", time.Second),
 		},
 		Cache: CacheConfig{
 			RedisURL:    getEnv("REDIS_URL", ""),
@@ -98,10 +231,77 @@ func NewConfig() *Config {
 			Enabled:     getBoolEnv("CACHE_ENABLED", false),
 		},
 		DB: DatabaseConfig{
+			Driver:           getDatabaseDriver(),
 			ConnectionString: getEnv("DATABASE_URL", ""),
+			SQLitePath:       getEnv("SQLITE_PATH", "paypal-proxy.db"),
 			MaxConnections:   getIntEnv("DB_MAX_CONNECTIONS", 10),
 			Timeout:         getDurationEnv("DB_TIMEOUT", 30*time.Second),
 			Enabled:         getBoolEnv("DATABASE_ENABLED", false),
+			AutoMigrate:     getBoolEnv("DB_AUTO_MIGRATE", true),
+		},
+		Proxy: ProxyConfig{
+			OrderTTL:           getDurationEnv("PROXY_ORDER_TTL", 24*time.Hour),
+			VerifyPollInterval: getDurationEnv("PAYMENT_VERIFY_POLL_INTERVAL", 30*time.Second),
+			VerifyPollAttempts: getIntEnv("PAYMENT_VERIFY_POLL_ATTEMPTS", 10),
+		},
+		Webhook: WebhookConfig{
+			RetryEnabled:   getBoolEnv("ENABLE_WEBHOOK_RETRY", false),
+			Workers:        getIntEnv("WEBHOOK_WORKERS", 4),
+			MaxAttempts:    getIntEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+			RetryBaseDelay: getDurationEnv("WEBHOOK_RETRY_BASE_DELAY", 30*time.Second),
+			RetryMaxDelay:  getDurationEnv("WEBHOOK_RETRY_MAX_DELAY", time.Hour),
+			PollInterval:   getDurationEnv("WEBHOOK_QUEUE_POLL_INTERVAL", time.Second),
+		},
+		Reconcile: ReconcileConfig{
+			Enabled:   getBoolEnv("ENABLE_RECONCILIATION", false),
+			Interval:  getDurationEnv("RECONCILE_INTERVAL", 15*time.Minute),
+			MinAge:    getDurationEnv("RECONCILE_MIN_AGE", 15*time.Minute),
+			BatchSize: getIntEnv("RECONCILE_BATCH_SIZE", 100),
+		},
+		Sweep: SweepConfig{
+			Enabled:           getBoolEnv("ENABLE_PROXY_ORDER_SWEEP", false),
+			Interval:          getDurationEnv("PROXY_SWEEP_INTERVAL", time.Hour),
+			TTL:               getDurationEnv("PROXY_SWEEP_TTL", 48*time.Hour),
+			Action:            strings.ToLower(getEnv("PROXY_SWEEP_ACTION", SweepActionCancel)),
+			RestoreMagicOrder: getBoolEnv("PROXY_SWEEP_RESTORE_MAGIC_ORDER", false),
+			BatchSize:         getIntEnv("PROXY_SWEEP_BATCH_SIZE", 100),
+		},
+		Outbox: OutboxConfig{
+			Enabled:        getBoolEnv("ENABLE_ORDER_OUTBOX", false),
+			PollInterval:   getDurationEnv("OUTBOX_POLL_INTERVAL", time.Second),
+			MaxAttempts:    getIntEnv("OUTBOX_MAX_ATTEMPTS", 20),
+			RetryBaseDelay: getDurationEnv("OUTBOX_RETRY_BASE_DELAY", 10*time.Second),
+			RetryMaxDelay:  getDurationEnv("OUTBOX_RETRY_MAX_DELAY", 30*time.Minute),
+			StuckAfter:     getDurationEnv("OUTBOX_STUCK_AFTER", 15*time.Minute),
+		},
+		Currency: CurrencyConfig{
+			SettlementCurrency: strings.ToUpper(getEnv("SETTLEMENT_CURRENCY", "")),
+			RateProvider:       getEnv("EXCHANGE_RATE_PROVIDER", ExchangeRateProviderStatic),
+			StaticRates:        getEnv("EXCHANGE_RATES", ""),
+			RatesFile:          getEnv("EXCHANGE_RATES_FILE", ""),
+			RemoteURL:          getEnv("EXCHANGE_RATE_API_URL", ""),
+			CacheTTL:           getDurationEnv("EXCHANGE_RATE_CACHE_TTL", time.Hour),
+		},
+		Anonymization: AnonymizationConfig{
+			PolicyFile: getEnv("ANONYMIZATION_POLICY_FILE", ""),
+			HashSalt:   getEnv("ANONYMIZATION_HASH_SALT", ""),
+		},
+		APIAuth: APIAuthConfig{
+			Enabled:     getBoolEnv("API_AUTH_ENABLED", true),
+			APIKeys:     getEnv("API_KEYS", ""),
+			JWTSecret:   getEnv("JWT_SECRET_KEY", ""),
+			JWTIssuer:   getEnv("JWT_ISSUER", ""),
+			JWTAudience: getEnv("JWT_AUDIENCE", ""),
+		},
+		StatusToken: StatusTokenConfig{
+			Secret: getEnv("STATUS_TOKEN_SECRET", ""),
+			TTL:    getDurationEnv("STATUS_TOKEN_TTL", 24*time.Hour),
+		},
+		Mock: MockConfig{
+			PayPal:             getBoolEnv("MOCK_PAYPAL", false),
+			PayPalAddress:      getEnv("MOCK_PAYPAL_ADDR", ":8091"),
+			WooCommerce:        getBoolEnv("MOCK_WOOCOMMERCE", false),
+			WooCommerceAddress: getEnv("MOCK_WOOCOMMERCE_ADDR", ":8092"),
 		},
 	}
 }
@@ -119,30 +319,33 @@ func (c *Config) Validate() error {
 		errors = append(errors, "BASE_URL is required")
 	}
 
-	// Validate Magic (MagicSpore) config
-	if c.Magic.URL == "" {
-		errors = append(errors, "MAGIC_SITE_URL is required")
-	}
+	// Store credentials are not needed when the stores are simulated
+	if !c.Mock.WooCommerce {
+		// Validate Magic (MagicSpore) config
+		if c.Magic.URL == "" {
+			errors = append(errors, "MAGIC_SITE_URL is required")
+		}
 
-	if c.Magic.ConsumerKey == "" {
-		errors = append(errors, "MAGIC_CONSUMER_KEY is required")
-	}
+		if c.Magic.ConsumerKey == "" {
+			errors = append(errors, "MAGIC_CONSUMER_KEY is required")
+		}
 
-	if c.Magic.ConsumerSecret == "" {
-		errors = append(errors, "MAGIC_CONSUMER_SECRET is required")
-	}
+		if c.Magic.ConsumerSecret == "" {
+			errors = append(errors, "MAGIC_CONSUMER_SECRET is required")
+		}
 
-	// Validate OITAM config
-	if c.OITAM.URL == "" {
-		errors = append(errors, "OITAM_SITE_URL is required")
-	}
+		// Validate OITAM config
+		if c.OITAM.URL == "" {
+			errors = append(errors, "OITAM_SITE_URL is required")
+		}
 
-	if c.OITAM.ConsumerKey == "" {
-		errors = append(errors, "OITAM_CONSUMER_KEY is required")
-	}
+		if c.OITAM.ConsumerKey == "" {
+			errors = append(errors, "OITAM_CONSUMER_KEY is required")
+		}
 
-	if c.OITAM.ConsumerSecret == "" {
-		errors = append(errors, "OITAM_CONSUMER_SECRET is required")
+		if c.OITAM.ConsumerSecret == "" {
+			errors = append(errors, "OITAM_CONSUMER_SECRET is required")
+		}
 	}
 
 	// PayPal validation (optional for development)
@@ -154,6 +357,26 @@ func (c *Config) Validate() error {
 		if c.PayPal.ClientSecret == "" {
 			errors = append(errors, "PAYPAL_CLIENT_SECRET is required for production")
 		}
+
+		// Webhooks drive payment state, so they must be authenticated
+		if c.PayPal.WebhookID == "" {
+			errors = append(errors, "PAYPAL_WEBHOOK_ID is required for production")
+		}
+
+		if c.PayPal.WebhookVerification == WebhookVerificationDisabled {
+			errors = append(errors, "PAYPAL_WEBHOOK_VERIFICATION must not be disabled in production")
+		}
+
+		// The API serves customer details and moves money
+		if !c.APIAuth.Enabled {
+			errors = append(errors, "API_AUTH_ENABLED must not be disabled in production")
+		}
+	}
+
+	switch c.PayPal.WebhookVerification {
+	case WebhookVerificationCertificate, WebhookVerificationAPI, WebhookVerificationDisabled:
+	default:
+		errors = append(errors, "PAYPAL_WEBHOOK_VERIFICATION must be certificate, api or disabled")
 	}
 
 	// Validate PayPal environment
@@ -161,6 +384,132 @@ func (c *Config) Validate() error {
 		errors = append(errors, "PAYPAL_ENVIRONMENT must be 'sandbox' or 'live'")
 	}
 
+	// Validate database config
+	switch c.DB.Driver {
+	case DatabaseDriverMemory:
+	case DatabaseDriverSQLite:
+		if c.DB.SQLitePath == "" {
+			errors = append(errors, "SQLITE_PATH is required for the sqlite driver")
+		}
+	case DatabaseDriverPostgres:
+		if c.DB.ConnectionString == "" {
+			errors = append(errors, "DATABASE_URL is required for the postgres driver")
+		}
+	default:
+		errors = append(errors, "DATABASE_DRIVER must be 'memory', 'sqlite' or 'postgres'")
+	}
+
+	if c.Proxy.OrderTTL <= 0 {
+		errors = append(errors, "PROXY_ORDER_TTL must be positive")
+	}
+
+	if c.Proxy.VerifyPollAttempts > 0 && c.Proxy.VerifyPollInterval <= 0 {
+		errors = append(errors, "PAYMENT_VERIFY_POLL_INTERVAL must be positive")
+	}
+
+	if c.Webhook.RetryEnabled {
+		if c.Webhook.Workers <= 0 {
+			errors = append(errors, "WEBHOOK_WORKERS must be positive")
+		}
+		if c.Webhook.MaxAttempts <= 0 {
+			errors = append(errors, "WEBHOOK_MAX_ATTEMPTS must be positive")
+		}
+		if c.Webhook.RetryBaseDelay <= 0 || c.Webhook.RetryMaxDelay < c.Webhook.RetryBaseDelay {
+			errors = append(errors, "WEBHOOK_RETRY_BASE_DELAY must be positive and not above WEBHOOK_RETRY_MAX_DELAY")
+		}
+		if c.Webhook.PollInterval <= 0 {
+			errors = append(errors, "WEBHOOK_QUEUE_POLL_INTERVAL must be positive")
+		}
+	}
+
+	if c.Reconcile.Enabled {
+		if c.Reconcile.Interval <= 0 {
+			errors = append(errors, "RECONCILE_INTERVAL must be positive")
+		}
+		if c.Reconcile.MinAge < 0 {
+			errors = append(errors, "RECONCILE_MIN_AGE must not be negative")
+		}
+		if c.Reconcile.BatchSize <= 0 {
+			errors = append(errors, "RECONCILE_BATCH_SIZE must be positive")
+		}
+	}
+
+	if c.Sweep.Enabled {
+		if c.Sweep.Interval <= 0 {
+			errors = append(errors, "PROXY_SWEEP_INTERVAL must be positive")
+		}
+		if c.Sweep.TTL < c.Proxy.OrderTTL {
+			errors = append(errors, "PROXY_SWEEP_TTL must not be below PROXY_ORDER_TTL")
+		}
+		if c.Sweep.Action != SweepActionCancel && c.Sweep.Action != SweepActionTrash {
+			errors = append(errors, "PROXY_SWEEP_ACTION must be 'cancel' or 'trash'")
+		}
+		if c.Sweep.BatchSize <= 0 {
+			errors = append(errors, "PROXY_SWEEP_BATCH_SIZE must be positive")
+		}
+	}
+
+	if c.Outbox.Enabled {
+		if c.Outbox.PollInterval <= 0 {
+			errors = append(errors, "OUTBOX_POLL_INTERVAL must be positive")
+		}
+		if c.Outbox.MaxAttempts <= 0 {
+			errors = append(errors, "OUTBOX_MAX_ATTEMPTS must be positive")
+		}
+		if c.Outbox.RetryBaseDelay <= 0 || c.Outbox.RetryMaxDelay < c.Outbox.RetryBaseDelay {
+			errors = append(errors, "OUTBOX_RETRY_BASE_DELAY must be positive and not above OUTBOX_RETRY_MAX_DELAY")
+		}
+		if c.Outbox.StuckAfter <= 0 {
+			errors = append(errors, "OUTBOX_STUCK_AFTER must be positive")
+		}
+	}
+
+	if c.APIAuth.Enabled && c.APIAuth.APIKeys == "" && c.APIAuth.JWTSecret == "" {
+		errors = append(errors, "API_KEYS or JWT_SECRET_KEY is required when API_AUTH_ENABLED is set")
+	}
+
+	if c.APIAuth.JWTSecret != "" && len(c.APIAuth.JWTSecret) < 32 {
+		errors = append(errors, "JWT_SECRET_KEY must be at least 32 characters")
+	}
+
+	if c.StatusToken.Secret != "" {
+		if len(c.StatusToken.Secret) < 32 {
+			errors = append(errors, "STATUS_TOKEN_SECRET must be at least 32 characters")
+		}
+		if c.StatusToken.TTL <= 0 {
+			errors = append(errors, "STATUS_TOKEN_TTL must be positive")
+		}
+	}
+
+	if c.Currency.SettlementCurrency != "" {
+		if !entities.IsKnownCurrency(c.Currency.SettlementCurrency) {
+			errors = append(errors, "SETTLEMENT_CURRENCY must be an ISO 4217 currency code")
+		}
+		switch c.Currency.RateProvider {
+		case ExchangeRateProviderStatic:
+			if c.Currency.StaticRates == "" && c.Currency.RatesFile == "" {
+				errors = append(errors, "EXCHANGE_RATES or EXCHANGE_RATES_FILE is required for the static rate provider")
+			}
+		case ExchangeRateProviderRemote:
+			if c.Currency.RemoteURL == "" {
+				errors = append(errors, "EXCHANGE_RATE_API_URL is required for the remote rate provider")
+			}
+			if c.Currency.CacheTTL <= 0 {
+				errors = append(errors, "EXCHANGE_RATE_CACHE_TTL must be positive")
+			}
+		default:
+			errors = append(errors, "EXCHANGE_RATE_PROVIDER must be 'static' or 'remote'")
+		}
+	}
+
+	if c.Mock.PayPal && c.Server.Environment == "production" {
+		errors = append(errors, "MOCK_PAYPAL must not be enabled in production")
+	}
+
+	if c.Mock.WooCommerce && c.Server.Environment == "production" {
+		errors = append(errors, "MOCK_WOOCOMMERCE must not be enabled in production")
+	}
+
 	if len(errors) > 0 {
 		return fmt.Errorf("configuration validation failed: %s", strings.Join(errors, ", "))
 	}
@@ -210,6 +559,22 @@ func (c *Config) GetPayPalConfig() PayPalConfig {
 	return c.PayPal
 }
 
+// GetAPIBase returns the PayPal REST API base URL for the configured environment
+func (p PayPalConfig) GetAPIBase() string {
+	if p.APIBase != "" {
+		return p.APIBase
+	}
+	if p.Environment == "live" {
+		return "https://api-m.paypal.com"
+	}
+	return "https://api-m.sandbox.paypal.com"
+}
+
+// HasCredentials checks if PayPal REST credentials are configured
+func (p PayPalConfig) HasCredentials() bool {
+	return p.ClientID != "" && p.ClientSecret != ""
+}
+
 // GetCacheConfig returns cache configuration
 func (c *Config) GetCacheConfig() CacheConfig {
 	return c.Cache
@@ -235,6 +600,121 @@ func (c *Config) GetWebhookSecret() string {
 	return getEnv("WEBHOOK_SECRET", "default-webhook-secret")
 }
 
+// GetProxyOrderTTL returns how long a proxy order accepts payment
+func (c *Config) GetProxyOrderTTL() time.Duration {
+	return c.Proxy.OrderTTL
+}
+
+// GetPaymentVerificationConfig returns polling settings for unconfirmed returns
+func (c *Config) GetPaymentVerificationConfig() interfaces.PaymentVerificationConfig {
+	return interfaces.PaymentVerificationConfig{
+		PollInterval: c.Proxy.VerifyPollInterval,
+		PollAttempts: c.Proxy.VerifyPollAttempts,
+	}
+}
+
+// GetWebhookQueueConfig returns asynchronous webhook processing settings
+func (c *Config) GetWebhookQueueConfig() interfaces.WebhookQueueConfig {
+	return interfaces.WebhookQueueConfig{
+		Enabled:        c.Webhook.RetryEnabled,
+		Workers:        c.Webhook.Workers,
+		MaxAttempts:    c.Webhook.MaxAttempts,
+		RetryBaseDelay: c.Webhook.RetryBaseDelay,
+		RetryMaxDelay:  c.Webhook.RetryMaxDelay,
+		PollInterval:   c.Webhook.PollInterval,
+	}
+}
+
+// GetReconciliationConfig returns background reconciliation settings
+func (c *Config) GetReconciliationConfig() interfaces.ReconciliationConfig {
+	return interfaces.ReconciliationConfig{
+		Enabled:   c.Reconcile.Enabled,
+		Interval:  c.Reconcile.Interval,
+		MinAge:    c.Reconcile.MinAge,
+		BatchSize: c.Reconcile.BatchSize,
+	}
+}
+
+// GetSweepConfig returns abandoned proxy order sweeping settings
+func (c *Config) GetSweepConfig() interfaces.SweepConfig {
+	return interfaces.SweepConfig{
+		Enabled:           c.Sweep.Enabled,
+		Interval:          c.Sweep.Interval,
+		TTL:               c.Sweep.TTL,
+		Trash:             c.Sweep.Action == SweepActionTrash,
+		RestoreMagicOrder: c.Sweep.RestoreMagicOrder,
+		BatchSize:         c.Sweep.BatchSize,
+	}
+}
+
+// GetOutboxConfig returns durable MagicSpore order update settings
+func (c *Config) GetOutboxConfig() interfaces.OutboxConfig {
+	return interfaces.OutboxConfig{
+		Enabled:        c.Outbox.Enabled,
+		PollInterval:   c.Outbox.PollInterval,
+		MaxAttempts:    c.Outbox.MaxAttempts,
+		RetryBaseDelay: c.Outbox.RetryBaseDelay,
+		RetryMaxDelay:  c.Outbox.RetryMaxDelay,
+		StuckAfter:     c.Outbox.StuckAfter,
+	}
+}
+
+// GetSettlementCurrency returns the currency of OITAM proxy orders
+func (c *Config) GetSettlementCurrency() string {
+	return c.Currency.SettlementCurrency
+}
+
+// GetCurrencyConfig returns currency conversion configuration
+func (c *Config) GetCurrencyConfig() CurrencyConfig {
+	return c.Currency
+}
+
+// GetStatusTokenConfig returns storefront status token settings
+func (c *Config) GetStatusTokenConfig() interfaces.StatusTokenConfig {
+	return interfaces.StatusTokenConfig{
+		Secret: c.StatusToken.Secret,
+		TTL:    c.StatusToken.TTL,
+	}
+}
+
+// GetAPIAuthConfig returns API authentication configuration
+func (c *Config) GetAPIAuthConfig() APIAuthConfig {
+	return c.APIAuth
+}
+
+// LoadAnonymizationPolicy reads and validates the anonymization policy file.
+// Without a file the default policy applies.
+func (c *Config) LoadAnonymizationPolicy() error {
+	policy := entities.DefaultAnonymizationPolicy()
+	if c.Anonymization.PolicyFile != "" {
+		data, err := os.ReadFile(c.Anonymization.PolicyFile)
+		if err != nil {
+			return fmt.Errorf("failed to read anonymization policy: %w", err)
+		}
+		if policy, err = entities.ParseAnonymizationPolicy(data); err != nil {
+			return err
+		}
+	}
+	policy.HashSalt = c.Anonymization.HashSalt
+
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid anonymization policy: %w", err)
+	}
+
+	c.Anonymization.policy = policy
+	return nil
+}
+
+// GetAnonymizationPolicy returns the policy applied to proxy orders
+func (c *Config) GetAnonymizationPolicy() *entities.AnonymizationPolicy {
+	if c.Anonymization.policy == nil {
+		policy := entities.DefaultAnonymizationPolicy()
+		policy.HashSalt = c.Anonymization.HashSalt
+		return policy
+	}
+	return c.Anonymization.policy
+}
+
 // GetEncryptionKey returns the encryption key for sensitive data
 func (c *Config) GetEncryptionKey() string {
 	return getEnv("ENCRYPTION_KEY", "default-encryption-key-change-me")
@@ -291,6 +771,18 @@ func getBoolEnv(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getDatabaseDriver resolves the storage driver. DATABASE_ENABLED=true without
+// an explicit DATABASE_DRIVER keeps selecting PostgreSQL.
+func getDatabaseDriver() string {
+	if driver := os.Getenv("DATABASE_DRIVER"); driver != "" {
+		return strings.ToLower(driver)
+	}
+	if getBoolEnv("DATABASE_ENABLED", false) {
+		return DatabaseDriverPostgres
+	}
+	return DatabaseDriverMemory
+}
+
 // getDurationEnv gets a duration environment variable with a default value
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
@@ -305,7 +797,7 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 func (c *Config) GetCORSConfig() map[string]interface{} {
 	allowedOrigins := getEnv("CORS_ALLOWED_ORIGINS", "*")
 	allowedMethods := getEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,DELETE,OPTIONS")
-	allowedHeaders := getEnv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization,X-Requested-With")
+	allowedHeaders := getEnv("CORS_ALLOWED_HEADERS", "Content-Type,Authorization,X-API-Key,X-Requested-With")
 
 	return map[string]interface{}{
 		"allowed_origins":    strings.Split(allowedOrigins, ","),
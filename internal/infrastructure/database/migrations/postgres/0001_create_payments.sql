@@ -0,0 +1,28 @@
+-- Payment records created by the return, cancel and webhook flows
+CREATE TABLE IF NOT EXISTS payments (
+    id              VARCHAR(64)   PRIMARY KEY,
+    order_id        VARCHAR(64)   NOT NULL,
+    payment_id      VARCHAR(128)  NOT NULL DEFAULT '',
+    payer_id        VARCHAR(64)   NOT NULL DEFAULT '',
+    transaction_id  VARCHAR(128)  NOT NULL DEFAULT '',
+    status          VARCHAR(32)   NOT NULL,
+    method          VARCHAR(32)   NOT NULL,
+    amount          NUMERIC(19,4) NOT NULL DEFAULT 0,
+    currency        VARCHAR(3)    NOT NULL DEFAULT '',
+    description     TEXT          NOT NULL DEFAULT '',
+    failure_reason  TEXT          NOT NULL DEFAULT '',
+    refund_amount   NUMERIC(19,4),
+    refund_currency VARCHAR(3),
+    approval_url    TEXT          NOT NULL DEFAULT '',
+    return_url      TEXT          NOT NULL DEFAULT '',
+    cancel_url      TEXT          NOT NULL DEFAULT '',
+    paypal_details  JSONB,
+    meta_data       JSONB         NOT NULL DEFAULT '[]',
+    created_at      TIMESTAMPTZ   NOT NULL,
+    updated_at      TIMESTAMPTZ   NOT NULL,
+    completed_at    TIMESTAMPTZ,
+    processed_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments (order_id);
+CREATE INDEX IF NOT EXISTS idx_payments_payment_id ON payments (payment_id) WHERE payment_id <> '';
@@ -0,0 +1,13 @@
+-- Links between MagicSpore orders and their OITAM proxy orders
+CREATE TABLE IF NOT EXISTS order_mappings (
+    oitam_order_id VARCHAR(64)  PRIMARY KEY,
+    magic_order_id VARCHAR(64)  NOT NULL,
+    order_key      VARCHAR(128) NOT NULL DEFAULT '',
+    state          VARCHAR(32)  NOT NULL,
+    created_at     TIMESTAMPTZ  NOT NULL,
+    updated_at     TIMESTAMPTZ  NOT NULL,
+    expires_at     TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_mappings_magic_order_id ON order_mappings (magic_order_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_order_mappings_active_expires_at ON order_mappings (expires_at) WHERE state = 'active';
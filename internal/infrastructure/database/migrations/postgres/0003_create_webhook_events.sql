@@ -0,0 +1,14 @@
+-- Webhook deliveries as received, used for deduplication and replay
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id           VARCHAR(128) PRIMARY KEY,
+    event_type   VARCHAR(128) NOT NULL,
+    body         TEXT         NOT NULL,
+    signature    VARCHAR(32)  NOT NULL,
+    status       VARCHAR(32)  NOT NULL,
+    result       TEXT         NOT NULL DEFAULT '',
+    attempts     INTEGER      NOT NULL DEFAULT 0,
+    received_at  TIMESTAMPTZ  NOT NULL,
+    processed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_received_at ON webhook_events (received_at);
@@ -0,0 +1,2 @@
+-- Status transitions recorded by the payment state machine, oldest first
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS status_history JSONB NOT NULL DEFAULT '[]';
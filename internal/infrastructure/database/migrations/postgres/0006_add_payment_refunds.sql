@@ -0,0 +1,2 @@
+-- Refund ledger keyed by PayPal refund ID, oldest first; refund_amount holds its total
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunds JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,21 @@
+-- PayPal disputes raised against MagicSpore orders
+CREATE TABLE IF NOT EXISTS disputes (
+    id                     VARCHAR(64)   PRIMARY KEY,
+    order_id               VARCHAR(64)   NOT NULL,
+    oitam_order_id         VARCHAR(64)   NOT NULL DEFAULT '',
+    transaction_id         VARCHAR(128)  NOT NULL DEFAULT '',
+    reason                 VARCHAR(64)   NOT NULL DEFAULT '',
+    status                 VARCHAR(32)   NOT NULL,
+    stage                  VARCHAR(32)   NOT NULL DEFAULT '',
+    outcome                VARCHAR(64)   NOT NULL DEFAULT '',
+    amount                 NUMERIC(19,4) NOT NULL DEFAULT 0,
+    currency               VARCHAR(3)    NOT NULL DEFAULT '',
+    seller_response_due_at TIMESTAMPTZ,
+    buyer_response_due_at  TIMESTAMPTZ,
+    created_at             TIMESTAMPTZ   NOT NULL,
+    updated_at             TIMESTAMPTZ   NOT NULL,
+    resolved_at            TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_disputes_order_id ON disputes (order_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_disputes_open ON disputes (created_at) WHERE status <> 'RESOLVED';
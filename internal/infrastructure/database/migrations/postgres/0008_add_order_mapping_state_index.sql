@@ -0,0 +1,2 @@
+-- Lets the reconciler list mappings by state, oldest first
+CREATE INDEX IF NOT EXISTS idx_order_mappings_state_created_at ON order_mappings (state, created_at);
@@ -0,0 +1,17 @@
+-- MagicSpore order mutations awaiting delivery, kept in sequence order per order
+CREATE TABLE IF NOT EXISTS outbox_entries (
+    seq             BIGSERIAL    PRIMARY KEY,
+    id              VARCHAR(64)  NOT NULL UNIQUE,
+    order_id        VARCHAR(64)  NOT NULL,
+    operation       VARCHAR(32)  NOT NULL,
+    payload         JSONB        NOT NULL,
+    status          VARCHAR(32)  NOT NULL,
+    attempts        INTEGER      NOT NULL DEFAULT 0,
+    last_error      TEXT         NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ  NOT NULL,
+    next_attempt_at TIMESTAMPTZ,
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_entries_order_id ON outbox_entries (order_id, seq);
+CREATE INDEX IF NOT EXISTS idx_outbox_entries_undelivered ON outbox_entries (status, next_attempt_at) WHERE status IN ('pending', 'failed');
@@ -0,0 +1,13 @@
+-- Append-only audit timeline of each MagicSpore order's payment flow
+CREATE TABLE IF NOT EXISTS order_events (
+    seq         BIGSERIAL    PRIMARY KEY,
+    id          VARCHAR(64)  NOT NULL UNIQUE,
+    order_id    VARCHAR(64)  NOT NULL,
+    type        VARCHAR(32)  NOT NULL,
+    message     TEXT         NOT NULL,
+    details     JSONB        NOT NULL DEFAULT '{}',
+    error       TEXT         NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ  NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_events_order_id ON order_events (order_id, seq);
@@ -0,0 +1,11 @@
+-- Payment records stored as JSON documents with indexed lookup columns
+CREATE TABLE IF NOT EXISTS payments (
+    id         TEXT    PRIMARY KEY,
+    order_id   TEXT    NOT NULL,
+    payment_id TEXT    NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    data       TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments (order_id);
+CREATE INDEX IF NOT EXISTS idx_payments_payment_id ON payments (payment_id);
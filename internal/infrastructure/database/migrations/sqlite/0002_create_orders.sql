@@ -0,0 +1,7 @@
+-- Orders stored as JSON documents keyed by WooCommerce-style integer IDs
+CREATE TABLE IF NOT EXISTS orders (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    status     TEXT    NOT NULL,
+    updated_at INTEGER NOT NULL,
+    data       TEXT    NOT NULL
+);
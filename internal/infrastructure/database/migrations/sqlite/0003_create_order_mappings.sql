@@ -0,0 +1,12 @@
+-- Links between MagicSpore orders and their OITAM proxy orders
+CREATE TABLE IF NOT EXISTS order_mappings (
+    oitam_order_id TEXT    PRIMARY KEY,
+    magic_order_id TEXT    NOT NULL,
+    state          TEXT    NOT NULL,
+    created_at     INTEGER NOT NULL,
+    expires_at     INTEGER NOT NULL,
+    data           TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_mappings_magic_order_id ON order_mappings (magic_order_id);
+CREATE INDEX IF NOT EXISTS idx_order_mappings_state_expires_at ON order_mappings (state, expires_at);
@@ -0,0 +1,10 @@
+-- Webhook deliveries as received, used for deduplication and replay
+CREATE TABLE IF NOT EXISTS webhook_events (
+    id          TEXT    PRIMARY KEY,
+    event_type  TEXT    NOT NULL,
+    status      TEXT    NOT NULL,
+    received_at INTEGER NOT NULL,
+    data        TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_received_at ON webhook_events (received_at);
@@ -0,0 +1,5 @@
+-- Queue position of webhook events awaiting background processing
+ALTER TABLE webhook_events ADD COLUMN next_attempt_at INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_webhook_events_next_attempt_at ON webhook_events (next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_events_status ON webhook_events (status, received_at);
@@ -0,0 +1,11 @@
+-- PayPal disputes raised against MagicSpore orders
+CREATE TABLE IF NOT EXISTS disputes (
+    id         TEXT    PRIMARY KEY,
+    order_id   TEXT    NOT NULL,
+    status     TEXT    NOT NULL,
+    created_at INTEGER NOT NULL,
+    data       TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_disputes_order_id ON disputes (order_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_disputes_status ON disputes (status, created_at);
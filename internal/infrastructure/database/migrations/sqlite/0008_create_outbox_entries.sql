@@ -0,0 +1,13 @@
+-- MagicSpore order mutations awaiting delivery, kept in sequence order per order
+CREATE TABLE IF NOT EXISTS outbox_entries (
+    seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+    id              TEXT    NOT NULL UNIQUE,
+    order_id        TEXT    NOT NULL,
+    status          TEXT    NOT NULL,
+    created_at      INTEGER NOT NULL,
+    next_attempt_at INTEGER,
+    data            TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_entries_order_id ON outbox_entries (order_id, seq);
+CREATE INDEX IF NOT EXISTS idx_outbox_entries_status ON outbox_entries (status, next_attempt_at);
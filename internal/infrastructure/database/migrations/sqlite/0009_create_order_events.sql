@@ -0,0 +1,10 @@
+-- Append-only audit timeline of each MagicSpore order's payment flow
+CREATE TABLE IF NOT EXISTS order_events (
+    seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+    id          TEXT    NOT NULL UNIQUE,
+    order_id    TEXT    NOT NULL,
+    occurred_at INTEGER NOT NULL,
+    data        TEXT    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_events_order_id ON order_events (order_id, seq);
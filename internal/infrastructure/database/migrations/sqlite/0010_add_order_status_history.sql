@@ -0,0 +1,2 @@
+-- Status transitions recorded by the order state machine, oldest first
+ALTER TABLE orders ADD COLUMN status_history TEXT NOT NULL DEFAULT '[]';
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"paypal-proxy/internal/domain/interfaces"
+	"time"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// PostgresConfig holds PostgreSQL connection configuration
+type PostgresConfig struct {
+	ConnectionString string
+	MaxConnections   int
+	Timeout          time.Duration
+	AutoMigrate      bool
+}
+
+// NewPostgresDB opens a PostgreSQL connection pool and applies pending migrations
+func NewPostgresDB(ctx context.Context, config PostgresConfig, logger interfaces.Logger) (*sql.DB, error) {
+	if config.ConnectionString == "" {
+		return nil, fmt.Errorf("database connection string is required")
+	}
+
+	db, err := sql.Open("postgres", config.ConnectionString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if config.MaxConnections > 0 {
+		db.SetMaxOpenConns(config.MaxConnections)
+		db.SetMaxIdleConns(config.MaxConnections)
+	}
+	db.SetConnMaxIdleTime(5 * time.Minute)
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := db.PingContext(pingCtx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if config.AutoMigrate {
+		migrations, err := fs.Sub(postgresMigrations, "migrations/postgres")
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to load migrations: %w", err)
+		}
+
+		if err := Migrate(pingCtx, db, migrations, logger); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	logger.Info("Connected to PostgreSQL", map[string]interface{}{
+		"max_connections": config.MaxConnections,
+		"auto_migrate":    config.AutoMigrate,
+	})
+
+	return db, nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"paypal-proxy/internal/domain/interfaces"
+
+	_ "modernc.org/sqlite" // Pure Go SQLite driver (no cgo required)
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// SQLiteConfig holds embedded SQLite configuration
+type SQLiteConfig struct {
+	Path        string // file path, or ":memory:" for a throwaway database
+	AutoMigrate bool
+}
+
+// NewSQLiteDB opens a file-backed SQLite database and applies pending migrations
+func NewSQLiteDB(ctx context.Context, config SQLiteConfig, logger interfaces.Logger) (*sql.DB, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("sqlite database path is required")
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", config.Path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// SQLite serializes writers; a single connection avoids SQLITE_BUSY and
+	// keeps ":memory:" databases shared across the pool.
+	db.SetMaxOpenConns(1)
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	if config.AutoMigrate {
+		migrations, err := fs.Sub(sqliteMigrations, "migrations/sqlite")
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to load migrations: %w", err)
+		}
+
+		if err := Migrate(ctx, db, migrations, logger); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	logger.Info("Opened SQLite database", map[string]interface{}{
+		"path":         config.Path,
+		"auto_migrate": config.AutoMigrate,
+	})
+
+	return db, nil
+}
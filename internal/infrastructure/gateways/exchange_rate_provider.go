@@ -0,0 +1,100 @@
+package gateways
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"strings"
+	"time"
+)
+
+// StaticExchangeRateProvider serves fixed exchange rates from configuration
+// or a rates file
+type StaticExchangeRateProvider struct {
+	rates map[string]*entities.ExchangeRate
+}
+
+// exchangeRatesFile is the format of EXCHANGE_RATES_FILE
+type exchangeRatesFile struct {
+	AsOf  time.Time              `json:"as_of"`
+	Rates map[string]json.Number `json:"rates"` // keyed by "FROM/TO"
+}
+
+// NewStaticExchangeRateProvider creates a provider from comma separated
+// FROM/TO=rate pairs and an optional JSON rates file. Rates from the file take
+// precedence over the inline pairs.
+func NewStaticExchangeRateProvider(pairs, path string) (interfaces.ExchangeRateProvider, error) {
+	provider := &StaticExchangeRateProvider{rates: make(map[string]*entities.ExchangeRate)}
+	loadedAt := time.Now()
+
+	for _, pair := range strings.Split(pairs, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		currencies, rate, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid exchange rate %q, expected FROM/TO=rate", pair)
+		}
+		if err := provider.add(currencies, rate, "static", loadedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read exchange rates file: %w", err)
+		}
+
+		var file exchangeRatesFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("failed to parse exchange rates file: %w", err)
+		}
+		asOf := file.AsOf
+		if asOf.IsZero() {
+			asOf = loadedAt
+		}
+		for currencies, rate := range file.Rates {
+			if err := provider.add(currencies, rate.String(), "file", asOf); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return provider, nil
+}
+
+// GetRate returns the configured rate for a currency pair
+func (p *StaticExchangeRateProvider) GetRate(ctx context.Context, from, to string) (*entities.ExchangeRate, error) {
+	rate, exists := p.rates[pairKey(from, to)]
+	if !exists {
+		return nil, fmt.Errorf("no exchange rate configured for %s/%s", strings.ToUpper(from), strings.ToUpper(to))
+	}
+
+	result := *rate
+	return &result, nil
+}
+
+// add parses and stores one FROM/TO rate
+func (p *StaticExchangeRateProvider) add(currencies, rate, source string, asOf time.Time) error {
+	from, to, found := strings.Cut(strings.TrimSpace(currencies), "/")
+	if !found || from == "" || to == "" {
+		return fmt.Errorf("invalid currency pair %q, expected FROM/TO", currencies)
+	}
+
+	exchangeRate, err := entities.NewExchangeRate(from, to, rate, source, asOf)
+	if err != nil {
+		return err
+	}
+	p.rates[pairKey(from, to)] = exchangeRate
+	return nil
+}
+
+// pairKey normalizes a currency pair for lookups
+func pairKey(from, to string) string {
+	return strings.ToUpper(strings.TrimSpace(from)) + "/" + strings.ToUpper(strings.TrimSpace(to))
+}
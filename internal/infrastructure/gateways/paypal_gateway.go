@@ -0,0 +1,684 @@
+package gateways
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PayPal REST API base URLs
+const (
+	PayPalSandboxAPIBase = "https://api-m.sandbox.paypal.com"
+	PayPalLiveAPIBase    = "https://api-m.paypal.com"
+)
+
+// tokenExpiryMargin renews access tokens slightly before PayPal expires them
+const tokenExpiryMargin = time.Minute
+
+// PayPalConfig holds PayPal REST API configuration
+type PayPalConfig struct {
+	ClientID      string
+	ClientSecret  string
+	APIBase       string
+	Timeout       time.Duration
+	RetryAttempts int
+	RetryDelay    time.Duration
+}
+
+// PayPalGateway implements PaymentGateway on the PayPal Orders v2 API
+type PayPalGateway struct {
+	config     PayPalConfig
+	httpClient *http.Client
+	logger     interfaces.Logger
+
+	tokenMutex  sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
+}
+
+// NewPayPalGateway creates a new PayPal Orders v2 gateway
+func NewPayPalGateway(config PayPalConfig, logger interfaces.Logger) interfaces.PaymentGateway {
+	if config.APIBase == "" {
+		config.APIBase = PayPalSandboxAPIBase
+	}
+	config.APIBase = strings.TrimRight(config.APIBase, "/")
+
+	return &PayPalGateway{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: config.Timeout,
+		},
+		logger: logger,
+	}
+}
+
+// PayPalAPIError describes an error response from the PayPal REST API
+type PayPalAPIError struct {
+	StatusCode int
+	Name       string `json:"name"`
+	Message    string `json:"message"`
+	DebugID    string `json:"debug_id"`
+	Details    []struct {
+		Issue       string `json:"issue"`
+		Description string `json:"description"`
+	} `json:"details"`
+}
+
+// Error implements the error interface
+func (e *PayPalAPIError) Error() string {
+	msg := fmt.Sprintf("paypal API error %d", e.StatusCode)
+	if e.Name != "" {
+		msg += ": " + e.Name
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if len(e.Details) > 0 && e.Details[0].Issue != "" {
+		msg += " (" + e.Details[0].Issue + ")"
+	}
+	if e.DebugID != "" {
+		msg += " [debug_id " + e.DebugID + "]"
+	}
+	return msg
+}
+
+// IsNotFound checks if the error is a PayPal 404 response
+func IsNotFound(err error) bool {
+	var apiErr *PayPalAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// CreatePayment creates a PayPal order with intent CAPTURE
+func (g *PayPalGateway) CreatePayment(ctx context.Context, request *entities.PaymentRequest) (*entities.PaymentResponse, error) {
+	if request == nil {
+		return nil, errors.New("payment request cannot be nil")
+	}
+
+	currency := request.Amount.Currency
+	if currency == "" {
+		currency = request.Currency
+	}
+
+	body := map[string]interface{}{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]interface{}{
+			{
+				"reference_id": request.OrderID,
+				"custom_id":    request.OrderID,
+				"description":  request.Description,
+				"amount": map[string]interface{}{
+					"currency_code": strings.ToUpper(currency),
+					"value":         formatAmount(request.Amount),
+				},
+			},
+		},
+		"payment_source": map[string]interface{}{
+			"paypal": map[string]interface{}{
+				"experience_context": map[string]interface{}{
+					"return_url":          request.ReturnURL,
+					"cancel_url":          request.CancelURL,
+					"user_action":         "PAY_NOW",
+					"shipping_preference": "NO_SHIPPING",
+				},
+			},
+		},
+	}
+
+	var order paypalOrder
+	if err := g.call(ctx, http.MethodPost, "/v2/checkout/orders", body, &order); err != nil {
+		g.logger.Error("Failed to create PayPal order", err, map[string]interface{}{
+			"order_id": request.OrderID,
+		})
+		return nil, fmt.Errorf("failed to create paypal order: %w", err)
+	}
+
+	response := &entities.PaymentResponse{
+		PaymentID: order.ID,
+		Status:    mapOrderStatus(order.Status),
+		CreatedAt: parsePayPalTime(order.CreateTime),
+	}
+	for _, link := range order.Links {
+		if link.Rel == "approve" || link.Rel == "payer-action" {
+			response.ApprovalURL = link.Href
+			break
+		}
+	}
+	if response.CreatedAt.IsZero() {
+		response.CreatedAt = time.Now()
+	}
+
+	g.logger.Info("PayPal order created", map[string]interface{}{
+		"order_id":        request.OrderID,
+		"paypal_order_id": order.ID,
+		"status":          order.Status,
+	})
+
+	return response, nil
+}
+
+// ProcessPayment captures an approved PayPal order
+func (g *PayPalGateway) ProcessPayment(ctx context.Context, paymentID string, payerID string) (*entities.Payment, error) {
+	var order paypalOrder
+	path := "/v2/checkout/orders/" + url.PathEscape(paymentID) + "/capture"
+	if err := g.call(ctx, http.MethodPost, path, map[string]interface{}{}, &order); err != nil {
+		g.logger.Error("Failed to capture PayPal order", err, map[string]interface{}{
+			"paypal_order_id": paymentID,
+			"payer_id":        payerID,
+		})
+		return nil, fmt.Errorf("failed to capture paypal order: %w", err)
+	}
+
+	payment := order.toPayment()
+	if payment.PayerID == "" {
+		payment.PayerID = payerID
+	}
+
+	g.logger.Info("PayPal order captured", map[string]interface{}{
+		"paypal_order_id": order.ID,
+		"capture_id":      payment.TransactionID,
+		"status":          payment.Status,
+	})
+
+	return payment, nil
+}
+
+// GetPaymentStatus looks up a capture by ID, falling back to an order lookup
+// so both capture IDs (stored as transaction IDs) and order IDs resolve
+func (g *PayPalGateway) GetPaymentStatus(ctx context.Context, paymentID string) (*entities.Payment, error) {
+	var capture paypalCapture
+	err := g.call(ctx, http.MethodGet, "/v2/payments/captures/"+url.PathEscape(paymentID), nil, &capture)
+	if err == nil {
+		return capture.toPayment(), nil
+	}
+	if !IsNotFound(err) {
+		return nil, fmt.Errorf("failed to look up paypal capture: %w", err)
+	}
+
+	var order paypalOrder
+	if err := g.call(ctx, http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(paymentID), nil, &order); err != nil {
+		if IsNotFound(err) {
+			return nil, fmt.Errorf("payment %s not found", paymentID)
+		}
+		return nil, fmt.Errorf("failed to look up paypal order: %w", err)
+	}
+
+	return order.toPayment(), nil
+}
+
+// CancelPayment abandons an uncaptured PayPal order. Orders v2 has no cancel
+// call; uncaptured orders simply expire, so this only guards against
+// cancelling money that has already moved.
+func (g *PayPalGateway) CancelPayment(ctx context.Context, paymentID string) error {
+	var order paypalOrder
+	if err := g.call(ctx, http.MethodGet, "/v2/checkout/orders/"+url.PathEscape(paymentID), nil, &order); err != nil {
+		return fmt.Errorf("failed to look up paypal order: %w", err)
+	}
+
+	if order.Status == "COMPLETED" {
+		return fmt.Errorf("paypal order %s is already captured; refund it instead", paymentID)
+	}
+
+	g.logger.Info("PayPal order abandoned", map[string]interface{}{
+		"paypal_order_id": paymentID,
+		"status":          order.Status,
+	})
+
+	return nil
+}
+
+// RefundPayment refunds a capture and returns the PayPal refund ID; a zero
+// amount refunds it in full. requestID is sent as the PayPal-Request-Id, so
+// PayPal answers a repeated refund with the one already made.
+func (g *PayPalGateway) RefundPayment(ctx context.Context, paymentID string, amount entities.Money, requestID string) (string, error) {
+	body := map[string]interface{}{}
+	if !amount.IsZero() {
+		body["amount"] = map[string]interface{}{
+			"currency_code": strings.ToUpper(amount.Currency),
+			"value":         formatAmount(amount),
+		}
+	}
+
+	var refund paypalRefund
+	path := "/v2/payments/captures/" + url.PathEscape(paymentID) + "/refund"
+	if err := g.callWithRequestID(ctx, http.MethodPost, path, requestID, body, &refund); err != nil {
+		g.logger.Error("Failed to refund PayPal capture", err, map[string]interface{}{
+			"capture_id": paymentID,
+			"amount":     amount.Decimal(),
+		})
+		return "", fmt.Errorf("failed to refund paypal capture: %w", err)
+	}
+
+	g.logger.Info("PayPal capture refunded", map[string]interface{}{
+		"capture_id": paymentID,
+		"refund_id":  refund.ID,
+		"status":     refund.Status,
+	})
+
+	return refund.ID, nil
+}
+
+// call performs an authenticated JSON request with retries on transient failures.
+// POST requests carry a PayPal-Request-Id so retries are idempotent.
+func (g *PayPalGateway) call(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	requestID := ""
+	if method == http.MethodPost {
+		requestID = newRequestID()
+	}
+	return g.callWithRequestID(ctx, method, path, requestID, body, out)
+}
+
+// callWithRequestID performs a request like call, sending requestID as the
+// PayPal-Request-Id when it is set
+func (g *PayPalGateway) callWithRequestID(ctx context.Context, method, path, requestID string, body interface{}, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+	}
+
+	var lastErr error
+	refreshedToken := false
+
+	for attempt := 0; attempt <= g.config.RetryAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * g.config.RetryDelay):
+			}
+
+			g.logger.Debug("Retrying PayPal request", map[string]interface{}{
+				"attempt": attempt,
+				"path":    path,
+			})
+		}
+
+		token, err := g.token(ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var reader io.Reader
+		if payload != nil {
+			reader = bytes.NewReader(payload)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, g.config.APIBase+path, reader)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Prefer", "return=representation")
+		if requestID != "" {
+			req.Header.Set("PayPal-Request-Id", requestID)
+		}
+
+		resp, err := g.httpClient.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("HTTP request failed: %w", err)
+			continue
+		}
+
+		err = decodeResponse(resp, out)
+		resp.Body.Close()
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		var apiErr *PayPalAPIError
+		if !errors.As(err, &apiErr) {
+			return err
+		}
+
+		// An expired or revoked token gets one immediate refresh
+		if apiErr.StatusCode == http.StatusUnauthorized && !refreshedToken {
+			g.invalidateToken()
+			refreshedToken = true
+			attempt--
+			continue
+		}
+
+		if apiErr.StatusCode < 500 && apiErr.StatusCode != http.StatusTooManyRequests {
+			return err
+		}
+	}
+
+	return lastErr
+}
+
+// token returns a cached OAuth2 access token, fetching a new one when needed
+func (g *PayPalGateway) token(ctx context.Context) (string, error) {
+	g.tokenMutex.Lock()
+	defer g.tokenMutex.Unlock()
+
+	if g.accessToken != "" && time.Now().Before(g.tokenExpiry) {
+		return g.accessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.config.APIBase+"/v1/oauth2/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.SetBasicAuth(g.config.ClientID, g.config.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request paypal access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := decodeResponse(resp, &token); err != nil {
+		return "", fmt.Errorf("failed to obtain paypal access token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("paypal returned an empty access token")
+	}
+
+	g.accessToken = token.AccessToken
+	g.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - tokenExpiryMargin)
+
+	g.logger.Debug("PayPal access token refreshed", map[string]interface{}{
+		"expires_in": token.ExpiresIn,
+	})
+
+	return g.accessToken, nil
+}
+
+// invalidateToken drops the cached access token
+func (g *PayPalGateway) invalidateToken() {
+	g.tokenMutex.Lock()
+	defer g.tokenMutex.Unlock()
+	g.accessToken = ""
+}
+
+// decodeResponse decodes a successful JSON body into out or returns a PayPalAPIError
+func decodeResponse(resp *http.Response, out interface{}) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		apiErr := &PayPalAPIError{StatusCode: resp.StatusCode}
+		if err := json.Unmarshal(data, apiErr); err != nil || apiErr.Message == "" {
+			// OAuth errors use error/error_description instead
+			var oauthErr struct {
+				Error       string `json:"error"`
+				Description string `json:"error_description"`
+			}
+			if json.Unmarshal(data, &oauthErr) == nil && oauthErr.Error != "" {
+				apiErr.Name = oauthErr.Error
+				apiErr.Message = oauthErr.Description
+			} else if apiErr.Message == "" {
+				apiErr.Message = strings.TrimSpace(string(data))
+			}
+		}
+		return apiErr
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// PayPal Orders v2 response types
+
+type paypalMoney struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type paypalLink struct {
+	Href   string `json:"href"`
+	Rel    string `json:"rel"`
+	Method string `json:"method"`
+}
+
+type paypalCapture struct {
+	ID         string       `json:"id"`
+	Status     string       `json:"status"`
+	Amount     paypalMoney  `json:"amount"`
+	CustomID   string       `json:"custom_id"`
+	InvoiceID  string       `json:"invoice_id"`
+	CreateTime string       `json:"create_time"`
+	UpdateTime string       `json:"update_time"`
+	Links      []paypalLink `json:"links"`
+}
+
+type paypalRefund struct {
+	ID     string      `json:"id"`
+	Status string      `json:"status"`
+	Amount paypalMoney `json:"amount"`
+}
+
+type paypalOrder struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	Intent        string `json:"intent"`
+	CreateTime    string `json:"create_time"`
+	UpdateTime    string `json:"update_time"`
+	PurchaseUnits []struct {
+		ReferenceID string      `json:"reference_id"`
+		CustomID    string      `json:"custom_id"`
+		InvoiceID   string      `json:"invoice_id"`
+		Description string      `json:"description"`
+		Amount      paypalMoney `json:"amount"`
+		Payments    struct {
+			Captures []paypalCapture `json:"captures"`
+		} `json:"payments"`
+	} `json:"purchase_units"`
+	Payer struct {
+		PayerID      string `json:"payer_id"`
+		EmailAddress string `json:"email_address"`
+		Name         struct {
+			GivenName string `json:"given_name"`
+			Surname   string `json:"surname"`
+		} `json:"name"`
+		Address struct {
+			CountryCode string `json:"country_code"`
+		} `json:"address"`
+	} `json:"payer"`
+	Links []paypalLink `json:"links"`
+}
+
+// toPayment maps a capture into a payment keyed by the capture ID
+func (c *paypalCapture) toPayment() *entities.Payment {
+	amount := c.Amount.toMoney()
+	payment := &entities.Payment{
+		OrderID:       c.CustomID,
+		PaymentID:     c.ID,
+		TransactionID: c.ID,
+		Status:        mapCaptureStatus(c.Status),
+		Method:        entities.PaymentMethodPayPal,
+		Amount:        amount,
+		Currency:      amount.Currency,
+		CreatedAt:     parsePayPalTime(c.CreateTime),
+		UpdatedAt:     parsePayPalTime(c.UpdateTime),
+		PayPalDetails: &entities.PayPalDetails{
+			PaymentID:  c.ID,
+			State:      c.Status,
+			Intent:     "CAPTURE",
+			Links:      convertLinks(c.Links),
+			CreateTime: parsePayPalTime(c.CreateTime),
+			UpdateTime: parsePayPalTime(c.UpdateTime),
+		},
+	}
+	if payment.IsCompleted() {
+		completedAt := payment.UpdatedAt
+		payment.CompletedAt = &completedAt
+	}
+	return payment
+}
+
+// toPayment maps an order into a payment keyed by the order ID. Captured
+// orders report the first capture as the transaction and amount.
+func (o *paypalOrder) toPayment() *entities.Payment {
+	payment := &entities.Payment{
+		PaymentID: o.ID,
+		PayerID:   o.Payer.PayerID,
+		Status:    mapOrderStatus(o.Status),
+		Method:    entities.PaymentMethodPayPal,
+		CreatedAt: parsePayPalTime(o.CreateTime),
+		UpdatedAt: parsePayPalTime(o.UpdateTime),
+	}
+
+	details := &entities.PayPalDetails{
+		PaymentID:  o.ID,
+		State:      o.Status,
+		Intent:     o.Intent,
+		Links:      convertLinks(o.Links),
+		CreateTime: parsePayPalTime(o.CreateTime),
+		UpdateTime: parsePayPalTime(o.UpdateTime),
+	}
+	if o.Payer.PayerID != "" {
+		details.Payer = &entities.PayPalPayer{
+			PaymentMethod: "paypal",
+			PayerInfo: &entities.PayPalPayerInfo{
+				Email:       o.Payer.EmailAddress,
+				FirstName:   o.Payer.Name.GivenName,
+				LastName:    o.Payer.Name.Surname,
+				PayerID:     o.Payer.PayerID,
+				CountryCode: o.Payer.Address.CountryCode,
+			},
+		}
+	}
+
+	for _, unit := range o.PurchaseUnits {
+		if payment.OrderID == "" {
+			payment.OrderID = unit.CustomID
+			payment.Description = unit.Description
+			payment.Amount = unit.Amount.toMoney()
+		}
+
+		details.Transactions = append(details.Transactions, &entities.PayPalTransaction{
+			Amount: &entities.PayPalAmount{
+				Total:    unit.Amount.Value,
+				Currency: unit.Amount.CurrencyCode,
+			},
+			Description:   unit.Description,
+			InvoiceNumber: unit.InvoiceID,
+		})
+
+		if payment.TransactionID == "" && len(unit.Payments.Captures) > 0 {
+			capture := unit.Payments.Captures[0]
+			payment.TransactionID = capture.ID
+			payment.Amount = capture.Amount.toMoney()
+			payment.Status = mapCaptureStatus(capture.Status)
+		}
+	}
+
+	payment.Currency = payment.Amount.Currency
+	payment.PayPalDetails = details
+	if payment.IsCompleted() {
+		completedAt := payment.UpdatedAt
+		payment.CompletedAt = &completedAt
+	}
+
+	return payment
+}
+
+// toMoney converts a PayPal amount into a domain value
+func (m paypalMoney) toMoney() entities.Money {
+	amount, err := entities.ParseMoneyRounded(m.Value, m.CurrencyCode, entities.RoundHalfUp)
+	if err != nil {
+		return entities.NewMoneyFromMinorUnits(0, m.CurrencyCode)
+	}
+	return amount
+}
+
+// mapOrderStatus maps a PayPal order status into a payment status
+func mapOrderStatus(status string) entities.PaymentStatus {
+	switch status {
+	case "APPROVED":
+		return entities.PaymentStatusApproved
+	case "COMPLETED":
+		return entities.PaymentStatusCompleted
+	case "VOIDED":
+		return entities.PaymentStatusCancelled
+	default: // CREATED, SAVED, PAYER_ACTION_REQUIRED
+		return entities.PaymentStatusCreated
+	}
+}
+
+// mapCaptureStatus maps a PayPal capture status into a payment status
+func mapCaptureStatus(status string) entities.PaymentStatus {
+	switch status {
+	case "COMPLETED", "PARTIALLY_REFUNDED":
+		return entities.PaymentStatusCompleted
+	case "REFUNDED":
+		return entities.PaymentStatusRefunded
+	case "DECLINED", "FAILED":
+		return entities.PaymentStatusFailed
+	default: // PENDING
+		return entities.PaymentStatusPending
+	}
+}
+
+// convertLinks maps PayPal HATEOAS links into domain links
+func convertLinks(links []paypalLink) []*entities.PayPalLink {
+	var converted []*entities.PayPalLink
+	for _, link := range links {
+		converted = append(converted, &entities.PayPalLink{
+			Href:   link.Href,
+			Rel:    link.Rel,
+			Method: link.Method,
+		})
+	}
+	return converted
+}
+
+// parsePayPalTime parses PayPal RFC 3339 timestamps, returning zero on failure
+func parsePayPalTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// formatAmount renders an amount the way PayPal expects it, with the
+// currency's minor unit digits (none for JPY)
+func formatAmount(m entities.Money) string {
+	return m.Decimal()
+}
+
+// newRequestID generates an idempotency key for PayPal POST requests
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,324 @@
+package gateways
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"paypal-proxy/internal/domain/interfaces"
+	"strings"
+	"sync"
+	"time"
+)
+
+// payPalAuthAlgo is the only transmission signature algorithm PayPal uses
+const payPalAuthAlgo = "SHA256withRSA"
+
+// Defaults for certificate based webhook verification
+var (
+	// DefaultPayPalCertHosts are the hosts PayPal serves signing certificates from
+	DefaultPayPalCertHosts = []string{
+		"api.paypal.com", "api-m.paypal.com", "api.sandbox.paypal.com", "api-m.sandbox.paypal.com",
+	}
+
+	// DefaultPayPalCertNames are the names PayPal signing certificates are issued to
+	DefaultPayPalCertNames = []string{
+		"messageverificationcerts.paypal.com", "messageverificationcerts.sandbox.paypal.com",
+	}
+)
+
+// maxCertCacheAge bounds how long a fetched signing certificate is reused
+const maxCertCacheAge = 24 * time.Hour
+
+// defaultTransmissionWindow is how far a transmission time may be from now
+// before a delivery is treated as a replay
+const defaultTransmissionWindow = 5 * time.Minute
+
+// ErrWebhookSignature is returned when a webhook delivery is not authentic
+var ErrWebhookSignature = errors.New("webhook signature verification failed")
+
+// PayPalCertVerifierConfig holds configuration for local webhook verification
+type PayPalCertVerifierConfig struct {
+	WebhookID  string         // webhook ID the deliveries were signed for
+	CertHosts  []string       // hosts certificates may be fetched from; defaults to PayPal's
+	CertNames  []string       // names the signing certificate must be valid for; defaults to PayPal's
+	Roots      *x509.CertPool // trusted roots; nil uses the system pool
+	Timeout    time.Duration  // certificate download timeout
+	HTTPClient *http.Client   // client for certificate downloads; overrides Timeout
+	MaxSkew    time.Duration  // accepted distance of the transmission time from now; defaults to 5 minutes
+}
+
+// PayPalCertVerifier verifies webhook signatures locally: it downloads the
+// signing certificate from PAYPAL-CERT-URL, checks its chain, and verifies the
+// RSA signature over transmissionID|time|webhookID|crc32(body). Deliveries
+// whose transmission time is outside MaxSkew of now are rejected as replays.
+type PayPalCertVerifier struct {
+	config     PayPalCertVerifierConfig
+	httpClient *http.Client
+	logger     interfaces.Logger
+	now        func() time.Time
+
+	cacheMutex sync.Mutex
+	certs      map[string]cachedCert
+}
+
+type cachedCert struct {
+	publicKey *rsa.PublicKey
+	expires   time.Time
+}
+
+// NewPayPalCertVerifier creates a verifier that checks signatures against PayPal's certificates
+func NewPayPalCertVerifier(config PayPalCertVerifierConfig, logger interfaces.Logger) interfaces.WebhookVerifier {
+	if len(config.CertHosts) == 0 {
+		config.CertHosts = DefaultPayPalCertHosts
+	}
+	if len(config.CertNames) == 0 {
+		config.CertNames = DefaultPayPalCertNames
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = defaultTransmissionWindow
+	}
+
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: config.Timeout}
+	}
+
+	return &PayPalCertVerifier{
+		config:     config,
+		httpClient: httpClient,
+		logger:     logger,
+		now:        time.Now,
+		certs:      make(map[string]cachedCert),
+	}
+}
+
+// VerifyWebhook checks the transmission signature with the signing certificate
+func (v *PayPalCertVerifier) VerifyWebhook(ctx context.Context, transmission interfaces.WebhookTransmission, body []byte) error {
+	if err := checkTransmission(transmission); err != nil {
+		return err
+	}
+	if err := v.checkTransmissionTime(transmission.Time); err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(transmission.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: malformed transmission signature", ErrWebhookSignature)
+	}
+
+	publicKey, err := v.certificate(ctx, transmission.CertURL)
+	if err != nil {
+		return err
+	}
+
+	signed := fmt.Sprintf("%s|%s|%s|%d", transmission.ID, transmission.Time, v.config.WebhookID, crc32.ChecksumIEEE(body))
+	digest := sha256.Sum256([]byte(signed))
+	if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+		return fmt.Errorf("%w: signature does not match", ErrWebhookSignature)
+	}
+
+	return nil
+}
+
+// checkTransmissionTime rejects transmissions sent too long before or after
+// now, so a captured delivery cannot be replayed later
+func (v *PayPalCertVerifier) checkTransmissionTime(transmissionTime string) error {
+	sentAt, err := time.Parse(time.RFC3339, transmissionTime)
+	if err != nil {
+		return fmt.Errorf("%w: malformed transmission time %q", ErrWebhookSignature, transmissionTime)
+	}
+
+	skew := v.now().Sub(sentAt)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > v.config.MaxSkew {
+		return fmt.Errorf("%w: transmission time %s is outside the accepted window", ErrWebhookSignature, transmissionTime)
+	}
+	return nil
+}
+
+// certificate returns the signing key behind a cert URL, downloading and
+// validating the certificate on a cache miss
+func (v *PayPalCertVerifier) certificate(ctx context.Context, certURL string) (*rsa.PublicKey, error) {
+	parsed, err := url.Parse(certURL)
+	if err != nil || parsed.Scheme != "https" || !v.allowedHost(parsed.Hostname()) {
+		return nil, fmt.Errorf("%w: untrusted certificate URL %q", ErrWebhookSignature, certURL)
+	}
+
+	v.cacheMutex.Lock()
+	cached, exists := v.certs[certURL]
+	v.cacheMutex.Unlock()
+	if exists && v.now().Before(cached.expires) {
+		return cached.publicKey, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate request: %w", err)
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download signing certificate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download signing certificate, status: %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing certificate: %w", err)
+	}
+
+	leaf, err := v.verifyChain(data)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, ok := leaf.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: signing certificate does not hold an RSA key", ErrWebhookSignature)
+	}
+
+	expires := v.now().Add(maxCertCacheAge)
+	if leaf.NotAfter.Before(expires) {
+		expires = leaf.NotAfter
+	}
+
+	v.cacheMutex.Lock()
+	v.certs[certURL] = cachedCert{publicKey: publicKey, expires: expires}
+	v.cacheMutex.Unlock()
+
+	v.logger.Info("PayPal signing certificate cached", map[string]interface{}{
+		"cert_url":  certURL,
+		"subject":   leaf.Subject.CommonName,
+		"not_after": leaf.NotAfter,
+	})
+
+	return publicKey, nil
+}
+
+// verifyChain parses a PEM bundle and verifies its leaf against the trusted roots
+func (v *PayPalCertVerifier) verifyChain(data []byte) (*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed signing certificate", ErrWebhookSignature)
+		}
+		chain = append(chain, cert)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w: no signing certificate found", ErrWebhookSignature)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	leaf := chain[0]
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         v.config.Roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		return nil, fmt.Errorf("%w: untrusted signing certificate: %v", ErrWebhookSignature, err)
+	}
+
+	for _, name := range v.config.CertNames {
+		if leaf.VerifyHostname(name) == nil || leaf.Subject.CommonName == name {
+			return leaf, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: signing certificate issued to %q", ErrWebhookSignature, leaf.Subject.CommonName)
+}
+
+// allowedHost checks if certificates may be fetched from a host
+func (v *PayPalCertVerifier) allowedHost(host string) bool {
+	for _, allowed := range v.config.CertHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// PayPalAPIVerifier verifies webhook signatures by calling PayPal's
+// /v1/notifications/verify-webhook-signature endpoint
+type PayPalAPIVerifier struct {
+	gateway   *PayPalGateway
+	webhookID string
+}
+
+// NewPayPalAPIVerifier creates a verifier that delegates to the PayPal REST API
+func NewPayPalAPIVerifier(config PayPalConfig, webhookID string, logger interfaces.Logger) interfaces.WebhookVerifier {
+	return &PayPalAPIVerifier{
+		gateway:   NewPayPalGateway(config, logger).(*PayPalGateway),
+		webhookID: webhookID,
+	}
+}
+
+// VerifyWebhook asks PayPal whether the transmission signature is valid
+func (v *PayPalAPIVerifier) VerifyWebhook(ctx context.Context, transmission interfaces.WebhookTransmission, body []byte) error {
+	if err := checkTransmission(transmission); err != nil {
+		return err
+	}
+	if !json.Valid(body) {
+		return fmt.Errorf("%w: webhook body is not JSON", ErrWebhookSignature)
+	}
+
+	// The event is embedded as received rather than decoded into a struct, which
+	// would drop fields and change the CRC PayPal checks
+	request := map[string]interface{}{
+		"auth_algo":         transmission.AuthAlgo,
+		"cert_url":          transmission.CertURL,
+		"transmission_id":   transmission.ID,
+		"transmission_sig":  transmission.Signature,
+		"transmission_time": transmission.Time,
+		"webhook_id":        v.webhookID,
+		"webhook_event":     json.RawMessage(body),
+	}
+
+	var response struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	if err := v.gateway.call(ctx, http.MethodPost, "/v1/notifications/verify-webhook-signature", request, &response); err != nil {
+		return fmt.Errorf("failed to verify webhook signature: %w", err)
+	}
+
+	if response.VerificationStatus != "SUCCESS" {
+		return fmt.Errorf("%w: PayPal reported %s", ErrWebhookSignature, response.VerificationStatus)
+	}
+	return nil
+}
+
+// checkTransmission ensures all signing headers are present and use the expected algorithm
+func checkTransmission(transmission interfaces.WebhookTransmission) error {
+	if transmission.ID == "" || transmission.Time == "" || transmission.Signature == "" || transmission.CertURL == "" {
+		return fmt.Errorf("%w: missing transmission headers", ErrWebhookSignature)
+	}
+	if transmission.AuthAlgo != payPalAuthAlgo {
+		return fmt.Errorf("%w: unsupported auth algorithm %q", ErrWebhookSignature, transmission.AuthAlgo)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package gateways
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RemoteExchangeRateConfig holds exchange rate API configuration
+type RemoteExchangeRateConfig struct {
+	BaseURL  string
+	Timeout  time.Duration
+	CacheTTL time.Duration
+}
+
+// RemoteExchangeRateProvider fetches rates from an HTTP API answering
+// GET {base}/latest?from=EUR&to=PLN with {"date": "2026-10-15", "rates": {"PLN": 4.2985}},
+// the format served by Frankfurter and compatible services
+type RemoteExchangeRateProvider struct {
+	config     RemoteExchangeRateConfig
+	httpClient *http.Client
+	logger     interfaces.Logger
+
+	mutex sync.Mutex
+	cache map[string]cachedExchangeRate
+}
+
+// cachedExchangeRate is a fetched rate and when it stops being reused
+type cachedExchangeRate struct {
+	rate    *entities.ExchangeRate
+	expires time.Time
+}
+
+// remoteRatesResponse is the exchange rate API response
+type remoteRatesResponse struct {
+	Base  string                 `json:"base"`
+	Date  string                 `json:"date"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// NewRemoteExchangeRateProvider creates a provider backed by an exchange rate API
+func NewRemoteExchangeRateProvider(config RemoteExchangeRateConfig, logger interfaces.Logger) interfaces.ExchangeRateProvider {
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+
+	return &RemoteExchangeRateProvider{
+		config: config,
+		httpClient: &http.Client{
+			Timeout: config.Timeout,
+		},
+		logger: logger,
+		cache:  make(map[string]cachedExchangeRate),
+	}
+}
+
+// GetRate returns the API's latest rate, reusing it for the cache TTL
+func (p *RemoteExchangeRateProvider) GetRate(ctx context.Context, from, to string) (*entities.ExchangeRate, error) {
+	key := pairKey(from, to)
+
+	p.mutex.Lock()
+	cached, exists := p.cache[key]
+	p.mutex.Unlock()
+	if exists && time.Now().Before(cached.expires) {
+		result := *cached.rate
+		return &result, nil
+	}
+
+	rate, err := p.fetch(ctx, strings.ToUpper(from), strings.ToUpper(to))
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	p.cache[key] = cachedExchangeRate{rate: rate, expires: time.Now().Add(p.config.CacheTTL)}
+	p.mutex.Unlock()
+
+	p.logger.Info("Exchange rate fetched", map[string]interface{}{
+		"from": rate.From,
+		"to":   rate.To,
+		"rate": rate.Rate,
+	})
+
+	result := *rate
+	return &result, nil
+}
+
+// fetch requests the latest rate for a currency pair
+func (p *RemoteExchangeRateProvider) fetch(ctx context.Context, from, to string) (*entities.ExchangeRate, error) {
+	query := url.Values{"from": {from}, "to": {to}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.BaseURL+"/latest?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch exchange rate: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("exchange rate API returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	// Decode numbers as text so the quoted rate is kept exactly
+	var response remoteRatesResponse
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode exchange rate response: %w", err)
+	}
+
+	value, exists := response.Rates[to]
+	if !exists {
+		return nil, fmt.Errorf("exchange rate API has no %s/%s rate", from, to)
+	}
+
+	asOf, err := time.Parse("2006-01-02", response.Date)
+	if err != nil {
+		asOf = time.Now()
+	}
+
+	source := "remote"
+	if parsed, err := url.Parse(p.config.BaseURL); err == nil && parsed.Host != "" {
+		source = parsed.Host
+	}
+
+	return entities.NewExchangeRate(from, to, value.String(), source, asOf)
+}
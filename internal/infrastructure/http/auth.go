@@ -0,0 +1,265 @@
+package http
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"strings"
+	"time"
+)
+
+// Authentication methods recorded on principals
+const (
+	AuthMethodAPIKey = "api_key"
+	AuthMethodJWT    = "jwt"
+)
+
+// jwtClockSkew is how far token times may disagree with the local clock
+const jwtClockSkew = 30 * time.Second
+
+// ErrInvalidCredentials is returned for credentials that do not identify a caller
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// APIKeyAuthenticator authenticates static API keys, each with its own scopes
+type APIKeyAuthenticator struct {
+	keys []apiKey
+}
+
+// apiKey is a configured key, kept only as its digest
+type apiKey struct {
+	digest [sha256.Size]byte
+	scopes []entities.AuthScope
+}
+
+// NewAPIKeyAuthenticator creates an authenticator from comma separated
+// key=scope|scope entries
+func NewAPIKeyAuthenticator(entries string) (interfaces.Authenticator, error) {
+	authenticator := &APIKeyAuthenticator{}
+	seen := make(map[[sha256.Size]byte]bool)
+
+	for _, entry := range strings.Split(entries, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, scopeList, found := strings.Cut(entry, "=")
+		if !found || key == "" {
+			return nil, errors.New("invalid API key entry, expected key=scope|scope")
+		}
+		scopes, err := entities.ParseAuthScopes(scopeList)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scopes for API key %s: %w", keyFingerprint(key), err)
+		}
+		if len(scopes) == 0 {
+			return nil, fmt.Errorf("API key %s has no scopes", keyFingerprint(key))
+		}
+
+		digest := sha256.Sum256([]byte(key))
+		if seen[digest] {
+			return nil, fmt.Errorf("API key %s is listed twice", keyFingerprint(key))
+		}
+		seen[digest] = true
+		authenticator.keys = append(authenticator.keys, apiKey{digest: digest, scopes: scopes})
+	}
+
+	if len(authenticator.keys) == 0 {
+		return nil, errors.New("no API keys configured")
+	}
+	return authenticator, nil
+}
+
+// Authenticate matches the X-API-Key header against the configured keys
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context, credentials interfaces.APICredentials) (*entities.Principal, error) {
+	if credentials.APIKey == "" {
+		return nil, nil
+	}
+
+	// Every key is compared so the time taken does not reveal which matched
+	digest := sha256.Sum256([]byte(credentials.APIKey))
+	var match *apiKey
+	for i := range a.keys {
+		if subtle.ConstantTimeCompare(digest[:], a.keys[i].digest[:]) == 1 {
+			match = &a.keys[i]
+		}
+	}
+	if match == nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &entities.Principal{
+		Subject: keyFingerprint(credentials.APIKey),
+		Method:  AuthMethodAPIKey,
+		Scopes:  append([]entities.AuthScope(nil), match.scopes...),
+	}, nil
+}
+
+// keyFingerprint identifies a key in logs without revealing it
+func keyFingerprint(key string) string {
+	digest := sha256.Sum256([]byte(key))
+	return "key_" + hex.EncodeToString(digest[:4])
+}
+
+// JWTConfig represents the validation of bearer tokens
+type JWTConfig struct {
+	Secret   string // HMAC key shared with the token issuer
+	Issuer   string // required iss claim, if set
+	Audience string // required aud entry, if set
+}
+
+// JWTAuthenticator authenticates HMAC signed JWT bearer tokens. Scopes come
+// from the space separated scope claim or the scopes array claim.
+type JWTAuthenticator struct {
+	config JWTConfig
+	now    func() time.Time
+}
+
+// jwtHashes are the accepted signing algorithms; none and asymmetric
+// algorithms are rejected
+var jwtHashes = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// jwtClaims are the registered and scope claims read from a token
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *int64          `json:"exp"`
+	NotBefore *int64          `json:"nbf"`
+	Scope     string          `json:"scope"`
+	Scopes    []string        `json:"scopes"`
+}
+
+// NewJWTAuthenticator creates a bearer token authenticator
+func NewJWTAuthenticator(config JWTConfig) (interfaces.Authenticator, error) {
+	if config.Secret == "" {
+		return nil, errors.New("JWT secret is required")
+	}
+	return &JWTAuthenticator{config: config, now: time.Now}, nil
+}
+
+// Authenticate validates the bearer token's signature and claims
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, credentials interfaces.APICredentials) (*entities.Principal, error) {
+	if credentials.BearerToken == "" {
+		return nil, nil
+	}
+
+	claims, err := a.verify(credentials.BearerToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	scopeList := claims.Scope
+	if len(claims.Scopes) > 0 {
+		scopeList = strings.Join(claims.Scopes, " ")
+	}
+	scopes, err := entities.ParseAuthScopes(scopeList)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject := claims.Subject
+	if subject == "" {
+		subject = "unknown"
+	}
+	return &entities.Principal{
+		Subject: subject,
+		Method:  AuthMethodJWT,
+		Scopes:  scopes,
+	}, nil
+}
+
+// verify checks a compact serialized token and returns its claims
+func (a *JWTAuthenticator) verify(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed header: %w", err)
+	}
+	newHash, ok := jwtHashes[header.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	mac := hmac.New(newHash, []byte(a.config.Secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("signature mismatch")
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed claims: %w", err)
+	}
+
+	now := a.now()
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtClockSkew)) {
+		return nil, errors.New("token expired")
+	}
+	if claims.NotBefore != nil && now.Add(jwtClockSkew).Before(time.Unix(*claims.NotBefore, 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	if a.config.Issuer != "" && claims.Issuer != a.config.Issuer {
+		return nil, errors.New("unexpected issuer")
+	}
+	if a.config.Audience != "" && !hasAudience(claims.Audience, a.config.Audience) {
+		return nil, errors.New("unexpected audience")
+	}
+
+	return &claims, nil
+}
+
+// decodeJWTSegment decodes a base64url JSON token segment
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// hasAudience reports whether an aud claim, a string or an array, names audience
+func hasAudience(claim json.RawMessage, audience string) bool {
+	var single string
+	if json.Unmarshal(claim, &single) == nil {
+		return single == audience
+	}
+	var many []string
+	if json.Unmarshal(claim, &many) == nil {
+		for _, entry := range many {
+			if entry == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
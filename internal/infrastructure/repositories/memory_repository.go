@@ -0,0 +1,983 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryPaymentRepository implements PaymentRepository in process memory.
+// Records are copied on write and read so callers never share state with the
+// store, matching the semantics of the database-backed repositories.
+type MemoryPaymentRepository struct {
+	mutex    sync.RWMutex
+	payments []*entities.Payment
+	logger   interfaces.Logger
+}
+
+// NewMemoryPaymentRepository creates a new in-memory payment repository
+func NewMemoryPaymentRepository(logger interfaces.Logger) interfaces.PaymentRepository {
+	return &MemoryPaymentRepository{
+		logger: logger,
+	}
+}
+
+// Create creates a new payment record
+func (r *MemoryPaymentRepository) Create(ctx context.Context, payment *entities.Payment) error {
+	if payment == nil {
+		return errors.New("payment cannot be nil")
+	}
+
+	now := time.Now()
+	if payment.CreatedAt.IsZero() {
+		payment.CreatedAt = now
+	}
+	if payment.UpdatedAt.IsZero() {
+		payment.UpdatedAt = now
+	}
+
+	stored, err := clonePayment(payment)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.payments {
+		if existing.ID == payment.ID {
+			return fmt.Errorf("payment %s already exists", payment.ID)
+		}
+	}
+	r.payments = append(r.payments, stored)
+
+	r.logger.Debug("Payment record stored in memory", map[string]interface{}{
+		"payment_id": payment.ID,
+		"order_id":   payment.OrderID,
+		"status":     payment.Status,
+	})
+
+	return nil
+}
+
+// GetByOrderID retrieves payments for a specific order, oldest first
+func (r *MemoryPaymentRepository) GetByOrderID(ctx context.Context, orderID string) ([]*entities.Payment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var payments []*entities.Payment
+	for _, payment := range r.payments {
+		if payment.OrderID != orderID {
+			continue
+		}
+		clone, err := clonePayment(payment)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, clone)
+	}
+
+	return payments, nil
+}
+
+// GetByPaymentID retrieves the most recent payment with the given PayPal payment ID
+func (r *MemoryPaymentRepository) GetByPaymentID(ctx context.Context, paymentID string) (*entities.Payment, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for i := len(r.payments) - 1; i >= 0; i-- {
+		if r.payments[i].PaymentID == paymentID {
+			return clonePayment(r.payments[i])
+		}
+	}
+
+	return nil, fmt.Errorf("payment %s %w", paymentID, interfaces.ErrNotFound)
+}
+
+// UpdateStatus updates the status of every record with the given PayPal payment ID
+func (r *MemoryPaymentRepository) UpdateStatus(ctx context.Context, paymentID string, status entities.PaymentStatus) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var matched []*entities.Payment
+	for _, payment := range r.payments {
+		if payment.PaymentID == paymentID {
+			matched = append(matched, payment)
+		}
+	}
+
+	if len(matched) == 0 {
+		return fmt.Errorf("payment %s %w", paymentID, interfaces.ErrNotFound)
+	}
+
+	// Check every record first so a rejected transition changes none of them
+	for _, payment := range matched {
+		if !payment.Status.CanTransitionTo(status) {
+			return &entities.StatusTransitionError{Entity: "payment", From: string(payment.Status), To: string(status)}
+		}
+	}
+
+	now := time.Now()
+	for _, payment := range matched {
+		if err := applyPaymentStatus(payment, status, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RecordRefund adds a refund to the most recent payment with the given PayPal payment ID
+func (r *MemoryPaymentRepository) RecordRefund(ctx context.Context, paymentID string, refund entities.PaymentRefund) (*entities.Payment, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := len(r.payments) - 1; i >= 0; i-- {
+		if r.payments[i].PaymentID != paymentID {
+			continue
+		}
+
+		// Refund a copy so a rejected refund leaves the record untouched
+		updated, err := clonePayment(r.payments[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := updated.ApplyRefund(refund, "refunded"); err != nil {
+			return nil, err
+		}
+		r.payments[i] = updated
+
+		return clonePayment(updated)
+	}
+
+	return nil, fmt.Errorf("payment %s %w", paymentID, interfaces.ErrNotFound)
+}
+
+// MemoryOrderRepository implements OrderRepository in process memory
+type MemoryOrderRepository struct {
+	mutex  sync.RWMutex
+	orders map[int]*entities.Order
+	nextID int
+	logger interfaces.Logger
+}
+
+// NewMemoryOrderRepository creates a new in-memory order repository
+func NewMemoryOrderRepository(logger interfaces.Logger) interfaces.OrderRepository {
+	return &MemoryOrderRepository{
+		orders: make(map[int]*entities.Order),
+		nextID: 1,
+		logger: logger,
+	}
+}
+
+// GetByID retrieves an order by its ID
+func (r *MemoryOrderRepository) GetByID(ctx context.Context, id string) (*entities.Order, error) {
+	orderID, err := parseOrderID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	order, exists := r.orders[orderID]
+	if !exists {
+		return nil, fmt.Errorf("order %s not found", id)
+	}
+
+	return cloneOrder(order)
+}
+
+// Create creates a new order, assigning an ID when none is set
+func (r *MemoryOrderRepository) Create(ctx context.Context, order *entities.Order) (*entities.Order, error) {
+	if order == nil {
+		return nil, errors.New("order cannot be nil")
+	}
+
+	stored, err := cloneOrder(order)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if stored.ID == 0 {
+		stored.ID = r.nextID
+	}
+	if _, exists := r.orders[stored.ID]; exists {
+		return nil, fmt.Errorf("order %d already exists", stored.ID)
+	}
+	if stored.ID >= r.nextID {
+		r.nextID = stored.ID + 1
+	}
+	if stored.DateCreated.IsZero() {
+		stored.DateCreated = time.Now()
+	}
+
+	r.orders[stored.ID] = stored
+
+	return cloneOrder(stored)
+}
+
+// Update updates an existing order
+func (r *MemoryOrderRepository) Update(ctx context.Context, id string, order *entities.Order) error {
+	return r.modify(id, func(existing *entities.Order) error {
+		updated, err := cloneOrder(order)
+		if err != nil {
+			return err
+		}
+		updated.ID = existing.ID
+		*existing = *updated
+		return nil
+	})
+}
+
+// UpdateStatus updates only the order status
+func (r *MemoryOrderRepository) UpdateStatus(ctx context.Context, id string, status entities.OrderStatus) error {
+	return r.modify(id, func(existing *entities.Order) error {
+		return existing.TransitionTo(status, "status update")
+	})
+}
+
+// UpdatePaymentInfo updates payment-related information
+func (r *MemoryOrderRepository) UpdatePaymentInfo(ctx context.Context, id string, payment *entities.Payment) error {
+	if payment == nil {
+		return errors.New("payment cannot be nil")
+	}
+
+	return r.modify(id, func(existing *entities.Order) error {
+		return applyPaymentInfo(existing, payment, time.Now())
+	})
+}
+
+// modify applies fn to a stored order under the write lock
+func (r *MemoryOrderRepository) modify(id string, fn func(*entities.Order) error) error {
+	orderID, err := parseOrderID(id)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	order, exists := r.orders[orderID]
+	if !exists {
+		return fmt.Errorf("order %s not found", id)
+	}
+
+	return fn(order)
+}
+
+// MemoryOrderMappingRepository implements OrderMappingRepository in process memory
+type MemoryOrderMappingRepository struct {
+	mutex    sync.RWMutex
+	mappings []*entities.OrderMapping
+	logger   interfaces.Logger
+}
+
+// NewMemoryOrderMappingRepository creates a new in-memory order mapping repository
+func NewMemoryOrderMappingRepository(logger interfaces.Logger) interfaces.OrderMappingRepository {
+	return &MemoryOrderMappingRepository{
+		logger: logger,
+	}
+}
+
+// Create stores a new mapping
+func (r *MemoryOrderMappingRepository) Create(ctx context.Context, mapping *entities.OrderMapping) error {
+	if mapping == nil {
+		return errors.New("order mapping cannot be nil")
+	}
+
+	stored := *mapping
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.mappings {
+		if existing.OITAMOrderID == mapping.OITAMOrderID {
+			return fmt.Errorf("order mapping for OITAM order %s already exists", mapping.OITAMOrderID)
+		}
+	}
+	r.mappings = append(r.mappings, &stored)
+
+	r.logger.Debug("Order mapping stored in memory", map[string]interface{}{
+		"order_id":       mapping.MagicOrderID,
+		"oitam_order_id": mapping.OITAMOrderID,
+	})
+
+	return nil
+}
+
+// GetByMagicOrderID retrieves the most recent mapping for a MagicSpore order
+func (r *MemoryOrderMappingRepository) GetByMagicOrderID(ctx context.Context, magicOrderID string) (*entities.OrderMapping, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for i := len(r.mappings) - 1; i >= 0; i-- {
+		if r.mappings[i].MagicOrderID == magicOrderID {
+			mapping := *r.mappings[i]
+			return &mapping, nil
+		}
+	}
+
+	return nil, fmt.Errorf("order mapping for order %s %w", magicOrderID, interfaces.ErrNotFound)
+}
+
+// GetByOITAMOrderID retrieves the mapping for an OITAM proxy order
+func (r *MemoryOrderMappingRepository) GetByOITAMOrderID(ctx context.Context, oitamOrderID string) (*entities.OrderMapping, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, existing := range r.mappings {
+		if existing.OITAMOrderID == oitamOrderID {
+			mapping := *existing
+			return &mapping, nil
+		}
+	}
+
+	return nil, fmt.Errorf("order mapping for OITAM order %s %w", oitamOrderID, interfaces.ErrNotFound)
+}
+
+// UpdateState updates the state of the mapping for an OITAM proxy order
+func (r *MemoryOrderMappingRepository) UpdateState(ctx context.Context, oitamOrderID string, state entities.OrderMappingState) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.mappings {
+		if existing.OITAMOrderID == oitamOrderID {
+			existing.State = state
+			existing.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+
+	return fmt.Errorf("order mapping for OITAM order %s %w", oitamOrderID, interfaces.ErrNotFound)
+}
+
+// ListByState retrieves up to limit mappings in a state created before
+// createdBefore and after the after mapping, oldest first
+func (r *MemoryOrderMappingRepository) ListByState(ctx context.Context, state entities.OrderMappingState, createdBefore time.Time, after *entities.OrderMapping, limit int) ([]*entities.OrderMapping, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var mappings []*entities.OrderMapping
+	for _, existing := range r.mappings {
+		if existing.State != state || !existing.CreatedAt.Before(createdBefore) {
+			continue
+		}
+		if after != nil && !mappingSortsAfter(existing, after) {
+			continue
+		}
+		mapping := *existing
+		mappings = append(mappings, &mapping)
+	}
+
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappingSortsAfter(mappings[j], mappings[i])
+	})
+	if len(mappings) > limit {
+		mappings = mappings[:limit]
+	}
+
+	return mappings, nil
+}
+
+// mappingSortsAfter reports whether mapping comes after other by creation
+// time, then OITAM order ID
+func mappingSortsAfter(mapping, other *entities.OrderMapping) bool {
+	if !mapping.CreatedAt.Equal(other.CreatedAt) {
+		return mapping.CreatedAt.After(other.CreatedAt)
+	}
+	return mapping.OITAMOrderID > other.OITAMOrderID
+}
+
+// MemoryWebhookEventRepository implements WebhookEventRepository in process memory
+type MemoryWebhookEventRepository struct {
+	mutex  sync.RWMutex
+	events []*entities.WebhookEvent
+	logger interfaces.Logger
+}
+
+// NewMemoryWebhookEventRepository creates a new in-memory webhook event repository
+func NewMemoryWebhookEventRepository(logger interfaces.Logger) interfaces.WebhookEventRepository {
+	return &MemoryWebhookEventRepository{
+		logger: logger,
+	}
+}
+
+// Create stores a new event, rejecting IDs that were already recorded
+func (r *MemoryWebhookEventRepository) Create(ctx context.Context, event *entities.WebhookEvent) error {
+	if event == nil {
+		return errors.New("webhook event cannot be nil")
+	}
+
+	stored := copyWebhookEvent(event)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.events {
+		if existing.ID == event.ID {
+			return entities.ErrDuplicateWebhookEvent
+		}
+	}
+	r.events = append(r.events, stored)
+
+	r.logger.Debug("Webhook event stored in memory", map[string]interface{}{
+		"event_id":   event.ID,
+		"event_type": event.EventType,
+		"signature":  event.Signature,
+	})
+
+	return nil
+}
+
+// GetByID retrieves an event by its PayPal event ID
+func (r *MemoryWebhookEventRepository) GetByID(ctx context.Context, id string) (*entities.WebhookEvent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, existing := range r.events {
+		if existing.ID == id {
+			return copyWebhookEvent(existing), nil
+		}
+	}
+
+	return nil, fmt.Errorf("webhook event %s %w", id, interfaces.ErrNotFound)
+}
+
+// Update replaces a stored event
+func (r *MemoryWebhookEventRepository) Update(ctx context.Context, event *entities.WebhookEvent) error {
+	if event == nil {
+		return errors.New("webhook event cannot be nil")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, existing := range r.events {
+		if existing.ID == event.ID {
+			r.events[i] = copyWebhookEvent(event)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("webhook event %s %w", event.ID, interfaces.ErrNotFound)
+}
+
+// ListByReceivedAt retrieves events received in [from, to), oldest first
+func (r *MemoryWebhookEventRepository) ListByReceivedAt(ctx context.Context, from, to time.Time) ([]*entities.WebhookEvent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var events []*entities.WebhookEvent
+	for _, event := range r.events {
+		if event.ReceivedAt.Before(from) || !event.ReceivedAt.Before(to) {
+			continue
+		}
+		events = append(events, copyWebhookEvent(event))
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ReceivedAt.Before(events[j].ReceivedAt)
+	})
+
+	return events, nil
+}
+
+// ListByStatus retrieves up to limit events in a status, oldest first
+func (r *MemoryWebhookEventRepository) ListByStatus(ctx context.Context, status entities.WebhookEventStatus, limit int) ([]*entities.WebhookEvent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var events []*entities.WebhookEvent
+	for _, event := range r.events {
+		if event.Status == status {
+			events = append(events, copyWebhookEvent(event))
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].ReceivedAt.Before(events[j].ReceivedAt)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+// ListDue retrieves up to limit queued events whose next attempt is at or before now
+func (r *MemoryWebhookEventRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.WebhookEvent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var events []*entities.WebhookEvent
+	for _, event := range r.events {
+		if event.IsQueued() && !event.NextAttemptAt.After(now) {
+			events = append(events, copyWebhookEvent(event))
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].NextAttemptAt.Before(*events[j].NextAttemptAt)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+
+	return events, nil
+}
+
+// Claim moves a due event's next attempt to leaseUntil if it is unchanged since it was read
+func (r *MemoryWebhookEventRepository) Claim(ctx context.Context, event *entities.WebhookEvent, leaseUntil time.Time) (bool, error) {
+	if event == nil || !event.IsQueued() {
+		return false, errors.New("only queued webhook events can be claimed")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.events {
+		if existing.ID != event.ID {
+			continue
+		}
+		if !existing.IsQueued() || !existing.NextAttemptAt.Equal(*event.NextAttemptAt) {
+			return false, nil
+		}
+		stored := leaseUntil
+		existing.NextAttemptAt = &stored
+		event.NextAttemptAt = &leaseUntil
+		return true, nil
+	}
+
+	return false, fmt.Errorf("webhook event %s %w", event.ID, interfaces.ErrNotFound)
+}
+
+// MemoryDisputeRepository implements DisputeRepository in process memory
+type MemoryDisputeRepository struct {
+	mutex    sync.RWMutex
+	disputes []*entities.Dispute
+	logger   interfaces.Logger
+}
+
+// NewMemoryDisputeRepository creates a new in-memory dispute repository
+func NewMemoryDisputeRepository(logger interfaces.Logger) interfaces.DisputeRepository {
+	return &MemoryDisputeRepository{
+		logger: logger,
+	}
+}
+
+// Save stores a dispute, replacing any earlier record with its ID
+func (r *MemoryDisputeRepository) Save(ctx context.Context, dispute *entities.Dispute) error {
+	if dispute == nil {
+		return errors.New("dispute cannot be nil")
+	}
+
+	stored := copyDispute(dispute)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, existing := range r.disputes {
+		if existing.ID == dispute.ID {
+			r.disputes[i] = stored
+			return nil
+		}
+	}
+	r.disputes = append(r.disputes, stored)
+
+	r.logger.Debug("Dispute stored in memory", map[string]interface{}{
+		"dispute_id": dispute.ID,
+		"order_id":   dispute.OrderID,
+		"status":     dispute.Status,
+	})
+
+	return nil
+}
+
+// GetByID retrieves a dispute by its PayPal dispute ID
+func (r *MemoryDisputeRepository) GetByID(ctx context.Context, id string) (*entities.Dispute, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, existing := range r.disputes {
+		if existing.ID == id {
+			return copyDispute(existing), nil
+		}
+	}
+
+	return nil, fmt.Errorf("dispute %s %w", id, interfaces.ErrNotFound)
+}
+
+// ListByOrderID retrieves the disputes of a MagicSpore order, oldest first
+func (r *MemoryDisputeRepository) ListByOrderID(ctx context.Context, orderID string) ([]*entities.Dispute, error) {
+	return r.list(func(dispute *entities.Dispute) bool {
+		return dispute.OrderID == orderID
+	}), nil
+}
+
+// ListOpen retrieves every dispute that is not resolved yet, oldest first
+func (r *MemoryDisputeRepository) ListOpen(ctx context.Context) ([]*entities.Dispute, error) {
+	return r.list(func(dispute *entities.Dispute) bool {
+		return dispute.IsOpen()
+	}), nil
+}
+
+// list copies the disputes matching keep, oldest first
+func (r *MemoryDisputeRepository) list(keep func(*entities.Dispute) bool) []*entities.Dispute {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var disputes []*entities.Dispute
+	for _, dispute := range r.disputes {
+		if keep(dispute) {
+			disputes = append(disputes, copyDispute(dispute))
+		}
+	}
+
+	sort.SliceStable(disputes, func(i, j int) bool {
+		return disputes[i].CreatedAt.Before(disputes[j].CreatedAt)
+	})
+
+	return disputes
+}
+
+// MemoryOutboxRepository implements OutboxRepository in process memory
+type MemoryOutboxRepository struct {
+	mutex        sync.RWMutex
+	entries      []*entities.OutboxEntry // in sequence order
+	lastSequence int64
+	logger       interfaces.Logger
+}
+
+// NewMemoryOutboxRepository creates a new in-memory outbox repository
+func NewMemoryOutboxRepository(logger interfaces.Logger) interfaces.OutboxRepository {
+	return &MemoryOutboxRepository{
+		logger: logger,
+	}
+}
+
+// Create stores a new entry at the end of its order's queue, assigning its sequence
+func (r *MemoryOutboxRepository) Create(ctx context.Context, entry *entities.OutboxEntry) error {
+	if entry == nil {
+		return errors.New("outbox entry cannot be nil")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.entries {
+		if existing.ID == entry.ID {
+			return fmt.Errorf("outbox entry %s already exists", entry.ID)
+		}
+	}
+
+	r.lastSequence++
+	entry.Sequence = r.lastSequence
+	stored, err := cloneOutboxEntry(entry)
+	if err != nil {
+		return err
+	}
+	r.entries = append(r.entries, stored)
+
+	r.logger.Debug("Outbox entry stored in memory", map[string]interface{}{
+		"entry_id":  entry.ID,
+		"order_id":  entry.OrderID,
+		"operation": entry.Operation,
+	})
+
+	return nil
+}
+
+// GetByID retrieves an entry by its ID
+func (r *MemoryOutboxRepository) GetByID(ctx context.Context, id string) (*entities.OutboxEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, existing := range r.entries {
+		if existing.ID == id {
+			return cloneOutboxEntry(existing)
+		}
+	}
+
+	return nil, fmt.Errorf("outbox entry %s %w", id, interfaces.ErrNotFound)
+}
+
+// Update replaces a stored entry
+func (r *MemoryOutboxRepository) Update(ctx context.Context, entry *entities.OutboxEntry) error {
+	if entry == nil {
+		return errors.New("outbox entry cannot be nil")
+	}
+
+	stored, err := cloneOutboxEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, existing := range r.entries {
+		if existing.ID == entry.ID {
+			stored.Sequence = existing.Sequence
+			r.entries[i] = stored
+			return nil
+		}
+	}
+
+	return fmt.Errorf("outbox entry %s %w", entry.ID, interfaces.ErrNotFound)
+}
+
+// ListByOrderID retrieves the entries of a MagicSpore order in sequence order
+func (r *MemoryOutboxRepository) ListByOrderID(ctx context.Context, orderID string) ([]*entities.OutboxEntry, error) {
+	return r.list(-1, func(entry *entities.OutboxEntry) bool {
+		return entry.OrderID == orderID
+	})
+}
+
+// ListDue retrieves up to limit pending entries due at or before now that no
+// undelivered entry of the same order precedes, in sequence order
+func (r *MemoryOutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.OutboxEntry, error) {
+	blocked := make(map[string]bool)
+	return r.list(limit, func(entry *entities.OutboxEntry) bool {
+		if !entry.IsUndelivered() || blocked[entry.OrderID] {
+			return false
+		}
+		blocked[entry.OrderID] = true
+		return entry.IsPending() && !entry.NextAttemptAt.After(now)
+	})
+}
+
+// ListUndelivered retrieves up to limit pending or failed entries created
+// before createdBefore, oldest first
+func (r *MemoryOutboxRepository) ListUndelivered(ctx context.Context, createdBefore time.Time, limit int) ([]*entities.OutboxEntry, error) {
+	return r.list(limit, func(entry *entities.OutboxEntry) bool {
+		return entry.IsUndelivered() && entry.CreatedAt.Before(createdBefore)
+	})
+}
+
+// Claim moves a pending entry's next attempt to leaseUntil if it is unchanged since it was read
+func (r *MemoryOutboxRepository) Claim(ctx context.Context, entry *entities.OutboxEntry, leaseUntil time.Time) (bool, error) {
+	if entry == nil || !entry.IsPending() {
+		return false, errors.New("only pending outbox entries can be claimed")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.entries {
+		if existing.ID != entry.ID {
+			continue
+		}
+		if !existing.IsPending() || !existing.NextAttemptAt.Equal(*entry.NextAttemptAt) {
+			return false, nil
+		}
+		stored := leaseUntil
+		existing.NextAttemptAt = &stored
+		entry.NextAttemptAt = &leaseUntil
+		return true, nil
+	}
+
+	return false, fmt.Errorf("outbox entry %s %w", entry.ID, interfaces.ErrNotFound)
+}
+
+// list copies up to limit entries matching keep in sequence order; a negative
+// limit returns them all
+func (r *MemoryOutboxRepository) list(limit int, keep func(*entities.OutboxEntry) bool) ([]*entities.OutboxEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var entries []*entities.OutboxEntry
+	for _, entry := range r.entries {
+		if limit >= 0 && len(entries) >= limit {
+			break
+		}
+		if !keep(entry) {
+			continue
+		}
+		clone, err := cloneOutboxEntry(entry)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, clone)
+	}
+
+	return entries, nil
+}
+
+// MemoryOrderEventRepository implements OrderEventRepository in process memory
+type MemoryOrderEventRepository struct {
+	mutex  sync.RWMutex
+	events []*entities.OrderEvent // in append order
+	logger interfaces.Logger
+}
+
+// NewMemoryOrderEventRepository creates a new in-memory order event repository
+func NewMemoryOrderEventRepository(logger interfaces.Logger) interfaces.OrderEventRepository {
+	return &MemoryOrderEventRepository{
+		logger: logger,
+	}
+}
+
+// Append adds an event to the end of its order's timeline
+func (r *MemoryOrderEventRepository) Append(ctx context.Context, event *entities.OrderEvent) error {
+	if event == nil {
+		return errors.New("order event cannot be nil")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.events {
+		if existing.ID == event.ID {
+			return fmt.Errorf("order event %s already exists", event.ID)
+		}
+	}
+	r.events = append(r.events, copyOrderEvent(event))
+
+	return nil
+}
+
+// ListByOrderID retrieves the timeline of a MagicSpore order in append order
+func (r *MemoryOrderEventRepository) ListByOrderID(ctx context.Context, orderID string) ([]*entities.OrderEvent, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var events []*entities.OrderEvent
+	for _, event := range r.events {
+		if event.OrderID == orderID {
+			events = append(events, copyOrderEvent(event))
+		}
+	}
+
+	return events, nil
+}
+
+// Shared helpers for the embedded repositories
+
+// applyPaymentStatus moves a payment to a new status through its state
+// machine, stamping completion time
+func applyPaymentStatus(payment *entities.Payment, status entities.PaymentStatus, now time.Time) error {
+	if err := payment.TransitionTo(status, "status update"); err != nil {
+		return err
+	}
+	payment.UpdatedAt = now
+	if status == entities.PaymentStatusCompleted && payment.CompletedAt == nil {
+		payment.CompletedAt = &now
+	}
+	return nil
+}
+
+// applyPaymentInfo mirrors WooCommerceRepository.UpdateMagicOrderPayment for
+// stored orders. Orders that cannot move to processing are left unchanged.
+func applyPaymentInfo(order *entities.Order, payment *entities.Payment, now time.Time) error {
+	if payment.IsCompleted() {
+		if err := order.TransitionTo(entities.StatusProcessing, "payment completed"); err != nil {
+			return err
+		}
+		order.DatePaid = &now
+	}
+
+	order.PaymentMethod = string(entities.PaymentMethodPayPal)
+	order.PaymentMethodTitle = "PayPal"
+	order.TransactionID = payment.TransactionID
+	order.MetaData = append(order.MetaData,
+		entities.MetaData{Key: "_paypal_payment_id", Value: payment.PaymentID},
+		entities.MetaData{Key: "_payment_completed_at", Value: now.Unix()},
+		entities.MetaData{Key: "_proxy_payment_processed", Value: "true"},
+	)
+
+	return nil
+}
+
+// parseOrderID converts a repository order ID into the integer key
+func parseOrderID(id string) (int, error) {
+	orderID, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, fmt.Errorf("invalid order ID: %s", id)
+	}
+	return orderID, nil
+}
+
+// clonePayment deep-copies a payment through its JSON representation
+func clonePayment(payment *entities.Payment) (*entities.Payment, error) {
+	var clone entities.Payment
+	if err := cloneJSON(payment, &clone); err != nil {
+		return nil, fmt.Errorf("failed to copy payment: %w", err)
+	}
+	return &clone, nil
+}
+
+// cloneOrder deep-copies an order through its JSON representation
+func cloneOrder(order *entities.Order) (*entities.Order, error) {
+	var clone entities.Order
+	if err := cloneJSON(order, &clone); err != nil {
+		return nil, fmt.Errorf("failed to copy order: %w", err)
+	}
+	return &clone, nil
+}
+
+// cloneOutboxEntry deep-copies an outbox entry through its JSON representation
+func cloneOutboxEntry(entry *entities.OutboxEntry) (*entities.OutboxEntry, error) {
+	var clone entities.OutboxEntry
+	if err := cloneJSON(entry, &clone); err != nil {
+		return nil, fmt.Errorf("failed to copy outbox entry: %w", err)
+	}
+	return &clone, nil
+}
+
+// copyWebhookEvent copies an event, including its optional timestamps
+func copyWebhookEvent(event *entities.WebhookEvent) *entities.WebhookEvent {
+	clone := *event
+	if event.ProcessedAt != nil {
+		processedAt := *event.ProcessedAt
+		clone.ProcessedAt = &processedAt
+	}
+	if event.NextAttemptAt != nil {
+		nextAttemptAt := *event.NextAttemptAt
+		clone.NextAttemptAt = &nextAttemptAt
+	}
+	return &clone
+}
+
+// copyDispute copies a dispute, including its optional timestamps
+func copyDispute(dispute *entities.Dispute) *entities.Dispute {
+	clone := *dispute
+	for _, field := range []**time.Time{&clone.SellerResponseDueAt, &clone.BuyerResponseDueAt, &clone.ResolvedAt} {
+		if *field != nil {
+			value := **field
+			*field = &value
+		}
+	}
+	return &clone
+}
+
+// copyOrderEvent copies an event, including its details
+func copyOrderEvent(event *entities.OrderEvent) *entities.OrderEvent {
+	clone := *event
+	if event.Details != nil {
+		clone.Details = make(map[string]string, len(event.Details))
+		for key, value := range event.Details {
+			clone.Details[key] = value
+		}
+	}
+	return &clone
+}
+
+// cloneJSON copies src into dst via JSON encoding
+func cloneJSON(src, dst interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dst)
+}
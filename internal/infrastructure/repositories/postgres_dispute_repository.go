@@ -0,0 +1,187 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+)
+
+// PostgresDisputeRepository implements DisputeRepository on top of PostgreSQL
+type PostgresDisputeRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewPostgresDisputeRepository creates a new PostgreSQL dispute repository
+func NewPostgresDisputeRepository(db *sql.DB, logger interfaces.Logger) interfaces.DisputeRepository {
+	return &PostgresDisputeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const disputeColumns = `id, order_id, oitam_order_id, transaction_id, reason, status, stage, outcome,
+	amount, currency, seller_response_due_at, buyer_response_due_at, created_at, updated_at, resolved_at`
+
+// Save stores a dispute, replacing any earlier record with its ID
+func (r *PostgresDisputeRepository) Save(ctx context.Context, dispute *entities.Dispute) error {
+	if dispute == nil {
+		return errors.New("dispute cannot be nil")
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO disputes (`+disputeColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (id) DO UPDATE SET
+			order_id = EXCLUDED.order_id,
+			oitam_order_id = EXCLUDED.oitam_order_id,
+			transaction_id = EXCLUDED.transaction_id,
+			reason = EXCLUDED.reason,
+			status = EXCLUDED.status,
+			stage = EXCLUDED.stage,
+			outcome = EXCLUDED.outcome,
+			amount = EXCLUDED.amount,
+			currency = EXCLUDED.currency,
+			seller_response_due_at = EXCLUDED.seller_response_due_at,
+			buyer_response_due_at = EXCLUDED.buyer_response_due_at,
+			updated_at = EXCLUDED.updated_at,
+			resolved_at = EXCLUDED.resolved_at`,
+		dispute.ID,
+		dispute.OrderID,
+		dispute.OITAMOrderID,
+		dispute.TransactionID,
+		dispute.Reason,
+		string(dispute.Status),
+		dispute.Stage,
+		dispute.Outcome,
+		dispute.Amount.Decimal(),
+		dispute.Amount.Currency,
+		nullableTime(dispute.SellerResponseDueAt),
+		nullableTime(dispute.BuyerResponseDueAt),
+		dispute.CreatedAt.UTC(),
+		dispute.UpdatedAt.UTC(),
+		nullableTime(dispute.ResolvedAt),
+	)
+	if err != nil {
+		r.logger.Error("Failed to save dispute", err, map[string]interface{}{
+			"dispute_id": dispute.ID,
+			"order_id":   dispute.OrderID,
+		})
+		return fmt.Errorf("failed to save dispute: %w", err)
+	}
+
+	r.logger.Info("Dispute stored", map[string]interface{}{
+		"dispute_id": dispute.ID,
+		"order_id":   dispute.OrderID,
+		"status":     dispute.Status,
+	})
+
+	return nil
+}
+
+// GetByID retrieves a dispute by its PayPal dispute ID
+func (r *PostgresDisputeRepository) GetByID(ctx context.Context, id string) (*entities.Dispute, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+disputeColumns+` FROM disputes WHERE id = $1`, id)
+
+	dispute, err := scanDispute(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("dispute %s %w", id, interfaces.ErrNotFound)
+	}
+	return dispute, err
+}
+
+// ListByOrderID retrieves the disputes of a MagicSpore order, oldest first
+func (r *PostgresDisputeRepository) ListByOrderID(ctx context.Context, orderID string) ([]*entities.Dispute, error) {
+	return r.query(ctx,
+		`SELECT `+disputeColumns+` FROM disputes WHERE order_id = $1 ORDER BY created_at, id`,
+		orderID,
+	)
+}
+
+// ListOpen retrieves every dispute that is not resolved yet, oldest first
+func (r *PostgresDisputeRepository) ListOpen(ctx context.Context) ([]*entities.Dispute, error) {
+	return r.query(ctx,
+		`SELECT `+disputeColumns+` FROM disputes WHERE status <> $1 ORDER BY created_at, id`,
+		string(entities.DisputeStatusResolved),
+	)
+}
+
+// query runs a select over disputes rows
+func (r *PostgresDisputeRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entities.Dispute, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disputes: %w", err)
+	}
+	defer rows.Close()
+
+	var disputes []*entities.Dispute
+	for rows.Next() {
+		dispute, err := scanDispute(rows)
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, dispute)
+	}
+
+	return disputes, rows.Err()
+}
+
+// scanDispute maps a disputes row to a domain entity
+func scanDispute(row rowScanner) (*entities.Dispute, error) {
+	var (
+		dispute             entities.Dispute
+		status              string
+		amount              string
+		currency            string
+		sellerResponseDueAt sql.NullTime
+		buyerResponseDueAt  sql.NullTime
+		resolvedAt          sql.NullTime
+	)
+
+	err := row.Scan(
+		&dispute.ID,
+		&dispute.OrderID,
+		&dispute.OITAMOrderID,
+		&dispute.TransactionID,
+		&dispute.Reason,
+		&status,
+		&dispute.Stage,
+		&dispute.Outcome,
+		&amount,
+		&currency,
+		&sellerResponseDueAt,
+		&buyerResponseDueAt,
+		&dispute.CreatedAt,
+		&dispute.UpdatedAt,
+		&resolvedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan dispute: %w", err)
+	}
+
+	dispute.Status = entities.DisputeStatus(status)
+	dispute.Amount, err = entities.ParseMoney(amount, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dispute amount: %w", err)
+	}
+
+	if sellerResponseDueAt.Valid {
+		t := sellerResponseDueAt.Time
+		dispute.SellerResponseDueAt = &t
+	}
+	if buyerResponseDueAt.Valid {
+		t := buyerResponseDueAt.Time
+		dispute.BuyerResponseDueAt = &t
+	}
+	if resolvedAt.Valid {
+		t := resolvedAt.Time
+		dispute.ResolvedAt = &t
+	}
+
+	return &dispute, nil
+}
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+)
+
+// PostgresOrderEventRepository implements OrderEventRepository on top of PostgreSQL
+type PostgresOrderEventRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewPostgresOrderEventRepository creates a new PostgreSQL order event repository
+func NewPostgresOrderEventRepository(db *sql.DB, logger interfaces.Logger) interfaces.OrderEventRepository {
+	return &PostgresOrderEventRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const orderEventColumns = `id, order_id, type, message, details, error, occurred_at`
+
+// Append adds an event to the end of its order's timeline
+func (r *PostgresOrderEventRepository) Append(ctx context.Context, event *entities.OrderEvent) error {
+	if event == nil {
+		return errors.New("order event cannot be nil")
+	}
+
+	details := event.Details
+	if details == nil {
+		details = map[string]string{}
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order event details: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO order_events (`+orderEventColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		event.ID,
+		event.OrderID,
+		string(event.Type),
+		event.Message,
+		detailsJSON,
+		event.Error,
+		event.OccurredAt.UTC(),
+	)
+	if err != nil {
+		r.logger.Error("Failed to insert order event", err, map[string]interface{}{
+			"event_id": event.ID,
+			"order_id": event.OrderID,
+		})
+		return fmt.Errorf("failed to append order event: %w", err)
+	}
+
+	return nil
+}
+
+// ListByOrderID retrieves the timeline of a MagicSpore order in append order
+func (r *PostgresOrderEventRepository) ListByOrderID(ctx context.Context, orderID string) ([]*entities.OrderEvent, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+orderEventColumns+` FROM order_events WHERE order_id = $1 ORDER BY seq`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*entities.OrderEvent
+	for rows.Next() {
+		event, err := scanOrderEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// scanOrderEvent maps an order_events row to a domain entity
+func scanOrderEvent(row rowScanner) (*entities.OrderEvent, error) {
+	var (
+		event     entities.OrderEvent
+		eventType string
+		details   []byte
+	)
+
+	err := row.Scan(
+		&event.ID,
+		&event.OrderID,
+		&eventType,
+		&event.Message,
+		&details,
+		&event.Error,
+		&event.OccurredAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan order event: %w", err)
+	}
+
+	event.Type = entities.OrderEventType(eventType)
+	if err := json.Unmarshal(details, &event.Details); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal order event details: %w", err)
+	}
+	if len(event.Details) == 0 {
+		event.Details = nil
+	}
+
+	return &event, nil
+}
@@ -0,0 +1,167 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"time"
+)
+
+// PostgresOrderMappingRepository implements OrderMappingRepository on top of PostgreSQL
+type PostgresOrderMappingRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewPostgresOrderMappingRepository creates a new PostgreSQL order mapping repository
+func NewPostgresOrderMappingRepository(db *sql.DB, logger interfaces.Logger) interfaces.OrderMappingRepository {
+	return &PostgresOrderMappingRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const orderMappingColumns = `oitam_order_id, magic_order_id, order_key, state, created_at, updated_at, expires_at`
+
+// Create stores a new mapping
+func (r *PostgresOrderMappingRepository) Create(ctx context.Context, mapping *entities.OrderMapping) error {
+	if mapping == nil {
+		return errors.New("order mapping cannot be nil")
+	}
+
+	_, err := r.db.ExecContext(ctx, `INSERT INTO order_mappings (`+orderMappingColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		mapping.OITAMOrderID,
+		mapping.MagicOrderID,
+		mapping.OrderKey,
+		string(mapping.State),
+		mapping.CreatedAt.UTC(),
+		mapping.UpdatedAt.UTC(),
+		mapping.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		r.logger.Error("Failed to insert order mapping", err, map[string]interface{}{
+			"order_id":       mapping.MagicOrderID,
+			"oitam_order_id": mapping.OITAMOrderID,
+		})
+		return fmt.Errorf("failed to create order mapping: %w", err)
+	}
+
+	r.logger.Info("Order mapping stored", map[string]interface{}{
+		"order_id":       mapping.MagicOrderID,
+		"oitam_order_id": mapping.OITAMOrderID,
+		"expires_at":     mapping.ExpiresAt,
+	})
+
+	return nil
+}
+
+// GetByMagicOrderID retrieves the most recent mapping for a MagicSpore order
+func (r *PostgresOrderMappingRepository) GetByMagicOrderID(ctx context.Context, magicOrderID string) (*entities.OrderMapping, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+orderMappingColumns+` FROM order_mappings WHERE magic_order_id = $1 ORDER BY created_at DESC LIMIT 1`,
+		magicOrderID,
+	)
+
+	mapping, err := scanOrderMapping(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("order mapping for order %s %w", magicOrderID, interfaces.ErrNotFound)
+	}
+	return mapping, err
+}
+
+// GetByOITAMOrderID retrieves the mapping for an OITAM proxy order
+func (r *PostgresOrderMappingRepository) GetByOITAMOrderID(ctx context.Context, oitamOrderID string) (*entities.OrderMapping, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+orderMappingColumns+` FROM order_mappings WHERE oitam_order_id = $1`,
+		oitamOrderID,
+	)
+
+	mapping, err := scanOrderMapping(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("order mapping for OITAM order %s %w", oitamOrderID, interfaces.ErrNotFound)
+	}
+	return mapping, err
+}
+
+// UpdateState updates the state of the mapping for an OITAM proxy order
+func (r *PostgresOrderMappingRepository) UpdateState(ctx context.Context, oitamOrderID string, state entities.OrderMappingState) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE order_mappings SET state = $2, updated_at = $3 WHERE oitam_order_id = $1`,
+		oitamOrderID, string(state), time.Now().UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order mapping state: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("order mapping for OITAM order %s %w", oitamOrderID, interfaces.ErrNotFound)
+	}
+
+	return nil
+}
+
+// ListByState retrieves up to limit mappings in a state created before
+// createdBefore and after the after mapping, oldest first
+func (r *PostgresOrderMappingRepository) ListByState(ctx context.Context, state entities.OrderMappingState, createdBefore time.Time, after *entities.OrderMapping, limit int) ([]*entities.OrderMapping, error) {
+	query := `SELECT ` + orderMappingColumns + ` FROM order_mappings WHERE state = $1 AND created_at < $2`
+	args := []interface{}{string(state), createdBefore.UTC()}
+	if after != nil {
+		query += ` AND (created_at, oitam_order_id) > ($3, $4)`
+		args = append(args, after.CreatedAt.UTC(), after.OITAMOrderID)
+	}
+	query += fmt.Sprintf(` ORDER BY created_at, oitam_order_id LIMIT $%d`, len(args)+1)
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order mappings: %w", err)
+	}
+	defer rows.Close()
+
+	var mappings []*entities.OrderMapping
+	for rows.Next() {
+		mapping, err := scanOrderMapping(rows)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, rows.Err()
+}
+
+// scanOrderMapping maps an order_mappings row to a domain entity
+func scanOrderMapping(row rowScanner) (*entities.OrderMapping, error) {
+	var (
+		mapping entities.OrderMapping
+		state   string
+	)
+
+	err := row.Scan(
+		&mapping.OITAMOrderID,
+		&mapping.MagicOrderID,
+		&mapping.OrderKey,
+		&state,
+		&mapping.CreatedAt,
+		&mapping.UpdatedAt,
+		&mapping.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan order mapping: %w", err)
+	}
+
+	mapping.State = entities.OrderMappingState(state)
+
+	return &mapping, nil
+}
@@ -0,0 +1,243 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"time"
+)
+
+// PostgresOutboxRepository implements OutboxRepository on top of PostgreSQL
+type PostgresOutboxRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewPostgresOutboxRepository creates a new PostgreSQL outbox repository
+func NewPostgresOutboxRepository(db *sql.DB, logger interfaces.Logger) interfaces.OutboxRepository {
+	return &PostgresOutboxRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const outboxEntryColumns = `seq, id, order_id, operation, payload, status, attempts, last_error,
+	created_at, next_attempt_at, delivered_at`
+
+// Create stores a new entry at the end of its order's queue, assigning its sequence
+func (r *PostgresOutboxRepository) Create(ctx context.Context, entry *entities.OutboxEntry) error {
+	if entry == nil {
+		return errors.New("outbox entry cannot be nil")
+	}
+
+	payload, err := json.Marshal(entry.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox payload: %w", err)
+	}
+
+	err = r.db.QueryRowContext(ctx, `INSERT INTO outbox_entries
+		(id, order_id, operation, payload, status, attempts, last_error, created_at, next_attempt_at, delivered_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING seq`,
+		entry.ID,
+		entry.OrderID,
+		string(entry.Operation),
+		payload,
+		string(entry.Status),
+		entry.Attempts,
+		entry.LastError,
+		entry.CreatedAt.UTC(),
+		nullableTime(entry.NextAttemptAt),
+		nullableTime(entry.DeliveredAt),
+	).Scan(&entry.Sequence)
+	if err != nil {
+		r.logger.Error("Failed to insert outbox entry", err, map[string]interface{}{
+			"entry_id": entry.ID,
+			"order_id": entry.OrderID,
+		})
+		return fmt.Errorf("failed to create outbox entry: %w", err)
+	}
+
+	r.logger.Debug("Outbox entry stored", map[string]interface{}{
+		"entry_id":  entry.ID,
+		"order_id":  entry.OrderID,
+		"operation": entry.Operation,
+	})
+
+	return nil
+}
+
+// GetByID retrieves an entry by its ID
+func (r *PostgresOutboxRepository) GetByID(ctx context.Context, id string) (*entities.OutboxEntry, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+outboxEntryColumns+` FROM outbox_entries WHERE id = $1`, id)
+
+	entry, err := scanOutboxEntry(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("outbox entry %s %w", id, interfaces.ErrNotFound)
+	}
+	return entry, err
+}
+
+// Update replaces the delivery state of a stored entry
+func (r *PostgresOutboxRepository) Update(ctx context.Context, entry *entities.OutboxEntry) error {
+	if entry == nil {
+		return errors.New("outbox entry cannot be nil")
+	}
+
+	result, err := r.db.ExecContext(ctx, `UPDATE outbox_entries
+		SET status = $2, attempts = $3, last_error = $4, next_attempt_at = $5, delivered_at = $6
+		WHERE id = $1`,
+		entry.ID,
+		string(entry.Status),
+		entry.Attempts,
+		entry.LastError,
+		nullableTime(entry.NextAttemptAt),
+		nullableTime(entry.DeliveredAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox entry: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("outbox entry %s %w", entry.ID, interfaces.ErrNotFound)
+	}
+
+	return nil
+}
+
+// ListByOrderID retrieves the entries of a MagicSpore order in sequence order
+func (r *PostgresOutboxRepository) ListByOrderID(ctx context.Context, orderID string) ([]*entities.OutboxEntry, error) {
+	return r.query(ctx,
+		`SELECT `+outboxEntryColumns+` FROM outbox_entries WHERE order_id = $1 ORDER BY seq`,
+		orderID,
+	)
+}
+
+// ListDue retrieves up to limit pending entries due at or before now that no
+// undelivered entry of the same order precedes, in sequence order
+func (r *PostgresOutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.OutboxEntry, error) {
+	return r.query(ctx,
+		`SELECT `+outboxEntryColumns+` FROM outbox_entries e
+		WHERE e.status = $1 AND e.next_attempt_at <= $2
+		AND NOT EXISTS (
+			SELECT 1 FROM outbox_entries p
+			WHERE p.order_id = e.order_id AND p.seq < e.seq AND p.status IN ($1, $3)
+		)
+		ORDER BY e.seq LIMIT $4`,
+		string(entities.OutboxPending), now.UTC(), string(entities.OutboxFailed), limit,
+	)
+}
+
+// ListUndelivered retrieves up to limit pending or failed entries created
+// before createdBefore, oldest first
+func (r *PostgresOutboxRepository) ListUndelivered(ctx context.Context, createdBefore time.Time, limit int) ([]*entities.OutboxEntry, error) {
+	return r.query(ctx,
+		`SELECT `+outboxEntryColumns+` FROM outbox_entries
+		WHERE status IN ($1, $2) AND created_at < $3 ORDER BY seq LIMIT $4`,
+		string(entities.OutboxPending), string(entities.OutboxFailed), createdBefore.UTC(), limit,
+	)
+}
+
+// Claim moves a pending entry's next attempt to leaseUntil if it is unchanged
+// since it was read. Times are compared at the microsecond precision PostgreSQL keeps.
+func (r *PostgresOutboxRepository) Claim(ctx context.Context, entry *entities.OutboxEntry, leaseUntil time.Time) (bool, error) {
+	if entry == nil || !entry.IsPending() {
+		return false, errors.New("only pending outbox entries can be claimed")
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE outbox_entries SET next_attempt_at = $2 WHERE id = $1 AND status = $4 AND next_attempt_at = $3`,
+		entry.ID, leaseUntil.UTC(), entry.NextAttemptAt.UTC(), string(entities.OutboxPending),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox entry: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	entry.NextAttemptAt = &leaseUntil
+	return true, nil
+}
+
+// query runs a select over outbox_entries rows
+func (r *PostgresOutboxRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entities.OutboxEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*entities.OutboxEntry
+	for rows.Next() {
+		entry, err := scanOutboxEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// scanOutboxEntry maps an outbox_entries row to a domain entity
+func scanOutboxEntry(row rowScanner) (*entities.OutboxEntry, error) {
+	var (
+		entry         entities.OutboxEntry
+		operation     string
+		payload       []byte
+		status        string
+		nextAttemptAt sql.NullTime
+		deliveredAt   sql.NullTime
+	)
+
+	err := row.Scan(
+		&entry.Sequence,
+		&entry.ID,
+		&entry.OrderID,
+		&operation,
+		&payload,
+		&status,
+		&entry.Attempts,
+		&entry.LastError,
+		&entry.CreatedAt,
+		&nextAttemptAt,
+		&deliveredAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+	}
+
+	entry.Operation = entities.OutboxOperation(operation)
+	entry.Status = entities.OutboxEntryStatus(status)
+	if err := json.Unmarshal(payload, &entry.Payload); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal outbox payload: %w", err)
+	}
+
+	if nextAttemptAt.Valid {
+		t := nextAttemptAt.Time
+		entry.NextAttemptAt = &t
+	}
+	if deliveredAt.Valid {
+		t := deliveredAt.Time
+		entry.DeliveredAt = &t
+	}
+
+	return &entry, nil
+}
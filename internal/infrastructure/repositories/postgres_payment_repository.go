@@ -0,0 +1,462 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"time"
+)
+
+// PostgresPaymentRepository implements PaymentRepository on top of PostgreSQL
+type PostgresPaymentRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewPostgresPaymentRepository creates a new PostgreSQL payment repository
+func NewPostgresPaymentRepository(db *sql.DB, logger interfaces.Logger) interfaces.PaymentRepository {
+	return &PostgresPaymentRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const paymentColumns = `id, order_id, payment_id, payer_id, transaction_id, status, method,
+	amount, currency, description, failure_reason, refund_amount, refund_currency,
+	approval_url, return_url, cancel_url, paypal_details, meta_data,
+	created_at, updated_at, completed_at, processed_at, status_history, refunds`
+
+// Create creates a new payment record
+func (r *PostgresPaymentRepository) Create(ctx context.Context, payment *entities.Payment) error {
+	if payment == nil {
+		return errors.New("payment cannot be nil")
+	}
+
+	now := time.Now()
+	if payment.CreatedAt.IsZero() {
+		payment.CreatedAt = now
+	}
+	if payment.UpdatedAt.IsZero() {
+		payment.UpdatedAt = now
+	}
+
+	paypalDetails, err := marshalNullableJSON(payment.PayPalDetails)
+	if err != nil {
+		return fmt.Errorf("failed to marshal paypal details: %w", err)
+	}
+
+	metaData, err := json.Marshal(nonNilMetaData(payment.MetaData))
+	if err != nil {
+		return fmt.Errorf("failed to marshal payment metadata: %w", err)
+	}
+
+	statusHistory, err := json.Marshal(nonNilStatusHistory(payment.StatusHistory))
+	if err != nil {
+		return fmt.Errorf("failed to marshal payment status history: %w", err)
+	}
+
+	refunds, err := json.Marshal(nonNilRefunds(payment.Refunds))
+	if err != nil {
+		return fmt.Errorf("failed to marshal payment refunds: %w", err)
+	}
+
+	var refundAmount sql.NullString
+	var refundCurrency sql.NullString
+	if payment.RefundAmount != nil {
+		refundAmount = sql.NullString{String: payment.RefundAmount.Decimal(), Valid: true}
+		refundCurrency = sql.NullString{String: payment.RefundAmount.Currency, Valid: true}
+	}
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO payments (`+paymentColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)`,
+		payment.ID,
+		payment.OrderID,
+		payment.PaymentID,
+		payment.PayerID,
+		payment.TransactionID,
+		string(payment.Status),
+		string(payment.Method),
+		payment.Amount.Decimal(),
+		paymentCurrency(payment),
+		payment.Description,
+		payment.FailureReason,
+		refundAmount,
+		refundCurrency,
+		payment.ApprovalURL,
+		payment.ReturnURL,
+		payment.CancelURL,
+		paypalDetails,
+		metaData,
+		payment.CreatedAt.UTC(),
+		payment.UpdatedAt.UTC(),
+		nullableTime(payment.CompletedAt),
+		nullableTime(&payment.ProcessedAt),
+		statusHistory,
+		refunds,
+	)
+	if err != nil {
+		r.logger.Error("Failed to insert payment record", err, map[string]interface{}{
+			"payment_id": payment.ID,
+			"order_id":   payment.OrderID,
+		})
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	r.logger.Info("Payment record stored", map[string]interface{}{
+		"payment_id":        payment.ID,
+		"order_id":          payment.OrderID,
+		"paypal_payment_id": payment.PaymentID,
+		"status":            payment.Status,
+	})
+
+	return nil
+}
+
+// GetByOrderID retrieves payments for a specific order, oldest first
+func (r *PostgresPaymentRepository) GetByOrderID(ctx context.Context, orderID string) ([]*entities.Payment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE order_id = $1 ORDER BY created_at, id`,
+		orderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*entities.Payment
+	for rows.Next() {
+		payment, err := scanPayment(rows)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// GetByPaymentID retrieves the most recent payment with the given PayPal payment ID
+func (r *PostgresPaymentRepository) GetByPaymentID(ctx context.Context, paymentID string) (*entities.Payment, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE payment_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1`,
+		paymentID,
+	)
+
+	payment, err := scanPayment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("payment %s %w", paymentID, interfaces.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// UpdateStatus updates the status of every record with the given PayPal payment
+// ID. The records are locked and checked against the payment state machine
+// first, so a rejected transition changes none of them.
+func (r *PostgresPaymentRepository) UpdateStatus(ctx context.Context, paymentID string, status entities.PaymentStatus) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, status FROM payments WHERE payment_id = $1 FOR UPDATE`, paymentID)
+	if err != nil {
+		return fmt.Errorf("failed to query payments: %w", err)
+	}
+
+	current := make(map[string]entities.PaymentStatus)
+	for rows.Next() {
+		var id, from string
+		if err := rows.Scan(&id, &from); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan payment: %w", err)
+		}
+		current[id] = entities.PaymentStatus(from)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read payments: %w", err)
+	}
+
+	if len(current) == 0 {
+		return fmt.Errorf("payment %s %w", paymentID, interfaces.ErrNotFound)
+	}
+
+	for _, from := range current {
+		if !from.CanTransitionTo(status) {
+			return &entities.StatusTransitionError{Entity: "payment", From: string(from), To: string(status)}
+		}
+	}
+
+	now := time.Now().UTC()
+
+	var completedAt sql.NullTime
+	if status == entities.PaymentStatusCompleted {
+		completedAt = sql.NullTime{Time: now, Valid: true}
+	}
+
+	for id, from := range current {
+		if from == status {
+			continue
+		}
+
+		change, err := json.Marshal([]entities.StatusChange{{From: string(from), To: string(status), Reason: "status update", At: now}})
+		if err != nil {
+			return fmt.Errorf("failed to marshal payment status change: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE payments
+			SET status = $2, updated_at = $3, completed_at = COALESCE(completed_at, $4), status_history = status_history || $5::jsonb
+			WHERE id = $1`,
+			id, string(status), now, completedAt, change,
+		); err != nil {
+			return fmt.Errorf("failed to update payment status: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit payment status: %w", err)
+	}
+
+	r.logger.Info("Payment status updated", map[string]interface{}{
+		"paypal_payment_id": paymentID,
+		"status":            status,
+	})
+
+	return nil
+}
+
+// RecordRefund adds a refund to the most recent payment with the given PayPal
+// payment ID. The record is locked while the refund is checked against the
+// amount still refundable, so concurrent refunds cannot exceed the capture.
+func (r *PostgresPaymentRepository) RecordRefund(ctx context.Context, paymentID string, refund entities.PaymentRefund) (*entities.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx,
+		`SELECT `+paymentColumns+` FROM payments WHERE payment_id = $1 ORDER BY created_at DESC, id DESC LIMIT 1 FOR UPDATE`,
+		paymentID,
+	)
+
+	payment, err := scanPayment(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("payment %s %w", paymentID, interfaces.ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := payment.ApplyRefund(refund, "refunded"); err != nil {
+		return nil, err
+	}
+
+	statusHistory, err := json.Marshal(nonNilStatusHistory(payment.StatusHistory))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payment status history: %w", err)
+	}
+
+	refunds, err := json.Marshal(nonNilRefunds(payment.Refunds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payment refunds: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE payments
+		SET status = $2, refund_amount = $3, refund_currency = $4, updated_at = $5, status_history = $6, refunds = $7
+		WHERE id = $1`,
+		payment.ID,
+		string(payment.Status),
+		payment.RefundAmount.Decimal(),
+		payment.RefundAmount.Currency,
+		payment.UpdatedAt.UTC(),
+		statusHistory,
+		refunds,
+	); err != nil {
+		return nil, fmt.Errorf("failed to record payment refund: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit payment refund: %w", err)
+	}
+
+	r.logger.Info("Payment refund recorded", map[string]interface{}{
+		"paypal_payment_id": paymentID,
+		"refund_id":         refund.ID,
+		"amount":            refund.Amount.Decimal(),
+		"refunded":          payment.RefundAmount.Decimal(),
+		"status":            payment.Status,
+	})
+
+	return payment, nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPayment maps a payments row to a domain entity
+func scanPayment(row rowScanner) (*entities.Payment, error) {
+	var (
+		payment        entities.Payment
+		status         string
+		method         string
+		amount         string
+		refundAmount   sql.NullString
+		refundCurrency sql.NullString
+		paypalDetails  []byte
+		metaData       []byte
+		completedAt    sql.NullTime
+		processedAt    sql.NullTime
+		statusHistory  []byte
+		refunds        []byte
+	)
+
+	err := row.Scan(
+		&payment.ID,
+		&payment.OrderID,
+		&payment.PaymentID,
+		&payment.PayerID,
+		&payment.TransactionID,
+		&status,
+		&method,
+		&amount,
+		&payment.Currency,
+		&payment.Description,
+		&payment.FailureReason,
+		&refundAmount,
+		&refundCurrency,
+		&payment.ApprovalURL,
+		&payment.ReturnURL,
+		&payment.CancelURL,
+		&paypalDetails,
+		&metaData,
+		&payment.CreatedAt,
+		&payment.UpdatedAt,
+		&completedAt,
+		&processedAt,
+		&statusHistory,
+		&refunds,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan payment: %w", err)
+	}
+
+	payment.Status = entities.PaymentStatus(status)
+	payment.Method = entities.PaymentMethod(method)
+	// NUMERIC columns come back as exact decimal text
+	payment.Amount, err = entities.ParseMoney(amount, payment.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse payment amount: %w", err)
+	}
+
+	if refundAmount.Valid {
+		refund, err := entities.ParseMoney(refundAmount.String, refundCurrency.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse refund amount: %w", err)
+		}
+		payment.RefundAmount = &refund
+	}
+
+	if len(paypalDetails) > 0 {
+		payment.PayPalDetails = &entities.PayPalDetails{}
+		if err := json.Unmarshal(paypalDetails, payment.PayPalDetails); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal paypal details: %w", err)
+		}
+	}
+
+	if len(metaData) > 0 {
+		if err := json.Unmarshal(metaData, &payment.MetaData); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal payment metadata: %w", err)
+		}
+	}
+
+	if len(statusHistory) > 0 {
+		if err := json.Unmarshal(statusHistory, &payment.StatusHistory); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal payment status history: %w", err)
+		}
+	}
+
+	if len(refunds) > 0 {
+		if err := json.Unmarshal(refunds, &payment.Refunds); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal payment refunds: %w", err)
+		}
+	}
+
+	if completedAt.Valid {
+		t := completedAt.Time
+		payment.CompletedAt = &t
+	}
+	if processedAt.Valid {
+		payment.ProcessedAt = processedAt.Time
+	}
+
+	return &payment, nil
+}
+
+// paymentCurrency returns the currency to persist for a payment
+func paymentCurrency(payment *entities.Payment) string {
+	if payment.Amount.Currency != "" {
+		return payment.Amount.Currency
+	}
+	return payment.Currency
+}
+
+// marshalNullableJSON marshals v, returning nil for nil pointers
+func marshalNullableJSON(v *entities.PayPalDetails) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+// nonNilMetaData ensures metadata is stored as an empty array rather than null
+func nonNilMetaData(metaData []entities.MetaData) []entities.MetaData {
+	if metaData == nil {
+		return []entities.MetaData{}
+	}
+	return metaData
+}
+
+// nonNilStatusHistory ensures status history is stored as an empty array rather than null
+func nonNilStatusHistory(history []entities.StatusChange) []entities.StatusChange {
+	if history == nil {
+		return []entities.StatusChange{}
+	}
+	return history
+}
+
+// nonNilRefunds ensures the refund ledger is stored as an empty array rather than null
+func nonNilRefunds(refunds []entities.PaymentRefund) []entities.PaymentRefund {
+	if refunds == nil {
+		return []entities.PaymentRefund{}
+	}
+	return refunds
+}
+
+// nullableTime converts an optional time into a SQL nullable value
+func nullableTime(t *time.Time) sql.NullTime {
+	if t == nil || t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
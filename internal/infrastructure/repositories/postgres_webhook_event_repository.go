@@ -0,0 +1,235 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"time"
+)
+
+// PostgresWebhookEventRepository implements WebhookEventRepository on top of PostgreSQL
+type PostgresWebhookEventRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewPostgresWebhookEventRepository creates a new PostgreSQL webhook event repository
+func NewPostgresWebhookEventRepository(db *sql.DB, logger interfaces.Logger) interfaces.WebhookEventRepository {
+	return &PostgresWebhookEventRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+const webhookEventColumns = `id, event_type, body, signature, status, result, attempts, received_at, processed_at, next_attempt_at`
+
+// Create stores a new event, rejecting IDs that were already recorded
+func (r *PostgresWebhookEventRepository) Create(ctx context.Context, event *entities.WebhookEvent) error {
+	if event == nil {
+		return errors.New("webhook event cannot be nil")
+	}
+
+	result, err := r.db.ExecContext(ctx, `INSERT INTO webhook_events (`+webhookEventColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (id) DO NOTHING`,
+		event.ID,
+		event.EventType,
+		event.Body,
+		string(event.Signature),
+		string(event.Status),
+		event.Result,
+		event.Attempts,
+		event.ReceivedAt.UTC(),
+		nullableTime(event.ProcessedAt),
+		nullableTime(event.NextAttemptAt),
+	)
+	if err != nil {
+		r.logger.Error("Failed to insert webhook event", err, map[string]interface{}{
+			"event_id":   event.ID,
+			"event_type": event.EventType,
+		})
+		return fmt.Errorf("failed to create webhook event: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return entities.ErrDuplicateWebhookEvent
+	}
+
+	r.logger.Info("Webhook event stored", map[string]interface{}{
+		"event_id":   event.ID,
+		"event_type": event.EventType,
+		"signature":  event.Signature,
+	})
+
+	return nil
+}
+
+// GetByID retrieves an event by its PayPal event ID
+func (r *PostgresWebhookEventRepository) GetByID(ctx context.Context, id string) (*entities.WebhookEvent, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT `+webhookEventColumns+` FROM webhook_events WHERE id = $1`,
+		id,
+	)
+
+	event, err := scanWebhookEvent(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("webhook event %s %w", id, interfaces.ErrNotFound)
+	}
+	return event, err
+}
+
+// Update replaces a stored event
+func (r *PostgresWebhookEventRepository) Update(ctx context.Context, event *entities.WebhookEvent) error {
+	if event == nil {
+		return errors.New("webhook event cannot be nil")
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE webhook_events SET event_type = $2, body = $3, signature = $4, status = $5, result = $6, attempts = $7,
+		processed_at = $8, next_attempt_at = $9
+		WHERE id = $1`,
+		event.ID,
+		event.EventType,
+		event.Body,
+		string(event.Signature),
+		string(event.Status),
+		event.Result,
+		event.Attempts,
+		nullableTime(event.ProcessedAt),
+		nullableTime(event.NextAttemptAt),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook event: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("webhook event %s %w", event.ID, interfaces.ErrNotFound)
+	}
+
+	return nil
+}
+
+// ListByReceivedAt retrieves events received in [from, to), oldest first
+func (r *PostgresWebhookEventRepository) ListByReceivedAt(ctx context.Context, from, to time.Time) ([]*entities.WebhookEvent, error) {
+	return r.query(ctx,
+		`SELECT `+webhookEventColumns+` FROM webhook_events WHERE received_at >= $1 AND received_at < $2 ORDER BY received_at, id`,
+		from.UTC(), to.UTC(),
+	)
+}
+
+// ListByStatus retrieves up to limit events in a status, oldest first
+func (r *PostgresWebhookEventRepository) ListByStatus(ctx context.Context, status entities.WebhookEventStatus, limit int) ([]*entities.WebhookEvent, error) {
+	return r.query(ctx,
+		`SELECT `+webhookEventColumns+` FROM webhook_events WHERE status = $1 ORDER BY received_at, id LIMIT $2`,
+		string(status), limit,
+	)
+}
+
+// ListDue retrieves up to limit queued events whose next attempt is at or before now
+func (r *PostgresWebhookEventRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.WebhookEvent, error) {
+	return r.query(ctx,
+		`SELECT `+webhookEventColumns+` FROM webhook_events WHERE next_attempt_at <= $1 ORDER BY next_attempt_at, id LIMIT $2`,
+		now.UTC(), limit,
+	)
+}
+
+// Claim moves a due event's next attempt to leaseUntil if it is unchanged since
+// it was read. Times are compared at the microsecond precision PostgreSQL keeps.
+func (r *PostgresWebhookEventRepository) Claim(ctx context.Context, event *entities.WebhookEvent, leaseUntil time.Time) (bool, error) {
+	if event == nil || !event.IsQueued() {
+		return false, errors.New("only queued webhook events can be claimed")
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE webhook_events SET next_attempt_at = $2 WHERE id = $1 AND next_attempt_at = $3`,
+		event.ID, leaseUntil.UTC(), event.NextAttemptAt.UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook event: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	event.NextAttemptAt = &leaseUntil
+	return true, nil
+}
+
+// query runs a select over webhook_events rows
+func (r *PostgresWebhookEventRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entities.WebhookEvent, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*entities.WebhookEvent
+	for rows.Next() {
+		event, err := scanWebhookEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// scanWebhookEvent maps a webhook_events row to a domain entity
+func scanWebhookEvent(row rowScanner) (*entities.WebhookEvent, error) {
+	var (
+		event         entities.WebhookEvent
+		signature     string
+		status        string
+		processedAt   sql.NullTime
+		nextAttemptAt sql.NullTime
+	)
+
+	err := row.Scan(
+		&event.ID,
+		&event.EventType,
+		&event.Body,
+		&signature,
+		&status,
+		&event.Result,
+		&event.Attempts,
+		&event.ReceivedAt,
+		&processedAt,
+		&nextAttemptAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan webhook event: %w", err)
+	}
+
+	event.Signature = entities.WebhookSignatureVerdict(signature)
+	event.Status = entities.WebhookEventStatus(status)
+	if processedAt.Valid {
+		t := processedAt.Time
+		event.ProcessedAt = &t
+	}
+	if nextAttemptAt.Valid {
+		t := nextAttemptAt.Time
+		event.NextAttemptAt = &t
+	}
+
+	return &event, nil
+}
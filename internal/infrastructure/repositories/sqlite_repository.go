@@ -0,0 +1,1109 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"time"
+)
+
+// SQLitePaymentRepository implements PaymentRepository on an embedded SQLite database
+type SQLitePaymentRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewSQLitePaymentRepository creates a new SQLite payment repository
+func NewSQLitePaymentRepository(db *sql.DB, logger interfaces.Logger) interfaces.PaymentRepository {
+	return &SQLitePaymentRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create creates a new payment record
+func (r *SQLitePaymentRepository) Create(ctx context.Context, payment *entities.Payment) error {
+	if payment == nil {
+		return errors.New("payment cannot be nil")
+	}
+
+	now := time.Now()
+	if payment.CreatedAt.IsZero() {
+		payment.CreatedAt = now
+	}
+	if payment.UpdatedAt.IsZero() {
+		payment.UpdatedAt = now
+	}
+
+	data, err := json.Marshal(payment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payment: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		"INSERT INTO payments (id, order_id, payment_id, created_at, data) VALUES (?, ?, ?, ?, ?)",
+		payment.ID, payment.OrderID, payment.PaymentID, payment.CreatedAt.UnixNano(), string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create payment: %w", err)
+	}
+
+	r.logger.Debug("Payment record stored in SQLite", map[string]interface{}{
+		"payment_id": payment.ID,
+		"order_id":   payment.OrderID,
+		"status":     payment.Status,
+	})
+
+	return nil
+}
+
+// GetByOrderID retrieves payments for a specific order, oldest first
+func (r *SQLitePaymentRepository) GetByOrderID(ctx context.Context, orderID string) ([]*entities.Payment, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT data FROM payments WHERE order_id = ? ORDER BY created_at, rowid",
+		orderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payments: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []*entities.Payment
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan payment: %w", err)
+		}
+
+		var payment entities.Payment
+		if err := json.Unmarshal([]byte(data), &payment); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal payment: %w", err)
+		}
+		payments = append(payments, &payment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+// GetByPaymentID retrieves the most recent payment with the given PayPal payment ID
+func (r *SQLitePaymentRepository) GetByPaymentID(ctx context.Context, paymentID string) (*entities.Payment, error) {
+	var data string
+	err := r.db.QueryRowContext(ctx,
+		"SELECT data FROM payments WHERE payment_id = ? ORDER BY created_at DESC, rowid DESC LIMIT 1",
+		paymentID,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("payment %s %w", paymentID, interfaces.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment: %w", err)
+	}
+
+	var payment entities.Payment
+	if err := json.Unmarshal([]byte(data), &payment); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payment: %w", err)
+	}
+
+	return &payment, nil
+}
+
+// UpdateStatus updates the status of every record with the given PayPal payment ID
+func (r *SQLitePaymentRepository) UpdateStatus(ctx context.Context, paymentID string, status entities.PaymentStatus) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, data FROM payments WHERE payment_id = ?", paymentID)
+	if err != nil {
+		return fmt.Errorf("failed to query payments: %w", err)
+	}
+
+	updates := make(map[string]*entities.Payment)
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan payment: %w", err)
+		}
+
+		var payment entities.Payment
+		if err := json.Unmarshal([]byte(data), &payment); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to unmarshal payment: %w", err)
+		}
+		updates[id] = &payment
+	}
+	rows.Close()
+
+	if len(updates) == 0 {
+		return fmt.Errorf("payment %s %w", paymentID, interfaces.ErrNotFound)
+	}
+
+	now := time.Now()
+	for id, payment := range updates {
+		if err := applyPaymentStatus(payment, status, now); err != nil {
+			return err
+		}
+
+		data, err := json.Marshal(payment)
+		if err != nil {
+			return fmt.Errorf("failed to marshal payment: %w", err)
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE payments SET data = ? WHERE id = ?", string(data), id); err != nil {
+			return fmt.Errorf("failed to update payment status: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RecordRefund adds a refund to the most recent payment with the given PayPal payment ID
+func (r *SQLitePaymentRepository) RecordRefund(ctx context.Context, paymentID string, refund entities.PaymentRefund) (*entities.Payment, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id, data string
+	err = tx.QueryRowContext(ctx,
+		"SELECT id, data FROM payments WHERE payment_id = ? ORDER BY created_at DESC, rowid DESC LIMIT 1",
+		paymentID,
+	).Scan(&id, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("payment %s %w", paymentID, interfaces.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query payment: %w", err)
+	}
+
+	var payment entities.Payment
+	if err := json.Unmarshal([]byte(data), &payment); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal payment: %w", err)
+	}
+
+	if err := payment.ApplyRefund(refund, "refunded"); err != nil {
+		return nil, err
+	}
+
+	updated, err := json.Marshal(&payment)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payment: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE payments SET data = ? WHERE id = ?", string(updated), id); err != nil {
+		return nil, fmt.Errorf("failed to record payment refund: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit payment refund: %w", err)
+	}
+
+	return &payment, nil
+}
+
+// SQLiteOrderRepository implements OrderRepository on an embedded SQLite database
+type SQLiteOrderRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewSQLiteOrderRepository creates a new SQLite order repository
+func NewSQLiteOrderRepository(db *sql.DB, logger interfaces.Logger) interfaces.OrderRepository {
+	return &SQLiteOrderRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// GetByID retrieves an order by its ID
+func (r *SQLiteOrderRepository) GetByID(ctx context.Context, id string) (*entities.Order, error) {
+	orderID, err := parseOrderID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanOrder(r.db.QueryRowContext(ctx, "SELECT data, status_history FROM orders WHERE id = ?", orderID), id)
+}
+
+// Create creates a new order, assigning an ID when none is set
+func (r *SQLiteOrderRepository) Create(ctx context.Context, order *entities.Order) (*entities.Order, error) {
+	if order == nil {
+		return nil, errors.New("order cannot be nil")
+	}
+
+	stored, err := cloneOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	if stored.DateCreated.IsZero() {
+		stored.DateCreated = time.Now()
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Insert first so SQLite can assign the ID, then store the document with it
+	var result sql.Result
+	if stored.ID == 0 {
+		result, err = tx.ExecContext(ctx,
+			"INSERT INTO orders (status, updated_at, data) VALUES (?, ?, '{}')",
+			string(stored.Status), time.Now().UnixNano(),
+		)
+	} else {
+		result, err = tx.ExecContext(ctx,
+			"INSERT INTO orders (id, status, updated_at, data) VALUES (?, ?, ?, '{}')",
+			stored.ID, string(stored.Status), time.Now().UnixNano(),
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create order: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read order ID: %w", err)
+	}
+	stored.ID = int(id)
+
+	if err := r.writeOrder(ctx, tx, stored); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit order: %w", err)
+	}
+
+	return stored, nil
+}
+
+// Update updates an existing order
+func (r *SQLiteOrderRepository) Update(ctx context.Context, id string, order *entities.Order) error {
+	return r.modify(ctx, id, func(existing *entities.Order) error {
+		updated, err := cloneOrder(order)
+		if err != nil {
+			return err
+		}
+		updated.ID = existing.ID
+		*existing = *updated
+		return nil
+	})
+}
+
+// UpdateStatus updates only the order status
+func (r *SQLiteOrderRepository) UpdateStatus(ctx context.Context, id string, status entities.OrderStatus) error {
+	return r.modify(ctx, id, func(existing *entities.Order) error {
+		return existing.TransitionTo(status, "status update")
+	})
+}
+
+// UpdatePaymentInfo updates payment-related information
+func (r *SQLiteOrderRepository) UpdatePaymentInfo(ctx context.Context, id string, payment *entities.Payment) error {
+	if payment == nil {
+		return errors.New("payment cannot be nil")
+	}
+
+	return r.modify(ctx, id, func(existing *entities.Order) error {
+		return applyPaymentInfo(existing, payment, time.Now())
+	})
+}
+
+// modify loads, mutates and stores an order in a single transaction
+func (r *SQLiteOrderRepository) modify(ctx context.Context, id string, fn func(*entities.Order) error) error {
+	orderID, err := parseOrderID(id)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	order, err := scanOrder(tx.QueryRowContext(ctx, "SELECT data, status_history FROM orders WHERE id = ?", orderID), id)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(order); err != nil {
+		return err
+	}
+
+	if err := r.writeOrder(ctx, tx, order); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// writeOrder stores the order document and its indexed columns. The status
+// history is kept in its own column rather than in the document.
+func (r *SQLiteOrderRepository) writeOrder(ctx context.Context, tx *sql.Tx, order *entities.Order) error {
+	document := *order
+	document.StatusHistory = nil
+	data, err := json.Marshal(&document)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order: %w", err)
+	}
+
+	statusHistory, err := json.Marshal(nonNilStatusHistory(order.StatusHistory))
+	if err != nil {
+		return fmt.Errorf("failed to marshal order status history: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE orders SET status = ?, updated_at = ?, status_history = ?, data = ? WHERE id = ?",
+		string(order.Status), time.Now().UnixNano(), string(statusHistory), string(data), order.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+
+	return nil
+}
+
+// scanOrder reads an order document and its status history column
+func scanOrder(row *sql.Row, id string) (*entities.Order, error) {
+	var data, statusHistory string
+	err := row.Scan(&data, &statusHistory)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("order %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order: %w", err)
+	}
+
+	var order entities.Order
+	if err := json.Unmarshal([]byte(data), &order); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal order: %w", err)
+	}
+	if err := json.Unmarshal([]byte(statusHistory), &order.StatusHistory); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal order status history: %w", err)
+	}
+	if len(order.StatusHistory) == 0 {
+		order.StatusHistory = nil
+	}
+
+	return &order, nil
+}
+
+// SQLiteOrderMappingRepository implements OrderMappingRepository on an embedded SQLite database
+type SQLiteOrderMappingRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewSQLiteOrderMappingRepository creates a new SQLite order mapping repository
+func NewSQLiteOrderMappingRepository(db *sql.DB, logger interfaces.Logger) interfaces.OrderMappingRepository {
+	return &SQLiteOrderMappingRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create stores a new mapping
+func (r *SQLiteOrderMappingRepository) Create(ctx context.Context, mapping *entities.OrderMapping) error {
+	if mapping == nil {
+		return errors.New("order mapping cannot be nil")
+	}
+
+	data, err := json.Marshal(mapping)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order mapping: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO order_mappings (oitam_order_id, magic_order_id, state, created_at, expires_at, data)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		mapping.OITAMOrderID, mapping.MagicOrderID, string(mapping.State),
+		mapping.CreatedAt.UnixNano(), mapping.ExpiresAt.UnixNano(), string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create order mapping: %w", err)
+	}
+
+	r.logger.Debug("Order mapping stored in SQLite", map[string]interface{}{
+		"order_id":       mapping.MagicOrderID,
+		"oitam_order_id": mapping.OITAMOrderID,
+	})
+
+	return nil
+}
+
+// GetByMagicOrderID retrieves the most recent mapping for a MagicSpore order
+func (r *SQLiteOrderMappingRepository) GetByMagicOrderID(ctx context.Context, magicOrderID string) (*entities.OrderMapping, error) {
+	var data string
+	err := r.db.QueryRowContext(ctx,
+		"SELECT data FROM order_mappings WHERE magic_order_id = ? ORDER BY created_at DESC, rowid DESC LIMIT 1",
+		magicOrderID,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("order mapping for order %s %w", magicOrderID, interfaces.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order mapping: %w", err)
+	}
+
+	return unmarshalOrderMapping(data)
+}
+
+// GetByOITAMOrderID retrieves the mapping for an OITAM proxy order
+func (r *SQLiteOrderMappingRepository) GetByOITAMOrderID(ctx context.Context, oitamOrderID string) (*entities.OrderMapping, error) {
+	var data string
+	err := r.db.QueryRowContext(ctx,
+		"SELECT data FROM order_mappings WHERE oitam_order_id = ?",
+		oitamOrderID,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("order mapping for OITAM order %s %w", oitamOrderID, interfaces.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order mapping: %w", err)
+	}
+
+	return unmarshalOrderMapping(data)
+}
+
+// UpdateState updates the state of the mapping for an OITAM proxy order
+func (r *SQLiteOrderMappingRepository) UpdateState(ctx context.Context, oitamOrderID string, state entities.OrderMappingState) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var data string
+	err = tx.QueryRowContext(ctx, "SELECT data FROM order_mappings WHERE oitam_order_id = ?", oitamOrderID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("order mapping for OITAM order %s %w", oitamOrderID, interfaces.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query order mapping: %w", err)
+	}
+
+	mapping, err := unmarshalOrderMapping(data)
+	if err != nil {
+		return err
+	}
+	mapping.State = state
+	mapping.UpdatedAt = time.Now()
+
+	updated, err := json.Marshal(mapping)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order mapping: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE order_mappings SET state = ?, data = ? WHERE oitam_order_id = ?",
+		string(state), string(updated), oitamOrderID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order mapping state: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ListByState retrieves up to limit mappings in a state created before
+// createdBefore and after the after mapping, oldest first
+func (r *SQLiteOrderMappingRepository) ListByState(ctx context.Context, state entities.OrderMappingState, createdBefore time.Time, after *entities.OrderMapping, limit int) ([]*entities.OrderMapping, error) {
+	query := "SELECT data FROM order_mappings WHERE state = ? AND created_at < ?"
+	args := []interface{}{string(state), createdBefore.UnixNano()}
+	if after != nil {
+		query += " AND (created_at > ? OR (created_at = ? AND oitam_order_id > ?))"
+		args = append(args, after.CreatedAt.UnixNano(), after.CreatedAt.UnixNano(), after.OITAMOrderID)
+	}
+	query += " ORDER BY created_at, oitam_order_id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order mappings: %w", err)
+	}
+	defer rows.Close()
+
+	var mappings []*entities.OrderMapping
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan order mapping: %w", err)
+		}
+		mapping, err := unmarshalOrderMapping(data)
+		if err != nil {
+			return nil, err
+		}
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, rows.Err()
+}
+
+// unmarshalOrderMapping decodes a stored mapping document
+func unmarshalOrderMapping(data string) (*entities.OrderMapping, error) {
+	var mapping entities.OrderMapping
+	if err := json.Unmarshal([]byte(data), &mapping); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal order mapping: %w", err)
+	}
+	return &mapping, nil
+}
+
+// SQLiteWebhookEventRepository implements WebhookEventRepository on an embedded SQLite database
+type SQLiteWebhookEventRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewSQLiteWebhookEventRepository creates a new SQLite webhook event repository
+func NewSQLiteWebhookEventRepository(db *sql.DB, logger interfaces.Logger) interfaces.WebhookEventRepository {
+	return &SQLiteWebhookEventRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create stores a new event, rejecting IDs that were already recorded
+func (r *SQLiteWebhookEventRepository) Create(ctx context.Context, event *entities.WebhookEvent) error {
+	if event == nil {
+		return errors.New("webhook event cannot be nil")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO webhook_events (id, event_type, status, received_at, next_attempt_at, data)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		event.ID, event.EventType, string(event.Status), event.ReceivedAt.UnixNano(),
+		nullableUnixNano(event.NextAttemptAt), string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook event: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return entities.ErrDuplicateWebhookEvent
+	}
+
+	r.logger.Debug("Webhook event stored in SQLite", map[string]interface{}{
+		"event_id":   event.ID,
+		"event_type": event.EventType,
+		"signature":  event.Signature,
+	})
+
+	return nil
+}
+
+// GetByID retrieves an event by its PayPal event ID
+func (r *SQLiteWebhookEventRepository) GetByID(ctx context.Context, id string) (*entities.WebhookEvent, error) {
+	var data string
+	err := r.db.QueryRowContext(ctx, "SELECT data FROM webhook_events WHERE id = ?", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("webhook event %s %w", id, interfaces.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook event: %w", err)
+	}
+
+	return unmarshalWebhookEvent(data)
+}
+
+// Update replaces a stored event
+func (r *SQLiteWebhookEventRepository) Update(ctx context.Context, event *entities.WebhookEvent) error {
+	if event == nil {
+		return errors.New("webhook event cannot be nil")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE webhook_events SET status = ?, next_attempt_at = ?, data = ? WHERE id = ?",
+		string(event.Status), nullableUnixNano(event.NextAttemptAt), string(data), event.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook event: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("webhook event %s %w", event.ID, interfaces.ErrNotFound)
+	}
+
+	return nil
+}
+
+// ListByReceivedAt retrieves events received in [from, to), oldest first
+func (r *SQLiteWebhookEventRepository) ListByReceivedAt(ctx context.Context, from, to time.Time) ([]*entities.WebhookEvent, error) {
+	return r.query(ctx,
+		"SELECT data FROM webhook_events WHERE received_at >= ? AND received_at < ? ORDER BY received_at, rowid",
+		from.UnixNano(), to.UnixNano(),
+	)
+}
+
+// ListByStatus retrieves up to limit events in a status, oldest first
+func (r *SQLiteWebhookEventRepository) ListByStatus(ctx context.Context, status entities.WebhookEventStatus, limit int) ([]*entities.WebhookEvent, error) {
+	return r.query(ctx,
+		"SELECT data FROM webhook_events WHERE status = ? ORDER BY received_at, rowid LIMIT ?",
+		string(status), limit,
+	)
+}
+
+// ListDue retrieves up to limit queued events whose next attempt is at or before now
+func (r *SQLiteWebhookEventRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.WebhookEvent, error) {
+	return r.query(ctx,
+		"SELECT data FROM webhook_events WHERE next_attempt_at <= ? ORDER BY next_attempt_at, rowid LIMIT ?",
+		now.UnixNano(), limit,
+	)
+}
+
+// Claim moves a due event's next attempt to leaseUntil if it is unchanged since it was read
+func (r *SQLiteWebhookEventRepository) Claim(ctx context.Context, event *entities.WebhookEvent, leaseUntil time.Time) (bool, error) {
+	if event == nil || !event.IsQueued() {
+		return false, errors.New("only queued webhook events can be claimed")
+	}
+
+	claimed := *event
+	claimed.NextAttemptAt = &leaseUntil
+	data, err := json.Marshal(&claimed)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE webhook_events SET next_attempt_at = ?, data = ? WHERE id = ? AND next_attempt_at = ?",
+		leaseUntil.UnixNano(), string(data), event.ID, event.NextAttemptAt.UnixNano(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim webhook event: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	event.NextAttemptAt = &leaseUntil
+	return true, nil
+}
+
+// query runs a select over stored event documents
+func (r *SQLiteWebhookEventRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entities.WebhookEvent, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*entities.WebhookEvent
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook event: %w", err)
+		}
+		event, err := unmarshalWebhookEvent(data)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// unmarshalWebhookEvent decodes a stored event document
+func unmarshalWebhookEvent(data string) (*entities.WebhookEvent, error) {
+	var event entities.WebhookEvent
+	if err := json.Unmarshal([]byte(data), &event); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook event: %w", err)
+	}
+	return &event, nil
+}
+
+// nullableUnixNano converts an optional time into a nullable UnixNano column value
+func nullableUnixNano(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.UnixNano(), Valid: true}
+}
+
+// SQLiteDisputeRepository implements DisputeRepository on an embedded SQLite database
+type SQLiteDisputeRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewSQLiteDisputeRepository creates a new SQLite dispute repository
+func NewSQLiteDisputeRepository(db *sql.DB, logger interfaces.Logger) interfaces.DisputeRepository {
+	return &SQLiteDisputeRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Save stores a dispute, replacing any earlier record with its ID
+func (r *SQLiteDisputeRepository) Save(ctx context.Context, dispute *entities.Dispute) error {
+	if dispute == nil {
+		return errors.New("dispute cannot be nil")
+	}
+
+	data, err := json.Marshal(dispute)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dispute: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO disputes (id, order_id, status, created_at, data)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET order_id = excluded.order_id, status = excluded.status, data = excluded.data`,
+		dispute.ID, dispute.OrderID, string(dispute.Status), dispute.CreatedAt.UnixNano(), string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save dispute: %w", err)
+	}
+
+	r.logger.Debug("Dispute stored in SQLite", map[string]interface{}{
+		"dispute_id": dispute.ID,
+		"order_id":   dispute.OrderID,
+		"status":     dispute.Status,
+	})
+
+	return nil
+}
+
+// GetByID retrieves a dispute by its PayPal dispute ID
+func (r *SQLiteDisputeRepository) GetByID(ctx context.Context, id string) (*entities.Dispute, error) {
+	var data string
+	err := r.db.QueryRowContext(ctx, "SELECT data FROM disputes WHERE id = ?", id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("dispute %s %w", id, interfaces.ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dispute: %w", err)
+	}
+
+	return unmarshalDispute(data)
+}
+
+// ListByOrderID retrieves the disputes of a MagicSpore order, oldest first
+func (r *SQLiteDisputeRepository) ListByOrderID(ctx context.Context, orderID string) ([]*entities.Dispute, error) {
+	return r.query(ctx,
+		"SELECT data FROM disputes WHERE order_id = ? ORDER BY created_at, rowid",
+		orderID,
+	)
+}
+
+// ListOpen retrieves every dispute that is not resolved yet, oldest first
+func (r *SQLiteDisputeRepository) ListOpen(ctx context.Context) ([]*entities.Dispute, error) {
+	return r.query(ctx,
+		"SELECT data FROM disputes WHERE status <> ? ORDER BY created_at, rowid",
+		string(entities.DisputeStatusResolved),
+	)
+}
+
+// query runs a select over stored dispute documents
+func (r *SQLiteDisputeRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entities.Dispute, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query disputes: %w", err)
+	}
+	defer rows.Close()
+
+	var disputes []*entities.Dispute
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan dispute: %w", err)
+		}
+		dispute, err := unmarshalDispute(data)
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, dispute)
+	}
+
+	return disputes, rows.Err()
+}
+
+// unmarshalDispute decodes a stored dispute document
+func unmarshalDispute(data string) (*entities.Dispute, error) {
+	var dispute entities.Dispute
+	if err := json.Unmarshal([]byte(data), &dispute); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dispute: %w", err)
+	}
+	return &dispute, nil
+}
+
+// SQLiteOutboxRepository implements OutboxRepository on an embedded SQLite database
+type SQLiteOutboxRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewSQLiteOutboxRepository creates a new SQLite outbox repository
+func NewSQLiteOutboxRepository(db *sql.DB, logger interfaces.Logger) interfaces.OutboxRepository {
+	return &SQLiteOutboxRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Create stores a new entry at the end of its order's queue, assigning its sequence
+func (r *SQLiteOutboxRepository) Create(ctx context.Context, entry *entities.OutboxEntry) error {
+	if entry == nil {
+		return errors.New("outbox entry cannot be nil")
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO outbox_entries (id, order_id, status, created_at, next_attempt_at, data)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.ID, entry.OrderID, string(entry.Status), entry.CreatedAt.UnixNano(),
+		nullableUnixNano(entry.NextAttemptAt), string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox entry: %w", err)
+	}
+
+	sequence, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to read outbox entry sequence: %w", err)
+	}
+	entry.Sequence = sequence
+
+	r.logger.Debug("Outbox entry stored in SQLite", map[string]interface{}{
+		"entry_id":  entry.ID,
+		"order_id":  entry.OrderID,
+		"operation": entry.Operation,
+	})
+
+	return nil
+}
+
+// GetByID retrieves an entry by its ID
+func (r *SQLiteOutboxRepository) GetByID(ctx context.Context, id string) (*entities.OutboxEntry, error) {
+	entries, err := r.query(ctx, "SELECT seq, data FROM outbox_entries WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("outbox entry %s %w", id, interfaces.ErrNotFound)
+	}
+	return entries[0], nil
+}
+
+// Update replaces a stored entry
+func (r *SQLiteOutboxRepository) Update(ctx context.Context, entry *entities.OutboxEntry) error {
+	if entry == nil {
+		return errors.New("outbox entry cannot be nil")
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE outbox_entries SET status = ?, next_attempt_at = ?, data = ? WHERE id = ?",
+		string(entry.Status), nullableUnixNano(entry.NextAttemptAt), string(data), entry.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update outbox entry: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("outbox entry %s %w", entry.ID, interfaces.ErrNotFound)
+	}
+
+	return nil
+}
+
+// ListByOrderID retrieves the entries of a MagicSpore order in sequence order
+func (r *SQLiteOutboxRepository) ListByOrderID(ctx context.Context, orderID string) ([]*entities.OutboxEntry, error) {
+	return r.query(ctx, "SELECT seq, data FROM outbox_entries WHERE order_id = ? ORDER BY seq", orderID)
+}
+
+// ListDue retrieves up to limit pending entries due at or before now that no
+// undelivered entry of the same order precedes, in sequence order
+func (r *SQLiteOutboxRepository) ListDue(ctx context.Context, now time.Time, limit int) ([]*entities.OutboxEntry, error) {
+	return r.query(ctx,
+		`SELECT e.seq, e.data FROM outbox_entries e
+		WHERE e.status = ? AND e.next_attempt_at <= ?
+		AND NOT EXISTS (
+			SELECT 1 FROM outbox_entries p
+			WHERE p.order_id = e.order_id AND p.seq < e.seq AND p.status IN (?, ?)
+		)
+		ORDER BY e.seq LIMIT ?`,
+		string(entities.OutboxPending), now.UnixNano(),
+		string(entities.OutboxPending), string(entities.OutboxFailed), limit,
+	)
+}
+
+// ListUndelivered retrieves up to limit pending or failed entries created
+// before createdBefore, oldest first
+func (r *SQLiteOutboxRepository) ListUndelivered(ctx context.Context, createdBefore time.Time, limit int) ([]*entities.OutboxEntry, error) {
+	return r.query(ctx,
+		"SELECT seq, data FROM outbox_entries WHERE status IN (?, ?) AND created_at < ? ORDER BY seq LIMIT ?",
+		string(entities.OutboxPending), string(entities.OutboxFailed), createdBefore.UnixNano(), limit,
+	)
+}
+
+// Claim moves a pending entry's next attempt to leaseUntil if it is unchanged since it was read
+func (r *SQLiteOutboxRepository) Claim(ctx context.Context, entry *entities.OutboxEntry, leaseUntil time.Time) (bool, error) {
+	if entry == nil || !entry.IsPending() {
+		return false, errors.New("only pending outbox entries can be claimed")
+	}
+
+	claimed := *entry
+	claimed.NextAttemptAt = &leaseUntil
+	data, err := json.Marshal(&claimed)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal outbox entry: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE outbox_entries SET next_attempt_at = ?, data = ? WHERE id = ? AND status = ? AND next_attempt_at = ?",
+		leaseUntil.UnixNano(), string(data), entry.ID, string(entities.OutboxPending), entry.NextAttemptAt.UnixNano(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim outbox entry: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	entry.NextAttemptAt = &leaseUntil
+	return true, nil
+}
+
+// query runs a select over stored entry documents and their sequence
+func (r *SQLiteOutboxRepository) query(ctx context.Context, query string, args ...interface{}) ([]*entities.OutboxEntry, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*entities.OutboxEntry
+	for rows.Next() {
+		var (
+			sequence int64
+			data     string
+			entry    entities.OutboxEntry
+		)
+		if err := rows.Scan(&sequence, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal outbox entry: %w", err)
+		}
+		entry.Sequence = sequence
+		entries = append(entries, &entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// SQLiteOrderEventRepository implements OrderEventRepository on an embedded SQLite database
+type SQLiteOrderEventRepository struct {
+	db     *sql.DB
+	logger interfaces.Logger
+}
+
+// NewSQLiteOrderEventRepository creates a new SQLite order event repository
+func NewSQLiteOrderEventRepository(db *sql.DB, logger interfaces.Logger) interfaces.OrderEventRepository {
+	return &SQLiteOrderEventRepository{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Append adds an event to the end of its order's timeline
+func (r *SQLiteOrderEventRepository) Append(ctx context.Context, event *entities.OrderEvent) error {
+	if event == nil {
+		return errors.New("order event cannot be nil")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal order event: %w", err)
+	}
+
+	_, err = r.db.ExecContext(ctx,
+		"INSERT INTO order_events (id, order_id, occurred_at, data) VALUES (?, ?, ?, ?)",
+		event.ID, event.OrderID, event.OccurredAt.UnixNano(), string(data),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to append order event: %w", err)
+	}
+
+	return nil
+}
+
+// ListByOrderID retrieves the timeline of a MagicSpore order in append order
+func (r *SQLiteOrderEventRepository) ListByOrderID(ctx context.Context, orderID string) ([]*entities.OrderEvent, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT data FROM order_events WHERE order_id = ? ORDER BY seq", orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*entities.OrderEvent
+	for rows.Next() {
+		var (
+			data  string
+			event entities.OrderEvent
+		)
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan order event: %w", err)
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal order event: %w", err)
+		}
+		events = append(events, &event)
+	}
+
+	return events, rows.Err()
+}
@@ -9,11 +9,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"paypal-proxy/internal/domain/entities"
 	"paypal-proxy/internal/domain/interfaces"
 	"strings"
-	"strconv"
 	"time"
 )
 
@@ -86,7 +84,7 @@ func (r *WooCommerceRepository) GetMagicOrder(ctx context.Context, orderID strin
 	var wcOrder WooCommerceOrder
 	err = r.executeWithRetry(ctx, req, func(resp *http.Response) error {
 		if resp.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("order %s not found", orderID)
+			return fmt.Errorf("order %s %w", orderID, interfaces.ErrNotFound)
 		}
 
 		if resp.StatusCode != http.StatusOK {
@@ -119,7 +117,7 @@ func (r *WooCommerceRepository) GetMagicOrder(ctx context.Context, orderID strin
 	r.logger.Info("Successfully fetched MagicSpore order", map[string]interface{}{
 		"order_id": orderID,
 		"status":   order.Status,
-		"total":    order.Total.Amount,
+		"total":    order.Total.Decimal(),
 		"currency": order.Currency,
 	})
 
@@ -130,7 +128,7 @@ func (r *WooCommerceRepository) GetMagicOrder(ctx context.Context, orderID strin
 func (r *WooCommerceRepository) CreateOITAMOrder(ctx context.Context, order *entities.Order) (*entities.Order, error) {
 	r.logger.Info("Creating order on OITAM", map[string]interface{}{
 		"original_order_number": order.Number,
-		"total":                 order.Total.Amount,
+		"total":                 order.Total.Decimal(),
 		"currency":              order.Currency,
 		"line_items":            len(order.LineItems),
 	})
@@ -229,7 +227,7 @@ func (r *WooCommerceRepository) GetOITAMOrder(ctx context.Context, orderID strin
 	var wcOrder WooCommerceOrder
 	err = r.executeWithRetry(ctx, req, func(resp *http.Response) error {
 		if resp.StatusCode == http.StatusNotFound {
-			return fmt.Errorf("order %s not found", orderID)
+			return fmt.Errorf("order %s %w", orderID, interfaces.ErrNotFound)
 		}
 
 		if resp.StatusCode != http.StatusOK {
@@ -262,7 +260,7 @@ func (r *WooCommerceRepository) GetOITAMOrder(ctx context.Context, orderID strin
 	r.logger.Info("Successfully fetched OITAM order", map[string]interface{}{
 		"order_id": orderID,
 		"status":   order.Status,
-		"total":    order.Total.Amount,
+		"total":    order.Total.Decimal(),
 	})
 
 	return order, nil
@@ -288,6 +286,35 @@ func (r *WooCommerceRepository) UpdateOITAMOrderStatus(ctx context.Context, orde
 	return r.updateOrderStatus(ctx, r.oitamConfig, orderID, status)
 }
 
+// TrashOITAMOrder moves an OITAM order to the trash. The order is not deleted
+// permanently, so it can still be restored from the WooCommerce admin.
+func (r *WooCommerceRepository) TrashOITAMOrder(ctx context.Context, orderID string) error {
+	r.logger.Info("Moving OITAM order to trash", map[string]interface{}{
+		"order_id": orderID,
+	})
+
+	apiURL := fmt.Sprintf("%s/wp-json/wc/v3/orders/%s",
+		strings.TrimRight(r.oitamConfig.URL, "/"),
+		orderID)
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", apiURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	r.addWooCommerceAuth(req, r.oitamConfig)
+	r.addStandardHeaders(req)
+
+	return r.executeWithRetry(ctx, req, func(resp *http.Response) error {
+		// 410 Gone means the order is already in the trash
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusGone {
+			body, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("failed to trash order, status: %d, response: %s", resp.StatusCode, string(body))
+		}
+		return nil
+	}, r.oitamConfig.RetryAttempts)
+}
+
 // UpdateMagicOrder updates an order on MagicSpore
 func (r *WooCommerceRepository) UpdateMagicOrder(ctx context.Context, orderID string, order *entities.Order) error {
 	r.logger.Info("Updating order on MagicSpore", map[string]interface{}{
@@ -347,6 +374,88 @@ func (r *WooCommerceRepository) UpdateMagicOrderPayment(ctx context.Context, ord
 	return r.updateOrder(ctx, r.magicConfig, orderID, updateData)
 }
 
+// UpdateMagicOrderTransactionID replaces the PayPal transaction ID recorded on a
+// MagicSpore order without touching its status
+func (r *WooCommerceRepository) UpdateMagicOrderTransactionID(ctx context.Context, orderID string, transactionID string) error {
+	r.logger.Info("Updating MagicSpore order transaction ID", map[string]interface{}{
+		"order_id":       orderID,
+		"transaction_id": transactionID,
+	})
+
+	return r.updateOrder(ctx, r.magicConfig, orderID, map[string]interface{}{
+		"transaction_id": transactionID,
+	})
+}
+
+// AddMagicOrderNote adds a private order note on MagicSpore
+func (r *WooCommerceRepository) AddMagicOrderNote(ctx context.Context, orderID string, note string) error {
+	r.logger.Info("Adding MagicSpore order note", map[string]interface{}{
+		"order_id": orderID,
+	})
+
+	apiURL := fmt.Sprintf("%s/wp-json/wc/v3/orders/%s/notes",
+		strings.TrimRight(r.magicConfig.URL, "/"),
+		orderID)
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"note":          note,
+		"customer_note": false,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal order note: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	r.addWooCommerceAuth(req, r.magicConfig)
+	r.addStandardHeaders(req)
+
+	return r.executeWithRetry(ctx, req, func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("failed to add order note, status: %d, response: %s", resp.StatusCode, string(body))
+		}
+		return nil
+	}, r.magicConfig.RetryAttempts)
+}
+
+// UpdateMagicOrderMetaData adds or replaces meta data entries on a MagicSpore order
+func (r *WooCommerceRepository) UpdateMagicOrderMetaData(ctx context.Context, orderID string, metaData []entities.MetaData) error {
+	r.logger.Info("Updating MagicSpore order meta data", map[string]interface{}{
+		"order_id": orderID,
+		"entries":  len(metaData),
+	})
+
+	return r.updateOrder(ctx, r.magicConfig, orderID, map[string]interface{}{
+		"meta_data": convertMetaDataToWC(metaData),
+	})
+}
+
+// CreateMagicOrderRefund records a refund on a MagicSpore order
+func (r *WooCommerceRepository) CreateMagicOrderRefund(ctx context.Context, orderID string, refund *entities.OrderRefund) (*entities.OrderRefund, error) {
+	r.logger.Info("Creating MagicSpore order refund", map[string]interface{}{
+		"order_id": orderID,
+		"amount":   refund.Amount.Decimal(),
+		"currency": refund.Amount.Currency,
+	})
+
+	return r.createRefund(ctx, r.magicConfig, orderID, refund)
+}
+
+// CreateOITAMOrderRefund records a refund on an OITAM proxy order
+func (r *WooCommerceRepository) CreateOITAMOrderRefund(ctx context.Context, orderID string, refund *entities.OrderRefund) (*entities.OrderRefund, error) {
+	r.logger.Info("Creating OITAM order refund", map[string]interface{}{
+		"proxy_order_id": orderID,
+		"amount":         refund.Amount.Decimal(),
+		"currency":       refund.Amount.Currency,
+	})
+
+	return r.createRefund(ctx, r.oitamConfig, orderID, refund)
+}
+
 // Helper methods
 
 // addWooCommerceAuth adds WooCommerce API authentication to request
@@ -384,8 +493,15 @@ func (r *WooCommerceRepository) executeWithRetry(ctx context.Context, req *http.
 			})
 		}
 
-		// Clone request for retry
+		// Clone request for retry, rewinding the body consumed by earlier attempts
 		reqClone := req.Clone(ctx)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			reqClone.Body = body
+		}
 
 		resp, err := r.httpClient.Do(reqClone)
 		if err != nil {
@@ -459,6 +575,66 @@ func (r *WooCommerceRepository) updateOrder(ctx context.Context, config WooComme
 	}, config.RetryAttempts)
 }
 
+// createRefund posts a refund to an order's refunds endpoint. The money has
+// already been returned through PayPal, so WooCommerce is told not to refund
+// through its own gateway, and the request is never retried because a
+// repeated refund would be recorded twice.
+func (r *WooCommerceRepository) createRefund(ctx context.Context, config WooCommerceConfig, orderID string, refund *entities.OrderRefund) (*entities.OrderRefund, error) {
+	apiURL := fmt.Sprintf("%s/wp-json/wc/v3/orders/%s/refunds",
+		strings.TrimRight(config.URL, "/"),
+		orderID)
+
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"amount":     refund.Amount.ToWooCommerceFormat(),
+		"reason":     refund.Reason,
+		"api_refund": false,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal refund: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	r.addWooCommerceAuth(req, config)
+	r.addStandardHeaders(req)
+
+	var created WooCommerceRefund
+	err = r.executeWithRetry(ctx, req, func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("failed to create refund, status: %d, response: %s", resp.StatusCode, string(body))
+		}
+
+		if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+			return fmt.Errorf("failed to decode refund response: %w", err)
+		}
+		return nil
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, err := entities.FromWooCommerceFormat(created.Amount, refund.Amount.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid refund amount: %w", err)
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, created.DateCreated)
+	if err != nil {
+		createdAt = time.Now()
+	}
+
+	return &entities.OrderRefund{
+		ID:          created.ID,
+		Amount:      amount,
+		Reason:      created.Reason,
+		DateCreated: createdAt,
+	}, nil
+}
+
 // updateOrderFull updates order with complete data
 func (r *WooCommerceRepository) updateOrderFull(ctx context.Context, config WooCommerceConfig, orderID string, orderData interface{}) error {
 	jsonData, err := json.Marshal(orderData)
@@ -479,7 +655,7 @@ func (r *WooCommerceRepository) updateOrderFull(ctx context.Context, config WooC
 	return r.executeWithRetry(ctx, req, func(resp *http.Response) error {
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
-			return fmt.Errorf("failed to update order, status: %d", resp.StatusCode)
+			return fmt.Errorf("failed to update order, status: %d, response: %s", resp.StatusCode, string(body))
 		}
 		return nil
 	}, config.RetryAttempts)
@@ -512,6 +688,14 @@ type WooCommerceOrder struct {
 	MetaData        []WooCommerceMetaData  `json:"meta_data"`
 }
 
+// WooCommerceRefund represents WooCommerce API order refund format
+type WooCommerceRefund struct {
+	ID          int    `json:"id"`
+	DateCreated string `json:"date_created"`
+	Amount      string `json:"amount"`
+	Reason      string `json:"reason"`
+}
+
 type WooCommerceAddress struct {
 	FirstName string `json:"first_name"`
 	LastName  string `json:"last_name"`
@@ -581,9 +765,9 @@ type WooCommerceMetaData struct {
 
 // convertWooCommerceToEntity converts WooCommerce API response to domain entity
 func (r *WooCommerceRepository) convertWooCommerceToEntity(wcOrder *WooCommerceOrder) (*entities.Order, error) {
-	totalAmount, err := strconv.ParseFloat(wcOrder.Total, 64)
+	total, err := entities.FromWooCommerceFormat(wcOrder.Total, wcOrder.Currency)
 	if err != nil {
-		return nil, fmt.Errorf("invalid total amount: %s", wcOrder.Total)
+		return nil, fmt.Errorf("invalid total amount: %w", err)
 	}
 
 	createdAt, err := time.Parse(time.RFC3339, wcOrder.DateCreated)
@@ -596,7 +780,7 @@ func (r *WooCommerceRepository) convertWooCommerceToEntity(wcOrder *WooCommerceO
 		Number:        wcOrder.Number,
 		Status:        entities.OrderStatus(wcOrder.Status),
 		Currency:      wcOrder.Currency,
-		Total:         entities.Money{Amount: totalAmount, Currency: wcOrder.Currency},
+		Total:         total,
 		PaymentMethod: wcOrder.PaymentMethod,
 		PaymentMethodTitle: wcOrder.PaymentMethodTitle,
 		TransactionID: wcOrder.TransactionID,
@@ -605,6 +789,11 @@ func (r *WooCommerceRepository) convertWooCommerceToEntity(wcOrder *WooCommerceO
 		CustomerNote:  wcOrder.CustomerNote,
 	}
 
+	for _, meta := range wcOrder.MetaData {
+		order.MetaData = append(order.MetaData, entities.MetaData{ID: meta.ID, Key: meta.Key, Value: meta.Value})
+	}
+	order.StatusHistory = entities.StatusHistoryFromMetaData(order.MetaData)
+
 	// Convert addresses
 	order.Billing = r.convertAddress(wcOrder.Billing)
 	order.Shipping = r.convertAddress(wcOrder.Shipping)
@@ -626,6 +815,13 @@ func (r *WooCommerceRepository) convertWooCommerceToEntity(wcOrder *WooCommerceO
 		}
 		order.ShippingLines = append(order.ShippingLines, *convertedShipping)
 	}
+	for _, fee := range wcOrder.FeeLines {
+		convertedFee, err := r.convertFeeLine(fee, wcOrder.Currency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert fee line: %w", err)
+		}
+		order.FeeLines = append(order.FeeLines, *convertedFee)
+	}
 
 	return order, nil
 }
@@ -650,70 +846,93 @@ func (r *WooCommerceRepository) convertEntityToWooCommerce(order *entities.Order
 	wcOrder["line_items"] = lineItems
 
 	// Add metadata for proxy orders
+	var metaData []map[string]interface{}
 	if order.Number != "" {
-		wcOrder["meta_data"] = []map[string]interface{}{
-			{
+		metaData = append(metaData,
+			map[string]interface{}{
 				"key":   "_original_order_number",
 				"value": order.Number,
 			},
-			{
+			map[string]interface{}{
 				"key":   "_proxy_order",
 				"value": "true",
 			},
-		}
+		)
+	}
+	if len(order.StatusHistory) > 0 {
+		metaData = append(metaData, convertMetaDataToWC([]entities.MetaData{order.StatusHistoryMetaData()})...)
+	}
+	if metaData != nil {
+		wcOrder["meta_data"] = metaData
 	}
 
 	return wcOrder
 }
 
-// convertToOITAMOrder converts order to OITAM format for creation
+// convertToOITAMOrder converts order to OITAM format for creation. The order
+// is already anonymized by the domain's anonymization policy, so its names
+// and meta data are sent as they are.
 func (r *WooCommerceRepository) convertToOITAMOrder(order *entities.Order) map[string]interface{} {
-	// Convert line items with anonymized names
 	var lineItems []map[string]interface{}
-	for i, item := range order.LineItems {
+	for _, item := range order.LineItems {
 		lineItem := map[string]interface{}{
-			"name":     fmt.Sprintf("Item %d", i+1), // Anonymized name
-			"quantity": item.Quantity,
-			"total":    item.Total.ToWooCommerceFormat(),
-			"sku":      item.SKU, // Keep SKU for inventory tracking
-			"meta_data": []map[string]interface{}{
-				{
-					"key":   "_original_name",
-					"value": item.Name,
-				},
-				{
-					"key":   "_original_product_id",
-					"value": item.ProductID,
-				},
-			},
+			"name":      item.Name,
+			"quantity":  item.Quantity,
+			"total":     item.Total.ToWooCommerceFormat(),
+			"meta_data": convertMetaDataToWC(item.MetaData),
+		}
+		if item.SKU != "" {
+			lineItem["sku"] = item.SKU
 		}
 		lineItems = append(lineItems, lineItem)
 	}
 
+	shippingLines := make([]map[string]interface{}, 0, len(order.ShippingLines))
+	for _, line := range order.ShippingLines {
+		shippingLines = append(shippingLines, map[string]interface{}{
+			"method_id":    line.MethodID,
+			"method_title": line.MethodTitle,
+			"total":        line.Total.ToWooCommerceFormat(),
+		})
+	}
+
+	feeLines := make([]map[string]interface{}, 0, len(order.FeeLines))
+	for _, line := range order.FeeLines {
+		feeLines = append(feeLines, map[string]interface{}{
+			"name":  line.Name,
+			"total": line.Total.ToWooCommerceFormat(),
+		})
+	}
+
 	return map[string]interface{}{
 		"status":   "pending",
 		"currency": order.Currency,
 		"total":    order.Total.ToWooCommerceFormat(),
 		"billing":  r.convertAddressToWC(order.Billing),
 		"shipping": r.convertAddressToWC(order.Shipping),
-		"line_items":     lineItems,
-		"payment_method": "paypal",
+		"customer_note":        order.CustomerNote,
+		"line_items":           lineItems,
+		"shipping_lines":       shippingLines,
+		"fee_lines":            feeLines,
+		"payment_method":       "paypal",
 		"payment_method_title": "PayPal",
-		"meta_data": []map[string]interface{}{
-			{
-				"key":   "_original_order_number",
-				"value": order.Number,
-			},
-			{
-				"key":   "_proxy_order",
-				"value": "true",
-			},
-			{
-				"key":   "_proxy_created_at",
-				"value": time.Now().Unix(),
-			},
-		},
+		"meta_data": append(convertMetaDataToWC(order.MetaData), map[string]interface{}{
+			"key":   "_proxy_created_at",
+			"value": time.Now().Unix(),
+		}),
+	}
+}
+
+// convertMetaDataToWC converts meta data entries to WooCommerce API format
+func convertMetaDataToWC(metaData []entities.MetaData) []map[string]interface{} {
+	converted := make([]map[string]interface{}, 0, len(metaData))
+	for _, meta := range metaData {
+		converted = append(converted, map[string]interface{}{
+			"key":   meta.Key,
+			"value": meta.Value,
+		})
 	}
+	return converted
 }
 
 // Address conversion helpers
@@ -749,19 +968,20 @@ func (r *WooCommerceRepository) convertAddressToWC(addr entities.Address) map[st
 	}
 }
 
-// Line item conversion helpers
+// Line item conversion helpers. WooCommerce keeps line amounts unrounded when
+// taxes are rounded at subtotal level, so they are rounded to the minor unit.
 func (r *WooCommerceRepository) convertLineItem(wcItem WooCommerceLineItem, currency string) (*entities.LineItem, error) {
-	priceAmount, err := strconv.ParseFloat(wcItem.Price, 64)
+	price, err := entities.ParseMoneyRounded(wcItem.Price, currency, entities.RoundHalfUp)
 	if err != nil {
 		return nil, fmt.Errorf("invalid price: %s", wcItem.Price)
 	}
 
-	subtotalAmount, err := strconv.ParseFloat(wcItem.Subtotal, 64)
+	subtotal, err := entities.ParseMoneyRounded(wcItem.Subtotal, currency, entities.RoundHalfUp)
 	if err != nil {
 		return nil, fmt.Errorf("invalid subtotal: %s", wcItem.Subtotal)
 	}
 
-	totalAmount, err := strconv.ParseFloat(wcItem.Total, 64)
+	total, err := entities.ParseMoneyRounded(wcItem.Total, currency, entities.RoundHalfUp)
 	if err != nil {
 		return nil, fmt.Errorf("invalid total: %s", wcItem.Total)
 	}
@@ -773,9 +993,9 @@ func (r *WooCommerceRepository) convertLineItem(wcItem WooCommerceLineItem, curr
 		VariationID: wcItem.VariationID,
 		Quantity:    wcItem.Quantity,
 		SKU:         wcItem.SKU,
-		Price:       entities.Money{Amount: priceAmount, Currency: currency},
-		Subtotal:    entities.Money{Amount: subtotalAmount, Currency: currency},
-		Total:       entities.Money{Amount: totalAmount, Currency: currency},
+		Price:       price,
+		Subtotal:    subtotal,
+		Total:       total,
 	}, nil
 }
 
@@ -791,7 +1011,7 @@ func (r *WooCommerceRepository) convertLineItemToWC(item entities.LineItem) map[
 
 // Shipping line conversion helpers
 func (r *WooCommerceRepository) convertShippingLine(wcShipping WooCommerceShipping, currency string) (*entities.ShippingLine, error) {
-	totalAmount, err := strconv.ParseFloat(wcShipping.Total, 64)
+	total, err := entities.ParseMoneyRounded(wcShipping.Total, currency, entities.RoundHalfUp)
 	if err != nil {
 		return nil, fmt.Errorf("invalid shipping total: %s", wcShipping.Total)
 	}
@@ -800,6 +1020,20 @@ func (r *WooCommerceRepository) convertShippingLine(wcShipping WooCommerceShippi
 		ID:          wcShipping.ID,
 		MethodID:    wcShipping.MethodID,
 		MethodTitle: wcShipping.MethodTitle,
-		Total:       entities.Money{Amount: totalAmount, Currency: currency},
+		Total:       total,
+	}, nil
+}
+
+// Fee line conversion helpers
+func (r *WooCommerceRepository) convertFeeLine(wcFee WooCommerceFee, currency string) (*entities.FeeLine, error) {
+	total, err := entities.ParseMoneyRounded(wcFee.Total, currency, entities.RoundHalfUp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid fee total: %s", wcFee.Total)
+	}
+
+	return &entities.FeeLine{
+		ID:    wcFee.ID,
+		Name:  wcFee.Name,
+		Total: total,
 	}, nil
 }
\ No newline at end of file
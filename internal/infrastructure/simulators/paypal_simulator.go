@@ -0,0 +1,1098 @@
+package simulators
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"paypal-proxy/internal/domain/interfaces"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PayPalOutcome scripts how the simulated buyer and PayPal treat an order
+type PayPalOutcome string
+
+const (
+	PayPalOutcomeApprove PayPalOutcome = "approve" // buyer approves and the capture completes
+	PayPalOutcomeDeny    PayPalOutcome = "deny"    // buyer approves but the capture is declined
+	PayPalOutcomeCancel  PayPalOutcome = "cancel"  // buyer cancels on the approval page
+	PayPalOutcomeDelay   PayPalOutcome = "delay"   // capture stays pending until settled
+)
+
+// PayPalScenario scripts the outcome for orders carrying a given reference
+type PayPalScenario struct {
+	Outcome PayPalOutcome
+	// SettleAfter completes delayed captures automatically; zero waits for SettleCapture
+	SettleAfter time.Duration
+}
+
+// PayPalSimulatorConfig holds PayPal simulator configuration
+type PayPalSimulatorConfig struct {
+	ClientID      string        // expected OAuth client ID; empty accepts any
+	ClientSecret  string        // expected OAuth client secret; empty accepts any
+	BaseURL       string        // public URL used in links; derived from requests when empty
+	WebhookURL    string        // receiver for emitted webhooks; empty disables delivery
+	WebhookID     string        // webhook ID included in transmission signatures
+	WebhookSecret string        // also sign webhooks with an HMAC header when set
+	TokenTTL      time.Duration // access token lifetime
+}
+
+// PayPalWebhookDelivery records a webhook emitted by the simulator
+type PayPalWebhookDelivery struct {
+	EventID    string          `json:"event_id"`
+	EventType  string          `json:"event_type"`
+	ResourceID string          `json:"resource_id"`
+	Event      json.RawMessage `json:"event"`
+	Headers    http.Header     `json:"headers"`
+	StatusCode int             `json:"status_code"`
+	Error      string          `json:"error,omitempty"`
+	SentAt     time.Time       `json:"sent_at"`
+}
+
+// PayPalSimulator is an in-process stand-in for the PayPal REST API. It serves
+// the OAuth, Orders v2, captures, refunds and webhook verification endpoints
+// the gateway uses, a buyer approval page, and /simulator admin endpoints for
+// scripting outcomes from out-of-process tests.
+type PayPalSimulator struct {
+	config     PayPalSimulatorConfig
+	logger     interfaces.Logger
+	httpClient *http.Client
+
+	privateKey *rsa.PrivateKey
+	certPEM    []byte
+
+	mutex      sync.Mutex
+	sequence   int
+	lastBase   string
+	tokens     map[string]time.Time
+	orders     map[string]*simOrder
+	captures   map[string]*simCapture
+	scenarios  map[string]PayPalScenario
+	idempotent map[string]simResponse
+	webhooks   []*PayPalWebhookDelivery
+	timers     []*time.Timer
+	deliveries sync.WaitGroup
+}
+
+type simMoney struct {
+	CurrencyCode string `json:"currency_code"`
+	Value        string `json:"value"`
+}
+
+type simOrder struct {
+	ID          string
+	Status      string
+	Intent      string
+	ReferenceID string
+	CustomID    string
+	InvoiceID   string
+	Description string
+	Amount      simMoney
+	ReturnURL   string
+	CancelURL   string
+	PayerID     string
+	CaptureIDs  []string
+	CreateTime  time.Time
+	UpdateTime  time.Time
+}
+
+type simCapture struct {
+	ID            string
+	OrderID       string
+	Status        string
+	StatusReason  string
+	Amount        simMoney
+	RefundedCents int64
+	CustomID      string
+	InvoiceID     string
+	CreateTime    time.Time
+	UpdateTime    time.Time
+}
+
+type simResponse struct {
+	status int
+	body   []byte
+}
+
+// simError is a PayPal-shaped error response
+type simError struct {
+	Name    string           `json:"name"`
+	Message string           `json:"message"`
+	DebugID string           `json:"debug_id"`
+	Details []simErrorDetail `json:"details,omitempty"`
+}
+
+type simErrorDetail struct {
+	Issue       string `json:"issue"`
+	Description string `json:"description"`
+}
+
+const simulatorCertPath = "/v1/notifications/certs/CERT-simulator"
+
+// DefaultPayPalWebhookID is the webhook ID used when none is configured
+const DefaultPayPalWebhookID = "WH-SIMULATOR"
+
+// NewPayPalSimulator creates a new PayPal simulator
+func NewPayPalSimulator(config PayPalSimulatorConfig, logger interfaces.Logger) (*PayPalSimulator, error) {
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = 9 * time.Hour
+	}
+	if config.WebhookID == "" {
+		config.WebhookID = DefaultPayPalWebhookID
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+
+	privateKey, certPEM, err := newSigningCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook signing certificate: %w", err)
+	}
+
+	s := &PayPalSimulator{
+		config:     config,
+		logger:     logger,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		privateKey: privateKey,
+		certPEM:    certPEM,
+	}
+	s.Reset()
+
+	return s, nil
+}
+
+// SetScenario scripts the outcome for orders whose custom_id or reference_id
+// matches reference. An empty reference sets the default outcome.
+func (s *PayPalSimulator) SetScenario(reference string, scenario PayPalScenario) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scenarios[reference] = scenario
+}
+
+// ApproveOrder approves an order as the buyer would on the approval page
+func (s *PayPalSimulator) ApproveOrder(orderID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, exists := s.orders[orderID]
+	if !exists {
+		return fmt.Errorf("order %s not found", orderID)
+	}
+	return s.approveLocked(order)
+}
+
+// SettleCapture completes or declines a pending capture
+func (s *PayPalSimulator) SettleCapture(captureID string, outcome PayPalOutcome) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.settleLocked(captureID, outcome)
+}
+
+// ExpireTokens invalidates every issued access token
+func (s *PayPalSimulator) ExpireTokens() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tokens = make(map[string]time.Time)
+}
+
+// Webhooks returns the webhooks emitted so far
+func (s *PayPalSimulator) Webhooks() []PayPalWebhookDelivery {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make([]PayPalWebhookDelivery, 0, len(s.webhooks))
+	for _, delivery := range s.webhooks {
+		result = append(result, *delivery)
+	}
+	return result
+}
+
+// Flush waits for in-flight webhook deliveries to finish
+func (s *PayPalSimulator) Flush() {
+	s.deliveries.Wait()
+}
+
+// Reset discards all orders, captures, tokens, scenarios and webhooks
+func (s *PayPalSimulator) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, timer := range s.timers {
+		timer.Stop()
+	}
+	s.timers = nil
+	s.tokens = make(map[string]time.Time)
+	s.orders = make(map[string]*simOrder)
+	s.captures = make(map[string]*simCapture)
+	s.scenarios = make(map[string]PayPalScenario)
+	s.idempotent = make(map[string]simResponse)
+	s.webhooks = nil
+}
+
+// ServeHTTP routes simulator requests
+func (s *PayPalSimulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimRight(r.URL.Path, "/")
+	s.rememberBase(r)
+
+	switch {
+	case path == "/v1/oauth2/token" && r.Method == http.MethodPost:
+		s.handleToken(w, r)
+	case path == "/checkoutnow" && r.Method == http.MethodGet:
+		s.handleCheckout(w, r)
+	case path == simulatorCertPath && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Write(s.certPEM)
+	case strings.HasPrefix(path, "/simulator/"):
+		s.handleAdmin(w, r, strings.TrimPrefix(path, "/simulator/"))
+	default:
+		s.handleAPI(w, r, path)
+	}
+}
+
+// handleToken issues OAuth2 client-credentials tokens
+func (s *PayPalSimulator) handleToken(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || (s.config.ClientID != "" && clientID != s.config.ClientID) ||
+		(s.config.ClientSecret != "" && clientSecret != s.config.ClientSecret) {
+		writeSimJSON(w, http.StatusUnauthorized, map[string]string{
+			"error":             "invalid_client",
+			"error_description": "Client Authentication failed",
+		})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		writeSimJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "unsupported_grant_type",
+			"error_description": "Grant Type is NULL",
+		})
+		return
+	}
+
+	s.mutex.Lock()
+	token := "A21AA" + s.nextID("TOKEN")
+	s.tokens[token] = time.Now().Add(s.config.TokenTTL)
+	s.mutex.Unlock()
+
+	writeSimJSON(w, http.StatusOK, map[string]interface{}{
+		"scope":        "https://uri.paypal.com/services/payments/payment",
+		"access_token": token,
+		"token_type":   "Bearer",
+		"app_id":       "APP-SIMULATOR",
+		"expires_in":   int(s.config.TokenTTL.Seconds()),
+	})
+}
+
+// handleCheckout plays the buyer on the PayPal approval page
+func (s *PayPalSimulator) handleCheckout(w http.ResponseWriter, r *http.Request) {
+	orderID := r.URL.Query().Get("token")
+
+	s.mutex.Lock()
+	order, exists := s.orders[orderID]
+	if !exists {
+		s.mutex.Unlock()
+		http.Error(w, "order not found", http.StatusNotFound)
+		return
+	}
+
+	var target string
+	if s.scenarioFor(order).Outcome == PayPalOutcomeCancel {
+		target = appendQuery(order.CancelURL, url.Values{"token": {order.ID}})
+	} else {
+		if err := s.approveLocked(order); err != nil {
+			s.mutex.Unlock()
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		target = appendQuery(order.ReturnURL, url.Values{"token": {order.ID}, "PayerID": {order.PayerID}})
+	}
+	rendered := s.renderOrder(order)
+	s.mutex.Unlock()
+
+	if target == "" {
+		writeSimJSON(w, http.StatusOK, rendered)
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// handleAPI serves authenticated REST endpoints
+func (s *PayPalSimulator) handleAPI(w http.ResponseWriter, r *http.Request, path string) {
+	if !s.authorized(r) {
+		writeSimJSON(w, http.StatusUnauthorized, simError{
+			Name:    "AUTHENTICATION_FAILURE",
+			Message: "Authentication failed due to invalid authentication credentials or a missing Authorization header.",
+			DebugID: s.debugID(),
+		})
+		return
+	}
+
+	var body []byte
+	if r.Body != nil {
+		buffer := new(bytes.Buffer)
+		buffer.ReadFrom(r.Body)
+		body = buffer.Bytes()
+	}
+
+	// Replay POST responses for a repeated PayPal-Request-Id
+	idempotencyKey := ""
+	if requestID := r.Header.Get("PayPal-Request-Id"); requestID != "" && r.Method == http.MethodPost {
+		idempotencyKey = requestID + " " + path
+		s.mutex.Lock()
+		cached, exists := s.idempotent[idempotencyKey]
+		s.mutex.Unlock()
+		if exists {
+			writeSimRaw(w, cached.status, cached.body)
+			return
+		}
+	}
+
+	status, response := s.route(r.Method, strings.Split(strings.Trim(path, "/"), "/"), body)
+
+	encoded, _ := json.Marshal(response)
+	if idempotencyKey != "" && status < 500 {
+		s.mutex.Lock()
+		s.idempotent[idempotencyKey] = simResponse{status: status, body: encoded}
+		s.mutex.Unlock()
+	}
+	writeSimRaw(w, status, encoded)
+}
+
+// route dispatches an authenticated API call
+func (s *PayPalSimulator) route(method string, parts []string, body []byte) (int, interface{}) {
+	switch {
+	case method == http.MethodPost && matchPath(parts, "v2", "checkout", "orders"):
+		return s.createOrder(body)
+	case method == http.MethodGet && matchPath(parts, "v2", "checkout", "orders", "*"):
+		return s.getOrder(parts[3])
+	case method == http.MethodPost && matchPath(parts, "v2", "checkout", "orders", "*", "capture"):
+		return s.captureOrder(parts[3])
+	case method == http.MethodGet && matchPath(parts, "v2", "payments", "captures", "*"):
+		return s.getCapture(parts[3])
+	case method == http.MethodPost && matchPath(parts, "v2", "payments", "captures", "*", "refund"):
+		return s.refundCapture(parts[3], body)
+	case method == http.MethodPost && matchPath(parts, "v1", "notifications", "verify-webhook-signature"):
+		return s.verifyWebhookSignature(body)
+	}
+
+	return http.StatusNotFound, s.newError("RESOURCE_NOT_FOUND", "The specified resource does not exist.", "")
+}
+
+// createOrder creates an order awaiting buyer approval
+func (s *PayPalSimulator) createOrder(body []byte) (int, interface{}) {
+	var request struct {
+		Intent        string `json:"intent"`
+		PurchaseUnits []struct {
+			ReferenceID string   `json:"reference_id"`
+			CustomID    string   `json:"custom_id"`
+			InvoiceID   string   `json:"invoice_id"`
+			Description string   `json:"description"`
+			Amount      simMoney `json:"amount"`
+		} `json:"purchase_units"`
+		PaymentSource struct {
+			PayPal struct {
+				ExperienceContext struct {
+					ReturnURL string `json:"return_url"`
+					CancelURL string `json:"cancel_url"`
+				} `json:"experience_context"`
+			} `json:"paypal"`
+		} `json:"payment_source"`
+		ApplicationContext struct {
+			ReturnURL string `json:"return_url"`
+			CancelURL string `json:"cancel_url"`
+		} `json:"application_context"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return http.StatusBadRequest, s.newError("INVALID_REQUEST", "Request is not well-formed, syntactically incorrect, or violates schema.", "MALFORMED_REQUEST_JSON")
+	}
+	if request.Intent != "CAPTURE" {
+		return http.StatusUnprocessableEntity, s.newError("UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "UNSUPPORTED_INTENT")
+	}
+	if len(request.PurchaseUnits) != 1 {
+		return http.StatusUnprocessableEntity, s.newError("UNPROCESSABLE_ENTITY", "The simulator supports exactly one purchase unit.", "INVALID_PARAMETER_VALUE")
+	}
+
+	unit := request.PurchaseUnits[0]
+	if cents, ok := parseCents(unit.Amount.Value); !ok || cents <= 0 || unit.Amount.CurrencyCode == "" {
+		return http.StatusUnprocessableEntity, s.newError("UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "INVALID_AMOUNT")
+	}
+
+	returnURL := request.PaymentSource.PayPal.ExperienceContext.ReturnURL
+	cancelURL := request.PaymentSource.PayPal.ExperienceContext.CancelURL
+	if returnURL == "" {
+		returnURL = request.ApplicationContext.ReturnURL
+		cancelURL = request.ApplicationContext.CancelURL
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UTC()
+	order := &simOrder{
+		ID:          s.nextID("ORDER"),
+		Status:      "PAYER_ACTION_REQUIRED",
+		Intent:      request.Intent,
+		ReferenceID: unit.ReferenceID,
+		CustomID:    unit.CustomID,
+		InvoiceID:   unit.InvoiceID,
+		Description: unit.Description,
+		Amount:      unit.Amount,
+		ReturnURL:   returnURL,
+		CancelURL:   cancelURL,
+		CreateTime:  now,
+		UpdateTime:  now,
+	}
+	s.orders[order.ID] = order
+
+	s.logger.Info("PayPal simulator order created", map[string]interface{}{
+		"paypal_order_id": order.ID,
+		"custom_id":       order.CustomID,
+		"amount":          order.Amount.Value,
+	})
+
+	return http.StatusCreated, s.renderOrder(order)
+}
+
+// getOrder returns an order
+func (s *PayPalSimulator) getOrder(orderID string) (int, interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, exists := s.orders[orderID]
+	if !exists {
+		return http.StatusNotFound, s.newError("RESOURCE_NOT_FOUND", "The specified resource does not exist.", "INVALID_RESOURCE_ID")
+	}
+	return http.StatusOK, s.renderOrder(order)
+}
+
+// captureOrder captures an approved order according to its scenario
+func (s *PayPalSimulator) captureOrder(orderID string) (int, interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, exists := s.orders[orderID]
+	if !exists {
+		return http.StatusNotFound, s.newError("RESOURCE_NOT_FOUND", "The specified resource does not exist.", "INVALID_RESOURCE_ID")
+	}
+
+	switch order.Status {
+	case "APPROVED":
+	case "COMPLETED":
+		return http.StatusUnprocessableEntity, s.newError("UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "ORDER_ALREADY_CAPTURED")
+	default:
+		return http.StatusUnprocessableEntity, s.newError("UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "ORDER_NOT_APPROVED")
+	}
+
+	scenario := s.scenarioFor(order)
+	now := time.Now().UTC()
+	capture := &simCapture{
+		ID:         s.nextID("CAPTURE"),
+		OrderID:    order.ID,
+		Status:     "COMPLETED",
+		Amount:     order.Amount,
+		CustomID:   order.CustomID,
+		InvoiceID:  order.InvoiceID,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+
+	eventType := "PAYMENT.CAPTURE.COMPLETED"
+	switch scenario.Outcome {
+	case PayPalOutcomeDeny:
+		capture.Status = "DECLINED"
+		eventType = "PAYMENT.CAPTURE.DENIED"
+	case PayPalOutcomeDelay:
+		capture.Status = "PENDING"
+		capture.StatusReason = "PENDING_REVIEW"
+		eventType = "PAYMENT.CAPTURE.PENDING"
+	}
+
+	s.captures[capture.ID] = capture
+	order.CaptureIDs = append(order.CaptureIDs, capture.ID)
+	order.Status = "COMPLETED"
+	order.UpdateTime = now
+
+	s.logger.Info("PayPal simulator order captured", map[string]interface{}{
+		"paypal_order_id": order.ID,
+		"capture_id":      capture.ID,
+		"status":          capture.Status,
+	})
+
+	s.emitLocked(eventType, "capture", capture.ID, s.renderCapture(capture))
+	s.emitLocked("CHECKOUT.ORDER.COMPLETED", "checkout-order", order.ID, s.renderOrder(order))
+
+	if scenario.Outcome == PayPalOutcomeDelay && scenario.SettleAfter > 0 {
+		captureID := capture.ID
+		s.timers = append(s.timers, time.AfterFunc(scenario.SettleAfter, func() {
+			s.SettleCapture(captureID, PayPalOutcomeApprove)
+		}))
+	}
+
+	return http.StatusCreated, s.renderOrder(order)
+}
+
+// getCapture returns a capture
+func (s *PayPalSimulator) getCapture(captureID string) (int, interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	capture, exists := s.captures[captureID]
+	if !exists {
+		return http.StatusNotFound, s.newError("RESOURCE_NOT_FOUND", "The specified resource does not exist.", "INVALID_RESOURCE_ID")
+	}
+	return http.StatusOK, s.renderCapture(capture)
+}
+
+// refundCapture refunds a completed capture in full or in part
+func (s *PayPalSimulator) refundCapture(captureID string, body []byte) (int, interface{}) {
+	var request struct {
+		Amount *simMoney `json:"amount"`
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &request); err != nil {
+			return http.StatusBadRequest, s.newError("INVALID_REQUEST", "Request is not well-formed, syntactically incorrect, or violates schema.", "MALFORMED_REQUEST_JSON")
+		}
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	capture, exists := s.captures[captureID]
+	if !exists {
+		return http.StatusNotFound, s.newError("RESOURCE_NOT_FOUND", "The specified resource does not exist.", "INVALID_RESOURCE_ID")
+	}
+	if capture.Status != "COMPLETED" && capture.Status != "PARTIALLY_REFUNDED" {
+		return http.StatusUnprocessableEntity, s.newError("UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "CAPTURE_FULLY_REFUNDED")
+	}
+
+	captured, _ := parseCents(capture.Amount.Value)
+	remaining := captured - capture.RefundedCents
+	refundCents := remaining
+	if request.Amount != nil {
+		if !strings.EqualFold(request.Amount.CurrencyCode, capture.Amount.CurrencyCode) {
+			return http.StatusUnprocessableEntity, s.newError("UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "REFUND_CURRENCY_MISMATCH")
+		}
+		cents, ok := parseCents(request.Amount.Value)
+		if !ok || cents <= 0 {
+			return http.StatusUnprocessableEntity, s.newError("UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "INVALID_AMOUNT")
+		}
+		refundCents = cents
+	}
+	if refundCents > remaining {
+		return http.StatusUnprocessableEntity, s.newError("UNPROCESSABLE_ENTITY", "The requested action could not be performed.", "REFUND_AMOUNT_EXCEEDED")
+	}
+
+	now := time.Now().UTC()
+	capture.RefundedCents += refundCents
+	capture.UpdateTime = now
+	capture.Status = "PARTIALLY_REFUNDED"
+	if capture.RefundedCents == captured {
+		capture.Status = "REFUNDED"
+	}
+
+	refund := map[string]interface{}{
+		"id":          s.nextID("REFUND"),
+		"status":      "COMPLETED",
+		"amount":      simMoney{CurrencyCode: capture.Amount.CurrencyCode, Value: formatCents(refundCents)},
+		"custom_id":   capture.CustomID,
+		"invoice_id":  capture.InvoiceID,
+		"create_time": now.Format(time.RFC3339),
+		"update_time": now.Format(time.RFC3339),
+		"links": []map[string]string{
+			{"href": s.base() + "/v2/payments/captures/" + capture.ID, "rel": "up", "method": "GET"},
+		},
+	}
+
+	s.logger.Info("PayPal simulator capture refunded", map[string]interface{}{
+		"capture_id": capture.ID,
+		"refund_id":  refund["id"],
+		"amount":     formatCents(refundCents),
+	})
+
+	s.emitLocked("PAYMENT.CAPTURE.REFUNDED", "refund", refund["id"].(string), refund)
+
+	return http.StatusCreated, refund
+}
+
+// verifyWebhookSignature checks a transmission signature the way
+// /v1/notifications/verify-webhook-signature does
+func (s *PayPalSimulator) verifyWebhookSignature(body []byte) (int, interface{}) {
+	var request struct {
+		AuthAlgo         string          `json:"auth_algo"`
+		CertURL          string          `json:"cert_url"`
+		TransmissionID   string          `json:"transmission_id"`
+		TransmissionSig  string          `json:"transmission_sig"`
+		TransmissionTime string          `json:"transmission_time"`
+		WebhookID        string          `json:"webhook_id"`
+		WebhookEvent     json.RawMessage `json:"webhook_event"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return http.StatusBadRequest, s.newError("INVALID_REQUEST", "Request is not well-formed, syntactically incorrect, or violates schema.", "MALFORMED_REQUEST_JSON")
+	}
+
+	status := "FAILURE"
+	if request.WebhookID == s.config.WebhookID &&
+		s.checkSignature(request.TransmissionID, request.TransmissionTime, request.TransmissionSig, request.WebhookEvent) {
+		status = "SUCCESS"
+	}
+
+	return http.StatusOK, map[string]string{"verification_status": status}
+}
+
+// checkSignature verifies a transmission signature over the event as sent
+func (s *PayPalSimulator) checkSignature(transmissionID, transmissionTime, signature string, event []byte) bool {
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(signingInput(transmissionID, transmissionTime, s.config.WebhookID, event)))
+	return rsa.VerifyPKCS1v15(&s.privateKey.PublicKey, crypto.SHA256, digest[:], decoded) == nil
+}
+
+// handleAdmin serves the scripting endpoints used by out-of-process tests
+func (s *PayPalSimulator) handleAdmin(w http.ResponseWriter, r *http.Request, path string) {
+	parts := strings.Split(path, "/")
+
+	switch {
+	case r.Method == http.MethodPost && matchPath(parts, "scenarios"):
+		var request struct {
+			Reference   string        `json:"reference"`
+			Outcome     PayPalOutcome `json:"outcome"`
+			SettleAfter string        `json:"settle_after"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeSimJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if !validOutcome(request.Outcome) {
+			writeSimJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown outcome %q", request.Outcome)})
+			return
+		}
+		scenario := PayPalScenario{Outcome: request.Outcome}
+		if request.SettleAfter != "" {
+			delay, err := time.ParseDuration(request.SettleAfter)
+			if err != nil {
+				writeSimJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+				return
+			}
+			scenario.SettleAfter = delay
+		}
+		s.SetScenario(request.Reference, scenario)
+		writeSimJSON(w, http.StatusOK, map[string]string{"status": "scripted"})
+
+	case r.Method == http.MethodPost && matchPath(parts, "orders", "*", "approve"):
+		if err := s.ApproveOrder(parts[1]); err != nil {
+			writeSimJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		writeSimJSON(w, http.StatusOK, map[string]string{"status": "approved"})
+
+	case r.Method == http.MethodPost && matchPath(parts, "captures", "*", "settle"):
+		outcome := PayPalOutcome(r.URL.Query().Get("outcome"))
+		if outcome == "" {
+			outcome = PayPalOutcomeApprove
+		}
+		if err := s.SettleCapture(parts[1], outcome); err != nil {
+			writeSimJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+			return
+		}
+		writeSimJSON(w, http.StatusOK, map[string]string{"status": "settled"})
+
+	case r.Method == http.MethodGet && matchPath(parts, "webhooks"):
+		s.Flush()
+		writeSimJSON(w, http.StatusOK, s.Webhooks())
+
+	case r.Method == http.MethodPost && matchPath(parts, "tokens", "expire"):
+		s.ExpireTokens()
+		writeSimJSON(w, http.StatusOK, map[string]string{"status": "expired"})
+
+	case r.Method == http.MethodPost && matchPath(parts, "reset"):
+		s.Reset()
+		writeSimJSON(w, http.StatusOK, map[string]string{"status": "reset"})
+
+	default:
+		writeSimJSON(w, http.StatusNotFound, map[string]string{"error": "unknown simulator endpoint"})
+	}
+}
+
+// approveLocked moves an order to APPROVED; callers hold the mutex
+func (s *PayPalSimulator) approveLocked(order *simOrder) error {
+	switch order.Status {
+	case "APPROVED":
+		return nil
+	case "PAYER_ACTION_REQUIRED", "CREATED":
+	default:
+		return fmt.Errorf("order %s cannot be approved in status %s", order.ID, order.Status)
+	}
+
+	order.Status = "APPROVED"
+	order.PayerID = "PAYER" + strings.TrimPrefix(order.ID, "ORDER")
+	order.UpdateTime = time.Now().UTC()
+
+	s.emitLocked("CHECKOUT.ORDER.APPROVED", "checkout-order", order.ID, s.renderOrder(order))
+	return nil
+}
+
+// settleLocked resolves a pending capture; callers hold the mutex
+func (s *PayPalSimulator) settleLocked(captureID string, outcome PayPalOutcome) error {
+	capture, exists := s.captures[captureID]
+	if !exists {
+		return fmt.Errorf("capture %s not found", captureID)
+	}
+	if capture.Status != "PENDING" {
+		return fmt.Errorf("capture %s is not pending", captureID)
+	}
+
+	eventType := "PAYMENT.CAPTURE.COMPLETED"
+	capture.Status = "COMPLETED"
+	if outcome == PayPalOutcomeDeny {
+		capture.Status = "DECLINED"
+		eventType = "PAYMENT.CAPTURE.DENIED"
+	}
+	capture.StatusReason = ""
+	capture.UpdateTime = time.Now().UTC()
+
+	s.emitLocked(eventType, "capture", capture.ID, s.renderCapture(capture))
+	return nil
+}
+
+// scenarioFor finds the scripted scenario for an order; callers hold the mutex
+func (s *PayPalSimulator) scenarioFor(order *simOrder) PayPalScenario {
+	for _, reference := range []string{order.CustomID, order.ReferenceID} {
+		if reference == "" {
+			continue
+		}
+		if scenario, exists := s.scenarios[reference]; exists {
+			return scenario
+		}
+	}
+	if scenario, exists := s.scenarios[""]; exists {
+		return scenario
+	}
+	return PayPalScenario{Outcome: PayPalOutcomeApprove}
+}
+
+// emitLocked records a webhook and delivers it asynchronously; callers hold the mutex
+func (s *PayPalSimulator) emitLocked(eventType, resourceType, resourceID string, resource interface{}) {
+	now := time.Now().UTC()
+	event := map[string]interface{}{
+		"id":            s.nextID("WH"),
+		"event_version": "1.0",
+		"create_time":   now.Format(time.RFC3339),
+		"resource_type": resourceType,
+		"event_type":    eventType,
+		"summary":       fmt.Sprintf("%s for %s", eventType, resourceID),
+		"resource":      resource,
+	}
+	body, _ := json.Marshal(event)
+
+	transmissionID := s.nextID("TX")
+	transmissionTime := now.Format(time.RFC3339)
+	digest := sha256.Sum256([]byte(signingInput(transmissionID, transmissionTime, s.config.WebhookID, body)))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, s.privateKey, crypto.SHA256, digest[:])
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("PAYPAL-AUTH-ALGO", "SHA256withRSA")
+	headers.Set("PAYPAL-CERT-URL", s.base()+simulatorCertPath)
+	headers.Set("PAYPAL-TRANSMISSION-ID", transmissionID)
+	headers.Set("PAYPAL-TRANSMISSION-TIME", transmissionTime)
+	headers.Set("PAYPAL-TRANSMISSION-SIG", base64.StdEncoding.EncodeToString(signature))
+	if s.config.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(s.config.WebhookSecret))
+		mac.Write(body)
+		headers.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	delivery := &PayPalWebhookDelivery{
+		EventID:    event["id"].(string),
+		EventType:  eventType,
+		ResourceID: resourceID,
+		Event:      body,
+		Headers:    headers,
+		SentAt:     now,
+	}
+	s.webhooks = append(s.webhooks, delivery)
+
+	if s.config.WebhookURL == "" {
+		return
+	}
+
+	s.deliveries.Add(1)
+	go s.deliver(delivery, body, headers)
+}
+
+// deliver posts a webhook to the configured receiver
+func (s *PayPalSimulator) deliver(delivery *PayPalWebhookDelivery, body []byte, headers http.Header) {
+	defer s.deliveries.Done()
+
+	statusCode := 0
+	errorMessage := ""
+
+	req, err := http.NewRequest(http.MethodPost, s.config.WebhookURL, bytes.NewReader(body))
+	if err == nil {
+		req.Header = headers.Clone()
+		var resp *http.Response
+		if resp, err = s.httpClient.Do(req); err == nil {
+			statusCode = resp.StatusCode
+			resp.Body.Close()
+		}
+	}
+	if err != nil {
+		errorMessage = err.Error()
+		s.logger.Warn("PayPal simulator webhook delivery failed", map[string]interface{}{
+			"event_id":   delivery.EventID,
+			"event_type": delivery.EventType,
+			"error":      errorMessage,
+		})
+	}
+
+	s.mutex.Lock()
+	delivery.StatusCode = statusCode
+	delivery.Error = errorMessage
+	s.mutex.Unlock()
+}
+
+// renderOrder renders an order as Orders v2 JSON; callers hold the mutex
+func (s *PayPalSimulator) renderOrder(order *simOrder) map[string]interface{} {
+	base := s.base()
+	unit := map[string]interface{}{
+		"reference_id": order.ReferenceID,
+		"custom_id":    order.CustomID,
+		"invoice_id":   order.InvoiceID,
+		"description":  order.Description,
+		"amount":       order.Amount,
+	}
+	if len(order.CaptureIDs) > 0 {
+		captures := make([]interface{}, 0, len(order.CaptureIDs))
+		for _, captureID := range order.CaptureIDs {
+			captures = append(captures, s.renderCapture(s.captures[captureID]))
+		}
+		unit["payments"] = map[string]interface{}{"captures": captures}
+	}
+
+	links := []map[string]string{
+		{"href": base + "/v2/checkout/orders/" + order.ID, "rel": "self", "method": "GET"},
+	}
+	switch order.Status {
+	case "PAYER_ACTION_REQUIRED", "CREATED":
+		links = append(links, map[string]string{"href": base + "/checkoutnow?token=" + order.ID, "rel": "payer-action", "method": "GET"})
+	case "APPROVED":
+		links = append(links, map[string]string{"href": base + "/v2/checkout/orders/" + order.ID + "/capture", "rel": "capture", "method": "POST"})
+	}
+
+	rendered := map[string]interface{}{
+		"id":             order.ID,
+		"status":         order.Status,
+		"intent":         order.Intent,
+		"purchase_units": []interface{}{unit},
+		"create_time":    order.CreateTime.Format(time.RFC3339),
+		"update_time":    order.UpdateTime.Format(time.RFC3339),
+		"links":          links,
+	}
+	if order.PayerID != "" {
+		rendered["payer"] = map[string]interface{}{
+			"payer_id":      order.PayerID,
+			"email_address": "buyer@simulator.paypal.test",
+			"name":          map[string]string{"given_name": "Sim", "surname": "Buyer"},
+			"address":       map[string]string{"country_code": "PL"},
+		}
+	}
+	return rendered
+}
+
+// renderCapture renders a capture as Payments v2 JSON; callers hold the mutex
+func (s *PayPalSimulator) renderCapture(capture *simCapture) map[string]interface{} {
+	base := s.base()
+	rendered := map[string]interface{}{
+		"id":            capture.ID,
+		"status":        capture.Status,
+		"amount":        capture.Amount,
+		"custom_id":     capture.CustomID,
+		"invoice_id":    capture.InvoiceID,
+		"final_capture": true,
+		"create_time":   capture.CreateTime.Format(time.RFC3339),
+		"update_time":   capture.UpdateTime.Format(time.RFC3339),
+		"supplementary_data": map[string]interface{}{
+			"related_ids": map[string]string{"order_id": capture.OrderID},
+		},
+		"links": []map[string]string{
+			{"href": base + "/v2/payments/captures/" + capture.ID, "rel": "self", "method": "GET"},
+			{"href": base + "/v2/payments/captures/" + capture.ID + "/refund", "rel": "refund", "method": "POST"},
+			{"href": base + "/v2/checkout/orders/" + capture.OrderID, "rel": "up", "method": "GET"},
+		},
+	}
+	if capture.StatusReason != "" {
+		rendered["status_details"] = map[string]string{"reason": capture.StatusReason}
+	}
+	return rendered
+}
+
+// authorized checks the bearer token of an API request
+func (s *PayPalSimulator) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expiry, exists := s.tokens[token]
+	return exists && time.Now().Before(expiry)
+}
+
+// rememberBase records the public URL requests arrive on
+func (s *PayPalSimulator) rememberBase(r *http.Request) {
+	if s.config.BaseURL != "" || r.Host == "" {
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	s.mutex.Lock()
+	s.lastBase = scheme + "://" + r.Host
+	s.mutex.Unlock()
+}
+
+// base returns the public URL used in links; callers hold the mutex
+func (s *PayPalSimulator) base() string {
+	if s.config.BaseURL != "" {
+		return s.config.BaseURL
+	}
+	return s.lastBase
+}
+
+// nextID generates a deterministic, sequential identifier; callers hold the mutex
+func (s *PayPalSimulator) nextID(prefix string) string {
+	s.sequence++
+	return fmt.Sprintf("%s%010d", prefix, s.sequence)
+}
+
+// debugID generates a PayPal-style debug ID
+func (s *PayPalSimulator) debugID() string {
+	b := make([]byte, 7)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// newError builds a PayPal-shaped error body
+func (s *PayPalSimulator) newError(name, message, issue string) simError {
+	err := simError{Name: name, Message: message, DebugID: s.debugID()}
+	if issue != "" {
+		err.Details = []simErrorDetail{{Issue: issue, Description: message}}
+	}
+	return err
+}
+
+// signingInput builds the string PayPal signs for webhook transmissions
+func signingInput(transmissionID, transmissionTime, webhookID string, body []byte) string {
+	return fmt.Sprintf("%s|%s|%s|%d", transmissionID, transmissionTime, webhookID, crc32.ChecksumIEEE(body))
+}
+
+// newSigningCertificate creates the self-signed certificate served at the cert URL
+func newSigningCertificate() (*rsa.PrivateKey, []byte, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "messageverificationcerts.simulator.paypal.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return privateKey, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// validOutcome checks if an outcome is known
+func validOutcome(outcome PayPalOutcome) bool {
+	switch outcome {
+	case PayPalOutcomeApprove, PayPalOutcomeDeny, PayPalOutcomeCancel, PayPalOutcomeDelay:
+		return true
+	}
+	return false
+}
+
+// matchPath matches path segments against a pattern where "*" matches any segment
+func matchPath(parts []string, pattern ...string) bool {
+	if len(parts) != len(pattern) {
+		return false
+	}
+	for i, segment := range pattern {
+		if segment != "*" && segment != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// appendQuery adds query parameters to a URL, returning "" for an empty URL
+func appendQuery(rawURL string, values url.Values) string {
+	if rawURL == "" {
+		return ""
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	query := parsed.Query()
+	for key, value := range values {
+		query[key] = value
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// parseCents parses a decimal amount into minor units
+func parseCents(value string) (int64, bool) {
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int64(math.Round(amount * 100)), true
+}
+
+// formatCents renders minor units as a decimal amount
+func formatCents(cents int64) string {
+	return strconv.FormatFloat(float64(cents)/100, 'f', 2, 64)
+}
+
+// writeSimJSON writes a JSON response
+func writeSimJSON(w http.ResponseWriter, status int, body interface{}) {
+	encoded, _ := json.Marshal(body)
+	writeSimRaw(w, status, encoded)
+}
+
+// writeSimRaw writes an encoded JSON response
+func writeSimRaw(w http.ResponseWriter, status int, body []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
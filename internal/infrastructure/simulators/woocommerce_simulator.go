@@ -0,0 +1,1180 @@
+package simulators
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"paypal-proxy/internal/domain/interfaces"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// wooCommerceDateFormat is the site-local timestamp format of the REST API
+const wooCommerceDateFormat = "2006-01-02T15:04:05"
+
+// wooCommerceStatuses lists the order statuses the REST API accepts
+var wooCommerceStatuses = []string{
+	"pending", "processing", "on-hold", "completed", "cancelled", "refunded", "failed", "checkout-draft",
+}
+
+// WooCommerceSimulatorConfig holds configuration for one simulated store
+type WooCommerceSimulatorConfig struct {
+	Name           string        // store label used in logs
+	ConsumerKey    string        // expected consumer key; empty accepts any
+	ConsumerSecret string        // expected consumer secret; empty accepts any
+	FirstOrderID   int           // ID assigned to the first created order
+	Latency        time.Duration // delay added to every REST response
+}
+
+// WooCommerceFault injects an error response into upcoming REST requests
+type WooCommerceFault struct {
+	Method string `json:"method"` // HTTP method to fail; empty matches any
+	Status int    `json:"status"` // response status code
+	Count  int    `json:"count"`  // number of requests to fail
+}
+
+// WooCommerceStatusChange records an order status transition
+type WooCommerceStatusChange struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+// WooCommerceSimulator is an in-process stand-in for one WooCommerce store's
+// /wp-json/wc/v3/orders REST API, with /simulator admin endpoints for latency,
+// fault injection and inspection from out-of-process tests.
+type WooCommerceSimulator struct {
+	config WooCommerceSimulatorConfig
+	logger interfaces.Logger
+
+	mutex      sync.Mutex
+	nextID     int
+	nextItemID int
+	nextNoteID int
+	latency    time.Duration
+	faults     []*WooCommerceFault
+	requests   int
+	orders     map[int]*wcSimOrder
+	history    map[int][]WooCommerceStatusChange
+	notes      map[int][]wcSimNote
+	refunds    map[int][]wcSimRefund
+}
+
+type wcSimAddress struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Company   string `json:"company"`
+	Address1  string `json:"address_1"`
+	Address2  string `json:"address_2"`
+	City      string `json:"city"`
+	State     string `json:"state"`
+	Postcode  string `json:"postcode"`
+	Country   string `json:"country"`
+	Email     string `json:"email,omitempty"`
+	Phone     string `json:"phone"`
+}
+
+type wcSimMeta struct {
+	ID    int         `json:"id"`
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+type wcSimLineItem struct {
+	ID          int         `json:"id"`
+	Name        string      `json:"name"`
+	ProductID   int         `json:"product_id"`
+	VariationID int         `json:"variation_id"`
+	Quantity    int         `json:"quantity"`
+	TaxClass    string      `json:"tax_class"`
+	Subtotal    string      `json:"subtotal"`
+	SubtotalTax string      `json:"subtotal_tax"`
+	Total       string      `json:"total"`
+	TotalTax    string      `json:"total_tax"`
+	SKU         string      `json:"sku"`
+	Price       string      `json:"price"`
+	MetaData    []wcSimMeta `json:"meta_data"`
+}
+
+type wcSimLine struct {
+	ID          int         `json:"id"`
+	MethodID    string      `json:"method_id,omitempty"`
+	MethodTitle string      `json:"method_title,omitempty"`
+	Name        string      `json:"name,omitempty"`
+	Total       string      `json:"total"`
+	TotalTax    string      `json:"total_tax"`
+	MetaData    []wcSimMeta `json:"meta_data"`
+}
+
+type wcSimOrder struct {
+	ID                 int              `json:"id"`
+	ParentID           int              `json:"parent_id"`
+	Number             string           `json:"number"`
+	OrderKey           string           `json:"order_key"`
+	CreatedVia         string           `json:"created_via"`
+	Status             string           `json:"status"`
+	Currency           string           `json:"currency"`
+	DateCreated        string           `json:"date_created"`
+	DateModified       string           `json:"date_modified"`
+	DiscountTotal      string           `json:"discount_total"`
+	ShippingTotal      string           `json:"shipping_total"`
+	TotalTax           string           `json:"total_tax"`
+	Total              string           `json:"total"`
+	CustomerID         int              `json:"customer_id"`
+	CustomerNote       string           `json:"customer_note"`
+	Billing            wcSimAddress     `json:"billing"`
+	Shipping           wcSimAddress     `json:"shipping"`
+	PaymentMethod      string           `json:"payment_method"`
+	PaymentMethodTitle string           `json:"payment_method_title"`
+	TransactionID      string           `json:"transaction_id"`
+	DatePaid           *string          `json:"date_paid"`
+	DateCompleted      *string          `json:"date_completed"`
+	MetaData           []wcSimMeta      `json:"meta_data"`
+	LineItems          []wcSimLineItem  `json:"line_items"`
+	TaxLines           []wcSimLine      `json:"tax_lines"`
+	ShippingLines      []wcSimLine      `json:"shipping_lines"`
+	FeeLines           []wcSimLine      `json:"fee_lines"`
+	CouponLines        []wcSimLine      `json:"coupon_lines"`
+	Refunds            []wcSimRefundRef `json:"refunds"`
+}
+
+type wcSimNote struct {
+	ID           int    `json:"id"`
+	Author       string `json:"author"`
+	DateCreated  string `json:"date_created"`
+	Note         string `json:"note"`
+	CustomerNote bool   `json:"customer_note"`
+}
+
+type wcSimRefund struct {
+	ID              int         `json:"id"`
+	DateCreated     string      `json:"date_created"`
+	Amount          string      `json:"amount"`
+	Reason          string      `json:"reason"`
+	RefundedBy      int         `json:"refunded_by"`
+	RefundedPayment bool        `json:"refunded_payment"`
+	MetaData        []wcSimMeta `json:"meta_data"`
+}
+
+// wcSimRefundRef is the refund summary embedded in an order
+type wcSimRefundRef struct {
+	ID     int    `json:"id"`
+	Reason string `json:"reason"`
+	Total  string `json:"total"`
+}
+
+// wcSimLineInput accepts line amounts sent as strings or numbers
+type wcSimLineInput struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	ProductID   int             `json:"product_id"`
+	VariationID int             `json:"variation_id"`
+	Quantity    *int            `json:"quantity"`
+	SKU         string          `json:"sku"`
+	Subtotal    json.RawMessage `json:"subtotal"`
+	Total       json.RawMessage `json:"total"`
+	MethodID    string          `json:"method_id"`
+	MethodTitle string          `json:"method_title"`
+	MetaData    []wcSimMeta     `json:"meta_data"`
+}
+
+// wcSimError is a WordPress REST error response
+type wcSimError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// NewWooCommerceSimulator creates a new simulated WooCommerce store
+func NewWooCommerceSimulator(config WooCommerceSimulatorConfig, logger interfaces.Logger) *WooCommerceSimulator {
+	if config.FirstOrderID <= 0 {
+		config.FirstOrderID = 1000
+	}
+	if config.Name == "" {
+		config.Name = "woocommerce"
+	}
+
+	s := &WooCommerceSimulator{
+		config: config,
+		logger: logger,
+	}
+	s.Reset()
+
+	return s
+}
+
+// SetLatency changes the delay added to every REST response
+func (s *WooCommerceSimulator) SetLatency(latency time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latency = latency
+}
+
+// InjectFault fails the next matching REST requests with the given status
+func (s *WooCommerceSimulator) InjectFault(fault WooCommerceFault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if fault.Count <= 0 {
+		fault.Count = 1
+	}
+	fault.Method = strings.ToUpper(fault.Method)
+	s.faults = append(s.faults, &fault)
+}
+
+// RequestCount returns the number of REST requests received, including failed ones
+func (s *WooCommerceSimulator) RequestCount() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.requests
+}
+
+// StatusHistory returns the status transitions of an order
+func (s *WooCommerceSimulator) StatusHistory(orderID int) []WooCommerceStatusChange {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]WooCommerceStatusChange(nil), s.history[orderID]...)
+}
+
+// Notes returns the text of an order's notes, oldest first
+func (s *WooCommerceSimulator) Notes(orderID int) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	notes := make([]string, 0, len(s.notes[orderID]))
+	for _, note := range s.notes[orderID] {
+		notes = append(notes, note.Note)
+	}
+	return notes
+}
+
+// Refunds returns the amounts of an order's refunds, oldest first
+func (s *WooCommerceSimulator) Refunds(orderID int) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	amounts := make([]string, 0, len(s.refunds[orderID]))
+	for _, refund := range s.refunds[orderID] {
+		amounts = append(amounts, refund.Amount)
+	}
+	return amounts
+}
+
+// Reset discards all orders, faults and counters and restores the configured latency
+func (s *WooCommerceSimulator) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextID = s.config.FirstOrderID
+	s.nextItemID = 1
+	s.nextNoteID = 1
+	s.latency = s.config.Latency
+	s.faults = nil
+	s.requests = 0
+	s.orders = make(map[int]*wcSimOrder)
+	s.history = make(map[int][]WooCommerceStatusChange)
+	s.notes = make(map[int][]wcSimNote)
+	s.refunds = make(map[int][]wcSimRefund)
+}
+
+// ServeHTTP routes simulator requests
+func (s *WooCommerceSimulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+
+	if strings.HasPrefix(path, "simulator/") {
+		s.handleAdmin(w, r, strings.Split(strings.TrimPrefix(path, "simulator/"), "/"))
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if !matchPath(parts, "wp-json", "wc", "v3", "orders") && !matchPath(parts, "wp-json", "wc", "v3", "orders", "*") &&
+		!matchPath(parts, "wp-json", "wc", "v3", "orders", "*", "notes") &&
+		!matchPath(parts, "wp-json", "wc", "v3", "orders", "*", "refunds") {
+		writeSimJSON(w, http.StatusNotFound, s.newError("rest_no_route", "No route was found matching the URL and request method.", http.StatusNotFound))
+		return
+	}
+
+	s.mutex.Lock()
+	s.requests++
+	latency := s.latency
+	fault := s.takeFault(r.Method)
+	s.mutex.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(latency):
+		}
+	}
+
+	if fault != 0 {
+		writeSimJSON(w, fault, s.newError("simulator_injected_fault", "Injected fault.", fault))
+		return
+	}
+
+	if !s.authorized(r) {
+		writeSimJSON(w, http.StatusUnauthorized, s.newError("woocommerce_rest_authentication_error", "Consumer key is invalid.", http.StatusUnauthorized))
+		return
+	}
+
+	if len(parts) == 4 {
+		switch r.Method {
+		case http.MethodGet:
+			s.listOrders(w, r)
+		case http.MethodPost:
+			s.createOrder(w, r)
+		default:
+			writeSimJSON(w, http.StatusNotFound, s.newError("rest_no_route", "No route was found matching the URL and request method.", http.StatusNotFound))
+		}
+		return
+	}
+
+	orderID, err := strconv.Atoi(parts[4])
+	if err != nil {
+		writeSimJSON(w, http.StatusNotFound, s.newError("woocommerce_rest_shop_order_invalid_id", "Invalid ID.", http.StatusNotFound))
+		return
+	}
+
+	if len(parts) == 6 {
+		switch {
+		case parts[5] == "notes" && r.Method == http.MethodGet:
+			s.listNotes(w, orderID)
+		case parts[5] == "notes" && r.Method == http.MethodPost:
+			s.createNote(w, r, orderID)
+		case parts[5] == "refunds" && r.Method == http.MethodGet:
+			s.listRefunds(w, orderID)
+		case parts[5] == "refunds" && r.Method == http.MethodPost:
+			s.createRefund(w, r, orderID)
+		default:
+			writeSimJSON(w, http.StatusNotFound, s.newError("rest_no_route", "No route was found matching the URL and request method.", http.StatusNotFound))
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.getOrder(w, orderID)
+	case http.MethodPut, http.MethodPost, http.MethodPatch:
+		s.updateOrder(w, r, orderID)
+	case http.MethodDelete:
+		s.deleteOrder(w, r, orderID)
+	default:
+		writeSimJSON(w, http.StatusNotFound, s.newError("rest_no_route", "No route was found matching the URL and request method.", http.StatusNotFound))
+	}
+}
+
+// listOrders lists orders newest first with optional status filtering and paging
+func (s *WooCommerceSimulator) listOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage <= 0 {
+		perPage = 10
+	}
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page <= 0 {
+		page = 1
+	}
+	statuses := map[string]bool{}
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status != "" && status != "any" {
+			statuses[status] = true
+		}
+	}
+
+	s.mutex.Lock()
+	var matched []wcSimOrder
+	for _, order := range s.orders {
+		if len(statuses) == 0 || statuses[order.Status] {
+			matched = append(matched, *order)
+		}
+	}
+	s.mutex.Unlock()
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+
+	total := len(matched)
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+
+	w.Header().Set("X-WP-Total", strconv.Itoa(total))
+	w.Header().Set("X-WP-TotalPages", strconv.Itoa((total+perPage-1)/perPage))
+	writeSimJSON(w, http.StatusOK, append([]wcSimOrder{}, matched[start:end]...))
+}
+
+// getOrder returns a single order
+func (s *WooCommerceSimulator) getOrder(w http.ResponseWriter, orderID int) {
+	s.mutex.Lock()
+	order, exists := s.orders[orderID]
+	var result wcSimOrder
+	if exists {
+		result = *order
+	}
+	s.mutex.Unlock()
+
+	if !exists {
+		writeSimJSON(w, http.StatusNotFound, s.newError("woocommerce_rest_shop_order_invalid_id", "Invalid ID.", http.StatusNotFound))
+		return
+	}
+	writeSimJSON(w, http.StatusOK, result)
+}
+
+// createOrder creates an order the way POST /orders does
+func (s *WooCommerceSimulator) createOrder(w http.ResponseWriter, r *http.Request) {
+	fields, err := decodeFields(r)
+	if err != nil {
+		writeSimJSON(w, http.StatusBadRequest, s.newError("rest_invalid_json", "Invalid JSON body passed.", http.StatusBadRequest))
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().Format(wooCommerceDateFormat)
+	order := &wcSimOrder{
+		ID:            s.nextID,
+		Number:        strconv.Itoa(s.nextID),
+		OrderKey:      "wc_order_" + randomToken(),
+		CreatedVia:    "rest-api",
+		Status:        "pending",
+		Currency:      "USD",
+		DateCreated:   now,
+		DateModified:  now,
+		DiscountTotal: "0.00",
+		ShippingTotal: "0.00",
+		TotalTax:      "0.00",
+		Total:         "0.00",
+		MetaData:      []wcSimMeta{},
+		LineItems:     []wcSimLineItem{},
+		TaxLines:      []wcSimLine{},
+		ShippingLines: []wcSimLine{},
+		FeeLines:      []wcSimLine{},
+		CouponLines:   []wcSimLine{},
+		Refunds:       []wcSimRefundRef{},
+	}
+
+	if apiErr := s.applyFields(order, fields, true); apiErr != nil {
+		writeSimJSON(w, http.StatusBadRequest, apiErr)
+		return
+	}
+
+	s.nextID++
+	s.orders[order.ID] = order
+	s.history[order.ID] = append(s.history[order.ID], WooCommerceStatusChange{To: order.Status, At: time.Now()})
+
+	s.logger.Info("WooCommerce simulator order created", map[string]interface{}{
+		"store":    s.config.Name,
+		"order_id": order.ID,
+		"status":   order.Status,
+		"total":    order.Total,
+	})
+
+	writeSimJSON(w, http.StatusCreated, *order)
+}
+
+// updateOrder applies a partial update the way PUT /orders/{id} does
+func (s *WooCommerceSimulator) updateOrder(w http.ResponseWriter, r *http.Request, orderID int) {
+	fields, err := decodeFields(r)
+	if err != nil {
+		writeSimJSON(w, http.StatusBadRequest, s.newError("rest_invalid_json", "Invalid JSON body passed.", http.StatusBadRequest))
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, exists := s.orders[orderID]
+	if !exists {
+		writeSimJSON(w, http.StatusNotFound, s.newError("woocommerce_rest_shop_order_invalid_id", "Invalid ID.", http.StatusNotFound))
+		return
+	}
+
+	// Apply to a copy so a rejected update leaves the order untouched
+	updated := cloneOrder(order)
+	if apiErr := s.applyFields(updated, fields, false); apiErr != nil {
+		writeSimJSON(w, http.StatusBadRequest, apiErr)
+		return
+	}
+	updated.DateModified = time.Now().Format(wooCommerceDateFormat)
+
+	if updated.Status != order.Status {
+		s.history[orderID] = append(s.history[orderID], WooCommerceStatusChange{From: order.Status, To: updated.Status, At: time.Now()})
+		s.logger.Info("WooCommerce simulator order status changed", map[string]interface{}{
+			"store":    s.config.Name,
+			"order_id": orderID,
+			"from":     order.Status,
+			"to":       updated.Status,
+		})
+	}
+	s.orders[orderID] = updated
+
+	writeSimJSON(w, http.StatusOK, *updated)
+}
+
+// deleteOrder moves an order to the trash the way DELETE /orders/{id} does, or
+// removes it for good with force=true. Trashing an order twice is rejected
+// with 410 Gone.
+func (s *WooCommerceSimulator) deleteOrder(w http.ResponseWriter, r *http.Request, orderID int) {
+	force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, exists := s.orders[orderID]
+	if !exists {
+		writeSimJSON(w, http.StatusNotFound, s.newError("woocommerce_rest_shop_order_invalid_id", "Invalid ID.", http.StatusNotFound))
+		return
+	}
+
+	if force {
+		delete(s.orders, orderID)
+		writeSimJSON(w, http.StatusOK, *order)
+		return
+	}
+
+	if order.Status == "trash" {
+		writeSimJSON(w, http.StatusGone, s.newError("woocommerce_rest_already_trashed", "The order has already been deleted.", http.StatusGone))
+		return
+	}
+
+	trashed := cloneOrder(order)
+	trashed.Status = "trash"
+	trashed.DateModified = time.Now().Format(wooCommerceDateFormat)
+	s.history[orderID] = append(s.history[orderID], WooCommerceStatusChange{From: order.Status, To: trashed.Status, At: time.Now()})
+	s.orders[orderID] = trashed
+
+	s.logger.Info("WooCommerce simulator order trashed", map[string]interface{}{
+		"store":    s.config.Name,
+		"order_id": orderID,
+	})
+
+	writeSimJSON(w, http.StatusOK, *trashed)
+}
+
+// listNotes returns an order's notes newest first, as GET /orders/{id}/notes does
+func (s *WooCommerceSimulator) listNotes(w http.ResponseWriter, orderID int) {
+	s.mutex.Lock()
+	_, exists := s.orders[orderID]
+	notes := make([]wcSimNote, 0, len(s.notes[orderID]))
+	for i := len(s.notes[orderID]) - 1; i >= 0; i-- {
+		notes = append(notes, s.notes[orderID][i])
+	}
+	s.mutex.Unlock()
+
+	if !exists {
+		writeSimJSON(w, http.StatusNotFound, s.newError("woocommerce_rest_order_invalid_id", "Invalid order ID.", http.StatusNotFound))
+		return
+	}
+	writeSimJSON(w, http.StatusOK, notes)
+}
+
+// createNote adds an order note the way POST /orders/{id}/notes does
+func (s *WooCommerceSimulator) createNote(w http.ResponseWriter, r *http.Request, orderID int) {
+	var request struct {
+		Note         string `json:"note"`
+		CustomerNote bool   `json:"customer_note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeSimJSON(w, http.StatusBadRequest, s.newError("rest_invalid_json", "Invalid JSON body passed.", http.StatusBadRequest))
+		return
+	}
+	if request.Note == "" {
+		writeSimJSON(w, http.StatusBadRequest, s.newError("rest_missing_callback_param", "Missing parameter(s): note", http.StatusBadRequest))
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.orders[orderID]; !exists {
+		writeSimJSON(w, http.StatusNotFound, s.newError("woocommerce_rest_order_invalid_id", "Invalid order ID.", http.StatusNotFound))
+		return
+	}
+
+	note := wcSimNote{
+		ID:           s.nextNoteID,
+		Author:       "WooCommerce",
+		DateCreated:  time.Now().Format(wooCommerceDateFormat),
+		Note:         request.Note,
+		CustomerNote: request.CustomerNote,
+	}
+	s.nextNoteID++
+	s.notes[orderID] = append(s.notes[orderID], note)
+
+	writeSimJSON(w, http.StatusCreated, note)
+}
+
+// listRefunds returns an order's refunds newest first, as GET /orders/{id}/refunds does
+func (s *WooCommerceSimulator) listRefunds(w http.ResponseWriter, orderID int) {
+	s.mutex.Lock()
+	_, exists := s.orders[orderID]
+	refunds := make([]wcSimRefund, 0, len(s.refunds[orderID]))
+	for i := len(s.refunds[orderID]) - 1; i >= 0; i-- {
+		refunds = append(refunds, s.refunds[orderID][i])
+	}
+	s.mutex.Unlock()
+
+	if !exists {
+		writeSimJSON(w, http.StatusNotFound, s.newError("woocommerce_rest_order_invalid_id", "Invalid order ID.", http.StatusNotFound))
+		return
+	}
+	writeSimJSON(w, http.StatusOK, refunds)
+}
+
+// createRefund records a refund the way POST /orders/{id}/refunds does. Like
+// WooCommerce, api_refund defaults to true, and the simulated store has no
+// gateway able to refund automatically, so callers must send api_refund false.
+// An order refunded in full moves to refunded.
+func (s *WooCommerceSimulator) createRefund(w http.ResponseWriter, r *http.Request, orderID int) {
+	fields, err := decodeFields(r)
+	if err != nil {
+		writeSimJSON(w, http.StatusBadRequest, s.newError("rest_invalid_json", "Invalid JSON body passed.", http.StatusBadRequest))
+		return
+	}
+
+	var cents int64
+	if raw, exists := fields["amount"]; exists {
+		var ok bool
+		if cents, ok = parseFlexibleCents(raw); !ok {
+			apiErr := s.newError("rest_invalid_param", "Invalid parameter(s): amount", http.StatusBadRequest)
+			apiErr.Data["params"] = map[string]string{"amount": "amount is invalid."}
+			writeSimJSON(w, http.StatusBadRequest, apiErr)
+			return
+		}
+	}
+	var reason string
+	if raw, exists := fields["reason"]; exists {
+		json.Unmarshal(raw, &reason)
+	}
+	apiRefund := true
+	if raw, exists := fields["api_refund"]; exists {
+		json.Unmarshal(raw, &apiRefund)
+	}
+
+	if cents <= 0 {
+		writeSimJSON(w, http.StatusBadRequest, s.newError("woocommerce_rest_invalid_order_refund", "Refund amount must be greater than zero.", http.StatusBadRequest))
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	order, exists := s.orders[orderID]
+	if !exists {
+		writeSimJSON(w, http.StatusNotFound, s.newError("woocommerce_rest_order_invalid_id", "Invalid order ID.", http.StatusNotFound))
+		return
+	}
+
+	total, _ := parseCents(order.Total)
+	var refunded int64
+	for _, existing := range s.refunds[orderID] {
+		amount, _ := parseCents(existing.Amount)
+		refunded += amount
+	}
+	if cents > total-refunded {
+		writeSimJSON(w, http.StatusInternalServerError, s.newError("woocommerce_rest_cannot_create_order_refund", "Invalid refund amount.", http.StatusInternalServerError))
+		return
+	}
+	if apiRefund {
+		writeSimJSON(w, http.StatusInternalServerError, s.newError("woocommerce_rest_cannot_create_order_refund", "The payment gateway for this order does not support automatic refunds.", http.StatusInternalServerError))
+		return
+	}
+
+	refund := wcSimRefund{
+		ID:          s.nextID,
+		DateCreated: time.Now().Format(wooCommerceDateFormat),
+		Amount:      formatCents(cents),
+		Reason:      reason,
+		MetaData:    []wcSimMeta{},
+	}
+	// Refunds share the order ID sequence, as they are posts of their own
+	s.nextID++
+	s.refunds[orderID] = append(s.refunds[orderID], refund)
+
+	updated := cloneOrder(order)
+	updated.Refunds = append([]wcSimRefundRef{{ID: refund.ID, Reason: reason, Total: "-" + refund.Amount}}, updated.Refunds...)
+	updated.DateModified = refund.DateCreated
+	if refunded+cents == total && updated.Status != "refunded" {
+		s.history[orderID] = append(s.history[orderID], WooCommerceStatusChange{From: order.Status, To: "refunded", At: time.Now()})
+		updated.Status = "refunded"
+	}
+	s.orders[orderID] = updated
+
+	s.logger.Info("WooCommerce simulator order refunded", map[string]interface{}{
+		"store":     s.config.Name,
+		"order_id":  orderID,
+		"refund_id": refund.ID,
+		"amount":    refund.Amount,
+		"status":    updated.Status,
+	})
+
+	writeSimJSON(w, http.StatusCreated, refund)
+}
+
+// applyFields applies writable request fields to an order; callers hold the mutex
+func (s *WooCommerceSimulator) applyFields(order *wcSimOrder, fields map[string]json.RawMessage, creating bool) *wcSimError {
+	invalid := func(param string) *wcSimError {
+		apiErr := s.newError("rest_invalid_param", "Invalid parameter(s): "+param, http.StatusBadRequest)
+		apiErr.Data["params"] = map[string]string{param: param + " is invalid."}
+		return apiErr
+	}
+
+	stringFields := map[string]*string{
+		"currency":             &order.Currency,
+		"customer_note":        &order.CustomerNote,
+		"payment_method":       &order.PaymentMethod,
+		"payment_method_title": &order.PaymentMethodTitle,
+		"transaction_id":       &order.TransactionID,
+	}
+	for key, target := range stringFields {
+		if raw, exists := fields[key]; exists {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return invalid(key)
+			}
+		}
+	}
+	order.Currency = strings.ToUpper(order.Currency)
+
+	for key, target := range map[string]*wcSimAddress{"billing": &order.Billing, "shipping": &order.Shipping} {
+		if raw, exists := fields[key]; exists {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return invalid(key)
+			}
+		}
+	}
+
+	if raw, exists := fields["customer_id"]; exists {
+		if err := json.Unmarshal(raw, &order.CustomerID); err != nil {
+			return invalid("customer_id")
+		}
+	}
+
+	if raw, exists := fields["meta_data"]; exists {
+		var meta []wcSimMeta
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			return invalid("meta_data")
+		}
+		order.MetaData = s.mergeMeta(order.MetaData, meta)
+	}
+
+	linesChanged := false
+	if raw, exists := fields["line_items"]; exists {
+		var inputs []wcSimLineInput
+		if err := json.Unmarshal(raw, &inputs); err != nil {
+			return invalid("line_items")
+		}
+		items, ok := s.mergeLineItems(order.LineItems, inputs)
+		if !ok {
+			return invalid("line_items")
+		}
+		order.LineItems = items
+		linesChanged = true
+	}
+	for key, target := range map[string]*[]wcSimLine{"shipping_lines": &order.ShippingLines, "fee_lines": &order.FeeLines} {
+		if raw, exists := fields[key]; exists {
+			var inputs []wcSimLineInput
+			if err := json.Unmarshal(raw, &inputs); err != nil {
+				return invalid(key)
+			}
+			lines, ok := s.mergeLines(*target, inputs)
+			if !ok {
+				return invalid(key)
+			}
+			*target = lines
+			linesChanged = true
+		}
+	}
+
+	// Totals follow the lines unless the request sets one explicitly
+	if raw, exists := fields["total"]; exists && creating {
+		cents, ok := parseFlexibleCents(raw)
+		if !ok {
+			return invalid("total")
+		}
+		order.Total = formatCents(cents)
+	} else if linesChanged {
+		order.Total = formatCents(s.linesTotal(order))
+	}
+	order.ShippingTotal = formatCents(sumLines(order.ShippingLines))
+
+	status := order.Status
+	if raw, exists := fields["status"]; exists {
+		if err := json.Unmarshal(raw, &status); err != nil || !validWooCommerceStatus(status) {
+			apiErr := s.newError("rest_invalid_param", "Invalid parameter(s): status", http.StatusBadRequest)
+			apiErr.Data["params"] = map[string]string{
+				"status": "status is not one of " + strings.Join(wooCommerceStatuses, ", ") + ".",
+			}
+			return apiErr
+		}
+	}
+
+	var setPaid bool
+	if raw, exists := fields["set_paid"]; exists {
+		if err := json.Unmarshal(raw, &setPaid); err != nil {
+			return invalid("set_paid")
+		}
+	}
+
+	now := time.Now().Format(wooCommerceDateFormat)
+	if raw, exists := fields["date_paid"]; exists {
+		var datePaid string
+		if err := json.Unmarshal(raw, &datePaid); err != nil {
+			return invalid("date_paid")
+		}
+		order.DatePaid = &datePaid
+	}
+	if setPaid {
+		if order.DatePaid == nil {
+			order.DatePaid = &now
+		}
+		if status == "pending" || status == "on-hold" || status == "failed" {
+			status = "processing"
+		}
+	}
+
+	// Paid statuses stamp payment and completion dates as WooCommerce does
+	if (status == "processing" || status == "completed") && order.DatePaid == nil {
+		order.DatePaid = &now
+	}
+	if status == "completed" && order.DateCompleted == nil {
+		order.DateCompleted = &now
+	}
+	order.Status = status
+
+	return nil
+}
+
+// mergeMeta updates meta entries by ID or key and appends new ones; callers hold the mutex
+func (s *WooCommerceSimulator) mergeMeta(existing []wcSimMeta, updates []wcSimMeta) []wcSimMeta {
+	merged := append([]wcSimMeta{}, existing...)
+
+	for _, update := range updates {
+		replaced := false
+		for i := range merged {
+			if (update.ID != 0 && merged[i].ID == update.ID) || (update.ID == 0 && merged[i].Key == update.Key) {
+				merged[i].Value = update.Value
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			update.ID = s.nextItemID
+			s.nextItemID++
+			merged = append(merged, update)
+		}
+	}
+
+	return merged
+}
+
+// mergeLineItems updates items by ID, removes items set to zero quantity and
+// appends items without an ID; callers hold the mutex
+func (s *WooCommerceSimulator) mergeLineItems(existing []wcSimLineItem, inputs []wcSimLineInput) ([]wcSimLineItem, bool) {
+	items := append([]wcSimLineItem{}, existing...)
+
+	for _, input := range inputs {
+		index := -1
+		if input.ID != 0 {
+			for i := range items {
+				if items[i].ID == input.ID {
+					index = i
+					break
+				}
+			}
+			if index < 0 {
+				return nil, false
+			}
+		}
+
+		if index >= 0 && input.Quantity != nil && *input.Quantity == 0 {
+			items = append(items[:index], items[index+1:]...)
+			continue
+		}
+
+		item := wcSimLineItem{ID: s.nextItemID, Quantity: 1, SubtotalTax: "0.00", TotalTax: "0.00", MetaData: []wcSimMeta{}}
+		if index >= 0 {
+			item = items[index]
+		}
+
+		if input.Name != "" {
+			item.Name = input.Name
+		}
+		if input.ProductID != 0 {
+			item.ProductID = input.ProductID
+		}
+		if input.VariationID != 0 {
+			item.VariationID = input.VariationID
+		}
+		if input.SKU != "" {
+			item.SKU = input.SKU
+		}
+		if input.Quantity != nil {
+			if *input.Quantity < 0 {
+				return nil, false
+			}
+			item.Quantity = *input.Quantity
+		}
+		if len(input.Total) > 0 {
+			cents, ok := parseFlexibleCents(input.Total)
+			if !ok {
+				return nil, false
+			}
+			item.Total = formatCents(cents)
+		}
+		if item.Total == "" {
+			item.Total = "0.00"
+		}
+		if len(input.Subtotal) > 0 {
+			cents, ok := parseFlexibleCents(input.Subtotal)
+			if !ok {
+				return nil, false
+			}
+			item.Subtotal = formatCents(cents)
+		} else if index < 0 {
+			item.Subtotal = item.Total
+		}
+		if input.MetaData != nil {
+			item.MetaData = s.mergeMeta(item.MetaData, input.MetaData)
+		}
+
+		totalCents, _ := parseCents(item.Total)
+		item.Price = "0.00"
+		if item.Quantity > 0 {
+			item.Price = formatCents(totalCents / int64(item.Quantity))
+		}
+
+		if index >= 0 {
+			items[index] = item
+		} else {
+			s.nextItemID++
+			items = append(items, item)
+		}
+	}
+
+	return items, true
+}
+
+// mergeLines updates shipping or fee lines by ID and appends new ones; callers hold the mutex
+func (s *WooCommerceSimulator) mergeLines(existing []wcSimLine, inputs []wcSimLineInput) ([]wcSimLine, bool) {
+	lines := append([]wcSimLine{}, existing...)
+
+	for _, input := range inputs {
+		line := wcSimLine{ID: s.nextItemID, Total: "0.00", TotalTax: "0.00", MetaData: []wcSimMeta{}}
+		index := -1
+		if input.ID != 0 {
+			for i := range lines {
+				if lines[i].ID == input.ID {
+					index = i
+					line = lines[i]
+					break
+				}
+			}
+			if index < 0 {
+				return nil, false
+			}
+		}
+
+		if input.MethodID != "" {
+			line.MethodID = input.MethodID
+		}
+		if input.MethodTitle != "" {
+			line.MethodTitle = input.MethodTitle
+		}
+		if input.Name != "" {
+			line.Name = input.Name
+		}
+		if len(input.Total) > 0 {
+			cents, ok := parseFlexibleCents(input.Total)
+			if !ok {
+				return nil, false
+			}
+			line.Total = formatCents(cents)
+		}
+
+		if index >= 0 {
+			lines[index] = line
+		} else {
+			s.nextItemID++
+			lines = append(lines, line)
+		}
+	}
+
+	return lines, true
+}
+
+// linesTotal recalculates an order total from its lines
+func (s *WooCommerceSimulator) linesTotal(order *wcSimOrder) int64 {
+	var total int64
+	for _, item := range order.LineItems {
+		cents, _ := parseCents(item.Total)
+		total += cents
+	}
+	return total + sumLines(order.ShippingLines) + sumLines(order.FeeLines)
+}
+
+// handleAdmin serves the scripting endpoints used by out-of-process tests
+func (s *WooCommerceSimulator) handleAdmin(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case r.Method == http.MethodPost && matchPath(parts, "latency"):
+		var request struct {
+			Latency string `json:"latency"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeSimJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		latency, err := time.ParseDuration(request.Latency)
+		if err != nil {
+			writeSimJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		s.SetLatency(latency)
+		writeSimJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+
+	case r.Method == http.MethodPost && matchPath(parts, "faults"):
+		var fault WooCommerceFault
+		if err := json.NewDecoder(r.Body).Decode(&fault); err != nil || fault.Status < 400 {
+			writeSimJSON(w, http.StatusBadRequest, map[string]string{"error": "fault needs an error status"})
+			return
+		}
+		s.InjectFault(fault)
+		writeSimJSON(w, http.StatusOK, map[string]string{"status": "injected"})
+
+	case r.Method == http.MethodGet && matchPath(parts, "orders", "*", "history"):
+		orderID, err := strconv.Atoi(parts[1])
+		if err != nil {
+			writeSimJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid order ID"})
+			return
+		}
+		writeSimJSON(w, http.StatusOK, s.StatusHistory(orderID))
+
+	case r.Method == http.MethodGet && matchPath(parts, "stats"):
+		writeSimJSON(w, http.StatusOK, map[string]int{"requests": s.RequestCount()})
+
+	case r.Method == http.MethodPost && matchPath(parts, "reset"):
+		s.Reset()
+		writeSimJSON(w, http.StatusOK, map[string]string{"status": "reset"})
+
+	default:
+		writeSimJSON(w, http.StatusNotFound, map[string]string{"error": "unknown simulator endpoint"})
+	}
+}
+
+// takeFault consumes a matching injected fault; callers hold the mutex
+func (s *WooCommerceSimulator) takeFault(method string) int {
+	for i, fault := range s.faults {
+		if fault.Method != "" && fault.Method != method {
+			continue
+		}
+		fault.Count--
+		if fault.Count <= 0 {
+			s.faults = append(s.faults[:i], s.faults[i+1:]...)
+		}
+		return fault.Status
+	}
+	return 0
+}
+
+// authorized checks Basic auth or consumer_key/consumer_secret query parameters
+func (s *WooCommerceSimulator) authorized(r *http.Request) bool {
+	key, secret, ok := r.BasicAuth()
+	if !ok {
+		key = r.URL.Query().Get("consumer_key")
+		secret = r.URL.Query().Get("consumer_secret")
+		ok = key != ""
+	}
+	if !ok {
+		return false
+	}
+
+	return (s.config.ConsumerKey == "" || key == s.config.ConsumerKey) &&
+		(s.config.ConsumerSecret == "" || secret == s.config.ConsumerSecret)
+}
+
+// newError builds a WordPress REST error body
+func (s *WooCommerceSimulator) newError(code, message string, status int) *wcSimError {
+	return &wcSimError{
+		Code:    code,
+		Message: message,
+		Data:    map[string]interface{}{"status": status},
+	}
+}
+
+// decodeFields decodes a JSON object body into raw fields
+func decodeFields(r *http.Request) (map[string]json.RawMessage, error) {
+	buffer := new(bytes.Buffer)
+	if _, err := buffer.ReadFrom(r.Body); err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if buffer.Len() == 0 {
+		return fields, nil
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// cloneOrder deep-copies an order's slices
+func cloneOrder(order *wcSimOrder) *wcSimOrder {
+	clone := *order
+	clone.MetaData = append([]wcSimMeta{}, order.MetaData...)
+	clone.LineItems = append([]wcSimLineItem{}, order.LineItems...)
+	clone.ShippingLines = append([]wcSimLine{}, order.ShippingLines...)
+	clone.FeeLines = append([]wcSimLine{}, order.FeeLines...)
+	clone.Refunds = append([]wcSimRefundRef{}, order.Refunds...)
+	return &clone
+}
+
+// sumLines totals shipping or fee lines in minor units
+func sumLines(lines []wcSimLine) int64 {
+	var total int64
+	for _, line := range lines {
+		cents, _ := parseCents(line.Total)
+		total += cents
+	}
+	return total
+}
+
+// parseFlexibleCents parses an amount sent as a JSON string or number
+func parseFlexibleCents(raw json.RawMessage) (int64, bool) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return parseCents(text)
+	}
+	var number float64
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return parseCents(strconv.FormatFloat(number, 'f', -1, 64))
+	}
+	return 0, false
+}
+
+// validWooCommerceStatus checks if a status is accepted by the REST API
+func validWooCommerceStatus(status string) bool {
+	for _, valid := range wooCommerceStatuses {
+		if status == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// randomToken generates a random order key suffix
+func randomToken() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/services"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler handles operational requests such as webhook replay
+type AdminHandler struct {
+	orchestrator *services.PaymentOrchestrator
+	logger       interfaces.Logger
+	config       interfaces.ConfigService
+}
+
+// NewAdminHandler creates a new admin handler
+func NewAdminHandler(orchestrator *services.PaymentOrchestrator, logger interfaces.Logger, config interfaces.ConfigService) *AdminHandler {
+	return &AdminHandler{
+		orchestrator: orchestrator,
+		logger:       logger,
+		config:       config,
+	}
+}
+
+// ReplayWebhookEvent processes a stored webhook event again
+func (h *AdminHandler) ReplayWebhookEvent(c *gin.Context) {
+	eventID := c.Param("id")
+
+	h.logger.Info("Admin webhook replay request", map[string]interface{}{
+		"webhook_id": eventID,
+		"client_ip":  c.ClientIP(),
+	})
+
+	result, err := h.orchestrator.ReplayWebhook(c.Request.Context(), eventID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, interfaces.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondWithError(c, status, "Webhook replay failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ReplayWebhookEvents processes again the webhook events received between the
+// RFC 3339 from and to query parameters
+func (h *AdminHandler) ReplayWebhookEvents(c *gin.Context) {
+	from, err := time.Parse(time.RFC3339, c.Query("from"))
+	if err != nil {
+		h.respondWithError(c, http.StatusBadRequest, "Invalid from parameter", err)
+		return
+	}
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		if to, err = time.Parse(time.RFC3339, value); err != nil {
+			h.respondWithError(c, http.StatusBadRequest, "Invalid to parameter", err)
+			return
+		}
+	}
+	if !from.Before(to) {
+		h.respondWithError(c, http.StatusBadRequest, "Invalid replay range", nil)
+		return
+	}
+
+	h.logger.Info("Admin webhook range replay request", map[string]interface{}{
+		"from":      from,
+		"to":        to,
+		"client_ip": c.ClientIP(),
+	})
+
+	response, err := h.orchestrator.ReplayWebhooks(c.Request.Context(), from, to)
+	if err != nil {
+		h.respondWithError(c, http.StatusInternalServerError, "Webhook replay failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RequeueWebhookEvent puts a stored webhook event back on the processing queue
+func (h *AdminHandler) RequeueWebhookEvent(c *gin.Context) {
+	eventID := c.Param("id")
+
+	h.logger.Info("Admin webhook requeue request", map[string]interface{}{
+		"webhook_id": eventID,
+		"client_ip":  c.ClientIP(),
+	})
+
+	response, err := h.orchestrator.RequeueWebhook(c.Request.Context(), eventID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, interfaces.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		h.respondWithError(c, status, "Webhook requeue failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ListDeadLetterWebhooks lists webhook events that exhausted their processing attempts
+func (h *AdminHandler) ListDeadLetterWebhooks(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		h.respondWithError(c, http.StatusBadRequest, "Invalid limit parameter", nil)
+		return
+	}
+
+	events, err := h.orchestrator.DeadLetterWebhooks(c.Request.Context(), limit)
+	if err != nil {
+		h.respondWithError(c, http.StatusInternalServerError, "Failed to list dead-lettered webhooks", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"count":  len(events),
+	})
+}
+
+// ListOpenDisputes lists the PayPal disputes that are not resolved yet, the
+// most urgent response deadline first
+func (h *AdminHandler) ListOpenDisputes(c *gin.Context) {
+	disputes, err := h.orchestrator.OpenDisputes(c.Request.Context())
+	if err != nil {
+		h.respondWithError(c, http.StatusInternalServerError, "Failed to list open disputes", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"disputes": disputes,
+		"count":    len(disputes),
+	})
+}
+
+// Reconcile runs a reconciliation of active proxy orders and returns its report
+func (h *AdminHandler) Reconcile(c *gin.Context) {
+	h.logger.Info("Admin reconciliation request", map[string]interface{}{
+		"client_ip": c.ClientIP(),
+	})
+
+	report, err := h.orchestrator.Reconcile(c.Request.Context())
+	if err != nil {
+		h.respondWithError(c, http.StatusInternalServerError, "Reconciliation failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// LastReconciliation returns the report of the most recent reconciliation run
+func (h *AdminHandler) LastReconciliation(c *gin.Context) {
+	report := h.orchestrator.LastReconciliation()
+	if report == nil {
+		h.respondWithError(c, http.StatusNotFound, "No reconciliation has run yet", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// SweepProxyOrders closes abandoned proxy orders now and returns the sweep report
+func (h *AdminHandler) SweepProxyOrders(c *gin.Context) {
+	h.logger.Info("Admin proxy order sweep request", map[string]interface{}{
+		"client_ip": c.ClientIP(),
+	})
+
+	report, err := h.orchestrator.SweepProxyOrders(c.Request.Context())
+	if err != nil {
+		h.respondWithError(c, http.StatusInternalServerError, "Proxy order sweep failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// LastProxyOrderSweep returns the report of the most recent proxy order sweep
+func (h *AdminHandler) LastProxyOrderSweep(c *gin.Context) {
+	report := h.orchestrator.LastProxyOrderSweep()
+	if report == nil {
+		h.respondWithError(c, http.StatusNotFound, "No proxy order sweep has run yet", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ListStuckOutboxEntries lists MagicSpore order updates still undelivered past the stuck threshold
+func (h *AdminHandler) ListStuckOutboxEntries(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		h.respondWithError(c, http.StatusBadRequest, "Invalid limit parameter", nil)
+		return
+	}
+
+	entries, err := h.orchestrator.StuckOutboxEntries(c.Request.Context(), limit)
+	if err != nil {
+		h.respondWithError(c, http.StatusInternalServerError, "Failed to list stuck outbox entries", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// RetryOutboxEntry gives an undelivered outbox entry a fresh set of attempts
+// and returns it after an immediate delivery attempt
+func (h *AdminHandler) RetryOutboxEntry(c *gin.Context) {
+	entryID := c.Param("id")
+
+	h.logger.Info("Admin outbox retry request", map[string]interface{}{
+		"entry_id":  entryID,
+		"client_ip": c.ClientIP(),
+	})
+
+	entry, err := h.orchestrator.RetryOutboxEntry(c.Request.Context(), entryID)
+	if err != nil {
+		h.respondWithError(c, outboxErrorStatus(err), "Outbox retry failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// DiscardOutboxEntry gives up on an undelivered outbox entry, releasing the
+// later updates of its order
+func (h *AdminHandler) DiscardOutboxEntry(c *gin.Context) {
+	entryID := c.Param("id")
+
+	h.logger.Info("Admin outbox discard request", map[string]interface{}{
+		"entry_id":  entryID,
+		"client_ip": c.ClientIP(),
+	})
+
+	entry, err := h.orchestrator.DiscardOutboxEntry(c.Request.Context(), entryID)
+	if err != nil {
+		h.respondWithError(c, outboxErrorStatus(err), "Outbox discard failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+// outboxErrorStatus maps an outbox entry error to an HTTP status
+func outboxErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, usecases.ErrOutboxEntryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, usecases.ErrOutboxEntryFinished):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// RefundOrder refunds all or part of an order's payment. The optional JSON
+// body takes an amount in the order currency and a reason; without an amount
+// everything still refundable is refunded.
+func (h *AdminHandler) RefundOrder(c *gin.Context) {
+	request := dto.PaymentRefundRequest{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			h.respondWithError(c, http.StatusBadRequest, "Invalid refund request", err)
+			return
+		}
+	}
+	request.OrderID = c.Param("id")
+	request.IdempotencyKey = c.GetHeader("Idempotency-Key")
+
+	h.logger.Info("Admin refund request", map[string]interface{}{
+		"order_id":  request.OrderID,
+		"amount":    request.Amount,
+		"client_ip": c.ClientIP(),
+	})
+
+	response, err := h.orchestrator.HandlePaymentRefund(c.Request.Context(), &request)
+	if err != nil {
+		var validationErr *entities.ValidationError
+		status := http.StatusInternalServerError
+		switch {
+		case errors.As(err, &validationErr):
+			status = http.StatusBadRequest
+		case errors.Is(err, entities.ErrInvalidStatusTransition):
+			status = http.StatusConflict
+		case errors.Is(err, usecases.ErrRefundsUnavailable):
+			status = http.StatusServiceUnavailable
+		case errors.Is(err, usecases.ErrNoRefundablePayment), errors.Is(err, interfaces.ErrNotFound):
+			status = http.StatusNotFound
+		}
+		h.respondWithError(c, status, "Refund failed", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// respondWithError sends an error response. Outside development only
+// validation errors are detailed; other errors are logged, not returned.
+func (h *AdminHandler) respondWithError(c *gin.Context, statusCode int, message string, err error) {
+	errorResponse := dto.ErrorResponse{
+		Error:     message,
+		Code:      statusCode,
+		Message:   message,
+		Timestamp: time.Now().Unix(),
+	}
+
+	var validationErr *entities.ValidationError
+	switch {
+	case err == nil:
+	case h.config.GetServerConfig().GetEnvironment() == "development", errors.As(err, &validationErr):
+		errorResponse.Message = err.Error()
+	default:
+		h.logger.Error("Internal error (hidden from response)", err, map[string]interface{}{
+			"status_code": statusCode,
+			"client_ip":   c.ClientIP(),
+		})
+	}
+
+	c.JSON(statusCode, errorResponse)
+}
@@ -1,9 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
 	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -11,13 +14,17 @@ import (
 // APIHandler handles API requests
 type APIHandler struct {
 	wooCommerceRepo interfaces.WooCommerceRepository
+	timeline        *usecases.OrderTimelineUseCase
+	publicStatus    *usecases.PublicOrderStatusUseCase
 	logger          interfaces.Logger
 }
 
 // NewAPIHandler creates a new API handler
-func NewAPIHandler(wooCommerceRepo interfaces.WooCommerceRepository, logger interfaces.Logger) *APIHandler {
+func NewAPIHandler(wooCommerceRepo interfaces.WooCommerceRepository, timeline *usecases.OrderTimelineUseCase, publicStatus *usecases.PublicOrderStatusUseCase, logger interfaces.Logger) *APIHandler {
 	return &APIHandler{
 		wooCommerceRepo: wooCommerceRepo,
+		timeline:        timeline,
+		publicStatus:    publicStatus,
 		logger:          logger,
 	}
 }
@@ -70,7 +77,7 @@ func (h *APIHandler) GetOrderStatus(c *gin.Context) {
 	response := dto.OrderStatusResponse{
 		OrderID:       orderID,
 		Status:        string(order.Status),
-		Total:         order.Total.Amount,
+		Total:         order.Total.Float64(),
 		Currency:      order.Currency,
 		PaymentMethod: order.PaymentMethod,
 		DateCreated:   order.DateCreated,
@@ -81,6 +88,51 @@ func (h *APIHandler) GetOrderStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GetOrderStatusByToken retrieves the minimal status of the order a status
+// token was issued for. It needs no API credentials.
+func (h *APIHandler) GetOrderStatusByToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	token := c.Query("token")
+	if token == "" {
+		h.respondWithError(c, http.StatusBadRequest, "Status token is required", nil)
+		return
+	}
+
+	response, err := h.publicStatus.Execute(c.Request.Context(), token)
+	if err != nil {
+		// Never echo the cause, so tokens cannot be used to probe for orders
+		if errors.Is(err, services.ErrStatusTokenExpired) {
+			h.respondWithError(c, http.StatusGone, "Status token expired", nil)
+			return
+		}
+		h.respondWithError(c, http.StatusNotFound, "Order status not found", nil)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetOrderTimeline retrieves the audit timeline of an order's payment flow
+func (h *APIHandler) GetOrderTimeline(c *gin.Context) {
+	orderID := c.Param("id")
+	if orderID == "" {
+		h.respondWithError(c, http.StatusBadRequest, "Order ID is required", nil)
+		return
+	}
+
+	timeline, err := h.timeline.Timeline(c.Request.Context(), orderID)
+	if err != nil {
+		h.logger.Error("Failed to get order timeline", err, map[string]interface{}{
+			"order_id": orderID,
+		})
+		h.respondWithError(c, http.StatusInternalServerError, "Failed to get order timeline", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, timeline)
+}
+
 // CreateOrder handles order creation (for testing)
 func (h *APIHandler) CreateOrder(c *gin.Context) {
 	h.logger.Info("API create order request (not implemented)", map[string]interface{}{
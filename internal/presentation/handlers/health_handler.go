@@ -12,13 +12,15 @@ import (
 // HealthHandler handles health check requests
 type HealthHandler struct {
 	logger    interfaces.Logger
+	config    interfaces.ConfigService
 	startTime time.Time
 }
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler(logger interfaces.Logger) *HealthHandler {
+func NewHealthHandler(logger interfaces.Logger, config interfaces.ConfigService) *HealthHandler {
 	return &HealthHandler{
 		logger:    logger,
+		config:    config,
 		startTime: time.Now(),
 	}
 }
@@ -40,4 +42,28 @@ func (h *HealthHandler) HealthCheck(c *gin.Context) {
 	})
 
 	c.JSON(http.StatusOK, response)
-}
\ No newline at end of file
+}
+// Ping handles simple liveness pings
+func (h *HealthHandler) Ping(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "OK",
+		"message": "pong",
+	})
+}
+
+// ReadinessCheck reports whether the service is ready to accept traffic
+func (h *HealthHandler) ReadinessCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":      "ready",
+		"environment": h.config.GetServerConfig().GetEnvironment(),
+		"timestamp":   time.Now(),
+	})
+}
+
+// LivenessCheck reports whether the process is alive
+func (h *HealthHandler) LivenessCheck(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "alive",
+		"uptime": time.Since(h.startTime).String(),
+	})
+}
@@ -1,14 +1,13 @@
 package handlers
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"paypal-proxy/internal/application/dto"
 	"paypal-proxy/internal/application/services"
+	"paypal-proxy/internal/domain/entities"
 	"paypal-proxy/internal/domain/interfaces"
 	"regexp"
 	"strings"
@@ -19,18 +18,20 @@ import (
 
 // PaymentHandler handles payment-related HTTP requests with security features
 type PaymentHandler struct {
-	orchestrator  *services.PaymentOrchestrator
-	logger        interfaces.Logger
-	config        interfaces.ConfigService
-	orderIDRegex  *regexp.Regexp
-	allowedDomains map[string]bool
+	orchestrator    *services.PaymentOrchestrator
+	logger          interfaces.Logger
+	config          interfaces.ConfigService
+	webhookVerifier interfaces.WebhookVerifier
+	orderIDRegex    *regexp.Regexp
+	allowedDomains  map[string]bool
 }
 
-// NewPaymentHandler creates a new payment handler with security features
-func NewPaymentHandler(orchestrator *services.PaymentOrchestrator, logger interfaces.Logger, config interfaces.ConfigService) *PaymentHandler {
+// NewPaymentHandler creates a new payment handler with security features.
+// A nil webhookVerifier accepts unsigned webhooks outside production only.
+func NewPaymentHandler(orchestrator *services.PaymentOrchestrator, logger interfaces.Logger, config interfaces.ConfigService, webhookVerifier interfaces.WebhookVerifier) *PaymentHandler {
 	// Compile regex for order ID validation (alphanumeric, 1-50 chars)
 	orderIDRegex := regexp.MustCompile(`^[a-zA-Z0-9]{1,50}$`)
-	
+
 	// Setup allowed domains for security
 	allowedDomains := make(map[string]bool)
 	allowedDomains["magicspore.com"] = true
@@ -39,13 +40,14 @@ func NewPaymentHandler(orchestrator *services.PaymentOrchestrator, logger interf
 	allowedDomains["www.oitam.com"] = true
 	allowedDomains["localhost"] = true
 	allowedDomains["127.0.0.1"] = true
-	
+
 	return &PaymentHandler{
-		orchestrator:  orchestrator,
-		logger:        logger,
-		config:        config,
-		orderIDRegex:  orderIDRegex,
-		allowedDomains: allowedDomains,
+		orchestrator:    orchestrator,
+		logger:          logger,
+		config:          config,
+		webhookVerifier: webhookVerifier,
+		orderIDRegex:    orderIDRegex,
+		allowedDomains:  allowedDomains,
 	}
 }
 
@@ -57,20 +59,20 @@ func (h *PaymentHandler) PaymentRedirect(c *gin.Context) {
 		h.respondWithError(c, http.StatusMethodNotAllowed, "Method not allowed", nil)
 		return
 	}
-	
+
 	// Security: Check referrer if present
 	if !h.isValidReferrer(c) {
 		h.logSecurityEvent(c, "invalid_referrer", "Request from untrusted referrer")
 		h.respondWithError(c, http.StatusForbidden, "Invalid referrer", nil)
 		return
 	}
-	
+
 	orderID := c.Query("orderId")
 	if orderID == "" {
 		h.respondWithError(c, http.StatusBadRequest, "Missing orderId parameter", nil)
 		return
 	}
-	
+
 	// Security: Validate order ID format
 	if !h.validateOrderID(orderID) {
 		h.logSecurityEvent(c, "invalid_order_id", fmt.Sprintf("Invalid order ID format: %s", orderID))
@@ -79,11 +81,11 @@ func (h *PaymentHandler) PaymentRedirect(c *gin.Context) {
 	}
 
 	h.logger.Info("Payment redirect request", map[string]interface{}{
-		"order_id":     orderID,
-		"domain":       c.Request.Host,
-		"user_agent":   c.Request.UserAgent(),
-		"remote_addr":  c.ClientIP(),
-		"referrer":     c.Request.Referer(),
+		"order_id":    orderID,
+		"domain":      c.Request.Host,
+		"user_agent":  c.Request.UserAgent(),
+		"remote_addr": c.ClientIP(),
+		"referrer":    c.Request.Referer(),
 	})
 
 	request := &dto.PaymentRedirectRequest{
@@ -112,10 +114,10 @@ func (h *PaymentHandler) PayPalReturn(c *gin.Context) {
 		h.respondWithError(c, http.StatusMethodNotAllowed, "Method not allowed", nil)
 		return
 	}
-	
+
 	// Security: Rate limiting check (handled by middleware but log here too)
 	h.logSecurityEvent(c, "paypal_return", "PayPal return request received")
-	
+
 	request := &dto.PaymentReturnRequest{
 		OrderID:       h.sanitizeInput(c.Query("order_id")),
 		OITAMOrderID:  h.sanitizeInput(c.Query("oitam_order_id")),
@@ -124,7 +126,7 @@ func (h *PaymentHandler) PayPalReturn(c *gin.Context) {
 		PayerID:       h.sanitizeInput(c.Query("PayerID")),
 		TransactionID: h.sanitizeInput(c.Query("transaction_id")),
 	}
-	
+
 	// Security: Validate required fields
 	if !h.validatePaymentReturnRequest(request) {
 		h.logSecurityEvent(c, "invalid_return_data", "Invalid PayPal return parameters")
@@ -160,14 +162,14 @@ func (h *PaymentHandler) PayPalCancel(c *gin.Context) {
 		h.respondWithError(c, http.StatusMethodNotAllowed, "Method not allowed", nil)
 		return
 	}
-	
+
 	h.logSecurityEvent(c, "paypal_cancel", "PayPal cancel request received")
-	
+
 	request := &dto.PaymentCancelRequest{
 		OrderID:      h.sanitizeInput(c.Query("order_id")),
 		OITAMOrderID: h.sanitizeInput(c.Query("oitam_order_id")),
 	}
-	
+
 	// Security: Validate order ID if provided
 	if request.OrderID != "" && !h.validateOrderID(request.OrderID) {
 		h.logSecurityEvent(c, "invalid_order_id", fmt.Sprintf("Invalid order ID in cancel: %s", request.OrderID))
@@ -201,7 +203,7 @@ func (h *PaymentHandler) WebhookHandler(c *gin.Context) {
 		h.respondWithError(c, http.StatusMethodNotAllowed, "Method not allowed", nil)
 		return
 	}
-	
+
 	// Security: Validate Content-Type
 	contentType := c.GetHeader("Content-Type")
 	if !strings.Contains(contentType, "application/json") {
@@ -209,7 +211,7 @@ func (h *PaymentHandler) WebhookHandler(c *gin.Context) {
 		h.respondWithError(c, http.StatusUnsupportedMediaType, "Invalid content type", nil)
 		return
 	}
-	
+
 	// Security: Read and validate webhook signature if configured
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
@@ -219,26 +221,41 @@ func (h *PaymentHandler) WebhookHandler(c *gin.Context) {
 		h.respondWithError(c, http.StatusBadRequest, "Invalid request body", err)
 		return
 	}
-	
+
+	// Parse webhook data from the already consumed body
+	var request dto.WebhookRequest
+	parseErr := json.Unmarshal(body, &request)
+
 	// Security: Verify webhook signature
-	if !h.verifyWebhookSignature(c, body) {
+	verdict := h.verifyWebhookSignature(c, body)
+	delivery := &dto.WebhookDelivery{
+		Request:   &request,
+		Body:      body,
+		Signature: string(verdict),
+	}
+	if verdict == entities.SignatureInvalid {
+		if parseErr == nil {
+			if err := h.orchestrator.RecordRejectedWebhook(c.Request.Context(), delivery); err != nil {
+				h.logger.Error("Failed to record rejected webhook", err, map[string]interface{}{
+					"webhook_id": request.ID,
+				})
+			}
+		}
 		h.logSecurityEvent(c, "invalid_signature", "Webhook signature verification failed")
 		h.respondWithError(c, http.StatusUnauthorized, "Invalid signature", nil)
 		return
 	}
-	
-	// Parse webhook data
-	var request dto.WebhookRequest
-	if err := c.ShouldBindJSON(&request); err != nil {
-		h.logger.Error("Failed to parse webhook data", err, map[string]interface{}{
-			"content_type":   contentType,
-			"body_length":    len(body),
-			"remote_addr":    c.ClientIP(),
+
+	if parseErr != nil {
+		h.logger.Error("Failed to parse webhook data", parseErr, map[string]interface{}{
+			"content_type": contentType,
+			"body_length":  len(body),
+			"remote_addr":  c.ClientIP(),
 		})
-		h.respondWithError(c, http.StatusBadRequest, "Invalid webhook data", err)
+		h.respondWithError(c, http.StatusBadRequest, "Invalid webhook data", parseErr)
 		return
 	}
-	
+
 	// Security: Validate webhook event type
 	if !h.isValidWebhookEventType(request.EventType) {
 		h.logSecurityEvent(c, "invalid_event_type", fmt.Sprintf("Unknown webhook event type: %s", request.EventType))
@@ -246,12 +263,19 @@ func (h *PaymentHandler) WebhookHandler(c *gin.Context) {
 		return
 	}
 
+	// Event IDs are what redeliveries are matched on
+	if request.ID == "" {
+		h.respondWithError(c, http.StatusBadRequest, "Missing webhook event ID", nil)
+		return
+	}
+
 	h.logger.Info("Webhook received", map[string]interface{}{
 		"event_type": request.EventType,
 		"webhook_id": request.ID,
+		"signature":  verdict,
 	})
 
-	response, err := h.orchestrator.HandleWebhook(c.Request.Context(), &request)
+	response, err := h.orchestrator.HandleWebhook(c.Request.Context(), delivery)
 	if err != nil {
 		h.logger.Error("Webhook processing failed", err, map[string]interface{}{
 			"event_type": request.EventType,
@@ -271,12 +295,12 @@ func (h *PaymentHandler) validateOrderID(orderID string) bool {
 	if orderID == "" {
 		return false
 	}
-	
+
 	// Check length (1-50 characters)
 	if len(orderID) < 1 || len(orderID) > 50 {
 		return false
 	}
-	
+
 	// Check format using regex (alphanumeric only)
 	return h.orderIDRegex.MatchString(orderID)
 }
@@ -290,7 +314,7 @@ func (h *PaymentHandler) sanitizeInput(input string) string {
 	input = strings.ReplaceAll(input, "\"", "")
 	input = strings.ReplaceAll(input, ";", "")
 	input = strings.ReplaceAll(input, "&", "")
-	
+
 	// Trim whitespace
 	return strings.TrimSpace(input)
 }
@@ -301,14 +325,14 @@ func (h *PaymentHandler) isValidReferrer(c *gin.Context) bool {
 	if referrer == "" {
 		return true // Allow empty referrer for direct access
 	}
-	
+
 	// Extract domain from referrer
 	if strings.HasPrefix(referrer, "http://") {
 		referrer = referrer[7:]
 	} else if strings.HasPrefix(referrer, "https://") {
 		referrer = referrer[8:]
 	}
-	
+
 	// Get domain part
 	parts := strings.Split(referrer, "/")
 	if len(parts) > 0 {
@@ -319,7 +343,7 @@ func (h *PaymentHandler) isValidReferrer(c *gin.Context) bool {
 		}
 		return h.allowedDomains[domain]
 	}
-	
+
 	return false
 }
 
@@ -329,17 +353,17 @@ func (h *PaymentHandler) validatePaymentReturnRequest(req *dto.PaymentReturnRequ
 	if req.OrderID == "" || !h.validateOrderID(req.OrderID) {
 		return false
 	}
-	
+
 	// Payment ID should be alphanumeric if present
 	if req.PaymentID != "" && !regexp.MustCompile(`^[a-zA-Z0-9_-]{1,100}$`).MatchString(req.PaymentID) {
 		return false
 	}
-	
+
 	// Payer ID should be alphanumeric if present
 	if req.PayerID != "" && !regexp.MustCompile(`^[a-zA-Z0-9]{1,50}$`).MatchString(req.PayerID) {
 		return false
 	}
-	
+
 	// Status should be one of expected values if present
 	if req.Status != "" {
 		validStatuses := []string{"approved", "completed", "cancelled", "failed"}
@@ -354,73 +378,63 @@ func (h *PaymentHandler) validatePaymentReturnRequest(req *dto.PaymentReturnRequ
 			return false
 		}
 	}
-	
+
 	return true
 }
 
-// verifyWebhookSignature verifies webhook signature for security
-func (h *PaymentHandler) verifyWebhookSignature(c *gin.Context, body []byte) bool {
-	// Get webhook secret from config
-	webhookSecret := h.config.GetWebhookSecret()
-	if webhookSecret == "" || webhookSecret == "default-webhook-secret" {
-		// If no secret is configured, skip verification (development mode)
-		h.logger.Warn("Webhook signature verification skipped - no secret configured", map[string]interface{}{
-			"environment": h.config.GetServerConfig().GetEnvironment(),
+// verifyWebhookSignature verifies the PayPal transmission signature of a webhook
+func (h *PaymentHandler) verifyWebhookSignature(c *gin.Context, body []byte) entities.WebhookSignatureVerdict {
+	environment := h.config.GetServerConfig().GetEnvironment()
+	if h.webhookVerifier == nil {
+		if environment == "production" {
+			h.logger.Error("Webhook rejected - signature verification is not configured", nil, map[string]interface{}{
+				"environment": environment,
+			})
+			return entities.SignatureInvalid
+		}
+
+		// Without a verifier (development mode), skip verification
+		h.logger.Warn("Webhook signature verification skipped - no verifier configured", map[string]interface{}{
+			"environment": environment,
 		})
-		return true
+		return entities.SignatureSkipped
 	}
-	
-	// Get signature from header
-	signature := c.GetHeader("X-PayPal-Transmission-Sig")
-	if signature == "" {
-		signature = c.GetHeader("X-Hub-Signature-256")
+
+	transmission := interfaces.WebhookTransmission{
+		ID:        c.GetHeader("PAYPAL-TRANSMISSION-ID"),
+		Time:      c.GetHeader("PAYPAL-TRANSMISSION-TIME"),
+		Signature: c.GetHeader("PAYPAL-TRANSMISSION-SIG"),
+		CertURL:   c.GetHeader("PAYPAL-CERT-URL"),
+		AuthAlgo:  c.GetHeader("PAYPAL-AUTH-ALGO"),
 	}
-	
-	if signature == "" {
-		return false
+
+	if err := h.webhookVerifier.VerifyWebhook(c.Request.Context(), transmission, body); err != nil {
+		h.logger.Warn("Webhook signature verification failed", map[string]interface{}{
+			"error":           err.Error(),
+			"transmission_id": transmission.ID,
+		})
+		return entities.SignatureInvalid
 	}
-	
-	// Create HMAC hash
-	mac := hmac.New(sha256.New, []byte(webhookSecret))
-	mac.Write(body)
-	expectedMAC := mac.Sum(nil)
-	expectedSignature := "sha256=" + hex.EncodeToString(expectedMAC)
-	
-	// Compare signatures
-	return hmac.Equal([]byte(signature), []byte(expectedSignature))
+
+	return entities.SignatureVerified
 }
 
-// isValidWebhookEventType validates webhook event types
+// isValidWebhookEventType validates webhook event types against the supported catalogue
 func (h *PaymentHandler) isValidWebhookEventType(eventType string) bool {
-	validEvents := []string{
-		"PAYMENT.CAPTURE.COMPLETED",
-		"PAYMENT.CAPTURE.DENIED",
-		"PAYMENT.CAPTURE.REFUNDED",
-		"PAYMENT.CAPTURE.PENDING",
-		"CHECKOUT.ORDER.APPROVED",
-		"CHECKOUT.ORDER.COMPLETED",
-	}
-	
-	for _, valid := range validEvents {
-		if eventType == valid {
-			return true
-		}
-	}
-	
-	return false
+	return entities.IsSupportedWebhookEventType(eventType)
 }
 
 // logSecurityEvent logs security-related events
 func (h *PaymentHandler) logSecurityEvent(c *gin.Context, eventType, description string) {
 	h.logger.Warn("Security event", map[string]interface{}{
-		"event_type":   eventType,
-		"description":  description,
-		"remote_addr":  c.ClientIP(),
-		"user_agent":   c.Request.UserAgent(),
-		"method":       c.Request.Method,
-		"path":         c.Request.URL.Path,
-		"referrer":     c.Request.Referer(),
-		"timestamp":    time.Now().Unix(),
+		"event_type":  eventType,
+		"description": description,
+		"remote_addr": c.ClientIP(),
+		"user_agent":  c.Request.UserAgent(),
+		"method":      c.Request.Method,
+		"path":        c.Request.URL.Path,
+		"referrer":    c.Request.Referer(),
+		"timestamp":   time.Now().Unix(),
 	})
 }
 
@@ -430,7 +444,7 @@ func (h *PaymentHandler) respondWithError(c *gin.Context, statusCode int, messag
 	c.Header("X-Content-Type-Options", "nosniff")
 	c.Header("X-Frame-Options", "DENY")
 	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
-	
+
 	errorResponse := dto.ErrorResponse{
 		Error:     message,
 		Code:      statusCode,
@@ -451,4 +465,4 @@ func (h *PaymentHandler) respondWithError(c *gin.Context, statusCode int, messag
 	}
 
 	c.JSON(statusCode, errorResponse)
-}
\ No newline at end of file
+}
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"net/http"
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey is the gin context key of the authenticated caller
+const principalKey = "principal"
+
+// APIAuth authenticates API requests and enforces per-route scopes. A nil
+// APIAuth, for deployments without API auth, authenticates no caller, so
+// routes that require a scope reject every request.
+type APIAuth struct {
+	authenticators []interfaces.Authenticator
+	logger         interfaces.Logger
+}
+
+// NewAPIAuth creates API authentication trying each authenticator in turn
+func NewAPIAuth(authenticators []interfaces.Authenticator, logger interfaces.Logger) *APIAuth {
+	return &APIAuth{
+		authenticators: authenticators,
+		logger:         logger,
+	}
+}
+
+// Authenticate identifies the caller from the X-API-Key or bearer
+// Authorization header. Requests without credentials continue anonymously;
+// invalid credentials are rejected.
+func (a *APIAuth) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a == nil {
+			c.Next()
+			return
+		}
+
+		credentials := interfaces.APICredentials{APIKey: c.GetHeader("X-API-Key")}
+		if scheme, token, found := strings.Cut(c.GetHeader("Authorization"), " "); found && strings.EqualFold(scheme, "Bearer") {
+			credentials.BearerToken = strings.TrimSpace(token)
+		}
+
+		for _, authenticator := range a.authenticators {
+			principal, err := authenticator.Authenticate(c.Request.Context(), credentials)
+			if err != nil {
+				a.logger.Warn("API authentication failed", map[string]interface{}{
+					"path":      c.Request.URL.Path,
+					"client_ip": c.ClientIP(),
+					"error":     err.Error(),
+				})
+				a.reject(c, http.StatusUnauthorized, "Invalid credentials")
+				return
+			}
+			if principal != nil {
+				c.Set(principalKey, principal)
+				break
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// Require rejects requests whose caller does not hold scope
+func (a *APIAuth) Require(scope entities.AuthScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalFrom(c)
+		if principal == nil {
+			a.reject(c, http.StatusUnauthorized, "Authentication required")
+			return
+		}
+		if !principal.HasScope(scope) {
+			a.logger.Warn("API caller lacks required scope", map[string]interface{}{
+				"path":    c.Request.URL.Path,
+				"subject": principal.Subject,
+				"method":  principal.Method,
+				"scope":   scope,
+			})
+			a.reject(c, http.StatusForbidden, "Missing scope "+string(scope))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// PrincipalFrom returns the authenticated caller of a request, or nil
+func PrincipalFrom(c *gin.Context) *entities.Principal {
+	value, exists := c.Get(principalKey)
+	if !exists {
+		return nil
+	}
+	principal, _ := value.(*entities.Principal)
+	return principal
+}
+
+// reject aborts the request with an error response
+func (a *APIAuth) reject(c *gin.Context, statusCode int, message string) {
+	if statusCode == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Bearer realm="paypal-proxy"`)
+	}
+	c.AbortWithStatusJSON(statusCode, dto.ErrorResponse{
+		Error:     http.StatusText(statusCode),
+		Message:   message,
+		Code:      statusCode,
+		Timestamp: time.Now().Unix(),
+	})
+}
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"time"
@@ -11,15 +16,21 @@ import (
 	"paypal-proxy/internal/application/usecases"
 
 	// Domain layer
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
 	domainServices "paypal-proxy/internal/domain/services"
 
 	// Infrastructure layer
 	"paypal-proxy/internal/infrastructure/config"
+	"paypal-proxy/internal/infrastructure/database"
+	"paypal-proxy/internal/infrastructure/gateways"
 	infraHttp "paypal-proxy/internal/infrastructure/http"
 	"paypal-proxy/internal/infrastructure/repositories"
+	"paypal-proxy/internal/infrastructure/simulators"
 
 	// Presentation layer
 	"paypal-proxy/internal/presentation/handlers"
+	"paypal-proxy/internal/presentation/middleware"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -37,12 +48,45 @@ func main() {
 		log.Fatal("Failed to initialize application:", err)
 	}
 
+	// Maintenance commands run against the same storage and exit
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay-webhooks":
+			if err := runReplayWebhooks(app, os.Args[2:]); err != nil {
+				log.Fatal("Webhook replay failed: ", err)
+			}
+			return
+		default:
+			log.Fatalf("Unknown command %q", os.Args[1])
+		}
+	}
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
 
+	if app.outboxDispatcher != nil {
+		app.outboxDispatcher.Start(context.Background())
+		defer app.outboxDispatcher.Stop()
+	}
+
+	if app.webhookWorker != nil {
+		app.webhookWorker.Start(context.Background())
+		defer app.webhookWorker.Stop()
+	}
+
+	if app.reconciler != nil {
+		app.reconciler.Start(context.Background())
+		defer app.reconciler.Stop()
+	}
+
+	if app.sweeper != nil {
+		app.sweeper.Start(context.Background())
+		defer app.sweeper.Stop()
+	}
+
 	app.logger.Info("PayPal Proxy Server starting", map[string]interface{}{
 		"port":        port,
 		"environment": app.config.GetServerConfig().GetEnvironment(),
@@ -61,8 +105,15 @@ func main() {
 // Application container
 type Application struct {
 	config *config.Config
-	logger *infraHttp.Logger
+	logger interfaces.Logger
 	router *gin.Engine
+
+	orchestrator     *services.PaymentOrchestrator
+	webhookWorker    *services.WebhookWorker
+	reconciler       *services.Reconciler
+	sweeper          *services.ProxyOrderSweeper
+	outboxDispatcher *services.OutboxDispatcher
+	storageDriver    string
 }
 
 // initializeApplication sets up dependency injection and returns the application
@@ -84,16 +135,24 @@ func initializeApplication() (*Application, error) {
 	
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
-		logger.Error("Configuration validation failed", err, map[string]interface{}{})
-		logger.Warn("Continuing with potentially invalid configuration", map[string]interface{}{
-			"error": err.Error(),
-		})
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	// A broken anonymization policy must never fall back to leaking order data
+	if err := cfg.LoadAnonymizationPolicy(); err != nil {
+		return nil, fmt.Errorf("failed to load anonymization policy: %w", err)
 	}
 
 	// Infrastructure - HTTP Client
 	httpClient := infraHttp.NewDefaultHTTPClient(logger)
 	
 	// Infrastructure - Repository Layer
+	if cfg.Mock.WooCommerce {
+		if err := startWooCommerceSimulators(cfg, logger); err != nil {
+			return nil, fmt.Errorf("failed to start WooCommerce simulators: %w", err)
+		}
+	}
+
 	magicConfig := repositories.WooCommerceConfig{
 		URL:            cfg.GetMagicSporeConfig().APIURL,
 		ConsumerKey:    cfg.GetMagicSporeConfig().ConsumerKey,
@@ -113,30 +172,113 @@ func initializeApplication() (*Application, error) {
 	wooCommerceRepo := repositories.NewWooCommerceRepository(magicConfig, oitamConfig, logger)
 	urlBuilder := infraHttp.NewURLBuilder(cfg, logger)
 
+	// Infrastructure - Persistence (memory, sqlite or postgres)
+	dbConfig := cfg.GetDatabaseConfig()
+	store, err := initializeStorage(dbConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	paymentRepo := store.payments
+	mappingRepo := store.orderMappings
+	webhookEventRepo := store.webhookEvents
+	disputeRepo := store.disputes
+
+	// Every step of an order's payment flow is kept on its audit timeline,
+	// including each write that reaches MagicSpore
+	timelineUseCase := usecases.NewOrderTimelineUseCase(store.orderEvents, logger)
+	wooCommerceRepo = services.NewTimelineWooCommerceRepository(wooCommerceRepo, timelineUseCase)
+
+	// MagicSpore order updates are recorded in a durable outbox before they
+	// are sent, so a MagicSpore outage cannot lose them (ENABLE_ORDER_OUTBOX)
+	outboxUseCase := usecases.NewOutboxUseCase(wooCommerceRepo, store.outbox, logger, cfg)
+	if cfg.GetOutboxConfig().Enabled {
+		wooCommerceRepo = services.NewOutboxWooCommerceRepository(wooCommerceRepo, outboxUseCase)
+	}
+
+	// Infrastructure - Payment gateway. Without PayPal credentials, returns
+	// are verified from the OITAM proxy order alone.
+	var paymentGateway interfaces.PaymentGateway
+	paypalConfig := cfg.GetPayPalConfig()
+	if cfg.Mock.PayPal {
+		if cfg.PayPal.WebhookID == "" {
+			cfg.PayPal.WebhookID = simulators.DefaultPayPalWebhookID
+		}
+		apiBase, err := startPayPalSimulator(cfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start PayPal simulator: %w", err)
+		}
+		paypalConfig.APIBase = apiBase
+		if !paypalConfig.HasCredentials() {
+			paypalConfig.ClientID = "simulator"
+			paypalConfig.ClientSecret = "simulator"
+		}
+		// The simulator's signing certificate is self-signed, so webhooks are
+		// verified through its verify-webhook-signature endpoint instead
+		paypalConfig.WebhookID = cfg.PayPal.WebhookID
+		if paypalConfig.WebhookVerification != config.WebhookVerificationDisabled {
+			paypalConfig.WebhookVerification = config.WebhookVerificationAPI
+		}
+	}
+	if paypalConfig.HasCredentials() {
+		paymentGateway = gateways.NewPayPalGateway(gateways.PayPalConfig{
+			ClientID:      paypalConfig.ClientID,
+			ClientSecret:  paypalConfig.ClientSecret,
+			APIBase:       paypalConfig.GetAPIBase(),
+			Timeout:       paypalConfig.Timeout,
+			RetryAttempts: paypalConfig.RetryAttempts,
+			RetryDelay:    paypalConfig.RetryDelay,
+		}, logger)
+	} else {
+		logger.Warn("PayPal credentials not configured - captures are not verified with PayPal", map[string]interface{}{
+			"environment": paypalConfig.Environment,
+		})
+	}
+
+	webhookVerifier := newWebhookVerifier(paypalConfig, logger)
+
+	// Infrastructure - Exchange rates, only needed with a settlement currency
+	exchangeRateProvider, err := newExchangeRateProvider(cfg.GetCurrencyConfig(), logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize exchange rate provider: %w", err)
+	}
+
 	// 2. Domain Layer - Business Logic Services
 	orderDomainService := domainServices.NewOrderDomainService(logger)
 	paymentDomainService := domainServices.NewPaymentDomainService(logger)
+	var currencyService *domainServices.CurrencyConversionService
+	if exchangeRateProvider != nil {
+		currencyService = domainServices.NewCurrencyConversionService(exchangeRateProvider, logger)
+	}
 
 	// 3. Application Layer - Use Cases
 	redirectUseCase := usecases.NewPaymentRedirectUseCase(
 		wooCommerceRepo,
+		mappingRepo,
 		urlBuilder,
 		orderDomainService,
 		paymentDomainService,
+		currencyService,
+		timelineUseCase,
 		logger,
 		cfg,
 	)
 
 	returnUseCase := usecases.NewPaymentReturnUseCase(
 		wooCommerceRepo,
+		paymentRepo,
+		mappingRepo,
+		paymentGateway,
 		paymentDomainService,
 		orderDomainService,
+		timelineUseCase,
 		logger,
 		cfg,
 	)
 
 	cancelUseCase := usecases.NewPaymentCancelUseCase(
 		wooCommerceRepo,
+		paymentRepo,
+		mappingRepo,
 		paymentDomainService,
 		orderDomainService,
 		logger,
@@ -145,28 +287,109 @@ func initializeApplication() (*Application, error) {
 
 	webhookUseCase := usecases.NewWebhookUseCase(
 		wooCommerceRepo,
+		paymentRepo,
+		mappingRepo,
+		webhookEventRepo,
+		disputeRepo,
+		paymentDomainService,
+		orderDomainService,
+		timelineUseCase,
+		logger,
+		cfg,
+	)
+
+	refundUseCase := usecases.NewPaymentRefundUseCase(
+		wooCommerceRepo,
+		paymentRepo,
+		mappingRepo,
+		paymentGateway,
 		paymentDomainService,
 		orderDomainService,
 		logger,
+	)
+
+	reconcileUseCase := usecases.NewReconciliationUseCase(
+		returnUseCase,
+		wooCommerceRepo,
+		paymentRepo,
+		mappingRepo,
+		paymentGateway,
+		logger,
+		cfg,
+	)
+
+	sweepUseCase := usecases.NewProxyOrderSweepUseCase(
+		wooCommerceRepo,
+		mappingRepo,
+		orderDomainService,
+		logger,
 		cfg,
 	)
 
+	publicStatusUseCase := usecases.NewPublicOrderStatusUseCase(wooCommerceRepo, cfg, logger)
+
 	// Application services - Orchestrator
 	orchestrator := services.NewPaymentOrchestrator(
 		redirectUseCase,
 		returnUseCase,
 		cancelUseCase,
 		webhookUseCase,
+		refundUseCase,
+		reconcileUseCase,
+		sweepUseCase,
+		outboxUseCase,
 		logger,
 	)
 
+	// Background webhook processing (ENABLE_WEBHOOK_RETRY)
+	var webhookWorker *services.WebhookWorker
+	if cfg.GetWebhookQueueConfig().Enabled {
+		if dbConfig.Driver == config.DatabaseDriverMemory {
+			logger.Warn("Webhook queue uses in-memory storage - queued events are lost on restart", map[string]interface{}{
+				"setting": "DATABASE_DRIVER",
+			})
+		}
+		webhookWorker = services.NewWebhookWorker(webhookUseCase, cfg, logger)
+	}
+
+	// Background reconciliation of proxy orders (ENABLE_RECONCILIATION)
+	var reconciler *services.Reconciler
+	if cfg.GetReconciliationConfig().Enabled {
+		reconciler = services.NewReconciler(reconcileUseCase, cfg, logger)
+	}
+
+	// Background closing of abandoned proxy orders (ENABLE_PROXY_ORDER_SWEEP)
+	var sweeper *services.ProxyOrderSweeper
+	if cfg.GetSweepConfig().Enabled {
+		sweeper = services.NewProxyOrderSweeper(sweepUseCase, cfg, logger)
+	}
+
+	// Background delivery of outbox entries (ENABLE_ORDER_OUTBOX)
+	var outboxDispatcher *services.OutboxDispatcher
+	if cfg.GetOutboxConfig().Enabled {
+		if dbConfig.Driver == config.DatabaseDriverMemory {
+			logger.Warn("Order outbox uses in-memory storage - undelivered updates are lost on restart", map[string]interface{}{
+				"setting": "DATABASE_DRIVER",
+			})
+		}
+		outboxDispatcher = services.NewOutboxDispatcher(outboxUseCase, cfg, logger)
+	}
+
 	// 4. Presentation Layer - HTTP Handlers
-	paymentHandler := handlers.NewPaymentHandler(orchestrator, logger, cfg)
+	paymentHandler := handlers.NewPaymentHandler(orchestrator, logger, cfg, webhookVerifier)
 	healthHandler := handlers.NewHealthHandler(logger, cfg)
-	apiHandler := handlers.NewAPIHandler(wooCommerceRepo, logger)
+	apiHandler := handlers.NewAPIHandler(wooCommerceRepo, timelineUseCase, publicStatusUseCase, logger)
+	adminHandler := handlers.NewAdminHandler(orchestrator, logger, cfg)
+
+	// API authentication (API_AUTH_ENABLED). Misconfigured credentials stop
+	// startup rather than leaving the API open.
+	apiAuth, err := newAPIAuth(cfg.GetAPIAuthConfig(), logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize API authentication: %w", err)
+	}
 
 	// 5. HTTP Router Setup
-	if serverConfig.Environment == "production" {
+	if serverConfig.GetEnvironment() == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	} else {
 		gin.SetMode(gin.DebugMode)
@@ -231,7 +454,7 @@ func initializeApplication() (*Application, error) {
 	})
 
 	// Routes setup
-	setupRoutes(router, paymentHandler, healthHandler, apiHandler)
+	setupRoutes(router, paymentHandler, healthHandler, apiHandler, adminHandler, apiAuth)
 
 	// Cleanup function for graceful shutdown
 	defer func() {
@@ -251,22 +474,308 @@ func initializeApplication() (*Application, error) {
 			"security_headers": true,
 			"request_logging": true,
 			"cors": true,
+			"storage_driver": dbConfig.Driver,
+			"webhook_queue": webhookWorker != nil,
+			"reconciliation": reconciler != nil,
+			"proxy_order_sweep": sweeper != nil,
+			"order_outbox": outboxDispatcher != nil,
+			"api_auth": apiAuth != nil,
+			"status_tokens": cfg.GetStatusTokenConfig().Secret != "",
 		},
 	})
 
 	return &Application{
-		config: cfg,
-		logger: logger,
-		router: router,
+		config:           cfg,
+		logger:           logger,
+		router:           router,
+		orchestrator:     orchestrator,
+		webhookWorker:    webhookWorker,
+		reconciler:       reconciler,
+		sweeper:          sweeper,
+		outboxDispatcher: outboxDispatcher,
+		storageDriver:    dbConfig.Driver,
 	}, nil
 }
 
+// newWebhookVerifier builds the verifier for PAYPAL_WEBHOOK_VERIFICATION. It
+// returns nil when webhooks cannot be verified, which the payment handler
+// only tolerates outside production.
+func newWebhookVerifier(paypalConfig config.PayPalConfig, logger interfaces.Logger) interfaces.WebhookVerifier {
+	if paypalConfig.WebhookVerification == config.WebhookVerificationDisabled {
+		logger.Warn("PayPal webhook signature verification disabled", map[string]interface{}{
+			"environment": paypalConfig.Environment,
+		})
+		return nil
+	}
+
+	if paypalConfig.WebhookID == "" {
+		logger.Warn("PayPal webhook ID not configured - webhook signatures are not verified", map[string]interface{}{
+			"environment": paypalConfig.Environment,
+		})
+		return nil
+	}
+
+	if paypalConfig.WebhookVerification == config.WebhookVerificationAPI {
+		if !paypalConfig.HasCredentials() {
+			logger.Warn("PayPal credentials not configured - webhook signatures are not verified", map[string]interface{}{
+				"environment": paypalConfig.Environment,
+			})
+			return nil
+		}
+
+		return gateways.NewPayPalAPIVerifier(gateways.PayPalConfig{
+			ClientID:      paypalConfig.ClientID,
+			ClientSecret:  paypalConfig.ClientSecret,
+			APIBase:       paypalConfig.GetAPIBase(),
+			Timeout:       paypalConfig.Timeout,
+			RetryAttempts: paypalConfig.RetryAttempts,
+			RetryDelay:    paypalConfig.RetryDelay,
+		}, paypalConfig.WebhookID, logger)
+	}
+
+	return gateways.NewPayPalCertVerifier(gateways.PayPalCertVerifierConfig{
+		WebhookID: paypalConfig.WebhookID,
+		Timeout:   paypalConfig.Timeout,
+	}, logger)
+}
+
+// newExchangeRateProvider selects the exchange rate provider for the settlement
+// currency. Without a settlement currency no conversion takes place.
+func newExchangeRateProvider(currencyConfig config.CurrencyConfig, logger interfaces.Logger) (interfaces.ExchangeRateProvider, error) {
+	if currencyConfig.SettlementCurrency == "" {
+		return nil, nil
+	}
+
+	logger.Info("Proxy orders settle in a fixed currency", map[string]interface{}{
+		"settlement_currency": currencyConfig.SettlementCurrency,
+		"rate_provider":       currencyConfig.RateProvider,
+	})
+
+	if currencyConfig.RateProvider == config.ExchangeRateProviderRemote {
+		return gateways.NewRemoteExchangeRateProvider(gateways.RemoteExchangeRateConfig{
+			BaseURL:  currencyConfig.RemoteURL,
+			CacheTTL: currencyConfig.CacheTTL,
+		}, logger), nil
+	}
+
+	return gateways.NewStaticExchangeRateProvider(currencyConfig.StaticRates, currencyConfig.RatesFile)
+}
+
+// newAPIAuth builds authentication for the API routes from the configured API
+// keys and JWT secret. It returns nil when API auth is disabled.
+func newAPIAuth(authConfig config.APIAuthConfig, logger interfaces.Logger) (*middleware.APIAuth, error) {
+	if !authConfig.Enabled {
+		logger.Warn("API authentication disabled - order, refund and admin routes are unavailable", map[string]interface{}{
+			"setting": "API_AUTH_ENABLED",
+		})
+		return nil, nil
+	}
+
+	var authenticators []interfaces.Authenticator
+	if authConfig.APIKeys != "" {
+		authenticator, err := infraHttp.NewAPIKeyAuthenticator(authConfig.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+	if authConfig.JWTSecret != "" {
+		authenticator, err := infraHttp.NewJWTAuthenticator(infraHttp.JWTConfig{
+			Secret:   authConfig.JWTSecret,
+			Issuer:   authConfig.JWTIssuer,
+			Audience: authConfig.JWTAudience,
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, authenticator)
+	}
+	if len(authenticators) == 0 {
+		return nil, fmt.Errorf("API_KEYS or JWT_SECRET_KEY is required when API_AUTH_ENABLED is set")
+	}
+
+	logger.Info("API authentication enabled", map[string]interface{}{
+		"api_keys":   authConfig.APIKeys != "",
+		"jwt_bearer": authConfig.JWTSecret != "",
+	})
+
+	return middleware.NewAPIAuth(authenticators, logger), nil
+}
+
+// startPayPalSimulator serves the PayPal simulator for MOCK_PAYPAL and returns
+// its API base URL. Webhooks are delivered to this server's local /webhook endpoint.
+func startPayPalSimulator(cfg *config.Config, logger interfaces.Logger) (string, error) {
+	paypalConfig := cfg.GetPayPalConfig()
+	simulator, err := simulators.NewPayPalSimulator(simulators.PayPalSimulatorConfig{
+		ClientID:      paypalConfig.ClientID,
+		ClientSecret:  paypalConfig.ClientSecret,
+		WebhookURL:    "http://127.0.0.1:" + cfg.GetServerConfig().GetPort() + "/webhook",
+		WebhookID:     paypalConfig.WebhookID,
+		WebhookSecret: cfg.GetWebhookSecret(),
+	}, logger)
+	if err != nil {
+		return "", err
+	}
+
+	listener, err := net.Listen("tcp", cfg.Mock.PayPalAddress)
+	if err != nil {
+		return "", err
+	}
+
+	go func() {
+		if err := http.Serve(listener, simulator); err != nil {
+			logger.Error("PayPal simulator stopped", err, map[string]interface{}{
+				"address": cfg.Mock.PayPalAddress,
+			})
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	apiBase := "http://127.0.0.1:" + port
+
+	logger.Warn("Using PayPal simulator - no real payments are processed", map[string]interface{}{
+		"address":  cfg.Mock.PayPalAddress,
+		"api_base": apiBase,
+	})
+
+	return apiBase, nil
+}
+
+// startWooCommerceSimulators serves simulated MagicSpore and OITAM stores for
+// MOCK_WOOCOMMERCE on one listener and points the store configuration at them.
+func startWooCommerceSimulators(cfg *config.Config, logger interfaces.Logger) error {
+	stores := []struct {
+		name         string
+		store        *config.WooCommerceConfig
+		firstOrderID int
+	}{
+		{name: "magicspore", store: &cfg.Magic, firstOrderID: 1000},
+		{name: "oitam", store: &cfg.OITAM, firstOrderID: 5000},
+	}
+
+	listener, err := net.Listen("tcp", cfg.Mock.WooCommerceAddress)
+	if err != nil {
+		return err
+	}
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	mux := http.NewServeMux()
+	for _, s := range stores {
+		if s.store.ConsumerKey == "" || s.store.ConsumerSecret == "" {
+			s.store.ConsumerKey = "simulator"
+			s.store.ConsumerSecret = "simulator"
+		}
+		simulator := simulators.NewWooCommerceSimulator(simulators.WooCommerceSimulatorConfig{
+			Name:           s.name,
+			ConsumerKey:    s.store.ConsumerKey,
+			ConsumerSecret: s.store.ConsumerSecret,
+			FirstOrderID:   s.firstOrderID,
+		}, logger)
+		mux.Handle("/"+s.name+"/", http.StripPrefix("/"+s.name, simulator))
+		s.store.URL = "http://127.0.0.1:" + port + "/" + s.name
+	}
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			logger.Error("WooCommerce simulators stopped", err, map[string]interface{}{
+				"address": cfg.Mock.WooCommerceAddress,
+			})
+		}
+	}()
+
+	logger.Warn("Using WooCommerce simulators - store orders are kept in memory", map[string]interface{}{
+		"address":   cfg.Mock.WooCommerceAddress,
+		"magic_url": cfg.Magic.URL,
+		"oitam_url": cfg.OITAM.URL,
+	})
+
+	return nil
+}
+
+// storage groups the repositories backed by the configured database driver
+type storage struct {
+	payments      interfaces.PaymentRepository
+	orders        interfaces.OrderRepository
+	orderMappings interfaces.OrderMappingRepository
+	webhookEvents interfaces.WebhookEventRepository
+	disputes      interfaces.DisputeRepository
+	outbox        interfaces.OutboxRepository
+	orderEvents   interfaces.OrderEventRepository
+}
+
+// initializeStorage builds repositories for the configured database driver
+func initializeStorage(dbConfig config.DatabaseConfig, logger interfaces.Logger) (*storage, error) {
+	ctx := context.Background()
+
+	switch dbConfig.Driver {
+	case config.DatabaseDriverPostgres:
+		db, err := database.NewPostgresDB(ctx, database.PostgresConfig{
+			ConnectionString: dbConfig.ConnectionString,
+			MaxConnections:   dbConfig.MaxConnections,
+			Timeout:          dbConfig.Timeout,
+			AutoMigrate:      dbConfig.AutoMigrate,
+		}, logger)
+		if err != nil {
+			return nil, err
+		}
+		// The server database persists payments, order mappings, webhook events,
+		// disputes, the outbox and the order timeline; orders themselves live in
+		// WooCommerce and are only cached in memory
+		return &storage{
+			payments:      repositories.NewPostgresPaymentRepository(db, logger),
+			orders:        repositories.NewMemoryOrderRepository(logger),
+			orderMappings: repositories.NewPostgresOrderMappingRepository(db, logger),
+			webhookEvents: repositories.NewPostgresWebhookEventRepository(db, logger),
+			disputes:      repositories.NewPostgresDisputeRepository(db, logger),
+			outbox:        repositories.NewPostgresOutboxRepository(db, logger),
+			orderEvents:   repositories.NewPostgresOrderEventRepository(db, logger),
+		}, nil
+
+	case config.DatabaseDriverSQLite:
+		db, err := database.NewSQLiteDB(ctx, database.SQLiteConfig{
+			Path:        dbConfig.SQLitePath,
+			AutoMigrate: dbConfig.AutoMigrate,
+		}, logger)
+		if err != nil {
+			return nil, err
+		}
+		return &storage{
+			payments:      repositories.NewSQLitePaymentRepository(db, logger),
+			orders:        repositories.NewSQLiteOrderRepository(db, logger),
+			orderMappings: repositories.NewSQLiteOrderMappingRepository(db, logger),
+			webhookEvents: repositories.NewSQLiteWebhookEventRepository(db, logger),
+			disputes:      repositories.NewSQLiteDisputeRepository(db, logger),
+			outbox:        repositories.NewSQLiteOutboxRepository(db, logger),
+			orderEvents:   repositories.NewSQLiteOrderEventRepository(db, logger),
+		}, nil
+
+	case config.DatabaseDriverMemory:
+		logger.Warn("Using in-memory storage - records are lost on restart", map[string]interface{}{
+			"setting": "DATABASE_DRIVER",
+		})
+		return &storage{
+			payments:      repositories.NewMemoryPaymentRepository(logger),
+			orders:        repositories.NewMemoryOrderRepository(logger),
+			orderMappings: repositories.NewMemoryOrderMappingRepository(logger),
+			webhookEvents: repositories.NewMemoryWebhookEventRepository(logger),
+			disputes:      repositories.NewMemoryDisputeRepository(logger),
+			outbox:        repositories.NewMemoryOutboxRepository(logger),
+			orderEvents:   repositories.NewMemoryOrderEventRepository(logger),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", dbConfig.Driver)
+	}
+}
+
 // setupRoutes configures all application routes
 func setupRoutes(
 	router *gin.Engine,
 	paymentHandler *handlers.PaymentHandler,
 	healthHandler *handlers.HealthHandler,
 	apiHandler *handlers.APIHandler,
+	adminHandler *handlers.AdminHandler,
+	apiAuth *middleware.APIAuth,
 ) {
 	// Health check endpoints
 	health := router.Group("/")
@@ -292,20 +801,50 @@ func setupRoutes(
 		payment.POST("/paypal-webhook", paymentHandler.WebhookHandler) // Alternative endpoint
 	}
 
-	// API routes for order management
+	// API routes for order management. Each route names the scope its
+	// caller needs; without API auth those routes reject every caller.
 	api := router.Group("/api/v1")
+	api.Use(apiAuth.Authenticate())
 	{
+		readOrders := apiAuth.Require(entities.ScopeReadOrders)
+		writeRefunds := apiAuth.Require(entities.ScopeWriteRefunds)
+		admin := apiAuth.Require(entities.ScopeAdmin)
+
 		// Order endpoints
-		api.GET("/order/:id", apiHandler.GetOrder)
-		api.POST("/order", apiHandler.CreateOrder)
-		api.PUT("/order/:id", apiHandler.UpdateOrder)
+		api.GET("/order/:id", readOrders, apiHandler.GetOrder)
+		api.GET("/order/:id/timeline", readOrders, apiHandler.GetOrderTimeline)
+		api.POST("/order", admin, apiHandler.CreateOrder)
+		api.PUT("/order/:id", admin, apiHandler.UpdateOrder)
 		
 		// Status endpoints  
-		api.GET("/status/:id", apiHandler.GetOrderStatus)
-		api.GET("/order/:id/status", apiHandler.GetOrderStatus)
+		api.GET("/status/:id", readOrders, apiHandler.GetOrderStatus)
+		api.GET("/order/:id/status", readOrders, apiHandler.GetOrderStatus)
+
+		// Storefront status polling, authorized by the signed status token
+		// handed out in the redirect flow rather than by API credentials
+		api.GET("/status", apiHandler.GetOrderStatusByToken)
 		
 		// Health endpoint for API
 		api.GET("/health", healthHandler.HealthCheck)
+
+		// Refunds and admin endpoints move money and change payment state, so
+		// without API auth they are not registered at all. Replays then go
+		// through the replay-webhooks command instead.
+		if apiAuth != nil {
+			api.POST("/order/:id/refund", writeRefunds, adminHandler.RefundOrder)
+			api.POST("/admin/webhooks/events/:id/replay", admin, adminHandler.ReplayWebhookEvent)
+			api.POST("/admin/webhooks/replay", admin, adminHandler.ReplayWebhookEvents)
+			api.POST("/admin/webhooks/events/:id/requeue", admin, adminHandler.RequeueWebhookEvent)
+			api.GET("/admin/webhooks/dead-letter", admin, adminHandler.ListDeadLetterWebhooks)
+			api.GET("/admin/disputes", admin, adminHandler.ListOpenDisputes)
+			api.GET("/admin/reconciliation", admin, adminHandler.LastReconciliation)
+			api.POST("/admin/reconciliation", admin, adminHandler.Reconcile)
+			api.GET("/admin/proxy-orders/sweep", admin, adminHandler.LastProxyOrderSweep)
+			api.POST("/admin/proxy-orders/sweep", admin, adminHandler.SweepProxyOrders)
+			api.GET("/admin/outbox/stuck", admin, adminHandler.ListStuckOutboxEntries)
+			api.POST("/admin/outbox/:id/retry", admin, adminHandler.RetryOutboxEntry)
+			api.POST("/admin/outbox/:id/discard", admin, adminHandler.DiscardOutboxEntry)
+		}
 	}
 
 	// Legacy endpoints for backward compatibility
@@ -313,4 +852,60 @@ func setupRoutes(
 	{
 		legacy.GET("/paypal", paymentHandler.PaymentRedirect) // Legacy redirect
 	}
-}
\ No newline at end of file
+}
+
+// runReplayWebhooks implements the replay-webhooks command, which processes
+// stored webhook events again by ID or by receive time range:
+//
+//	paypal-proxy replay-webhooks -id WH-123
+//	paypal-proxy replay-webhooks -from 2024-01-01T00:00:00Z [-to 2024-01-02T00:00:00Z]
+func runReplayWebhooks(app *Application, args []string) error {
+	flags := flag.NewFlagSet("replay-webhooks", flag.ContinueOnError)
+	eventID := flags.String("id", "", "webhook event ID to replay")
+	fromValue := flags.String("from", "", "replay events received at or after this RFC 3339 time")
+	toValue := flags.String("to", "", "replay events received before this RFC 3339 time (default now)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if app.storageDriver == config.DatabaseDriverMemory {
+		return fmt.Errorf("webhook events are only replayable from sqlite or postgres storage")
+	}
+
+	ctx := context.Background()
+	var result interface{}
+
+	switch {
+	case *eventID != "":
+		replayed, err := app.orchestrator.ReplayWebhook(ctx, *eventID)
+		if err != nil {
+			return err
+		}
+		result = replayed
+
+	case *fromValue != "":
+		from, err := time.Parse(time.RFC3339, *fromValue)
+		if err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+		to := time.Now()
+		if *toValue != "" {
+			if to, err = time.Parse(time.RFC3339, *toValue); err != nil {
+				return fmt.Errorf("invalid -to: %w", err)
+			}
+		}
+		replayed, err := app.orchestrator.ReplayWebhooks(ctx, from, to)
+		if err != nil {
+			return err
+		}
+		result = replayed
+
+	default:
+		flags.Usage()
+		return fmt.Errorf("either -id or -from is required")
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
@@ -0,0 +1,114 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// TestSimulatedPayPalWebhooksAreAccepted checks that signed webhooks emitted by
+// the PayPal simulator (MOCK_PAYPAL=true) are accepted by the proxy
+func (suite *E2ETestSuite) TestSimulatedPayPalWebhooksAreAccepted() {
+	simulatorURL := suite.paypalSimulatorURL()
+
+	suite.simulatorCall(http.MethodPost, simulatorURL+"/simulator/reset", nil, nil)
+
+	token := suite.simulatorToken(simulatorURL)
+
+	var order struct {
+		ID string `json:"id"`
+	}
+	suite.simulatorCall(http.MethodPost, simulatorURL+"/v2/checkout/orders", map[string]interface{}{
+		"intent": "CAPTURE",
+		"purchase_units": []map[string]interface{}{{
+			"custom_id": fmt.Sprintf("E2E_SIM_%d", time.Now().Unix()),
+			"amount":    map[string]string{"currency_code": "PLN", "value": "10.00"},
+		}},
+	}, &order, "Authorization", "Bearer "+token)
+	suite.Require().NotEmpty(order.ID)
+
+	suite.simulatorCall(http.MethodPost, simulatorURL+"/simulator/orders/"+order.ID+"/approve", nil, nil)
+
+	var webhooks []struct {
+		EventType  string `json:"event_type"`
+		StatusCode int    `json:"status_code"`
+		Error      string `json:"error"`
+	}
+	suite.simulatorCall(http.MethodGet, simulatorURL+"/simulator/webhooks", nil, &webhooks)
+
+	suite.Require().Len(webhooks, 1)
+	suite.Equal("CHECKOUT.ORDER.APPROVED", webhooks[0].EventType)
+	suite.Empty(webhooks[0].Error)
+	suite.Equal(http.StatusOK, webhooks[0].StatusCode)
+}
+
+// paypalSimulatorURL returns the simulator URL, skipping when it is not running
+func (suite *E2ETestSuite) paypalSimulatorURL() string {
+	simulatorURL := getEnvOrDefault("TEST_PAYPAL_SIMULATOR_URL", "http://localhost:8091")
+
+	resp, err := suite.httpClient.Get(simulatorURL + "/simulator/webhooks")
+	if err != nil {
+		suite.T().Skipf("PayPal simulator not reachable at %s: %v", simulatorURL, err)
+	}
+	resp.Body.Close()
+
+	return simulatorURL
+}
+
+// simulatorToken fetches an access token from the simulator
+func (suite *E2ETestSuite) simulatorToken(simulatorURL string) string {
+	req, err := http.NewRequest(http.MethodPost, simulatorURL+"/v1/oauth2/token", bytes.NewBufferString("grant_type=client_credentials"))
+	suite.Require().NoError(err)
+	req.SetBasicAuth(getEnvOrDefault("PAYPAL_CLIENT_ID", "test_paypal_client_id"), getEnvOrDefault("PAYPAL_CLIENT_SECRET", "test_paypal_secret"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := suite.httpClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&token))
+	return token.AccessToken
+}
+
+// simulatorCall sends a JSON request to the simulator and decodes the response
+func (suite *E2ETestSuite) simulatorCall(method, url string, body interface{}, out interface{}, headers ...string) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		suite.Require().NoError(err)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(payload))
+	suite.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := suite.httpClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	suite.Require().Less(resp.StatusCode, 300, "%s %s", method, url)
+
+	if out != nil {
+		suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+	}
+}
+
+// getEnvOrDefault reads an environment variable with a fallback
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,328 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+	"paypal-proxy/internal/infrastructure/simulators"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// sensitiveOrderValues are details of the seeded MagicSpore order that must
+// never reach OITAM
+var sensitiveOrderValues = []string{
+	"Golden Teacher Spore Syringe",
+	"Psilocybe Cubensis Grow Kit",
+	"GT-SYR-10ML",
+	"KIT-CUBE-01",
+	"strain",
+	"Jan",
+	"Kowalski",
+	"jan.kowalski@example.com",
+	"+48 600 700 800",
+	"Marszałkowska 1",
+	"Warszawa",
+	"00-001",
+	"Leave at the door",
+}
+
+// AnonymizationIntegrationTestSuite tests that proxy orders only reveal what
+// the anonymization policy allows
+type AnonymizationIntegrationTestSuite struct {
+	suite.Suite
+	logger      interfaces.Logger
+	cfg         *config.Config
+	magic       *simulators.WooCommerceSimulator
+	oitam       *simulators.WooCommerceSimulator
+	magicServer *httptest.Server
+	oitamServer *httptest.Server
+	repo        interfaces.WooCommerceRepository
+}
+
+// SetupTest starts both store simulators
+func (suite *AnonymizationIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+	suite.cfg = config.NewConfig()
+	suite.cfg.Proxy.OrderTTL = time.Hour
+
+	suite.magic = simulators.NewWooCommerceSimulator(simulators.WooCommerceSimulatorConfig{
+		Name:           "magicspore",
+		ConsumerKey:    "ck_magic",
+		ConsumerSecret: "cs_magic",
+		FirstOrderID:   1000,
+	}, suite.logger)
+	suite.oitam = simulators.NewWooCommerceSimulator(simulators.WooCommerceSimulatorConfig{
+		Name:           "oitam",
+		ConsumerKey:    "ck_oitam",
+		ConsumerSecret: "cs_oitam",
+		FirstOrderID:   5000,
+	}, suite.logger)
+	suite.magicServer = httptest.NewServer(suite.magic)
+	suite.oitamServer = httptest.NewServer(suite.oitam)
+
+	suite.repo = repositories.NewWooCommerceRepository(
+		repositories.WooCommerceConfig{URL: suite.magicServer.URL, ConsumerKey: "ck_magic", ConsumerSecret: "cs_magic", Timeout: 5 * time.Second, RetryAttempts: 1},
+		repositories.WooCommerceConfig{URL: suite.oitamServer.URL, ConsumerKey: "ck_oitam", ConsumerSecret: "cs_oitam", Timeout: 5 * time.Second, RetryAttempts: 1},
+		suite.logger,
+	)
+}
+
+// TearDownTest stops the simulators
+func (suite *AnonymizationIntegrationTestSuite) TearDownTest() {
+	suite.magicServer.Close()
+	suite.oitamServer.Close()
+}
+
+// TestNoOriginalDetailsLeakToOITAM tests the default policy end to end
+func (suite *AnonymizationIntegrationTestSuite) TestNoOriginalDetailsLeakToOITAM() {
+	suite.Require().NoError(suite.cfg.LoadAnonymizationPolicy())
+
+	raw := suite.redirectAndFetchProxyOrder()
+	for _, value := range sensitiveOrderValues {
+		suite.NotContains(raw, value)
+	}
+
+	proxyOrder, err := suite.repo.GetOITAMOrder(context.Background(), "5000")
+	suite.Require().NoError(err)
+	suite.Require().Len(proxyOrder.LineItems, 2)
+	suite.Equal("Item 1", proxyOrder.LineItems[0].Name)
+	suite.Equal("Item 2", proxyOrder.LineItems[1].Name)
+	suite.Empty(proxyOrder.LineItems[0].SKU)
+	suite.Equal("PL", proxyOrder.Billing.Country)
+	suite.Equal("noreply@oitam.com", proxyOrder.Billing.Email)
+	suite.Equal("109.97", proxyOrder.Total.Decimal())
+
+	// Shipping and fee lines are sent with their totals
+	suite.Require().Len(proxyOrder.ShippingLines, 1)
+	suite.Equal("flat_rate", proxyOrder.ShippingLines[0].MethodID)
+	suite.Equal("Courier", proxyOrder.ShippingLines[0].MethodTitle)
+	suite.Equal("15.00", proxyOrder.ShippingLines[0].Total.Decimal())
+	suite.Require().Len(proxyOrder.FeeLines, 1)
+	suite.Equal("Packaging", proxyOrder.FeeLines[0].Name)
+	suite.Equal("5.00", proxyOrder.FeeLines[0].Total.Decimal())
+}
+
+// TestConfiguredPolicyNamesItemsByCategory tests a policy file with
+// descriptors and hashed fields
+func (suite *AnonymizationIntegrationTestSuite) TestConfiguredPolicyNamesItemsByCategory() {
+	suite.cfg.Anonymization.PolicyFile = suite.writePolicy(`{
+		"fields": {
+			"line_item.name": {"action": "replace", "value": "{descriptor} #{n}"},
+			"billing.email": {"action": "replace", "value": "{hash}@customers.oitam.com"},
+			"billing.phone": {"action": "hash"},
+			"shipping_line.method_title": {"action": "replace", "value": "Delivery"},
+			"fee_line.name": {"action": "replace", "value": "Fee {n}"}
+		},
+		"default_descriptor": "Lab supply",
+		"descriptors": [
+			{"descriptor": "Microscopy sample", "sku_prefixes": ["GT-"]}
+		]
+	}`)
+	suite.cfg.Anonymization.HashSalt = "test-salt"
+	suite.Require().NoError(suite.cfg.LoadAnonymizationPolicy())
+
+	raw := suite.redirectAndFetchProxyOrder()
+	for _, value := range sensitiveOrderValues {
+		suite.NotContains(raw, value)
+	}
+
+	proxyOrder, err := suite.repo.GetOITAMOrder(context.Background(), "5000")
+	suite.Require().NoError(err)
+	suite.Equal("Microscopy sample #1", proxyOrder.LineItems[0].Name)
+	suite.Equal("Lab supply #2", proxyOrder.LineItems[1].Name)
+	suite.Regexp(`^[0-9a-f]{16}@customers\.oitam\.com$`, proxyOrder.Billing.Email)
+	suite.Regexp(`^[0-9a-f]{16}$`, proxyOrder.Billing.Phone)
+	suite.Equal("Customer", proxyOrder.Billing.FirstName, "unlisted fields keep their default rule")
+	suite.NotContains(raw, "Courier")
+	suite.NotContains(raw, "Packaging")
+	suite.Require().Len(proxyOrder.ShippingLines, 1)
+	suite.Equal("Delivery", proxyOrder.ShippingLines[0].MethodTitle)
+	suite.Require().Len(proxyOrder.FeeLines, 1)
+	suite.Equal("Fee 1", proxyOrder.FeeLines[0].Name)
+}
+
+// TestPolicyRules tests each action on a single order
+func (suite *AnonymizationIntegrationTestSuite) TestPolicyRules() {
+	order := &entities.Order{
+		Number:       "1001",
+		Currency:     "PLN",
+		Total:        entities.NewMoney(10, "PLN"),
+		CustomerNote: "Call me",
+		Billing:      entities.Address{FirstName: "Jan", Email: "jan@example.com", Country: "PL", City: "Kraków"},
+		LineItems: []entities.LineItem{
+			{Name: "Spore print", ProductID: 42, SKU: "SP-1", Quantity: 1, Total: entities.NewMoney(10, "PLN"),
+				MetaData: []entities.MetaData{{Key: "strain", Value: "B+"}}},
+		},
+		MetaData: []entities.MetaData{{Key: "_customer_ip", Value: "10.0.0.1"}},
+	}
+
+	policy := entities.DefaultAnonymizationPolicy()
+	policy.HashSalt = "salt"
+	policy.Fields["billing.first_name"] = entities.AnonymizationRule{Action: entities.AnonymizeKeep}
+	policy.Fields["billing.email"] = entities.AnonymizationRule{Action: entities.AnonymizeHash}
+	policy.Fields["billing.city"] = entities.AnonymizationRule{Action: entities.AnonymizeDrop}
+	policy.Fields[entities.FieldLineItemSKU] = entities.AnonymizationRule{Action: entities.AnonymizeKeep}
+	policy.Fields[entities.FieldLineItemMetaData] = entities.AnonymizationRule{Action: entities.AnonymizeKeep}
+	policy.Descriptors = []entities.ItemDescriptor{{Descriptor: "Print", ProductIDs: []int{42}}}
+	suite.Require().NoError(policy.Validate())
+
+	anonymous := order.ToAnonymousOrder(policy)
+	suite.Equal("Jan", anonymous.Billing.FirstName)
+	suite.Len(anonymous.Billing.Email, 16)
+	suite.Equal(anonymous.Billing.Email, order.ToAnonymousOrder(policy).Billing.Email, "hashes are stable")
+	suite.Empty(anonymous.Billing.City)
+	suite.Empty(anonymous.CustomerNote)
+	suite.Equal("Print 1", anonymous.LineItems[0].Name)
+	suite.Equal("SP-1", anonymous.LineItems[0].SKU)
+	suite.Zero(anonymous.LineItems[0].ProductID, "store product IDs are never copied")
+	suite.Equal([]entities.MetaData{{Key: "strain", Value: "B+"}}, anonymous.LineItems[0].MetaData)
+	suite.Equal([]entities.MetaData{
+		{Key: entities.MetaOriginalOrderNumber, Value: "1001"},
+		{Key: entities.MetaProxyOrder, Value: "true"},
+	}, anonymous.MetaData)
+
+	otherSalt := *policy
+	otherSalt.HashSalt = "other"
+	suite.NotEqual(anonymous.Billing.Email, order.ToAnonymousOrder(&otherSalt).Billing.Email)
+
+	anonymous.LineItems[0].MetaData[0].Value = "changed"
+	suite.Equal("B+", order.LineItems[0].MetaData[0].Value, "the original order is not modified")
+
+	defaults := order.ToAnonymousOrder(nil)
+	suite.Equal("Item 1", defaults.LineItems[0].Name)
+	suite.Empty(defaults.LineItems[0].SKU)
+	suite.Empty(defaults.LineItems[0].MetaData)
+	suite.Equal("PL", defaults.Billing.Country)
+}
+
+// TestInvalidPoliciesAreRejected tests policy validation
+func (suite *AnonymizationIntegrationTestSuite) TestInvalidPoliciesAreRejected() {
+	for name, policy := range map[string]string{
+		"unknown field":     `{"fields": {"billing.ssn": {"action": "drop"}}}`,
+		"unknown action":    `{"fields": {"billing.email": {"action": "encrypt"}}}`,
+		"empty replacement": `{"fields": {"billing.email": {"action": "replace"}}}`,
+		"hashed meta data":  `{"fields": {"meta_data": {"action": "hash"}}}`,
+		"hash without salt": `{"fields": {"billing.email": {"action": "hash"}}}`,
+		"unnamed category":  `{"descriptors": [{"product_ids": [1]}]}`,
+		"malformed json":    `{"fields": [}`,
+	} {
+		suite.cfg.Anonymization.PolicyFile = suite.writePolicy(policy)
+		suite.Error(suite.cfg.LoadAnonymizationPolicy(), name)
+	}
+
+	suite.cfg.Anonymization.PolicyFile = filepath.Join(suite.T().TempDir(), "missing.json")
+	suite.Error(suite.cfg.LoadAnonymizationPolicy())
+}
+
+// redirectAndFetchProxyOrder seeds a MagicSpore order, runs the redirect and
+// returns the raw OITAM order JSON
+func (suite *AnonymizationIntegrationTestSuite) redirectAndFetchProxyOrder() string {
+	orderID := suite.seedMagicOrder()
+
+	useCase := usecases.NewPaymentRedirectUseCase(
+		suite.repo,
+		repositories.NewMemoryOrderMappingRepository(suite.logger),
+		infraHttp.NewURLBuilder(suite.cfg, suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		nil,
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+	response, err := useCase.Execute(context.Background(), &dto.PaymentRedirectRequest{
+		OrderID: orderID,
+		Domain:  "magicspore.com",
+	})
+	suite.Require().NoError(err)
+	suite.Require().Equal("5000", response.ProxyOrderID)
+
+	req, err := http.NewRequest(http.MethodGet, suite.oitamServer.URL+"/wp-json/wc/v3/orders/5000", nil)
+	suite.Require().NoError(err)
+	req.SetBasicAuth("ck_oitam", "cs_oitam")
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	suite.Require().NoError(err)
+	return string(body)
+}
+
+// seedMagicOrder creates a MagicSpore order full of identifying details
+func (suite *AnonymizationIntegrationTestSuite) seedMagicOrder() string {
+	address := map[string]string{
+		"first_name": "Jan",
+		"last_name":  "Kowalski",
+		"address_1":  "Marszałkowska 1",
+		"city":       "Warszawa",
+		"postcode":   "00-001",
+		"country":    "PL",
+		"email":      "jan.kowalski@example.com",
+		"phone":      "+48 600 700 800",
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"currency":      "PLN",
+		"customer_note": "Leave at the door",
+		"billing":       address,
+		"shipping":      address,
+		"line_items": []map[string]interface{}{
+			{"name": "Golden Teacher Spore Syringe", "product_id": 42, "sku": "GT-SYR-10ML", "quantity": 2, "total": "59.98",
+				"meta_data": []map[string]string{{"key": "strain", "value": "Golden Teacher"}}},
+			{"name": "Psilocybe Cubensis Grow Kit", "product_id": 77, "sku": "KIT-CUBE-01", "quantity": 1, "total": "29.99"},
+		},
+		"shipping_lines": []map[string]string{{"method_id": "flat_rate", "method_title": "Courier", "total": "15.00"}},
+		"fee_lines":      []map[string]string{{"name": "Packaging", "total": "5.00"}},
+		"meta_data":      []map[string]string{{"key": "_customer_ip", "value": "10.0.0.1"}},
+	})
+	suite.Require().NoError(err)
+
+	req, err := http.NewRequest(http.MethodPost, suite.magicServer.URL+"/wp-json/wc/v3/orders", bytes.NewReader(payload))
+	suite.Require().NoError(err)
+	req.SetBasicAuth("ck_magic", "cs_magic")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	suite.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&created))
+	return strconv.Itoa(created.ID)
+}
+
+// writePolicy writes a policy file and returns its path
+func (suite *AnonymizationIntegrationTestSuite) writePolicy(policy string) string {
+	path := filepath.Join(suite.T().TempDir(), "policy.json")
+	suite.Require().NoError(os.WriteFile(path, []byte(policy), 0o600))
+	return path
+}
+
+// TestAnonymizationIntegrationTestSuite runs the anonymization suite
+func TestAnonymizationIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(AnonymizationIntegrationTestSuite))
+}
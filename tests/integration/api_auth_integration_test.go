@@ -0,0 +1,254 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/presentation/middleware"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+const testJWTSecret = "test-jwt-secret-at-least-32-characters"
+
+// APIAuthIntegrationTestSuite tests API key and JWT authentication of the API routes
+type APIAuthIntegrationTestSuite struct {
+	suite.Suite
+	logger  interfaces.Logger
+	apiKeys interfaces.Authenticator
+	jwt     interfaces.Authenticator
+	router  *gin.Engine
+}
+
+// SetupTest builds both authenticators and a router with scoped routes
+func (suite *APIAuthIntegrationTestSuite) SetupTest() {
+	gin.SetMode(gin.TestMode)
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	var err error
+	suite.apiKeys, err = infraHttp.NewAPIKeyAuthenticator("reader-key=read:orders, refunds-key=read:orders|write:refunds, admin-key=admin")
+	suite.Require().NoError(err)
+	suite.jwt, err = infraHttp.NewJWTAuthenticator(infraHttp.JWTConfig{
+		Secret:   testJWTSecret,
+		Issuer:   "https://auth.magicspore.com",
+		Audience: "paypal-proxy",
+	})
+	suite.Require().NoError(err)
+
+	apiAuth := middleware.NewAPIAuth([]interfaces.Authenticator{suite.apiKeys, suite.jwt}, suite.logger)
+	ok := func(c *gin.Context) {
+		principal := middleware.PrincipalFrom(c)
+		subject := ""
+		if principal != nil {
+			subject = principal.Subject
+		}
+		c.JSON(http.StatusOK, gin.H{"subject": subject})
+	}
+
+	suite.router = gin.New()
+	api := suite.router.Group("/api/v1")
+	api.Use(apiAuth.Authenticate())
+	api.GET("/health", ok)
+	api.GET("/order/:id", apiAuth.Require(entities.ScopeReadOrders), ok)
+	api.POST("/order/:id/refund", apiAuth.Require(entities.ScopeWriteRefunds), ok)
+	api.GET("/admin/disputes", apiAuth.Require(entities.ScopeAdmin), ok)
+}
+
+// TestAPIKeyScopes verifies that keys authenticate with their configured scopes
+func (suite *APIAuthIntegrationTestSuite) TestAPIKeyScopes() {
+	principal, err := suite.apiKeys.Authenticate(context.Background(), interfaces.APICredentials{APIKey: "refunds-key"})
+	suite.Require().NoError(err)
+	suite.Require().NotNil(principal)
+	suite.Equal(infraHttp.AuthMethodAPIKey, principal.Method)
+	suite.Contains(principal.Subject, "key_")
+	suite.NotContains(principal.Subject, "refunds-key", "the subject must not reveal the key")
+	suite.True(principal.HasScope(entities.ScopeWriteRefunds))
+	suite.False(principal.HasScope(entities.ScopeAdmin))
+
+	_, err = suite.apiKeys.Authenticate(context.Background(), interfaces.APICredentials{APIKey: "wrong-key"})
+	suite.True(errors.Is(err, infraHttp.ErrInvalidCredentials))
+
+	principal, err = suite.apiKeys.Authenticate(context.Background(), interfaces.APICredentials{BearerToken: "token"})
+	suite.NoError(err)
+	suite.Nil(principal, "requests without an API key are left to other authenticators")
+}
+
+// TestAPIKeyConfiguration verifies that malformed key lists are rejected
+func (suite *APIAuthIntegrationTestSuite) TestAPIKeyConfiguration() {
+	for _, entries := range []string{
+		"",
+		"no-scopes",
+		"key=",
+		"key=read:orders|delete:everything",
+		"key=read:orders,key=admin",
+		"=admin",
+	} {
+		_, err := infraHttp.NewAPIKeyAuthenticator(entries)
+		suite.Error(err, entries)
+	}
+}
+
+// TestJWTValidation verifies signature, algorithm and claim checks of bearer tokens
+func (suite *APIAuthIntegrationTestSuite) TestJWTValidation() {
+	valid := suite.claims(map[string]interface{}{"scope": "read:orders write:refunds"})
+	principal, err := suite.jwt.Authenticate(context.Background(), interfaces.APICredentials{
+		BearerToken: suite.signJWT("HS256", testJWTSecret, valid),
+	})
+	suite.Require().NoError(err)
+	suite.Require().NotNil(principal)
+	suite.Equal("ops-dashboard", principal.Subject)
+	suite.Equal(infraHttp.AuthMethodJWT, principal.Method)
+	suite.True(principal.HasScope(entities.ScopeWriteRefunds))
+	suite.False(principal.HasScope(entities.ScopeAdmin))
+
+	principal, err = suite.jwt.Authenticate(context.Background(), interfaces.APICredentials{
+		BearerToken: suite.signJWT("HS512", testJWTSecret, suite.claims(map[string]interface{}{
+			"scopes": []string{"admin"},
+			"aud":    []string{"other", "paypal-proxy"},
+		})),
+	})
+	suite.Require().NoError(err)
+	suite.True(principal.HasScope(entities.ScopeReadOrders), "admin grants every scope")
+
+	invalid := map[string]string{
+		"wrong secret":  suite.signJWT("HS256", "another-secret-of-at-least-32-chars", valid),
+		"expired":       suite.signJWT("HS256", testJWTSecret, suite.claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})),
+		"not yet valid": suite.signJWT("HS256", testJWTSecret, suite.claims(map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})),
+		"no expiry":     suite.signJWT("HS256", testJWTSecret, suite.claims(map[string]interface{}{"exp": nil})),
+		"wrong issuer":  suite.signJWT("HS256", testJWTSecret, suite.claims(map[string]interface{}{"iss": "https://evil.example"})),
+		"wrong aud":     suite.signJWT("HS256", testJWTSecret, suite.claims(map[string]interface{}{"aud": "other"})),
+		"unknown scope": suite.signJWT("HS256", testJWTSecret, suite.claims(map[string]interface{}{"scope": "root"})),
+		"alg none":      suite.signJWT("none", testJWTSecret, valid),
+		"malformed":     "not-a-token",
+	}
+	for name, token := range invalid {
+		_, err := suite.jwt.Authenticate(context.Background(), interfaces.APICredentials{BearerToken: token})
+		suite.True(errors.Is(err, infraHttp.ErrInvalidCredentials), name)
+	}
+}
+
+// TestRouteScopes verifies the responses of scoped routes for each kind of caller
+func (suite *APIAuthIntegrationTestSuite) TestRouteScopes() {
+	readerToken := suite.signJWT("HS256", testJWTSecret, suite.claims(map[string]interface{}{"scope": "read:orders"}))
+
+	testCases := []struct {
+		name         string
+		method       string
+		path         string
+		headers      map[string]string
+		expectedCode int
+	}{
+		{"health is public", "GET", "/api/v1/health", nil, http.StatusOK},
+		{"order without credentials", "GET", "/api/v1/order/1001", nil, http.StatusUnauthorized},
+		{"order with invalid key", "GET", "/api/v1/order/1001", map[string]string{"X-API-Key": "wrong-key"}, http.StatusUnauthorized},
+		{"order with reader key", "GET", "/api/v1/order/1001", map[string]string{"X-API-Key": "reader-key"}, http.StatusOK},
+		{"order with reader token", "GET", "/api/v1/order/1001", map[string]string{"Authorization": "Bearer " + readerToken}, http.StatusOK},
+		{"refund with reader key", "POST", "/api/v1/order/1001/refund", map[string]string{"X-API-Key": "reader-key"}, http.StatusForbidden},
+		{"refund with refunds key", "POST", "/api/v1/order/1001/refund", map[string]string{"X-API-Key": "refunds-key"}, http.StatusOK},
+		{"admin with refunds key", "GET", "/api/v1/admin/disputes", map[string]string{"X-API-Key": "refunds-key"}, http.StatusForbidden},
+		{"admin with admin key", "GET", "/api/v1/admin/disputes", map[string]string{"X-API-Key": "admin-key"}, http.StatusOK},
+		{"refund with admin key", "POST", "/api/v1/order/1001/refund", map[string]string{"X-API-Key": "admin-key"}, http.StatusOK},
+		{"health with invalid token", "GET", "/api/v1/health", map[string]string{"Authorization": "Bearer not-a-token"}, http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		suite.T().Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+			recorder := httptest.NewRecorder()
+			suite.router.ServeHTTP(recorder, req)
+
+			suite.Equal(tc.expectedCode, recorder.Code)
+			if tc.expectedCode == http.StatusUnauthorized {
+				suite.NotEmpty(recorder.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+// TestDisabledAuth verifies that a nil APIAuth rejects every request to a
+// scoped route, with or without credentials, and leaves unscoped routes open
+func (suite *APIAuthIntegrationTestSuite) TestDisabledAuth() {
+	var apiAuth *middleware.APIAuth
+	router := gin.New()
+	router.Use(apiAuth.Authenticate())
+	router.GET("/api/v1/admin/disputes", apiAuth.Require(entities.ScopeAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	router.GET("/api/v1/health", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/admin/disputes", nil))
+	suite.Equal(http.StatusUnauthorized, recorder.Code)
+
+	req := httptest.NewRequest("GET", "/api/v1/admin/disputes", nil)
+	req.Header.Set("X-API-Key", "admin-key")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+	suite.Equal(http.StatusUnauthorized, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/health", nil))
+	suite.Equal(http.StatusOK, recorder.Code)
+}
+
+// claims returns valid token claims with overrides applied; nil values remove a claim
+func (suite *APIAuthIntegrationTestSuite) claims(overrides map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": "ops-dashboard",
+		"iss": "https://auth.magicspore.com",
+		"aud": "paypal-proxy",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for name, value := range overrides {
+		if value == nil {
+			delete(claims, name)
+			continue
+		}
+		claims[name] = value
+	}
+	return claims
+}
+
+// signJWT builds a compact token; only HS256 and HS512 are signed properly,
+// other algorithms get an HS256 signature under their declared name
+func (suite *APIAuthIntegrationTestSuite) signJWT(alg, secret string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	suite.Require().NoError(err)
+	payload, err := json.Marshal(claims)
+	suite.Require().NoError(err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	newHash := sha256.New
+	if alg == "HS512" {
+		newHash = sha512.New
+	}
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// TestAPIAuthIntegrationTestSuite runs the API authentication suite
+func TestAPIAuthIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(APIAuthIntegrationTestSuite))
+}
@@ -0,0 +1,334 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	"paypal-proxy/internal/infrastructure/gateways"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// CurrencyConversionIntegrationTestSuite tests settling proxy orders in a
+// configured currency
+type CurrencyConversionIntegrationTestSuite struct {
+	suite.Suite
+	cfg         *config.Config
+	wooCommerce *fakeWooCommerce
+	gateway     *fakePaymentGateway
+	payments    interfaces.PaymentRepository
+	mappings    interfaces.OrderMappingRepository
+	logger      interfaces.Logger
+	currency    *services.CurrencyConversionService
+}
+
+// SetupTest settles in EUR with a static PLN/EUR rate
+func (suite *CurrencyConversionIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	suite.cfg = config.NewConfig()
+	suite.cfg.Proxy.OrderTTL = time.Hour
+	suite.cfg.Proxy.VerifyPollAttempts = 0
+	suite.cfg.Currency.SettlementCurrency = "EUR"
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.gateway = newFakePaymentGateway()
+	suite.payments = repositories.NewMemoryPaymentRepository(suite.logger)
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(suite.logger)
+
+	provider, err := gateways.NewStaticExchangeRateProvider("PLN/EUR=0.2325", "")
+	suite.Require().NoError(err)
+	suite.currency = services.NewCurrencyConversionService(provider, suite.logger)
+
+	suite.wooCommerce.addMagicOrder(1001, 49.99)
+}
+
+// TestStaticProviderParsesPairs tests inline FROM/TO=rate configuration
+func (suite *CurrencyConversionIntegrationTestSuite) TestStaticProviderParsesPairs() {
+	provider, err := gateways.NewStaticExchangeRateProvider(" PLN/EUR=0.2325, eur/jpy=162.5 ", "")
+	suite.Require().NoError(err)
+
+	rate, err := provider.GetRate(context.Background(), "EUR", "JPY")
+	suite.Require().NoError(err)
+	suite.Equal("162.5", rate.Rate)
+	suite.Equal("static", rate.Source)
+
+	_, err = provider.GetRate(context.Background(), "EUR", "PLN")
+	suite.Error(err, "inverse rates are not derived")
+
+	for _, pairs := range []string{"PLN/EUR", "PLNEUR=0.2", "PLN/EUR=abc", "PLN/EUR=0", "PLN/EUR=-1"} {
+		_, err := gateways.NewStaticExchangeRateProvider(pairs, "")
+		suite.Error(err, pairs)
+	}
+}
+
+// TestRatesFileOverridesInlinePairs tests loading rates from a JSON file
+func (suite *CurrencyConversionIntegrationTestSuite) TestRatesFileOverridesInlinePairs() {
+	path := filepath.Join(suite.T().TempDir(), "rates.json")
+	suite.Require().NoError(os.WriteFile(path, []byte(`{
+		"as_of": "2026-10-15T00:00:00Z",
+		"rates": {"PLN/EUR": 0.23251234, "PLN/USD": 0.2710}
+	}`), 0o600))
+
+	provider, err := gateways.NewStaticExchangeRateProvider("PLN/EUR=0.2325", path)
+	suite.Require().NoError(err)
+
+	rate, err := provider.GetRate(context.Background(), "PLN", "EUR")
+	suite.Require().NoError(err)
+	suite.Equal("0.23251234", rate.Rate, "the quoted rate is kept exactly")
+	suite.Equal("file", rate.Source)
+	suite.Equal(time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), rate.AsOf.UTC())
+
+	_, err = gateways.NewStaticExchangeRateProvider("", filepath.Join(suite.T().TempDir(), "missing.json"))
+	suite.Error(err)
+}
+
+// TestRemoteProviderCachesRates tests the HTTP rate API provider
+func (suite *CurrencyConversionIntegrationTestSuite) TestRemoteProviderCachesRates() {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		suite.Equal("/latest", r.URL.Path)
+		if r.URL.Query().Get("to") != "EUR" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"not found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"base":%q,"date":"2026-10-15","rates":{"EUR":0.23249876}}`, r.URL.Query().Get("from"))
+	}))
+	defer server.Close()
+
+	provider := gateways.NewRemoteExchangeRateProvider(gateways.RemoteExchangeRateConfig{
+		BaseURL:  server.URL + "/",
+		CacheTTL: time.Hour,
+	}, suite.logger)
+
+	for i := 0; i < 3; i++ {
+		rate, err := provider.GetRate(context.Background(), "pln", "eur")
+		suite.Require().NoError(err)
+		suite.Equal("PLN", rate.From)
+		suite.Equal("EUR", rate.To)
+		suite.Equal("0.23249876", rate.Rate)
+		suite.Equal(time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), rate.AsOf)
+		suite.Contains(server.URL, rate.Source)
+	}
+	suite.Equal(int32(1), atomic.LoadInt32(&requests), "rates are cached for the TTL")
+
+	_, err := provider.GetRate(context.Background(), "PLN", "USD")
+	suite.Error(err)
+}
+
+// TestConversionRescalesMinorUnits tests currencies with zero and three decimals
+func (suite *CurrencyConversionIntegrationTestSuite) TestConversionRescalesMinorUnits() {
+	provider, err := gateways.NewStaticExchangeRateProvider("EUR/JPY=162.5,EUR/KWD=0.3312,JPY/EUR=0.00615", "")
+	suite.Require().NoError(err)
+	service := services.NewCurrencyConversionService(provider, suite.logger)
+
+	conversion, err := service.Convert(context.Background(), entities.NewMoney(10.01, "EUR"), "JPY")
+	suite.Require().NoError(err)
+	suite.Equal("1627", conversion.Converted.Decimal(), "1626.625 JPY rounds half up to whole yen")
+
+	conversion, err = service.Convert(context.Background(), entities.NewMoney(10.01, "EUR"), "KWD")
+	suite.Require().NoError(err)
+	suite.Equal("3.315", conversion.Converted.Decimal())
+
+	conversion, err = service.Convert(context.Background(), entities.NewMoneyFromMinorUnits(1999, "JPY"), "EUR")
+	suite.Require().NoError(err)
+	suite.Equal("12.29", conversion.Converted.Decimal())
+
+	conversion, err = service.Convert(context.Background(), entities.NewMoney(10.01, "EUR"), "eur")
+	suite.Require().NoError(err)
+	suite.Equal("10.01", conversion.Converted.Decimal())
+	suite.Equal("identity", conversion.Rate.Source)
+}
+
+// TestConversionMetaDataRoundTrips tests reading a recorded conversion back
+func (suite *CurrencyConversionIntegrationTestSuite) TestConversionMetaDataRoundTrips() {
+	conversion, err := suite.currency.Convert(context.Background(), entities.NewMoney(49.99, "PLN"), "EUR")
+	suite.Require().NoError(err)
+
+	parsed, ok := entities.CurrencyConversionFromMetaData(conversion.MetaData())
+	suite.Require().True(ok)
+	suite.True(parsed.Original.Equals(conversion.Original))
+	suite.True(parsed.Converted.Equals(conversion.Converted))
+	suite.Equal("0.2325", parsed.Rate.Rate)
+
+	_, ok = entities.CurrencyConversionFromMetaData([]entities.MetaData{{Key: "_other", Value: "1"}})
+	suite.False(ok)
+}
+
+// TestRedirectCreatesProxyOrderInSettlementCurrency tests the converted proxy order
+func (suite *CurrencyConversionIntegrationTestSuite) TestRedirectCreatesProxyOrderInSettlementCurrency() {
+	proxyOrderID := suite.startCheckout(suite.newRedirectUseCase(suite.currency))
+
+	proxyOrder, err := suite.wooCommerce.GetOITAMOrder(context.Background(), proxyOrderID)
+	suite.Require().NoError(err)
+	suite.Equal("EUR", proxyOrder.Currency)
+	suite.Equal("11.62", proxyOrder.Total.Decimal())
+	suite.Require().Len(proxyOrder.LineItems, 1)
+	suite.Equal("11.62", proxyOrder.LineItems[0].Total.Decimal())
+	suite.Equal("EUR", proxyOrder.LineItems[0].Total.Currency)
+
+	magicOrder, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal("PLN", magicOrder.Currency)
+	suite.Equal("49.99", magicOrder.Total.Decimal())
+	suite.Equal("PLN", magicOrder.LineItems[0].Total.Currency, "the MagicSpore order lines are not converted")
+
+	for _, order := range []*entities.Order{proxyOrder, magicOrder} {
+		conversion, ok := entities.CurrencyConversionFromMetaData(order.MetaData)
+		suite.Require().True(ok)
+		suite.Equal("0.2325", conversion.Rate.Rate)
+		suite.Equal("static", conversion.Rate.Source)
+		suite.Equal("49.99 PLN", conversion.Original.String())
+		suite.Equal("11.62 EUR", conversion.Converted.String())
+	}
+}
+
+// TestRepeatedRedirectReusesConvertedProxyOrder tests reuse with a converted total
+func (suite *CurrencyConversionIntegrationTestSuite) TestRepeatedRedirectReusesConvertedProxyOrder() {
+	useCase := suite.newRedirectUseCase(suite.currency)
+
+	first := suite.startCheckout(useCase)
+	second := suite.startCheckout(useCase)
+
+	suite.Equal(first, second)
+	suite.Equal(1, suite.wooCommerce.oitamOrdersCreated())
+}
+
+// TestChangedSettlementCurrencyCreatesNewProxyOrder tests that a proxy order
+// in a previous settlement currency is not reused
+func (suite *CurrencyConversionIntegrationTestSuite) TestChangedSettlementCurrencyCreatesNewProxyOrder() {
+	suite.cfg.Currency.SettlementCurrency = ""
+	first := suite.startCheckout(suite.newRedirectUseCase(nil))
+
+	suite.cfg.Currency.SettlementCurrency = "EUR"
+	second := suite.startCheckout(suite.newRedirectUseCase(suite.currency))
+
+	suite.NotEqual(first, second)
+	proxyOrder, err := suite.wooCommerce.GetOITAMOrder(context.Background(), second)
+	suite.Require().NoError(err)
+	suite.Equal("EUR", proxyOrder.Currency)
+}
+
+// TestMissingRateFailsRedirect tests that no proxy order is created without a rate
+func (suite *CurrencyConversionIntegrationTestSuite) TestMissingRateFailsRedirect() {
+	suite.cfg.Currency.SettlementCurrency = "USD"
+
+	for _, currencyService := range []*services.CurrencyConversionService{suite.currency, nil} {
+		_, err := suite.newRedirectUseCase(currencyService).Execute(context.Background(), &dto.PaymentRedirectRequest{
+			OrderID: "1001",
+			Domain:  "magicspore.com",
+		})
+		suite.Error(err)
+	}
+	suite.Equal(0, suite.wooCommerce.oitamOrdersCreated())
+}
+
+// TestConvertedCaptureMarksOrderPaid tests verifying a capture in the settlement currency
+func (suite *CurrencyConversionIntegrationTestSuite) TestConvertedCaptureMarksOrderPaid() {
+	proxyOrderID := suite.startCheckout(suite.newRedirectUseCase(suite.currency))
+	suite.payProxyOrder(proxyOrderID, "CAPTURE-EUR")
+	suite.setCapture("CAPTURE-EUR", entities.NewMoney(11.62, "EUR"))
+
+	response, err := suite.newReturnUseCase().Execute(context.Background(), &dto.PaymentReturnRequest{OrderID: "1001"})
+	suite.Require().NoError(err)
+	suite.Equal("success", response.Status)
+
+	payment, err := suite.payments.GetByPaymentID(context.Background(), "CAPTURE-EUR")
+	suite.Require().NoError(err)
+	suite.Equal("11.62 EUR", payment.Amount.String())
+}
+
+// TestUnconvertedCaptureIsRejected tests that paying the original amount in
+// the settlement currency does not complete the order
+func (suite *CurrencyConversionIntegrationTestSuite) TestUnconvertedCaptureIsRejected() {
+	proxyOrderID := suite.startCheckout(suite.newRedirectUseCase(suite.currency))
+	suite.payProxyOrder(proxyOrderID, "CAPTURE-PLN")
+	suite.setCapture("CAPTURE-PLN", entities.NewMoney(49.99, "PLN"))
+
+	response, err := suite.newReturnUseCase().Execute(context.Background(), &dto.PaymentReturnRequest{OrderID: "1001"})
+	suite.Require().NoError(err)
+	suite.Equal("error", response.Status)
+
+	magicOrder, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusPending, magicOrder.Status)
+}
+
+func (suite *CurrencyConversionIntegrationTestSuite) newRedirectUseCase(currencyService *services.CurrencyConversionService) *usecases.PaymentRedirectUseCase {
+	return usecases.NewPaymentRedirectUseCase(
+		suite.wooCommerce,
+		suite.mappings,
+		infraHttp.NewURLBuilder(suite.cfg, suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		currencyService,
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+}
+
+func (suite *CurrencyConversionIntegrationTestSuite) newReturnUseCase() *usecases.PaymentReturnUseCase {
+	return usecases.NewPaymentReturnUseCase(
+		suite.wooCommerce,
+		suite.payments,
+		suite.mappings,
+		suite.gateway,
+		services.NewPaymentDomainService(suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+}
+
+func (suite *CurrencyConversionIntegrationTestSuite) startCheckout(useCase *usecases.PaymentRedirectUseCase) string {
+	response, err := useCase.Execute(context.Background(), &dto.PaymentRedirectRequest{
+		OrderID: "1001",
+		Domain:  "magicspore.com",
+	})
+	suite.Require().NoError(err)
+	return response.ProxyOrderID
+}
+
+// payProxyOrder marks the OITAM order paid without changing its total
+func (suite *CurrencyConversionIntegrationTestSuite) payProxyOrder(proxyOrderID, captureID string) {
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.oitamOrders, proxyOrderID, func(order *entities.Order) {
+		order.Status = entities.StatusProcessing
+		order.TransactionID = captureID
+	}))
+}
+
+// setCapture scripts a completed capture in any currency
+func (suite *CurrencyConversionIntegrationTestSuite) setCapture(captureID string, amount entities.Money) {
+	suite.gateway.mutex.Lock()
+	defer suite.gateway.mutex.Unlock()
+	suite.gateway.captures[captureID] = &entities.Payment{
+		PaymentID: captureID,
+		Status:    entities.PaymentStatusCompleted,
+		Amount:    amount,
+	}
+}
+
+// TestCurrencyConversionIntegrationTestSuite runs the currency conversion suite
+func TestCurrencyConversionIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(CurrencyConversionIntegrationTestSuite))
+}
@@ -0,0 +1,213 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// DisputeIntegrationTestSuite tests dispute tracking from the dispute webhooks
+type DisputeIntegrationTestSuite struct {
+	suite.Suite
+	logger      interfaces.Logger
+	wooCommerce *fakeWooCommerce
+	disputes    interfaces.DisputeRepository
+	mappings    interfaces.OrderMappingRepository
+	useCase     *usecases.WebhookUseCase
+	events      int
+}
+
+// SetupTest wires a fresh use case with processing MagicSpore order 1001 paid through OITAM order 5000
+func (suite *DisputeIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.disputes = repositories.NewMemoryDisputeRepository(suite.logger)
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(suite.logger)
+	suite.useCase = suite.newUseCase(suite.disputes)
+	suite.events = 0
+
+	suite.wooCommerce.addMagicOrder(1001, 49.99)
+	suite.wooCommerce.setMagicOrderStatus(1001, entities.StatusProcessing)
+	proxyOrder := &entities.Order{ID: 5000, OrderKey: "wc_order_5000"}
+	suite.Require().NoError(suite.mappings.Create(context.Background(), entities.NewOrderMapping("1001", proxyOrder, time.Hour)))
+}
+
+// TestOpenDisputesAreListedByDeadline tests that open disputes are stored and listed most urgent first
+func (suite *DisputeIntegrationTestSuite) TestOpenDisputesAreListedByDeadline() {
+	now := time.Now().UTC().Truncate(time.Second)
+	later := now.Add(10 * 24 * time.Hour)
+	sooner := now.Add(2 * 24 * time.Hour)
+
+	suite.dispute(entities.EventCustomerDisputeCreated, withTimes(disputeResource("PP-D-1", "OPEN", ""), now, &later))
+	suite.dispute(entities.EventCustomerDisputeCreated, withTimes(disputeResource("PP-D-2", "WAITING_FOR_SELLER_RESPONSE", ""), now, &sooner))
+	suite.dispute(entities.EventCustomerDisputeCreated, withTimes(disputeResource("PP-D-3", "UNDER_REVIEW", ""), now, nil))
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusOnHold, order.Status)
+
+	notes := suite.wooCommerce.notesFor("1001")
+	suite.Require().Len(notes, 3)
+	suite.Contains(notes[0], "Response due by "+later.Format("2006-01-02 15:04 MST")+".")
+	suite.NotContains(notes[2], "Response due by")
+
+	disputes, err := suite.useCase.OpenDisputes(context.Background())
+	suite.Require().NoError(err)
+	suite.Require().Len(disputes, 3)
+	suite.Equal("PP-D-2", disputes[0].ID, "The most urgent deadline should come first")
+	suite.Equal("PP-D-1", disputes[1].ID)
+	suite.Equal("PP-D-3", disputes[2].ID, "Disputes without a deadline should come last")
+
+	suite.Equal("1001", disputes[0].OrderID)
+	suite.Equal("5000", disputes[0].OITAMOrderID)
+	suite.Equal("CAPTURE-1", disputes[0].TransactionID)
+	suite.Equal("49.99", disputes[0].Amount)
+	suite.Equal("PLN", disputes[0].Currency)
+	suite.Require().NotNil(disputes[0].Deadline)
+	suite.True(disputes[0].Deadline.Equal(sooner))
+	suite.Nil(disputes[2].Deadline)
+}
+
+// TestWonDisputeWaitsForOtherOpenDisputes tests that the order stays on hold until its last dispute is resolved
+func (suite *DisputeIntegrationTestSuite) TestWonDisputeWaitsForOtherOpenDisputes() {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	suite.dispute(entities.EventCustomerDisputeCreated, withTimes(disputeResource("PP-D-1", "OPEN", ""), now, nil))
+	suite.dispute(entities.EventCustomerDisputeCreated, withTimes(disputeResource("PP-D-2", "OPEN", ""), now, nil))
+	suite.dispute(entities.EventCustomerDisputeResolved, withTimes(disputeResource("PP-D-1", "RESOLVED", "RESOLVED_SELLER_FAVOUR"), now.Add(time.Minute), nil))
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusOnHold, order.Status)
+	notes := suite.wooCommerce.notesFor("1001")
+	suite.Contains(notes[len(notes)-1], "Order stays on hold while 1 other dispute(s) are open.")
+
+	suite.dispute(entities.EventCustomerDisputeResolved, withTimes(disputeResource("PP-D-2", "RESOLVED", "RESOLVED_SELLER_FAVOUR"), now.Add(2*time.Minute), nil))
+
+	order, err = suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusProcessing, order.Status)
+
+	disputes, err := suite.useCase.OpenDisputes(context.Background())
+	suite.Require().NoError(err)
+	suite.Empty(disputes)
+
+	stored, err := suite.disputes.GetByID(context.Background(), "PP-D-1")
+	suite.Require().NoError(err)
+	suite.Equal("RESOLVED_SELLER_FAVOUR", stored.Outcome)
+	suite.Require().NotNil(stored.ResolvedAt)
+}
+
+// TestStaleUpdateDoesNotReopenDispute tests that a late update is noted without undoing the resolution
+func (suite *DisputeIntegrationTestSuite) TestStaleUpdateDoesNotReopenDispute() {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	suite.dispute(entities.EventCustomerDisputeCreated, withTimes(disputeResource("PP-D-1", "OPEN", ""), now, nil))
+	suite.dispute(entities.EventCustomerDisputeResolved, withTimes(disputeResource("PP-D-1", "RESOLVED", "RESOLVED_SELLER_FAVOUR"), now.Add(time.Hour), nil))
+	suite.dispute(entities.EventCustomerDisputeUpdated, withTimes(disputeResource("PP-D-1", "WAITING_FOR_SELLER_RESPONSE", ""), now.Add(time.Minute), nil))
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusProcessing, order.Status, "A stale update should not hold the order again")
+	suite.Len(suite.wooCommerce.notesFor("1001"), 3)
+
+	stored, err := suite.disputes.GetByID(context.Background(), "PP-D-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.DisputeStatusResolved, stored.Status)
+
+	disputes, err := suite.useCase.OpenDisputes(context.Background())
+	suite.Require().NoError(err)
+	suite.Empty(disputes)
+}
+
+// TestDisputeLookupErrorIsNotANewDispute tests that a failed lookup is
+// returned instead of overwriting the stored dispute with the report
+func (suite *DisputeIntegrationTestSuite) TestDisputeLookupErrorIsNotANewDispute() {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	suite.dispute(entities.EventCustomerDisputeCreated, withTimes(disputeResource("PP-D-1", "OPEN", ""), now, nil))
+
+	suite.useCase = suite.newUseCase(&unavailableDisputeRepository{DisputeRepository: suite.disputes})
+	_, err := suite.useCase.Execute(context.Background(), &dto.WebhookRequest{
+		ID:        "WH-LOOKUP",
+		EventType: entities.EventCustomerDisputeResolved,
+		Resource:  withTimes(disputeResource("PP-D-1", "RESOLVED", "RESOLVED_SELLER_FAVOUR"), now.Add(time.Hour), nil),
+	})
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "database unavailable")
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusOnHold, order.Status, "The order should stay on hold until the dispute can be read")
+
+	stored, err := suite.disputes.GetByID(context.Background(), "PP-D-1")
+	suite.Require().NoError(err)
+	suite.True(stored.IsOpen())
+}
+
+// newUseCase builds the webhook use case on disputes
+func (suite *DisputeIntegrationTestSuite) newUseCase(disputes interfaces.DisputeRepository) *usecases.WebhookUseCase {
+	return usecases.NewWebhookUseCase(
+		suite.wooCommerce,
+		repositories.NewMemoryPaymentRepository(suite.logger),
+		suite.mappings,
+		repositories.NewMemoryWebhookEventRepository(suite.logger),
+		disputes,
+		services.NewPaymentDomainService(suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		nil,
+		suite.logger,
+		config.NewConfig(),
+	)
+}
+
+// dispute delivers a dispute event and requires it to be processed
+func (suite *DisputeIntegrationTestSuite) dispute(eventType string, resource map[string]interface{}) {
+	suite.events++
+	response, err := suite.useCase.Execute(context.Background(), &dto.WebhookRequest{
+		ID:        "WH-" + strconv.Itoa(suite.events),
+		EventType: eventType,
+		Resource:  resource,
+	})
+	suite.Require().NoError(err)
+	suite.Equal("processed", response.Status)
+}
+
+// withTimes sets the update time and seller response deadline of a dispute resource
+func withTimes(resource map[string]interface{}, updatedAt time.Time, sellerResponseDueAt *time.Time) map[string]interface{} {
+	resource["create_time"] = updatedAt.Format(time.RFC3339)
+	resource["update_time"] = updatedAt.Format(time.RFC3339)
+	if sellerResponseDueAt != nil {
+		resource["seller_response_due_date"] = sellerResponseDueAt.Format(time.RFC3339)
+	}
+	return resource
+}
+
+// unavailableDisputeRepository fails every dispute lookup
+type unavailableDisputeRepository struct {
+	interfaces.DisputeRepository
+}
+
+func (r *unavailableDisputeRepository) GetByID(ctx context.Context, id string) (*entities.Dispute, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func TestDisputeIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(DisputeIntegrationTestSuite))
+}
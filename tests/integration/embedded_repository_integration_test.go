@@ -0,0 +1,680 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/infrastructure/database"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// EmbeddedRepositoryTestSuite runs the same persistence checks against the
+// in-memory and SQLite repositories. Neither needs external services.
+type EmbeddedRepositoryTestSuite struct {
+	suite.Suite
+	newRepositories func() embeddedRepositories
+}
+
+// embeddedRepositories groups the repositories sharing one store
+type embeddedRepositories struct {
+	payments interfaces.PaymentRepository
+	orders   interfaces.OrderRepository
+	mappings interfaces.OrderMappingRepository
+	events   interfaces.WebhookEventRepository
+	disputes interfaces.DisputeRepository
+	outbox   interfaces.OutboxRepository
+	timeline interfaces.OrderEventRepository
+}
+
+// TestPaymentLifecycle tests storing, querying and updating payments
+func (suite *EmbeddedRepositoryTestSuite) TestPaymentLifecycle() {
+	ctx := context.Background()
+	payments := suite.newRepositories().payments
+
+	first := &entities.Payment{
+		ID:        "pay_1",
+		OrderID:   "1001",
+		PaymentID: "PAYID-1",
+		Status:    entities.PaymentStatusPending,
+		Method:    entities.PaymentMethodPayPal,
+		Amount:    entities.NewMoney(25.50, "EUR"),
+	}
+	second := &entities.Payment{
+		ID:        "pay_2",
+		OrderID:   "1001",
+		PaymentID: "PAYID-1",
+		Status:    entities.PaymentStatusApproved,
+		Method:    entities.PaymentMethodPayPal,
+		Amount:    entities.NewMoney(25.50, "EUR"),
+		CreatedAt: time.Now().Add(time.Second),
+	}
+
+	suite.Require().NoError(payments.Create(ctx, first))
+	suite.Require().NoError(payments.Create(ctx, second))
+	suite.Error(payments.Create(ctx, first), "Duplicate IDs should be rejected")
+
+	// Mutating the caller's copy must not change the stored record
+	first.Status = entities.PaymentStatusFailed
+
+	byOrder, err := payments.GetByOrderID(ctx, "1001")
+	suite.Require().NoError(err)
+	suite.Require().Len(byOrder, 2)
+	suite.Equal("pay_1", byOrder[0].ID, "Payments should be returned oldest first")
+	suite.Equal(entities.PaymentStatusPending, byOrder[0].Status)
+
+	latest, err := payments.GetByPaymentID(ctx, "PAYID-1")
+	suite.Require().NoError(err)
+	suite.Equal("pay_2", latest.ID, "Lookup by PayPal ID should return the newest record")
+
+	suite.Require().NoError(payments.UpdateStatus(ctx, "PAYID-1", entities.PaymentStatusCompleted))
+	latest, err = payments.GetByPaymentID(ctx, "PAYID-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusCompleted, latest.Status)
+	suite.NotNil(latest.CompletedAt)
+
+	_, err = payments.GetByPaymentID(ctx, "PAYID-MISSING")
+	suite.Error(err)
+	suite.Error(payments.UpdateStatus(ctx, "PAYID-MISSING", entities.PaymentStatusFailed))
+}
+
+// TestStatusTransitionGuards tests that the repositories enforce the state machines
+func (suite *EmbeddedRepositoryTestSuite) TestStatusTransitionGuards() {
+	ctx := context.Background()
+	repos := suite.newRepositories()
+
+	suite.Require().NoError(repos.payments.Create(ctx, &entities.Payment{
+		ID:        "pay_guard",
+		OrderID:   "1001",
+		PaymentID: "PAYID-GUARD",
+		Status:    entities.PaymentStatusPending,
+	}))
+	suite.Require().NoError(repos.payments.UpdateStatus(ctx, "PAYID-GUARD", entities.PaymentStatusCompleted))
+
+	err := repos.payments.UpdateStatus(ctx, "PAYID-GUARD", entities.PaymentStatusFailed)
+	suite.Require().Error(err)
+	suite.True(errors.Is(err, entities.ErrInvalidStatusTransition))
+
+	payment, err := repos.payments.GetByPaymentID(ctx, "PAYID-GUARD")
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusCompleted, payment.Status)
+	suite.Require().Len(payment.StatusHistory, 1)
+	suite.Equal("pending", payment.StatusHistory[0].From)
+	suite.Equal("completed", payment.StatusHistory[0].To)
+
+	created, err := repos.orders.Create(ctx, &entities.Order{Status: entities.StatusPending, Currency: "PLN"})
+	suite.Require().NoError(err)
+	id := fmt.Sprintf("%d", created.ID)
+
+	suite.Require().NoError(repos.orders.UpdateStatus(ctx, id, entities.StatusCancelled))
+	err = repos.orders.UpdateStatus(ctx, id, entities.StatusCompleted)
+	suite.True(errors.Is(err, entities.ErrInvalidStatusTransition))
+
+	order, err := repos.orders.GetByID(ctx, id)
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusCancelled, order.Status)
+	suite.Require().Len(order.StatusHistory, 1)
+}
+
+// TestPaymentRefunds tests that refunds accumulate and never exceed the captured amount
+func (suite *EmbeddedRepositoryTestSuite) TestPaymentRefunds() {
+	ctx := context.Background()
+	payments := suite.newRepositories().payments
+
+	suite.Require().NoError(payments.Create(ctx, &entities.Payment{
+		ID:        "pay_refund",
+		OrderID:   "1001",
+		PaymentID: "CAPTURE-1",
+		Status:    entities.PaymentStatusCompleted,
+		Method:    entities.PaymentMethodPayPal,
+		Amount:    entities.NewMoney(100.00, "PLN"),
+	}))
+
+	refund := func(id string, amount entities.Money) entities.PaymentRefund {
+		return entities.PaymentRefund{ID: id, Amount: amount, Source: entities.RefundSourceAPI}
+	}
+
+	payment, err := payments.RecordRefund(ctx, "CAPTURE-1", refund("REFUND-1", entities.NewMoney(40.00, "PLN")))
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusCompleted, payment.Status)
+	suite.Equal("40.00", payment.RefundAmount.Decimal())
+
+	_, err = payments.RecordRefund(ctx, "CAPTURE-1", refund("REFUND-1", entities.NewMoney(40.00, "PLN")))
+	suite.True(errors.Is(err, entities.ErrDuplicateRefund), "A refund should only be recorded once")
+	_, err = payments.RecordRefund(ctx, "CAPTURE-1", refund("REFUND-2", entities.NewMoney(60.01, "PLN")))
+	suite.Error(err, "Refunds past the captured amount should be rejected")
+	_, err = payments.RecordRefund(ctx, "CAPTURE-1", refund("REFUND-2", entities.NewMoney(60.00, "EUR")))
+	suite.Error(err, "Refunds in another currency should be rejected")
+
+	payment, err = payments.RecordRefund(ctx, "CAPTURE-1", refund("REFUND-2", entities.NewMoney(60.00, "PLN")))
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusRefunded, payment.Status)
+
+	stored, err := payments.GetByPaymentID(ctx, "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusRefunded, stored.Status)
+	suite.Equal("100.00", stored.RefundAmount.Decimal())
+	suite.Require().Len(stored.Refunds, 2)
+	suite.Equal("REFUND-1", stored.Refunds[0].ID)
+	suite.Equal("40.00", stored.Refunds[0].Amount.Decimal())
+	suite.Equal("REFUND-2", stored.Refunds[1].ID)
+	suite.Require().Len(stored.StatusHistory, 1)
+	suite.Equal("refunded", stored.StatusHistory[0].To)
+
+	_, err = payments.RecordRefund(ctx, "CAPTURE-1", refund("REFUND-3", entities.NewMoney(1.00, "PLN")))
+	suite.True(errors.Is(err, entities.ErrInvalidStatusTransition), "Refunded payments should not be refunded again")
+
+	_, err = payments.RecordRefund(ctx, "CAPTURE-MISSING", refund("REFUND-4", entities.NewMoney(1.00, "PLN")))
+	suite.Error(err)
+}
+
+// TestOrderLifecycle tests creating and updating orders
+func (suite *EmbeddedRepositoryTestSuite) TestOrderLifecycle() {
+	ctx := context.Background()
+	orders := suite.newRepositories().orders
+
+	created, err := orders.Create(ctx, &entities.Order{
+		Number:   "MS-1",
+		Status:   entities.StatusPending,
+		Currency: "PLN",
+		Total:    entities.NewMoney(99.00, "PLN"),
+		LineItems: []entities.LineItem{
+			{Name: "Widget", Quantity: 1, Total: entities.NewMoney(99.00, "PLN")},
+		},
+	})
+	suite.Require().NoError(err)
+	suite.NotZero(created.ID, "Create should assign an order ID")
+
+	id := fmt.Sprintf("%d", created.ID)
+
+	suite.Require().NoError(orders.UpdateStatus(ctx, id, entities.StatusOnHold))
+	fetched, err := orders.GetByID(ctx, id)
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusOnHold, fetched.Status)
+	suite.Equal("MS-1", fetched.Number)
+	suite.Len(fetched.LineItems, 1)
+
+	payment := &entities.Payment{
+		PaymentID:     "PAYID-9",
+		TransactionID: "TXN-9",
+		Status:        entities.PaymentStatusCompleted,
+	}
+	suite.Require().NoError(orders.UpdatePaymentInfo(ctx, id, payment))
+
+	paid, err := orders.GetByID(ctx, id)
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusProcessing, paid.Status)
+	suite.Equal("TXN-9", paid.TransactionID)
+	suite.NotNil(paid.DatePaid)
+
+	_, err = orders.GetByID(ctx, "424242")
+	suite.Error(err)
+	suite.Contains(err.Error(), "not found")
+	suite.Error(orders.UpdateStatus(ctx, "not-a-number", entities.StatusFailed))
+}
+
+// TestOrderStatusHistory tests that an order's status history survives a round trip
+func (suite *EmbeddedRepositoryTestSuite) TestOrderStatusHistory() {
+	ctx := context.Background()
+	orders := suite.newRepositories().orders
+
+	created, err := orders.Create(ctx, &entities.Order{
+		Number:   "MS-2",
+		Status:   entities.StatusPending,
+		Currency: "PLN",
+		Total:    entities.NewMoney(49.99, "PLN"),
+	})
+	suite.Require().NoError(err)
+	suite.Empty(created.StatusHistory)
+
+	id := fmt.Sprintf("%d", created.ID)
+
+	suite.Require().NoError(orders.UpdateStatus(ctx, id, entities.StatusOnHold))
+	suite.Require().NoError(orders.UpdateStatus(ctx, id, entities.StatusProcessing))
+
+	fetched, err := orders.GetByID(ctx, id)
+	suite.Require().NoError(err)
+	suite.Require().Len(fetched.StatusHistory, 2)
+	suite.Equal(string(entities.StatusPending), fetched.StatusHistory[0].From)
+	suite.Equal(string(entities.StatusOnHold), fetched.StatusHistory[0].To)
+	suite.Equal(string(entities.StatusProcessing), fetched.StatusHistory[1].To)
+	suite.Equal("status update", fetched.StatusHistory[1].Reason)
+	suite.False(fetched.StatusHistory[1].At.IsZero())
+
+	// A full update keeps the history it is given
+	suite.Require().NoError(fetched.TransitionTo(entities.StatusCompleted, "shipped"))
+	suite.Require().NoError(orders.Update(ctx, id, fetched))
+
+	updated, err := orders.GetByID(ctx, id)
+	suite.Require().NoError(err)
+	suite.Require().Len(updated.StatusHistory, 3)
+	suite.Equal("shipped", updated.StatusHistory[2].Reason)
+	suite.True(fetched.StatusHistory[2].At.Equal(updated.StatusHistory[2].At))
+}
+
+// TestOrderMappingLifecycle tests linking proxy orders and resolving both sides
+func (suite *EmbeddedRepositoryTestSuite) TestOrderMappingLifecycle() {
+	ctx := context.Background()
+	mappings := suite.newRepositories().mappings
+
+	first := entities.NewOrderMapping("1001", &entities.Order{ID: 501, OrderKey: "wc_order_a"}, time.Hour)
+	suite.Require().NoError(mappings.Create(ctx, first))
+
+	// A second redirect for the same order creates a newer proxy order
+	second := entities.NewOrderMapping("1001", &entities.Order{ID: 502, OrderKey: "wc_order_b"}, time.Hour)
+	second.CreatedAt = second.CreatedAt.Add(time.Second)
+	suite.Require().NoError(mappings.Create(ctx, second))
+	suite.Error(mappings.Create(ctx, second), "Duplicate OITAM orders should be rejected")
+
+	latest, err := mappings.GetByMagicOrderID(ctx, "1001")
+	suite.Require().NoError(err)
+	suite.Equal("502", latest.OITAMOrderID, "Lookup by MagicSpore order should return the newest mapping")
+	suite.Equal("wc_order_b", latest.OrderKey)
+	suite.Equal(entities.MappingStateActive, latest.State)
+
+	byProxy, err := mappings.GetByOITAMOrderID(ctx, "501")
+	suite.Require().NoError(err)
+	suite.Equal("1001", byProxy.MagicOrderID)
+	suite.False(byProxy.IsExpired(time.Now()))
+	suite.True(byProxy.IsExpired(time.Now().Add(2 * time.Hour)))
+
+	suite.Require().NoError(mappings.UpdateState(ctx, "502", entities.MappingStatePaid))
+	paid, err := mappings.GetByOITAMOrderID(ctx, "502")
+	suite.Require().NoError(err)
+	suite.Equal(entities.MappingStatePaid, paid.State)
+	suite.True(paid.IsFinal())
+
+	_, err = mappings.GetByMagicOrderID(ctx, "9999")
+	suite.Error(err)
+	suite.Contains(err.Error(), "not found")
+	suite.Error(mappings.UpdateState(ctx, "9999", entities.MappingStateCancelled))
+}
+
+// TestOrderMappingListByState tests listing mappings by state and age
+func (suite *EmbeddedRepositoryTestSuite) TestOrderMappingListByState() {
+	ctx := context.Background()
+	mappings := suite.newRepositories().mappings
+
+	now := time.Now()
+	for i, age := range []time.Duration{time.Hour, 3 * time.Hour, 2 * time.Hour, time.Minute} {
+		mapping := entities.NewOrderMapping(fmt.Sprintf("%d", 1001+i), &entities.Order{ID: 501 + i}, time.Hour)
+		mapping.CreatedAt = now.Add(-age)
+		suite.Require().NoError(mappings.Create(ctx, mapping))
+	}
+	suite.Require().NoError(mappings.UpdateState(ctx, "501", entities.MappingStatePaid))
+
+	listed, err := mappings.ListByState(ctx, entities.MappingStateActive, now.Add(-30*time.Minute), nil, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(listed, 2, "Paid and recent mappings should be skipped")
+	suite.Equal("502", listed[0].OITAMOrderID, "Mappings should be listed oldest first")
+	suite.Equal("503", listed[1].OITAMOrderID)
+
+	listed, err = mappings.ListByState(ctx, entities.MappingStateActive, now, nil, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(listed, 1)
+	suite.Equal("502", listed[0].OITAMOrderID)
+
+	// Pages continue after the last mapping of the previous one
+	listed, err = mappings.ListByState(ctx, entities.MappingStateActive, now, listed[0], 2)
+	suite.Require().NoError(err)
+	suite.Require().Len(listed, 2)
+	suite.Equal("503", listed[0].OITAMOrderID)
+	suite.Equal("504", listed[1].OITAMOrderID)
+
+	listed, err = mappings.ListByState(ctx, entities.MappingStateActive, now, listed[1], 2)
+	suite.Require().NoError(err)
+	suite.Empty(listed)
+
+	// Mappings created at the same time are ordered by OITAM order ID
+	tied := entities.NewOrderMapping("1005", &entities.Order{ID: 505}, time.Hour)
+	tied.CreatedAt = now.Add(-2 * time.Hour)
+	suite.Require().NoError(mappings.Create(ctx, tied))
+	cursor, err := mappings.GetByOITAMOrderID(ctx, "503")
+	suite.Require().NoError(err)
+	listed, err = mappings.ListByState(ctx, entities.MappingStateActive, now, cursor, 1)
+	suite.Require().NoError(err)
+	suite.Require().Len(listed, 1)
+	suite.Equal("505", listed[0].OITAMOrderID)
+
+	listed, err = mappings.ListByState(ctx, entities.MappingStatePaid, now, nil, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(listed, 1)
+	suite.Equal("1001", listed[0].MagicOrderID)
+}
+
+// TestWebhookEventLifecycle tests deduplication, outcome updates and time range listing
+func (suite *EmbeddedRepositoryTestSuite) TestWebhookEventLifecycle() {
+	ctx := context.Background()
+	events := suite.newRepositories().events
+
+	first := entities.NewWebhookEvent("WH-1", "PAYMENT.CAPTURE.COMPLETED", []byte(`{"id":"WH-1"}`), entities.SignatureVerified)
+	second := entities.NewWebhookEvent("WH-2", "PAYMENT.CAPTURE.DENIED", []byte(`{"id":"WH-2"}`), entities.SignatureInvalid)
+	second.ReceivedAt = first.ReceivedAt.Add(time.Minute)
+
+	suite.Require().NoError(events.Create(ctx, first))
+	suite.Require().NoError(events.Create(ctx, second))
+	suite.ErrorIs(events.Create(ctx, first), entities.ErrDuplicateWebhookEvent)
+
+	first.RecordOutcome(entities.WebhookEventProcessed, "done")
+	suite.Require().NoError(events.Update(ctx, first))
+
+	stored, err := events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.WebhookEventProcessed, stored.Status)
+	suite.Equal(`{"id":"WH-1"}`, stored.Body)
+	suite.Equal(1, stored.Attempts)
+	suite.Require().NotNil(stored.ProcessedAt)
+
+	rejected, err := events.GetByID(ctx, "WH-2")
+	suite.Require().NoError(err)
+	suite.Equal(entities.WebhookEventRejected, rejected.Status)
+	suite.False(rejected.IsTrusted())
+
+	// A signed redelivery replaces the body stored with the bad signature
+	rejected.AcceptRedelivery(entities.NewWebhookEvent("WH-2", "PAYMENT.CAPTURE.COMPLETED", []byte(`{"id":"WH-2","signed":true}`), entities.SignatureVerified))
+	suite.Require().NoError(events.Update(ctx, rejected))
+	redelivered, err := events.GetByID(ctx, "WH-2")
+	suite.Require().NoError(err)
+	suite.Equal("PAYMENT.CAPTURE.COMPLETED", redelivered.EventType)
+	suite.Equal(`{"id":"WH-2","signed":true}`, redelivered.Body)
+	suite.True(redelivered.IsTrusted())
+
+	listed, err := events.ListByReceivedAt(ctx, first.ReceivedAt.Add(-time.Second), second.ReceivedAt.Add(time.Second))
+	suite.Require().NoError(err)
+	suite.Require().Len(listed, 2)
+	suite.Equal("WH-1", listed[0].ID, "Events should be listed oldest first")
+
+	listed, err = events.ListByReceivedAt(ctx, first.ReceivedAt, second.ReceivedAt)
+	suite.Require().NoError(err)
+	suite.Require().Len(listed, 1, "The upper bound should be exclusive")
+
+	_, err = events.GetByID(ctx, "WH-9")
+	suite.ErrorIs(err, interfaces.ErrNotFound)
+	suite.ErrorIs(events.Update(ctx, entities.NewWebhookEvent("WH-9", "X", nil, entities.SignatureSkipped)), interfaces.ErrNotFound)
+}
+
+// TestWebhookEventQueue tests due listing, claiming and status listing
+func (suite *EmbeddedRepositoryTestSuite) TestWebhookEventQueue() {
+	ctx := context.Background()
+	events := suite.newRepositories().events
+	now := time.Now().Round(0).Truncate(time.Microsecond)
+
+	due := entities.NewWebhookEvent("WH-1", "PAYMENT.CAPTURE.COMPLETED", []byte(`{}`), entities.SignatureVerified)
+	due.Enqueue(now.Add(-time.Second))
+	later := entities.NewWebhookEvent("WH-2", "PAYMENT.CAPTURE.COMPLETED", []byte(`{}`), entities.SignatureVerified)
+	later.Enqueue(now.Add(time.Hour))
+	dead := entities.NewWebhookEvent("WH-3", "PAYMENT.CAPTURE.COMPLETED", []byte(`{}`), entities.SignatureVerified)
+	dead.RecordOutcome(entities.WebhookEventDeadLetter, "gave up")
+
+	for _, event := range []*entities.WebhookEvent{due, later, dead} {
+		suite.Require().NoError(events.Create(ctx, event))
+	}
+
+	listed, err := events.ListDue(ctx, now, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(listed, 1)
+	suite.Equal("WH-1", listed[0].ID)
+
+	stale := *listed[0]
+	claimed, err := events.Claim(ctx, listed[0], now.Add(time.Minute))
+	suite.Require().NoError(err)
+	suite.True(claimed)
+	suite.True(listed[0].NextAttemptAt.Equal(now.Add(time.Minute)))
+
+	claimed, err = events.Claim(ctx, &stale, now.Add(time.Minute))
+	suite.Require().NoError(err)
+	suite.False(claimed, "A claim based on an outdated read should fail")
+
+	listed, err = events.ListDue(ctx, now, 10)
+	suite.Require().NoError(err)
+	suite.Empty(listed)
+
+	stored, err := events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.True(stored.NextAttemptAt.Equal(now.Add(time.Minute)))
+
+	deadLetters, err := events.ListByStatus(ctx, entities.WebhookEventDeadLetter, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(deadLetters, 1)
+	suite.Equal("WH-3", deadLetters[0].ID)
+	suite.Nil(deadLetters[0].NextAttemptAt)
+}
+
+// TestDisputeLifecycle tests storing, updating and listing disputes
+func (suite *EmbeddedRepositoryTestSuite) TestDisputeLifecycle() {
+	ctx := context.Background()
+	disputes := suite.newRepositories().disputes
+
+	createdAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	dueAt := createdAt.Add(10 * 24 * time.Hour)
+	first := &entities.Dispute{
+		ID:                  "PP-D-1",
+		OrderID:             "1001",
+		OITAMOrderID:        "5000",
+		Reason:              "MERCHANDISE_OR_SERVICE_NOT_RECEIVED",
+		Status:              entities.DisputeStatusOpen,
+		Amount:              entities.NewMoneyFromMinorUnits(4999, "PLN"),
+		SellerResponseDueAt: &dueAt,
+		CreatedAt:           createdAt,
+		UpdatedAt:           createdAt,
+	}
+	second := &entities.Dispute{
+		ID:        "PP-D-2",
+		OrderID:   "1001",
+		Status:    entities.DisputeStatusOpen,
+		Amount:    entities.NewMoneyFromMinorUnits(1000, "PLN"),
+		CreatedAt: createdAt.Add(time.Minute),
+		UpdatedAt: createdAt.Add(time.Minute),
+	}
+	suite.Require().NoError(disputes.Save(ctx, first))
+	suite.Require().NoError(disputes.Save(ctx, second))
+
+	stored, err := disputes.GetByID(ctx, "PP-D-1")
+	suite.Require().NoError(err)
+	suite.Equal("5000", stored.OITAMOrderID)
+	suite.True(stored.Amount.Equals(first.Amount))
+	suite.Require().NotNil(stored.Deadline())
+	suite.True(stored.Deadline().Equal(dueAt))
+
+	open, err := disputes.ListOpen(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(open, 2)
+	suite.Equal("PP-D-1", open[0].ID, "Open disputes should be listed oldest first")
+
+	// Saving again replaces the stored dispute
+	resolvedAt := createdAt.Add(time.Hour)
+	first.Status = entities.DisputeStatusResolved
+	first.Outcome = "RESOLVED_SELLER_FAVOUR"
+	first.UpdatedAt = resolvedAt
+	first.ResolvedAt = &resolvedAt
+	suite.Require().NoError(disputes.Save(ctx, first))
+
+	open, err = disputes.ListOpen(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(open, 1)
+	suite.Equal("PP-D-2", open[0].ID)
+
+	byOrder, err := disputes.ListByOrderID(ctx, "1001")
+	suite.Require().NoError(err)
+	suite.Require().Len(byOrder, 2)
+	suite.Equal("PP-D-1", byOrder[0].ID)
+	suite.False(byOrder[0].IsOpen())
+	suite.Equal("RESOLVED_SELLER_FAVOUR", byOrder[0].Outcome)
+
+	_, err = disputes.GetByID(ctx, "PP-D-9")
+	suite.Error(err)
+	suite.True(errors.Is(err, interfaces.ErrNotFound))
+	suite.Contains(err.Error(), "not found")
+}
+
+// TestOutboxLifecycle tests sequencing, per-order ordering and claiming of outbox entries
+func (suite *EmbeddedRepositoryTestSuite) TestOutboxLifecycle() {
+	ctx := context.Background()
+	outbox := suite.newRepositories().outbox
+
+	paid := entities.NewOutboxEntry("1001", entities.OutboxUpdatePayment, entities.OutboxPayload{
+		Payment: &entities.Payment{TransactionID: "CAPTURE-1", Status: entities.PaymentStatusCompleted},
+	})
+	note := entities.NewOutboxEntry("1001", entities.OutboxAddNote, entities.OutboxPayload{Note: "Paid with PayPal"})
+	other := entities.NewOutboxEntry("1002", entities.OutboxUpdateStatus, entities.OutboxPayload{Status: entities.StatusCancelled})
+	suite.Require().NoError(outbox.Create(ctx, paid))
+	suite.Require().NoError(outbox.Create(ctx, note))
+	suite.Require().NoError(outbox.Create(ctx, other))
+	suite.Less(paid.Sequence, note.Sequence)
+	suite.Less(note.Sequence, other.Sequence)
+
+	stored, err := outbox.GetByID(ctx, paid.ID)
+	suite.Require().NoError(err)
+	suite.Equal(paid.Sequence, stored.Sequence)
+	suite.Equal(entities.OutboxUpdatePayment, stored.Operation)
+	suite.Require().NotNil(stored.Payload.Payment)
+	suite.Equal("CAPTURE-1", stored.Payload.Payment.TransactionID)
+
+	// Only the head of each order's queue is due
+	now := time.Now().Add(time.Second)
+	due, err := outbox.ListDue(ctx, now, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(due, 2)
+	suite.Equal(paid.ID, due[0].ID)
+	suite.Equal(other.ID, due[1].ID)
+
+	// A claim only succeeds against the state it was read in
+	leaseUntil := now.Add(time.Minute).Round(0).Truncate(time.Microsecond)
+	claimed, err := outbox.Claim(ctx, due[0], leaseUntil)
+	suite.Require().NoError(err)
+	suite.True(claimed)
+	claimed, err = outbox.Claim(ctx, stored, leaseUntil)
+	suite.Require().NoError(err)
+	suite.False(claimed, "A stale copy should not claim the entry again")
+
+	// A failed head keeps holding back the rest of its order
+	due[0].RecordFailure("MagicSpore unavailable")
+	suite.Require().NoError(outbox.Update(ctx, due[0]))
+	due, err = outbox.ListDue(ctx, now, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(due, 1)
+	suite.Equal(other.ID, due[0].ID)
+
+	undelivered, err := outbox.ListUndelivered(ctx, now, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(undelivered, 3)
+	suite.Equal(entities.OutboxFailed, undelivered[0].Status)
+	suite.Equal("MagicSpore unavailable", undelivered[0].LastError)
+
+	// Delivering the head releases the next entry of the order
+	stored, err = outbox.GetByID(ctx, paid.ID)
+	suite.Require().NoError(err)
+	stored.RecordDelivery()
+	suite.Require().NoError(outbox.Update(ctx, stored))
+	due, err = outbox.ListDue(ctx, now, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(due, 2)
+	suite.Equal(note.ID, due[0].ID)
+
+	byOrder, err := outbox.ListByOrderID(ctx, "1001")
+	suite.Require().NoError(err)
+	suite.Require().Len(byOrder, 2)
+	suite.Equal(entities.OutboxDelivered, byOrder[0].Status)
+	suite.NotNil(byOrder[0].DeliveredAt)
+	suite.Nil(byOrder[0].NextAttemptAt)
+
+	_, err = outbox.GetByID(ctx, "obx_missing")
+	suite.ErrorIs(err, interfaces.ErrNotFound)
+	suite.ErrorIs(outbox.Update(ctx, entities.NewOutboxEntry("1003", entities.OutboxAddNote, entities.OutboxPayload{})), interfaces.ErrNotFound)
+}
+
+// TestOrderEventLifecycle tests appending to and reading order timelines
+func (suite *EmbeddedRepositoryTestSuite) TestOrderEventLifecycle() {
+	ctx := context.Background()
+	timeline := suite.newRepositories().timeline
+
+	requested := entities.NewOrderEvent("1001", entities.OrderEventRedirectRequested, "Payment redirect requested", map[string]string{
+		"domain": "magicspore.com",
+	})
+	other := entities.NewOrderEvent("1002", entities.OrderEventRedirectRequested, "Payment redirect requested", nil)
+	failed := entities.NewOrderEvent("1001", entities.OrderEventError, "Payment redirect failed", nil)
+	failed.Error = "failed to create payment order: OITAM unavailable"
+	// Appended out of time order; the timeline keeps append order
+	failed.OccurredAt = requested.OccurredAt.Add(-time.Second)
+
+	suite.Require().NoError(timeline.Append(ctx, requested))
+	suite.Require().NoError(timeline.Append(ctx, other))
+	suite.Require().NoError(timeline.Append(ctx, failed))
+	suite.Error(timeline.Append(ctx, requested), "Event IDs should be unique")
+
+	events, err := timeline.ListByOrderID(ctx, "1001")
+	suite.Require().NoError(err)
+	suite.Require().Len(events, 2)
+	suite.Equal(requested.ID, events[0].ID)
+	suite.Equal(entities.OrderEventRedirectRequested, events[0].Type)
+	suite.Equal("magicspore.com", events[0].Details["domain"])
+	suite.True(events[0].OccurredAt.Equal(requested.OccurredAt))
+	suite.Equal(failed.ID, events[1].ID)
+	suite.Equal(failed.Error, events[1].Error)
+	suite.Empty(events[1].Details)
+
+	// Returned events are copies
+	events[0].Details["domain"] = "changed"
+	events, err = timeline.ListByOrderID(ctx, "1001")
+	suite.Require().NoError(err)
+	suite.Equal("magicspore.com", events[0].Details["domain"])
+
+	events, err = timeline.ListByOrderID(ctx, "1003")
+	suite.Require().NoError(err)
+	suite.Empty(events)
+}
+
+// TestMemoryRepositories runs the suite against the in-memory repositories
+func TestMemoryRepositories(t *testing.T) {
+	logger := infraHttp.NewDefaultLogger("error")
+	suite.Run(t, &EmbeddedRepositoryTestSuite{
+		newRepositories: func() embeddedRepositories {
+			return embeddedRepositories{
+				payments: repositories.NewMemoryPaymentRepository(logger),
+				orders:   repositories.NewMemoryOrderRepository(logger),
+				mappings: repositories.NewMemoryOrderMappingRepository(logger),
+				events:   repositories.NewMemoryWebhookEventRepository(logger),
+				disputes: repositories.NewMemoryDisputeRepository(logger),
+				outbox:   repositories.NewMemoryOutboxRepository(logger),
+				timeline: repositories.NewMemoryOrderEventRepository(logger),
+			}
+		},
+	})
+}
+
+// TestSQLiteRepositories runs the suite against file-backed SQLite repositories
+func TestSQLiteRepositories(t *testing.T) {
+	logger := infraHttp.NewDefaultLogger("error")
+	suite.Run(t, &EmbeddedRepositoryTestSuite{
+		newRepositories: func() embeddedRepositories {
+			db, err := database.NewSQLiteDB(context.Background(), database.SQLiteConfig{
+				Path:        filepath.Join(t.TempDir(), "paypal-proxy.db"),
+				AutoMigrate: true,
+			}, logger)
+			if err != nil {
+				t.Fatalf("failed to open sqlite database: %v", err)
+			}
+			t.Cleanup(func() { db.Close() })
+
+			return embeddedRepositories{
+				payments: repositories.NewSQLitePaymentRepository(db, logger),
+				orders:   repositories.NewSQLiteOrderRepository(db, logger),
+				mappings: repositories.NewSQLiteOrderMappingRepository(db, logger),
+				events:   repositories.NewSQLiteWebhookEventRepository(db, logger),
+				disputes: repositories.NewSQLiteDisputeRepository(db, logger),
+				outbox:   repositories.NewSQLiteOutboxRepository(db, logger),
+				timeline: repositories.NewSQLiteOrderEventRepository(db, logger),
+			}
+		},
+	})
+}
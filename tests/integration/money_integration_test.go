@@ -0,0 +1,196 @@
+//go:build integration
+
+package integration
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"paypal-proxy/internal/domain/entities"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// MoneyIntegrationTestSuite tests exact money arithmetic in minor units
+type MoneyIntegrationTestSuite struct {
+	suite.Suite
+}
+
+// TestParseAndFormatUseCurrencyExponent tests lossless parsing for currencies
+// with zero, two and three minor unit digits
+func (suite *MoneyIntegrationTestSuite) TestParseAndFormatUseCurrencyExponent() {
+	tests := []struct {
+		value      string
+		currency   string
+		minorUnits int64
+		formatted  string
+	}{
+		{"49.99", "PLN", 4999, "49.99"},
+		{"0.10", "eur", 10, "0.10"},
+		{"5", "USD", 500, "5.00"},
+		{"5000", "JPY", 5000, "5000"},
+		{"1.250", "KWD", 1250, "1.250"},
+		{"0.005", "BHD", 5, "0.005"},
+		{"-12.30", "EUR", -1230, "-12.30"},
+		{"49.9900", "PLN", 4999, "49.99"},
+	}
+
+	for _, tt := range tests {
+		money, err := entities.FromWooCommerceFormat(tt.value, tt.currency)
+		suite.Require().NoError(err, tt.value)
+		suite.Equal(tt.minorUnits, money.MinorUnits, tt.value)
+		suite.Equal(tt.formatted, money.ToWooCommerceFormat(), tt.value)
+	}
+
+	for _, invalid := range []string{"", "abc", "1.2.3", "--1", "1e3", "1/3", "."} {
+		_, err := entities.ParseMoney(invalid, "EUR")
+		suite.Error(err, invalid)
+	}
+
+	_, err := entities.ParseMoney("10.5", "JPY")
+	suite.Error(err, "JPY has no minor unit")
+	_, err = entities.ParseMoney("49.999", "PLN")
+	suite.Error(err, "Extra significant digits should not be dropped silently")
+
+	rounded, err := entities.ParseMoneyRounded("40.650406504065", "EUR", entities.RoundHalfUp)
+	suite.Require().NoError(err)
+	suite.Equal(int64(4065), rounded.MinorUnits)
+
+	suite.Equal(2, entities.CurrencyExponent("unknown"))
+	suite.False(entities.IsKnownCurrency("XYZ"))
+	suite.True(entities.IsKnownCurrency("jpy"))
+}
+
+// TestNewMoneyIsExact tests that float amounts land on the intended minor unit
+func (suite *MoneyIntegrationTestSuite) TestNewMoneyIsExact() {
+	suite.Equal(int64(4999), entities.NewMoney(49.99, "PLN").MinorUnits)
+	suite.Equal(int64(30), entities.NewMoney(0.1+0.2, "EUR").MinorUnits)
+	suite.Equal(int64(1001), entities.NewMoney(10.005, "EUR").MinorUnits, "Halves round away from zero")
+	suite.Equal("PLN", entities.NewMoney(1, "pln").Currency)
+}
+
+// TestArithmetic tests addition, subtraction and comparison
+func (suite *MoneyIntegrationTestSuite) TestArithmetic() {
+	a := entities.NewMoney(0.10, "EUR")
+	b := entities.NewMoney(0.20, "EUR")
+
+	sum, err := a.Add(b)
+	suite.Require().NoError(err)
+	suite.True(sum.Equals(entities.NewMoney(0.30, "EUR")))
+
+	difference, err := a.Sub(b)
+	suite.Require().NoError(err)
+	suite.Equal("-0.10", difference.Decimal())
+	suite.True(difference.IsNegative())
+
+	cmp, err := a.Compare(b)
+	suite.Require().NoError(err)
+	suite.Equal(-1, cmp)
+
+	_, err = a.Add(entities.NewMoney(1, "USD"))
+	suite.Error(err)
+	_, err = a.Sub(entities.NewMoney(1, "USD"))
+	suite.Error(err)
+	_, err = a.Compare(entities.NewMoney(1, "USD"))
+	suite.Error(err)
+	suite.False(a.Equals(entities.NewMoney(0.10, "USD")))
+}
+
+// TestMultiplyRoundingModes tests each rounding mode on halves and negatives
+func (suite *MoneyIntegrationTestSuite) TestMultiplyRoundingModes() {
+	price := entities.NewMoneyFromMinorUnits(5, "EUR") // 0.05
+
+	tests := []struct {
+		mode     entities.RoundingMode
+		factor   float64
+		expected int64
+	}{
+		{entities.RoundHalfUp, 0.5, 3},
+		{entities.RoundHalfEven, 0.5, 2},
+		{entities.RoundDown, 0.5, 2},
+		{entities.RoundUp, 0.5, 3},
+		{entities.RoundHalfUp, -0.5, -3},
+		{entities.RoundHalfEven, -0.5, -2},
+		{entities.RoundDown, 0.59, 2},
+		{entities.RoundUp, 0.41, 3},
+		{entities.RoundHalfUp, 3, 15},
+	}
+
+	for _, tt := range tests {
+		suite.Equal(tt.expected, price.Multiply(tt.factor, tt.mode).MinorUnits, "mode %d factor %v", tt.mode, tt.factor)
+	}
+
+	// 1.15 * 1.00 must be 1.15, not 1.14 from the binary float
+	suite.Equal("1.15", entities.NewMoney(1, "EUR").Multiply(1.15, entities.RoundDown).Decimal())
+
+	third := entities.NewMoney(100, "EUR").MultiplyRat(big.NewRat(1, 3), entities.RoundHalfEven)
+	suite.Equal("33.33", third.Decimal())
+}
+
+// TestAllocateKeepsEveryMinorUnit tests proportional splits without loss
+func (suite *MoneyIntegrationTestSuite) TestAllocateKeepsEveryMinorUnit() {
+	total := entities.NewMoney(100, "EUR")
+
+	parts, err := total.Split(3)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"33.34", "33.33", "33.33"}, decimals(parts))
+
+	parts, err = entities.NewMoney(0.05, "EUR").Allocate(3, 7)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"0.02", "0.03"}, decimals(parts))
+
+	parts, err = entities.NewMoney(-0.05, "EUR").Split(2)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"-0.03", "-0.02"}, decimals(parts))
+
+	parts, err = entities.NewMoneyFromMinorUnits(7, "JPY").Allocate(0, 1, 1)
+	suite.Require().NoError(err)
+	suite.Equal([]string{"0", "4", "3"}, decimals(parts))
+
+	_, err = total.Allocate()
+	suite.Error(err)
+	_, err = total.Allocate(0, 0)
+	suite.Error(err)
+	_, err = total.Allocate(1, -1)
+	suite.Error(err)
+	_, err = total.Split(0)
+	suite.Error(err)
+}
+
+// TestJSONRoundTrip tests that money is stored as an exact decimal number and
+// that records written with float amounts still load
+func (suite *MoneyIntegrationTestSuite) TestJSONRoundTrip() {
+	encoded, err := json.Marshal(entities.NewMoney(1.25, "KWD"))
+	suite.Require().NoError(err)
+	suite.JSONEq(`{"amount":1.250,"currency":"KWD"}`, string(encoded))
+
+	var decoded entities.Money
+	suite.Require().NoError(json.Unmarshal(encoded, &decoded))
+	suite.Equal(int64(1250), decoded.MinorUnits)
+
+	suite.Require().NoError(json.Unmarshal([]byte(`{"currency":"PLN","amount":49.99}`), &decoded))
+	suite.Equal(int64(4999), decoded.MinorUnits)
+
+	suite.Require().NoError(json.Unmarshal([]byte(`{"amount":"5000","currency":"JPY"}`), &decoded))
+	suite.Equal(int64(5000), decoded.MinorUnits)
+
+	suite.Require().NoError(json.Unmarshal([]byte(`{"amount":0,"currency":""}`), &decoded))
+	suite.True(decoded.IsZero())
+
+	suite.Error(json.Unmarshal([]byte(`{"amount":1.5,"currency":"JPY"}`), &decoded))
+}
+
+// decimals formats money values for comparison
+func decimals(values []entities.Money) []string {
+	formatted := make([]string, len(values))
+	for i, value := range values {
+		formatted[i] = value.Decimal()
+	}
+	return formatted
+}
+
+// TestMoneyIntegrationTestSuite runs the money suite
+func TestMoneyIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(MoneyIntegrationTestSuite))
+}
@@ -0,0 +1,245 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	appServices "paypal-proxy/internal/application/services"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// OrderTimelineIntegrationTestSuite tests the audit timeline recorded along the payment flow
+type OrderTimelineIntegrationTestSuite struct {
+	suite.Suite
+	cfg        *config.Config
+	logger     interfaces.Logger
+	magicSpore *unavailableMagicSpore
+	mappings   interfaces.OrderMappingRepository
+	timeline   *usecases.OrderTimelineUseCase
+	redirect   *usecases.PaymentRedirectUseCase
+	returns    *usecases.PaymentReturnUseCase
+	webhook    *usecases.WebhookUseCase
+}
+
+// SetupTest wires the payment flow use cases around one timeline and MagicSpore order 1001
+func (suite *OrderTimelineIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	suite.cfg = config.NewConfig()
+	suite.cfg.Proxy.OrderTTL = time.Hour
+	suite.cfg.Proxy.VerifyPollAttempts = 0
+	suite.cfg.Webhook.RetryEnabled = false
+	suite.magicSpore = newUnavailableMagicSpore()
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(suite.logger)
+	suite.timeline = usecases.NewOrderTimelineUseCase(repositories.NewMemoryOrderEventRepository(suite.logger), suite.logger)
+
+	wooCommerce := appServices.NewTimelineWooCommerceRepository(suite.magicSpore, suite.timeline)
+	payments := repositories.NewMemoryPaymentRepository(suite.logger)
+	suite.redirect = usecases.NewPaymentRedirectUseCase(
+		wooCommerce,
+		suite.mappings,
+		infraHttp.NewURLBuilder(suite.cfg, suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		nil,
+		suite.timeline,
+		suite.logger,
+		suite.cfg,
+	)
+	suite.returns = usecases.NewPaymentReturnUseCase(
+		wooCommerce,
+		payments,
+		suite.mappings,
+		nil,
+		services.NewPaymentDomainService(suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		suite.timeline,
+		suite.logger,
+		suite.cfg,
+	)
+	suite.webhook = usecases.NewWebhookUseCase(
+		wooCommerce,
+		payments,
+		suite.mappings,
+		repositories.NewMemoryWebhookEventRepository(suite.logger),
+		repositories.NewMemoryDisputeRepository(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		suite.timeline,
+		suite.logger,
+		suite.cfg,
+	)
+
+	suite.magicSpore.addMagicOrder(1001, 49.99)
+}
+
+// TestPaymentFlowIsRecorded tests that each step from redirect to webhook lands on the timeline
+func (suite *OrderTimelineIntegrationTestSuite) TestPaymentFlowIsRecorded() {
+	ctx := context.Background()
+
+	redirect, err := suite.redirect.Execute(ctx, &dto.PaymentRedirectRequest{OrderID: "1001", Domain: "magicspore.com"})
+	suite.Require().NoError(err)
+	suite.payProxyOrder(redirect.ProxyOrderID, "CAPTURE-1")
+
+	response, err := suite.returns.Execute(ctx, &dto.PaymentReturnRequest{
+		OrderID:      "1001",
+		OITAMOrderID: redirect.ProxyOrderID,
+		PayerID:      "PAYER-1",
+	})
+	suite.Require().NoError(err)
+	suite.Equal("success", response.Status)
+
+	_, err = suite.webhook.Execute(ctx, &dto.WebhookRequest{
+		ID:        "WH-1",
+		EventType: entities.EventPaymentCaptureCompleted,
+		Resource:  captureResource("CAPTURE-1", "COMPLETED"),
+	})
+	suite.Require().NoError(err)
+
+	timeline := suite.timelineFor("1001")
+	suite.Equal(len(timeline.Events), timeline.Count)
+	suite.Require().True(len(timeline.Events) >= 6)
+	suite.Equal([]string{
+		string(entities.OrderEventRedirectRequested),
+		string(entities.OrderEventProxyOrderCreated),
+		string(entities.OrderEventCheckoutURLIssued),
+		string(entities.OrderEventReturnReceived),
+		string(entities.OrderEventMagicOrderUpdated),
+	}, eventTypes(timeline)[:5])
+	suite.Contains(eventTypes(timeline)[5:], string(entities.OrderEventWebhookReceived))
+	suite.NotContains(eventTypes(timeline), string(entities.OrderEventError))
+
+	events := timeline.Events
+	suite.Equal("magicspore.com", events[0].Details["domain"])
+	suite.Equal(redirect.ProxyOrderID, events[1].Details["oitam_order_id"])
+	suite.Equal("49.99", events[1].Details["total"])
+	suite.Equal(redirect.RedirectURL, events[2].Details["checkout_url"])
+	suite.Equal("false", events[2].Details["reused"])
+	suite.Equal(map[string]string{"oitam_order_id": redirect.ProxyOrderID, "payer_id": "PAYER-1"}, events[3].Details)
+	suite.Equal("CAPTURE-1", events[4].Details["transaction_id"])
+
+	for i := 1; i < len(events); i++ {
+		suite.False(events[i].OccurredAt.Before(events[i-1].OccurredAt), "Events should be listed in the order they happened")
+	}
+
+	webhook := events[len(events)-1]
+	for _, event := range events {
+		if event.Type == string(entities.OrderEventWebhookReceived) {
+			webhook = event
+		}
+	}
+	suite.Equal(entities.EventPaymentCaptureCompleted, webhook.Details["event_type"])
+	suite.Equal("WH-1", webhook.Details["webhook_id"])
+	suite.Equal("CAPTURE-1", webhook.Details["resource_id"])
+}
+
+// TestReusedCheckoutIsRecorded tests that a second redirect records the reused checkout URL
+func (suite *OrderTimelineIntegrationTestSuite) TestReusedCheckoutIsRecorded() {
+	ctx := context.Background()
+	request := &dto.PaymentRedirectRequest{OrderID: "1001", Domain: "magicspore.com"}
+
+	_, err := suite.redirect.Execute(ctx, request)
+	suite.Require().NoError(err)
+	_, err = suite.redirect.Execute(ctx, request)
+	suite.Require().NoError(err)
+
+	timeline := suite.timelineFor("1001")
+	suite.Equal([]string{
+		string(entities.OrderEventRedirectRequested),
+		string(entities.OrderEventProxyOrderCreated),
+		string(entities.OrderEventCheckoutURLIssued),
+		string(entities.OrderEventRedirectRequested),
+		string(entities.OrderEventCheckoutURLIssued),
+	}, eventTypes(timeline))
+	suite.Equal("true", timeline.Events[4].Details["reused"])
+}
+
+// TestFailedRedirectIsRecorded tests that a redirect failure ends with an error event
+func (suite *OrderTimelineIntegrationTestSuite) TestFailedRedirectIsRecorded() {
+	_, err := suite.redirect.Execute(context.Background(), &dto.PaymentRedirectRequest{OrderID: "9999", Domain: "magicspore.com"})
+	suite.Require().Error(err)
+
+	timeline := suite.timelineFor("9999")
+	suite.Equal([]string{
+		string(entities.OrderEventRedirectRequested),
+		string(entities.OrderEventError),
+	}, eventTypes(timeline))
+	suite.Equal(err.Error(), timeline.Events[1].Error)
+}
+
+// TestFailedMagicSporeWriteIsRecorded tests that a payment MagicSpore rejects shows up as an error
+func (suite *OrderTimelineIntegrationTestSuite) TestFailedMagicSporeWriteIsRecorded() {
+	ctx := context.Background()
+
+	redirect, err := suite.redirect.Execute(ctx, &dto.PaymentRedirectRequest{OrderID: "1001", Domain: "magicspore.com"})
+	suite.Require().NoError(err)
+	suite.payProxyOrder(redirect.ProxyOrderID, "CAPTURE-1")
+	suite.magicSpore.setUnavailable("1001", true)
+
+	_, err = suite.returns.Execute(ctx, &dto.PaymentReturnRequest{OrderID: "1001"})
+	suite.Require().NoError(err)
+
+	timeline := suite.timelineFor("1001")
+	types := eventTypes(timeline)
+	suite.NotContains(types, string(entities.OrderEventMagicOrderUpdated))
+	suite.Require().Contains(types, string(entities.OrderEventError))
+
+	var messages []string
+	for _, event := range timeline.Events {
+		if event.Type == string(entities.OrderEventError) {
+			messages = append(messages, event.Message)
+			suite.Contains(event.Error, "unavailable")
+		}
+	}
+	suite.Contains(messages, "Payment written to MagicSpore failed")
+}
+
+// TestUnknownOrderHasEmptyTimeline tests that an order without events returns an empty list
+func (suite *OrderTimelineIntegrationTestSuite) TestUnknownOrderHasEmptyTimeline() {
+	timeline := suite.timelineFor("4242")
+	suite.Equal("4242", timeline.OrderID)
+	suite.NotNil(timeline.Events)
+	suite.Empty(timeline.Events)
+	suite.Zero(timeline.Count)
+}
+
+// payProxyOrder marks the OITAM order paid the way WooCommerce PayPal does
+func (suite *OrderTimelineIntegrationTestSuite) payProxyOrder(proxyOrderID, captureID string) {
+	suite.Require().NoError(suite.magicSpore.update(suite.magicSpore.oitamOrders, proxyOrderID, func(order *entities.Order) {
+		order.Status = entities.StatusProcessing
+		order.TransactionID = captureID
+		order.Total = entities.NewMoney(49.99, "PLN")
+	}))
+}
+
+func (suite *OrderTimelineIntegrationTestSuite) timelineFor(orderID string) *dto.OrderTimelineResponse {
+	timeline, err := suite.timeline.Timeline(context.Background(), orderID)
+	suite.Require().NoError(err)
+	return timeline
+}
+
+// eventTypes lists the types of a timeline's events in order
+func eventTypes(timeline *dto.OrderTimelineResponse) []string {
+	types := make([]string, 0, len(timeline.Events))
+	for _, event := range timeline.Events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+// TestOrderTimelineIntegrationTestSuite runs the order timeline suite
+func TestOrderTimelineIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(OrderTimelineIntegrationTestSuite))
+}
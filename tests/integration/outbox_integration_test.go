@@ -0,0 +1,361 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	appServices "paypal-proxy/internal/application/services"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// OutboxIntegrationTestSuite tests the durable delivery of MagicSpore order updates
+type OutboxIntegrationTestSuite struct {
+	suite.Suite
+	cfg         *config.Config
+	logger      interfaces.Logger
+	magicSpore  *unavailableMagicSpore
+	outboxRepo  interfaces.OutboxRepository
+	outbox      *usecases.OutboxUseCase
+	wooCommerce interfaces.WooCommerceRepository
+	mappings    interfaces.OrderMappingRepository
+}
+
+// SetupTest wires an outbox in front of MagicSpore orders 1001 and 1002
+func (suite *OutboxIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	suite.cfg = config.NewConfig()
+	suite.cfg.Proxy.OrderTTL = time.Hour
+	suite.cfg.Proxy.VerifyPollAttempts = 0
+	suite.cfg.Outbox.Enabled = true
+	suite.cfg.Outbox.PollInterval = 5 * time.Millisecond
+	suite.cfg.Outbox.MaxAttempts = 3
+	suite.cfg.Outbox.RetryBaseDelay = time.Millisecond
+	suite.cfg.Outbox.RetryMaxDelay = 5 * time.Millisecond
+	suite.cfg.Outbox.StuckAfter = 0
+
+	suite.magicSpore = newUnavailableMagicSpore()
+	suite.outboxRepo = repositories.NewMemoryOutboxRepository(suite.logger)
+	suite.outbox = usecases.NewOutboxUseCase(suite.magicSpore, suite.outboxRepo, suite.logger, suite.cfg)
+	suite.wooCommerce = appServices.NewOutboxWooCommerceRepository(suite.magicSpore, suite.outbox)
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(suite.logger)
+
+	suite.magicSpore.addMagicOrder(1001, 49.99)
+	suite.magicSpore.addMagicOrder(1002, 19.99)
+}
+
+// TestUpdatesAreDeliveredRightAway tests that an available MagicSpore receives updates synchronously
+func (suite *OutboxIntegrationTestSuite) TestUpdatesAreDeliveredRightAway() {
+	ctx := context.Background()
+
+	suite.Require().NoError(suite.wooCommerce.UpdateMagicOrderPayment(ctx, "1001", &entities.Payment{
+		TransactionID: "CAPTURE-1",
+		Status:        entities.PaymentStatusCompleted,
+	}))
+	suite.Require().NoError(suite.wooCommerce.AddMagicOrderNote(ctx, "1001", "Paid with PayPal"))
+
+	order := suite.magicOrder("1001")
+	suite.Equal(entities.StatusProcessing, order.Status)
+	suite.Equal("CAPTURE-1", order.TransactionID)
+	suite.Equal([]string{"Paid with PayPal"}, suite.magicSpore.notesFor("1001"))
+
+	entries, err := suite.outboxRepo.ListByOrderID(ctx, "1001")
+	suite.Require().NoError(err)
+	suite.Require().Len(entries, 2)
+	for _, entry := range entries {
+		suite.Equal(entities.OutboxDelivered, entry.Status)
+		suite.Equal(1, entry.Attempts)
+	}
+	suite.Empty(suite.stuck())
+}
+
+// TestPaidStatusSurvivesMagicSporeOutage tests that a verified return is applied once MagicSpore is back
+func (suite *OutboxIntegrationTestSuite) TestPaidStatusSurvivesMagicSporeOutage() {
+	// Failed updates wait longer than the test runs, until they are retried
+	suite.cfg.Outbox.RetryBaseDelay = time.Hour
+	suite.cfg.Outbox.RetryMaxDelay = time.Hour
+	proxyOrderID := suite.startCheckout()
+	suite.Require().NoError(suite.magicSpore.update(suite.magicSpore.oitamOrders, proxyOrderID, func(order *entities.Order) {
+		order.Status = entities.StatusProcessing
+		order.TransactionID = "CAPTURE-1"
+		order.Total = entities.NewMoney(49.99, "PLN")
+	}))
+
+	suite.magicSpore.setUnavailable("1001", true)
+	returnUseCase := usecases.NewPaymentReturnUseCase(
+		suite.wooCommerce,
+		repositories.NewMemoryPaymentRepository(suite.logger),
+		suite.mappings,
+		nil,
+		services.NewPaymentDomainService(suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+	response, err := returnUseCase.Execute(context.Background(), &dto.PaymentReturnRequest{OrderID: "1001"})
+	suite.Require().NoError(err)
+	suite.Equal("success", response.Status)
+	suite.Equal(entities.StatusPending, suite.magicOrder("1001").Status)
+
+	stuck := suite.stuck()
+	suite.Require().Len(stuck, 2)
+	suite.Equal("1001", stuck[0].OrderID)
+	suite.Equal(string(entities.OutboxUpdatePayment), stuck[0].Operation)
+	suite.Equal(string(entities.OutboxPending), stuck[0].Status)
+	suite.Equal(1, stuck[0].Attempts, "Recording the status history does not retry the payment before it is due")
+	suite.Contains(stuck[0].LastError, "unavailable")
+	suite.Equal(string(entities.OutboxUpdateMetaData), stuck[1].Operation)
+	suite.Equal(0, stuck[1].Attempts, "The status history waits behind the payment")
+
+	suite.magicSpore.setUnavailable("1001", false)
+	retried, err := suite.outbox.Retry(context.Background(), stuck[0].ID)
+	suite.Require().NoError(err)
+	suite.Equal(string(entities.OutboxDelivered), retried.Status)
+	suite.Equal(1, retried.Attempts, "Retrying starts a fresh set of attempts")
+
+	order := suite.magicOrder("1001")
+	suite.Equal(entities.StatusProcessing, order.Status)
+	suite.Equal("CAPTURE-1", order.TransactionID)
+	_, recorded := order.MetaValue(entities.MetaStatusHistory)
+	suite.True(recorded, "The status history is delivered after the payment")
+	suite.Empty(suite.stuck())
+}
+
+// TestUpdatesAreDeliveredInOrderPerOrder tests that a failing update holds back only its own order
+func (suite *OutboxIntegrationTestSuite) TestUpdatesAreDeliveredInOrderPerOrder() {
+	ctx := context.Background()
+	suite.magicSpore.setUnavailable("1001", true)
+
+	suite.Require().NoError(suite.wooCommerce.UpdateMagicOrderStatus(ctx, "1001", entities.StatusProcessing))
+	suite.Require().NoError(suite.wooCommerce.AddMagicOrderNote(ctx, "1001", "Paid with PayPal"))
+	suite.Require().NoError(suite.wooCommerce.AddMagicOrderNote(ctx, "1002", "Other order"))
+	suite.Equal([]string{"Other order"}, suite.magicSpore.notesFor("1002"), "Other orders are not held back")
+	suite.Equal(1, suite.magicSpore.attemptsFor("1001"), "Later updates wait behind the failing one")
+
+	suite.exhaustAttempts()
+
+	stuck := suite.stuck()
+	suite.Require().Len(stuck, 2)
+	suite.Equal(string(entities.OutboxFailed), stuck[0].Status)
+	suite.Equal(3, stuck[0].Attempts)
+	suite.Equal(string(entities.OutboxAddNote), stuck[1].Operation)
+	suite.Equal(0, stuck[1].Attempts, "The note was never sent ahead of the status")
+	suite.Equal(3, suite.magicSpore.attemptsFor("1001"))
+
+	// A failed entry stays put once MagicSpore is back, until an operator retries it
+	suite.magicSpore.setUnavailable("1001", false)
+	suite.dispatch()
+	suite.Equal(entities.StatusPending, suite.magicOrder("1001").Status)
+
+	retried, err := suite.outbox.Retry(ctx, stuck[0].ID)
+	suite.Require().NoError(err)
+	suite.Equal(string(entities.OutboxDelivered), retried.Status)
+	suite.NotNil(retried.DeliveredAt)
+
+	suite.Equal(entities.StatusProcessing, suite.magicOrder("1001").Status)
+	suite.Equal([]string{"Paid with PayPal"}, suite.magicSpore.notesFor("1001"))
+	suite.Empty(suite.stuck())
+
+	_, err = suite.outbox.Retry(ctx, stuck[0].ID)
+	suite.Require().Error(err)
+	suite.ErrorIs(err, usecases.ErrOutboxEntryFinished)
+	suite.Contains(err.Error(), "is already delivered")
+}
+
+// TestDiscardReleasesLaterUpdates tests that discarding a failed update lets the rest of its order through
+func (suite *OutboxIntegrationTestSuite) TestDiscardReleasesLaterUpdates() {
+	ctx := context.Background()
+	suite.magicSpore.setUnavailable("1001", true)
+	suite.Require().NoError(suite.wooCommerce.UpdateMagicOrderStatus(ctx, "1001", entities.StatusCancelled))
+	suite.Require().NoError(suite.wooCommerce.AddMagicOrderNote(ctx, "1001", "Customer called"))
+	suite.exhaustAttempts()
+	suite.magicSpore.setUnavailable("1001", false)
+
+	stuck := suite.stuck()
+	suite.Require().Len(stuck, 2)
+
+	discarded, err := suite.outbox.Discard(ctx, stuck[0].ID)
+	suite.Require().NoError(err)
+	suite.Equal(string(entities.OutboxDiscarded), discarded.Status)
+
+	suite.dispatch()
+	suite.Equal(entities.StatusPending, suite.magicOrder("1001").Status, "The discarded update is never sent")
+	suite.Equal([]string{"Customer called"}, suite.magicSpore.notesFor("1001"))
+	suite.Empty(suite.stuck())
+
+	_, err = suite.outbox.Retry(ctx, stuck[0].ID)
+	suite.Require().Error(err)
+	suite.ErrorIs(err, usecases.ErrOutboxEntryFinished)
+	suite.Contains(err.Error(), "is already discarded")
+	_, err = suite.outbox.Discard(ctx, "obx_missing")
+	suite.ErrorIs(err, usecases.ErrOutboxEntryNotFound)
+}
+
+// TestDispatcherDeliversInBackground tests that the dispatcher retries updates without an operator
+func (suite *OutboxIntegrationTestSuite) TestDispatcherDeliversInBackground() {
+	suite.magicSpore.setUnavailable("1001", true)
+	suite.Require().NoError(suite.wooCommerce.UpdateMagicOrderPayment(context.Background(), "1001", &entities.Payment{
+		TransactionID: "CAPTURE-2",
+		Status:        entities.PaymentStatusCompleted,
+	}))
+	suite.magicSpore.setUnavailable("1001", false)
+
+	dispatcher := appServices.NewOutboxDispatcher(suite.outbox, suite.cfg, suite.logger)
+	dispatcher.Start(context.Background())
+	defer dispatcher.Stop()
+
+	suite.Eventually(func() bool {
+		order, err := suite.magicSpore.GetMagicOrder(context.Background(), "1001")
+		return err == nil && order.TransactionID == "CAPTURE-2"
+	}, 2*time.Second, 5*time.Millisecond)
+	suite.Equal(entities.StatusProcessing, suite.magicOrder("1001").Status)
+}
+
+// startCheckout redirects MagicSpore order 1001 and returns its proxy order ID
+func (suite *OutboxIntegrationTestSuite) startCheckout() string {
+	redirect := usecases.NewPaymentRedirectUseCase(
+		suite.wooCommerce,
+		suite.mappings,
+		infraHttp.NewURLBuilder(suite.cfg, suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		nil,
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+	response, err := redirect.Execute(context.Background(), &dto.PaymentRedirectRequest{
+		OrderID: "1001",
+		Domain:  "magicspore.com",
+	})
+	suite.Require().NoError(err)
+	return response.ProxyOrderID
+}
+
+// exhaustAttempts lets the dispatcher retry until the failing entries give up
+func (suite *OutboxIntegrationTestSuite) exhaustAttempts() {
+	for i := 0; i < suite.cfg.Outbox.MaxAttempts; i++ {
+		time.Sleep(2 * suite.cfg.Outbox.RetryMaxDelay)
+		suite.dispatch()
+	}
+}
+
+// dispatch delivers every entry that is due, as the dispatcher would
+func (suite *OutboxIntegrationTestSuite) dispatch() {
+	ctx := context.Background()
+	for {
+		entry, err := suite.outbox.ClaimNext(ctx, time.Minute)
+		suite.Require().NoError(err)
+		if entry == nil {
+			return
+		}
+		suite.Require().NoError(suite.outbox.Deliver(ctx, entry))
+	}
+}
+
+func (suite *OutboxIntegrationTestSuite) stuck() []dto.OutboxEntryResponse {
+	entries, err := suite.outbox.Stuck(context.Background(), 10)
+	suite.Require().NoError(err)
+	return entries
+}
+
+func (suite *OutboxIntegrationTestSuite) magicOrder(orderID string) *entities.Order {
+	order, err := suite.magicSpore.GetMagicOrder(context.Background(), orderID)
+	suite.Require().NoError(err)
+	return order
+}
+
+// TestOutboxIntegrationTestSuite runs the outbox suite
+func TestOutboxIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(OutboxIntegrationTestSuite))
+}
+
+// unavailableMagicSpore fails MagicSpore order updates of selected orders, as
+// during an outage, and counts the attempts
+type unavailableMagicSpore struct {
+	*fakeWooCommerce
+	outageMutex sync.Mutex
+	unavailable map[string]bool
+	attempts    map[string]int
+}
+
+func newUnavailableMagicSpore() *unavailableMagicSpore {
+	return &unavailableMagicSpore{
+		fakeWooCommerce: newFakeWooCommerce(),
+		unavailable:     make(map[string]bool),
+		attempts:        make(map[string]int),
+	}
+}
+
+func (m *unavailableMagicSpore) setUnavailable(orderID string, unavailable bool) {
+	m.outageMutex.Lock()
+	defer m.outageMutex.Unlock()
+	m.unavailable[orderID] = unavailable
+}
+
+func (m *unavailableMagicSpore) attemptsFor(orderID string) int {
+	m.outageMutex.Lock()
+	defer m.outageMutex.Unlock()
+	return m.attempts[orderID]
+}
+
+// attempt counts an update and fails it while the order is unavailable
+func (m *unavailableMagicSpore) attempt(orderID string) error {
+	m.outageMutex.Lock()
+	defer m.outageMutex.Unlock()
+	m.attempts[orderID]++
+	if m.unavailable[orderID] {
+		return errors.New("MagicSpore unavailable: 503 Service Unavailable")
+	}
+	return nil
+}
+
+func (m *unavailableMagicSpore) UpdateMagicOrderStatus(ctx context.Context, orderID string, status entities.OrderStatus) error {
+	if err := m.attempt(orderID); err != nil {
+		return err
+	}
+	return m.fakeWooCommerce.UpdateMagicOrderStatus(ctx, orderID, status)
+}
+
+func (m *unavailableMagicSpore) UpdateMagicOrderPayment(ctx context.Context, orderID string, payment *entities.Payment) error {
+	if err := m.attempt(orderID); err != nil {
+		return err
+	}
+	return m.fakeWooCommerce.UpdateMagicOrderPayment(ctx, orderID, payment)
+}
+
+func (m *unavailableMagicSpore) UpdateMagicOrderTransactionID(ctx context.Context, orderID string, transactionID string) error {
+	if err := m.attempt(orderID); err != nil {
+		return err
+	}
+	return m.fakeWooCommerce.UpdateMagicOrderTransactionID(ctx, orderID, transactionID)
+}
+
+func (m *unavailableMagicSpore) AddMagicOrderNote(ctx context.Context, orderID string, note string) error {
+	if err := m.attempt(orderID); err != nil {
+		return err
+	}
+	return m.fakeWooCommerce.AddMagicOrderNote(ctx, orderID, note)
+}
+
+func (m *unavailableMagicSpore) UpdateMagicOrderMetaData(ctx context.Context, orderID string, metaData []entities.MetaData) error {
+	if err := m.attempt(orderID); err != nil {
+		return err
+	}
+	return m.fakeWooCommerce.UpdateMagicOrderMetaData(ctx, orderID, metaData)
+}
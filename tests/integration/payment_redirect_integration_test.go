@@ -0,0 +1,361 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PaymentRedirectIntegrationTestSuite tests the redirect use case against
+// in-memory stores and a fake WooCommerce backend
+type PaymentRedirectIntegrationTestSuite struct {
+	suite.Suite
+	cfg         *config.Config
+	wooCommerce *fakeWooCommerce
+	mappings    interfaces.OrderMappingRepository
+	useCase     *usecases.PaymentRedirectUseCase
+}
+
+// SetupTest wires a fresh use case for every test
+func (suite *PaymentRedirectIntegrationTestSuite) SetupTest() {
+	logger := infraHttp.NewDefaultLogger("error")
+
+	suite.cfg = config.NewConfig()
+	suite.cfg.Proxy.OrderTTL = time.Hour
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(logger)
+	suite.useCase = usecases.NewPaymentRedirectUseCase(
+		suite.wooCommerce,
+		suite.mappings,
+		infraHttp.NewURLBuilder(suite.cfg, logger),
+		services.NewOrderDomainService(logger),
+		services.NewPaymentDomainService(logger),
+		nil,
+		nil,
+		logger,
+		suite.cfg,
+	)
+
+	suite.wooCommerce.addMagicOrder(1001, 49.99)
+}
+
+// TestRepeatedRedirectReusesProxyOrder tests that a refresh reuses the open proxy order
+func (suite *PaymentRedirectIntegrationTestSuite) TestRepeatedRedirectReusesProxyOrder() {
+	first := suite.redirect("1001")
+	suite.Equal("redirect_created", first.Status)
+
+	second := suite.redirect("1001")
+	suite.Equal("redirect_reused", second.Status)
+	suite.Equal(first.ProxyOrderID, second.ProxyOrderID)
+	suite.Equal(first.RedirectURL, second.RedirectURL)
+	suite.Equal(1, suite.wooCommerce.oitamOrdersCreated())
+}
+
+// TestConcurrentRedirectsCreateOneProxyOrder tests double-clicks arriving together
+func (suite *PaymentRedirectIntegrationTestSuite) TestConcurrentRedirectsCreateOneProxyOrder() {
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := suite.useCase.Execute(context.Background(), &dto.PaymentRedirectRequest{OrderID: "1001", Domain: "magicspore.com"})
+			suite.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	suite.Equal(1, suite.wooCommerce.oitamOrdersCreated())
+}
+
+// TestChangedTotalCreatesNewProxyOrder tests that a cart change is not paid at the old price
+func (suite *PaymentRedirectIntegrationTestSuite) TestChangedTotalCreatesNewProxyOrder() {
+	first := suite.redirect("1001")
+
+	suite.wooCommerce.addMagicOrder(1001, 59.99)
+	second := suite.redirect("1001")
+
+	suite.Equal("redirect_created", second.Status)
+	suite.NotEqual(first.ProxyOrderID, second.ProxyOrderID)
+
+	previous, err := suite.mappings.GetByOITAMOrderID(context.Background(), first.ProxyOrderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.MappingStateCancelled, previous.State)
+	suite.assertProxyOrderStatus(first.ProxyOrderID, entities.StatusCancelled)
+	suite.assertProxyOrderStatus(second.ProxyOrderID, entities.StatusPending)
+}
+
+// TestExpiredProxyOrderIsReplaced tests that expired proxy orders are not reused
+func (suite *PaymentRedirectIntegrationTestSuite) TestExpiredProxyOrderIsReplaced() {
+	suite.cfg.Proxy.OrderTTL = time.Nanosecond
+	first := suite.redirect("1001")
+
+	time.Sleep(time.Millisecond)
+	suite.cfg.Proxy.OrderTTL = time.Hour
+	second := suite.redirect("1001")
+
+	suite.Equal("redirect_created", second.Status)
+	suite.NotEqual(first.ProxyOrderID, second.ProxyOrderID)
+
+	previous, err := suite.mappings.GetByOITAMOrderID(context.Background(), first.ProxyOrderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.MappingStateExpired, previous.State)
+	suite.assertProxyOrderStatus(first.ProxyOrderID, entities.StatusCancelled)
+}
+
+// TestPaidProxyOrderIsNotCancelled tests that superseding a proxy order that
+// was paid in the meantime leaves it for reconciliation
+func (suite *PaymentRedirectIntegrationTestSuite) TestPaidProxyOrderIsNotCancelled() {
+	first := suite.redirect("1001")
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.oitamOrders, first.ProxyOrderID, func(order *entities.Order) {
+		order.Status = entities.StatusProcessing
+	}))
+
+	second := suite.redirect("1001")
+	suite.NotEqual(first.ProxyOrderID, second.ProxyOrderID)
+	suite.assertProxyOrderStatus(first.ProxyOrderID, entities.StatusProcessing)
+}
+
+// assertProxyOrderStatus requires an OITAM proxy order to have a status
+func (suite *PaymentRedirectIntegrationTestSuite) assertProxyOrderStatus(proxyOrderID string, status entities.OrderStatus) {
+	order, err := suite.wooCommerce.GetOITAMOrder(context.Background(), proxyOrderID)
+	suite.Require().NoError(err)
+	suite.Equal(status, order.Status, "proxy order %s", proxyOrderID)
+}
+
+// redirect runs the use case and requires it to succeed
+func (suite *PaymentRedirectIntegrationTestSuite) redirect(orderID string) *dto.PaymentRedirectResponse {
+	response, err := suite.useCase.Execute(context.Background(), &dto.PaymentRedirectRequest{
+		OrderID: orderID,
+		Domain:  "magicspore.com",
+	})
+	suite.Require().NoError(err)
+	return response
+}
+
+// TestPaymentRedirectIntegrationTestSuite runs the redirect suite
+func TestPaymentRedirectIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(PaymentRedirectIntegrationTestSuite))
+}
+
+// fakeWooCommerce is an in-process WooCommerceRepository for use case tests
+type fakeWooCommerce struct {
+	mutex       sync.Mutex
+	magicOrders map[string]*entities.Order
+	oitamOrders map[string]*entities.Order
+	magicNotes  map[string][]string
+	refunds     map[string][]entities.OrderRefund // keyed by store and order ID
+	nextOITAMID int
+}
+
+func newFakeWooCommerce() *fakeWooCommerce {
+	return &fakeWooCommerce{
+		magicOrders: make(map[string]*entities.Order),
+		oitamOrders: make(map[string]*entities.Order),
+		magicNotes:  make(map[string][]string),
+		refunds:     make(map[string][]entities.OrderRefund),
+		nextOITAMID: 5000,
+	}
+}
+
+// addMagicOrder stores a pending, payable MagicSpore order
+func (f *fakeWooCommerce) addMagicOrder(id int, total float64) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.magicOrders[strconv.Itoa(id)] = &entities.Order{
+		ID:       id,
+		Number:   strconv.Itoa(id),
+		Status:   entities.StatusPending,
+		OrderKey: fmt.Sprintf("wc_order_magic_%d", id),
+		Currency: "PLN",
+		Total:    entities.NewMoney(total, "PLN"),
+		LineItems: []entities.LineItem{
+			{Name: "Widget", Quantity: 1, Total: entities.NewMoney(total, "PLN")},
+		},
+	}
+}
+
+func (f *fakeWooCommerce) oitamOrdersCreated() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.oitamOrders)
+}
+
+// setMagicOrderStatus moves a MagicSpore order to a status directly
+func (f *fakeWooCommerce) setMagicOrderStatus(id int, status entities.OrderStatus) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.magicOrders[strconv.Itoa(id)].Status = status
+}
+
+// notesFor returns the notes added to a MagicSpore order
+func (f *fakeWooCommerce) notesFor(orderID string) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.magicNotes[orderID]...)
+}
+
+// refundsFor returns the refunds created on an order of the "magic" or "oitam" store
+func (f *fakeWooCommerce) refundsFor(store, orderID string) []entities.OrderRefund {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]entities.OrderRefund(nil), f.refunds[store+":"+orderID]...)
+}
+
+func (f *fakeWooCommerce) GetMagicOrder(ctx context.Context, orderID string) (*entities.Order, error) {
+	return f.get(f.magicOrders, orderID)
+}
+
+func (f *fakeWooCommerce) UpdateMagicOrder(ctx context.Context, orderID string, order *entities.Order) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.magicOrders[orderID] = order
+	return nil
+}
+
+func (f *fakeWooCommerce) UpdateMagicOrderStatus(ctx context.Context, orderID string, status entities.OrderStatus) error {
+	return f.update(f.magicOrders, orderID, func(order *entities.Order) { order.Status = status })
+}
+
+func (f *fakeWooCommerce) UpdateMagicOrderPayment(ctx context.Context, orderID string, payment *entities.Payment) error {
+	return f.update(f.magicOrders, orderID, func(order *entities.Order) {
+		order.TransactionID = payment.TransactionID
+		if payment.IsCompleted() {
+			order.Status = entities.StatusProcessing
+		}
+	})
+}
+
+func (f *fakeWooCommerce) UpdateMagicOrderTransactionID(ctx context.Context, orderID string, transactionID string) error {
+	return f.update(f.magicOrders, orderID, func(order *entities.Order) { order.TransactionID = transactionID })
+}
+
+func (f *fakeWooCommerce) UpdateMagicOrderMetaData(ctx context.Context, orderID string, metaData []entities.MetaData) error {
+	return f.update(f.magicOrders, orderID, func(order *entities.Order) {
+		// WooCommerce replaces existing keys and appends new ones
+		merged := append([]entities.MetaData{}, order.MetaData...)
+		for _, meta := range metaData {
+			replaced := false
+			for i := range merged {
+				if merged[i].Key == meta.Key {
+					merged[i].Value = meta.Value
+					replaced = true
+				}
+			}
+			if !replaced {
+				merged = append(merged, meta)
+			}
+		}
+		order.MetaData = merged
+	})
+}
+
+func (f *fakeWooCommerce) AddMagicOrderNote(ctx context.Context, orderID string, note string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, exists := f.magicOrders[orderID]; !exists {
+		return fmt.Errorf("order %s not found", orderID)
+	}
+	f.magicNotes[orderID] = append(f.magicNotes[orderID], note)
+	return nil
+}
+
+func (f *fakeWooCommerce) CreateMagicOrderRefund(ctx context.Context, orderID string, refund *entities.OrderRefund) (*entities.OrderRefund, error) {
+	return f.createRefund(f.magicOrders, "magic", orderID, refund)
+}
+
+func (f *fakeWooCommerce) CreateOITAMOrder(ctx context.Context, order *entities.Order) (*entities.Order, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	created := *order
+	created.ID = f.nextOITAMID
+	created.OrderKey = fmt.Sprintf("wc_order_%d", created.ID)
+	created.Status = entities.StatusPending
+	created.MetaData = append(append([]entities.MetaData{}, order.MetaData...), entities.MetaData{Key: "_proxy_created_at", Value: strconv.FormatInt(time.Now().Unix(), 10)})
+	f.nextOITAMID++
+	f.oitamOrders[strconv.Itoa(created.ID)] = &created
+
+	result := created
+	return &result, nil
+}
+
+func (f *fakeWooCommerce) GetOITAMOrder(ctx context.Context, orderID string) (*entities.Order, error) {
+	return f.get(f.oitamOrders, orderID)
+}
+
+func (f *fakeWooCommerce) UpdateOITAMOrder(ctx context.Context, orderID string, order *entities.Order) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.oitamOrders[orderID] = order
+	return nil
+}
+
+func (f *fakeWooCommerce) UpdateOITAMOrderStatus(ctx context.Context, orderID string, status entities.OrderStatus) error {
+	return f.update(f.oitamOrders, orderID, func(order *entities.Order) { order.Status = status })
+}
+
+func (f *fakeWooCommerce) TrashOITAMOrder(ctx context.Context, orderID string) error {
+	return f.update(f.oitamOrders, orderID, func(order *entities.Order) { order.Status = "trash" })
+}
+
+func (f *fakeWooCommerce) CreateOITAMOrderRefund(ctx context.Context, orderID string, refund *entities.OrderRefund) (*entities.OrderRefund, error) {
+	return f.createRefund(f.oitamOrders, "oitam", orderID, refund)
+}
+
+func (f *fakeWooCommerce) createRefund(orders map[string]*entities.Order, store, orderID string, refund *entities.OrderRefund) (*entities.OrderRefund, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if _, exists := orders[orderID]; !exists {
+		return nil, fmt.Errorf("order %s not found", orderID)
+	}
+	created := *refund
+	created.ID = len(f.refunds[store+":"+orderID]) + 1
+	created.DateCreated = time.Now()
+	f.refunds[store+":"+orderID] = append(f.refunds[store+":"+orderID], created)
+
+	result := created
+	return &result, nil
+}
+
+func (f *fakeWooCommerce) get(orders map[string]*entities.Order, orderID string) (*entities.Order, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	order, exists := orders[orderID]
+	if !exists {
+		return nil, fmt.Errorf("order %s not found", orderID)
+	}
+	result := *order
+	return &result, nil
+}
+
+func (f *fakeWooCommerce) update(orders map[string]*entities.Order, orderID string, fn func(*entities.Order)) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	order, exists := orders[orderID]
+	if !exists {
+		return fmt.Errorf("order %s not found", orderID)
+	}
+	fn(order)
+	return nil
+}
@@ -0,0 +1,479 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PaymentRefundIntegrationTestSuite tests full and partial refunds across
+// PayPal, both WooCommerce stores and the payment records
+type PaymentRefundIntegrationTestSuite struct {
+	suite.Suite
+	wooCommerce *fakeWooCommerce
+	gateway     *fakePaymentGateway
+	payments    interfaces.PaymentRepository
+	mappings    interfaces.OrderMappingRepository
+	refund      *usecases.PaymentRefundUseCase
+	webhook     *usecases.WebhookUseCase
+	logger      interfaces.Logger
+}
+
+// SetupTest wires fresh stores and a refund use case with a payment gateway
+func (suite *PaymentRefundIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.gateway = newFakePaymentGateway()
+	suite.payments = repositories.NewMemoryPaymentRepository(suite.logger)
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(suite.logger)
+	suite.refund = suite.newRefundUseCase(suite.gateway)
+	suite.webhook = usecases.NewWebhookUseCase(
+		suite.wooCommerce,
+		suite.payments,
+		suite.mappings,
+		repositories.NewMemoryWebhookEventRepository(suite.logger),
+		repositories.NewMemoryDisputeRepository(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		nil,
+		suite.logger,
+		config.NewConfig(),
+	)
+}
+
+// TestFullRefund tests that refunding without an amount returns the whole capture
+func (suite *PaymentRefundIntegrationTestSuite) TestFullRefund() {
+	proxyOrderID := suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	response, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001"})
+	suite.Require().NoError(err)
+
+	suite.Equal("refunded", response.Status)
+	suite.Equal("100.00", response.Amount)
+	suite.Equal("100.00", response.RefundedTotal)
+	suite.Equal("0.00", response.RefundableAmount)
+	suite.Equal(proxyOrderID, response.OITAMOrderID)
+	suite.Empty(response.Warnings)
+
+	suite.Equal([]entities.Money{entities.NewMoney(100.00, "PLN")}, suite.gateway.refundsFor("CAPTURE-1"))
+	suite.Require().Len(suite.wooCommerce.refundsFor("magic", "1001"), 1)
+	suite.Equal("100.00", suite.wooCommerce.refundsFor("magic", "1001")[0].Amount.Decimal())
+	suite.Require().Len(suite.wooCommerce.refundsFor("oitam", proxyOrderID), 1)
+	suite.Equal("100.00", suite.wooCommerce.refundsFor("oitam", proxyOrderID)[0].Amount.Decimal())
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusRefunded, order.Status)
+
+	mapping, err := suite.mappings.GetByOITAMOrderID(context.Background(), proxyOrderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.MappingStateRefunded, mapping.State)
+
+	payment, err := suite.payments.GetByPaymentID(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusRefunded, payment.Status)
+	suite.Require().NotNil(payment.RefundAmount)
+	suite.Equal("100.00", payment.RefundAmount.Decimal())
+
+	notes := suite.wooCommerce.notesFor("1001")
+	suite.Require().Len(notes, 1)
+	suite.Contains(notes[0], "Refunded 100.00 PLN through PayPal (capture CAPTURE-1).")
+}
+
+// TestPartialRefundsAccumulate tests that partial refunds add up to a full refund
+func (suite *PaymentRefundIntegrationTestSuite) TestPartialRefundsAccumulate() {
+	suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	first, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: "30.00"})
+	suite.Require().NoError(err)
+	suite.Equal("partially_refunded", first.Status)
+	suite.Equal("30.00", first.RefundedTotal)
+	suite.Equal("70.00", first.RefundableAmount)
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusProcessing, order.Status)
+	suite.Contains(suite.wooCommerce.notesFor("1001")[0], "70.00 PLN of the capture remains refundable.")
+
+	second, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: "70.00"})
+	suite.Require().NoError(err)
+	suite.Equal("refunded", second.Status)
+	suite.Equal("100.00", second.RefundedTotal)
+
+	suite.Equal([]entities.Money{entities.NewMoney(30.00, "PLN"), entities.NewMoney(70.00, "PLN")}, suite.gateway.refundsFor("CAPTURE-1"))
+	suite.Len(suite.wooCommerce.refundsFor("magic", "1001"), 2)
+
+	order, err = suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusRefunded, order.Status)
+}
+
+// TestRefundBeyondRemainingIsRejected tests that nothing is refunded past the capture
+func (suite *PaymentRefundIntegrationTestSuite) TestRefundBeyondRemainingIsRejected() {
+	suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	_, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: "30.00"})
+	suite.Require().NoError(err)
+
+	_, err = suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: "80.00"})
+	var validationErr *entities.ValidationError
+	suite.Require().True(errors.As(err, &validationErr), "expected validation error, got %v", err)
+	suite.Contains(err.Error(), "exceeds refundable 70.00 PLN")
+
+	suite.Len(suite.gateway.refundsFor("CAPTURE-1"), 1)
+	suite.Len(suite.wooCommerce.refundsFor("magic", "1001"), 1)
+}
+
+// TestInvalidAmountIsRejected tests amounts that cannot be refunded
+func (suite *PaymentRefundIntegrationTestSuite) TestInvalidAmountIsRejected() {
+	suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	for _, amount := range []string{"0", "-5.00", "1.005", "ten"} {
+		_, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: amount})
+		var validationErr *entities.ValidationError
+		suite.True(errors.As(err, &validationErr), "amount %q: expected validation error, got %v", amount, err)
+	}
+
+	suite.Empty(suite.gateway.refundsFor("CAPTURE-1"))
+}
+
+// TestRefundedPaymentCannotBeRefundedAgain tests that a fully refunded order has no payment left to refund
+func (suite *PaymentRefundIntegrationTestSuite) TestRefundedPaymentCannotBeRefundedAgain() {
+	suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	_, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001"})
+	suite.Require().NoError(err)
+
+	_, err = suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001"})
+	suite.ErrorIs(err, usecases.ErrNoRefundablePayment)
+	suite.Len(suite.gateway.refundsFor("CAPTURE-1"), 1)
+}
+
+// TestUnpaidOrderIsNotRefunded tests orders without a completed payment
+func (suite *PaymentRefundIntegrationTestSuite) TestUnpaidOrderIsNotRefunded() {
+	suite.wooCommerce.addMagicOrder(1002, 100.00)
+
+	_, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1002"})
+	suite.ErrorIs(err, usecases.ErrNoRefundablePayment)
+	suite.Equal("order 1002: no completed payment to refund", err.Error())
+}
+
+// TestConcurrentPartialRefundsStayWithinTheCapture tests that parallel
+// refunds of one order see each other's effect on the refundable balance
+func (suite *PaymentRefundIntegrationTestSuite) TestConcurrentPartialRefundsStayWithinTheCapture() {
+	suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := 0
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: "40.00"})
+			if err == nil {
+				mutex.Lock()
+				succeeded++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	suite.Equal(2, succeeded, "Only two 40.00 refunds fit in the 100.00 capture")
+	suite.Len(suite.gateway.refundsFor("CAPTURE-1"), 2)
+	payment, err := suite.payments.GetByPaymentID(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal("80.00", payment.RefundedAmount().Decimal())
+}
+
+// TestRetriedRefundIsMadeOnce tests that a request retried with the same
+// idempotency key is refunded by PayPal once
+func (suite *PaymentRefundIntegrationTestSuite) TestRetriedRefundIsMadeOnce() {
+	suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+	request := dto.PaymentRefundRequest{OrderID: "1001", Amount: "30.00", IdempotencyKey: "refund-request-1"}
+
+	first, err := suite.refund.Execute(context.Background(), &request)
+	suite.Require().NoError(err)
+	retried := request
+	retry, err := suite.refund.Execute(context.Background(), &retried)
+	suite.Require().NoError(err)
+
+	suite.Equal(first.RefundID, retry.RefundID)
+	suite.Len(suite.gateway.refundsFor("CAPTURE-1"), 1)
+	suite.Equal("30.00", retry.RefundedTotal)
+	suite.Len(suite.wooCommerce.refundsFor("magic", "1001"), 1, "The retry is not recorded on the order again")
+
+	// A new request for the same amount is a second refund
+	second, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: "30.00", IdempotencyKey: "refund-request-2"})
+	suite.Require().NoError(err)
+	suite.NotEqual(first.RefundID, second.RefundID)
+	suite.Equal("60.00", second.RefundedTotal)
+}
+
+// TestRefundNeedsPaymentGateway tests that refunds are refused without PayPal credentials
+func (suite *PaymentRefundIntegrationTestSuite) TestRefundNeedsPaymentGateway() {
+	suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	_, err := suite.newRefundUseCase(nil).Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001"})
+	suite.ErrorIs(err, usecases.ErrRefundsUnavailable)
+	suite.Empty(suite.wooCommerce.refundsFor("magic", "1001"))
+}
+
+// TestGatewayFailureChangesNothing tests that a refund PayPal refused is not recorded
+func (suite *PaymentRefundIntegrationTestSuite) TestGatewayFailureChangesNothing() {
+	suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+	suite.gateway.refundErr = errors.New("paypal unavailable")
+
+	_, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001"})
+	suite.Require().Error(err)
+
+	payment, err := suite.payments.GetByPaymentID(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusCompleted, payment.Status)
+	suite.Nil(payment.RefundAmount)
+	suite.Empty(suite.wooCommerce.refundsFor("magic", "1001"))
+	suite.Empty(suite.wooCommerce.notesFor("1001"))
+}
+
+// TestConvertedCaptureIsRefundedProportionally tests refunds of orders paid in the settlement currency
+func (suite *PaymentRefundIntegrationTestSuite) TestConvertedCaptureIsRefundedProportionally() {
+	proxyOrderID := suite.paidOrder(1001, 100.00, entities.NewMoney(23.26, "EUR"), "CAPTURE-1")
+
+	response, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: "50.00"})
+	suite.Require().NoError(err)
+	suite.Equal("50.00", response.Amount)
+	suite.Equal("PLN", response.Currency)
+	suite.Equal("11.63", response.SettlementAmount)
+	suite.Equal("EUR", response.SettlementCurrency)
+
+	suite.Equal([]entities.Money{entities.NewMoney(11.63, "EUR")}, suite.gateway.refundsFor("CAPTURE-1"))
+	suite.Equal(entities.NewMoney(50.00, "PLN"), suite.wooCommerce.refundsFor("magic", "1001")[0].Amount)
+	suite.Equal(entities.NewMoney(11.63, "EUR"), suite.wooCommerce.refundsFor("oitam", proxyOrderID)[0].Amount)
+
+	// The rest of the capture returns the rest of the order total
+	response, err = suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001"})
+	suite.Require().NoError(err)
+	suite.Equal("refunded", response.Status)
+	suite.Equal("50.00", response.Amount)
+	suite.Equal("11.63", response.SettlementAmount)
+}
+
+// TestRefundReasonStaysOffProxyOrder tests that the OITAM store never sees the refund reason
+func (suite *PaymentRefundIntegrationTestSuite) TestRefundReasonStaysOffProxyOrder() {
+	proxyOrderID := suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	_, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{
+		OrderID: "1001",
+		Amount:  "10.00",
+		Reason:  "Jan Kowalski returned a damaged spore print",
+	})
+	suite.Require().NoError(err)
+
+	suite.Equal("Jan Kowalski returned a damaged spore print", suite.wooCommerce.refundsFor("magic", "1001")[0].Reason)
+	suite.Equal("Refund", suite.wooCommerce.refundsFor("oitam", proxyOrderID)[0].Reason)
+	suite.Contains(suite.wooCommerce.notesFor("1001")[0], "Reason: Jan Kowalski returned a damaged spore print")
+}
+
+// TestMissingProxyOrderIsReportedAsWarning tests that a refund PayPal made is reported even when a store update fails
+func (suite *PaymentRefundIntegrationTestSuite) TestMissingProxyOrderIsReportedAsWarning() {
+	suite.wooCommerce.addMagicOrder(1003, 100.00)
+	suite.wooCommerce.setMagicOrderStatus(1003, entities.StatusProcessing)
+	suite.storePayment("1003", "CAPTURE-3", entities.NewMoney(100.00, "PLN"))
+
+	response, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1003"})
+	suite.Require().NoError(err)
+	suite.Equal("refunded", response.Status)
+	suite.Empty(response.OITAMOrderID)
+	suite.Require().Len(response.Warnings, 1)
+	suite.Contains(response.Warnings[0], "Failed to find OITAM proxy order")
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1003")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusRefunded, order.Status)
+}
+
+// TestWebhookPartialRefundsAccumulate tests that refund webhooks only refund the order once they add up to the capture
+func (suite *PaymentRefundIntegrationTestSuite) TestWebhookPartialRefundsAccumulate() {
+	proxyOrderID := suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	suite.refundWebhook("WH-1", "REFUND-A", proxyOrderID, "CAPTURE-1", entities.NewMoney(30.00, "PLN"))
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusProcessing, order.Status)
+	suite.Require().Len(suite.wooCommerce.refundsFor("magic", "1001"), 1)
+	suite.Equal("30.00", suite.wooCommerce.refundsFor("magic", "1001")[0].Amount.Decimal())
+	suite.Equal([]string{"PayPal refund REFUND-A issued for 30.00 PLN. Partially refunded: 30.00 PLN of 100.00 PLN captured."}, suite.wooCommerce.notesFor("1001"))
+
+	mapping, err := suite.mappings.GetByOITAMOrderID(context.Background(), proxyOrderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.MappingStatePaid, mapping.State)
+
+	// The same refund delivered as another event is not counted again
+	suite.refundWebhook("WH-2", "REFUND-A", proxyOrderID, "CAPTURE-1", entities.NewMoney(30.00, "PLN"))
+	suite.Len(suite.wooCommerce.refundsFor("magic", "1001"), 1)
+
+	suite.refundWebhook("WH-3", "REFUND-B", proxyOrderID, "CAPTURE-1", entities.NewMoney(70.00, "PLN"))
+
+	order, err = suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusRefunded, order.Status)
+	suite.Len(suite.wooCommerce.refundsFor("magic", "1001"), 1, "The refunded status covers the final refund")
+
+	mapping, err = suite.mappings.GetByOITAMOrderID(context.Background(), proxyOrderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.MappingStateRefunded, mapping.State)
+
+	payment, err := suite.payments.GetByPaymentID(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusRefunded, payment.Status)
+	suite.Equal("100.00", payment.RefundAmount.Decimal())
+	suite.Require().Len(payment.Refunds, 2)
+	suite.Equal(entities.RefundSourceWebhook, payment.Refunds[0].Source)
+}
+
+// TestWebhookForApiRefundIsNotRecordedTwice tests the webhook PayPal sends for a refund made through the API
+func (suite *PaymentRefundIntegrationTestSuite) TestWebhookForApiRefundIsNotRecordedTwice() {
+	proxyOrderID := suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	response, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: "40.00"})
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(response.RefundID)
+
+	suite.refundWebhook("WH-1", response.RefundID, proxyOrderID, "CAPTURE-1", entities.NewMoney(40.00, "PLN"))
+
+	suite.Len(suite.wooCommerce.refundsFor("magic", "1001"), 1)
+	payment, err := suite.payments.GetByPaymentID(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal("40.00", payment.RefundAmount.Decimal())
+	suite.Require().Len(payment.Refunds, 1)
+	suite.Equal(entities.RefundSourceAPI, payment.Refunds[0].Source)
+}
+
+// TestApiRefundAfterItsWebhookIsNotRecordedTwice tests a refund webhook that arrives before PayPal's API response is handled
+func (suite *PaymentRefundIntegrationTestSuite) TestApiRefundAfterItsWebhookIsNotRecordedTwice() {
+	proxyOrderID := suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	// The fake gateway numbers refunds per capture
+	suite.refundWebhook("WH-1", "REFUND-CAPTURE-1-1", proxyOrderID, "CAPTURE-1", entities.NewMoney(40.00, "PLN"))
+
+	response, err := suite.refund.Execute(context.Background(), &dto.PaymentRefundRequest{OrderID: "1001", Amount: "40.00"})
+	suite.Require().NoError(err)
+	suite.Equal("REFUND-CAPTURE-1-1", response.RefundID)
+	suite.Equal("partially_refunded", response.Status)
+	suite.Equal("40.00", response.RefundedTotal)
+	suite.Empty(response.Warnings)
+
+	suite.Len(suite.wooCommerce.refundsFor("magic", "1001"), 1)
+	suite.Len(suite.wooCommerce.refundsFor("oitam", proxyOrderID), 1)
+}
+
+// TestWebhookRefundOfConvertedCapture tests that partial refund lines are given in the order currency
+func (suite *PaymentRefundIntegrationTestSuite) TestWebhookRefundOfConvertedCapture() {
+	proxyOrderID := suite.paidOrder(1001, 100.00, entities.NewMoney(23.26, "EUR"), "CAPTURE-1")
+
+	suite.refundWebhook("WH-1", "REFUND-A", proxyOrderID, "CAPTURE-1", entities.NewMoney(11.63, "EUR"))
+
+	refunds := suite.wooCommerce.refundsFor("magic", "1001")
+	suite.Require().Len(refunds, 1)
+	suite.Equal(entities.NewMoney(50.00, "PLN"), refunds[0].Amount)
+}
+
+// TestWebhookRefundBeyondCaptureIsOnlyNoted tests a refund the payment ledger cannot take
+func (suite *PaymentRefundIntegrationTestSuite) TestWebhookRefundBeyondCaptureIsOnlyNoted() {
+	proxyOrderID := suite.paidOrder(1001, 100.00, entities.NewMoney(100.00, "PLN"), "CAPTURE-1")
+
+	suite.refundWebhook("WH-1", "REFUND-A", proxyOrderID, "CAPTURE-1", entities.NewMoney(100.01, "PLN"))
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusProcessing, order.Status)
+	suite.Empty(suite.wooCommerce.refundsFor("magic", "1001"))
+	suite.Equal([]string{"PayPal refund REFUND-A issued for 100.01 PLN. Refund not recorded: Refund amount 100.01 PLN exceeds refundable 100.00 PLN. Order status left at processing."}, suite.wooCommerce.notesFor("1001"))
+}
+
+// refundWebhook delivers a PAYMENT.CAPTURE.REFUNDED webhook for a refund of a capture
+func (suite *PaymentRefundIntegrationTestSuite) refundWebhook(eventID, refundID, proxyOrderID, captureID string, amount entities.Money) {
+	response, err := suite.webhook.Execute(context.Background(), &dto.WebhookRequest{
+		ID:        eventID,
+		EventType: entities.EventPaymentCaptureRefunded,
+		Resource: map[string]interface{}{
+			"id":        refundID,
+			"status":    "COMPLETED",
+			"custom_id": proxyOrderID,
+			"amount":    map[string]interface{}{"value": amount.Decimal(), "currency_code": amount.Currency},
+			"links": []interface{}{
+				map[string]interface{}{"href": "https://api.paypal.test/v2/payments/captures/" + captureID, "rel": "up", "method": "GET"},
+			},
+		},
+	})
+	suite.Require().NoError(err)
+	suite.Equal("processed", response.Status)
+}
+
+// paidOrder stores a MagicSpore order paid through an OITAM proxy order and
+// returns the proxy order ID
+func (suite *PaymentRefundIntegrationTestSuite) paidOrder(orderID int, total float64, captured entities.Money, captureID string) string {
+	suite.wooCommerce.addMagicOrder(orderID, total)
+	suite.wooCommerce.setMagicOrderStatus(orderID, entities.StatusProcessing)
+
+	proxyOrder, err := suite.wooCommerce.CreateOITAMOrder(context.Background(), &entities.Order{
+		Status:        entities.StatusProcessing,
+		Currency:      captured.Currency,
+		Total:         captured,
+		TransactionID: captureID,
+	})
+	suite.Require().NoError(err)
+
+	mapping := entities.NewOrderMapping(strconv.Itoa(orderID), proxyOrder, time.Hour)
+	mapping.State = entities.MappingStatePaid
+	suite.Require().NoError(suite.mappings.Create(context.Background(), mapping))
+
+	suite.storePayment(strconv.Itoa(orderID), captureID, captured)
+	return mapping.OITAMOrderID
+}
+
+// storePayment records a completed capture for an order
+func (suite *PaymentRefundIntegrationTestSuite) storePayment(orderID, captureID string, captured entities.Money) {
+	payment := services.NewPaymentDomainService(suite.logger).CreatePaymentRecord(
+		context.Background(), orderID, captureID, "PAYER-1", captured, entities.PaymentStatusCompleted,
+	)
+	suite.Require().NoError(suite.payments.Create(context.Background(), payment))
+	suite.gateway.setCapture(captureID, entities.PaymentStatusCompleted, captured.Float64())
+}
+
+// newRefundUseCase builds the refund use case with or without a payment gateway
+func (suite *PaymentRefundIntegrationTestSuite) newRefundUseCase(gateway interfaces.PaymentGateway) *usecases.PaymentRefundUseCase {
+	return usecases.NewPaymentRefundUseCase(
+		suite.wooCommerce,
+		suite.payments,
+		suite.mappings,
+		gateway,
+		services.NewPaymentDomainService(suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		suite.logger,
+	)
+}
+
+// TestPaymentRefundIntegrationTestSuite runs the payment refund suite
+func TestPaymentRefundIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(PaymentRefundIntegrationTestSuite))
+}
@@ -0,0 +1,134 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/infrastructure/database"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PaymentRepositoryIntegrationTestSuite tests the PostgreSQL payment and webhook event repositories
+type PaymentRepositoryIntegrationTestSuite struct {
+	suite.Suite
+	repo   interfaces.PaymentRepository
+	events interfaces.WebhookEventRepository
+	logger interfaces.Logger
+}
+
+// SetupSuite connects to the test database and applies migrations
+func (suite *PaymentRepositoryIntegrationTestSuite) SetupSuite() {
+	if os.Getenv("INTEGRATION_TESTS_ENABLED") != "true" {
+		suite.T().Skip("Integration tests disabled")
+	}
+
+	connectionString := os.Getenv("TEST_DATABASE_URL")
+	if connectionString == "" {
+		suite.T().Skip("TEST_DATABASE_URL not provided")
+	}
+
+	suite.logger = infraHttp.NewDefaultLogger("debug")
+
+	db, err := database.NewPostgresDB(context.Background(), database.PostgresConfig{
+		ConnectionString: connectionString,
+		MaxConnections:   5,
+		Timeout:          10 * time.Second,
+		AutoMigrate:      true,
+	}, suite.logger)
+	suite.Require().NoError(err, "Should connect to test database")
+
+	suite.repo = repositories.NewPostgresPaymentRepository(db, suite.logger)
+	suite.events = repositories.NewPostgresWebhookEventRepository(db, suite.logger)
+}
+
+// TestCreateAndQueryPayment tests storing a payment and reading it back
+func (suite *PaymentRepositoryIntegrationTestSuite) TestCreateAndQueryPayment() {
+	ctx := context.Background()
+	suffix := time.Now().UnixNano()
+	orderID := fmt.Sprintf("it-order-%d", suffix)
+	paypalID := fmt.Sprintf("PAYID-%d", suffix)
+
+	payment := &entities.Payment{
+		ID:            fmt.Sprintf("pay_%d", suffix),
+		OrderID:       orderID,
+		PaymentID:     paypalID,
+		PayerID:       "PAYER123",
+		TransactionID: paypalID,
+		Status:        entities.PaymentStatusPending,
+		Method:        entities.PaymentMethodPayPal,
+		Amount:        entities.NewMoney(49.99, "PLN"),
+		ProcessedAt:   time.Now(),
+		MetaData: []entities.MetaData{
+			{Key: "payment_provider", Value: "paypal"},
+		},
+	}
+
+	suite.Require().NoError(suite.repo.Create(ctx, payment), "Should store payment")
+
+	byOrder, err := suite.repo.GetByOrderID(ctx, orderID)
+	suite.Require().NoError(err)
+	suite.Require().Len(byOrder, 1, "Should find payment by order ID")
+	suite.Equal(payment.ID, byOrder[0].ID)
+	suite.Equal("49.99", byOrder[0].Amount.Decimal())
+	suite.Equal("PLN", byOrder[0].Amount.Currency)
+	suite.Len(byOrder[0].MetaData, 1)
+
+	byPayPalID, err := suite.repo.GetByPaymentID(ctx, paypalID)
+	suite.Require().NoError(err)
+	suite.Equal(orderID, byPayPalID.OrderID, "Should find payment by PayPal payment ID")
+
+	suite.Require().NoError(suite.repo.UpdateStatus(ctx, paypalID, entities.PaymentStatusCompleted))
+
+	updated, err := suite.repo.GetByPaymentID(ctx, paypalID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusCompleted, updated.Status)
+	suite.NotNil(updated.CompletedAt, "Completed payments should record completion time")
+}
+
+// TestMissingPayment tests lookups for unknown payment IDs
+func (suite *PaymentRepositoryIntegrationTestSuite) TestMissingPayment() {
+	ctx := context.Background()
+
+	payment, err := suite.repo.GetByPaymentID(ctx, "PAYID-DOES-NOT-EXIST")
+	suite.Error(err)
+	suite.Nil(payment)
+	suite.Contains(err.Error(), "not found")
+
+	suite.Error(suite.repo.UpdateStatus(ctx, "PAYID-DOES-NOT-EXIST", entities.PaymentStatusFailed))
+}
+
+// TestWebhookEventRedelivery tests that updating an event stores the body and
+// type of a signed redelivery over those of a rejected delivery
+func (suite *PaymentRepositoryIntegrationTestSuite) TestWebhookEventRedelivery() {
+	ctx := context.Background()
+	eventID := fmt.Sprintf("WH-IT-%d", time.Now().UnixNano())
+
+	event := entities.NewWebhookEvent(eventID, "PAYMENT.CAPTURE.DENIED", []byte(`{"forged":true}`), entities.SignatureInvalid)
+	suite.Require().NoError(suite.events.Create(ctx, event))
+
+	event.AcceptRedelivery(entities.NewWebhookEvent(eventID, "PAYMENT.CAPTURE.COMPLETED", []byte(`{"signed":true}`), entities.SignatureVerified))
+	event.RecordOutcome(entities.WebhookEventProcessed, "done")
+	suite.Require().NoError(suite.events.Update(ctx, event))
+
+	stored, err := suite.events.GetByID(ctx, eventID)
+	suite.Require().NoError(err)
+	suite.Equal("PAYMENT.CAPTURE.COMPLETED", stored.EventType)
+	suite.Equal(`{"signed":true}`, stored.Body)
+	suite.Equal(entities.SignatureVerified, stored.Signature)
+	suite.Equal(entities.WebhookEventProcessed, stored.Status)
+}
+
+// TestPaymentRepositoryIntegrationTestSuite runs the payment repository suite
+func TestPaymentRepositoryIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(PaymentRepositoryIntegrationTestSuite))
+}
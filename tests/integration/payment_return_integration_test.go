@@ -0,0 +1,342 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PaymentReturnIntegrationTestSuite tests server-side payment verification on return
+type PaymentReturnIntegrationTestSuite struct {
+	suite.Suite
+	cfg         *config.Config
+	wooCommerce *fakeWooCommerce
+	gateway     *fakePaymentGateway
+	payments    interfaces.PaymentRepository
+	mappings    interfaces.OrderMappingRepository
+	redirect    *usecases.PaymentRedirectUseCase
+	logger      interfaces.Logger
+}
+
+// SetupTest wires fresh stores and a proxy order for MagicSpore order 1001
+func (suite *PaymentReturnIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	suite.cfg = config.NewConfig()
+	suite.cfg.Proxy.OrderTTL = time.Hour
+	suite.cfg.Proxy.VerifyPollAttempts = 0
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.gateway = newFakePaymentGateway()
+	suite.payments = repositories.NewMemoryPaymentRepository(suite.logger)
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(suite.logger)
+	suite.redirect = usecases.NewPaymentRedirectUseCase(
+		suite.wooCommerce,
+		suite.mappings,
+		infraHttp.NewURLBuilder(suite.cfg, suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		nil,
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+
+	suite.wooCommerce.addMagicOrder(1001, 49.99)
+}
+
+// newReturnUseCase builds the return use case with or without a payment gateway
+func (suite *PaymentReturnIntegrationTestSuite) newReturnUseCase(gateway interfaces.PaymentGateway) *usecases.PaymentReturnUseCase {
+	return usecases.NewPaymentReturnUseCase(
+		suite.wooCommerce,
+		suite.payments,
+		suite.mappings,
+		gateway,
+		services.NewPaymentDomainService(suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+}
+
+// TestCraftedReturnDoesNotMarkOrderPaid tests that query parameters alone prove nothing
+func (suite *PaymentReturnIntegrationTestSuite) TestCraftedReturnDoesNotMarkOrderPaid() {
+	suite.startCheckout()
+
+	response := suite.returnFor(suite.newReturnUseCase(suite.gateway), &dto.PaymentReturnRequest{
+		OrderID:   "1001",
+		PaymentID: "PAY-FORGED",
+		PayerID:   "PAYER-FORGED",
+		Status:    "approved",
+	})
+
+	suite.Equal("pending", response.Status)
+	suite.Equal(entities.StatusPending, suite.magicOrderStatus())
+}
+
+// TestVerifiedCaptureMarksOrderPaid tests the happy path with a PayPal capture lookup
+func (suite *PaymentReturnIntegrationTestSuite) TestVerifiedCaptureMarksOrderPaid() {
+	proxyOrderID := suite.startCheckout()
+	suite.payProxyOrder(proxyOrderID, "CAPTURE-1", 49.99)
+	suite.gateway.setCapture("CAPTURE-1", entities.PaymentStatusCompleted, 49.99)
+
+	response := suite.returnFor(suite.newReturnUseCase(suite.gateway), &dto.PaymentReturnRequest{OrderID: "1001"})
+
+	suite.Equal("success", response.Status)
+	suite.Equal(entities.StatusProcessing, suite.magicOrderStatus())
+
+	mapping, err := suite.mappings.GetByOITAMOrderID(context.Background(), proxyOrderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.MappingStatePaid, mapping.State)
+
+	payment, err := suite.payments.GetByPaymentID(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal("49.99", payment.Amount.Decimal())
+}
+
+// TestCaptureAmountMismatchIsRejected tests that an underpaid capture never completes the order
+func (suite *PaymentReturnIntegrationTestSuite) TestCaptureAmountMismatchIsRejected() {
+	proxyOrderID := suite.startCheckout()
+	suite.payProxyOrder(proxyOrderID, "CAPTURE-2", 49.99)
+	suite.gateway.setCapture("CAPTURE-2", entities.PaymentStatusCompleted, 4.99)
+
+	response := suite.returnFor(suite.newReturnUseCase(suite.gateway), &dto.PaymentReturnRequest{OrderID: "1001"})
+
+	suite.Equal("error", response.Status)
+	suite.Equal(entities.StatusPending, suite.magicOrderStatus())
+}
+
+// TestCaptureCannotPayTwoOrders tests that one capture is not replayed for another order
+func (suite *PaymentReturnIntegrationTestSuite) TestCaptureCannotPayTwoOrders() {
+	proxyOrderID := suite.startCheckout()
+	suite.payProxyOrder(proxyOrderID, "CAPTURE-3", 49.99)
+
+	suite.Require().NoError(suite.payments.Create(context.Background(), &entities.Payment{
+		ID:        "pay_other",
+		OrderID:   "2002",
+		PaymentID: "CAPTURE-3",
+		Status:    entities.PaymentStatusCompleted,
+	}))
+
+	response := suite.returnFor(suite.newReturnUseCase(nil), &dto.PaymentReturnRequest{OrderID: "1001"})
+
+	suite.Equal("error", response.Status)
+	suite.Equal(entities.StatusPending, suite.magicOrderStatus())
+}
+
+// TestReturnWithoutMappingIsRejected tests that a paid proxy order named only in
+// the return URL does not pay an order that never checked out through it
+func (suite *PaymentReturnIntegrationTestSuite) TestReturnWithoutMappingIsRejected() {
+	magicOrder, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	proxyOrder, err := suite.wooCommerce.CreateOITAMOrder(context.Background(), magicOrder.ToAnonymousOrder(nil))
+	suite.Require().NoError(err)
+	suite.payProxyOrder(fmt.Sprintf("%d", proxyOrder.ID), "CAPTURE-6", 49.99)
+
+	response := suite.returnFor(suite.newReturnUseCase(nil), &dto.PaymentReturnRequest{
+		OrderID:      "1001",
+		OITAMOrderID: fmt.Sprintf("%d", proxyOrder.ID),
+	})
+
+	suite.Equal("error", response.Status)
+	suite.Equal(entities.StatusPending, suite.magicOrderStatus())
+}
+
+// TestForeignProxyOrderIsRejected tests that a proxy order created for another
+// order cannot pay this one
+func (suite *PaymentReturnIntegrationTestSuite) TestForeignProxyOrderIsRejected() {
+	proxyOrderID := suite.startCheckout()
+	suite.payProxyOrder(proxyOrderID, "CAPTURE-7", 49.99)
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.oitamOrders, proxyOrderID, func(order *entities.Order) {
+		for i := range order.MetaData {
+			if order.MetaData[i].Key == entities.MetaOriginalOrderNumber {
+				order.MetaData[i].Value = "2002"
+			}
+		}
+	}))
+
+	response := suite.returnFor(suite.newReturnUseCase(nil), &dto.PaymentReturnRequest{OrderID: "1001"})
+
+	suite.Equal("error", response.Status)
+	suite.Equal(entities.StatusPending, suite.magicOrderStatus())
+}
+
+// TestPaymentLookupErrorIsNotTrusted tests that a failing capture lookup leaves
+// the order pending instead of skipping the duplicate-capture check
+func (suite *PaymentReturnIntegrationTestSuite) TestPaymentLookupErrorIsNotTrusted() {
+	proxyOrderID := suite.startCheckout()
+	suite.payProxyOrder(proxyOrderID, "CAPTURE-8", 49.99)
+	suite.payments = &unavailablePaymentRepository{PaymentRepository: suite.payments}
+
+	response := suite.returnFor(suite.newReturnUseCase(nil), &dto.PaymentReturnRequest{OrderID: "1001"})
+
+	suite.Equal("pending", response.Status)
+	suite.Equal(entities.StatusPending, suite.magicOrderStatus())
+}
+
+// TestRefundedOrderIsNotPaidAgain tests that the order state machine rejects a
+// return for an order that was already refunded
+func (suite *PaymentReturnIntegrationTestSuite) TestRefundedOrderIsNotPaidAgain() {
+	proxyOrderID := suite.startCheckout()
+	suite.payProxyOrder(proxyOrderID, "CAPTURE-5", 49.99)
+	suite.wooCommerce.setMagicOrderStatus(1001, entities.StatusRefunded)
+
+	response := suite.returnFor(suite.newReturnUseCase(nil), &dto.PaymentReturnRequest{OrderID: "1001"})
+
+	suite.Equal("error", response.Status)
+	suite.Equal(entities.StatusRefunded, suite.magicOrderStatus())
+}
+
+// TestPendingReturnIsPolled tests that a capture settling after the return is picked up
+func (suite *PaymentReturnIntegrationTestSuite) TestPendingReturnIsPolled() {
+	suite.cfg.Proxy.VerifyPollAttempts = 20
+	suite.cfg.Proxy.VerifyPollInterval = 10 * time.Millisecond
+
+	proxyOrderID := suite.startCheckout()
+	response := suite.returnFor(suite.newReturnUseCase(nil), &dto.PaymentReturnRequest{OrderID: "1001"})
+	suite.Equal("pending", response.Status)
+
+	suite.payProxyOrder(proxyOrderID, "CAPTURE-4", 49.99)
+
+	suite.Eventually(func() bool {
+		return suite.magicOrderStatus() == entities.StatusProcessing
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// startCheckout runs the redirect and returns the created proxy order ID
+func (suite *PaymentReturnIntegrationTestSuite) startCheckout() string {
+	response, err := suite.redirect.Execute(context.Background(), &dto.PaymentRedirectRequest{
+		OrderID: "1001",
+		Domain:  "magicspore.com",
+	})
+	suite.Require().NoError(err)
+	return response.ProxyOrderID
+}
+
+// payProxyOrder marks the OITAM order paid the way WooCommerce PayPal does
+func (suite *PaymentReturnIntegrationTestSuite) payProxyOrder(proxyOrderID, captureID string, total float64) {
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.oitamOrders, proxyOrderID, func(order *entities.Order) {
+		order.Status = entities.StatusProcessing
+		order.TransactionID = captureID
+		order.Total = entities.NewMoney(total, "PLN")
+	}))
+}
+
+func (suite *PaymentReturnIntegrationTestSuite) returnFor(useCase *usecases.PaymentReturnUseCase, request *dto.PaymentReturnRequest) *dto.PaymentReturnResponse {
+	response, err := useCase.Execute(context.Background(), request)
+	suite.Require().NoError(err)
+	return response
+}
+
+func (suite *PaymentReturnIntegrationTestSuite) magicOrderStatus() entities.OrderStatus {
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	return order.Status
+}
+
+// TestPaymentReturnIntegrationTestSuite runs the payment return suite
+func TestPaymentReturnIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(PaymentReturnIntegrationTestSuite))
+}
+
+// unavailablePaymentRepository fails every capture lookup
+type unavailablePaymentRepository struct {
+	interfaces.PaymentRepository
+}
+
+func (r *unavailablePaymentRepository) GetByPaymentID(ctx context.Context, paymentID string) (*entities.Payment, error) {
+	return nil, errors.New("database unavailable")
+}
+
+// fakePaymentGateway serves scripted capture lookups
+type fakePaymentGateway struct {
+	mutex     sync.Mutex
+	captures  map[string]*entities.Payment
+	refunds   map[string][]entities.Money
+	requests  map[string]string // refund ID by PayPal-Request-Id
+	refundErr error
+}
+
+func newFakePaymentGateway() *fakePaymentGateway {
+	return &fakePaymentGateway{
+		captures: make(map[string]*entities.Payment),
+		refunds:  make(map[string][]entities.Money),
+		requests: make(map[string]string),
+	}
+}
+
+func (g *fakePaymentGateway) setCapture(captureID string, status entities.PaymentStatus, amount float64) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.captures[captureID] = &entities.Payment{
+		PaymentID: captureID,
+		Status:    status,
+		Amount:    entities.NewMoney(amount, "PLN"),
+	}
+}
+
+func (g *fakePaymentGateway) CreatePayment(ctx context.Context, request *entities.PaymentRequest) (*entities.PaymentResponse, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (g *fakePaymentGateway) ProcessPayment(ctx context.Context, paymentID string, payerID string) (*entities.Payment, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (g *fakePaymentGateway) GetPaymentStatus(ctx context.Context, paymentID string) (*entities.Payment, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	capture, exists := g.captures[paymentID]
+	if !exists {
+		return nil, fmt.Errorf("capture %s not found", paymentID)
+	}
+	result := *capture
+	return &result, nil
+}
+
+func (g *fakePaymentGateway) CancelPayment(ctx context.Context, paymentID string) error {
+	return fmt.Errorf("not supported")
+}
+
+func (g *fakePaymentGateway) RefundPayment(ctx context.Context, paymentID string, amount entities.Money, requestID string) (string, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.refundErr != nil {
+		return "", g.refundErr
+	}
+	if _, exists := g.captures[paymentID]; !exists {
+		return "", fmt.Errorf("capture %s not found", paymentID)
+	}
+	if refundID, exists := g.requests[requestID]; exists {
+		return refundID, nil
+	}
+	g.refunds[paymentID] = append(g.refunds[paymentID], amount)
+	refundID := fmt.Sprintf("REFUND-%s-%d", paymentID, len(g.refunds[paymentID]))
+	g.requests[requestID] = refundID
+	return refundID, nil
+}
+
+// refundsFor returns the amounts refunded on a capture
+func (g *fakePaymentGateway) refundsFor(captureID string) []entities.Money {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return append([]entities.Money(nil), g.refunds[captureID]...)
+}
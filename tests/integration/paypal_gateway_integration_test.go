@@ -0,0 +1,241 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/infrastructure/gateways"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PayPalGatewayIntegrationTestSuite tests the Orders v2 gateway against a local PayPal stand-in
+type PayPalGatewayIntegrationTestSuite struct {
+	suite.Suite
+	server  *httptest.Server
+	paypal  *paypalStandIn
+	gateway interfaces.PaymentGateway
+}
+
+// SetupTest starts a fresh stand-in and gateway for every test
+func (suite *PayPalGatewayIntegrationTestSuite) SetupTest() {
+	suite.paypal = &paypalStandIn{requestIDs: make(map[string]int)}
+	suite.server = httptest.NewServer(suite.paypal)
+	suite.gateway = gateways.NewPayPalGateway(gateways.PayPalConfig{
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		APIBase:       suite.server.URL,
+		Timeout:       5 * time.Second,
+		RetryAttempts: 2,
+		RetryDelay:    time.Millisecond,
+	}, infraHttp.NewDefaultLogger("error"))
+}
+
+// TearDownTest stops the stand-in
+func (suite *PayPalGatewayIntegrationTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+// TestCreatePaymentReturnsApprovalURL tests order creation and token reuse
+func (suite *PayPalGatewayIntegrationTestSuite) TestCreatePaymentReturnsApprovalURL() {
+	for i := 0; i < 2; i++ {
+		response, err := suite.gateway.CreatePayment(context.Background(), &entities.PaymentRequest{
+			OrderID:   "1001",
+			Amount:    entities.NewMoney(49.99, "PLN"),
+			ReturnURL: "https://proxy.example/return",
+			CancelURL: "https://proxy.example/cancel",
+		})
+		suite.Require().NoError(err)
+		suite.Equal("ORDER-1", response.PaymentID)
+		suite.Equal(entities.PaymentStatusCreated, response.Status)
+		suite.Equal("https://www.sandbox.paypal.com/checkoutnow?token=ORDER-1", response.ApprovalURL)
+	}
+
+	suite.Equal(1, suite.paypal.tokenRequests)
+	suite.Equal("49.99", suite.paypal.lastAmount)
+}
+
+// TestProcessPaymentMapsCapture tests capture mapping into the payment entity
+func (suite *PayPalGatewayIntegrationTestSuite) TestProcessPaymentMapsCapture() {
+	payment, err := suite.gateway.ProcessPayment(context.Background(), "ORDER-1", "PAYER-1")
+	suite.Require().NoError(err)
+
+	suite.Equal("ORDER-1", payment.PaymentID)
+	suite.Equal("CAPTURE-1", payment.TransactionID)
+	suite.Equal("1001", payment.OrderID)
+	suite.Equal(entities.PaymentStatusCompleted, payment.Status)
+	suite.Equal("49.99", payment.Amount.Decimal())
+	suite.Equal("PLN", payment.Currency)
+	suite.Require().NotNil(payment.PayPalDetails)
+	suite.Equal("buyer@example.com", payment.PayPalDetails.Payer.PayerInfo.Email)
+}
+
+// TestGetPaymentStatusFallsBackToOrder tests capture lookup with an order ID
+func (suite *PayPalGatewayIntegrationTestSuite) TestGetPaymentStatusFallsBackToOrder() {
+	capture, err := suite.gateway.GetPaymentStatus(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusCompleted, capture.Status)
+	suite.Equal("49.99", capture.Amount.Decimal())
+
+	order, err := suite.gateway.GetPaymentStatus(context.Background(), "ORDER-1")
+	suite.Require().NoError(err)
+	suite.Equal("CAPTURE-1", order.TransactionID)
+
+	_, err = suite.gateway.GetPaymentStatus(context.Background(), "UNKNOWN")
+	suite.Error(err)
+}
+
+// TestExpiredTokenIsRefreshed tests that a 401 fetches a new token once
+func (suite *PayPalGatewayIntegrationTestSuite) TestExpiredTokenIsRefreshed() {
+	_, err := suite.gateway.GetPaymentStatus(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+
+	suite.paypal.revokeTokens()
+	_, err = suite.gateway.GetPaymentStatus(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal(2, suite.paypal.tokenRequests)
+}
+
+// TestServerErrorsAreRetriedIdempotently tests retries reuse the PayPal-Request-Id
+func (suite *PayPalGatewayIntegrationTestSuite) TestServerErrorsAreRetriedIdempotently() {
+	suite.paypal.failNext = 1
+
+	_, err := suite.gateway.RefundPayment(context.Background(), "CAPTURE-1", entities.NewMoney(10, "PLN"), "refund-1")
+	suite.Require().NoError(err)
+
+	suite.Equal(map[string]int{"refund-1": 2}, suite.paypal.requestIDs, "Refunds send the caller's request ID")
+}
+
+// TestCancelCapturedOrderFails tests that captured money is not silently cancelled
+func (suite *PayPalGatewayIntegrationTestSuite) TestCancelCapturedOrderFails() {
+	suite.Error(suite.gateway.CancelPayment(context.Background(), "ORDER-1"))
+}
+
+// TestPayPalGatewayIntegrationTestSuite runs the PayPal gateway suite
+func TestPayPalGatewayIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(PayPalGatewayIntegrationTestSuite))
+}
+
+// paypalStandIn serves a minimal slice of the PayPal REST API
+type paypalStandIn struct {
+	mutex         sync.Mutex
+	tokenRequests int
+	tokenVersion  int
+	failNext      int
+	lastAmount    string
+	requestIDs    map[string]int
+}
+
+func (p *paypalStandIn) revokeTokens() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.tokenVersion++
+}
+
+func (p *paypalStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if r.URL.Path == "/v1/oauth2/token" {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "client" || pass != "secret" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+			return
+		}
+		p.tokenRequests++
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token": p.currentToken(),
+			"expires_in":   32400,
+		})
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer "+p.currentToken() {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"name": "AUTHENTICATION_FAILURE", "message": "expired token"})
+		return
+	}
+
+	if id := r.Header.Get("PayPal-Request-Id"); id != "" {
+		p.requestIDs[id]++
+	}
+
+	if p.failNext > 0 {
+		p.failNext--
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"name": "INTERNAL_SERVICE_ERROR", "message": "try again"})
+		return
+	}
+
+	capture := map[string]interface{}{
+		"id":          "CAPTURE-1",
+		"status":      "COMPLETED",
+		"custom_id":   "1001",
+		"amount":      map[string]string{"currency_code": "PLN", "value": "49.99"},
+		"create_time": "2024-01-01T10:00:00Z",
+		"update_time": "2024-01-01T10:00:05Z",
+	}
+	order := map[string]interface{}{
+		"id":     "ORDER-1",
+		"status": "COMPLETED",
+		"intent": "CAPTURE",
+		"payer": map[string]interface{}{
+			"payer_id":      "PAYER-1",
+			"email_address": "buyer@example.com",
+		},
+		"purchase_units": []map[string]interface{}{{
+			"custom_id": "1001",
+			"amount":    map[string]string{"currency_code": "PLN", "value": "49.99"},
+			"payments":  map[string]interface{}{"captures": []interface{}{capture}},
+		}},
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/checkout/orders":
+		var body struct {
+			PurchaseUnits []struct {
+				Amount struct {
+					Value string `json:"value"`
+				} `json:"amount"`
+			} `json:"purchase_units"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if len(body.PurchaseUnits) > 0 {
+			p.lastAmount = body.PurchaseUnits[0].Amount.Value
+		}
+		writeJSON(w, http.StatusCreated, map[string]interface{}{
+			"id":     "ORDER-1",
+			"status": "PAYER_ACTION_REQUIRED",
+			"links": []map[string]string{
+				{"href": "https://www.sandbox.paypal.com/checkoutnow?token=ORDER-1", "rel": "payer-action", "method": "GET"},
+			},
+		})
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/checkout/orders/ORDER-1/capture":
+		writeJSON(w, http.StatusCreated, order)
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/checkout/orders/ORDER-1":
+		writeJSON(w, http.StatusOK, order)
+	case r.Method == http.MethodGet && r.URL.Path == "/v2/payments/captures/CAPTURE-1":
+		writeJSON(w, http.StatusOK, capture)
+	case r.Method == http.MethodPost && r.URL.Path == "/v2/payments/captures/CAPTURE-1/refund":
+		writeJSON(w, http.StatusCreated, map[string]string{"id": "REFUND-1", "status": "COMPLETED"})
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"name": "RESOURCE_NOT_FOUND", "message": "not found"})
+	}
+}
+
+func (p *paypalStandIn) currentToken() string {
+	return "token-" + string(rune('A'+p.tokenVersion))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
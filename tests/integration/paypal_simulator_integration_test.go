@@ -0,0 +1,302 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/infrastructure/gateways"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/simulators"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// PayPalSimulatorIntegrationTestSuite drives the PayPal gateway through the simulator
+type PayPalSimulatorIntegrationTestSuite struct {
+	suite.Suite
+	simulator *simulators.PayPalSimulator
+	server    *httptest.Server
+	receiver  *httptest.Server
+	received  *webhookRecorder
+	gateway   interfaces.PaymentGateway
+	browser   *http.Client
+}
+
+// SetupTest starts a simulator delivering webhooks to a local receiver
+func (suite *PayPalSimulatorIntegrationTestSuite) SetupTest() {
+	logger := infraHttp.NewDefaultLogger("error")
+
+	suite.received = &webhookRecorder{}
+	suite.receiver = httptest.NewServer(suite.received)
+
+	simulator, err := simulators.NewPayPalSimulator(simulators.PayPalSimulatorConfig{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		WebhookURL:   suite.receiver.URL,
+		WebhookID:    "WH-TEST",
+	}, logger)
+	suite.Require().NoError(err)
+	suite.simulator = simulator
+	suite.server = httptest.NewServer(simulator)
+
+	suite.gateway = gateways.NewPayPalGateway(gateways.PayPalConfig{
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		APIBase:       suite.server.URL,
+		Timeout:       5 * time.Second,
+		RetryAttempts: 1,
+		RetryDelay:    time.Millisecond,
+	}, logger)
+
+	suite.browser = &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// TearDownTest stops the simulator and receiver
+func (suite *PayPalSimulatorIntegrationTestSuite) TearDownTest() {
+	suite.simulator.Flush()
+	suite.server.Close()
+	suite.receiver.Close()
+}
+
+// TestApprovedCheckoutCompletes tests create, buyer approval and capture
+func (suite *PayPalSimulatorIntegrationTestSuite) TestApprovedCheckoutCompletes() {
+	created := suite.createPayment("5001", 49.99)
+
+	location := suite.approve(created.ApprovalURL)
+	suite.Equal("/return", location.Path)
+	suite.Equal(created.PaymentID, location.Query().Get("token"))
+	suite.NotEmpty(location.Query().Get("PayerID"))
+
+	payment, err := suite.gateway.ProcessPayment(context.Background(), created.PaymentID, location.Query().Get("PayerID"))
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusCompleted, payment.Status)
+	suite.Equal("5001", payment.OrderID)
+	suite.Equal("49.99", payment.Amount.Decimal())
+
+	capture, err := suite.gateway.GetPaymentStatus(context.Background(), payment.TransactionID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusCompleted, capture.Status)
+
+	suite.Equal([]string{"CHECKOUT.ORDER.APPROVED", "PAYMENT.CAPTURE.COMPLETED", "CHECKOUT.ORDER.COMPLETED"}, suite.receivedEventTypes())
+}
+
+// TestCancelledCheckoutReturnsToCancelURL tests a buyer abandoning the approval page
+func (suite *PayPalSimulatorIntegrationTestSuite) TestCancelledCheckoutReturnsToCancelURL() {
+	suite.simulator.SetScenario("5002", simulators.PayPalScenario{Outcome: simulators.PayPalOutcomeCancel})
+	created := suite.createPayment("5002", 10)
+
+	location := suite.approve(created.ApprovalURL)
+	suite.Equal("/cancel", location.Path)
+
+	_, err := suite.gateway.ProcessPayment(context.Background(), created.PaymentID, "")
+	suite.Error(err)
+}
+
+// TestDeniedCaptureIsDeclined tests a scripted denial
+func (suite *PayPalSimulatorIntegrationTestSuite) TestDeniedCaptureIsDeclined() {
+	suite.simulator.SetScenario("5003", simulators.PayPalScenario{Outcome: simulators.PayPalOutcomeDeny})
+	created := suite.createPayment("5003", 10)
+	suite.Require().NoError(suite.simulator.ApproveOrder(created.PaymentID))
+
+	payment, err := suite.gateway.ProcessPayment(context.Background(), created.PaymentID, "")
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusFailed, payment.Status)
+	suite.Contains(suite.receivedEventTypes(), "PAYMENT.CAPTURE.DENIED")
+}
+
+// TestDelayedCaptureSettlesOnDemand tests a pending capture completing later
+func (suite *PayPalSimulatorIntegrationTestSuite) TestDelayedCaptureSettlesOnDemand() {
+	suite.simulator.SetScenario("5004", simulators.PayPalScenario{Outcome: simulators.PayPalOutcomeDelay})
+	created := suite.createPayment("5004", 10)
+	suite.Require().NoError(suite.simulator.ApproveOrder(created.PaymentID))
+
+	payment, err := suite.gateway.ProcessPayment(context.Background(), created.PaymentID, "")
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusPending, payment.Status)
+
+	suite.Require().NoError(suite.simulator.SettleCapture(payment.TransactionID, simulators.PayPalOutcomeApprove))
+
+	capture, err := suite.gateway.GetPaymentStatus(context.Background(), payment.TransactionID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusCompleted, capture.Status)
+	suite.Contains(suite.receivedEventTypes(), "PAYMENT.CAPTURE.PENDING")
+	suite.Contains(suite.receivedEventTypes(), "PAYMENT.CAPTURE.COMPLETED")
+}
+
+// TestPartialAndFullRefunds tests refunds up to the captured amount
+func (suite *PayPalSimulatorIntegrationTestSuite) TestPartialAndFullRefunds() {
+	payment := suite.completeCheckout("5005", 30)
+
+	partial, err := suite.gateway.RefundPayment(context.Background(), payment.TransactionID, entities.NewMoney(10, "PLN"), "refund-1")
+	suite.Require().NoError(err)
+	repeated, err := suite.gateway.RefundPayment(context.Background(), payment.TransactionID, entities.NewMoney(10, "PLN"), "refund-1")
+	suite.Require().NoError(err)
+	suite.Equal(partial, repeated, "A repeated PayPal-Request-Id returns the refund already made")
+	full, err := suite.gateway.RefundPayment(context.Background(), payment.TransactionID, entities.Money{}, "refund-2")
+	suite.Require().NoError(err)
+	suite.NotEmpty(partial)
+	suite.NotEqual(partial, full)
+	_, err = suite.gateway.RefundPayment(context.Background(), payment.TransactionID, entities.NewMoney(1, "PLN"), "refund-3")
+	suite.Error(err)
+
+	capture, err := suite.gateway.GetPaymentStatus(context.Background(), payment.TransactionID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.PaymentStatusRefunded, capture.Status)
+}
+
+// TestWebhookSignaturesVerify tests emitted webhooks against the verification endpoint
+func (suite *PayPalSimulatorIntegrationTestSuite) TestWebhookSignaturesVerify() {
+	suite.completeCheckout("5006", 10)
+	suite.simulator.Flush()
+
+	webhooks := suite.received.all()
+	suite.Require().NotEmpty(webhooks)
+
+	delivered := webhooks[0]
+	suite.Equal("SUCCESS", suite.verify(delivered.headers, delivered.body, "WH-TEST"))
+	suite.Equal("FAILURE", suite.verify(delivered.headers, bytes.Replace(delivered.body, []byte("5006"), []byte("9999"), 1), "WH-TEST"))
+	suite.Equal("FAILURE", suite.verify(delivered.headers, delivered.body, "WH-OTHER"))
+}
+
+// createPayment creates a PayPal order through the gateway
+func (suite *PayPalSimulatorIntegrationTestSuite) createPayment(orderID string, amount float64) *entities.PaymentResponse {
+	response, err := suite.gateway.CreatePayment(context.Background(), &entities.PaymentRequest{
+		OrderID:   orderID,
+		Amount:    entities.NewMoney(amount, "PLN"),
+		ReturnURL: "https://proxy.example/return",
+		CancelURL: "https://proxy.example/cancel",
+	})
+	suite.Require().NoError(err)
+	suite.Require().NotEmpty(response.ApprovalURL)
+	return response
+}
+
+// completeCheckout creates, approves and captures an order
+func (suite *PayPalSimulatorIntegrationTestSuite) completeCheckout(orderID string, amount float64) *entities.Payment {
+	created := suite.createPayment(orderID, amount)
+	suite.Require().NoError(suite.simulator.ApproveOrder(created.PaymentID))
+
+	payment, err := suite.gateway.ProcessPayment(context.Background(), created.PaymentID, "")
+	suite.Require().NoError(err)
+	return payment
+}
+
+// approve follows the approval link as the buyer and returns the redirect target
+func (suite *PayPalSimulatorIntegrationTestSuite) approve(approvalURL string) *url.URL {
+	resp, err := suite.browser.Get(approvalURL)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	suite.Require().Equal(http.StatusFound, resp.StatusCode)
+
+	location, err := resp.Location()
+	suite.Require().NoError(err)
+	return location
+}
+
+// verify asks the simulator to verify a webhook transmission
+func (suite *PayPalSimulatorIntegrationTestSuite) verify(headers http.Header, body []byte, webhookID string) string {
+	token := suite.accessToken()
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"auth_algo":         headers.Get("PAYPAL-AUTH-ALGO"),
+		"cert_url":          headers.Get("PAYPAL-CERT-URL"),
+		"transmission_id":   headers.Get("PAYPAL-TRANSMISSION-ID"),
+		"transmission_sig":  headers.Get("PAYPAL-TRANSMISSION-SIG"),
+		"transmission_time": headers.Get("PAYPAL-TRANSMISSION-TIME"),
+		"webhook_id":        webhookID,
+		"webhook_event":     json.RawMessage(body),
+	})
+
+	req, err := http.NewRequest(http.MethodPost, suite.server.URL+"/v1/notifications/verify-webhook-signature", bytes.NewReader(payload))
+	suite.Require().NoError(err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+
+	var result struct {
+		VerificationStatus string `json:"verification_status"`
+	}
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&result))
+	return result.VerificationStatus
+}
+
+// accessToken fetches a token directly from the simulator
+func (suite *PayPalSimulatorIntegrationTestSuite) accessToken() string {
+	req, err := http.NewRequest(http.MethodPost, suite.server.URL+"/v1/oauth2/token", bytes.NewBufferString("grant_type=client_credentials"))
+	suite.Require().NoError(err)
+	req.SetBasicAuth("client", "secret")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&token))
+	return token.AccessToken
+}
+
+// receivedEventTypes returns delivered event types in order
+func (suite *PayPalSimulatorIntegrationTestSuite) receivedEventTypes() []string {
+	suite.simulator.Flush()
+
+	var eventTypes []string
+	for _, delivery := range suite.simulator.Webhooks() {
+		suite.Equal(http.StatusOK, delivery.StatusCode)
+		eventTypes = append(eventTypes, delivery.EventType)
+	}
+	return eventTypes
+}
+
+// TestPayPalSimulatorIntegrationTestSuite runs the PayPal simulator suite
+func TestPayPalSimulatorIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(PayPalSimulatorIntegrationTestSuite))
+}
+
+// webhookRecorder records webhook deliveries
+type webhookRecorder struct {
+	mutex      sync.Mutex
+	deliveries []recordedWebhook
+}
+
+type recordedWebhook struct {
+	headers http.Header
+	body    []byte
+}
+
+func (rec *webhookRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rec.mutex.Lock()
+	rec.deliveries = append(rec.deliveries, recordedWebhook{headers: r.Header.Clone(), body: body})
+	rec.mutex.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (rec *webhookRecorder) all() []recordedWebhook {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+	return append([]recordedWebhook(nil), rec.deliveries...)
+}
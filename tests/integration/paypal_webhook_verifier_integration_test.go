@@ -0,0 +1,292 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"hash/crc32"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/infrastructure/gateways"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/simulators"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const testWebhookID = "WH-TEST"
+
+// PayPalWebhookVerifierIntegrationTestSuite tests webhook signature verification
+type PayPalWebhookVerifierIntegrationTestSuite struct {
+	suite.Suite
+	logger     interfaces.Logger
+	roots      *x509.CertPool
+	caCert     *x509.Certificate
+	caKey      *rsa.PrivateKey
+	signingKey *rsa.PrivateKey
+	certServer *httptest.Server
+	certPEM    []byte
+	downloads  int32
+}
+
+// SetupTest creates a CA, a PayPal-style signing certificate and a TLS server serving it
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	suite.caKey, suite.caCert = suite.newCA()
+	suite.roots = x509.NewCertPool()
+	suite.roots.AddCert(suite.caCert)
+
+	suite.signingKey, suite.certPEM = suite.newLeaf("messageverificationcerts.paypal.com", suite.caCert, suite.caKey)
+
+	suite.downloads = 0
+	suite.certServer = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&suite.downloads, 1)
+		w.Write(suite.certPEM)
+	}))
+}
+
+// TearDownTest stops the certificate server
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) TearDownTest() {
+	suite.certServer.Close()
+}
+
+// TestValidSignatureIsAccepted tests local verification and certificate caching
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) TestValidSignatureIsAccepted() {
+	verifier := suite.newCertVerifier()
+	body := []byte(`{"id":"WH-1","event_type":"PAYMENT.CAPTURE.COMPLETED"}`)
+
+	suite.NoError(verifier.VerifyWebhook(context.Background(), suite.sign("TX-1", testWebhookID, body), body))
+	suite.NoError(verifier.VerifyWebhook(context.Background(), suite.sign("TX-2", testWebhookID, body), body))
+	suite.Equal(int32(1), atomic.LoadInt32(&suite.downloads), "certificate should be cached")
+}
+
+// TestTamperedDeliveryIsRejected tests body, webhook ID and header tampering
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) TestTamperedDeliveryIsRejected() {
+	verifier := suite.newCertVerifier()
+	body := []byte(`{"id":"WH-1","event_type":"PAYMENT.CAPTURE.COMPLETED"}`)
+	transmission := suite.sign("TX-1", testWebhookID, body)
+
+	err := verifier.VerifyWebhook(context.Background(), transmission, []byte(`{"id":"WH-1","event_type":"PAYMENT.CAPTURE.REFUNDED"}`))
+	suite.ErrorIs(err, gateways.ErrWebhookSignature)
+
+	err = verifier.VerifyWebhook(context.Background(), suite.sign("TX-1", "WH-OTHER", body), body)
+	suite.ErrorIs(err, gateways.ErrWebhookSignature)
+
+	replayed := transmission
+	replayed.ID = "TX-2"
+	suite.ErrorIs(verifier.VerifyWebhook(context.Background(), replayed, body), gateways.ErrWebhookSignature)
+
+	unsigned := transmission
+	unsigned.Signature = ""
+	suite.ErrorIs(verifier.VerifyWebhook(context.Background(), unsigned, body), gateways.ErrWebhookSignature)
+
+	wrongAlgo := transmission
+	wrongAlgo.AuthAlgo = "SHA1withRSA"
+	suite.ErrorIs(verifier.VerifyWebhook(context.Background(), wrongAlgo, body), gateways.ErrWebhookSignature)
+}
+
+// TestStaleTransmissionIsRejected tests that validly signed deliveries are only
+// accepted close to their transmission time
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) TestStaleTransmissionIsRejected() {
+	verifier := suite.newCertVerifier()
+	body := []byte(`{"id":"WH-1","event_type":"PAYMENT.CAPTURE.COMPLETED"}`)
+
+	suite.NoError(verifier.VerifyWebhook(context.Background(), suite.signAt("TX-1", testWebhookID, body, time.Now().Add(-2*time.Minute)), body))
+
+	stale := suite.signAt("TX-2", testWebhookID, body, time.Now().Add(-10*time.Minute))
+	suite.ErrorIs(verifier.VerifyWebhook(context.Background(), stale, body), gateways.ErrWebhookSignature)
+
+	future := suite.signAt("TX-3", testWebhookID, body, time.Now().Add(10*time.Minute))
+	suite.ErrorIs(verifier.VerifyWebhook(context.Background(), future, body), gateways.ErrWebhookSignature)
+
+	malformed := suite.sign("TX-4", testWebhookID, body)
+	malformed.Time = "yesterday"
+	suite.ErrorIs(verifier.VerifyWebhook(context.Background(), malformed, body), gateways.ErrWebhookSignature)
+
+	lenient := gateways.NewPayPalCertVerifier(gateways.PayPalCertVerifierConfig{
+		WebhookID:  testWebhookID,
+		CertHosts:  []string{"127.0.0.1"},
+		Roots:      suite.roots,
+		HTTPClient: suite.certServer.Client(),
+		MaxSkew:    time.Hour,
+	}, suite.logger)
+	suite.NoError(lenient.VerifyWebhook(context.Background(), stale, body))
+}
+
+// TestUntrustedCertificatesAreRejected tests cert URL, chain and subject checks
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) TestUntrustedCertificatesAreRejected() {
+	body := []byte(`{"id":"WH-1"}`)
+	transmission := suite.sign("TX-1", testWebhookID, body)
+
+	// Certificates from hosts other than PayPal's are never fetched
+	strict := gateways.NewPayPalCertVerifier(gateways.PayPalCertVerifierConfig{
+		WebhookID:  testWebhookID,
+		Roots:      suite.roots,
+		HTTPClient: suite.certServer.Client(),
+	}, suite.logger)
+	suite.ErrorIs(strict.VerifyWebhook(context.Background(), transmission, body), gateways.ErrWebhookSignature)
+	suite.Equal(int32(0), atomic.LoadInt32(&suite.downloads))
+
+	// A certificate that does not chain to a trusted root
+	otherKey, otherCA := suite.newCA()
+	suite.signingKey, suite.certPEM = suite.newLeaf("messageverificationcerts.paypal.com", otherCA, otherKey)
+	suite.ErrorIs(suite.newCertVerifier().VerifyWebhook(context.Background(), suite.sign("TX-1", testWebhookID, body), body), gateways.ErrWebhookSignature)
+
+	// A trusted certificate issued to someone else
+	suite.signingKey, suite.certPEM = suite.newLeaf("attacker.example.com", suite.caCert, suite.caKey)
+	suite.ErrorIs(suite.newCertVerifier().VerifyWebhook(context.Background(), suite.sign("TX-1", testWebhookID, body), body), gateways.ErrWebhookSignature)
+}
+
+// TestAPIVerificationAgainstSimulator tests the verify-webhook-signature mode
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) TestAPIVerificationAgainstSimulator() {
+	received := &webhookRecorder{}
+	receiver := httptest.NewServer(received)
+	defer receiver.Close()
+
+	simulator, err := simulators.NewPayPalSimulator(simulators.PayPalSimulatorConfig{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		WebhookURL:   receiver.URL,
+		WebhookID:    testWebhookID,
+	}, suite.logger)
+	suite.Require().NoError(err)
+	server := httptest.NewServer(simulator)
+	defer server.Close()
+
+	paypalConfig := gateways.PayPalConfig{
+		ClientID:      "client",
+		ClientSecret:  "secret",
+		APIBase:       server.URL,
+		Timeout:       5 * time.Second,
+		RetryAttempts: 1,
+		RetryDelay:    time.Millisecond,
+	}
+	gateway := gateways.NewPayPalGateway(paypalConfig, suite.logger)
+	created, err := gateway.CreatePayment(context.Background(), &entities.PaymentRequest{
+		OrderID:   "7001",
+		Amount:    entities.NewMoney(25, "PLN"),
+		ReturnURL: "https://proxy.example/return",
+		CancelURL: "https://proxy.example/cancel",
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(simulator.ApproveOrder(created.PaymentID))
+	simulator.Flush()
+
+	deliveries := received.all()
+	suite.Require().Len(deliveries, 1)
+	headers, body := deliveries[0].headers, deliveries[0].body
+	transmission := interfaces.WebhookTransmission{
+		ID:        headers.Get("PAYPAL-TRANSMISSION-ID"),
+		Time:      headers.Get("PAYPAL-TRANSMISSION-TIME"),
+		Signature: headers.Get("PAYPAL-TRANSMISSION-SIG"),
+		CertURL:   headers.Get("PAYPAL-CERT-URL"),
+		AuthAlgo:  headers.Get("PAYPAL-AUTH-ALGO"),
+	}
+
+	verifier := gateways.NewPayPalAPIVerifier(paypalConfig, testWebhookID, suite.logger)
+	suite.NoError(verifier.VerifyWebhook(context.Background(), transmission, body))
+
+	tampered := bytes.Replace(body, []byte("CHECKOUT.ORDER.APPROVED"), []byte("CHECKOUT.ORDER.COMPLETED"), 1)
+	suite.Require().NotEqual(body, tampered)
+	suite.ErrorIs(verifier.VerifyWebhook(context.Background(), transmission, tampered), gateways.ErrWebhookSignature)
+
+	wrongID := gateways.NewPayPalAPIVerifier(paypalConfig, "WH-OTHER", suite.logger)
+	suite.ErrorIs(wrongID.VerifyWebhook(context.Background(), transmission, body), gateways.ErrWebhookSignature)
+}
+
+// newCertVerifier creates a certificate verifier trusting the test CA and server
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) newCertVerifier() interfaces.WebhookVerifier {
+	return gateways.NewPayPalCertVerifier(gateways.PayPalCertVerifierConfig{
+		WebhookID:  testWebhookID,
+		CertHosts:  []string{"127.0.0.1"},
+		Roots:      suite.roots,
+		HTTPClient: suite.certServer.Client(),
+	}, suite.logger)
+}
+
+// sign produces transmission headers the way PayPal signs webhook deliveries
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) sign(transmissionID, webhookID string, body []byte) interfaces.WebhookTransmission {
+	return suite.signAt(transmissionID, webhookID, body, time.Now())
+}
+
+// signAt produces transmission headers for a delivery sent at sentAt
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) signAt(transmissionID, webhookID string, body []byte, sentAt time.Time) interfaces.WebhookTransmission {
+	transmissionTime := sentAt.UTC().Format(time.RFC3339)
+	digest := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%d", transmissionID, transmissionTime, webhookID, crc32.ChecksumIEEE(body))))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, suite.signingKey, crypto.SHA256, digest[:])
+	suite.Require().NoError(err)
+
+	return interfaces.WebhookTransmission{
+		ID:        transmissionID,
+		Time:      transmissionTime,
+		Signature: base64.StdEncoding.EncodeToString(signature),
+		CertURL:   suite.certServer.URL + "/v1/notifications/certs/CERT-TEST",
+		AuthAlgo:  "SHA256withRSA",
+	}
+}
+
+// newCA creates a self-signed certificate authority
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) newCA() (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().NoError(err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	suite.Require().NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	suite.Require().NoError(err)
+
+	return key, cert
+}
+
+// newLeaf creates a signing certificate issued by a CA, returning its key and PEM chain
+func (suite *PayPalWebhookVerifierIntegrationTestSuite) newLeaf(name string, ca *x509.Certificate, caKey *rsa.PrivateKey) (*rsa.PrivateKey, []byte) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	suite.Require().NoError(err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(12 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	suite.Require().NoError(err)
+
+	chain := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+	return key, chain
+}
+
+// TestPayPalWebhookVerifierIntegrationTestSuite runs the webhook verifier suite
+func TestPayPalWebhookVerifierIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(PayPalWebhookVerifierIntegrationTestSuite))
+}
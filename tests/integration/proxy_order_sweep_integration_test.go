@@ -0,0 +1,296 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ProxyOrderSweepIntegrationTestSuite tests the closing of proxy orders left behind by abandoned checkouts
+type ProxyOrderSweepIntegrationTestSuite struct {
+	suite.Suite
+	cfg         *config.Config
+	wooCommerce *fakeWooCommerce
+	mappings    interfaces.OrderMappingRepository
+	sweeper     *usecases.ProxyOrderSweepUseCase
+}
+
+// SetupTest wires fresh stores with pending MagicSpore orders 1001 and 1002
+func (suite *ProxyOrderSweepIntegrationTestSuite) SetupTest() {
+	logger := infraHttp.NewDefaultLogger("error")
+
+	suite.cfg = config.NewConfig()
+	suite.cfg.Proxy.OrderTTL = time.Hour
+	suite.cfg.Sweep.TTL = 2 * time.Hour
+	suite.cfg.Sweep.Action = config.SweepActionCancel
+	suite.cfg.Sweep.RestoreMagicOrder = false
+	suite.cfg.Sweep.BatchSize = 100
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(logger)
+	suite.sweeper = usecases.NewProxyOrderSweepUseCase(suite.wooCommerce, suite.mappings, services.NewOrderDomainService(logger), logger, suite.cfg)
+
+	suite.wooCommerce.addMagicOrder(1001, 49.99)
+	suite.wooCommerce.addMagicOrder(1002, 19.99)
+}
+
+// TestAbandonedProxyOrderIsCancelled tests that an unpaid proxy order past the TTL is cancelled and its mapping expired
+func (suite *ProxyOrderSweepIntegrationTestSuite) TestAbandonedProxyOrderIsCancelled() {
+	proxyOrderID := suite.checkout(1001, 3*time.Hour)
+	suite.Nil(suite.sweeper.LastReport())
+
+	report := suite.sweep()
+
+	suite.Equal(1, report.Scanned)
+	suite.Equal(1, report.Expired)
+	suite.Require().Len(report.Orders, 1)
+	swept := report.Orders[0]
+	suite.Equal("1001", swept.OrderID)
+	suite.Equal(proxyOrderID, swept.OITAMOrderID)
+	suite.Equal(usecases.SweepOutcomeCancelled, swept.Outcome)
+	suite.Equal(string(entities.StatusPending), swept.OITAMStatus)
+	suite.False(swept.MagicRestored)
+
+	suite.Equal(entities.StatusCancelled, suite.oitamStatus(proxyOrderID))
+	suite.Equal(entities.MappingStateExpired, suite.mappingState(proxyOrderID))
+	suite.Empty(suite.wooCommerce.notesFor("1001"), "The MagicSpore order is only touched when restoring is enabled")
+	suite.Same(report, suite.sweeper.LastReport())
+
+	// The expired mapping is checked again, but its proxy order is already closed
+	report = suite.sweep()
+	suite.Equal(1, report.Scanned)
+	suite.Empty(report.Orders)
+}
+
+// TestAbandonedProxyOrderIsTrashedAndOrderRestored tests trashing with the MagicSpore order moved back to pending
+func (suite *ProxyOrderSweepIntegrationTestSuite) TestAbandonedProxyOrderIsTrashedAndOrderRestored() {
+	suite.cfg.Sweep.Action = config.SweepActionTrash
+	suite.cfg.Sweep.RestoreMagicOrder = true
+	proxyOrderID := suite.checkout(1001, 3*time.Hour)
+	suite.wooCommerce.setMagicOrderStatus(1001, entities.StatusFailed)
+
+	report := suite.sweep()
+
+	suite.Equal(1, report.Expired)
+	suite.Require().Len(report.Orders, 1)
+	suite.Equal(usecases.SweepOutcomeTrashed, report.Orders[0].Outcome)
+	suite.True(report.Orders[0].MagicRestored)
+
+	suite.Equal(entities.OrderStatus("trash"), suite.oitamStatus(proxyOrderID))
+	suite.Equal(entities.MappingStateExpired, suite.mappingState(proxyOrderID))
+	restored := suite.magicOrder("1001")
+	suite.Equal(entities.StatusPending, restored.Status)
+	history := entities.StatusHistoryFromMetaData(restored.MetaData)
+	suite.Require().NotEmpty(history, "The restore is recorded in the status history")
+	suite.Equal(string(entities.StatusFailed), history[len(history)-1].From)
+	suite.Equal(string(entities.StatusPending), history[len(history)-1].To)
+	suite.Equal([]string{"PayPal checkout abandoned: proxy order " + proxyOrderID + " was not paid within 2h0m0s and has been closed. The order is awaiting payment again."},
+		suite.wooCommerce.notesFor("1001"))
+}
+
+// TestRecentAndPaidProxyOrdersAreKept tests that fresh and possibly paid proxy orders are not closed
+func (suite *ProxyOrderSweepIntegrationTestSuite) TestRecentAndPaidProxyOrdersAreKept() {
+	recent := suite.checkout(1001, 30*time.Minute)
+	paid := suite.checkout(1002, 3*time.Hour)
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.oitamOrders, paid, func(order *entities.Order) {
+		order.Status = entities.StatusProcessing
+		order.TransactionID = "CAPTURE-1"
+	}))
+
+	report := suite.sweep()
+
+	suite.Equal(1, report.Scanned, "Recent mappings are not listed")
+	suite.Equal(0, report.Expired)
+	suite.Equal(1, report.Skipped)
+	suite.Require().Len(report.Orders, 1)
+	suite.Equal(paid, report.Orders[0].OITAMOrderID)
+	suite.Equal(usecases.SweepOutcomeSkipped, report.Orders[0].Outcome)
+
+	suite.Equal(entities.StatusPending, suite.oitamStatus(recent))
+	suite.Equal(entities.MappingStateActive, suite.mappingState(recent))
+	suite.Equal(entities.StatusProcessing, suite.oitamStatus(paid))
+	suite.Equal(entities.MappingStateActive, suite.mappingState(paid), "Paid proxy orders are left to reconciliation")
+}
+
+// TestProxyCreatedAtMetaIsPreferred tests that the proxy order's own creation stamp decides its age
+func (suite *ProxyOrderSweepIntegrationTestSuite) TestProxyCreatedAtMetaIsPreferred() {
+	proxyOrderID := suite.checkout(1001, 3*time.Hour)
+	suite.stampCreatedAt(proxyOrderID, time.Now().Add(-30*time.Minute))
+
+	report := suite.sweep()
+
+	suite.Equal(1, report.Scanned)
+	suite.Empty(report.Orders)
+	suite.Equal(entities.StatusPending, suite.oitamStatus(proxyOrderID))
+	suite.Equal(entities.MappingStateActive, suite.mappingState(proxyOrderID))
+}
+
+// TestClosedProxyOrderOnlyExpiresMapping tests that a proxy order closed on OITAM is not touched again
+func (suite *ProxyOrderSweepIntegrationTestSuite) TestClosedProxyOrderOnlyExpiresMapping() {
+	proxyOrderID := suite.checkout(1001, 3*time.Hour)
+	suite.Require().NoError(suite.wooCommerce.UpdateOITAMOrderStatus(context.Background(), proxyOrderID, entities.StatusCancelled))
+
+	report := suite.sweep()
+
+	suite.Equal(1, report.Expired)
+	suite.Require().Len(report.Orders, 1)
+	suite.Equal(usecases.SweepOutcomeAlreadyClosed, report.Orders[0].Outcome)
+	suite.Equal(entities.MappingStateExpired, suite.mappingState(proxyOrderID))
+}
+
+// TestPendingProxyOrdersOfClosedMappingsAreClosed tests that cancelled and
+// superseded checkouts do not leave a payable proxy order behind
+func (suite *ProxyOrderSweepIntegrationTestSuite) TestPendingProxyOrdersOfClosedMappingsAreClosed() {
+	suite.cfg.Sweep.RestoreMagicOrder = true
+	cancelled := suite.checkout(1001, 3*time.Hour)
+	expired := suite.checkout(1002, 3*time.Hour)
+	suite.Require().NoError(suite.mappings.UpdateState(context.Background(), cancelled, entities.MappingStateCancelled))
+	suite.Require().NoError(suite.mappings.UpdateState(context.Background(), expired, entities.MappingStateExpired))
+
+	report := suite.sweep()
+
+	suite.Equal(2, report.Scanned)
+	suite.Equal(2, report.Expired)
+	suite.Require().Len(report.Orders, 2)
+	for _, swept := range report.Orders {
+		suite.Equal(usecases.SweepOutcomeCancelled, swept.Outcome, swept.OrderID)
+		suite.False(swept.MagicRestored, "Closed mappings never restore their MagicSpore order")
+	}
+	suite.Equal(entities.StatusCancelled, suite.oitamStatus(cancelled))
+	suite.Equal(entities.StatusCancelled, suite.oitamStatus(expired))
+	suite.Equal(entities.MappingStateCancelled, suite.mappingState(cancelled))
+	suite.Equal(entities.MappingStateExpired, suite.mappingState(expired))
+	suite.Empty(suite.wooCommerce.notesFor("1001"))
+	suite.Empty(suite.wooCommerce.notesFor("1002"))
+
+	// Both proxy orders are closed now, so nothing is reported again
+	report = suite.sweep()
+	suite.Equal(2, report.Scanned)
+	suite.Empty(report.Orders)
+}
+
+// TestPaidProxyOrderOfClosedMappingNeedsReview tests that a closed mapping
+// whose proxy order was paid anyway is reported rather than closed
+func (suite *ProxyOrderSweepIntegrationTestSuite) TestPaidProxyOrderOfClosedMappingNeedsReview() {
+	proxyOrderID := suite.checkout(1001, 3*time.Hour)
+	suite.Require().NoError(suite.mappings.UpdateState(context.Background(), proxyOrderID, entities.MappingStateCancelled))
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.oitamOrders, proxyOrderID, func(order *entities.Order) {
+		order.Status = entities.StatusProcessing
+		order.TransactionID = "CAPTURE-1"
+	}))
+
+	report := suite.sweep()
+
+	suite.Equal(1, report.Skipped)
+	suite.Require().Len(report.Orders, 1)
+	suite.Equal(usecases.SweepOutcomeSkipped, report.Orders[0].Outcome)
+	suite.Contains(report.Orders[0].Detail, "needs review")
+	suite.Equal(entities.StatusProcessing, suite.oitamStatus(proxyOrderID))
+	suite.Equal(entities.MappingStateCancelled, suite.mappingState(proxyOrderID))
+}
+
+// TestClosedMappingsPastTheWindowAreNotListed tests that old closed mappings are not checked on every sweep
+func (suite *ProxyOrderSweepIntegrationTestSuite) TestClosedMappingsPastTheWindowAreNotListed() {
+	suite.cfg.Sweep.Interval = 10 * time.Minute
+	old := suite.checkout(1001, 5*time.Hour)
+	suite.Require().NoError(suite.mappings.UpdateState(context.Background(), old, entities.MappingStateCancelled))
+
+	report := suite.sweep()
+
+	suite.Equal(0, report.Scanned)
+	suite.Equal(entities.StatusPending, suite.oitamStatus(old))
+}
+
+// TestRestoreLeavesPaidAndCancelledOrdersAlone tests that only MagicSpore orders awaiting payment are restored
+func (suite *ProxyOrderSweepIntegrationTestSuite) TestRestoreLeavesPaidAndCancelledOrdersAlone() {
+	suite.cfg.Sweep.RestoreMagicOrder = true
+	first := suite.checkout(1001, 3*time.Hour)
+	second := suite.checkout(1002, 3*time.Hour)
+	suite.wooCommerce.setMagicOrderStatus(1001, entities.StatusProcessing)
+	suite.wooCommerce.setMagicOrderStatus(1002, entities.StatusCancelled)
+
+	report := suite.sweep()
+
+	suite.Equal(2, report.Expired)
+	for _, swept := range report.Orders {
+		suite.False(swept.MagicRestored, swept.OrderID)
+	}
+	suite.Equal(entities.StatusCancelled, suite.oitamStatus(first))
+	suite.Equal(entities.StatusCancelled, suite.oitamStatus(second))
+	suite.Equal(entities.StatusProcessing, suite.magicOrder("1001").Status)
+	suite.Equal(entities.StatusCancelled, suite.magicOrder("1002").Status)
+	suite.Empty(suite.wooCommerce.notesFor("1001"))
+	suite.Empty(suite.wooCommerce.notesFor("1002"))
+}
+
+// checkout creates a proxy order and mapping for a MagicSpore order as if the
+// customer had been redirected age ago
+func (suite *ProxyOrderSweepIntegrationTestSuite) checkout(orderID int, age time.Duration) string {
+	magicOrder := suite.magicOrder(strconv.Itoa(orderID))
+	proxyOrder, err := suite.wooCommerce.CreateOITAMOrder(context.Background(), magicOrder.ToAnonymousOrder(nil))
+	suite.Require().NoError(err)
+
+	createdAt := time.Now().Add(-age)
+	mapping := entities.NewOrderMapping(strconv.Itoa(orderID), proxyOrder, suite.cfg.Proxy.OrderTTL)
+	mapping.CreatedAt = createdAt
+	mapping.UpdatedAt = createdAt
+	mapping.ExpiresAt = createdAt.Add(suite.cfg.Proxy.OrderTTL)
+	suite.Require().NoError(suite.mappings.Create(context.Background(), mapping))
+
+	proxyOrderID := strconv.Itoa(proxyOrder.ID)
+	suite.stampCreatedAt(proxyOrderID, createdAt)
+	return proxyOrderID
+}
+
+// stampCreatedAt replaces the _proxy_created_at meta of a proxy order
+func (suite *ProxyOrderSweepIntegrationTestSuite) stampCreatedAt(proxyOrderID string, createdAt time.Time) {
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.oitamOrders, proxyOrderID, func(order *entities.Order) {
+		for i := range order.MetaData {
+			if order.MetaData[i].Key == "_proxy_created_at" {
+				order.MetaData[i].Value = strconv.FormatInt(createdAt.Unix(), 10)
+			}
+		}
+	}))
+}
+
+func (suite *ProxyOrderSweepIntegrationTestSuite) sweep() *dto.SweepReport {
+	report, err := suite.sweeper.Execute(context.Background())
+	suite.Require().NoError(err)
+	return report
+}
+
+func (suite *ProxyOrderSweepIntegrationTestSuite) magicOrder(orderID string) *entities.Order {
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), orderID)
+	suite.Require().NoError(err)
+	return order
+}
+
+func (suite *ProxyOrderSweepIntegrationTestSuite) oitamStatus(proxyOrderID string) entities.OrderStatus {
+	order, err := suite.wooCommerce.GetOITAMOrder(context.Background(), proxyOrderID)
+	suite.Require().NoError(err)
+	return order.Status
+}
+
+func (suite *ProxyOrderSweepIntegrationTestSuite) mappingState(proxyOrderID string) entities.OrderMappingState {
+	mapping, err := suite.mappings.GetByOITAMOrderID(context.Background(), proxyOrderID)
+	suite.Require().NoError(err)
+	return mapping.State
+}
+
+// TestProxyOrderSweepIntegrationTestSuite runs the proxy order sweep suite
+func TestProxyOrderSweepIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(ProxyOrderSweepIntegrationTestSuite))
+}
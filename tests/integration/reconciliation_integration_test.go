@@ -0,0 +1,287 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// ReconciliationIntegrationTestSuite tests the repair of drift between MagicSpore, OITAM and PayPal
+type ReconciliationIntegrationTestSuite struct {
+	suite.Suite
+	cfg          *config.Config
+	wooCommerce  *fakeWooCommerce
+	gateway      *fakePaymentGateway
+	payments     interfaces.PaymentRepository
+	mappings     interfaces.OrderMappingRepository
+	logger       interfaces.Logger
+	proxyOrderID string
+}
+
+// SetupTest wires fresh stores and a proxy order for MagicSpore order 1001
+func (suite *ReconciliationIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	suite.cfg = config.NewConfig()
+	suite.cfg.Proxy.OrderTTL = time.Hour
+	suite.cfg.Reconcile.MinAge = 0
+	suite.cfg.Reconcile.BatchSize = 100
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.gateway = newFakePaymentGateway()
+	suite.payments = repositories.NewMemoryPaymentRepository(suite.logger)
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(suite.logger)
+
+	suite.wooCommerce.addMagicOrder(1001, 49.99)
+	redirect := usecases.NewPaymentRedirectUseCase(
+		suite.wooCommerce,
+		suite.mappings,
+		infraHttp.NewURLBuilder(suite.cfg, suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		nil,
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+	response, err := redirect.Execute(context.Background(), &dto.PaymentRedirectRequest{
+		OrderID: "1001",
+		Domain:  "magicspore.com",
+	})
+	suite.Require().NoError(err)
+	suite.proxyOrderID = response.ProxyOrderID
+}
+
+// newReconciliationUseCase builds the reconciler with or without a payment gateway
+func (suite *ReconciliationIntegrationTestSuite) newReconciliationUseCase(gateway interfaces.PaymentGateway) *usecases.ReconciliationUseCase {
+	paymentService := services.NewPaymentDomainService(suite.logger)
+	orderService := services.NewOrderDomainService(suite.logger)
+	returnUseCase := usecases.NewPaymentReturnUseCase(
+		suite.wooCommerce,
+		suite.payments,
+		suite.mappings,
+		gateway,
+		paymentService,
+		orderService,
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+	return usecases.NewReconciliationUseCase(
+		returnUseCase,
+		suite.wooCommerce,
+		suite.payments,
+		suite.mappings,
+		gateway,
+		suite.logger,
+		suite.cfg,
+	)
+}
+
+// TestMissedPaymentIsRecovered tests that a paid proxy order the MagicSpore order never heard about is applied
+func (suite *ReconciliationIntegrationTestSuite) TestMissedPaymentIsRecovered() {
+	suite.payProxyOrder("CAPTURE-1")
+	suite.gateway.setCapture("CAPTURE-1", entities.PaymentStatusCompleted, 49.99)
+	reconciler := suite.newReconciliationUseCase(suite.gateway)
+	suite.Nil(reconciler.LastReport())
+
+	report := suite.reconcile(reconciler)
+
+	suite.Equal(1, report.Scanned)
+	suite.Equal(1, report.Repaired)
+	suite.Require().Len(report.Discrepancies, 1)
+	discrepancy := report.Discrepancies[0]
+	suite.Equal(usecases.DiscrepancyMissedPayment, discrepancy.Kind)
+	suite.Equal("1001", discrepancy.OrderID)
+	suite.Equal(suite.proxyOrderID, discrepancy.OITAMOrderID)
+	suite.Equal("CAPTURE-1", discrepancy.TransactionID)
+	suite.Equal(string(entities.PaymentStatusCompleted), discrepancy.PayPalStatus)
+	suite.True(discrepancy.Repaired)
+
+	order := suite.magicOrder()
+	suite.Equal(entities.StatusProcessing, order.Status)
+	suite.Equal("CAPTURE-1", order.TransactionID)
+	suite.Equal([]string{"Payment recovered by reconciliation: proxy order " + suite.proxyOrderID + " was paid with PayPal capture CAPTURE-1 (49.99 PLN)."},
+		suite.wooCommerce.notesFor("1001"))
+	suite.Equal(entities.MappingStatePaid, suite.mappingState())
+
+	payment, err := suite.payments.GetByPaymentID(context.Background(), "CAPTURE-1")
+	suite.Require().NoError(err)
+	suite.Equal("1001", payment.OrderID)
+	suite.Same(report, reconciler.LastReport())
+
+	// The repaired mapping is no longer active, so a second run finds nothing
+	report = suite.reconcile(reconciler)
+	suite.Equal(0, report.Scanned)
+	suite.Empty(report.Discrepancies)
+}
+
+// TestUnsettledCaptureIsReported tests that a proxy order paid with a pending capture is left for a later run
+func (suite *ReconciliationIntegrationTestSuite) TestUnsettledCaptureIsReported() {
+	suite.payProxyOrder("CAPTURE-2")
+	suite.gateway.setCapture("CAPTURE-2", entities.PaymentStatusPending, 49.99)
+
+	report := suite.reconcile(suite.newReconciliationUseCase(suite.gateway))
+
+	suite.Equal(0, report.Repaired)
+	suite.Equal(1, report.Unresolved)
+	suite.Require().Len(report.Discrepancies, 1)
+	suite.Equal(usecases.DiscrepancyPaymentUnconfirmed, report.Discrepancies[0].Kind)
+	suite.Equal(string(entities.PaymentStatusPending), report.Discrepancies[0].PayPalStatus)
+	suite.False(report.Discrepancies[0].Repaired)
+
+	suite.Equal(entities.StatusPending, suite.magicOrder().Status)
+	suite.Equal(entities.MappingStateActive, suite.mappingState())
+}
+
+// TestTransactionIDDriftIsRepaired tests that a paid order missing its capture ID gets the proxy order's
+func (suite *ReconciliationIntegrationTestSuite) TestTransactionIDDriftIsRepaired() {
+	suite.payProxyOrder("CAPTURE-3")
+	suite.wooCommerce.setMagicOrderStatus(1001, entities.StatusProcessing)
+
+	report := suite.reconcile(suite.newReconciliationUseCase(nil))
+
+	suite.Equal(1, report.Repaired)
+	suite.Require().Len(report.Discrepancies, 1)
+	suite.Equal(usecases.DiscrepancyTransactionID, report.Discrepancies[0].Kind)
+	suite.Empty(report.Discrepancies[0].PayPalStatus, "Without a gateway PayPal is not consulted")
+
+	order := suite.magicOrder()
+	suite.Equal(entities.StatusProcessing, order.Status)
+	suite.Equal("CAPTURE-3", order.TransactionID)
+	suite.Equal(entities.MappingStatePaid, suite.mappingState())
+}
+
+// TestDuplicatePaymentIsReported tests that an order paid by two captures is left for a manual refund
+func (suite *ReconciliationIntegrationTestSuite) TestDuplicatePaymentIsReported() {
+	suite.payProxyOrder("CAPTURE-5")
+	suite.Require().NoError(suite.wooCommerce.UpdateMagicOrderPayment(context.Background(), "1001", &entities.Payment{
+		TransactionID: "CAPTURE-4",
+		Status:        entities.PaymentStatusCompleted,
+	}))
+	suite.Require().NoError(suite.payments.Create(context.Background(), &entities.Payment{
+		ID:        "pay_1",
+		OrderID:   "1001",
+		PaymentID: "CAPTURE-4",
+		Status:    entities.PaymentStatusCompleted,
+		Amount:    entities.NewMoney(49.99, "PLN"),
+	}))
+
+	report := suite.reconcile(suite.newReconciliationUseCase(nil))
+
+	suite.Equal(1, report.Unresolved)
+	suite.Require().Len(report.Discrepancies, 1)
+	suite.Equal(usecases.DiscrepancyDuplicatePayment, report.Discrepancies[0].Kind)
+	suite.Equal("CAPTURE-4", suite.magicOrder().TransactionID, "The recorded capture should be kept")
+	suite.Equal(entities.MappingStateActive, suite.mappingState())
+}
+
+// TestClosedProxyOrderClosesMapping tests that a cancelled proxy order no longer counts as outstanding
+func (suite *ReconciliationIntegrationTestSuite) TestClosedProxyOrderClosesMapping() {
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.oitamOrders, suite.proxyOrderID, func(order *entities.Order) {
+		order.Status = entities.StatusCancelled
+	}))
+
+	report := suite.reconcile(suite.newReconciliationUseCase(nil))
+
+	suite.Equal(1, report.Repaired)
+	suite.Require().Len(report.Discrepancies, 1)
+	suite.Equal(usecases.DiscrepancyProxyOrderClosed, report.Discrepancies[0].Kind)
+	suite.Equal(entities.MappingStateCancelled, suite.mappingState())
+	suite.Equal(entities.StatusPending, suite.magicOrder().Status)
+}
+
+// TestOutstandingAndRecentOrdersAreSkipped tests that unpaid and fresh proxy orders are not reported
+func (suite *ReconciliationIntegrationTestSuite) TestOutstandingAndRecentOrdersAreSkipped() {
+	reconciler := suite.newReconciliationUseCase(nil)
+
+	report := suite.reconcile(reconciler)
+	suite.Equal(1, report.Scanned)
+	suite.Empty(report.Discrepancies, "A proxy order awaiting payment is not a discrepancy")
+
+	suite.payProxyOrder("CAPTURE-6")
+	suite.cfg.Reconcile.MinAge = time.Hour
+
+	report = suite.reconcile(reconciler)
+	suite.Equal(0, report.Scanned, "Recent proxy orders are left to the return and webhook flows")
+	suite.Equal(entities.StatusPending, suite.magicOrder().Status)
+}
+
+// TestBatchesContinueWhereThePreviousRunStopped tests that older proxy orders
+// do not keep newer ones from being checked
+func (suite *ReconciliationIntegrationTestSuite) TestBatchesContinueWhereThePreviousRunStopped() {
+	suite.cfg.Reconcile.BatchSize = 2
+	now := time.Now()
+	for i, proxyOrderID := range []int{6001, 6002, 6003} {
+		// MagicSpore orders that cannot be fetched report which proxy orders were checked
+		mapping := entities.NewOrderMapping(strconv.Itoa(2001+i), &entities.Order{ID: proxyOrderID}, time.Hour)
+		mapping.CreatedAt = now.Add(-time.Duration(3-i) * time.Hour)
+		suite.Require().NoError(suite.mappings.Create(context.Background(), mapping))
+	}
+	reconciler := suite.newReconciliationUseCase(nil)
+
+	checked := func(report *dto.ReconciliationReport) []string {
+		var proxyOrderIDs []string
+		for _, discrepancy := range report.Discrepancies {
+			proxyOrderIDs = append(proxyOrderIDs, discrepancy.OITAMOrderID)
+		}
+		return proxyOrderIDs
+	}
+
+	report := suite.reconcile(reconciler)
+	suite.Equal(2, report.Scanned)
+	suite.Equal([]string{"6001", "6002"}, checked(report))
+
+	report = suite.reconcile(reconciler)
+	suite.Equal(2, report.Scanned, "The newest proxy order is checked in the second run")
+	suite.Equal([]string{"6003"}, checked(report))
+
+	report = suite.reconcile(reconciler)
+	suite.Equal(2, report.Scanned, "The run after the newest proxy order starts over with the oldest")
+	suite.Equal([]string{"6001", "6002"}, checked(report))
+}
+
+// payProxyOrder marks the OITAM order paid the way WooCommerce PayPal does
+func (suite *ReconciliationIntegrationTestSuite) payProxyOrder(captureID string) {
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.oitamOrders, suite.proxyOrderID, func(order *entities.Order) {
+		order.Status = entities.StatusProcessing
+		order.TransactionID = captureID
+		order.Total = entities.NewMoney(49.99, "PLN")
+	}))
+}
+
+func (suite *ReconciliationIntegrationTestSuite) reconcile(reconciler *usecases.ReconciliationUseCase) *dto.ReconciliationReport {
+	report, err := reconciler.Execute(context.Background())
+	suite.Require().NoError(err)
+	return report
+}
+
+func (suite *ReconciliationIntegrationTestSuite) magicOrder() *entities.Order {
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	return order
+}
+
+func (suite *ReconciliationIntegrationTestSuite) mappingState() entities.OrderMappingState {
+	mapping, err := suite.mappings.GetByOITAMOrderID(context.Background(), suite.proxyOrderID)
+	suite.Require().NoError(err)
+	return mapping.State
+}
+
+// TestReconciliationIntegrationTestSuite runs the reconciliation suite
+func TestReconciliationIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(ReconciliationIntegrationTestSuite))
+}
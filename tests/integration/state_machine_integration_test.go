@@ -0,0 +1,166 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// StateMachineIntegrationTestSuite tests the order and payment state machines
+// and the use cases guarded by them
+type StateMachineIntegrationTestSuite struct {
+	suite.Suite
+	logger       interfaces.Logger
+	orderService *services.OrderDomainService
+}
+
+// SetupTest creates a fresh order domain service
+func (suite *StateMachineIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+	suite.orderService = services.NewOrderDomainService(suite.logger)
+}
+
+// TestOrderTransitions tests allowed and rejected order status changes
+func (suite *StateMachineIntegrationTestSuite) TestOrderTransitions() {
+	tests := []struct {
+		from    entities.OrderStatus
+		to      entities.OrderStatus
+		allowed bool
+	}{
+		{entities.StatusPending, entities.StatusProcessing, true},
+		{entities.StatusPending, entities.StatusCancelled, true},
+		{entities.StatusOnHold, entities.StatusRefunded, true},
+		{entities.StatusProcessing, entities.StatusCompleted, true},
+		{entities.StatusCompleted, entities.StatusRefunded, true},
+		{entities.StatusCancelled, entities.StatusProcessing, true},
+		{entities.StatusFailed, entities.StatusPending, true},
+		{entities.StatusCompleted, entities.StatusFailed, false},
+		{entities.StatusCompleted, entities.StatusPending, false},
+		{entities.StatusProcessing, entities.StatusCancelled, false},
+		{entities.StatusRefunded, entities.StatusProcessing, false},
+		{entities.StatusCancelled, entities.StatusCompleted, false},
+	}
+
+	for _, tt := range tests {
+		suite.Equal(tt.allowed, tt.from.CanTransitionTo(tt.to), "%s -> %s", tt.from, tt.to)
+	}
+
+	suite.True(entities.StatusRefunded.CanTransitionTo(entities.StatusRefunded), "Staying in a status is always allowed")
+}
+
+// TestPaymentTransitions tests allowed and rejected payment status changes
+func (suite *StateMachineIntegrationTestSuite) TestPaymentTransitions() {
+	tests := []struct {
+		from    entities.PaymentStatus
+		to      entities.PaymentStatus
+		allowed bool
+	}{
+		{entities.PaymentStatusPending, entities.PaymentStatusCompleted, true},
+		{entities.PaymentStatusApproved, entities.PaymentStatusFailed, true},
+		{entities.PaymentStatusCompleted, entities.PaymentStatusRefunded, true},
+		{entities.PaymentStatusCompleted, entities.PaymentStatusFailed, false},
+		{entities.PaymentStatusRefunded, entities.PaymentStatusCompleted, false},
+		{entities.PaymentStatusCancelled, entities.PaymentStatusCompleted, false},
+	}
+
+	for _, tt := range tests {
+		suite.Equal(tt.allowed, tt.from.CanTransitionTo(tt.to), "%s -> %s", tt.from, tt.to)
+	}
+}
+
+// TestTransitionHistoryAndGuardErrors tests history recording and rejected transitions
+func (suite *StateMachineIntegrationTestSuite) TestTransitionHistoryAndGuardErrors() {
+	order := &entities.Order{ID: 1001, Status: entities.StatusPending}
+
+	suite.Require().NoError(order.TransitionTo(entities.StatusProcessing, "payment captured"))
+	suite.Require().NoError(order.TransitionTo(entities.StatusProcessing, "duplicate capture"))
+	suite.Require().NoError(order.TransitionTo(entities.StatusCompleted, "shipped"))
+
+	suite.Require().Len(order.StatusHistory, 2, "A no-op transition should not be recorded")
+	suite.Equal("pending", order.StatusHistory[0].From)
+	suite.Equal("processing", order.StatusHistory[0].To)
+	suite.Equal("payment captured", order.StatusHistory[0].Reason)
+	suite.False(order.StatusHistory[0].At.IsZero())
+
+	err := order.TransitionTo(entities.StatusFailed, entities.EventPaymentCaptureDenied)
+	suite.Require().Error(err)
+	suite.True(errors.Is(err, entities.ErrInvalidStatusTransition))
+	suite.Equal("order cannot move from completed to failed", err.Error())
+	suite.Equal(entities.StatusCompleted, order.Status, "A rejected transition should leave the order unchanged")
+	suite.Len(order.StatusHistory, 2)
+
+	payment := &entities.Payment{Status: entities.PaymentStatusPending}
+	suite.Require().NoError(payment.MarkAsCompleted("TXN-1"))
+	suite.Equal("TXN-1", payment.TransactionID)
+	suite.Require().Len(payment.StatusHistory, 1)
+
+	var transitionErr *entities.StatusTransitionError
+	suite.Require().True(errors.As(payment.MarkAsFailed("late failure"), &transitionErr))
+	suite.Equal("payment", transitionErr.Entity)
+	suite.Equal(entities.PaymentStatusCompleted, payment.Status)
+}
+
+// TestDetermineOrderStatusRespectsStateMachine tests that a payment status never
+// maps to an order status the order cannot move to
+func (suite *StateMachineIntegrationTestSuite) TestDetermineOrderStatusRespectsStateMachine() {
+	ctx := context.Background()
+	failed := &entities.Payment{Status: entities.PaymentStatusFailed}
+
+	pending := &entities.Order{Status: entities.StatusPending}
+	suite.Equal(entities.StatusFailed, suite.orderService.DetermineOrderStatus(ctx, pending, failed))
+
+	completed := &entities.Order{Status: entities.StatusCompleted}
+	suite.Equal(entities.StatusCompleted, suite.orderService.DetermineOrderStatus(ctx, completed, failed))
+
+	suite.Equal(entities.StatusProcessing, suite.orderService.DetermineOrderStatus(ctx, nil, &entities.Payment{Status: entities.PaymentStatusCompleted}))
+}
+
+// TestCancelRedirectKeepsPaidOrder tests that a late cancel redirect does not
+// cancel an order the state machine has moved past payment
+func (suite *StateMachineIntegrationTestSuite) TestCancelRedirectKeepsPaidOrder() {
+	wooCommerce := newFakeWooCommerce()
+	wooCommerce.addMagicOrder(1001, 49.99)
+	wooCommerce.addMagicOrder(1002, 19.99)
+	wooCommerce.setMagicOrderStatus(1001, entities.StatusProcessing)
+
+	useCase := usecases.NewPaymentCancelUseCase(
+		wooCommerce,
+		repositories.NewMemoryPaymentRepository(suite.logger),
+		repositories.NewMemoryOrderMappingRepository(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		suite.orderService,
+		suite.logger,
+		config.NewConfig(),
+	)
+
+	for _, orderID := range []string{"1001", "1002"} {
+		_, err := useCase.Execute(context.Background(), &dto.PaymentCancelRequest{OrderID: orderID})
+		suite.Require().NoError(err)
+	}
+
+	paid, err := wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusProcessing, paid.Status)
+
+	unpaid, err := wooCommerce.GetMagicOrder(context.Background(), "1002")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusCancelled, unpaid.Status)
+}
+
+// TestStateMachineIntegrationTestSuite runs the state machine suite
+func TestStateMachineIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(StateMachineIntegrationTestSuite))
+}
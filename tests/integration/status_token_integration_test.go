@@ -0,0 +1,258 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+	"paypal-proxy/internal/presentation/handlers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/suite"
+)
+
+const testStatusTokenSecret = "test-status-token-secret-32-chars-min"
+
+// StatusTokenIntegrationTestSuite tests storefront status tokens from their
+// issuance in the redirect flow to the public status endpoint
+type StatusTokenIntegrationTestSuite struct {
+	suite.Suite
+	cfg          *config.Config
+	logger       interfaces.Logger
+	wooCommerce  *fakeWooCommerce
+	orderFetches int32
+	mappings     interfaces.OrderMappingRepository
+	publicStatus *usecases.PublicOrderStatusUseCase
+}
+
+// SetupTest wires the use cases with status tokens enabled for MagicSpore order 1001
+func (suite *StatusTokenIntegrationTestSuite) SetupTest() {
+	suite.logger = infraHttp.NewDefaultLogger("error")
+
+	suite.cfg = config.NewConfig()
+	suite.cfg.Proxy.OrderTTL = time.Hour
+	suite.cfg.Proxy.VerifyPollAttempts = 0
+	suite.cfg.StatusToken.Secret = testStatusTokenSecret
+	suite.cfg.StatusToken.TTL = time.Hour
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(suite.logger)
+	suite.orderFetches = 0
+	suite.publicStatus = usecases.NewPublicOrderStatusUseCase(&countingWooCommerce{fakeWooCommerce: suite.wooCommerce, fetches: &suite.orderFetches}, suite.cfg, suite.logger)
+
+	suite.wooCommerce.addMagicOrder(1001, 49.99)
+	suite.wooCommerce.addMagicOrder(1002, 19.99)
+}
+
+// TestRedirectIssuesStatusToken tests that a checkout redirect hands out a token for its order
+func (suite *StatusTokenIntegrationTestSuite) TestRedirectIssuesStatusToken() {
+	response := suite.redirect("1001")
+	suite.Require().NotEmpty(response.StatusToken)
+	suite.NotContains(response.StatusToken, "wc_order_magic_1001", "the token must not carry the order key")
+
+	status, err := suite.publicStatus.Execute(context.Background(), response.StatusToken)
+	suite.Require().NoError(err)
+	suite.Equal("1001", status.OrderID)
+	suite.Equal(string(entities.StatusPending), status.Status)
+	suite.Nil(status.DatePaid)
+
+	reused := suite.redirect("1001")
+	suite.Equal("redirect_reused", reused.Status)
+	suite.NotEmpty(reused.StatusToken)
+}
+
+// TestStorefrontURLsCarryStatusToken tests that the customer lands back on
+// magicspore.com with a working token
+func (suite *StatusTokenIntegrationTestSuite) TestStorefrontURLsCarryStatusToken() {
+	suite.redirect("1001")
+
+	response, err := suite.newReturnUseCase().Execute(context.Background(), &dto.PaymentReturnRequest{OrderID: "1001"})
+	suite.Require().NoError(err)
+	suite.Equal("pending", response.Status)
+	suite.assertStatusTokenFor("1001", response.RedirectURL)
+
+	suite.wooCommerce.setMagicOrderStatus(1002, entities.StatusProcessing)
+	alreadyPaid := suite.redirect("1002")
+	suite.Equal("already_paid", alreadyPaid.Status)
+	suite.assertStatusTokenFor("1002", alreadyPaid.RedirectURL)
+
+	status, err := suite.publicStatus.Execute(context.Background(), alreadyPaid.StatusToken)
+	suite.Require().NoError(err)
+	suite.Equal(string(entities.StatusProcessing), status.Status)
+}
+
+// TestTamperedTokensAreRejected tests that a token only opens the order it was issued for
+func (suite *StatusTokenIntegrationTestSuite) TestTamperedTokensAreRejected() {
+	token := suite.redirect("1001").StatusToken
+	parts := strings.Split(token, ".")
+	suite.Require().Len(parts, 4)
+	otherParts := strings.Split(suite.redirect("1002").StatusToken, ".")
+	suite.Require().Len(otherParts, 4)
+
+	for name, tampered := range map[string]string{
+		"other order":    strings.Join([]string{"1002", parts[1], parts[2], parts[3]}, "."),
+		"later expiry":   strings.Join([]string{parts[0], "99999999999", parts[2], parts[3]}, "."),
+		"other key tag":  strings.Join([]string{parts[0], parts[1], otherParts[2], parts[3]}, "."),
+		"bad signature":  strings.Join([]string{parts[0], parts[1], parts[2], "c2lnbmF0dXJl"}, "."),
+		"missing fields": strings.Join(parts[:3], "."),
+		"empty":          "",
+	} {
+		_, err := suite.publicStatus.Execute(context.Background(), tampered)
+		suite.ErrorIs(err, services.ErrInvalidStatusToken, name)
+	}
+
+	other := services.NewStatusTokenService(interfaces.StatusTokenConfig{Secret: "another-status-token-secret-32-chars", TTL: time.Hour})
+	_, err := suite.publicStatus.Execute(context.Background(), other.Issue("1001", "wc_order_magic_1001"))
+	suite.ErrorIs(err, services.ErrInvalidStatusToken, "tokens signed with another secret")
+
+	suite.Zero(atomic.LoadInt32(&suite.orderFetches), "forged tokens must be rejected before the order is fetched")
+}
+
+// TestOrderKeyChangeRevokesTokens tests that tokens stop working once the order key changes
+func (suite *StatusTokenIntegrationTestSuite) TestOrderKeyChangeRevokesTokens() {
+	token := suite.redirect("1001").StatusToken
+
+	suite.Require().NoError(suite.wooCommerce.update(suite.wooCommerce.magicOrders, "1001", func(order *entities.Order) {
+		order.OrderKey = "wc_order_rotated"
+	}))
+
+	_, err := suite.publicStatus.Execute(context.Background(), token)
+	suite.ErrorIs(err, services.ErrInvalidStatusToken)
+	suite.Equal(int32(1), atomic.LoadInt32(&suite.orderFetches), "the order key can only be checked against the fetched order")
+}
+
+// TestExpiredTokensAreRejected tests that tokens stop working after their TTL
+func (suite *StatusTokenIntegrationTestSuite) TestExpiredTokensAreRejected() {
+	expired := services.NewStatusTokenService(interfaces.StatusTokenConfig{Secret: testStatusTokenSecret, TTL: -time.Minute})
+
+	_, err := suite.publicStatus.Execute(context.Background(), expired.Issue("1001", "wc_order_magic_1001"))
+	suite.ErrorIs(err, services.ErrStatusTokenExpired)
+	suite.Zero(atomic.LoadInt32(&suite.orderFetches), "expired tokens must be rejected before the order is fetched")
+}
+
+// TestDisabledStatusTokens tests that without a secret no tokens are issued or accepted
+func (suite *StatusTokenIntegrationTestSuite) TestDisabledStatusTokens() {
+	token := suite.redirect("1001").StatusToken
+
+	suite.cfg.StatusToken.Secret = ""
+	response := suite.redirect("1001")
+	suite.Empty(response.StatusToken)
+	suite.NotContains(response.RedirectURL, "status_token")
+
+	disabled := usecases.NewPublicOrderStatusUseCase(suite.wooCommerce, suite.cfg, suite.logger)
+	_, err := disabled.Execute(context.Background(), token)
+	suite.ErrorIs(err, services.ErrInvalidStatusToken)
+}
+
+// TestStatusEndpoint tests the public endpoint's responses and that it exposes
+// only the minimal status fields
+func (suite *StatusTokenIntegrationTestSuite) TestStatusEndpoint() {
+	gin.SetMode(gin.TestMode)
+	apiHandler := handlers.NewAPIHandler(suite.wooCommerce, nil, suite.publicStatus, suite.logger)
+	router := gin.New()
+	router.GET("/api/v1/status", apiHandler.GetOrderStatusByToken)
+
+	token := suite.redirect("1001").StatusToken
+	expired := services.NewStatusTokenService(interfaces.StatusTokenConfig{Secret: testStatusTokenSecret, TTL: -time.Minute})
+
+	get := func(token string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/api/v1/status?token="+url.QueryEscape(token), nil))
+		return recorder
+	}
+
+	recorder := get(token)
+	suite.Require().Equal(http.StatusOK, recorder.Code)
+	suite.Equal("no-store", recorder.Header().Get("Cache-Control"))
+
+	var body map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(recorder.Body.Bytes(), &body))
+	suite.Len(body, 3)
+	suite.Equal("1001", body["order_id"])
+	suite.Equal("pending", body["status"])
+	suite.NotContains(recorder.Body.String(), "49.99", "the order total must not be exposed")
+
+	suite.Equal(http.StatusBadRequest, get("").Code)
+	suite.Equal(http.StatusNotFound, get("1001.99999999999.a2V5VGFn.c2lnbmF0dXJl").Code)
+	suite.Equal(http.StatusGone, get(expired.Issue("1001", "wc_order_magic_1001")).Code)
+}
+
+// redirect runs the redirect use case for an order and requires it to succeed
+func (suite *StatusTokenIntegrationTestSuite) redirect(orderID string) *dto.PaymentRedirectResponse {
+	redirect := usecases.NewPaymentRedirectUseCase(
+		suite.wooCommerce,
+		suite.mappings,
+		infraHttp.NewURLBuilder(suite.cfg, suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		services.NewPaymentDomainService(suite.logger),
+		nil,
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+
+	response, err := redirect.Execute(context.Background(), &dto.PaymentRedirectRequest{
+		OrderID: orderID,
+		Domain:  "magicspore.com",
+	})
+	suite.Require().NoError(err)
+	return response
+}
+
+// newReturnUseCase builds the return use case without a payment gateway
+func (suite *StatusTokenIntegrationTestSuite) newReturnUseCase() *usecases.PaymentReturnUseCase {
+	return usecases.NewPaymentReturnUseCase(
+		suite.wooCommerce,
+		repositories.NewMemoryPaymentRepository(suite.logger),
+		suite.mappings,
+		nil,
+		services.NewPaymentDomainService(suite.logger),
+		services.NewOrderDomainService(suite.logger),
+		nil,
+		suite.logger,
+		suite.cfg,
+	)
+}
+
+// assertStatusTokenFor requires a storefront URL to carry a valid token for orderID
+func (suite *StatusTokenIntegrationTestSuite) assertStatusTokenFor(orderID, redirectURL string) {
+	parsed, err := url.Parse(redirectURL)
+	suite.Require().NoError(err)
+	token := parsed.Query().Get("status_token")
+	suite.Require().NotEmpty(token, redirectURL)
+
+	status, err := suite.publicStatus.Execute(context.Background(), token)
+	suite.Require().NoError(err)
+	suite.Equal(orderID, status.OrderID)
+}
+
+// countingWooCommerce counts the MagicSpore orders the status endpoint fetches
+type countingWooCommerce struct {
+	*fakeWooCommerce
+	fetches *int32
+}
+
+func (c *countingWooCommerce) GetMagicOrder(ctx context.Context, orderID string) (*entities.Order, error) {
+	atomic.AddInt32(c.fetches, 1)
+	return c.fakeWooCommerce.GetMagicOrder(ctx, orderID)
+}
+
+// TestStatusTokenIntegrationTestSuite runs the status token suite
+func TestStatusTokenIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(StatusTokenIntegrationTestSuite))
+}
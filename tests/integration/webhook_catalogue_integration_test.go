@@ -0,0 +1,343 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// WebhookCatalogueIntegrationTestSuite tests the order transitions and notes of each supported webhook event
+type WebhookCatalogueIntegrationTestSuite struct {
+	suite.Suite
+	wooCommerce *fakeWooCommerce
+	payments    interfaces.PaymentRepository
+	mappings    interfaces.OrderMappingRepository
+	useCase     *usecases.WebhookUseCase
+}
+
+// SetupTest wires a fresh use case with MagicSpore order 1001 paid through OITAM order 5000
+func (suite *WebhookCatalogueIntegrationTestSuite) SetupTest() {
+	logger := infraHttp.NewDefaultLogger("error")
+
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.payments = repositories.NewMemoryPaymentRepository(logger)
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(logger)
+	suite.useCase = usecases.NewWebhookUseCase(
+		suite.wooCommerce,
+		suite.payments,
+		suite.mappings,
+		repositories.NewMemoryWebhookEventRepository(logger),
+		repositories.NewMemoryDisputeRepository(logger),
+		services.NewPaymentDomainService(logger),
+		services.NewOrderDomainService(logger),
+		nil,
+		logger,
+		config.NewConfig(),
+	)
+
+	suite.wooCommerce.addMagicOrder(1001, 49.99)
+	proxyOrder := &entities.Order{ID: 5000, OrderKey: "wc_order_5000"}
+	suite.Require().NoError(suite.mappings.Create(context.Background(), entities.NewOrderMapping("1001", proxyOrder, time.Hour)))
+}
+
+// TestEventTransitions tests each event type against the order status it starts from
+func (suite *WebhookCatalogueIntegrationTestSuite) TestEventTransitions() {
+	testCases := []struct {
+		name      string
+		eventType string
+		resource  map[string]interface{}
+		paid      bool
+		from      entities.OrderStatus
+		to        entities.OrderStatus
+		mapping   entities.OrderMappingState
+		note      string
+	}{
+		{
+			name:      "pending capture holds the order",
+			eventType: entities.EventPaymentCapturePending,
+			resource:  withReason(captureResource("CAPTURE-1", "PENDING"), "PENDING_REVIEW"),
+			from:      entities.StatusPending,
+			to:        entities.StatusOnHold,
+			mapping:   entities.MappingStateActive,
+			note:      "PayPal capture CAPTURE-1 pending for 49.99 PLN (reason: PENDING_REVIEW).",
+		},
+		{
+			name:      "denied capture fails the order",
+			eventType: entities.EventPaymentCaptureDenied,
+			resource:  captureResource("CAPTURE-1", "DECLINED"),
+			from:      entities.StatusOnHold,
+			to:        entities.StatusFailed,
+			mapping:   entities.MappingStateFailed,
+			note:      "PayPal capture CAPTURE-1 denied.",
+		},
+		{
+			name:      "approved order is only noted",
+			eventType: entities.EventCheckoutOrderApproved,
+			resource:  checkoutOrderResource("ORDER-1"),
+			from:      entities.StatusPending,
+			to:        entities.StatusPending,
+			mapping:   entities.MappingStateActive,
+			note:      "PayPal order ORDER-1 approved by the buyer.",
+		},
+		{
+			name:      "completed order with completed captures is paid",
+			eventType: entities.EventCheckoutOrderCompleted,
+			resource:  checkoutOrderResource("ORDER-1", "COMPLETED"),
+			from:      entities.StatusOnHold,
+			to:        entities.StatusProcessing,
+			mapping:   entities.MappingStatePaid,
+			note:      "PayPal order ORDER-1 completed.",
+		},
+		{
+			name:      "completed order with a pending capture stays on hold",
+			eventType: entities.EventCheckoutOrderCompleted,
+			resource:  checkoutOrderResource("ORDER-1", "PENDING"),
+			from:      entities.StatusOnHold,
+			to:        entities.StatusOnHold,
+			mapping:   entities.MappingStateActive,
+			note:      "PayPal order ORDER-1 completed.",
+		},
+		{
+			name:      "authorization holds the order",
+			eventType: entities.EventPaymentAuthorizationCreated,
+			resource:  captureResource("AUTH-1", "CREATED"),
+			from:      entities.StatusPending,
+			to:        entities.StatusOnHold,
+			mapping:   entities.MappingStateActive,
+			note:      "PayPal authorization AUTH-1 created for 49.99 PLN.",
+		},
+		{
+			name:      "voided authorization cancels the order",
+			eventType: entities.EventPaymentAuthorizationVoided,
+			resource:  captureResource("AUTH-1", "VOIDED"),
+			from:      entities.StatusOnHold,
+			to:        entities.StatusCancelled,
+			mapping:   entities.MappingStateCancelled,
+			note:      "PayPal authorization AUTH-1 voided.",
+		},
+		{
+			name:      "refund of a paid order",
+			eventType: entities.EventPaymentCaptureRefunded,
+			resource:  captureResource("REFUND-1", "COMPLETED"),
+			paid:      true,
+			from:      entities.StatusCompleted,
+			to:        entities.StatusRefunded,
+			mapping:   entities.MappingStateRefunded,
+			note:      "PayPal refund REFUND-1 issued for 49.99 PLN.",
+		},
+		{
+			name:      "refund of an unpaid order leaves its status",
+			eventType: entities.EventPaymentCaptureRefunded,
+			resource:  captureResource("REFUND-1", "COMPLETED"),
+			from:      entities.StatusPending,
+			to:        entities.StatusPending,
+			mapping:   entities.MappingStateActive,
+			note:      "PayPal refund REFUND-1 issued for 49.99 PLN. No captured payment found to refund. Order status left at pending.",
+		},
+		{
+			name:      "reversed capture refunds the order",
+			eventType: entities.EventPaymentCaptureReversed,
+			resource:  captureResource("REFUND-2", "COMPLETED"),
+			from:      entities.StatusProcessing,
+			to:        entities.StatusRefunded,
+			mapping:   entities.MappingStateRefunded,
+			note:      "PayPal payment reversed for 49.99 PLN (REFUND-2).",
+		},
+		{
+			name:      "opened dispute holds the order",
+			eventType: entities.EventCustomerDisputeCreated,
+			resource:  disputeResource("PP-D-1", "OPEN", ""),
+			from:      entities.StatusProcessing,
+			to:        entities.StatusOnHold,
+			mapping:   entities.MappingStateActive,
+			note:      "PayPal dispute PP-D-1 opened for 49.99 PLN (reason: MERCHANDISE_OR_SERVICE_NOT_RECEIVED).",
+		},
+		{
+			name:      "updated dispute holds a processing order",
+			eventType: entities.EventCustomerDisputeUpdated,
+			resource:  disputeResource("PP-D-1", "WAITING_FOR_SELLER_RESPONSE", ""),
+			from:      entities.StatusProcessing,
+			to:        entities.StatusOnHold,
+			mapping:   entities.MappingStateActive,
+			note:      "PayPal dispute PP-D-1 updated (status: WAITING_FOR_SELLER_RESPONSE).",
+		},
+		{
+			name:      "updated dispute keeps the order on hold",
+			eventType: entities.EventCustomerDisputeUpdated,
+			resource:  disputeResource("PP-D-1", "WAITING_FOR_SELLER_RESPONSE", ""),
+			from:      entities.StatusOnHold,
+			to:        entities.StatusOnHold,
+			mapping:   entities.MappingStateActive,
+			note:      "PayPal dispute PP-D-1 updated (status: WAITING_FOR_SELLER_RESPONSE).",
+		},
+		{
+			name:      "dispute lost refunds the order",
+			eventType: entities.EventCustomerDisputeResolved,
+			resource:  disputeResource("PP-D-1", "RESOLVED", "RESOLVED_BUYER_FAVOUR"),
+			from:      entities.StatusOnHold,
+			to:        entities.StatusRefunded,
+			mapping:   entities.MappingStateRefunded,
+			note:      "PayPal dispute PP-D-1 resolved (outcome: RESOLVED_BUYER_FAVOUR).",
+		},
+		{
+			name:      "dispute won releases the order",
+			eventType: entities.EventCustomerDisputeResolved,
+			resource:  disputeResource("PP-D-1", "RESOLVED", "RESOLVED_SELLER_FAVOUR"),
+			from:      entities.StatusOnHold,
+			to:        entities.StatusProcessing,
+			mapping:   entities.MappingStateActive,
+			note:      "PayPal dispute PP-D-1 resolved (outcome: RESOLVED_SELLER_FAVOUR).",
+		},
+	}
+
+	for _, tc := range testCases {
+		suite.Run(tc.name, func() {
+			suite.SetupTest()
+			suite.wooCommerce.setMagicOrderStatus(1001, tc.from)
+			if tc.paid {
+				suite.storeCapture("CAPTURE-1", entities.NewMoney(49.99, "PLN"))
+			}
+
+			response, err := suite.useCase.Execute(context.Background(), &dto.WebhookRequest{
+				ID:        "WH-1",
+				EventType: tc.eventType,
+				Resource:  tc.resource,
+			})
+			suite.Require().NoError(err)
+			suite.Equal("processed", response.Status)
+
+			order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+			suite.Require().NoError(err)
+			suite.Equal(tc.to, order.Status)
+			suite.Equal([]string{tc.note}, suite.wooCommerce.notesFor("1001"))
+
+			mapping, err := suite.mappings.GetByOITAMOrderID(context.Background(), "5000")
+			suite.Require().NoError(err)
+			suite.Equal(tc.mapping, mapping.State)
+		})
+	}
+}
+
+// TestCaptureCompletedOnFinishedOrder tests that a late capture does not reopen a completed order
+func (suite *WebhookCatalogueIntegrationTestSuite) TestCaptureCompletedOnFinishedOrder() {
+	suite.wooCommerce.setMagicOrderStatus(1001, entities.StatusCompleted)
+
+	response, err := suite.useCase.Execute(context.Background(), &dto.WebhookRequest{
+		ID:        "WH-1",
+		EventType: entities.EventPaymentCaptureCompleted,
+		Resource:  captureResource("CAPTURE-1", "COMPLETED"),
+	})
+	suite.Require().NoError(err)
+	suite.Equal("processed", response.Status)
+
+	order, err := suite.wooCommerce.GetMagicOrder(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusCompleted, order.Status)
+	suite.Equal([]string{"PayPal capture CAPTURE-1 completed for 49.99 PLN. Order status left at completed."}, suite.wooCommerce.notesFor("1001"))
+
+	payments, err := suite.payments.GetByOrderID(context.Background(), "1001")
+	suite.Require().NoError(err)
+	suite.Empty(payments)
+}
+
+// TestUnreferencedAndUnknownEventsAreIgnored tests events the proxy cannot apply to an order
+func (suite *WebhookCatalogueIntegrationTestSuite) TestUnreferencedAndUnknownEventsAreIgnored() {
+	response, err := suite.useCase.Execute(context.Background(), &dto.WebhookRequest{
+		ID:        "WH-1",
+		EventType: entities.EventPaymentCaptureDenied,
+		Resource:  map[string]interface{}{"id": "CAPTURE-1"},
+	})
+	suite.Require().NoError(err)
+	suite.Equal("ignored", response.Status)
+
+	response, err = suite.useCase.Execute(context.Background(), &dto.WebhookRequest{
+		ID:        "WH-2",
+		EventType: "BILLING.SUBSCRIPTION.CREATED",
+		Resource:  captureResource("SUB-1", "ACTIVE"),
+	})
+	suite.Require().NoError(err)
+	suite.Equal("ignored", response.Status)
+
+	suite.Empty(suite.wooCommerce.notesFor("1001"))
+}
+
+// storeCapture records a completed payment for order 1001
+func (suite *WebhookCatalogueIntegrationTestSuite) storeCapture(captureID string, amount entities.Money) {
+	payment := entities.NewPayment("1001", amount, entities.PaymentMethodPayPal)
+	payment.ID = "pay_" + captureID
+	payment.PaymentID = captureID
+	payment.Status = entities.PaymentStatusCompleted
+	suite.Require().NoError(suite.payments.Create(context.Background(), payment))
+}
+
+// captureResource builds a capture, refund or authorization resource for OITAM order 5000
+func captureResource(id, status string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":        id,
+		"status":    status,
+		"custom_id": "5000",
+		"amount":    map[string]interface{}{"value": "49.99", "currency_code": "PLN"},
+	}
+}
+
+// withReason adds status details to a capture resource
+func withReason(resource map[string]interface{}, reason string) map[string]interface{} {
+	resource["status_details"] = map[string]interface{}{"reason": reason}
+	return resource
+}
+
+// checkoutOrderResource builds a checkout order resource with captures in the given statuses
+func checkoutOrderResource(id string, captureStatuses ...string) map[string]interface{} {
+	unit := map[string]interface{}{
+		"custom_id": "5000",
+		"amount":    map[string]interface{}{"value": "49.99", "currency_code": "PLN"},
+	}
+	if len(captureStatuses) > 0 {
+		captures := make([]interface{}, 0, len(captureStatuses))
+		for i, status := range captureStatuses {
+			captures = append(captures, captureResource("CAPTURE-"+strconv.Itoa(i+1), status))
+		}
+		unit["payments"] = map[string]interface{}{"captures": captures}
+	}
+
+	return map[string]interface{}{
+		"id":             id,
+		"status":         "COMPLETED",
+		"purchase_units": []interface{}{unit},
+	}
+}
+
+// disputeResource builds a dispute resource over OITAM order 5000
+func disputeResource(id, status, outcome string) map[string]interface{} {
+	resource := map[string]interface{}{
+		"dispute_id":     id,
+		"status":         status,
+		"reason":         "MERCHANDISE_OR_SERVICE_NOT_RECEIVED",
+		"dispute_amount": map[string]interface{}{"value": "49.99", "currency_code": "PLN"},
+		"disputed_transactions": []interface{}{
+			map[string]interface{}{"seller_transaction_id": "CAPTURE-1", "custom": "5000"},
+		},
+	}
+	if outcome != "" {
+		resource["dispute_outcome"] = map[string]interface{}{"outcome_code": outcome}
+	}
+	return resource
+}
+
+func TestWebhookCatalogueIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(WebhookCatalogueIntegrationTestSuite))
+}
@@ -0,0 +1,465 @@
+//go:build integration
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/application/dto"
+	appServices "paypal-proxy/internal/application/services"
+	"paypal-proxy/internal/application/usecases"
+	"paypal-proxy/internal/domain/entities"
+	"paypal-proxy/internal/domain/interfaces"
+	"paypal-proxy/internal/domain/services"
+	"paypal-proxy/internal/infrastructure/config"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// WebhookEventIntegrationTestSuite tests webhook event storage, deduplication and replay
+type WebhookEventIntegrationTestSuite struct {
+	suite.Suite
+	cfg         *config.Config
+	logger      interfaces.Logger
+	wooCommerce *fakeWooCommerce
+	payments    interfaces.PaymentRepository
+	mappings    interfaces.OrderMappingRepository
+	events      interfaces.WebhookEventRepository
+	useCase     *usecases.WebhookUseCase
+}
+
+// SetupTest wires a fresh use case and MagicSpore order 1001
+func (suite *WebhookEventIntegrationTestSuite) SetupTest() {
+	logger := infraHttp.NewDefaultLogger("error")
+
+	suite.cfg = config.NewConfig()
+	suite.cfg.Webhook.RetryEnabled = false
+	suite.logger = logger
+	suite.wooCommerce = newFakeWooCommerce()
+	suite.payments = repositories.NewMemoryPaymentRepository(logger)
+	suite.mappings = repositories.NewMemoryOrderMappingRepository(logger)
+	suite.events = repositories.NewMemoryWebhookEventRepository(logger)
+	suite.useCase = usecases.NewWebhookUseCase(
+		suite.wooCommerce,
+		suite.payments,
+		suite.mappings,
+		suite.events,
+		repositories.NewMemoryDisputeRepository(logger),
+		services.NewPaymentDomainService(logger),
+		services.NewOrderDomainService(logger),
+		nil,
+		logger,
+		suite.cfg,
+	)
+
+	suite.wooCommerce.addMagicOrder(1001, 49.99)
+}
+
+// TestDuplicateDeliveryIsNotReprocessed tests that a redelivered event is acknowledged only
+func (suite *WebhookEventIntegrationTestSuite) TestDuplicateDeliveryIsNotReprocessed() {
+	ctx := context.Background()
+	delivery := suite.captureCompleted("WH-1", "1001", entities.SignatureVerified)
+
+	response, err := suite.useCase.Receive(ctx, delivery)
+	suite.Require().NoError(err)
+	suite.Equal("processed", response.Status)
+
+	response, err = suite.useCase.Receive(ctx, delivery)
+	suite.Require().NoError(err)
+	suite.Equal("duplicate", response.Status)
+
+	suite.Len(suite.paymentsFor("1001"), 1, "Redelivery should not record a second payment")
+
+	event, err := suite.events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.WebhookEventProcessed, event.Status)
+	suite.Equal(entities.SignatureVerified, event.Signature)
+	suite.Equal(1, event.Attempts)
+	suite.JSONEq(string(delivery.Body), event.Body)
+}
+
+// TestConcurrentDuplicatesAreProcessedOnce tests that parallel redeliveries apply once
+func (suite *WebhookEventIntegrationTestSuite) TestConcurrentDuplicatesAreProcessedOnce() {
+	delivery := suite.captureCompleted("WH-1", "1001", entities.SignatureVerified)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := suite.useCase.Receive(context.Background(), delivery)
+			suite.NoError(err)
+		}()
+	}
+	wg.Wait()
+
+	suite.Len(suite.paymentsFor("1001"), 1)
+}
+
+// TestFailedEventIsRetriedOnRedelivery tests that only unsuccessful events are processed again
+func (suite *WebhookEventIntegrationTestSuite) TestFailedEventIsRetriedOnRedelivery() {
+	ctx := context.Background()
+	delivery := suite.captureCompleted("WH-1", "2002", entities.SignatureVerified)
+
+	_, err := suite.useCase.Receive(ctx, delivery)
+	suite.Require().Error(err)
+
+	event, err := suite.events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.WebhookEventFailed, event.Status)
+	suite.Contains(event.Result, "order 2002 not found")
+
+	suite.wooCommerce.addMagicOrder(2002, 49.99)
+
+	response, err := suite.useCase.Receive(ctx, delivery)
+	suite.Require().NoError(err)
+	suite.Equal("processed", response.Status)
+
+	event, err = suite.events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.WebhookEventProcessed, event.Status)
+	suite.Equal(2, event.Attempts)
+}
+
+// TestUnmappedReferenceIsIgnored tests that a capture for an unknown proxy order
+// never falls back to a MagicSpore order with the same ID
+func (suite *WebhookEventIntegrationTestSuite) TestUnmappedReferenceIsIgnored() {
+	ctx := context.Background()
+	delivery := suite.captureCompleted("WH-1", "1001", entities.SignatureVerified)
+	delivery.Request.Resource["custom_id"] = "1001"
+
+	response, err := suite.useCase.Receive(ctx, delivery)
+	suite.Require().NoError(err)
+	suite.Equal("ignored", response.Status)
+
+	order, err := suite.wooCommerce.GetMagicOrder(ctx, "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusPending, order.Status)
+	suite.Empty(suite.paymentsFor("1001"))
+}
+
+// TestMismatchedCaptureIsHeldForReview tests that a capture for less than the
+// proxy order charged holds the order instead of marking it paid
+func (suite *WebhookEventIntegrationTestSuite) TestMismatchedCaptureIsHeldForReview() {
+	ctx := context.Background()
+	delivery := suite.captureCompleted("WH-1", "1001", entities.SignatureVerified)
+	delivery.Request.Resource["amount"] = map[string]interface{}{"value": "4.99", "currency_code": "PLN"}
+
+	response, err := suite.useCase.Receive(ctx, delivery)
+	suite.Require().NoError(err)
+	suite.Equal("processed", response.Status)
+
+	order, err := suite.wooCommerce.GetMagicOrder(ctx, "1001")
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusOnHold, order.Status)
+	suite.Empty(order.TransactionID)
+	suite.Empty(suite.paymentsFor("1001"))
+
+	notes := suite.wooCommerce.notesFor("1001")
+	suite.Require().NotEmpty(notes)
+	suite.Contains(notes[len(notes)-1], "needs review")
+}
+
+// TestRejectedEventIsNeverReplayed tests that unverified events are stored but not processed
+func (suite *WebhookEventIntegrationTestSuite) TestRejectedEventIsNeverReplayed() {
+	ctx := context.Background()
+	forged := suite.captureCompleted("WH-1", "1001", entities.SignatureInvalid)
+
+	suite.Require().NoError(suite.useCase.RecordRejected(ctx, forged))
+
+	event, err := suite.events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.WebhookEventRejected, event.Status)
+
+	_, err = suite.useCase.Replay(ctx, "WH-1")
+	suite.Error(err)
+	suite.Empty(suite.paymentsFor("1001"))
+
+	// A forged delivery must not block the genuine one carrying the same ID
+	response, err := suite.useCase.Receive(ctx, suite.captureCompleted("WH-1", "1001", entities.SignatureVerified))
+	suite.Require().NoError(err)
+	suite.Equal("processed", response.Status)
+	suite.Len(suite.paymentsFor("1001"), 1)
+}
+
+// TestSignedRedeliveryReplacesForgedBody tests that a signed delivery carrying
+// the ID of an earlier forged one is processed with its own body
+func (suite *WebhookEventIntegrationTestSuite) TestSignedRedeliveryReplacesForgedBody() {
+	ctx := context.Background()
+	suite.Require().NoError(suite.useCase.RecordRejected(ctx, suite.forgedCapture("WH-1", "1001")))
+
+	signed := suite.captureCompleted("WH-1", "1001", entities.SignatureVerified)
+	response, err := suite.useCase.Receive(ctx, signed)
+	suite.Require().NoError(err)
+	suite.Equal("processed", response.Status)
+
+	event, err := suite.events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.JSONEq(string(signed.Body), event.Body)
+	suite.Equal(entities.SignatureVerified, event.Signature)
+
+	_, err = suite.useCase.Replay(ctx, "WH-1")
+	suite.Require().NoError(err)
+	payments := suite.paymentsFor("1001")
+	suite.Require().Len(payments, 2)
+	for _, payment := range payments {
+		suite.Equal("CAPTURE-WH-1", payment.TransactionID, "Only the signed body is processed")
+	}
+}
+
+// TestQueuedSignedRedeliveryReplacesForgedBody tests the same for deliveries
+// processed by the workers
+func (suite *WebhookEventIntegrationTestSuite) TestQueuedSignedRedeliveryReplacesForgedBody() {
+	ctx := context.Background()
+	suite.enableQueue(3)
+	suite.Require().NoError(suite.useCase.RecordRejected(ctx, suite.forgedCapture("WH-1", "1001")))
+
+	response, err := suite.useCase.Receive(ctx, suite.captureCompleted("WH-1", "1001", entities.SignatureVerified))
+	suite.Require().NoError(err)
+	suite.Equal("queued", response.Status)
+
+	suite.startWorkers()
+
+	suite.Eventually(func() bool {
+		return suite.eventStatus("WH-1") == entities.WebhookEventProcessed
+	}, 2*time.Second, 10*time.Millisecond)
+	payments := suite.paymentsFor("1001")
+	suite.Require().Len(payments, 1)
+	suite.Equal("CAPTURE-WH-1", payments[0].TransactionID)
+}
+
+// TestReplayByIDAndRange tests replaying stored events on demand
+func (suite *WebhookEventIntegrationTestSuite) TestReplayByIDAndRange() {
+	ctx := context.Background()
+	start := time.Now().Add(-time.Second)
+
+	_, err := suite.useCase.Receive(ctx, suite.captureCompleted("WH-1", "1001", entities.SignatureVerified))
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.useCase.RecordRejected(ctx, suite.captureCompleted("WH-2", "1001", entities.SignatureInvalid)))
+
+	result, err := suite.useCase.Replay(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Equal("processed", result.Status)
+	suite.Len(suite.paymentsFor("1001"), 2, "Replay should run the event through the use case again")
+
+	response, err := suite.useCase.ReplayRange(ctx, start, time.Now().Add(time.Second))
+	suite.Require().NoError(err)
+	suite.Equal(1, response.Replayed)
+	suite.Equal(1, response.Skipped)
+	suite.Equal(0, response.Failed)
+	suite.Require().Len(response.Events, 2)
+
+	event, err := suite.events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Equal(3, event.Attempts)
+
+	_, err = suite.useCase.Replay(ctx, "WH-404")
+	suite.Error(err)
+	_, err = suite.useCase.ReplayRange(ctx, time.Now(), start)
+	suite.Error(err)
+}
+
+// TestQueuedDeliveryIsProcessedByWorkers tests that queued webhooks are acknowledged then processed
+func (suite *WebhookEventIntegrationTestSuite) TestQueuedDeliveryIsProcessedByWorkers() {
+	ctx := context.Background()
+	suite.enableQueue(3)
+	delivery := suite.captureCompleted("WH-1", "1001", entities.SignatureVerified)
+
+	response, err := suite.useCase.Receive(ctx, delivery)
+	suite.Require().NoError(err)
+	suite.Equal("queued", response.Status)
+	suite.Empty(suite.paymentsFor("1001"), "Queued webhooks should not be processed inline")
+
+	response, err = suite.useCase.Receive(ctx, delivery)
+	suite.Require().NoError(err)
+	suite.Equal("duplicate", response.Status)
+
+	suite.startWorkers()
+
+	suite.Eventually(func() bool {
+		return suite.eventStatus("WH-1") == entities.WebhookEventProcessed
+	}, 2*time.Second, 10*time.Millisecond)
+	suite.Len(suite.paymentsFor("1001"), 1)
+
+	event, err := suite.events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Nil(event.NextAttemptAt)
+	suite.Equal(1, event.Attempts)
+}
+
+// TestFailingEventBacksOffAndIsDeadLettered tests exponential retries and the dead-letter list
+func (suite *WebhookEventIntegrationTestSuite) TestFailingEventBacksOffAndIsDeadLettered() {
+	ctx := context.Background()
+	suite.enableQueue(3)
+
+	_, err := suite.useCase.Receive(ctx, suite.captureCompleted("WH-1", "2002", entities.SignatureVerified))
+	suite.Require().NoError(err)
+
+	event, err := suite.useCase.ClaimNext(ctx, time.Minute)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(event)
+	suite.Require().NoError(suite.useCase.ProcessQueued(ctx, event))
+
+	failed, err := suite.events.GetByID(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Equal(entities.WebhookEventFailed, failed.Status)
+	suite.Require().NotNil(failed.NextAttemptAt)
+	suite.WithinDuration(time.Now().Add(20*time.Millisecond), *failed.NextAttemptAt, 15*time.Millisecond)
+
+	suite.startWorkers()
+
+	suite.Eventually(func() bool {
+		return suite.eventStatus("WH-1") == entities.WebhookEventDeadLetter
+	}, 2*time.Second, 10*time.Millisecond)
+
+	deadLetters, err := suite.useCase.DeadLetters(ctx, 10)
+	suite.Require().NoError(err)
+	suite.Require().Len(deadLetters, 1)
+	suite.Equal("WH-1", deadLetters[0].ID)
+	suite.Equal(3, deadLetters[0].Attempts)
+	suite.Contains(deadLetters[0].Result, "order 2002 not found")
+
+	// Once the cause is fixed the event can be requeued with fresh attempts
+	suite.wooCommerce.addMagicOrder(2002, 49.99)
+	response, err := suite.useCase.Requeue(ctx, "WH-1")
+	suite.Require().NoError(err)
+	suite.Equal("queued", response.Status)
+
+	suite.Eventually(func() bool {
+		return suite.eventStatus("WH-1") == entities.WebhookEventProcessed
+	}, 2*time.Second, 10*time.Millisecond)
+
+	order, err := suite.wooCommerce.GetMagicOrder(ctx, "2002")
+	suite.Require().NoError(err)
+	suite.Equal("CAPTURE-WH-1", order.TransactionID)
+}
+
+// TestClaimIsExclusive tests that a due event is leased to exactly one worker
+func (suite *WebhookEventIntegrationTestSuite) TestClaimIsExclusive() {
+	ctx := context.Background()
+	suite.enableQueue(3)
+
+	_, err := suite.useCase.Receive(ctx, suite.captureCompleted("WH-1", "1001", entities.SignatureVerified))
+	suite.Require().NoError(err)
+
+	var (
+		wg      sync.WaitGroup
+		mutex   sync.Mutex
+		claimed int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			event, err := suite.useCase.ClaimNext(context.Background(), time.Minute)
+			suite.NoError(err)
+			if event != nil {
+				mutex.Lock()
+				claimed++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	suite.Equal(1, claimed)
+
+	event, err := suite.useCase.ClaimNext(ctx, time.Minute)
+	suite.Require().NoError(err)
+	suite.Nil(event, "A leased event should not be claimed again before the lease ends")
+}
+
+// enableQueue turns on background processing with short retry delays
+func (suite *WebhookEventIntegrationTestSuite) enableQueue(maxAttempts int) {
+	suite.cfg.Webhook = config.WebhookConfig{
+		RetryEnabled:   true,
+		Workers:        2,
+		MaxAttempts:    maxAttempts,
+		RetryBaseDelay: 20 * time.Millisecond,
+		RetryMaxDelay:  50 * time.Millisecond,
+		PollInterval:   5 * time.Millisecond,
+	}
+}
+
+// startWorkers runs a worker pool until the test ends
+func (suite *WebhookEventIntegrationTestSuite) startWorkers() {
+	worker := appServices.NewWebhookWorker(suite.useCase, suite.cfg, suite.logger)
+	worker.Start(context.Background())
+	suite.T().Cleanup(worker.Stop)
+}
+
+// eventStatus returns the stored status of an event
+func (suite *WebhookEventIntegrationTestSuite) eventStatus(eventID string) entities.WebhookEventStatus {
+	event, err := suite.events.GetByID(context.Background(), eventID)
+	suite.Require().NoError(err)
+	return event.Status
+}
+
+// captureCompleted builds a PAYMENT.CAPTURE.COMPLETED delivery for an order's
+// proxy order
+func (suite *WebhookEventIntegrationTestSuite) captureCompleted(eventID, orderID string, signature entities.WebhookSignatureVerdict) *dto.WebhookDelivery {
+	proxyOrderID := suite.proxyOrderFor(orderID)
+
+	request := &dto.WebhookRequest{
+		ID:         eventID,
+		EventType:  "PAYMENT.CAPTURE.COMPLETED",
+		CreateTime: time.Now().UTC().Truncate(time.Second),
+		Resource: map[string]interface{}{
+			"id":        "CAPTURE-" + eventID,
+			"custom_id": proxyOrderID,
+			"amount":    map[string]interface{}{"value": "49.99", "currency_code": "PLN"},
+		},
+	}
+	body, err := json.Marshal(request)
+	suite.Require().NoError(err)
+
+	return &dto.WebhookDelivery{Request: request, Body: body, Signature: string(signature)}
+}
+
+// forgedCapture builds a delivery with a bad signature that reports a
+// different capture than the genuine event with the same ID
+func (suite *WebhookEventIntegrationTestSuite) forgedCapture(eventID, orderID string) *dto.WebhookDelivery {
+	delivery := suite.captureCompleted(eventID, orderID, entities.SignatureInvalid)
+	delivery.Request.Resource["id"] = "CAPTURE-FORGED"
+	body, err := json.Marshal(delivery.Request)
+	suite.Require().NoError(err)
+	delivery.Body = body
+	return delivery
+}
+
+// proxyOrderFor returns the proxy order mapped to orderID, creating a
+// 49.99 PLN proxy order and its mapping on first use
+func (suite *WebhookEventIntegrationTestSuite) proxyOrderFor(orderID string) string {
+	ctx := context.Background()
+	if mapping, err := suite.mappings.GetByMagicOrderID(ctx, orderID); err == nil {
+		return mapping.OITAMOrderID
+	}
+
+	proxyOrder, err := suite.wooCommerce.CreateOITAMOrder(ctx, &entities.Order{
+		Currency: "PLN",
+		Total:    entities.NewMoney(49.99, "PLN"),
+		MetaData: []entities.MetaData{{Key: entities.MetaOriginalOrderNumber, Value: orderID}},
+	})
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.mappings.Create(ctx, entities.NewOrderMapping(orderID, proxyOrder, time.Hour)))
+	return fmt.Sprintf("%d", proxyOrder.ID)
+}
+
+// paymentsFor returns the payment records stored for an order
+func (suite *WebhookEventIntegrationTestSuite) paymentsFor(orderID string) []*entities.Payment {
+	payments, err := suite.payments.GetByOrderID(context.Background(), orderID)
+	suite.Require().NoError(err)
+	return payments
+}
+
+// TestWebhookEventIntegrationTestSuite runs the webhook event suite
+func TestWebhookEventIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(WebhookEventIntegrationTestSuite))
+}
@@ -4,13 +4,17 @@ package integration
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"paypal-proxy/internal/infrastructure/config"
 	infraHttp "paypal-proxy/internal/infrastructure/http"
 	"paypal-proxy/internal/infrastructure/repositories"
+	"paypal-proxy/internal/infrastructure/simulators"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -19,9 +23,10 @@ import (
 // WooCommerceIntegrationTestSuite tests WooCommerce API integration
 type WooCommerceIntegrationTestSuite struct {
 	suite.Suite
-	repo   *repositories.WooCommerceRepository
-	config *config.Config
-	logger *infraHttp.Logger
+	repo      *repositories.WooCommerceRepository
+	config    *config.Config
+	logger    *infraHttp.Logger
+	simulated []*httptest.Server
 }
 
 // SetupSuite initializes test environment
@@ -62,14 +67,33 @@ func (suite *WooCommerceIntegrationTestSuite) SetupSuite() {
 		RetryAttempts:  3,
 	}
 
-	// Skip test if credentials not provided
+	// Fall back to simulated stores when no real ones are configured
 	if magicConfig.URL == "" || oitamConfig.URL == "" {
-		suite.T().Skip("WooCommerce test credentials not provided")
+		magicConfig.URL = suite.startSimulatedStore("magicspore", magicConfig)
+		oitamConfig.URL = suite.startSimulatedStore("oitam", oitamConfig)
 	}
 
 	suite.repo = repositories.NewWooCommerceRepository(magicConfig, oitamConfig, suite.logger).(*repositories.WooCommerceRepository)
 }
 
+// TearDownSuite stops simulated stores
+func (suite *WooCommerceIntegrationTestSuite) TearDownSuite() {
+	for _, server := range suite.simulated {
+		server.Close()
+	}
+}
+
+// startSimulatedStore serves a WooCommerce simulator accepting the configured credentials
+func (suite *WooCommerceIntegrationTestSuite) startSimulatedStore(name string, storeConfig repositories.WooCommerceConfig) string {
+	server := httptest.NewServer(simulators.NewWooCommerceSimulator(simulators.WooCommerceSimulatorConfig{
+		Name:           name,
+		ConsumerKey:    storeConfig.ConsumerKey,
+		ConsumerSecret: storeConfig.ConsumerSecret,
+	}, suite.logger))
+	suite.simulated = append(suite.simulated, server)
+	return server.URL
+}
+
 // TestMagicOrderRetrieval tests fetching orders from MagicSpore
 func (suite *WooCommerceIntegrationTestSuite) TestMagicOrderRetrieval() {
 	ctx := context.Background()
@@ -93,12 +117,12 @@ func (suite *WooCommerceIntegrationTestSuite) TestMagicOrderRetrieval() {
 	if order != nil {
 		suite.Equal(testOrderID, order.Number, "Order number should match")
 		suite.NotEmpty(order.Currency, "Order should have currency")
-		suite.Greater(order.Total.Amount, 0.0, "Order should have positive total")
+		suite.True(order.Total.IsPositive(), "Order should have positive total")
 		
 		suite.logger.Info("Successfully fetched Magic order", map[string]interface{}{
 			"order_id": order.ID,
 			"number":   order.Number,
-			"total":    order.Total.Amount,
+			"total":    order.Total.Decimal(),
 			"currency": order.Currency,
 			"status":   order.Status,
 		})
@@ -128,12 +152,12 @@ func (suite *WooCommerceIntegrationTestSuite) TestOITAMOrderRetrieval() {
 	if order != nil {
 		suite.Equal(testOrderID, order.Number, "Order number should match")
 		suite.NotEmpty(order.Currency, "Order should have currency")
-		suite.Greater(order.Total.Amount, 0.0, "Order should have positive total")
+		suite.True(order.Total.IsPositive(), "Order should have positive total")
 		
 		suite.logger.Info("Successfully fetched OITAM order", map[string]interface{}{
 			"order_id": order.ID,
 			"number":   order.Number,
-			"total":    order.Total.Amount,
+			"total":    order.Total.Decimal(),
 			"currency": order.Currency,
 			"status":   order.Status,
 		})
@@ -205,28 +229,39 @@ func (suite *WooCommerceIntegrationTestSuite) TestOrderStatusUpdate() {
 	}
 }
 
-// TestNetworkResilience tests network error handling and retries
+// TestNetworkResilience tests network error handling and retries against a
+// local server that drops every connection
 func (suite *WooCommerceIntegrationTestSuite) TestNetworkResilience() {
 	ctx := context.Background()
 	
-	// Create a repository with invalid URL to test error handling
-	invalidConfig := repositories.WooCommerceConfig{
-		URL:            "https://invalid-domain-that-does-not-exist-12345.com",
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer server.Close()
+	
+	unreachableConfig := repositories.WooCommerceConfig{
+		URL:            server.URL,
 		ConsumerKey:    "test",
 		ConsumerSecret: "test",
 		Timeout:        5 * time.Second,
 		RetryAttempts:  2,
 	}
 	
-	invalidRepo := repositories.NewWooCommerceRepository(invalidConfig, invalidConfig, suite.logger)
+	unreachableRepo := repositories.NewWooCommerceRepository(unreachableConfig, unreachableConfig, suite.logger)
 	
-	// Should handle network errors gracefully
+	// Should handle network errors gracefully, backing off 1s and 2s between attempts
 	start := time.Now()
-	_, err := invalidRepo.GetMagicOrder(ctx, "123")
+	_, err := unreachableRepo.GetMagicOrder(ctx, "123")
 	duration := time.Since(start)
 	
-	suite.Error(err, "Should return error for invalid domain")
-	suite.Greater(duration, 4*time.Second, "Should take at least 4 seconds due to retries")
+	suite.Error(err, "Should return error for dropped connections")
+	suite.Equal(int32(3), atomic.LoadInt32(&attempts), "Should try once and retry twice")
+	suite.GreaterOrEqual(duration, 3*time.Second, "Should back off between retries")
 	suite.Less(duration, 20*time.Second, "Should not take too long")
 }
 
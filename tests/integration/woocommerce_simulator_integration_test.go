@@ -0,0 +1,464 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"paypal-proxy/internal/domain/entities"
+	infraHttp "paypal-proxy/internal/infrastructure/http"
+	"paypal-proxy/internal/infrastructure/repositories"
+	"paypal-proxy/internal/infrastructure/simulators"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// WooCommerceSimulatorIntegrationTestSuite drives the WooCommerce repository
+// against simulated MagicSpore and OITAM stores
+type WooCommerceSimulatorIntegrationTestSuite struct {
+	suite.Suite
+	magic       *simulators.WooCommerceSimulator
+	oitam       *simulators.WooCommerceSimulator
+	magicServer *httptest.Server
+	oitamServer *httptest.Server
+	repo        *repositories.WooCommerceRepository
+}
+
+// SetupTest starts both store simulators and a repository pointed at them
+func (suite *WooCommerceSimulatorIntegrationTestSuite) SetupTest() {
+	logger := infraHttp.NewDefaultLogger("error")
+
+	suite.magic = simulators.NewWooCommerceSimulator(simulators.WooCommerceSimulatorConfig{
+		Name:           "magicspore",
+		ConsumerKey:    "ck_magic",
+		ConsumerSecret: "cs_magic",
+		FirstOrderID:   1000,
+	}, logger)
+	suite.oitam = simulators.NewWooCommerceSimulator(simulators.WooCommerceSimulatorConfig{
+		Name:           "oitam",
+		ConsumerKey:    "ck_oitam",
+		ConsumerSecret: "cs_oitam",
+		FirstOrderID:   5000,
+	}, logger)
+	suite.magicServer = httptest.NewServer(suite.magic)
+	suite.oitamServer = httptest.NewServer(suite.oitam)
+
+	suite.repo = suite.newRepository("ck_magic", "cs_magic")
+}
+
+// TearDownTest stops the simulators
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TearDownTest() {
+	suite.magicServer.Close()
+	suite.oitamServer.Close()
+}
+
+// TestMagicOrderRetrieval tests fetching a seeded MagicSpore order
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestMagicOrderRetrieval() {
+	orderID := suite.seedMagicOrder()
+
+	order, err := suite.repo.GetMagicOrder(context.Background(), orderID)
+	suite.Require().NoError(err)
+	suite.Equal(1000, order.ID)
+	suite.Equal(orderID, order.Number)
+	suite.Equal("PLN", order.Currency)
+	suite.Equal(entities.StatusPending, order.Status)
+	suite.Equal("64.99", order.Total.Decimal())
+	suite.Require().Len(order.LineItems, 1)
+	suite.Equal(2, order.LineItems[0].Quantity)
+	suite.Equal("24.99", order.LineItems[0].Price.Decimal())
+	suite.Require().Len(order.ShippingLines, 1)
+	suite.Equal("15.01", order.ShippingLines[0].Total.Decimal())
+}
+
+// TestOITAMOrderCreation tests creating a proxy order and reading it back
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestOITAMOrderCreation() {
+	source, err := suite.repo.GetMagicOrder(context.Background(), suite.seedMagicOrder())
+	suite.Require().NoError(err)
+
+	created, err := suite.repo.CreateOITAMOrder(context.Background(), source.ToAnonymousOrder(nil))
+	suite.Require().NoError(err)
+	suite.Equal(5000, created.ID)
+	suite.Equal(entities.StatusPending, created.Status)
+	suite.Equal("64.99", created.Total.Decimal())
+	suite.NotEmpty(created.OrderKey)
+
+	fetched, err := suite.repo.GetOITAMOrder(context.Background(), "5000")
+	suite.Require().NoError(err)
+	suite.Equal(created.OrderKey, fetched.OrderKey)
+	suite.Equal("Item 1", fetched.LineItems[0].Name)
+
+	var raw struct {
+		MetaData []struct {
+			Key   string      `json:"key"`
+			Value interface{} `json:"value"`
+		} `json:"meta_data"`
+	}
+	suite.storeGet(suite.oitamServer.URL+"/wp-json/wc/v3/orders/5000", "ck_oitam", "cs_oitam", &raw)
+	meta := map[string]interface{}{}
+	for _, entry := range raw.MetaData {
+		meta[entry.Key] = entry.Value
+	}
+	suite.Equal(source.Number, meta["_original_order_number"])
+	suite.Equal("true", meta["_proxy_order"])
+}
+
+// TestPaymentUpdateMergesMetaAndMarksPaid tests the payment write-back to MagicSpore
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestPaymentUpdateMergesMetaAndMarksPaid() {
+	orderID := suite.seedMagicOrder()
+
+	payment := &entities.Payment{
+		ID:            "pay-1",
+		PaymentID:     "ORDER-1",
+		TransactionID: "CAPTURE-1",
+		Status:        entities.PaymentStatusCompleted,
+	}
+	suite.Require().NoError(suite.repo.UpdateMagicOrderPayment(context.Background(), orderID, payment))
+	suite.Require().NoError(suite.repo.UpdateMagicOrderPayment(context.Background(), orderID, payment))
+
+	var raw struct {
+		Status        string  `json:"status"`
+		TransactionID string  `json:"transaction_id"`
+		DatePaid      *string `json:"date_paid"`
+		MetaData      []struct {
+			Key string `json:"key"`
+		} `json:"meta_data"`
+	}
+	suite.storeGet(suite.magicServer.URL+"/wp-json/wc/v3/orders/"+orderID, "ck_magic", "cs_magic", &raw)
+	suite.Equal("processing", raw.Status)
+	suite.Equal("CAPTURE-1", raw.TransactionID)
+	suite.NotNil(raw.DatePaid)
+	suite.Len(raw.MetaData, 3, "repeated updates should replace meta entries by key")
+
+	history := suite.magic.StatusHistory(1000)
+	suite.Require().Len(history, 2)
+	suite.Equal("pending", history[1].From)
+	suite.Equal("processing", history[1].To)
+}
+
+// TestConversionMetaDataReachesBothStores tests that a recorded currency
+// conversion is written to both orders and read back
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestConversionMetaDataReachesBothStores() {
+	orderID := suite.seedMagicOrder()
+	source, err := suite.repo.GetMagicOrder(context.Background(), orderID)
+	suite.Require().NoError(err)
+
+	rate, err := entities.NewExchangeRate(source.Currency, "EUR", "0.25", "static", time.Now())
+	suite.Require().NoError(err)
+	converted, err := rate.Convert(source.Total, entities.RoundHalfUp)
+	suite.Require().NoError(err)
+	conversion := &entities.CurrencyConversion{Original: source.Total, Converted: converted, Rate: *rate}
+
+	suite.Require().NoError(suite.repo.UpdateMagicOrderMetaData(context.Background(), orderID, conversion.MetaData()))
+	magicOrder, err := suite.repo.GetMagicOrder(context.Background(), orderID)
+	suite.Require().NoError(err)
+	recorded, ok := entities.CurrencyConversionFromMetaData(magicOrder.MetaData)
+	suite.Require().True(ok)
+	suite.True(recorded.Converted.Equals(converted))
+
+	anonymousOrder := source.ToAnonymousOrder(nil)
+	anonymousOrder.MetaData = append(anonymousOrder.MetaData, conversion.MetaData()...)
+	_, err = suite.repo.CreateOITAMOrder(context.Background(), anonymousOrder)
+	suite.Require().NoError(err)
+	proxyOrder, err := suite.repo.GetOITAMOrder(context.Background(), "5000")
+	suite.Require().NoError(err)
+	recorded, ok = entities.CurrencyConversionFromMetaData(proxyOrder.MetaData)
+	suite.Require().True(ok)
+	suite.True(recorded.Original.Equals(source.Total))
+	suite.Equal("0.25", recorded.Rate.Rate)
+}
+
+// TestStatusTransitions tests status updates and rejection of unknown statuses
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestStatusTransitions() {
+	orderID := suite.seedMagicOrder()
+
+	suite.Require().NoError(suite.repo.UpdateMagicOrderStatus(context.Background(), orderID, entities.StatusCompleted))
+
+	order, err := suite.repo.GetMagicOrder(context.Background(), orderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusCompleted, order.Status)
+
+	err = suite.repo.UpdateMagicOrderStatus(context.Background(), orderID, entities.OrderStatus("shipped"))
+	suite.Error(err)
+	suite.Contains(err.Error(), "400")
+
+	order, err = suite.repo.GetMagicOrder(context.Background(), orderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusCompleted, order.Status, "rejected update should leave the order unchanged")
+}
+
+// TestStatusHistoryRoundTrips tests that the status history stored as meta data is read back
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestStatusHistoryRoundTrips() {
+	ctx := context.Background()
+	orderID := suite.seedMagicOrder()
+
+	order, err := suite.repo.GetMagicOrder(ctx, orderID)
+	suite.Require().NoError(err)
+	suite.Empty(order.StatusHistory)
+
+	suite.Require().NoError(order.TransitionTo(entities.StatusOnHold, "PAYMENT.CAPTURE.PENDING"))
+	suite.Require().NoError(order.TransitionTo(entities.StatusProcessing, "PAYMENT.CAPTURE.COMPLETED"))
+	suite.Require().NoError(suite.repo.UpdateMagicOrderStatus(ctx, orderID, order.Status))
+	suite.Require().NoError(suite.repo.UpdateMagicOrderMetaData(ctx, orderID, []entities.MetaData{order.StatusHistoryMetaData()}))
+
+	stored, err := suite.repo.GetMagicOrder(ctx, orderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusProcessing, stored.Status)
+	suite.Require().Len(stored.StatusHistory, 2)
+	suite.Equal(string(entities.StatusPending), stored.StatusHistory[0].From)
+	suite.Equal(string(entities.StatusOnHold), stored.StatusHistory[0].To)
+	suite.Equal("PAYMENT.CAPTURE.COMPLETED", stored.StatusHistory[1].Reason)
+	suite.True(order.StatusHistory[1].At.Equal(stored.StatusHistory[1].At))
+
+	// The history stays on the original order
+	_, err = suite.repo.CreateOITAMOrder(ctx, stored.ToAnonymousOrder(nil))
+	suite.Require().NoError(err)
+	proxyOrder, err := suite.repo.GetOITAMOrder(ctx, "5000")
+	suite.Require().NoError(err)
+	suite.Empty(proxyOrder.StatusHistory)
+}
+
+// TestProxyOrderCanBeCancelledOrTrashed tests closing abandoned proxy orders and reading their creation stamp
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestProxyOrderCanBeCancelledOrTrashed() {
+	source, err := suite.repo.GetMagicOrder(context.Background(), suite.seedMagicOrder())
+	suite.Require().NoError(err)
+
+	cancelled, err := suite.repo.CreateOITAMOrder(context.Background(), source.ToAnonymousOrder(nil))
+	suite.Require().NoError(err)
+	trashed, err := suite.repo.CreateOITAMOrder(context.Background(), source.ToAnonymousOrder(nil))
+	suite.Require().NoError(err)
+
+	stamped := false
+	for _, meta := range trashed.MetaData {
+		stamped = stamped || meta.Key == "_proxy_created_at"
+	}
+	suite.True(stamped, "Proxy orders should carry their creation time")
+
+	cancelledID := strconv.Itoa(cancelled.ID)
+	suite.Require().NoError(suite.repo.UpdateOITAMOrderStatus(context.Background(), cancelledID, entities.StatusCancelled))
+	order, err := suite.repo.GetOITAMOrder(context.Background(), cancelledID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusCancelled, order.Status)
+
+	trashedID := strconv.Itoa(trashed.ID)
+	suite.Require().NoError(suite.repo.TrashOITAMOrder(context.Background(), trashedID))
+	order, err = suite.repo.GetOITAMOrder(context.Background(), trashedID)
+	suite.Require().NoError(err, "Trashed orders can still be fetched")
+	suite.Equal(entities.OrderStatus("trash"), order.Status)
+
+	suite.Require().NoError(suite.repo.TrashOITAMOrder(context.Background(), trashedID), "Trashing twice should succeed")
+	history := suite.oitam.StatusHistory(trashed.ID)
+	suite.Require().Len(history, 2)
+	suite.Equal("pending", history[1].From)
+	suite.Equal("trash", history[1].To)
+}
+
+// TestOrderNotes tests adding private notes and listing them newest first
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestOrderNotes() {
+	orderID := suite.seedMagicOrder()
+
+	suite.Require().NoError(suite.repo.AddMagicOrderNote(context.Background(), orderID, "First note."))
+	suite.Require().NoError(suite.repo.AddMagicOrderNote(context.Background(), orderID, "Second note."))
+	suite.Equal([]string{"First note.", "Second note."}, suite.magic.Notes(1000))
+
+	var notes []struct {
+		Note         string `json:"note"`
+		CustomerNote bool   `json:"customer_note"`
+	}
+	suite.storeGet(suite.magicServer.URL+"/wp-json/wc/v3/orders/1000/notes", "ck_magic", "cs_magic", &notes)
+	suite.Require().Len(notes, 2)
+	suite.Equal("Second note.", notes[0].Note)
+	suite.False(notes[0].CustomerNote)
+
+	err := suite.repo.AddMagicOrderNote(context.Background(), "999999", "Lost note.")
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "404")
+}
+
+// TestOrderRefunds tests partial and full refunds through the refunds endpoint
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestOrderRefunds() {
+	orderID := suite.seedMagicOrder()
+	ctx := context.Background()
+
+	refund, err := suite.repo.CreateMagicOrderRefund(ctx, orderID, &entities.OrderRefund{
+		Amount: entities.NewMoney(20.00, "PLN"),
+		Reason: "Damaged item",
+	})
+	suite.Require().NoError(err)
+	suite.NotZero(refund.ID)
+	suite.Equal(entities.NewMoney(20.00, "PLN"), refund.Amount)
+	suite.Equal("Damaged item", refund.Reason)
+
+	order, err := suite.repo.GetMagicOrder(ctx, orderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusPending, order.Status)
+
+	_, err = suite.repo.CreateMagicOrderRefund(ctx, orderID, &entities.OrderRefund{Amount: entities.NewMoney(45.00, "PLN")})
+	suite.Require().Error(err, "Refunds past the order total should be rejected")
+
+	_, err = suite.repo.CreateMagicOrderRefund(ctx, orderID, &entities.OrderRefund{Amount: entities.NewMoney(44.99, "PLN")})
+	suite.Require().NoError(err)
+	suite.Equal([]string{"20.00", "44.99"}, suite.magic.Refunds(1000))
+
+	order, err = suite.repo.GetMagicOrder(ctx, orderID)
+	suite.Require().NoError(err)
+	suite.Equal(entities.StatusRefunded, order.Status, "A fully refunded order should move to refunded")
+
+	var raw struct {
+		Refunds []struct {
+			Total string `json:"total"`
+		} `json:"refunds"`
+	}
+	suite.storeGet(suite.magicServer.URL+"/wp-json/wc/v3/orders/1000", "ck_magic", "cs_magic", &raw)
+	suite.Require().Len(raw.Refunds, 2)
+	suite.Equal("-44.99", raw.Refunds[0].Total)
+}
+
+// TestRefundsSkipGatewayAndRetries tests that refunds never go through the store gateway or repeat
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestRefundsSkipGatewayAndRetries() {
+	orderID := suite.seedMagicOrder()
+
+	// WooCommerce refunds through the order's gateway unless told otherwise
+	payload := bytes.NewBufferString(`{"amount": "10.00"}`)
+	req, err := http.NewRequest(http.MethodPost, suite.magicServer.URL+"/wp-json/wc/v3/orders/1000/refunds", payload)
+	suite.Require().NoError(err)
+	req.SetBasicAuth("ck_magic", "cs_magic")
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	resp.Body.Close()
+	suite.Equal(http.StatusInternalServerError, resp.StatusCode)
+
+	suite.magic.InjectFault(simulators.WooCommerceFault{Method: http.MethodPost, Status: http.StatusServiceUnavailable, Count: 1})
+	before := suite.magic.RequestCount()
+
+	_, err = suite.repo.CreateMagicOrderRefund(context.Background(), orderID, &entities.OrderRefund{Amount: entities.NewMoney(10.00, "PLN")})
+	suite.Require().Error(err)
+	suite.Equal(before+1, suite.magic.RequestCount(), "A failed refund should not be retried")
+	suite.Empty(suite.magic.Refunds(1000))
+}
+
+// TestAuthenticationIsChecked tests that wrong credentials are rejected without retries
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestAuthenticationIsChecked() {
+	orderID := suite.seedMagicOrder()
+	before := suite.magic.RequestCount()
+
+	_, err := suite.newRepository("ck_magic", "wrong").GetMagicOrder(context.Background(), orderID)
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "401")
+	suite.Equal(before+1, suite.magic.RequestCount())
+}
+
+// TestMissingOrderIsNotFound tests the not-found error for unknown orders
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestMissingOrderIsNotFound() {
+	_, err := suite.repo.GetMagicOrder(context.Background(), "999999")
+	suite.Require().Error(err)
+	suite.Equal("order 999999 not found", err.Error())
+
+	_, err = suite.repo.GetOITAMOrder(context.Background(), "999999")
+	suite.Require().Error(err)
+	suite.Contains(err.Error(), "not found")
+}
+
+// TestInjectedFaultIsRetried tests that a transient 503 on create is retried with the full body
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestInjectedFaultIsRetried() {
+	source, err := suite.repo.GetMagicOrder(context.Background(), suite.seedMagicOrder())
+	suite.Require().NoError(err)
+
+	suite.oitam.InjectFault(simulators.WooCommerceFault{Method: http.MethodPost, Status: http.StatusServiceUnavailable, Count: 1})
+
+	created, err := suite.repo.CreateOITAMOrder(context.Background(), source.ToAnonymousOrder(nil))
+	suite.Require().NoError(err)
+	suite.Equal("64.99", created.Total.Decimal())
+	suite.Equal(2, suite.oitam.RequestCount())
+}
+
+// TestLatencyRespectsContext tests that simulated latency honours request deadlines
+func (suite *WooCommerceSimulatorIntegrationTestSuite) TestLatencyRespectsContext() {
+	orderID := suite.seedMagicOrder()
+	suite.magic.SetLatency(2 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := suite.repo.GetMagicOrder(ctx, orderID)
+	suite.Error(err)
+	suite.Less(time.Since(start), time.Second)
+}
+
+// seedMagicOrder creates a MagicSpore order directly on the simulator
+func (suite *WooCommerceSimulatorIntegrationTestSuite) seedMagicOrder() string {
+	payload, err := json.Marshal(map[string]interface{}{
+		"currency": "PLN",
+		"billing":  map[string]string{"first_name": "Jan", "last_name": "Kowalski", "email": "jan@example.com"},
+		"line_items": []map[string]interface{}{
+			{"name": "Spore print", "product_id": 42, "quantity": 2, "total": "49.98"},
+		},
+		"shipping_lines": []map[string]interface{}{
+			{"method_id": "flat_rate", "method_title": "Courier", "total": "15.01"},
+		},
+	})
+	suite.Require().NoError(err)
+
+	req, err := http.NewRequest(http.MethodPost, suite.magicServer.URL+"/wp-json/wc/v3/orders", bytes.NewReader(payload))
+	suite.Require().NoError(err)
+	req.SetBasicAuth("ck_magic", "cs_magic")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	suite.Require().Equal(http.StatusCreated, resp.StatusCode)
+
+	var created struct {
+		Number string `json:"number"`
+	}
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(&created))
+	return created.Number
+}
+
+// storeGet reads a raw order from a simulator
+func (suite *WooCommerceSimulatorIntegrationTestSuite) storeGet(url, key, secret string, out interface{}) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	suite.Require().NoError(err)
+	req.SetBasicAuth(key, secret)
+
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+	suite.Require().NoError(json.NewDecoder(resp.Body).Decode(out))
+}
+
+// newRepository creates a repository for both simulators with the given MagicSpore credentials
+func (suite *WooCommerceSimulatorIntegrationTestSuite) newRepository(magicKey, magicSecret string) *repositories.WooCommerceRepository {
+	logger := infraHttp.NewDefaultLogger("error")
+
+	magicConfig := repositories.WooCommerceConfig{
+		URL:            suite.magicServer.URL,
+		ConsumerKey:    magicKey,
+		ConsumerSecret: magicSecret,
+		Timeout:        5 * time.Second,
+		RetryAttempts:  1,
+	}
+	oitamConfig := repositories.WooCommerceConfig{
+		URL:            suite.oitamServer.URL,
+		ConsumerKey:    "ck_oitam",
+		ConsumerSecret: "cs_oitam",
+		Timeout:        5 * time.Second,
+		RetryAttempts:  1,
+	}
+
+	return repositories.NewWooCommerceRepository(magicConfig, oitamConfig, logger).(*repositories.WooCommerceRepository)
+}
+
+// TestWooCommerceSimulatorIntegrationTestSuite runs the WooCommerce simulator suite
+func TestWooCommerceSimulatorIntegrationTestSuite(t *testing.T) {
+	suite.Run(t, new(WooCommerceSimulatorIntegrationTestSuite))
+}